@@ -0,0 +1,144 @@
+package banking
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	bicBankCodeLength = 8
+)
+
+const (
+	directoryColumnBic = iota
+	directoryColumnName
+	directoryColumnCountry
+	directoryColumnCity
+	directoryColumnsCount
+)
+
+var (
+	ErrorDirectoryFileEmpty   = errors.New("bank directory file is empty")
+	ErrorDirectoryFileInvalid = errors.New("bank directory file has invalid format")
+)
+
+// Bank
+type Bank struct {
+	Bic     string `json:"bic"`
+	Name    string `json:"name"`
+	Country string `json:"country"`
+	City    string `json:"city"`
+}
+
+// Directory is the BIC directory loaded from the CSV file with columns: bic, name, country, city.
+// The first row of the file is the header.
+type Directory struct {
+	path      string
+	mx        sync.RWMutex
+	banks     map[string]*Bank
+	updatedAt time.Time
+}
+
+// NewDirectory
+func NewDirectory(path string) *Directory {
+	return &Directory{
+		path:  path,
+		banks: make(map[string]*Bank),
+	}
+}
+
+// Reload replaces the directory content with the content of the directory file
+func (d *Directory) Reload() error {
+	file, err := os.Open(d.path)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := file.Close(); err != nil {
+			return
+		}
+	}()
+
+	return d.Load(file)
+}
+
+// Load replaces the directory content with the CSV data read from the reader
+func (d *Directory) Load(r io.Reader) error {
+	rows, err := csv.NewReader(r).ReadAll()
+
+	if err != nil {
+		return ErrorDirectoryFileInvalid
+	}
+
+	if len(rows) <= 1 {
+		return ErrorDirectoryFileEmpty
+	}
+
+	banks := make(map[string]*Bank, len(rows)-1)
+
+	for _, row := range rows[1:] {
+		if len(row) < directoryColumnsCount {
+			return ErrorDirectoryFileInvalid
+		}
+
+		bic := strings.ToUpper(strings.TrimSpace(row[directoryColumnBic]))
+
+		if len(bic) < bicBankCodeLength {
+			return ErrorDirectoryFileInvalid
+		}
+
+		banks[bic] = &Bank{
+			Bic:     bic,
+			Name:    strings.TrimSpace(row[directoryColumnName]),
+			Country: strings.ToUpper(strings.TrimSpace(row[directoryColumnCountry])),
+			City:    strings.TrimSpace(row[directoryColumnCity]),
+		}
+	}
+
+	d.mx.Lock()
+	d.banks = banks
+	d.updatedAt = time.Now()
+	d.mx.Unlock()
+
+	return nil
+}
+
+// Lookup returns the bank by the full BIC or by the first 8 characters of BIC (bank head office)
+func (d *Directory) Lookup(bic string) (*Bank, bool) {
+	bic = strings.ToUpper(strings.TrimSpace(bic))
+
+	d.mx.RLock()
+	defer d.mx.RUnlock()
+
+	if bank, ok := d.banks[bic]; ok {
+		return bank, true
+	}
+
+	if len(bic) > bicBankCodeLength {
+		bank, ok := d.banks[bic[:bicBankCodeLength]]
+		return bank, ok
+	}
+
+	return nil, false
+}
+
+// Len returns the number of banks in the directory
+func (d *Directory) Len() int {
+	d.mx.RLock()
+	defer d.mx.RUnlock()
+	return len(d.banks)
+}
+
+// UpdatedAt returns the time of the last successful directory load
+func (d *Directory) UpdatedAt() time.Time {
+	d.mx.RLock()
+	defer d.mx.RUnlock()
+	return d.updatedAt
+}
@@ -0,0 +1,216 @@
+package banking
+
+import (
+	"fmt"
+	"github.com/go-pascal/iban"
+	"strings"
+)
+
+const (
+	IssueCodeBicNotFound                = "bic_not_found"
+	IssueCodeBicCompanyCountryMismatch  = "bic_company_country_mismatch"
+	IssueCodeIbanBicCountryMismatch     = "iban_bic_country_mismatch"
+	IssueCodeIbanCompanyCountryMismatch = "iban_company_country_mismatch"
+	IssueCodeIbanExpected               = "iban_expected"
+	IssueCodeCurrencyCountryMismatch    = "currency_country_mismatch"
+
+	IssueFieldSwift         = "swift"
+	IssueFieldAccountNumber = "account_number"
+	IssueFieldCurrency      = "currency"
+
+	issueMessageBicNotFound                = "bank with swift code %s not found in the bank directory"
+	issueMessageBicCompanyCountryMismatch  = "bank country %s does not match the company country %s"
+	issueMessageIbanBicCountryMismatch     = "iban country %s does not match the bank country %s"
+	issueMessageIbanCompanyCountryMismatch = "iban country %s does not match the company country %s"
+	issueMessageIbanExpected               = "banks of country %s use iban as the account number"
+	issueMessageCurrencyCountryMismatch    = "account currency %s is not used for settlements in country %s"
+
+	bicCountryOffset = 4
+	bicCountryLength = 2
+)
+
+var (
+	// settlementCurrencies are the currencies which accounts are accepted in any country
+	settlementCurrencies = map[string]bool{"USD": true, "EUR": true, "GBP": true}
+
+	// ibanCurrencies contains the local currencies of the countries which use iban
+	ibanCurrencies = map[string]string{
+		"AD": "EUR", "AE": "AED", "AT": "EUR", "AZ": "AZN", "BA": "BAM", "BE": "EUR", "BG": "BGN", "BH": "BHD",
+		"BY": "BYN", "CH": "CHF", "CY": "EUR", "CZ": "CZK", "DE": "EUR", "DK": "DKK", "EE": "EUR", "ES": "EUR",
+		"FI": "EUR", "FR": "EUR", "GB": "GBP", "GE": "GEL", "GI": "GIP", "GR": "EUR", "HR": "HRK", "HU": "HUF",
+		"IE": "EUR", "IL": "ILS", "IS": "ISK", "IT": "EUR", "KZ": "KZT", "LI": "CHF", "LT": "EUR", "LU": "EUR",
+		"LV": "EUR", "MC": "EUR", "MD": "MDL", "ME": "EUR", "MT": "EUR", "NL": "EUR", "NO": "NOK", "PL": "PLN",
+		"PT": "EUR", "RO": "RON", "RS": "RSD", "SA": "SAR", "SE": "SEK", "SI": "EUR", "SK": "EUR", "SM": "EUR",
+		"TR": "TRY", "UA": "UAH", "VA": "EUR",
+	}
+
+	// ibanTerritories are the territories which have own bic country code but use the iban of the other country
+	ibanTerritories = map[string]string{
+		"JE": "GB", "GG": "GB", "IM": "GB",
+		"GF": "FR", "GP": "FR", "MQ": "FR", "RE": "FR", "YT": "FR", "PM": "FR", "BL": "FR", "MF": "FR",
+		"NC": "FR", "PF": "FR", "WF": "FR",
+		"AX": "FI",
+	}
+
+	// otherCurrencies contains the local currencies of the countries which don't use iban
+	otherCurrencies = map[string]string{
+		"AU": "AUD", "CA": "CAD", "CN": "CNY", "HK": "HKD", "IN": "INR", "JP": "JPY", "KR": "KRW", "RU": "RUB",
+		"SG": "SGD", "TH": "THB", "US": "USD",
+	}
+)
+
+// Issue describes the problem found in the merchant banking data
+type Issue struct {
+	Code    string `json:"code"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Verification
+type Verification struct {
+	Bank        *Bank    `json:"bank,omitempty"`
+	BicCountry  string   `json:"bic_country,omitempty"`
+	IbanCountry string   `json:"iban_country,omitempty"`
+	Warnings    []*Issue `json:"warnings"`
+	Errors      []*Issue `json:"errors"`
+}
+
+// HasErrors
+func (v *Verification) HasErrors() bool {
+	return len(v.Errors) > 0
+}
+
+// Account contains the banking data to verify
+type Account struct {
+	Swift          string
+	AccountNumber  string
+	Currency       string
+	CompanyCountry string
+}
+
+// Verifier checks the merchant banking data against the bank directory.
+// The mismatches which make the payout impossible are reported as errors, the others are reported as warnings.
+type Verifier struct {
+	directory *Directory
+}
+
+// NewVerifier
+func NewVerifier(directory *Directory) *Verifier {
+	return &Verifier{directory: directory}
+}
+
+// Directory
+func (v *Verifier) Directory() *Directory {
+	return v.directory
+}
+
+// Verify
+func (v *Verifier) Verify(account *Account) *Verification {
+	res := &Verification{
+		Warnings: []*Issue{},
+		Errors:   []*Issue{},
+	}
+
+	swift := strings.ToUpper(strings.TrimSpace(account.Swift))
+	companyCountry := strings.ToUpper(account.CompanyCountry)
+
+	if len(swift) >= bicCountryOffset+bicCountryLength {
+		res.BicCountry = swift[bicCountryOffset : bicCountryOffset+bicCountryLength]
+	}
+
+	if bank, ok := v.directory.Lookup(swift); ok {
+		res.Bank = bank
+	} else if swift != "" {
+		res.Warnings = append(res.Warnings, newIssue(IssueCodeBicNotFound, IssueFieldSwift, issueMessageBicNotFound, swift))
+	}
+
+	if res.BicCountry != "" && companyCountry != "" && res.BicCountry != companyCountry {
+		res.Warnings = append(res.Warnings, newIssue(
+			IssueCodeBicCompanyCountryMismatch,
+			IssueFieldSwift,
+			issueMessageBicCompanyCountryMismatch,
+			res.BicCountry,
+			companyCountry,
+		))
+	}
+
+	accountNumber := strings.ToUpper(strings.Replace(account.AccountNumber, " ", "", -1))
+
+	if _, err := iban.NewIBAN(accountNumber); err == nil {
+		res.IbanCountry = accountNumber[:bicCountryLength]
+	}
+
+	if res.IbanCountry != "" {
+		if res.BicCountry != "" && res.IbanCountry != getIbanCountry(res.BicCountry) {
+			res.Errors = append(res.Errors, newIssue(
+				IssueCodeIbanBicCountryMismatch,
+				IssueFieldAccountNumber,
+				issueMessageIbanBicCountryMismatch,
+				res.IbanCountry,
+				res.BicCountry,
+			))
+		}
+
+		if companyCountry != "" && res.IbanCountry != getIbanCountry(companyCountry) {
+			res.Warnings = append(res.Warnings, newIssue(
+				IssueCodeIbanCompanyCountryMismatch,
+				IssueFieldAccountNumber,
+				issueMessageIbanCompanyCountryMismatch,
+				res.IbanCountry,
+				companyCountry,
+			))
+		}
+	} else if _, ok := ibanCurrencies[getIbanCountry(res.BicCountry)]; ok {
+		res.Warnings = append(res.Warnings, newIssue(
+			IssueCodeIbanExpected,
+			IssueFieldAccountNumber,
+			issueMessageIbanExpected,
+			res.BicCountry,
+		))
+	}
+
+	accountCountry := res.IbanCountry
+
+	if accountCountry == "" {
+		accountCountry = res.BicCountry
+	}
+
+	currency := strings.ToUpper(account.Currency)
+
+	if currency != "" && !settlementCurrencies[currency] {
+		local, ok := ibanCurrencies[accountCountry]
+
+		if !ok {
+			local, ok = otherCurrencies[accountCountry]
+		}
+
+		if ok && local != currency {
+			res.Warnings = append(res.Warnings, newIssue(
+				IssueCodeCurrencyCountryMismatch,
+				IssueFieldCurrency,
+				issueMessageCurrencyCountryMismatch,
+				currency,
+				accountCountry,
+			))
+		}
+	}
+
+	return res
+}
+
+// getIbanCountry returns the country code used in the iban of the country
+func getIbanCountry(country string) string {
+	if ibanCountry, ok := ibanTerritories[country]; ok {
+		return ibanCountry
+	}
+
+	return country
+}
+
+func newIssue(code, field, mask string, args ...interface{}) *Issue {
+	return &Issue{
+		Code:    code,
+		Field:   field,
+		Message: fmt.Sprintf(mask, args...),
+	}
+}
@@ -0,0 +1,140 @@
+package banking
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+const (
+	directoryTestFile = "./../../assets/banking/bic_directory.csv"
+)
+
+func loadTestDirectory(t *testing.T) *Directory {
+	directory := NewDirectory(directoryTestFile)
+	require.NoError(t, directory.Reload())
+	require.True(t, directory.Len() > 0)
+
+	return directory
+}
+
+func issueCodes(issues []*Issue) []string {
+	codes := []string{}
+
+	for _, issue := range issues {
+		codes = append(codes, issue.Code)
+	}
+
+	return codes
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	verifier := NewVerifier(loadTestDirectory(t))
+
+	tests := []struct {
+		name        string
+		account     *Account
+		bank        string
+		bicCountry  string
+		ibanCountry string
+		warnings    []string
+		errors      []string
+	}{
+		{
+			name:        "valid iban",
+			account:     &Account{Swift: "NWBKGB2L", AccountNumber: "GB29NWBK60161331926819", Currency: "GBP", CompanyCountry: "GB"},
+			bank:        "NWBKGB2L",
+			bicCountry:  "GB",
+			ibanCountry: "GB",
+		},
+		{
+			name:        "valid iban with spaces in lower case",
+			account:     &Account{Swift: "nwbkgb2l", AccountNumber: "gb29 nwbk 6016 1331 9268 19", CompanyCountry: "gb"},
+			bank:        "NWBKGB2L",
+			bicCountry:  "GB",
+			ibanCountry: "GB",
+		},
+		{
+			name:       "invalid iban checksum",
+			account:    &Account{Swift: "NWBKGB2L", AccountNumber: "GB29NWBK60161331926818", CompanyCountry: "GB"},
+			bank:       "NWBKGB2L",
+			bicCountry: "GB",
+			warnings:   []string{IssueCodeIbanExpected},
+		},
+		{
+			name:        "bic with branch code",
+			account:     &Account{Swift: "DEUTDEFF500", AccountNumber: "DE89370400440532013000", Currency: "EUR", CompanyCountry: "DE"},
+			bank:        "DEUTDEFF",
+			bicCountry:  "DE",
+			ibanCountry: "DE",
+		},
+		{
+			name:        "bic of territory with iban of other country",
+			account:     &Account{Swift: "NWBKJESHXXX", AccountNumber: "GB29NWBK60161331926819", Currency: "GBP", CompanyCountry: "JE"},
+			bicCountry:  "JE",
+			ibanCountry: "GB",
+			warnings:    []string{IssueCodeBicNotFound},
+		},
+		{
+			name:        "iban country mismatch",
+			account:     &Account{Swift: "DEUTDEFF", AccountNumber: "FR1420041010050500013M02606", CompanyCountry: "DE"},
+			bank:        "DEUTDEFF",
+			bicCountry:  "DE",
+			ibanCountry: "FR",
+			warnings:    []string{IssueCodeIbanCompanyCountryMismatch},
+			errors:      []string{IssueCodeIbanBicCountryMismatch},
+		},
+		{
+			name:        "company country mismatch",
+			account:     &Account{Swift: "DEUTDEFF", AccountNumber: "DE89370400440532013000", CompanyCountry: "FR"},
+			bank:        "DEUTDEFF",
+			bicCountry:  "DE",
+			ibanCountry: "DE",
+			warnings:    []string{IssueCodeBicCompanyCountryMismatch, IssueCodeIbanCompanyCountryMismatch},
+		},
+		{
+			name:       "currency country mismatch",
+			account:    &Account{Swift: "SABRRUMM", AccountNumber: "40702810000000000001", Currency: "KZT", CompanyCountry: "RU"},
+			bank:       "SABRRUMM",
+			bicCountry: "RU",
+			warnings:   []string{IssueCodeCurrencyCountryMismatch},
+		},
+		{
+			name:       "settlement currency in any country",
+			account:    &Account{Swift: "SABRRUMM", AccountNumber: "40702810000000000001", Currency: "USD", CompanyCountry: "RU"},
+			bank:       "SABRRUMM",
+			bicCountry: "RU",
+		},
+	}
+
+	for _, tt := range tests {
+		res := verifier.Verify(tt.account)
+
+		if tt.bank == "" {
+			assert.Nil(t, res.Bank, tt.name)
+		} else if assert.NotNil(t, res.Bank, tt.name) {
+			assert.Equal(t, tt.bank, res.Bank.Bic, tt.name)
+		}
+
+		assert.Equal(t, tt.bicCountry, res.BicCountry, tt.name)
+		assert.Equal(t, tt.ibanCountry, res.IbanCountry, tt.name)
+
+		if tt.warnings == nil {
+			tt.warnings = []string{}
+		}
+
+		if tt.errors == nil {
+			tt.errors = []string{}
+		}
+
+		assert.Equal(t, tt.warnings, issueCodes(res.Warnings), tt.name)
+		assert.Equal(t, tt.errors, issueCodes(res.Errors), tt.name)
+		assert.Equal(t, len(tt.errors) > 0, res.HasErrors(), tt.name)
+	}
+}
+
+func TestDirectory_Reload_NotFound(t *testing.T) {
+	directory := NewDirectory(directoryTestFile + ".unknown")
+	assert.Error(t, directory.Reload())
+	assert.Equal(t, 0, directory.Len())
+}
@@ -0,0 +1,153 @@
+package branding
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"time"
+)
+
+const (
+	TemplateAgreement = "agreement"
+	TemplateReceipt   = "receipt"
+
+	// LogoMaxSize is the max size of the logo file in bytes
+	LogoMaxSize = 1 << 20
+	// LegalFooterMaxLength is the max length of the legal footer text
+	LegalFooterMaxLength = 4000
+	// TemplateMaxLength is the max length of the template source
+	TemplateMaxLength = 256 << 10
+	// VersionDefault is the version of the default templates
+	VersionDefault = "default"
+)
+
+var (
+	ErrorTemplateUnknown     = errors.New("document template is unknown")
+	ErrorTemplateInvalid     = errors.New("document template can't be parsed")
+	ErrorTemplateTooLong     = errors.New("document template is too long")
+	ErrorLegalFooterTooLong  = errors.New("legal footer is too long")
+	ErrorLogoContentType     = errors.New("logo content type isn't allowed")
+	ErrorLogoMaxSize         = errors.New("logo file is too large")
+	ErrorLogoNotFound        = errors.New("logo isn't uploaded")
+	ErrorTemplateRenderError = errors.New("document template can't be rendered")
+
+	// Templates are the documents which can be branded by the operating company
+	Templates = []string{TemplateAgreement, TemplateReceipt}
+	// LogoContentTypes are the allowed content types of the logo
+	LogoContentTypes = []string{"image/png", "image/jpeg", "image/gif"}
+)
+
+// Logo is the uploaded logo of the operating company
+type Logo struct {
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	UploadedBy  string    `json:"uploaded_by"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+}
+
+// TemplateSet is the branding of the documents issued on behalf of the operating company.
+// The templates are html/template sources by the document name, the missed ones are rendered by the default templates.
+type TemplateSet struct {
+	OperatingCompanyId string            `json:"operating_company_id"`
+	LegalFooter        string            `json:"legal_footer"`
+	Templates          map[string]string `json:"templates"`
+	Logo               *Logo             `json:"logo,omitempty"`
+	UpdatedBy          string            `json:"updated_by,omitempty"`
+	UpdatedAt          *time.Time        `json:"updated_at,omitempty"`
+}
+
+// NewTemplateSet returns the empty set of the operating company, all documents of the set use the default templates
+func NewTemplateSet(operatingCompanyId string) *TemplateSet {
+	return &TemplateSet{OperatingCompanyId: operatingCompanyId, Templates: make(map[string]string)}
+}
+
+// Validate checks the legal footer and parses every template of the set
+func (s *TemplateSet) Validate(funcs template.FuncMap) error {
+	if len(s.LegalFooter) > LegalFooterMaxLength {
+		return ErrorLegalFooterTooLong
+	}
+
+	for name, source := range s.Templates {
+		if !IsTemplate(name) {
+			return ErrorTemplateUnknown
+		}
+
+		if len(source) > TemplateMaxLength {
+			return ErrorTemplateTooLong
+		}
+
+		if _, err := parse(name, source, funcs); err != nil {
+			return ErrorTemplateInvalid
+		}
+	}
+
+	return nil
+}
+
+// HasTemplate checks whether the document is branded by the set
+func (s *TemplateSet) HasTemplate(name string) bool {
+	return s != nil && s.Templates[name] != ""
+}
+
+// IsBranded checks whether the document differs from the default one, the logo and the legal footer
+// are used by the default templates too
+func (s *TemplateSet) IsBranded(name string) bool {
+	return s.HasTemplate(name) || s != nil && (s.Logo != nil || s.LegalFooter != "")
+}
+
+// Version identifies the saved state of the set, every change of the set changes its version
+func (s *TemplateSet) Version() string {
+	if s == nil || s.UpdatedAt == nil {
+		return VersionDefault
+	}
+
+	return fmt.Sprintf("%s_%d", s.OperatingCompanyId, s.UpdatedAt.UnixNano())
+}
+
+// Render executes the template of the set with the data
+func (s *TemplateSet) Render(name string, data interface{}, funcs template.FuncMap) ([]byte, error) {
+	if !s.HasTemplate(name) {
+		return nil, ErrorTemplateUnknown
+	}
+
+	tpl, err := parse(name, s.Templates[name], funcs)
+
+	if err != nil {
+		return nil, ErrorTemplateInvalid
+	}
+
+	buf := &bytes.Buffer{}
+
+	if err = tpl.Execute(buf, data); err != nil {
+		return nil, ErrorTemplateRenderError
+	}
+
+	return buf.Bytes(), nil
+}
+
+// IsTemplate checks whether the document can be branded
+func IsTemplate(name string) bool {
+	for _, item := range Templates {
+		if item == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsLogoContentType checks whether the content type is allowed for the logo
+func IsLogoContentType(contentType string) bool {
+	for _, item := range LogoContentTypes {
+		if item == contentType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parse(name, source string, funcs template.FuncMap) (*template.Template, error) {
+	return template.New(name).Funcs(funcs).Parse(source)
+}
@@ -0,0 +1,93 @@
+package branding
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"html/template"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+}
+
+func TestTemplateSet_Validate(t *testing.T) {
+	set := NewTemplateSet("operating_company_id")
+	assert.NoError(t, set.Validate(testFuncs))
+
+	set.Templates[TemplateReceipt] = `<p>{{ upper .Name }}</p>`
+	assert.NoError(t, set.Validate(testFuncs))
+
+	set.Templates[TemplateReceipt] = `<p>{{ unknown .Name }}</p>`
+	assert.Equal(t, ErrorTemplateInvalid, set.Validate(testFuncs))
+
+	set.Templates[TemplateReceipt] = strings.Repeat("a", TemplateMaxLength+1)
+	assert.Equal(t, ErrorTemplateTooLong, set.Validate(testFuncs))
+
+	delete(set.Templates, TemplateReceipt)
+	set.Templates["invoice"] = `<p></p>`
+	assert.Equal(t, ErrorTemplateUnknown, set.Validate(testFuncs))
+
+	delete(set.Templates, "invoice")
+	set.LegalFooter = strings.Repeat("a", LegalFooterMaxLength+1)
+	assert.Equal(t, ErrorLegalFooterTooLong, set.Validate(testFuncs))
+}
+
+func TestTemplateSet_Render(t *testing.T) {
+	var empty *TemplateSet
+	assert.False(t, empty.HasTemplate(TemplateAgreement))
+
+	set := NewTemplateSet("operating_company_id")
+	set.Templates[TemplateAgreement] = `<p>{{ upper .Name }}</p>`
+	assert.True(t, set.HasTemplate(TemplateAgreement))
+	assert.False(t, set.HasTemplate(TemplateReceipt))
+
+	out, err := set.Render(TemplateAgreement, map[string]string{"Name": "<company>"}, testFuncs)
+	require.NoError(t, err)
+	assert.Equal(t, "<p>&lt;COMPANY&gt;</p>", string(out))
+
+	_, err = set.Render(TemplateReceipt, nil, testFuncs)
+	assert.Equal(t, ErrorTemplateUnknown, err)
+
+	set.Templates[TemplateAgreement] = `<p>{{ .Name.Field }}</p>`
+	_, err = set.Render(TemplateAgreement, map[string]string{"Name": "company"}, testFuncs)
+	assert.Equal(t, ErrorTemplateRenderError, err)
+}
+
+func TestTemplateSet_IsBranded(t *testing.T) {
+	var empty *TemplateSet
+	assert.False(t, empty.IsBranded(TemplateAgreement))
+
+	set := NewTemplateSet("operating_company_id")
+	assert.False(t, set.IsBranded(TemplateAgreement))
+
+	set.Templates[TemplateReceipt] = `<p></p>`
+	assert.False(t, set.IsBranded(TemplateAgreement))
+	assert.True(t, set.IsBranded(TemplateReceipt))
+
+	set.LegalFooter = "Legal footer"
+	assert.True(t, set.IsBranded(TemplateAgreement))
+}
+
+func TestTemplateSet_Version(t *testing.T) {
+	var empty *TemplateSet
+	assert.Equal(t, VersionDefault, empty.Version())
+
+	set := NewTemplateSet("operating_company_id")
+	assert.Equal(t, VersionDefault, set.Version())
+
+	updatedAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	set.UpdatedAt = &updatedAt
+	version := set.Version()
+	assert.Contains(t, version, "operating_company_id")
+
+	updatedAt = updatedAt.Add(time.Second)
+	assert.NotEqual(t, version, set.Version())
+}
+
+func TestIsLogoContentType(t *testing.T) {
+	assert.True(t, IsLogoContentType("image/png"))
+	assert.False(t, IsLogoContentType("image/svg+xml"))
+}
@@ -0,0 +1,103 @@
+package branding
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	awsWrapper "github.com/paysuper/paysuper-aws-manager"
+	"github.com/paysuper/paysuper-management-api/internal/storage"
+	"io"
+	"time"
+)
+
+const (
+	setFileMask     = "operating_companies/%s/branding.json"
+	logoFileMask    = "operating_companies/%s/logo"
+	logoDataUriMask = "data:%s;base64,%s"
+)
+
+// Store keeps the template sets and the logos of the operating companies in the reporter bucket
+type Store struct {
+	files *storage.Store
+}
+
+// NewStore
+func NewStore(awsManager awsWrapper.AwsManagerInterface) *Store {
+	return &Store{files: storage.New(awsManager)}
+}
+
+// Get returns the template set of the operating company, the company without the branding gets the empty set
+func (s *Store) Get(ctx context.Context, operatingCompanyId string) (*TemplateSet, error) {
+	set := NewTemplateSet(operatingCompanyId)
+
+	if _, err := s.files.Load(ctx, fmt.Sprintf(setFileMask, operatingCompanyId), set); err != nil {
+		return nil, err
+	}
+
+	return set, nil
+}
+
+// Save replaces the template set of the operating company
+func (s *Store) Save(ctx context.Context, set *TemplateSet, userId string) error {
+	updatedAt := time.Now().UTC()
+	set.UpdatedBy = userId
+	set.UpdatedAt = &updatedAt
+
+	return s.files.Save(ctx, fmt.Sprintf(setFileMask, set.OperatingCompanyId), set)
+}
+
+// UploadLogo replaces the logo of the operating company and saves it to the template set
+func (s *Store) UploadLogo(
+	ctx context.Context,
+	set *TemplateSet,
+	contentType string,
+	size int64,
+	body io.Reader,
+	userId string,
+) error {
+	if !IsLogoContentType(contentType) {
+		return ErrorLogoContentType
+	}
+
+	if size > LogoMaxSize {
+		return ErrorLogoMaxSize
+	}
+
+	if err := s.files.Upload(ctx, fmt.Sprintf(logoFileMask, set.OperatingCompanyId), body); err != nil {
+		return err
+	}
+
+	set.Logo = &Logo{
+		ContentType: contentType,
+		Size:        size,
+		UploadedBy:  userId,
+		UploadedAt:  time.Now().UTC(),
+	}
+
+	return s.Save(ctx, set, userId)
+}
+
+// LogoDataUri returns the logo of the operating company as the data uri to be inlined into the documents
+func (s *Store) LogoDataUri(ctx context.Context, set *TemplateSet) (string, error) {
+	if set.Logo == nil {
+		return "", ErrorLogoNotFound
+	}
+
+	data, err := s.files.Read(ctx, fmt.Sprintf(logoFileMask, set.OperatingCompanyId))
+
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(logoDataUriMask, set.Logo.ContentType, base64.StdEncoding.EncodeToString(data)), nil
+}
+
+// DownloadLogo saves the logo of the operating company to the new temporary file and returns the path to the file.
+// The caller removes the temporary file when it's served.
+func (s *Store) DownloadLogo(ctx context.Context, set *TemplateSet) (string, error) {
+	if set.Logo == nil {
+		return "", ErrorLogoNotFound
+	}
+
+	return s.files.Download(ctx, fmt.Sprintf(logoFileMask, set.OperatingCompanyId))
+}
@@ -0,0 +1,375 @@
+package callback
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/paysuper/paysuper-management-api/pkg/signature"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+)
+
+const (
+	TypeCheckAccount      = "check_account"
+	TypeProcessPayment    = "process_payment"
+	TypeRefundPayment     = "refund_payment"
+	TypeChargebackPayment = "chargeback_payment"
+	TypeCancelPayment     = "cancel_payment"
+	TypeFraudPayment      = "fraud_payment"
+
+	responseBodyMax     = 64 * 1024
+	sampleUserId        = "test_user"
+	sampleUserEmail     = "test_user@paysuper.test"
+	sampleOrderAmount   = 10
+	sampleOrderCurrency = "USD"
+)
+
+var (
+	ErrorUrlInvalid     = errors.New("callback url must be absolute http or https url")
+	ErrorUrlForbidden   = errors.New("callback url must resolve to public ip addresses")
+	ErrorSecretKeyEmpty = errors.New("project secret key is empty")
+	ErrorTypeUnknown    = errors.New("callback type is unknown")
+
+	// events are the notification event names sent to the merchant for every callback type
+	events = map[string]string{
+		TypeCheckAccount:      "user.check_account",
+		TypeProcessPayment:    "payment.processed",
+		TypeRefundPayment:     "payment.refunded",
+		TypeChargebackPayment: "payment.chargeback",
+		TypeCancelPayment:     "payment.canceled",
+		TypeFraudPayment:      "payment.fraud",
+	}
+
+	// forbiddenNetworks are the non public networks the notifications are never sent to,
+	// so the tester can't be used to reach the internal services
+	forbiddenNetworks = parseNetworks(
+		"0.0.0.0/8",
+		"10.0.0.0/8",
+		"100.64.0.0/10",
+		"127.0.0.0/8",
+		"169.254.0.0/16",
+		"172.16.0.0/12",
+		"192.0.0.0/24",
+		"192.168.0.0/16",
+		"198.18.0.0/15",
+		"224.0.0.0/3",
+		"::/128",
+		"::1/128",
+		"fc00::/7",
+		"fe80::/10",
+		"ff00::/8",
+	)
+)
+
+// Notification is the sample notification sent to the project callback url
+type Notification struct {
+	Id        string      `json:"id"`
+	Type      string      `json:"type"`
+	Event     string      `json:"event"`
+	ProjectId string      `json:"project_id"`
+	Test      bool        `json:"test"`
+	CreatedAt time.Time   `json:"created_at"`
+	Object    interface{} `json:"object"`
+}
+
+type sampleUser struct {
+	Id    string `json:"id"`
+	Email string `json:"email"`
+}
+
+type sampleOrder struct {
+	Id       string      `json:"id"`
+	Status   string      `json:"status"`
+	Amount   float64     `json:"amount"`
+	Currency string      `json:"currency"`
+	User     *sampleUser `json:"user"`
+}
+
+// Signature describes the signature sent with the notification
+type Signature struct {
+	Header   string `json:"header"`
+	Value    string `json:"value"`
+	Valid    bool   `json:"valid"`
+	Accepted bool   `json:"accepted"`
+}
+
+// Result is the report of the single callback test
+type Result struct {
+	Type         string     `json:"type"`
+	Url          string     `json:"url"`
+	Request      string     `json:"request"`
+	Status       int        `json:"status"`
+	Latency      int64      `json:"latency_ms"`
+	ResponseBody string     `json:"response_body"`
+	Truncated    bool       `json:"truncated"`
+	Error        string     `json:"error,omitempty"`
+	Signature    *Signature `json:"signature"`
+}
+
+// Tester sends the sample notifications to the project callback urls.
+// In the local mode the notifications are sent to the local server verifying the signature instead of the project url.
+//
+// The project urls are merchant controlled, so the tester connects only to the public ip addresses:
+// the host is resolved once, every resolved address is checked and the connection is made to the checked address,
+// the redirects aren't followed and the proxy of the environment isn't used.
+type Tester struct {
+	client      *http.Client
+	localClient *http.Client
+	resolver    *net.Resolver
+	allowed     func(ip net.IP) bool
+	local       bool
+}
+
+// NewTester
+func NewTester(timeout time.Duration, local bool) *Tester {
+	t := &Tester{
+		localClient: &http.Client{Timeout: timeout},
+		resolver:    net.DefaultResolver,
+		allowed:     isPublicIp,
+		local:       local,
+	}
+	t.client = &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{DialContext: t.dialContext},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return t
+}
+
+// Test sends the sample notification of the type to the url.
+// With invalidSignature the notification is signed with the wrong key to check the merchant rejects it.
+func (t *Tester) Test(ctx context.Context, typ, rawUrl, projectId, secretKey string, invalidSignature bool) (*Result, error) {
+	event, ok := events[typ]
+
+	if !ok {
+		return nil, ErrorTypeUnknown
+	}
+
+	if secretKey == "" {
+		return nil, ErrorSecretKeyEmpty
+	}
+
+	u, err := url.Parse(rawUrl)
+
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrorUrlInvalid
+	}
+
+	body, err := json.Marshal(newNotification(typ, event, projectId))
+
+	if err != nil {
+		return nil, err
+	}
+
+	signKey := secretKey
+
+	if invalidSignature {
+		signKey = uuid.New().String()
+	}
+
+	sign := signature.Sign(body, signKey)
+	result := &Result{
+		Type:    typ,
+		Url:     rawUrl,
+		Request: string(body),
+		Signature: &Signature{
+			Header: signature.Header,
+			Value:  sign,
+			Valid:  signature.Verify(body, secretKey, sign),
+		},
+	}
+
+	client := t.client
+
+	if t.local {
+		server := httptest.NewServer(NewLocalHandler(secretKey))
+		defer server.Close()
+		rawUrl = server.URL
+		client = t.localClient
+	} else if _, err = t.resolve(ctx, u.Hostname()); err == ErrorUrlForbidden {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, rawUrl, bytes.NewReader(body))
+
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(signature.Header, sign)
+
+	start := time.Now()
+	rsp, err := client.Do(req)
+	result.Latency = int64(time.Since(start) / time.Millisecond)
+
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+
+	defer func() {
+		if err := rsp.Body.Close(); err != nil {
+			return
+		}
+	}()
+
+	data, err := ioutil.ReadAll(io.LimitReader(rsp.Body, responseBodyMax+1))
+
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	if len(data) > responseBodyMax {
+		data = data[:responseBodyMax]
+		result.Truncated = true
+	}
+
+	result.Status = rsp.StatusCode
+	result.ResponseBody = string(data)
+	result.Signature.Accepted = rsp.StatusCode >= http.StatusOK && rsp.StatusCode < http.StatusMultipleChoices
+
+	return result, nil
+}
+
+// dialContext connects to the first allowed address of the host, the addresses are checked right before the dial,
+// so the host resolving to the other address after the url check can't redirect the notification
+func (t *Tester) dialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ips, err := t.resolve(ctx, host)
+
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{}
+	var conn net.Conn
+
+	for _, ip := range ips {
+		conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+
+		if err == nil {
+			return conn, nil
+		}
+	}
+
+	return nil, err
+}
+
+// resolve returns the addresses of the host, the host is forbidden when any of its addresses isn't allowed
+func (t *Tester) resolve(ctx context.Context, host string) ([]net.IP, error) {
+	addrs, err := t.resolver.LookupIPAddr(ctx, host)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(addrs) == 0 {
+		return nil, ErrorUrlForbidden
+	}
+
+	ips := make([]net.IP, 0, len(addrs))
+
+	for _, addr := range addrs {
+		if !t.allowed(addr.IP) {
+			return nil, ErrorUrlForbidden
+		}
+
+		ips = append(ips, addr.IP)
+	}
+
+	return ips, nil
+}
+
+// NewLocalHandler returns the handler acting as the merchant callback endpoint.
+// It answers 200 for the notification with the valid signature and 403 otherwise.
+func NewLocalHandler(secretKey string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if !signature.Verify(body, secretKey, r.Header.Get(signature.Header)) {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"status":"error","message":"invalid signature"}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	})
+}
+
+func isPublicIp(ip net.IP) bool {
+	for _, network := range forbiddenNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+
+		if err != nil {
+			panic(err)
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks
+}
+
+func newNotification(typ, event, projectId string) *Notification {
+	notification := &Notification{
+		Id:        uuid.New().String(),
+		Type:      typ,
+		Event:     event,
+		ProjectId: projectId,
+		Test:      true,
+		CreatedAt: time.Now().UTC(),
+	}
+	user := &sampleUser{Id: sampleUserId, Email: sampleUserEmail}
+
+	if typ == TypeCheckAccount {
+		notification.Object = user
+		return notification
+	}
+
+	notification.Object = &sampleOrder{
+		Id:       uuid.New().String(),
+		Status:   typ,
+		Amount:   sampleOrderAmount,
+		Currency: sampleOrderCurrency,
+		User:     user,
+	}
+
+	return notification
+}
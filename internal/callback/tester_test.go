@@ -0,0 +1,91 @@
+package callback
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTester_Test_ForbiddenUrl(t *testing.T) {
+	tester := NewTester(time.Second, false)
+
+	for _, rawUrl := range []string{
+		"http://127.0.0.1:8080/callback",
+		"http://localhost/callback",
+		"http://10.0.0.1/callback",
+		"http://169.254.169.254/latest/meta-data",
+		"https://[::1]/callback",
+		"http://[::ffff:192.168.0.1]/callback",
+	} {
+		_, err := tester.Test(context.Background(), TypeProcessPayment, rawUrl, "project", "secret", false)
+		assert.Equal(t, ErrorUrlForbidden, err, rawUrl)
+	}
+
+	_, err := tester.Test(context.Background(), TypeProcessPayment, "ftp://example.com/callback", "project", "secret", false)
+	assert.Equal(t, ErrorUrlInvalid, err)
+}
+
+func TestTester_Test_RedirectNotFollowed(t *testing.T) {
+	redirected := false
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected = true
+	}))
+	defer target.Close()
+
+	server := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusFound))
+	defer server.Close()
+
+	tester := NewTester(time.Second, false)
+	tester.allowed = func(ip net.IP) bool { return true }
+
+	result, err := tester.Test(context.Background(), TypeProcessPayment, server.URL, "project", "secret", false)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusFound, result.Status)
+	assert.False(t, result.Signature.Accepted)
+	assert.False(t, redirected)
+}
+
+func TestTester_Test_AddressCheckedOnDial(t *testing.T) {
+	server := httptest.NewServer(NewLocalHandler("secret"))
+	defer server.Close()
+
+	checks := 0
+	tester := NewTester(time.Second, false)
+	tester.allowed = func(ip net.IP) bool {
+		checks++
+		return checks == 1
+	}
+
+	result, err := tester.Test(context.Background(), TypeProcessPayment, server.URL, "project", "secret", false)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.Status)
+	assert.True(t, strings.Contains(result.Error, ErrorUrlForbidden.Error()))
+}
+
+func TestIsPublicIp(t *testing.T) {
+	cases := map[string]bool{
+		"8.8.8.8":              true,
+		"2001:4860:4860::8888": true,
+		"127.0.0.1":            false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"100.64.0.1":           false,
+		"0.0.0.0":              false,
+		"::1":                  false,
+		"fe80::1":              false,
+		"fd00::1":              false,
+		"::ffff:127.0.0.1":     false,
+	}
+
+	for ip, public := range cases {
+		assert.Equal(t, public, isPublicIp(net.ParseIP(ip)), ip)
+	}
+}
@@ -0,0 +1,117 @@
+package dataexport
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	StatusPending    = "pending"
+	StatusProcessing = "processing"
+	StatusReady      = "ready"
+	StatusFailed     = "failed"
+	StatusExpired    = "expired"
+
+	// MimeType is the content type of the export bundle
+	MimeType = "application/zip"
+
+	// bundleManifestName is the file of the bundle describing its content
+	bundleManifestName = "manifest.json"
+)
+
+var (
+	ErrorExportNotFound   = errors.New("merchant data export not found")
+	ErrorExportInProgress = errors.New("merchant data export is in progress already")
+	ErrorExportNotReady   = errors.New("merchant data export isn't ready yet")
+	ErrorExportExpired    = errors.New("merchant data export is expired")
+)
+
+// Export is the request of the merchant for the bundle of all merchant data
+type Export struct {
+	Id          string     `json:"id"`
+	MerchantId  string     `json:"merchant_id"`
+	Status      string     `json:"status"`
+	Size        int64      `json:"size,omitempty"`
+	Files       []string   `json:"files,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	CreatedBy   string     `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// IsActive checks whether the export bundle is being collected
+func (e *Export) IsActive() bool {
+	return e.Status == StatusPending || e.Status == StatusProcessing
+}
+
+// Bundle is the ZIP archive of the merchant data. Every data section is the separate JSON file,
+// the attached documents are kept as is.
+type Bundle struct {
+	buf    *bytes.Buffer
+	writer *zip.Writer
+	files  []string
+}
+
+// NewBundle
+func NewBundle() *Bundle {
+	buf := &bytes.Buffer{}
+
+	return &Bundle{buf: buf, writer: zip.NewWriter(buf), files: make([]string, 0)}
+}
+
+// AddJson adds the data as the indented JSON file
+func (b *Bundle) AddJson(name string, data interface{}) error {
+	content, err := json.MarshalIndent(data, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	return b.AddFile(name, content)
+}
+
+// AddFile adds the file with the content
+func (b *Bundle) AddFile(name string, content []byte) error {
+	w, err := b.writer.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = w.Write(content); err != nil {
+		return err
+	}
+
+	b.files = append(b.files, name)
+
+	return nil
+}
+
+// Close adds the manifest of the bundle files and returns the archive content
+func (b *Bundle) Close(export *Export) ([]byte, error) {
+	manifest := map[string]interface{}{
+		"merchant_id":  export.MerchantId,
+		"export_id":    export.Id,
+		"generated_at": time.Now().UTC(),
+		"files":        b.files,
+	}
+
+	if err := b.AddJson(bundleManifestName, manifest); err != nil {
+		return nil, err
+	}
+
+	if err := b.writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return b.buf.Bytes(), nil
+}
+
+// Files returns the names of the added files
+func (b *Bundle) Files() []string {
+	return b.files
+}
@@ -0,0 +1,61 @@
+package dataexport
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"testing"
+)
+
+func TestBundle_Close(t *testing.T) {
+	export := &Export{Id: "export_id", MerchantId: "merchant_id"}
+	bundle := NewBundle()
+
+	require.NoError(t, bundle.AddJson("merchant.json", map[string]string{"id": "merchant_id"}))
+	require.NoError(t, bundle.AddFile("agreements/agreement.pdf", []byte("%PDF")))
+
+	content, err := bundle.Close(export)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"merchant.json", "agreements/agreement.pdf", bundleManifestName}, bundle.Files())
+
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	require.NoError(t, err)
+	require.Len(t, reader.File, 3)
+
+	files := make(map[string][]byte)
+
+	for _, file := range reader.File {
+		rc, err := file.Open()
+		require.NoError(t, err)
+		data, err := ioutil.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+		files[file.Name] = data
+	}
+
+	assert.Equal(t, []byte("%PDF"), files["agreements/agreement.pdf"])
+
+	merchant := make(map[string]string)
+	require.NoError(t, json.Unmarshal(files["merchant.json"], &merchant))
+	assert.Equal(t, "merchant_id", merchant["id"])
+
+	manifest := struct {
+		MerchantId string   `json:"merchant_id"`
+		ExportId   string   `json:"export_id"`
+		Files      []string `json:"files"`
+	}{}
+	require.NoError(t, json.Unmarshal(files[bundleManifestName], &manifest))
+	assert.Equal(t, "merchant_id", manifest.MerchantId)
+	assert.Equal(t, "export_id", manifest.ExportId)
+	assert.Equal(t, []string{"merchant.json", "agreements/agreement.pdf"}, manifest.Files)
+}
+
+func TestExport_IsActive(t *testing.T) {
+	assert.True(t, (&Export{Status: StatusPending}).IsActive())
+	assert.True(t, (&Export{Status: StatusProcessing}).IsActive())
+	assert.False(t, (&Export{Status: StatusReady}).IsActive())
+	assert.False(t, (&Export{Status: StatusFailed}).IsActive())
+}
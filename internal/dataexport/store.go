@@ -0,0 +1,257 @@
+package dataexport
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/ProtocolONE/go-core/v2/pkg/logger"
+	"github.com/ProtocolONE/go-core/v2/pkg/provider"
+	"github.com/google/uuid"
+	awsWrapper "github.com/paysuper/paysuper-aws-manager"
+	"github.com/paysuper/paysuper-management-api/internal/storage"
+	"time"
+)
+
+const (
+	manifestFileMask = "merchant_exports/%s/exports.json"
+	bundleFileMask   = "merchant_exports/%s/%s.zip"
+	bundlesPrefix    = "merchant_exports/"
+	bundleSuffix     = ".zip"
+
+	cleanupRunnerLockName = "merchant_exports/cleanup_runner"
+
+	// exportsMax is the count of the latest exports kept in the manifest
+	exportsMax = 20
+	// activeTimeout is the time after which the export still being collected is treated as interrupted
+	activeTimeout = time.Hour
+	// BundleTtl is the time the ready bundle is kept, the bundle has the personal and banking data of the merchant
+	BundleTtl = 7 * 24 * time.Hour
+
+	errorInterrupted = "export was interrupted"
+)
+
+// Store keeps the merchant exports and their bundles in the reporter bucket.
+// The manifest is the JSON list of the merchant exports from the latest one.
+// The bundle is deleted when its export is dropped from the manifest or when the bundle is expired.
+type Store struct {
+	files   *storage.Store
+	deleter storage.Deleter
+}
+
+// NewStore
+func NewStore(awsManager awsWrapper.AwsManagerInterface, deleter storage.Deleter) *Store {
+	return &Store{files: storage.New(awsManager), deleter: deleter}
+}
+
+// List returns the exports of the merchant from the latest one
+func (s *Store) List(ctx context.Context, merchantId string) ([]*Export, error) {
+	exports := make([]*Export, 0)
+
+	if _, err := s.files.Load(ctx, fmt.Sprintf(manifestFileMask, merchantId), &exports); err != nil {
+		return nil, err
+	}
+
+	markStale(exports, time.Now())
+
+	return exports, nil
+}
+
+// Get returns the merchant export by the identifier
+func (s *Store) Get(ctx context.Context, merchantId, id string) (*Export, error) {
+	exports, err := s.List(ctx, merchantId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, export := range exports {
+		if export.Id == id {
+			return export, nil
+		}
+	}
+
+	return nil, ErrorExportNotFound
+}
+
+// Create adds the pending export of the merchant, the merchant can have the single export in progress only.
+// The bundles of the exports dropped from the manifest are returned, the caller deletes them with DeleteBundles.
+func (s *Store) Create(ctx context.Context, merchantId, userId string) (*Export, []string, error) {
+	export := &Export{
+		Id:         uuid.New().String(),
+		MerchantId: merchantId,
+		Status:     StatusPending,
+		CreatedBy:  userId,
+		CreatedAt:  time.Now().UTC(),
+	}
+	dropped := make([]string, 0)
+	err := s.updateManifest(ctx, merchantId, func(exports []*Export) ([]*Export, error) {
+		for _, item := range exports {
+			if item.IsActive() {
+				return nil, ErrorExportInProgress
+			}
+		}
+
+		exports = append([]*Export{export}, exports...)
+
+		if len(exports) > exportsMax {
+			// deleting the bundle which was never uploaded is no-op, so the status isn't checked
+			for _, item := range exports[exportsMax:] {
+				dropped = append(dropped, bundleFileName(item))
+			}
+
+			exports = exports[:exportsMax]
+		}
+
+		return exports, nil
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return export, dropped, nil
+}
+
+// DeleteBundles deletes the bundles by their file names
+func (s *Store) DeleteBundles(ctx context.Context, fileNames []string) error {
+	for _, fileName := range fileNames {
+		if err := s.deleter.Delete(ctx, fileName); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Update saves the changed status of the export
+func (s *Store) Update(ctx context.Context, export *Export) error {
+	return s.updateManifest(ctx, export.MerchantId, func(exports []*Export) ([]*Export, error) {
+		for i, item := range exports {
+			if item.Id == export.Id {
+				exports[i] = export
+				return exports, nil
+			}
+		}
+
+		return nil, ErrorExportNotFound
+	})
+}
+
+// SaveBundle uploads the bundle content and marks the export as ready
+func (s *Store) SaveBundle(ctx context.Context, export *Export, content []byte, files []string) error {
+	if err := s.files.Upload(ctx, bundleFileName(export), bytes.NewReader(content)); err != nil {
+		return err
+	}
+
+	completedAt := time.Now().UTC()
+	expiresAt := completedAt.Add(BundleTtl)
+	export.Status = StatusReady
+	export.Size = int64(len(content))
+	export.Files = files
+	export.CompletedAt = &completedAt
+	export.ExpiresAt = &expiresAt
+
+	return s.Update(ctx, export)
+}
+
+// Download saves the bundle of the ready export to the new temporary file and returns the path to the file.
+// The caller removes the temporary file when it's served.
+func (s *Store) Download(ctx context.Context, export *Export) (string, error) {
+	if export.Status == StatusExpired {
+		return "", ErrorExportExpired
+	}
+
+	if export.Status != StatusReady {
+		return "", ErrorExportNotReady
+	}
+
+	return s.files.Download(ctx, bundleFileName(export))
+}
+
+// updateManifest changes the manifest of the merchant under the lock of the manifest file
+func (s *Store) updateManifest(
+	ctx context.Context,
+	merchantId string,
+	change func(exports []*Export) ([]*Export, error),
+) error {
+	exports := make([]*Export, 0)
+
+	return s.files.Update(ctx, fmt.Sprintf(manifestFileMask, merchantId), &exports, func(found bool) error {
+		markStale(exports, time.Now())
+		changed, err := change(exports)
+
+		if err != nil {
+			return err
+		}
+
+		exports = changed
+
+		return nil
+	})
+}
+
+// Cleanup deletes the bundles older than the ttl. The bundles are listed in the bucket,
+// so the bundles of the merchants which never request the exports again are deleted too.
+func (s *Store) Cleanup(ctx context.Context, now time.Time) (int, error) {
+	return s.deleter.DeleteOlder(ctx, bundlesPrefix, bundleSuffix, now.Add(-BundleTtl))
+}
+
+// RunCleanup deletes the expired bundles by the interval until the context is done.
+// Every replica runs the cleanup, the single one at a time does the work.
+func (s *Store) RunCleanup(ctx context.Context, interval time.Duration, lmt provider.LMT) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.cleanupOnce(ctx, now, lmt)
+		}
+	}
+}
+
+func (s *Store) cleanupOnce(ctx context.Context, now time.Time, lmt provider.LMT) {
+	unlock, ok, err := s.files.TryLock(ctx, cleanupRunnerLockName)
+
+	if err != nil {
+		lmt.L().Error("merchant data export cleanup lock failed", logger.PairArgs("err", err.Error()))
+		return
+	}
+
+	if !ok {
+		return
+	}
+
+	defer unlock()
+
+	deleted, err := s.Cleanup(ctx, now)
+
+	if err != nil {
+		lmt.L().Error("merchant data export cleanup failed", logger.PairArgs("err", err.Error()))
+	}
+
+	if deleted > 0 {
+		lmt.L().Info("expired merchant data export bundles deleted", logger.PairArgs("count", deleted))
+	}
+}
+
+func bundleFileName(export *Export) string {
+	return fmt.Sprintf(bundleFileMask, export.MerchantId, export.Id)
+}
+
+// markStale fails the exports which are collected for too long, their collector is gone.
+// The ready exports past the ttl are expired, their bundles are deleted by the cleanup.
+func markStale(exports []*Export, now time.Time) {
+	for _, export := range exports {
+		if export.IsActive() && now.Sub(export.CreatedAt) > activeTimeout {
+			export.Status = StatusFailed
+			export.Error = errorInterrupted
+		}
+
+		if export.Status == StatusReady && export.ExpiresAt != nil && now.After(*export.ExpiresAt) {
+			export.Status = StatusExpired
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package dataexport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/paysuper/paysuper-management-api/internal/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestStore_Create_DropsOldestBundles(t *testing.T) {
+	exports := make([]*Export, 0, exportsMax)
+
+	for i := 0; i < exportsMax; i++ {
+		exports = append(exports, &Export{
+			Id:         fmt.Sprintf("export_%d", i),
+			MerchantId: "merchant_id",
+			Status:     StatusReady,
+			CreatedAt:  time.Now().Add(-time.Hour),
+		})
+	}
+
+	manifest, err := json.Marshal(exports)
+	require.NoError(t, err)
+
+	files := map[string][]byte{fmt.Sprintf(manifestFileMask, "merchant_id"): manifest}
+	deleter := &mock.BucketMock{}
+	store := NewStore(mock.NewAwsManagerFilesMock(files), deleter)
+
+	export, dropped, err := store.Create(context.Background(), "merchant_id", "user_id")
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, export.Status)
+
+	list, err := store.List(context.Background(), "merchant_id")
+	require.NoError(t, err)
+	assert.Len(t, list, exportsMax)
+	assert.Equal(t, export.Id, list[0].Id)
+
+	assert.Equal(t, []string{fmt.Sprintf(bundleFileMask, "merchant_id", fmt.Sprintf("export_%d", exportsMax-1))}, dropped)
+	require.NoError(t, store.DeleteBundles(context.Background(), dropped))
+	assert.Equal(t, dropped, deleter.Deleted)
+
+	now := time.Now()
+	_, err = store.Cleanup(context.Background(), now)
+	require.NoError(t, err)
+	assert.Equal(t, []string{bundlesPrefix + "*" + bundleSuffix}, deleter.DeletedOlder)
+	assert.Equal(t, now.Add(-BundleTtl), deleter.Before)
+}
+
+func TestMarkStale(t *testing.T) {
+	now := time.Now()
+	expired := now.Add(-time.Minute)
+	valid := now.Add(time.Minute)
+	exports := []*Export{
+		{Status: StatusReady, ExpiresAt: &expired},
+		{Status: StatusReady, ExpiresAt: &valid},
+		{Status: StatusProcessing, CreatedAt: now.Add(-2 * activeTimeout)},
+		{Status: StatusPending, CreatedAt: now},
+	}
+
+	markStale(exports, now)
+
+	assert.Equal(t, StatusExpired, exports[0].Status)
+	assert.Equal(t, StatusReady, exports[1].Status)
+	assert.Equal(t, StatusFailed, exports[2].Status)
+	assert.Equal(t, errorInterrupted, exports[2].Error)
+	assert.Equal(t, StatusPending, exports[3].Status)
+
+	_, err := NewStore(nil, nil).Download(context.Background(), exports[0])
+	assert.Equal(t, ErrorExportExpired, err)
+}
@@ -0,0 +1,39 @@
+package dispatcher
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"time"
+)
+
+const (
+	responseCacheReferenceTtl = time.Hour
+	responseCacheRolesTtl     = 10 * time.Minute
+	responseCacheThemesTtl    = 5 * time.Minute
+)
+
+var (
+	// ResponseCacheRules is the whitelist of the cached reference data routes, the responses of the routes must not depend on the user.
+	// The query parameters of the rule must list all parameters read by the handler of the route.
+	ResponseCacheRules = map[string]*common.ResponseCacheRule{
+		common.NoAuthGroupPath + "/country":                {Tag: common.ResponseCacheTagCountries, Ttl: responseCacheReferenceTtl},
+		common.NoAuthGroupPath + "/country/:code":          {Tag: common.ResponseCacheTagCountries, Ttl: responseCacheReferenceTtl},
+		common.NoAuthGroupPath + "/price_group/country":    {Tag: common.ResponseCacheTagPriceGroups, Ttl: responseCacheReferenceTtl, Query: []string{"country"}},
+		common.NoAuthGroupPath + "/price_group/currencies": {Tag: common.ResponseCacheTagPriceGroups, Ttl: responseCacheReferenceTtl},
+		common.NoAuthGroupPath + "/price_group/region":     {Tag: common.ResponseCacheTagPriceGroups, Ttl: responseCacheReferenceTtl, Query: []string{"region"}},
+		common.AuthUserGroupPath + "/platforms":            {Tag: common.ResponseCacheTagPlatforms, Ttl: responseCacheReferenceTtl, Query: []string{"limit", "offset"}},
+		common.AuthUserGroupPath + "/merchants/roles":      {Tag: common.ResponseCacheTagRoles, Ttl: responseCacheRolesTtl},
+		common.SystemUserGroupPath + "/users/roles":        {Tag: common.ResponseCacheTagRoles, Ttl: responseCacheRolesTtl},
+
+		// the theme stylesheets are public, the theme change drops them by the tag
+		common.NoAuthGroupPath + "/projects/:project_id/theme.css": {Tag: common.ResponseCacheTagProjectThemes, Ttl: responseCacheThemesTtl},
+	}
+)
+
+// responseCacheGroup adds the response cache to the group, it's called after the authorization middlewares of the group
+func (d *Dispatcher) responseCacheGroup(grp *echo.Group) {
+	if !d.globalCfg.ResponseCacheEnabled || d.appSet.Services.Cache == nil {
+		return
+	}
+	grp.Use(d.appSet.Services.Cache.Middleware)
+}
@@ -0,0 +1,382 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/ProtocolONE/go-core/v2/pkg/logger"
+	"github.com/ProtocolONE/go-core/v2/pkg/provider"
+	"github.com/labstack/echo/v4"
+	awsWrapper "github.com/paysuper/paysuper-aws-manager"
+	"github.com/paysuper/paysuper-management-api/internal/storage"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ResponseCacheTagCountries     = "countries"
+	ResponseCacheTagPriceGroups   = "price_groups"
+	ResponseCacheTagPlatforms     = "platforms"
+	ResponseCacheTagRoles         = "roles"
+	ResponseCacheTagProjectThemes = "project_themes"
+
+	ResponseCacheHeader = "X-Cache"
+	ResponseCacheHit    = "HIT"
+	ResponseCacheMiss   = "MISS"
+
+	responseCacheMaxEntries = 10000
+	responseCacheEtagLength = 16
+
+	responseCacheInvalidationsFileName = "cache/invalidations.json"
+	responseCacheInvalidationsAll      = "*"
+)
+
+var (
+	ResponseCacheTags = []string{
+		ResponseCacheTagCountries,
+		ResponseCacheTagPriceGroups,
+		ResponseCacheTagPlatforms,
+		ResponseCacheTagRoles,
+		ResponseCacheTagProjectThemes,
+	}
+)
+
+// ResponseCacheRule describes the cached route: the tag used for the invalidation, the time to live of the response
+// and the query parameters the response depends on. The other query parameters don't make the separate entries.
+type ResponseCacheRule struct {
+	Tag   string
+	Ttl   time.Duration
+	Query []string
+}
+
+type cachedResponse struct {
+	tag          string
+	contentType  string
+	body         []byte
+	etag         string
+	lastModified time.Time
+	expiresAt    time.Time
+}
+
+// ResponseCache keeps the successful responses of the reference data routes in memory.
+// The responses are answered with ETag and Last-Modified and the conditional requests get 304.
+// Only the routes which responses don't depend on the user may be cached.
+//
+// Every replica of the API keeps its own cache. With the broadcast the invalidations are recorded
+// to the shared storage and the other replicas drop the responses on the next Sync.
+type ResponseCache struct {
+	rules   map[string]*ResponseCacheRule
+	entries map[string]*cachedResponse
+	mx      sync.RWMutex
+	files   *storage.Store
+	syncMx  sync.Mutex
+	synced  map[string]time.Time
+	hooksMx sync.RWMutex
+	hooks   map[string][]func()
+}
+
+// NewResponseCache
+func NewResponseCache(rules map[string]*ResponseCacheRule) *ResponseCache {
+	return &ResponseCache{
+		rules:   rules,
+		entries: make(map[string]*cachedResponse),
+		synced:  make(map[string]time.Time),
+		hooks:   make(map[string][]func()),
+	}
+}
+
+// OnInvalidate registers the hook called when the tag is invalidated by this or the other replica,
+// so the in-memory caches outside of the response cache are dropped together with the responses.
+func (c *ResponseCache) OnInvalidate(tag string, hook func()) {
+	if c == nil {
+		return
+	}
+
+	c.hooksMx.Lock()
+	defer c.hooksMx.Unlock()
+
+	c.hooks[tag] = append(c.hooks[tag], hook)
+}
+
+// Broadcast makes the cache share the invalidations with the other replicas through the bucket of the aws manager.
+// It's called once before the cache is used.
+func (c *ResponseCache) Broadcast(awsManager awsWrapper.AwsManagerInterface) {
+	c.files = storage.New(awsManager)
+}
+
+// Invalidate drops the cached responses of the tags and records the invalidation for the other replicas.
+// It's the hook for the routes changing the reference data. Without the tags the whole cache is dropped.
+func (c *ResponseCache) Invalidate(ctx context.Context, tags ...string) error {
+	if c == nil {
+		return nil
+	}
+
+	c.invalidate(tags...)
+
+	if c.files == nil {
+		return nil
+	}
+
+	if len(tags) <= 0 {
+		tags = []string{responseCacheInvalidationsAll}
+	}
+
+	c.syncMx.Lock()
+	defer c.syncMx.Unlock()
+
+	invalidations := make(map[string]time.Time)
+
+	return c.files.Update(ctx, responseCacheInvalidationsFileName, &invalidations, func(found bool) error {
+		now := time.Now().UTC()
+
+		for _, tag := range tags {
+			invalidations[tag] = now
+			// the own invalidation is already applied, so the sync skips it
+			c.synced[tag] = now
+		}
+
+		return nil
+	})
+}
+
+// Sync drops the cached responses of the tags invalidated by the other replicas since the last sync
+func (c *ResponseCache) Sync(ctx context.Context) error {
+	if c == nil || c.files == nil {
+		return nil
+	}
+
+	invalidations := make(map[string]time.Time)
+
+	if _, err := c.files.Load(ctx, responseCacheInvalidationsFileName, &invalidations); err != nil {
+		return err
+	}
+
+	c.syncMx.Lock()
+	defer c.syncMx.Unlock()
+
+	for tag, invalidatedAt := range invalidations {
+		if !invalidatedAt.After(c.synced[tag]) {
+			continue
+		}
+
+		if tag == responseCacheInvalidationsAll {
+			c.invalidate()
+		} else {
+			c.invalidate(tag)
+		}
+
+		c.synced[tag] = invalidatedAt
+	}
+
+	return nil
+}
+
+// RunSync syncs the invalidations with the interval until the context is done
+func (c *ResponseCache) RunSync(ctx context.Context, interval time.Duration, lmt provider.LMT) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Sync(ctx); err != nil {
+				lmt.L().Error("response cache invalidations sync failed", logger.PairArgs("err", err.Error()))
+			}
+		}
+	}
+}
+
+func (c *ResponseCache) invalidate(tags ...string) {
+	c.mx.Lock()
+
+	for key, entry := range c.entries {
+		if len(tags) <= 0 || containsString(tags, entry.tag) {
+			delete(c.entries, key)
+		}
+	}
+
+	c.mx.Unlock()
+
+	c.hooksMx.RLock()
+	defer c.hooksMx.RUnlock()
+
+	for tag, hooks := range c.hooks {
+		if len(tags) > 0 && !containsString(tags, tag) {
+			continue
+		}
+
+		for _, hook := range hooks {
+			hook()
+		}
+	}
+}
+
+// Middleware serves the GET requests of the whitelisted routes from the cache.
+// It must be used after the authorization middlewares of the group.
+func (c *ResponseCache) Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		rule, ok := c.rules[ctx.Path()]
+
+		if !ok || ctx.Request().Method != http.MethodGet {
+			return next(ctx)
+		}
+
+		key := responseCacheKey(ctx, rule)
+		now := time.Now()
+
+		c.mx.RLock()
+		entry, ok := c.entries[key]
+		c.mx.RUnlock()
+
+		if ok && entry.expiresAt.After(now) {
+			return c.write(ctx, entry, now, ResponseCacheHit)
+		}
+
+		response := ctx.Response()
+		recorder := &responseRecorder{header: make(http.Header), status: http.StatusOK}
+		ctx.SetResponse(echo.NewResponse(recorder, ctx.Echo()))
+		err := next(ctx)
+		ctx.SetResponse(response)
+
+		if err != nil {
+			return err
+		}
+
+		if recorder.status != http.StatusOK {
+			return recorder.flush(response)
+		}
+
+		entry = c.store(key, rule, recorder, entry, now)
+
+		return c.write(ctx, entry, now, ResponseCacheMiss)
+	}
+}
+
+func (c *ResponseCache) store(key string, rule *ResponseCacheRule, recorder *responseRecorder, old *cachedResponse, now time.Time) *cachedResponse {
+	sum := sha256.Sum256(recorder.body.Bytes())
+	entry := &cachedResponse{
+		tag:          rule.Tag,
+		contentType:  recorder.header.Get(echo.HeaderContentType),
+		body:         recorder.body.Bytes(),
+		etag:         fmt.Sprintf(`"%s"`, hex.EncodeToString(sum[:responseCacheEtagLength])),
+		lastModified: now.UTC().Truncate(time.Second),
+		expiresAt:    now.Add(rule.Ttl),
+	}
+
+	// the expired response with the same content keeps its modification time for the conditional requests
+	if old != nil && old.etag == entry.etag {
+		entry.lastModified = old.lastModified
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if len(c.entries) >= responseCacheMaxEntries {
+		for k, e := range c.entries {
+			if !e.expiresAt.After(now) {
+				delete(c.entries, k)
+			}
+		}
+	}
+
+	if len(c.entries) < responseCacheMaxEntries {
+		c.entries[key] = entry
+	}
+
+	return entry
+}
+
+func (c *ResponseCache) write(ctx echo.Context, entry *cachedResponse, now time.Time, state string) error {
+	header := ctx.Response().Header()
+	header.Set(echo.HeaderLastModified, entry.lastModified.Format(http.TimeFormat))
+	header.Set("ETag", entry.etag)
+	header.Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int64(entry.expiresAt.Sub(now)/time.Second)))
+	header.Set(ResponseCacheHeader, state)
+
+	if isResponseNotModified(ctx.Request(), entry) {
+		return ctx.NoContent(http.StatusNotModified)
+	}
+
+	return ctx.Blob(http.StatusOK, entry.contentType, entry.body)
+}
+
+// responseCacheKey returns the key of the response by the route, the path and the query parameters of the rule,
+// so the requests differing by the other parameters share the entry
+func responseCacheKey(ctx echo.Context, rule *ResponseCacheRule) string {
+	query := ctx.Request().URL.Query()
+	params := make(url.Values, len(rule.Query))
+
+	for _, name := range rule.Query {
+		if values, ok := query[name]; ok {
+			params[name] = values
+		}
+	}
+
+	return ctx.Path() + " " + ctx.Request().URL.Path + "?" + params.Encode()
+}
+
+// isResponseNotModified checks the conditional request headers, If-None-Match takes precedence over If-Modified-Since
+func isResponseNotModified(req *http.Request, entry *cachedResponse) bool {
+	if match := req.Header.Get("If-None-Match"); match != "" {
+		for _, etag := range strings.Split(match, ",") {
+			etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+
+			if etag == entry.etag || etag == "*" {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	since, err := http.ParseTime(req.Header.Get(echo.HeaderIfModifiedSince))
+
+	return err == nil && !entry.lastModified.After(since)
+}
+
+// responseRecorder keeps the response of the handler to put it to the cache
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	return r.body.Write(data)
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *responseRecorder) flush(response *echo.Response) error {
+	for key, values := range r.header {
+		response.Header()[key] = values
+	}
+
+	response.WriteHeader(r.status)
+	_, err := response.Write(r.body.Bytes())
+
+	return err
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -110,6 +110,7 @@ type Services struct {
 	Billing    grpc.BillingService
 	Tax        tax_service.TaxService
 	Reporter   reporterProto.ReporterService
+	Cache      *ResponseCache
 }
 
 // Handlers
@@ -13,6 +13,7 @@ type Config struct {
 	Auth1
 
 	HttpScheme              string `envconfig:"HTTP_SCHEME" default:"https"`
+	PublicUrl               string `envconfig:"PUBLIC_URL" required:"true"`
 	PaymentFormJsLibraryUrl string `envconfig:"PAYMENT_FORM_JS_LIBRARY_URL" required:"true"`
 	WebsocketUrl            string `envconfig:"WEBSOCKET_URL" default:"wss://cf.tst.protocol.one/connection/websocket"`
 
@@ -26,13 +27,51 @@ type Config struct {
 	AwsRegionReporter          string `envconfig:"AWS_REGION_REPORTER" default:"eu-west-1"`
 	AwsBucketReporter          string `envconfig:"AWS_BUCKET_REPORTER" required:"true"`
 
+	// the agreement signature routes are registered only when the e-signature provider is configured
+	ESignProvider          string `envconfig:"ESIGN_PROVIDER"`
+	ESignCallbackSecret    string `envconfig:"ESIGN_CALLBACK_SECRET"`
+	ESignPspSignatoryName  string `envconfig:"ESIGN_PSP_SIGNATORY_NAME" default:"PaySuper"`
+	ESignPspSignatoryEmail string `envconfig:"ESIGN_PSP_SIGNATORY_EMAIL"`
+
+	BankDirectoryFile string `envconfig:"BANK_DIRECTORY_FILE"`
+
+	InviteLifetime time.Duration `envconfig:"INVITE_LIFETIME" default:"168h"`
+
+	CallbackTesterTimeout     time.Duration `envconfig:"CALLBACK_TESTER_TIMEOUT" default:"10s"`
+	CallbackTesterLocalServer bool          `envconfig:"CALLBACK_TESTER_LOCAL_SERVER" default:"false"`
+
+	SandboxMode bool `envconfig:"SANDBOX_MODE" default:"false"`
+
+	TaxScheduleInterval time.Duration `envconfig:"TAX_SCHEDULE_INTERVAL" default:"1m"`
+
+	ResponseCacheEnabled bool `envconfig:"RESPONSE_CACHE_ENABLED" default:"true"`
+
+	SmtpHost     string `envconfig:"SMTP_HOST" default:"localhost"`
+	SmtpPort     int    `envconfig:"SMTP_PORT" default:"25"`
+	SmtpUser     string `envconfig:"SMTP_USER"`
+	SmtpPassword string `envconfig:"SMTP_PASSWORD"`
+	SmtpFrom     string `envconfig:"SMTP_FROM" default:"noreply@pay.super.com"`
+
+	PdfRendererBinary string `envconfig:"PDF_RENDERER_BINARY" default:"wkhtmltopdf"`
+	// the documents can't load anything from the network unless the allow-list proxy is set
+	PdfRendererProxy string `envconfig:"PDF_RENDERER_PROXY"`
+
+	ReceiptResendInterval   time.Duration `envconfig:"RECEIPT_RESEND_INTERVAL" default:"1m"`
+	ReceiptResendDailyLimit int           `envconfig:"RECEIPT_RESEND_DAILY_LIMIT" default:"5"`
+
+	SavedCardDeleteInterval   time.Duration `envconfig:"SAVED_CARD_DELETE_INTERVAL" default:"10s"`
+	SavedCardDeleteDailyLimit int           `envconfig:"SAVED_CARD_DELETE_DAILY_LIMIT" default:"10"`
+
 	LimitDefault                 int32 `default:"100"`
 	OffsetDefault                int32 `default:"0"`
 	LimitMax                     int32 `default:"1000"`
 	DisableAuthMiddleware        bool
 	CustomerTokenCookiesLifetime time.Duration // CustomerTokenCookiesLifetime = 2592000
 
-	OrderInlineFormUrlMask string `envconfig:"ORDER_INLINE_FORM_URL_MASK" required:"true"`
+	OrderInlineFormUrlMask      string        `envconfig:"ORDER_INLINE_FORM_URL_MASK" required:"true"`
+	PaymentFormUrlSecret        string        `envconfig:"PAYMENT_FORM_URL_SECRET" required:"true"`
+	PaymentFormUrlLifetime      time.Duration `envconfig:"PAYMENT_FORM_URL_LIFETIME" default:"24h"`
+	PaymentFormUrlUnsignedUntil string        `envconfig:"PAYMENT_FORM_URL_UNSIGNED_UNTIL"`
 
 	CookieDomain string `envconfig:"COOKIE_DOMAIN" required:"true"`
 	AllowOrigin  string `envconfig:"ALLOW_ORIGIN" default:"*"`
@@ -160,6 +160,91 @@ var (
 	ErrorMessageUnableToCheckInviteToken          = NewManagementApiResponseError("ma000107", "unable to check invite token")
 	ErrorMessageInvalidRoleType                   = NewManagementApiResponseError("ma000108", "invalid role type")
 	ErrorMessageUnableToDeleteUser                = NewManagementApiResponseError("ma000109", "unable to delete user")
+	ErrorOnboardingStepNotFilled                  = NewManagementApiResponseError("ma000110", "onboarding step data is not filled")
+	ErrorMessageSignatureRequestCreateFailed      = NewManagementApiResponseError("ma000111", "unable to create agreement signature request")
+	ErrorMessageSignatureCallbackInvalid          = NewManagementApiResponseError("ma000112", "agreement signature callback is invalid")
+	ErrorMessageBankingVerificationFailed         = NewManagementApiResponseError("ma000113", "merchant banking data verification failed")
+	ErrorMessageBankDirectoryReloadFailed         = NewManagementApiResponseError("ma000114", "unable to reload bank directory")
+	ErrorMessageDocumentTypeUnknown               = NewManagementApiResponseError("ma000115", "merchant document type is unknown")
+	ErrorMessageDocumentContentType               = NewManagementApiResponseError("ma000116", "merchant document content type is not allowed for the document type")
+	ErrorMessageDocumentUploadMaxSize             = NewManagementApiResponseError("ma000117", "merchant document max upload size exceeded")
+	ErrorMessageDocumentNotFound                  = NewManagementApiResponseError("ma000118", "merchant document not found")
+	ErrorMessageDocumentLocked                    = NewManagementApiResponseError("ma000119", "merchant document is in review or approved and can't be replaced")
+	ErrorMessageDocumentStatusTransition          = NewManagementApiResponseError("ma000120", "merchant document status can't be changed to the requested status")
+	ErrorMessageDocumentRejectReasonRequired      = NewManagementApiResponseError("ma000121", "reason is required to reject merchant document")
+	ErrorMessageDocumentStorageFailed             = NewManagementApiResponseError("ma000122", "unable to access merchant documents storage")
+	ErrorMessageInviteNotFound                    = NewManagementApiResponseError("ma000123", "pending invite not found")
+	ErrorMessageInvitesFileInvalid                = NewManagementApiResponseError("ma000124", "invites file must be a csv file with email and role columns")
+	ErrorMessageInvitesFileTooManyRows            = NewManagementApiResponseError("ma000125", "invites file contains too many rows")
+	ErrorMessageProductsImportFileInvalid         = NewManagementApiResponseError("ma000126", "products import file must be a csv or json file with products")
+	ErrorMessageProductsImportTooManyRows         = NewManagementApiResponseError("ma000127", "products import file contains too many rows")
+	ErrorMessageProductsImportSkuDuplicated       = NewManagementApiResponseError("ma000128", "product sku is duplicated in the import file")
+	ErrorMessageProjectCloneOverridesInvalid      = NewManagementApiResponseError("ma000129", "project clone overrides contain invalid fields")
+	ErrorMessageCallbackUrlNotConfigured          = NewManagementApiResponseError("ma000130", "project callback url for the notification type is not configured")
+	ErrorMessageCallbackUrlInvalid                = NewManagementApiResponseError("ma000131", "project callback url must be absolute http or https url")
+	ErrorMessageCallbackSecretKeyEmpty            = NewManagementApiResponseError("ma000132", "project secret key is empty, callbacks can't be signed")
+	ErrorMessageTaxesImportFileInvalid            = NewManagementApiResponseError("ma000133", "tax rates import file must be a csv file with country, state, city, zip, rate and effective_from columns")
+	ErrorMessageTaxesImportTooManyRows            = NewManagementApiResponseError("ma000134", "tax rates import file contains too many rows")
+	ErrorMessageTaxesImportRowDuplicated          = NewManagementApiResponseError("ma000135", "tax rate for the jurisdiction and effective date is duplicated in the import file")
+	ErrorMessageTaxRateCountryInvalid             = NewManagementApiResponseError("ma000136", "tax rate country must be two letter country code")
+	ErrorMessageTaxRateValueInvalid               = NewManagementApiResponseError("ma000137", "tax rate must be a number between 0 and 1")
+	ErrorMessageTaxRateEffectiveFromInvalid       = NewManagementApiResponseError("ma000138", "tax rate effective date must be in YYYY-MM-DD or RFC 3339 format")
+	ErrorMessageTaxRateChangeNotFound             = NewManagementApiResponseError("ma000139", "scheduled tax rate change not found")
+	ErrorMessageTaxRateScheduleStorageFailed      = NewManagementApiResponseError("ma000140", "unable to access tax rate schedule storage")
+	ErrorMessageZipFormatsReloadFailed            = NewManagementApiResponseError("ma000141", "unable to reload postal code formats")
+	ErrorMessageOrderTimelineStorageFailed        = NewManagementApiResponseError("ma000142", "unable to access order timeline storage")
+	ErrorMessagePaymentCostsImportFileInvalid     = NewManagementApiResponseError("ma000143", "payment costs import file must be a csv or xlsx file with type, method, region, percent and fix_amount columns")
+	ErrorMessagePaymentCostsImportTooManyRows     = NewManagementApiResponseError("ma000144", "payment costs import file contains too many rows")
+	ErrorMessagePaymentCostsImportRowDuplicated   = NewManagementApiResponseError("ma000145", "payment cost is duplicated in the import file")
+	ErrorMessagePaymentCostsImportValueInvalid    = NewManagementApiResponseError("ma000146", "payment cost has invalid value")
+	ErrorMessagePaymentCostsVersionNotFound       = NewManagementApiResponseError("ma000147", "payment costs version not found")
+	ErrorMessagePaymentCostsVersionsStorageFailed = NewManagementApiResponseError("ma000148", "unable to access payment costs versions storage")
+	ErrorMessageMerchantExportInProgress          = NewManagementApiResponseError("ma000149", "merchant data export is in progress already")
+	ErrorMessageMerchantExportNotFound            = NewManagementApiResponseError("ma000150", "merchant data export not found")
+	ErrorMessageMerchantExportNotReady            = NewManagementApiResponseError("ma000151", "merchant data export isn't ready yet")
+	ErrorMessageMerchantExportStorageFailed       = NewManagementApiResponseError("ma000152", "unable to access merchant data export storage")
+	ErrorMessageMerchantOffboardingNotFound       = NewManagementApiResponseError("ma000153", "merchant offboarding not found")
+	ErrorMessageMerchantOffboardingInProgress     = NewManagementApiResponseError("ma000154", "merchant offboarding is in progress already")
+	ErrorMessageMerchantOffboardingStorageFailed  = NewManagementApiResponseError("ma000155", "unable to access merchant offboarding storage")
+	ErrorMessageBrandingTemplateUnknown           = NewManagementApiResponseError("ma000156", "document template is unknown, allowed templates are agreement and receipt")
+	ErrorMessageBrandingTemplateInvalid           = NewManagementApiResponseError("ma000157", "document template can't be parsed")
+	ErrorMessageBrandingTemplateTooLong           = NewManagementApiResponseError("ma000158", "document template is too long")
+	ErrorMessageBrandingLegalFooterTooLong        = NewManagementApiResponseError("ma000159", "legal footer is too long")
+	ErrorMessageBrandingLogoContentType           = NewManagementApiResponseError("ma000160", "logo must be png, jpeg or gif image")
+	ErrorMessageBrandingLogoMaxSize               = NewManagementApiResponseError("ma000161", "logo file size must be up to 1 MB")
+	ErrorMessageBrandingLogoNotFound              = NewManagementApiResponseError("ma000162", "logo of operating company isn't uploaded")
+	ErrorMessageBrandingRenderFailed              = NewManagementApiResponseError("ma000163", "document template can't be rendered")
+	ErrorMessageBrandingStorageFailed             = NewManagementApiResponseError("ma000164", "unable to access operating company branding storage")
+	ErrorMessageReceiptEmailNotFound              = NewManagementApiResponseError("ma000165", "order has no customer email to send the receipt")
+	ErrorMessageReceiptResendThrottled            = NewManagementApiResponseError("ma000166", "receipt email was sent recently, try again later")
+	ErrorMessageReceiptRenderFailed               = NewManagementApiResponseError("ma000167", "unable to render receipt")
+	ErrorMessageReceiptEmailSendFailed            = NewManagementApiResponseError("ma000168", "unable to send receipt email")
+	ErrorMessagePaymentFormUrlParamsInvalid       = NewManagementApiResponseError("ma000169", "payment form url parameters are invalid")
+	ErrorMessagePaymentFormUrlSignatureInvalid    = NewManagementApiResponseError("ma000170", "payment form url signature is invalid")
+	ErrorMessagePaymentFormUrlExpired             = NewManagementApiResponseError("ma000171", "payment form url is expired")
+	ErrorMessageProjectThemeColorInvalid          = NewManagementApiResponseError("ma000172", "theme color must be in #RRGGBB format")
+	ErrorMessageProjectThemeLogoUrlInvalid        = NewManagementApiResponseError("ma000173", "theme logo url must be absolute https url")
+	ErrorMessageProjectThemeFontUnknown           = NewManagementApiResponseError("ma000174", "theme font isn't supported")
+	ErrorMessageProjectThemeLayoutInvalid         = NewManagementApiResponseError("ma000175", "theme layout options are invalid")
+	ErrorMessageProjectThemeCssRulesTooMany       = NewManagementApiResponseError("ma000176", "theme has too many custom css rules")
+	ErrorMessageProjectThemeCssSelectorDenied     = NewManagementApiResponseError("ma000177", "custom css selector isn't allowed")
+	ErrorMessageProjectThemeCssPropertyDenied     = NewManagementApiResponseError("ma000178", "custom css property isn't allowed")
+	ErrorMessageProjectThemeCssValueInvalid       = NewManagementApiResponseError("ma000179", "custom css value is invalid")
+	ErrorMessageProjectThemeStorageFailed         = NewManagementApiResponseError("ma000180", "unable to access project theme storage")
+	ErrorMessageSavedCardCustomerInvalid          = NewManagementApiResponseError("ma000181", "customer token is invalid or expired")
+	ErrorMessageSavedCardNotFound                 = NewManagementApiResponseError("ma000182", "saved card not found")
+	ErrorMessageSavedCardDeleteThrottled          = NewManagementApiResponseError("ma000183", "too many saved card deletions, try again later")
+	ErrorMessageSavedCardStorageFailed            = NewManagementApiResponseError("ma000184", "unable to access saved cards preferences storage")
+	ErrorMessageSignatureOrderInvalid             = NewManagementApiResponseError("ma000185", "agreement must be signed by merchant before paysuper")
+	ErrorMessageInviteExpired                     = NewManagementApiResponseError("ma000186", "invite is expired, ask to send it again")
+	ErrorMessageInviteStorageFailed               = NewManagementApiResponseError("ma000187", "unable to access invites storage")
+	ErrorMessageCallbackUrlForbidden              = NewManagementApiResponseError("ma000188", "project callback url must resolve to public ip addresses")
+	ErrorMessageResponseCacheInvalidateFailed     = NewManagementApiResponseError("ma000189", "unable to share response cache invalidation with other instances")
+	ErrorMessagePaymentCostsReplaceRowsInvalid    = NewManagementApiResponseError("ma000190", "payment costs are not replaced because some rows are invalid")
+	ErrorMessageBalanceHistoryCurrencyRequired    = NewManagementApiResponseError("ma000191", "balance history has several currencies, currency is required")
+	ErrorMessageMerchantExportExpired             = NewManagementApiResponseError("ma000192", "merchant data export is expired")
+	ErrorMessagePaymentFormUrlSignatureRequired   = NewManagementApiResponseError("ma000193", "payment form url must be signed")
+	ErrorMessagePaymentFormTokenStorageFailed     = NewManagementApiResponseError("ma000194", "unable to access payment form tokens storage")
 
 	ValidationErrors = map[string]*grpc.ResponseErrorMessage{
 		UserProfileFieldNumberOfEmployees: ErrorMessageIncorrectNumberOfEmployees,
@@ -75,6 +75,7 @@ func (d *Dispatcher) Dispatch(echoHttp *echo.Echo) error {
 	d.authUserGroup(grp.AuthUser)
 	d.systemUserGroup(grp.SystemUser)
 	d.webHookGroup(grp.WebHooks)
+	d.responseCacheGroup(grp.Common)
 	// init routes
 	for _, handler := range d.appSet.Handlers {
 		handler.Route(grp)
@@ -163,6 +164,7 @@ func (d *Dispatcher) authUserGroup(grp *echo.Group) {
 		})) // 3
 	}
 	grp.Use(d.MerchantBinderPreMiddleware) // 3
+	d.responseCacheGroup(grp)              // 4
 }
 
 func (d *Dispatcher) systemUserGroup(grp *echo.Group) {
@@ -175,6 +177,7 @@ func (d *Dispatcher) systemUserGroup(grp *echo.Group) {
 		})) // 2
 	}
 	grp.Use(d.SystemBinderPreMiddleware) // 3
+	d.responseCacheGroup(grp)            // 4
 }
 
 func (d *Dispatcher) webHookGroup(grp *echo.Group) {
@@ -60,6 +60,7 @@ func ProviderServices(srv *micro.Micro) common.Services {
 		Billing:    grpc.NewBillingService(pkg.ServiceName, srv.Client()),
 		Tax:        tax_service.NewTaxService(taxServiceConst.ServiceName, srv.Client()),
 		Reporter:   reporterProto.NewReporterService(reporterPkg.ServiceName, srv.Client()),
+		Cache:      common.NewResponseCache(ResponseCacheRules),
 	}
 }
 
@@ -0,0 +1,114 @@
+package esign
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	awsWrapper "github.com/paysuper/paysuper-aws-manager"
+	"net/http"
+	"sync"
+)
+
+const (
+	StatusPending  = "pending"
+	StatusSigned   = "signed"
+	StatusDeclined = "declined"
+)
+
+var (
+	ErrorProviderNotFound         = errors.New("e-signature provider not found")
+	ErrorSignatureRequestNotFound = errors.New("signature request not found")
+	ErrorCallbackSignatureInvalid = errors.New("callback signature is invalid")
+	ErrorCallbackInvalid          = errors.New("callback data is invalid")
+	ErrorSignersEmpty             = errors.New("signature request must contain at least one signer")
+	ErrorCallbackSecretEmpty      = errors.New("callback secret of e-signature provider is empty")
+	ErrorLocalProviderNotAllowed  = errors.New("local e-signature provider is allowed in sandbox mode only")
+	ErrorStorageEmpty             = errors.New("storage of e-signature provider is empty")
+
+	factories   = map[string]Factory{}
+	factoriesMx sync.RWMutex
+)
+
+// Signer describes the person who must sign the document
+type Signer struct {
+	SignerType int32  `json:"signer_type"`
+	Name       string `json:"name"`
+	Email      string `json:"email"`
+}
+
+// SignatureRequest describes the document which must be signed by the signers
+type SignatureRequest struct {
+	MerchantId string    `json:"merchant_id"`
+	Title      string    `json:"title"`
+	FilePath   string    `json:"file_path"`
+	Signers    []*Signer `json:"signers"`
+}
+
+// SignerResult contains the link which the signer must follow to sign the document
+type SignerResult struct {
+	Signer
+	SignUrl string `json:"sign_url"`
+}
+
+// SignatureRequestResult
+type SignatureRequestResult struct {
+	Id       string          `json:"id"`
+	Provider string          `json:"provider"`
+	Signers  []*SignerResult `json:"signers"`
+}
+
+// Callback contains the information about the signer's action received from the provider
+type Callback struct {
+	RequestId  string `json:"signature_request_id"`
+	MerchantId string `json:"merchant_id"`
+	SignerType int32  `json:"signer_type"`
+	Status     string `json:"status"`
+}
+
+// Options
+type Options struct {
+	CallbackSecret string
+	SignUrlMask    string
+	// Sandbox allows the providers which don't really sign the documents, e.g. the local one
+	Sandbox bool
+	// Storage keeps the signature requests shared by all replicas for the providers which don't keep them on their side
+	Storage awsWrapper.AwsManagerInterface
+}
+
+// Provider is the e-signature service used to sign the merchant agreement
+type Provider interface {
+	// Name returns the provider name used in the configuration
+	Name() string
+	// CreateSignatureRequest sends the document to the provider and returns the links to sign it
+	CreateSignatureRequest(ctx context.Context, req *SignatureRequest) (*SignatureRequestResult, error)
+	// ParseCallback checks the authenticity of the signer callback and returns its data
+	ParseCallback(ctx context.Context, header http.Header, body []byte) (*Callback, error)
+}
+
+// Factory
+type Factory func(opts Options) (Provider, error)
+
+// Register adds the provider factory to the list of available providers
+func Register(name string, factory Factory) {
+	factoriesMx.Lock()
+	defer factoriesMx.Unlock()
+	factories[name] = factory
+}
+
+// New creates the provider by the name it was registered with.
+// The callbacks of the provider can't be authenticated without the secret, so the empty secret is refused.
+func New(name string, opts Options) (Provider, error) {
+	if opts.CallbackSecret == "" {
+		return nil, ErrorCallbackSecretEmpty
+	}
+
+	factoriesMx.RLock()
+	factory, ok := factories[name]
+	factoriesMx.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%s: %s", ErrorProviderNotFound.Error(), name)
+	}
+
+	return factory(opts)
+}
@@ -0,0 +1,56 @@
+package esign
+
+import (
+	"context"
+	"github.com/paysuper/paysuper-management-api/internal/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	storage := mock.NewAwsManagerFilesMock(map[string][]byte{})
+
+	_, err := New(ProviderLocal, Options{Sandbox: true, Storage: storage})
+	assert.Equal(t, ErrorCallbackSecretEmpty, err)
+
+	_, err = New(ProviderLocal, Options{CallbackSecret: "secret", Storage: storage})
+	assert.Equal(t, ErrorLocalProviderNotAllowed, err)
+
+	_, err = New(ProviderLocal, Options{CallbackSecret: "secret", Sandbox: true})
+	assert.Equal(t, ErrorStorageEmpty, err)
+
+	_, err = New("unknown", Options{CallbackSecret: "secret", Sandbox: true, Storage: storage})
+	assert.Error(t, err)
+
+	p, err := New(ProviderLocal, Options{CallbackSecret: "secret", Sandbox: true, Storage: storage})
+	assert.NoError(t, err)
+	assert.Equal(t, ProviderLocal, p.Name())
+}
+
+func TestLocalProvider_ParseCallback_OtherReplica(t *testing.T) {
+	files := map[string][]byte{}
+	opts := Options{CallbackSecret: "secret", Storage: mock.NewAwsManagerFilesMock(files)}
+
+	res, err := NewLocalProvider(opts).CreateSignatureRequest(context.Background(), &SignatureRequest{
+		MerchantId: "merchant",
+		Signers:    []*Signer{{SignerType: 0, Email: "merchant@unit.test"}},
+	})
+	require.NoError(t, err)
+
+	// the callback is received by the replica which didn't create the request
+	other := NewLocalProvider(opts)
+	header, body, err := other.NewCallback(res.Id, 0, StatusSigned)
+	require.NoError(t, err)
+
+	cb, err := other.ParseCallback(context.Background(), header, body)
+	require.NoError(t, err)
+	assert.Equal(t, "merchant", cb.MerchantId)
+	assert.Equal(t, StatusSigned, cb.Status)
+
+	header, body, err = other.NewCallback("unknown", 0, StatusSigned)
+	require.NoError(t, err)
+
+	_, err = other.ParseCallback(context.Background(), header, body)
+	assert.Equal(t, ErrorSignatureRequestNotFound, err)
+}
@@ -0,0 +1,138 @@
+package esign
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/paysuper/paysuper-management-api/internal/storage"
+	"net/http"
+)
+
+const (
+	ProviderLocal = "local"
+
+	LocalHeaderSignature = "X-ESign-Signature"
+
+	localSignUrlMaskDefault = "local://esign/%s/%d"
+	localRequestFileMask    = "esign/local/requests/%s.json"
+)
+
+func init() {
+	Register(ProviderLocal, func(opts Options) (Provider, error) {
+		if !opts.Sandbox {
+			return nil, ErrorLocalProviderNotAllowed
+		}
+
+		if opts.Storage == nil {
+			return nil, ErrorStorageEmpty
+		}
+
+		return NewLocalProvider(opts), nil
+	})
+}
+
+// LocalProvider is the fake e-signature provider which keeps the signature requests in the storage,
+// so the callback is accepted by any replica.
+// It is used in tests and sandbox environments only, because anyone knowing the callback secret can sign for any side.
+type LocalProvider struct {
+	opts     Options
+	requests *storage.Store
+}
+
+// NewLocalProvider
+func NewLocalProvider(opts Options) *LocalProvider {
+	if opts.SignUrlMask == "" {
+		opts.SignUrlMask = localSignUrlMaskDefault
+	}
+
+	return &LocalProvider{
+		opts:     opts,
+		requests: storage.New(opts.Storage),
+	}
+}
+
+// Name
+func (p *LocalProvider) Name() string {
+	return ProviderLocal
+}
+
+// CreateSignatureRequest
+func (p *LocalProvider) CreateSignatureRequest(ctx context.Context, req *SignatureRequest) (*SignatureRequestResult, error) {
+	if len(req.Signers) <= 0 {
+		return nil, ErrorSignersEmpty
+	}
+
+	res := &SignatureRequestResult{
+		Id:       uuid.New().String(),
+		Provider: p.Name(),
+	}
+
+	for _, signer := range req.Signers {
+		res.Signers = append(res.Signers, &SignerResult{
+			Signer:  *signer,
+			SignUrl: fmt.Sprintf(p.opts.SignUrlMask, res.Id, signer.SignerType),
+		})
+	}
+
+	if err := p.requests.Save(ctx, localRequestFileName(res.Id), req); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// ParseCallback
+func (p *LocalProvider) ParseCallback(ctx context.Context, header http.Header, body []byte) (*Callback, error) {
+	if !hmac.Equal([]byte(header.Get(LocalHeaderSignature)), []byte(p.sign(body))) {
+		return nil, ErrorCallbackSignatureInvalid
+	}
+
+	cb := &Callback{}
+
+	if err := json.Unmarshal(body, cb); err != nil {
+		return nil, ErrorCallbackInvalid
+	}
+
+	req := &SignatureRequest{}
+	found, err := p.requests.Load(ctx, localRequestFileName(cb.RequestId), req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, ErrorSignatureRequestNotFound
+	}
+
+	cb.MerchantId = req.MerchantId
+
+	return cb, nil
+}
+
+// NewCallback emulates the provider's callback about the signer's action
+func (p *LocalProvider) NewCallback(requestId string, signerType int32, status string) (http.Header, []byte, error) {
+	body, err := json.Marshal(&Callback{RequestId: requestId, SignerType: signerType, Status: status})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header := http.Header{}
+	header.Set(LocalHeaderSignature, p.sign(body))
+
+	return header, body, nil
+}
+
+func (p *LocalProvider) sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(p.opts.CallbackSecret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func localRequestFileName(requestId string) string {
+	return fmt.Sprintf(localRequestFileMask, requestId)
+}
@@ -7,12 +7,14 @@ import (
 	"github.com/paysuper/paysuper-billing-server/pkg"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
 	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/invite"
 	"net/http"
 )
 
 type AdminUsersRoute struct {
 	dispatch common.HandlerSet
 	cfg      common.Config
+	invites  *invite.Store
 	provider.LMT
 }
 
@@ -22,14 +24,18 @@ const (
 	adminUserInvite   = "/users/invite"
 	adminResendInvite = "/users/resend"
 	adminUserRole     = "/users/roles/:role_id"
+	adminInvites      = "/users/invites"
+	adminInvitesBulk  = "/users/invites/bulk"
+	adminInvitesRole  = "/users/invites/:role_id"
 )
 
-func NewAdminUsersRoute(set common.HandlerSet, cfg *common.Config) *AdminUsersRoute {
+func NewAdminUsersRoute(set common.HandlerSet, invites *invite.Store, cfg *common.Config) *AdminUsersRoute {
 	set.AwareSet.Logger = set.AwareSet.Logger.WithFields(logger.Fields{"router": "AdminUsersRoute"})
 	return &AdminUsersRoute{
 		dispatch: set,
 		LMT:      &set.AwareSet,
 		cfg:      *cfg,
+		invites:  invites,
 	}
 }
 
@@ -41,6 +47,9 @@ func (h *AdminUsersRoute) Route(groups *common.Groups) {
 	groups.SystemUser.GET(adminListRoles, h.listRoles)
 	groups.SystemUser.DELETE(adminUserRole, h.deleteUser)
 	groups.SystemUser.GET(adminUserRole, h.getUser)
+	groups.SystemUser.GET(adminInvites, h.listInvites)
+	groups.SystemUser.POST(adminInvitesBulk, h.sendBulkInvites)
+	groups.SystemUser.DELETE(adminInvitesRole, h.revokeInvite)
 }
 
 func (h *AdminUsersRoute) changeRole(ctx echo.Context) error {
@@ -94,6 +103,8 @@ func (h *AdminUsersRoute) sendInvite(ctx echo.Context) error {
 		return echo.NewHTTPError(int(res.Status), res.Message)
 	}
 
+	recordInviteSent(ctx.Request().Context(), h, h.invites, res.Role.GetId())
+
 	return ctx.JSON(http.StatusOK, res)
 }
 
@@ -114,6 +125,8 @@ func (h *AdminUsersRoute) resendInvite(ctx echo.Context) error {
 		return echo.NewHTTPError(int(res.Status), res.Message)
 	}
 
+	recordInviteSent(ctx.Request().Context(), h, h.invites, req.RoleId)
+
 	return ctx.JSON(http.StatusOK, res)
 }
 
@@ -167,3 +180,86 @@ func (h *AdminUsersRoute) getUser(ctx echo.Context) error {
 
 	return ctx.JSON(http.StatusOK, res)
 }
+
+func (h *AdminUsersRoute) listInvites(ctx echo.Context) error {
+	res, err := h.dispatch.Services.Billing.GetAdminUsers(ctx.Request().Context(), &grpc.EmptyRequest{})
+
+	if err != nil {
+		return h.dispatch.SrvCallHandler(&grpc.EmptyRequest{}, err, pkg.ServiceName, "GetAdminUsers")
+	}
+
+	if res.Status != http.StatusOK {
+		return echo.NewHTTPError(int(res.Status), res.Message)
+	}
+
+	invites, err := getPendingInvites(ctx.Request().Context(), h.invites, res.Users, h.cfg.InviteLifetime)
+
+	if err != nil {
+		h.L().Error("invites storage call failed", logger.PairArgs("err", err.Error()))
+		return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessageInviteStorageFailed)
+	}
+
+	return ctx.JSON(http.StatusOK, invites)
+}
+
+func (h *AdminUsersRoute) revokeInvite(ctx echo.Context) error {
+	req := &grpc.AdminRoleRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	usersRes, err := h.dispatch.Services.Billing.GetAdminUsers(ctx.Request().Context(), &grpc.EmptyRequest{})
+
+	if err != nil {
+		return h.dispatch.SrvCallHandler(&grpc.EmptyRequest{}, err, pkg.ServiceName, "GetAdminUsers")
+	}
+
+	if usersRes.Status != http.StatusOK {
+		return echo.NewHTTPError(int(usersRes.Status), usersRes.Message)
+	}
+
+	if findPendingInvite(usersRes.Users, req.RoleId) == nil {
+		return echo.NewHTTPError(http.StatusNotFound, common.ErrorMessageInviteNotFound)
+	}
+
+	res, err := h.dispatch.Services.Billing.DeleteAdminUser(ctx.Request().Context(), req)
+
+	if err != nil {
+		return h.dispatch.SrvCallHandler(req, err, pkg.ServiceName, "DeleteAdminUser")
+	}
+
+	if res.Status != pkg.ResponseStatusOk {
+		return echo.NewHTTPError(int(res.Status), res.Message)
+	}
+
+	revokeInviteSending(ctx.Request().Context(), h, h.invites, req.RoleId)
+
+	return ctx.NoContent(http.StatusOK)
+}
+
+func (h *AdminUsersRoute) sendBulkInvites(ctx echo.Context) error {
+	rows, hErr := readInvitesFile(ctx)
+
+	if hErr != nil {
+		return hErr
+	}
+
+	result := sendBulkInvites(h.dispatch, rows, func(email, role string) (int32, *grpc.ResponseErrorMessage, error) {
+		inviteReq := &grpc.InviteUserAdminRequest{Email: email, Role: role}
+		res, err := h.dispatch.Services.Billing.InviteUserAdmin(ctx.Request().Context(), inviteReq)
+
+		if err != nil {
+			common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "InviteUserAdmin", inviteReq)
+			return 0, nil, err
+		}
+
+		if res.Status == pkg.ResponseStatusOk {
+			recordInviteSent(ctx.Request().Context(), h, h.invites, res.Role.GetId())
+		}
+
+		return res.Status, res.Message, nil
+	})
+
+	return ctx.JSON(http.StatusOK, result)
+}
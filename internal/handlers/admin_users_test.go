@@ -1,13 +1,17 @@
 package handlers
 
 import (
+	"encoding/json"
 	"errors"
 	"github.com/globalsign/mgo/bson"
 	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg"
 	"github.com/paysuper/paysuper-billing-server/pkg/mocks"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
 	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/invite"
+	"github.com/paysuper/paysuper-management-api/internal/mock"
 	"github.com/paysuper/paysuper-management-api/internal/test"
 	mock2 "github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -18,8 +22,10 @@ import (
 
 type AdminUsersTestSuite struct {
 	suite.Suite
-	router *AdminUsersRoute
-	caller *test.EchoReqResCaller
+	router  *AdminUsersRoute
+	caller  *test.EchoReqResCaller
+	invites *invite.Store
+	bucket  *mock.BucketMock
 }
 
 func Test_AdminUsers(t *testing.T) {
@@ -38,7 +44,9 @@ func (suite *AdminUsersTestSuite) SetupTest() {
 	}
 	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
 		mw.Pre(test.PreAuthUserMiddleware(user))
-		suite.router = NewAdminUsersRoute(set.HandlerSet, set.GlobalConfig)
+		suite.bucket = &mock.BucketMock{}
+		suite.invites = invite.NewStore(mock.NewAwsManagerFilesMock(map[string][]byte{}), suite.bucket)
+		suite.router = NewAdminUsersRoute(set.HandlerSet, suite.invites, set.GlobalConfig)
 		return common.Handlers{
 			suite.router,
 		}
@@ -220,3 +228,100 @@ func (suite *AdminUsersTestSuite) TestAdminChangeRole_Ok() {
 	shouldBe.Equal(http.StatusOK, res.Code)
 	shouldBe.Empty(res.Body.String())
 }
+
+func (suite *AdminUsersTestSuite) TestAdminUsers_ListInvites_Ok() {
+	shouldBe := require.New(suite.T())
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("GetAdminUsers", mock2.Anything, mock2.Anything).Return(&grpc.GetAdminUsersResponse{
+		Status: http.StatusOK,
+		Users:  newInvitedUserRoles(suite.T(), suite.invites),
+	}, nil)
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Path(common.SystemUserGroupPath + adminInvites).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+
+	var invites []*PendingInvite
+	shouldBe.NoError(json.Unmarshal(res.Body.Bytes(), &invites))
+	shouldBe.Len(invites, 2)
+	shouldBe.Equal("expired@unit.test", invites[0].Email)
+	shouldBe.True(invites[0].Expired)
+}
+
+func (suite *AdminUsersTestSuite) TestAdminUsers_RevokeInvite_Ok() {
+	shouldBe := require.New(suite.T())
+	roles := newInvitedUserRoles(suite.T(), suite.invites)
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("GetAdminUsers", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetAdminUsersResponse{Status: http.StatusOK, Users: roles}, nil)
+	billingService.On("DeleteAdminUser", mock2.Anything, mock2.Anything).
+		Return(&grpc.EmptyResponseWithStatus{Status: pkg.ResponseStatusOk}, nil)
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodDelete).
+		Params(":"+common.RequestRoleId, roles[2].Id).
+		Path(common.SystemUserGroupPath + adminInvitesRole).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+	billingService.AssertCalled(suite.T(), "DeleteAdminUser", mock2.Anything, mock2.Anything)
+	shouldBe.Equal([]string{"invites/" + roles[2].Id + ".json"}, suite.bucket.Deleted)
+}
+
+func (suite *AdminUsersTestSuite) TestAdminUsers_RevokeInvite_NotFound_Error() {
+	shouldBe := require.New(suite.T())
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("GetAdminUsers", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetAdminUsersResponse{Status: http.StatusOK, Users: newInvitedUserRoles(suite.T(), suite.invites)}, nil)
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodDelete).
+		Params(":"+common.RequestRoleId, bson.NewObjectId().Hex()).
+		Path(common.SystemUserGroupPath + adminInvitesRole).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.Error(err)
+	hErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusNotFound, hErr.Code)
+}
+
+func (suite *AdminUsersTestSuite) TestAdminUsers_SendBulkInvites_Ok() {
+	shouldBe := require.New(suite.T())
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("InviteUserAdmin", mock2.Anything, mock2.Anything).
+		Return(&grpc.InviteUserAdminResponse{Status: pkg.ResponseStatusOk}, nil)
+
+	body, contentType := newInvitesFileBody(suite.T(), "first@unit.test,system_admin\nsecond@unit.test,\n")
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.SystemUserGroupPath + adminInvitesBulk).
+		Init(func(request *http.Request, middleware test.Middleware) {
+			request.Header.Set(echo.HeaderContentType, contentType)
+		}).
+		Body(body).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+
+	result := &InviteBulkResult{}
+	shouldBe.NoError(json.Unmarshal(res.Body.Bytes(), result))
+	shouldBe.Equal(2, result.Total)
+	shouldBe.Equal(1, result.Invited)
+	shouldBe.Equal(common.ErrorMessageInvalidRoleType.Code, result.Rows[1].Message.Code)
+	billingService.AssertNumberOfCalls(suite.T(), "InviteUserAdmin", 1)
+}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"github.com/ProtocolONE/go-core/v2/pkg/logger"
+	"github.com/ProtocolONE/go-core/v2/pkg/provider"
+	"github.com/labstack/echo/v4"
+	awsWrapper "github.com/paysuper/paysuper-aws-manager"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/branding"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/esign"
+	"io/ioutil"
+	"net/http"
+	"os"
+)
+
+const (
+	merchantsAgreementSignaturePath   = "/merchants/agreement/signature"
+	merchantsIdAgreementSignaturePath = "/merchants/:merchant_id/agreement/signature"
+	agreementSignatureWebHookPath     = "/agreement/signature"
+)
+
+const (
+	agreementSignatureTitle           = "PaySuper license agreement"
+	agreementSignatureTempFilePattern = "agreement_*.pdf"
+)
+
+type AgreementSignatureRoute struct {
+	dispatch   common.HandlerSet
+	awsManager awsWrapper.AwsManagerInterface
+	signer     esign.Provider
+	documents  *documentRenderer
+	cfg        common.Config
+	provider.LMT
+}
+
+func NewAgreementSignatureRoute(
+	set common.HandlerSet,
+	awsManager awsWrapper.AwsManagerInterface,
+	brandingStore *branding.Store,
+	signer esign.Provider,
+	cfg *common.Config,
+) *AgreementSignatureRoute {
+	set.AwareSet.Logger = set.AwareSet.Logger.WithFields(logger.Fields{"router": "AgreementSignatureRoute"})
+	return &AgreementSignatureRoute{
+		dispatch:   set,
+		LMT:        &set.AwareSet,
+		cfg:        *cfg,
+		awsManager: awsManager,
+		signer:     signer,
+		documents:  newDocumentRenderer(set, brandingStore, *cfg),
+	}
+}
+
+func (h *AgreementSignatureRoute) Route(groups *common.Groups) {
+	groups.AuthUser.POST(merchantsAgreementSignaturePath, h.createMerchantSignatureRequest)
+	groups.SystemUser.POST(merchantsIdAgreementSignaturePath, h.createSystemSignatureRequest)
+	groups.WebHooks.POST(agreementSignatureWebHookPath, h.signatureCallback)
+}
+
+func (h *AgreementSignatureRoute) createMerchantSignatureRequest(ctx echo.Context) error {
+	return h.createSignatureRequest(ctx, pkg.SignerTypeMerchant)
+}
+
+func (h *AgreementSignatureRoute) createSystemSignatureRequest(ctx echo.Context) error {
+	return h.createSignatureRequest(ctx, pkg.SignerTypePs)
+}
+
+// createSignatureRequest sends the merchant agreement to the e-signature provider.
+// The merchant user gets the sign link for the merchant signer only.
+func (h *AgreementSignatureRoute) createSignatureRequest(ctx echo.Context, signerType int32) error {
+	req := &grpc.GetMerchantByRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	res, err := h.dispatch.Services.Billing.GetMerchantBy(ctx.Request().Context(), req)
+
+	if err != nil {
+		return h.dispatch.SrvCallHandler(req, err, pkg.ServiceName, "GetMerchantBy")
+	}
+
+	if res.Status != pkg.ResponseStatusOk {
+		return echo.NewHTTPError(int(res.Status), res.Message)
+	}
+
+	if res.Item.S3AgreementName == "" {
+		return echo.NewHTTPError(http.StatusNotFound, common.ErrorMessageAgreementNotGenerated)
+	}
+
+	if res.Item.Contacts == nil || res.Item.Contacts.Authorized == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageRequiredContactAuthorized)
+	}
+
+	filePath, err := h.agreementFile(ctx, res.Item)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := os.Remove(filePath); err != nil {
+			h.L().Error("agreement temporary file remove failed", logger.PairArgs("err", err.Error(), "file_path", filePath))
+		}
+	}()
+
+	signatureReq := &esign.SignatureRequest{
+		MerchantId: res.Item.Id,
+		Title:      agreementSignatureTitle,
+		FilePath:   filePath,
+		Signers:    h.getSigners(res.Item),
+	}
+	signatureRes, err := h.signer.CreateSignatureRequest(ctx.Request().Context(), signatureReq)
+
+	if err != nil {
+		h.L().Error(
+			"e-signature provider call failed",
+			logger.PairArgs("err", err.Error(), "provider", h.signer.Name(), "merchant_id", res.Item.Id),
+		)
+		return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessageSignatureRequestCreateFailed)
+	}
+
+	if signerType == pkg.SignerTypeMerchant {
+		signers := signatureRes.Signers[:0]
+
+		for _, signer := range signatureRes.Signers {
+			if signer.SignerType == pkg.SignerTypeMerchant {
+				signers = append(signers, signer)
+			}
+		}
+
+		signatureRes.Signers = signers
+	}
+
+	return ctx.JSON(http.StatusOK, signatureRes)
+}
+
+// agreementFile saves the agreement sent for the signature to the unique temporary file. The agreement branded
+// by the operating company is preferred, so the merchant signs the same document it downloads.
+func (h *AgreementSignatureRoute) agreementFile(ctx echo.Context, merchant *billing.Merchant) (string, error) {
+	data, ok := h.documents.agreementPdf(ctx, merchant)
+
+	if !ok {
+		return h.downloadAgreement(ctx, merchant.S3AgreementName)
+	}
+
+	file, err := ioutil.TempFile("", agreementSignatureTempFilePattern)
+
+	if err != nil {
+		h.L().Error("agreement temporary file create failed", logger.PairArgs("err", err.Error()))
+		return "", echo.NewHTTPError(http.StatusInternalServerError, common.ErrorAgreementFileNotExist)
+	}
+
+	_, err = file.Write(data)
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = os.Remove(file.Name())
+		h.L().Error("agreement temporary file write failed", logger.PairArgs("err", err.Error()))
+		return "", echo.NewHTTPError(http.StatusInternalServerError, common.ErrorAgreementFileNotExist)
+	}
+
+	return file.Name(), nil
+}
+
+// downloadAgreement saves the agreement to the unique temporary file, so the concurrent requests don't share it
+func (h *AgreementSignatureRoute) downloadAgreement(ctx echo.Context, fileName string) (string, error) {
+	file, err := ioutil.TempFile("", agreementSignatureTempFilePattern)
+
+	if err != nil {
+		h.L().Error("agreement temporary file create failed", logger.PairArgs("err", err.Error()))
+		return "", echo.NewHTTPError(http.StatusInternalServerError, common.ErrorAgreementFileNotExist)
+	}
+
+	filePath := file.Name()
+	_ = file.Close()
+
+	_, err = h.awsManager.Download(ctx.Request().Context(), filePath, &awsWrapper.DownloadInput{FileName: fileName})
+
+	if err != nil {
+		_ = os.Remove(filePath)
+		h.L().Error("AWS api call to download file failed", logger.PairArgs("err", err.Error(), "file_name", fileName))
+		return "", echo.NewHTTPError(http.StatusInternalServerError, common.ErrorAgreementFileNotExist)
+	}
+
+	return filePath, nil
+}
+
+func (h *AgreementSignatureRoute) getSigners(merchant *billing.Merchant) []*esign.Signer {
+	return []*esign.Signer{
+		{
+			SignerType: pkg.SignerTypeMerchant,
+			Name:       merchant.Contacts.Authorized.Name,
+			Email:      merchant.Contacts.Authorized.Email,
+		},
+		{
+			SignerType: pkg.SignerTypePs,
+			Name:       h.cfg.ESignPspSignatoryName,
+			Email:      h.cfg.ESignPspSignatoryEmail,
+		},
+	}
+}
+
+// signatureCallback receives the signer's action from the e-signature provider
+// and marks the agreement as signed by the signer's side.
+// The agreement is signed by the merchant first, so the PSP signature before the merchant's one is refused.
+func (h *AgreementSignatureRoute) signatureCallback(ctx echo.Context) error {
+	cb, err := h.signer.ParseCallback(ctx.Request().Context(), ctx.Request().Header, common.ExtractRawBodyContext(ctx))
+
+	if err != nil {
+		h.L().Error("e-signature callback parse failed", logger.PairArgs("err", err.Error(), "provider", h.signer.Name()))
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageSignatureCallbackInvalid)
+	}
+
+	if cb.Status != esign.StatusSigned {
+		return ctx.NoContent(http.StatusOK)
+	}
+
+	merchantReq := &grpc.GetMerchantByRequest{MerchantId: cb.MerchantId}
+	merchantRes, err := h.dispatch.Services.Billing.GetMerchantBy(ctx.Request().Context(), merchantReq)
+
+	if err != nil {
+		return h.dispatch.SrvCallHandler(merchantReq, err, pkg.ServiceName, "GetMerchantBy")
+	}
+
+	if merchantRes.Status != pkg.ResponseStatusOk {
+		return echo.NewHTTPError(int(merchantRes.Status), merchantRes.Message)
+	}
+
+	merchant := merchantRes.Item
+
+	if cb.SignerType == pkg.SignerTypePs && !merchant.HasMerchantSignature {
+		h.L().Error(
+			"e-signature callback of psp signer before merchant signer",
+			logger.PairArgs("provider", h.signer.Name(), "merchant_id", cb.MerchantId, "request_id", cb.RequestId),
+		)
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageSignatureOrderInvalid)
+	}
+
+	// the request replaces all agreement fields of the merchant, so the current values are kept
+	req := &grpc.ChangeMerchantDataRequest{
+		MerchantId:           cb.MerchantId,
+		HasMerchantSignature: merchant.HasMerchantSignature || cb.SignerType == pkg.SignerTypeMerchant,
+		HasPspSignature:      merchant.HasPspSignature || cb.SignerType == pkg.SignerTypePs,
+		AgreementSentViaMail: merchant.AgreementSentViaMail,
+		MailTrackingLink:     merchant.MailTrackingLink,
+	}
+	res, err := h.dispatch.Services.Billing.ChangeMerchantData(ctx.Request().Context(), req)
+
+	if err != nil {
+		return h.dispatch.SrvCallHandler(req, err, pkg.ServiceName, "ChangeMerchantData")
+	}
+
+	if res.Status != pkg.ResponseStatusOk {
+		return echo.NewHTTPError(int(res.Status), res.Message)
+	}
+
+	return ctx.NoContent(http.StatusOK)
+}
@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/labstack/echo/v4"
+	awsWrapperMocks "github.com/paysuper/paysuper-aws-manager/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/branding"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/esign"
+	"github.com/paysuper/paysuper-management-api/internal/mock"
+	"github.com/paysuper/paysuper-management-api/internal/test"
+	mock2 "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"testing"
+)
+
+type AgreementSignatureTestSuite struct {
+	suite.Suite
+	router *AgreementSignatureRoute
+	caller *test.EchoReqResCaller
+	signer *esign.LocalProvider
+}
+
+func Test_AgreementSignature(t *testing.T) {
+	suite.Run(t, new(AgreementSignatureTestSuite))
+}
+
+func (suite *AgreementSignatureTestSuite) SetupTest() {
+	user := &common.AuthUser{
+		Id:         "ffffffffffffffffffffffff",
+		Email:      "test@unit.test",
+		MerchantId: "ffffffffffffffffffffffff",
+	}
+	var e error
+	settings := test.DefaultSettings()
+	srv := common.Services{
+		Billing: &mocks.BillingService{},
+	}
+	suite.signer = esign.NewLocalProvider(esign.Options{
+		CallbackSecret: "secret",
+		Storage:        mock.NewAwsManagerFilesMock(map[string][]byte{}),
+	})
+	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
+		mw.Pre(test.PreAuthUserMiddleware(user))
+		awsManagerMock := &awsWrapperMocks.AwsManagerInterface{}
+		awsManagerMock.On("Upload", mock2.Anything, mock2.Anything, mock2.Anything).Return(&s3manager.UploadOutput{}, nil)
+		awsManagerMock.On("Download", mock2.Anything, mock2.Anything, mock2.Anything, mock2.Anything).Return(int64(0), nil)
+		suite.router = NewAgreementSignatureRoute(set.HandlerSet, awsManagerMock, branding.NewStore(awsManagerMock), suite.signer, set.GlobalConfig)
+		return common.Handlers{
+			suite.router,
+		}
+	})
+	if e != nil {
+		panic(e)
+	}
+}
+
+func (suite *AgreementSignatureTestSuite) TearDownTest() {}
+
+func (suite *AgreementSignatureTestSuite) getMerchant() *billing.Merchant {
+	return &billing.Merchant{
+		Id:              "ffffffffffffffffffffffff",
+		Contacts:        mock.OnboardingMerchantMock.Contacts,
+		S3AgreementName: mock.SomeAgreementName,
+	}
+}
+
+func (suite *AgreementSignatureTestSuite) createSignatureRequest(path string) *esign.SignatureRequestResult {
+	shouldBe := require.New(suite.T())
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Params(":"+common.RequestParameterMerchantId, "ffffffffffffffffffffffff").
+		Path(path).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+
+	result := &esign.SignatureRequestResult{}
+	shouldBe.NoError(json.Unmarshal(res.Body.Bytes(), result))
+
+	return result
+}
+
+func (suite *AgreementSignatureTestSuite) TestAgreementSignature_CreateMerchant_Ok() {
+	shouldBe := require.New(suite.T())
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("GetMerchantBy", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetMerchantResponse{Status: pkg.ResponseStatusOk, Item: suite.getMerchant()}, nil)
+
+	result := suite.createSignatureRequest(common.AuthUserGroupPath + merchantsAgreementSignaturePath)
+	shouldBe.NotEmpty(result.Id)
+	shouldBe.Equal(esign.ProviderLocal, result.Provider)
+	shouldBe.Len(result.Signers, 1)
+	shouldBe.Equal(pkg.SignerTypeMerchant, result.Signers[0].SignerType)
+	shouldBe.NotEmpty(result.Signers[0].SignUrl)
+}
+
+func (suite *AgreementSignatureTestSuite) TestAgreementSignature_CreateSystem_Ok() {
+	shouldBe := require.New(suite.T())
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("GetMerchantBy", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetMerchantResponse{Status: pkg.ResponseStatusOk, Item: suite.getMerchant()}, nil)
+
+	result := suite.createSignatureRequest(common.SystemUserGroupPath + merchantsIdAgreementSignaturePath)
+	shouldBe.Len(result.Signers, 2)
+}
+
+func (suite *AgreementSignatureTestSuite) TestAgreementSignature_Create_AgreementNotGenerated_Error() {
+	shouldBe := require.New(suite.T())
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("GetMerchantBy", mock2.Anything, mock2.Anything).Return(&grpc.GetMerchantResponse{
+		Status: pkg.ResponseStatusOk,
+		Item:   &billing.Merchant{Id: "ffffffffffffffffffffffff"},
+	}, nil)
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.AuthUserGroupPath + merchantsAgreementSignaturePath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.Error(err)
+	hErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusNotFound, hErr.Code)
+	shouldBe.Equal(common.ErrorMessageAgreementNotGenerated, hErr.Message)
+}
+
+func (suite *AgreementSignatureTestSuite) TestAgreementSignature_Create_BillingServerError() {
+	shouldBe := require.New(suite.T())
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("GetMerchantBy", mock2.Anything, mock2.Anything).Return(nil, errors.New("some error"))
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.AuthUserGroupPath + merchantsAgreementSignaturePath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.Error(err)
+	hErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusInternalServerError, hErr.Code)
+}
+
+func (suite *AgreementSignatureTestSuite) TestAgreementSignature_Callback_Ok() {
+	shouldBe := require.New(suite.T())
+
+	merchant := suite.getMerchant()
+	merchant.HasPspSignature = true
+	merchant.AgreementSentViaMail = true
+	merchant.MailTrackingLink = "https://tracking.unit.test/1"
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("GetMerchantBy", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetMerchantResponse{Status: pkg.ResponseStatusOk, Item: merchant}, nil)
+	billingService.On("ChangeMerchantData", mock2.Anything, mock2.MatchedBy(func(req *grpc.ChangeMerchantDataRequest) bool {
+		return req.MerchantId == merchant.Id && req.HasMerchantSignature && req.HasPspSignature &&
+			req.AgreementSentViaMail && req.MailTrackingLink == merchant.MailTrackingLink
+	})).Return(&grpc.ChangeMerchantDataResponse{Status: pkg.ResponseStatusOk, Item: merchant}, nil)
+
+	result := suite.createSignatureRequest(common.AuthUserGroupPath + merchantsAgreementSignaturePath)
+	header, body, err := suite.signer.NewCallback(result.Id, pkg.SignerTypeMerchant, esign.StatusSigned)
+	shouldBe.NoError(err)
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.WebHookGroupPath + agreementSignatureWebHookPath).
+		Init(test.ReqInitJSON()).
+		Init(func(request *http.Request, middleware test.Middleware) {
+			request.Header.Set(esign.LocalHeaderSignature, header.Get(esign.LocalHeaderSignature))
+		}).
+		BodyBytes(body).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+	billingService.AssertCalled(suite.T(), "ChangeMerchantData", mock2.Anything, mock2.Anything)
+}
+
+func (suite *AgreementSignatureTestSuite) TestAgreementSignature_Callback_Declined_Ok() {
+	shouldBe := require.New(suite.T())
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("GetMerchantBy", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetMerchantResponse{Status: pkg.ResponseStatusOk, Item: suite.getMerchant()}, nil)
+
+	result := suite.createSignatureRequest(common.AuthUserGroupPath + merchantsAgreementSignaturePath)
+	header, body, err := suite.signer.NewCallback(result.Id, pkg.SignerTypeMerchant, esign.StatusDeclined)
+	shouldBe.NoError(err)
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.WebHookGroupPath + agreementSignatureWebHookPath).
+		Init(test.ReqInitJSON()).
+		Init(func(request *http.Request, middleware test.Middleware) {
+			request.Header.Set(esign.LocalHeaderSignature, header.Get(esign.LocalHeaderSignature))
+		}).
+		BodyBytes(body).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+	billingService.AssertNotCalled(suite.T(), "ChangeMerchantData", mock2.Anything, mock2.Anything)
+}
+
+func (suite *AgreementSignatureTestSuite) TestAgreementSignature_Callback_InvalidSignature_Error() {
+	shouldBe := require.New(suite.T())
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.WebHookGroupPath + agreementSignatureWebHookPath).
+		Init(test.ReqInitJSON()).
+		Init(func(request *http.Request, middleware test.Middleware) {
+			request.Header.Set(esign.LocalHeaderSignature, "invalid")
+		}).
+		BodyString(`{"signature_request_id": "unknown", "signer_type": 0, "status": "signed"}`).
+		Exec(suite.T())
+
+	shouldBe.Error(err)
+	hErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusBadRequest, hErr.Code)
+	shouldBe.Equal(common.ErrorMessageSignatureCallbackInvalid, hErr.Message)
+}
+
+func (suite *AgreementSignatureTestSuite) TestAgreementSignature_Callback_UnknownRequest_Error() {
+	shouldBe := require.New(suite.T())
+
+	header, body, err := suite.signer.NewCallback("unknown", pkg.SignerTypePs, esign.StatusSigned)
+	shouldBe.NoError(err)
+
+	_, err = suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.WebHookGroupPath + agreementSignatureWebHookPath).
+		Init(test.ReqInitJSON()).
+		Init(func(request *http.Request, middleware test.Middleware) {
+			request.Header.Set(esign.LocalHeaderSignature, header.Get(esign.LocalHeaderSignature))
+		}).
+		BodyBytes(body).
+		Exec(suite.T())
+
+	shouldBe.Error(err)
+	hErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusBadRequest, hErr.Code)
+}
+
+func (suite *AgreementSignatureTestSuite) TestAgreementSignature_Callback_PspBeforeMerchant_Error() {
+	shouldBe := require.New(suite.T())
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("GetMerchantBy", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetMerchantResponse{Status: pkg.ResponseStatusOk, Item: suite.getMerchant()}, nil)
+
+	result := suite.createSignatureRequest(common.SystemUserGroupPath + merchantsIdAgreementSignaturePath)
+	header, body, err := suite.signer.NewCallback(result.Id, pkg.SignerTypePs, esign.StatusSigned)
+	shouldBe.NoError(err)
+
+	_, err = suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.WebHookGroupPath + agreementSignatureWebHookPath).
+		Init(test.ReqInitJSON()).
+		Init(func(request *http.Request, middleware test.Middleware) {
+			request.Header.Set(esign.LocalHeaderSignature, header.Get(esign.LocalHeaderSignature))
+		}).
+		BodyBytes(body).
+		Exec(suite.T())
+
+	shouldBe.Error(err)
+	hErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusBadRequest, hErr.Code)
+	shouldBe.Equal(common.ErrorMessageSignatureOrderInvalid, hErr.Message)
+	billingService.AssertNotCalled(suite.T(), "ChangeMerchantData", mock2.Anything, mock2.Anything)
+}
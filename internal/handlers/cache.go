@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"github.com/ProtocolONE/go-core/v2/pkg/logger"
+	"github.com/ProtocolONE/go-core/v2/pkg/provider"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"net/http"
+	"time"
+)
+
+const (
+	cacheInvalidatePath = "/cache/invalidate"
+
+	responseCacheSyncInterval = 10 * time.Second
+)
+
+type CacheRoute struct {
+	dispatch common.HandlerSet
+	cfg      common.Config
+	provider.LMT
+}
+
+type cacheInvalidateRequest struct {
+	Tags []string `json:"tags" validate:"omitempty,dive,oneof=countries price_groups platforms roles"`
+}
+
+func NewCacheRoute(set common.HandlerSet, cfg *common.Config) *CacheRoute {
+	set.AwareSet.Logger = set.AwareSet.Logger.WithFields(logger.Fields{"router": "CacheRoute"})
+	return &CacheRoute{
+		dispatch: set,
+		LMT:      &set.AwareSet,
+		cfg:      *cfg,
+	}
+}
+
+func (h *CacheRoute) Route(groups *common.Groups) {
+	groups.SystemUser.POST(cacheInvalidatePath, h.invalidate)
+}
+
+// invalidate drops the cached reference data responses of the tags, without the tags the whole cache is dropped.
+// It's used when the reference data is changed on the billing server side.
+// The other replicas of the API drop the responses on the next invalidations sync.
+func (h *CacheRoute) invalidate(ctx echo.Context) error {
+	req := &cacheInvalidateRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	if err := h.dispatch.Services.Cache.Invalidate(ctx.Request().Context(), req.Tags...); err != nil {
+		h.L().Error("response cache invalidation broadcast failed", logger.PairArgs("err", err.Error(), "tags", req.Tags))
+		return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessageResponseCacheInvalidateFailed)
+	}
+
+	h.L().Info("response cache invalidated", logger.PairArgs("tags", req.Tags))
+
+	return ctx.NoContent(http.StatusOK)
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/mock"
+	"github.com/paysuper/paysuper-management-api/internal/test"
+	mock2 "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type CacheTestSuite struct {
+	suite.Suite
+	caller  *test.EchoReqResCaller
+	billing *mocks.BillingService
+	cache   *common.ResponseCache
+	rules   map[string]*common.ResponseCacheRule
+	files   map[string][]byte
+}
+
+func Test_Cache(t *testing.T) {
+	suite.Run(t, new(CacheTestSuite))
+}
+
+func (suite *CacheTestSuite) SetupTest() {
+	suite.billing = &mocks.BillingService{}
+	suite.billing.On("GetCountriesList", mock2.Anything, mock2.Anything).
+		Return(&billing.CountriesList{Countries: []*billing.Country{{IsoCodeA2: "RU"}, {IsoCodeA2: "US"}}}, nil)
+	suite.billing.On("GetCountry", mock2.Anything, mock2.MatchedBy(func(req *billing.GetCountryRequest) bool {
+		return req.IsoCode == "RU"
+	})).Return(&billing.Country{IsoCodeA2: "RU"}, nil)
+	suite.billing.On("GetCountry", mock2.Anything, mock2.Anything).Return(nil, errors.New("not found"))
+
+	suite.rules = map[string]*common.ResponseCacheRule{
+		common.NoAuthGroupPath + "/country":       {Tag: common.ResponseCacheTagCountries, Ttl: time.Hour},
+		common.NoAuthGroupPath + "/country/:code": {Tag: common.ResponseCacheTagCountries, Ttl: 0},
+	}
+	suite.files = make(map[string][]byte)
+	suite.cache = common.NewResponseCache(suite.rules)
+	suite.cache.Broadcast(mock.NewAwsManagerFilesMock(suite.files))
+
+	var e error
+	settings := test.DefaultSettings()
+	srv := common.Services{
+		Billing: suite.billing,
+		Cache:   suite.cache,
+	}
+	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
+		return common.Handlers{
+			NewCountryApiV1(set.HandlerSet, set.GlobalConfig),
+			NewCacheRoute(set.HandlerSet, set.GlobalConfig),
+		}
+	})
+	if e != nil {
+		panic(e)
+	}
+}
+
+func (suite *CacheTestSuite) TearDownTest() {}
+
+func (suite *CacheTestSuite) get(path string, headers map[string]string) *httptest.ResponseRecorder {
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Path(common.NoAuthGroupPath + path).
+		Init(func(req *http.Request, mw test.Middleware) {
+			for key, value := range headers {
+				req.Header.Set(key, value)
+			}
+		}).
+		Exec(suite.T())
+
+	if err != nil {
+		return nil
+	}
+
+	return res
+}
+
+func (suite *CacheTestSuite) TestCache_Hit_Ok() {
+	shouldBe := require.New(suite.T())
+
+	res := suite.get("/country", nil)
+	shouldBe.NotNil(res)
+	shouldBe.Equal(http.StatusOK, res.Code)
+	shouldBe.Equal(common.ResponseCacheMiss, res.Header().Get(common.ResponseCacheHeader))
+	shouldBe.NotEmpty(res.Header().Get("ETag"))
+	shouldBe.NotEmpty(res.Header().Get(echo.HeaderLastModified))
+	shouldBe.Contains(res.Header().Get(echo.HeaderContentType), echo.MIMEApplicationJSON)
+
+	cached := suite.get("/country", nil)
+	shouldBe.NotNil(cached)
+	shouldBe.Equal(http.StatusOK, cached.Code)
+	shouldBe.Equal(common.ResponseCacheHit, cached.Header().Get(common.ResponseCacheHeader))
+	shouldBe.Equal(res.Header().Get("ETag"), cached.Header().Get("ETag"))
+	shouldBe.Equal(res.Body.String(), cached.Body.String())
+
+	suite.billing.AssertNumberOfCalls(suite.T(), "GetCountriesList", 1)
+}
+
+func (suite *CacheTestSuite) TestCache_IfNoneMatch_NotModified() {
+	shouldBe := require.New(suite.T())
+
+	res := suite.get("/country", nil)
+	shouldBe.NotNil(res)
+
+	res = suite.get("/country", map[string]string{"If-None-Match": res.Header().Get("ETag")})
+	shouldBe.NotNil(res)
+	shouldBe.Equal(http.StatusNotModified, res.Code)
+	shouldBe.Empty(res.Body.String())
+
+	res = suite.get("/country", map[string]string{"If-None-Match": `"unknown"`})
+	shouldBe.NotNil(res)
+	shouldBe.Equal(http.StatusOK, res.Code)
+}
+
+func (suite *CacheTestSuite) TestCache_IfModifiedSince_NotModified() {
+	shouldBe := require.New(suite.T())
+
+	res := suite.get("/country", nil)
+	shouldBe.NotNil(res)
+
+	res = suite.get("/country", map[string]string{echo.HeaderIfModifiedSince: res.Header().Get(echo.HeaderLastModified)})
+	shouldBe.NotNil(res)
+	shouldBe.Equal(http.StatusNotModified, res.Code)
+
+	since := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	res = suite.get("/country", map[string]string{echo.HeaderIfModifiedSince: since})
+	shouldBe.NotNil(res)
+	shouldBe.Equal(http.StatusOK, res.Code)
+}
+
+func (suite *CacheTestSuite) TestCache_Expired_KeepsLastModified() {
+	shouldBe := require.New(suite.T())
+
+	res := suite.get("/country/RU", nil)
+	shouldBe.NotNil(res)
+	shouldBe.Equal(common.ResponseCacheMiss, res.Header().Get(common.ResponseCacheHeader))
+
+	refreshed := suite.get("/country/RU", map[string]string{"If-None-Match": res.Header().Get("ETag")})
+	shouldBe.NotNil(refreshed)
+	shouldBe.Equal(http.StatusNotModified, refreshed.Code)
+	shouldBe.Equal(common.ResponseCacheMiss, refreshed.Header().Get(common.ResponseCacheHeader))
+	shouldBe.Equal(res.Header().Get(echo.HeaderLastModified), refreshed.Header().Get(echo.HeaderLastModified))
+
+	suite.billing.AssertNumberOfCalls(suite.T(), "GetCountry", 2)
+}
+
+func (suite *CacheTestSuite) TestCache_Error_NotCached() {
+	shouldBe := require.New(suite.T())
+
+	for i := 0; i < 2; i++ {
+		_, err := suite.caller.Builder().
+			Method(http.MethodGet).
+			Path(common.NoAuthGroupPath + "/country/XX").
+			Exec(suite.T())
+
+		shouldBe.Error(err)
+		httpErr, ok := err.(*echo.HTTPError)
+		shouldBe.True(ok)
+		shouldBe.Equal(http.StatusNotFound, httpErr.Code)
+	}
+
+	suite.billing.AssertNumberOfCalls(suite.T(), "GetCountry", 2)
+}
+
+func (suite *CacheTestSuite) TestCache_Invalidate_Ok() {
+	shouldBe := require.New(suite.T())
+
+	shouldBe.NotNil(suite.get("/country", nil))
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.SystemUserGroupPath + cacheInvalidatePath).
+		Init(test.ReqInitJSON()).
+		BodyString(`{"tags": ["countries"]}`).
+		Exec(suite.T())
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+
+	res = suite.get("/country", nil)
+	shouldBe.NotNil(res)
+	shouldBe.Equal(common.ResponseCacheMiss, res.Header().Get(common.ResponseCacheHeader))
+
+	suite.billing.AssertNumberOfCalls(suite.T(), "GetCountriesList", 2)
+}
+
+func (suite *CacheTestSuite) TestCache_IgnoredQuery_Hit() {
+	shouldBe := require.New(suite.T())
+
+	res := suite.get("/country?utm_source=a", nil)
+	shouldBe.NotNil(res)
+	shouldBe.Equal(common.ResponseCacheMiss, res.Header().Get(common.ResponseCacheHeader))
+
+	// the route doesn't read the query, so the other values don't make the separate entry
+	res = suite.get("/country?utm_source=b&ts=1", nil)
+	shouldBe.NotNil(res)
+	shouldBe.Equal(common.ResponseCacheHit, res.Header().Get(common.ResponseCacheHeader))
+
+	suite.billing.AssertNumberOfCalls(suite.T(), "GetCountriesList", 1)
+}
+
+func (suite *CacheTestSuite) TestCache_InvalidateBroadcast_Ok() {
+	shouldBe := require.New(suite.T())
+
+	shouldBe.NotNil(suite.get("/country", nil))
+
+	// the other replica invalidates the countries, the cache drops them on the sync only
+	other := common.NewResponseCache(suite.rules)
+	other.Broadcast(mock.NewAwsManagerFilesMock(suite.files))
+	shouldBe.NoError(other.Invalidate(context.Background(), common.ResponseCacheTagCountries))
+
+	res := suite.get("/country", nil)
+	shouldBe.NotNil(res)
+	shouldBe.Equal(common.ResponseCacheHit, res.Header().Get(common.ResponseCacheHeader))
+
+	shouldBe.NoError(suite.cache.Sync(context.Background()))
+
+	res = suite.get("/country", nil)
+	shouldBe.NotNil(res)
+	shouldBe.Equal(common.ResponseCacheMiss, res.Header().Get(common.ResponseCacheHeader))
+
+	// the invalidation is applied once
+	shouldBe.NoError(suite.cache.Sync(context.Background()))
+
+	res = suite.get("/country", nil)
+	shouldBe.NotNil(res)
+	shouldBe.Equal(common.ResponseCacheHit, res.Header().Get(common.ResponseCacheHeader))
+
+	suite.billing.AssertNumberOfCalls(suite.T(), "GetCountriesList", 2)
+}
+
+func (suite *CacheTestSuite) TestCache_Invalidate_UnknownTag() {
+	_, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.SystemUserGroupPath + cacheInvalidatePath).
+		Init(test.ReqInitJSON()).
+		BodyString(`{"tags": ["unknown"]}`).
+		Exec(suite.T())
+
+	shouldBe := require.New(suite.T())
+	shouldBe.Error(err)
+	httpErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusBadRequest, httpErr.Code)
+}
@@ -8,7 +8,9 @@ import (
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
 	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/timeline"
 	"net/http"
+	"strconv"
 )
 
 const (
@@ -21,14 +23,16 @@ const (
 
 type CardPayWebHook struct {
 	dispatch common.HandlerSet
+	journal  *timeline.Journal
 	cfg      common.Config
 	provider.LMT
 }
 
-func NewCardPayWebHook(set common.HandlerSet, cfg *common.Config) *CardPayWebHook {
+func NewCardPayWebHook(set common.HandlerSet, journal *timeline.Journal, cfg *common.Config) *CardPayWebHook {
 	set.AwareSet.Logger = set.AwareSet.Logger.WithFields(logger.Fields{"router": "CardPayWebHook"})
 	return &CardPayWebHook{
 		dispatch: set,
+		journal:  journal,
 		LMT:      &set.AwareSet,
 		cfg:      *cfg,
 	}
@@ -85,6 +89,18 @@ func (h *CardPayWebHook) paymentCallback(ctx echo.Context) error {
 		message["message"] = "Payment successfully complete"
 	}
 
+	event := &timeline.Event{
+		Type:    timeline.EventPaymentCallback,
+		Actor:   timeline.ActorPaymentSystem,
+		Details: map[string]string{"status": strconv.Itoa(int(res.Status)), "error": res.Error},
+	}
+
+	if httpStatus == http.StatusOK {
+		recordTimelineEvent(h.LMT, h.journal, req.OrderId, event)
+	} else {
+		recordRejectedTimelineEvent(h.LMT, h.journal, h.dispatch.Services.Billing, req.OrderId, event)
+	}
+
 	return ctx.JSON(httpStatus, message)
 }
 
@@ -116,10 +132,19 @@ func (h *CardPayWebHook) refundCallback(ctx echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorUnknown)
 	}
 
+	event := &timeline.Event{
+		Type:    timeline.EventRefundCallback,
+		Actor:   timeline.ActorPaymentSystem,
+		Details: map[string]string{"status": strconv.Itoa(int(res.Status)), "error": res.Error},
+	}
+
 	if res.Status != pkg.ResponseStatusOk {
+		recordRejectedTimelineEvent(h.LMT, h.journal, h.dispatch.Services.Billing, st.GetMerchantOrder().GetId(), event)
 		return echo.NewHTTPError(int(res.Status), res.Error)
 	}
 
+	recordTimelineEvent(h.LMT, h.journal, st.GetMerchantOrder().GetId(), event)
+
 	if res.Error != "" {
 		return ctx.JSON(http.StatusOK, map[string]string{"message": res.Error})
 	}
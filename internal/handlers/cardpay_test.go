@@ -12,6 +12,7 @@ import (
 	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
 	"github.com/paysuper/paysuper-management-api/internal/mock"
 	"github.com/paysuper/paysuper-management-api/internal/test"
+	"github.com/paysuper/paysuper-management-api/internal/timeline"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"net/http"
@@ -37,7 +38,7 @@ func (suite *CardPayTestSuite) SetupTest() {
 		Billing: mock.NewBillingServerOkMock(),
 	}
 	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
-		suite.router = NewCardPayWebHook(set.HandlerSet, set.GlobalConfig)
+		suite.router = NewCardPayWebHook(set.HandlerSet, timeline.NewJournal(mock.NewAwsManagerFilesMock(make(map[string][]byte))), set.GlobalConfig)
 		return common.Handlers{
 			suite.router,
 		}
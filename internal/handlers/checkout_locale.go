@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	geoip "github.com/ProtocolONE/geoip-service/pkg"
+	"github.com/ProtocolONE/geoip-service/pkg/proto"
+	"github.com/ProtocolONE/go-core/v2/pkg/logger"
+	"github.com/ProtocolONE/go-core/v2/pkg/provider"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	checkoutLocalePath = "/checkout/locale"
+
+	checkoutLanguageDefault = "en"
+)
+
+type CheckoutLocaleRoute struct {
+	dispatch common.HandlerSet
+	cfg      common.Config
+	provider.LMT
+}
+
+// CheckoutLocale is the localization of the payment form for the client resolved by the client IP address
+type CheckoutLocale struct {
+	Ip              string `json:"ip"`
+	Country         string `json:"country"`
+	Region          string `json:"region"`
+	Language        string `json:"language"`
+	Currency        string `json:"currency"`
+	PaymentsAllowed bool   `json:"payments_allowed"`
+}
+
+type acceptLanguage struct {
+	language string
+	weight   float64
+}
+
+func NewCheckoutLocaleRoute(set common.HandlerSet, cfg *common.Config) *CheckoutLocaleRoute {
+	set.AwareSet.Logger = set.AwareSet.Logger.WithFields(logger.Fields{"router": "CheckoutLocaleRoute"})
+	return &CheckoutLocaleRoute{
+		dispatch: set,
+		LMT:      &set.AwareSet,
+		cfg:      *cfg,
+	}
+}
+
+func (h *CheckoutLocaleRoute) Route(groups *common.Groups) {
+	groups.Common.GET(checkoutLocalePath, h.getLocale)
+}
+
+// getLocale returns in the single response the country, the price group region and currency,
+// the language and the payments availability for the client of the payment form.
+// Only the language is returned when the country of the client IP address is unknown.
+func (h *CheckoutLocaleRoute) getLocale(ctx echo.Context) error {
+	res := &CheckoutLocale{
+		Ip:       ctx.RealIP(),
+		Language: getAcceptLanguage(ctx.Request().Header.Get(common.HeaderAcceptLanguage)),
+	}
+
+	geoReq := &proto.GeoIpDataRequest{IP: res.Ip}
+	geoRes, err := h.dispatch.Services.Geo.GetIpData(ctx.Request().Context(), geoReq)
+
+	if err != nil {
+		return h.dispatch.SrvCallHandler(geoReq, err, geoip.ServiceName, "GetIpData")
+	}
+
+	res.Country = strings.ToUpper(geoRes.GetCountry().GetIsoCode())
+
+	if res.Country == "" {
+		return ctx.JSON(http.StatusOK, res)
+	}
+
+	countryReq := &billing.GetCountryRequest{IsoCode: res.Country}
+	country, err := h.dispatch.Services.Billing.GetCountry(ctx.Request().Context(), countryReq)
+
+	// the country unknown to the billing server isn't allowed for payments
+	if err != nil {
+		h.L().Info("country of client isn't found", logger.PairArgs("country", res.Country, "err", err.Error()))
+	} else {
+		res.PaymentsAllowed = country.PaymentsAllowed
+	}
+
+	groupReq := &grpc.PriceGroupByCountryRequest{Country: res.Country}
+	group, err := h.dispatch.Services.Billing.GetPriceGroupByCountry(ctx.Request().Context(), groupReq)
+
+	if err != nil {
+		return h.dispatch.SrvCallHandler(groupReq, err, pkg.ServiceName, "GetPriceGroupByCountry")
+	}
+
+	res.Region = group.Region
+	res.Currency = group.Currency
+
+	regionReq := &grpc.PriceGroupByRegionRequest{Region: group.Region}
+	regionRes, err := h.dispatch.Services.Billing.GetPriceGroupCurrencyByRegion(ctx.Request().Context(), regionReq)
+
+	if err != nil {
+		return h.dispatch.SrvCallHandler(regionReq, err, pkg.ServiceName, "GetPriceGroupCurrencyByRegion")
+	}
+
+	if len(regionRes.Region) > 0 && regionRes.Region[0].Currency != "" {
+		res.Currency = regionRes.Region[0].Currency
+	}
+
+	return ctx.JSON(http.StatusOK, res)
+}
+
+// getAcceptLanguage returns the language with the highest weight from the Accept-Language header
+func getAcceptLanguage(header string) string {
+	languages := make([]*acceptLanguage, 0)
+
+	for _, item := range strings.Split(header, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ";")
+		tag := strings.ToLower(strings.TrimSpace(parts[0]))
+
+		if idx := strings.Index(tag, "-"); idx >= 0 {
+			tag = tag[:idx]
+		}
+
+		if len(tag) < 2 || len(tag) > 3 {
+			continue
+		}
+
+		lang := &acceptLanguage{language: tag, weight: 1}
+
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+
+			if weight, err := strconv.ParseFloat(param[2:], 64); err == nil {
+				lang.weight = weight
+			}
+		}
+
+		if lang.weight > 0 {
+			languages = append(languages, lang)
+		}
+	}
+
+	if len(languages) <= 0 {
+		return checkoutLanguageDefault
+	}
+
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].weight > languages[j].weight
+	})
+
+	return languages[0].language
+}
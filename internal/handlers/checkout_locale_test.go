@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/mock"
+	"github.com/paysuper/paysuper-management-api/internal/test"
+	mock2 "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"testing"
+)
+
+type CheckoutLocaleTestSuite struct {
+	suite.Suite
+	router *CheckoutLocaleRoute
+	caller *test.EchoReqResCaller
+}
+
+func Test_CheckoutLocale(t *testing.T) {
+	suite.Run(t, new(CheckoutLocaleTestSuite))
+}
+
+func (suite *CheckoutLocaleTestSuite) SetupTest() {
+	billingService := &mocks.BillingService{}
+	billingService.On("GetCountry", mock2.Anything, mock2.MatchedBy(func(req *billing.GetCountryRequest) bool {
+		return req.IsoCode == "RU"
+	})).Return(&billing.Country{IsoCodeA2: "RU", PaymentsAllowed: true}, nil)
+	billingService.On("GetCountry", mock2.Anything, mock2.Anything).Return(nil, errors.New("not found"))
+	billingService.On("GetPriceGroupByCountry", mock2.Anything, mock2.Anything).
+		Return(&billing.PriceGroup{Id: "price_group_id", Region: "RUB", Currency: "RUB"}, nil)
+	billingService.On("GetPriceGroupCurrencyByRegion", mock2.Anything, mock2.MatchedBy(func(req *grpc.PriceGroupByRegionRequest) bool {
+		return req.Region == "RUB"
+	})).Return(&grpc.PriceGroupCurrenciesResponse{Region: []*grpc.PriceGroupRegions{{Currency: "RUB"}}}, nil)
+
+	var e error
+	settings := test.DefaultSettings()
+	srv := common.Services{
+		Billing: billingService,
+		Geo:     mock.NewGeoIpServiceTestOk(),
+	}
+	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
+		suite.router = NewCheckoutLocaleRoute(set.HandlerSet, set.GlobalConfig)
+		return common.Handlers{
+			suite.router,
+		}
+	})
+	if e != nil {
+		panic(e)
+	}
+}
+
+func (suite *CheckoutLocaleTestSuite) TearDownTest() {}
+
+func (suite *CheckoutLocaleTestSuite) getLocale(ip, acceptLanguage string) (*CheckoutLocale, error) {
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Path(common.NoAuthGroupPath + checkoutLocalePath).
+		Init(func(req *http.Request, mw test.Middleware) {
+			req.Header.Set(echo.HeaderXRealIP, ip)
+			req.Header.Set(common.HeaderAcceptLanguage, acceptLanguage)
+		}).
+		Exec(suite.T())
+
+	if err != nil {
+		return nil, err
+	}
+
+	require.Equal(suite.T(), http.StatusOK, res.Code)
+
+	locale := &CheckoutLocale{}
+	require.NoError(suite.T(), json.Unmarshal(res.Body.Bytes(), locale))
+
+	return locale, nil
+}
+
+func (suite *CheckoutLocaleTestSuite) TestCheckoutLocale_Ok() {
+	locale, err := suite.getLocale("127.0.0.1", "en-US;q=0.8, ru-RU, ru;q=0.9")
+
+	shouldBe := require.New(suite.T())
+	shouldBe.NoError(err)
+	shouldBe.Equal("127.0.0.1", locale.Ip)
+	shouldBe.Equal("RU", locale.Country)
+	shouldBe.Equal("RUB", locale.Region)
+	shouldBe.Equal("RUB", locale.Currency)
+	shouldBe.Equal("ru", locale.Language)
+	shouldBe.True(locale.PaymentsAllowed)
+}
+
+func (suite *CheckoutLocaleTestSuite) TestCheckoutLocale_CountryNotFound_NotAllowed() {
+	locale, err := suite.getLocale("10.0.0.1", "")
+
+	shouldBe := require.New(suite.T())
+	shouldBe.NoError(err)
+	shouldBe.Equal("UA", locale.Country)
+	shouldBe.Equal(checkoutLanguageDefault, locale.Language)
+	shouldBe.False(locale.PaymentsAllowed)
+}
+
+func (suite *CheckoutLocaleTestSuite) TestCheckoutLocale_GeoIpError() {
+	suite.router.dispatch.Services.Geo = mock.NewGeoIpServiceTestError()
+	_, err := suite.getLocale("127.0.0.1", "ru")
+
+	shouldBe := require.New(suite.T())
+	shouldBe.Error(err)
+	httpErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusInternalServerError, httpErr.Code)
+	shouldBe.Equal(common.ErrorInternal, httpErr.Message)
+}
+
+func (suite *CheckoutLocaleTestSuite) TestCheckoutLocale_BillingServer_Error() {
+	billingService := &mocks.BillingService{}
+	billingService.On("GetCountry", mock2.Anything, mock2.Anything).Return(&billing.Country{PaymentsAllowed: true}, nil)
+	billingService.On("GetPriceGroupByCountry", mock2.Anything, mock2.Anything).Return(nil, errors.New("some error"))
+	suite.router.dispatch.Services.Billing = billingService
+
+	_, err := suite.getLocale("127.0.0.1", "ru")
+
+	shouldBe := require.New(suite.T())
+	shouldBe.Error(err)
+	httpErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusInternalServerError, httpErr.Code)
+}
+
+func (suite *CheckoutLocaleTestSuite) TestGetAcceptLanguage() {
+	tests := map[string]string{
+		"":                               checkoutLanguageDefault,
+		"*":                              checkoutLanguageDefault,
+		"de":                             "de",
+		"fr-CH, fr;q=0.9, en;q=0.8":      "fr",
+		"en;q=0.5, de-DE;q=0.7, *;q=0.1": "de",
+		"ru;q=0, en":                     "en",
+	}
+
+	for header, language := range tests {
+		suite.Equal(language, getAcceptLanguage(header), header)
+	}
+}
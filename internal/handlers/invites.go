@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"github.com/ProtocolONE/go-core/v2/pkg/logger"
+	"github.com/ProtocolONE/go-core/v2/pkg/provider"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/invite"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	inviteBulkRowsMax         = 500
+	inviteBulkColumnEmail     = 0
+	inviteBulkColumnRole      = 1
+	inviteBulkColumnsCount    = 2
+	inviteBulkHeaderEmail     = "email"
+	inviteBulkRowStatusOk     = "invited"
+	inviteBulkRowStatusFailed = "failed"
+)
+
+// PendingInvite is the invited user role which isn't accepted yet
+type PendingInvite struct {
+	*billing.UserRole
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Expired   bool       `json:"expired"`
+}
+
+// InviteBulkRow is the result of the invite sending for the single row of the bulk invites file
+type InviteBulkRow struct {
+	Row     int                        `json:"row"`
+	Email   string                     `json:"email"`
+	Role    string                     `json:"role"`
+	Status  string                     `json:"status"`
+	Message *grpc.ResponseErrorMessage `json:"message,omitempty"`
+}
+
+// InviteBulkResult
+type InviteBulkResult struct {
+	Total   int              `json:"total"`
+	Invited int              `json:"invited"`
+	Failed  int              `json:"failed"`
+	Rows    []*InviteBulkRow `json:"rows"`
+}
+
+// inviteSender sends the single invite and returns the billing server response status and message
+type inviteSender func(email, role string) (int32, *grpc.ResponseErrorMessage, error)
+
+// getPendingInvites returns the not accepted invites sorted by the expiration time.
+// The invite expires in the lifetime after its last recorded sending. The invites sent before the sendings
+// were recorded expire in the lifetime after the role creation.
+func getPendingInvites(
+	ctx context.Context,
+	store *invite.Store,
+	roles []*billing.UserRole,
+	lifetime time.Duration,
+) ([]*PendingInvite, error) {
+	now := time.Now()
+	invites := make([]*PendingInvite, 0)
+	roleIds := make([]string, 0)
+
+	for _, role := range roles {
+		if role.Status == pkg.UserRoleStatusInvited {
+			roleIds = append(roleIds, role.Id)
+		}
+	}
+
+	sendings, err := store.GetMany(ctx, roleIds)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, role := range roles {
+		if role.Status != pkg.UserRoleStatusInvited {
+			continue
+		}
+
+		pending := &PendingInvite{UserRole: role}
+		sending, ok := sendings[role.Id]
+
+		if !ok {
+			sending = getLegacyInviteSending(role)
+		}
+
+		if sending != nil {
+			expiresAt := sending.ExpiresAt(lifetime)
+			pending.ExpiresAt = &expiresAt
+			pending.Expired = sending.IsExpired(lifetime, now)
+		}
+
+		invites = append(invites, pending)
+	}
+
+	sort.SliceStable(invites, func(i, j int) bool {
+		if invites[i].ExpiresAt == nil || invites[j].ExpiresAt == nil {
+			return invites[j].ExpiresAt == nil && invites[i].ExpiresAt != nil
+		}
+
+		return invites[i].ExpiresAt.Before(*invites[j].ExpiresAt)
+	})
+
+	return invites, nil
+}
+
+// getLegacyInviteSending returns the sending of the invite sent before the sendings were recorded.
+// The invite is sent when the role is created, nil is returned for the role without the creation time.
+func getLegacyInviteSending(role *billing.UserRole) *invite.Sending {
+	if role.CreatedAt == nil {
+		return nil
+	}
+
+	createdAt, err := ptypes.Timestamp(role.CreatedAt)
+
+	if err != nil {
+		return nil
+	}
+
+	return &invite.Sending{RoleId: role.Id, SentAt: createdAt}
+}
+
+// recordInviteSent records the sending of the invite of the role. The invite is sent already,
+// so the failed record is logged only and the invite expires in the lifetime after the role creation.
+func recordInviteSent(ctx context.Context, lmt provider.LMT, store *invite.Store, roleId string) {
+	if roleId == "" {
+		return
+	}
+
+	if _, err := store.Sent(ctx, roleId, time.Now()); err != nil {
+		lmt.L().Error(
+			"invite sending record failed",
+			logger.PairArgs("err", err.Error(), "role_id", roleId),
+		)
+	}
+}
+
+// checkInviteExpiry rejects the invite sent longer than the lifetime ago.
+// The invite sent before the sendings were recorded expires in the lifetime after the role creation.
+func checkInviteExpiry(
+	ctx context.Context,
+	lmt provider.LMT,
+	store *invite.Store,
+	role func() (*billing.UserRole, error),
+	roleId string,
+	lifetime time.Duration,
+) error {
+	sending, err := store.Get(ctx, roleId)
+
+	if err != nil {
+		lmt.L().Error(
+			"invite sending storage call failed",
+			logger.PairArgs("err", err.Error(), "role_id", roleId),
+		)
+		return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessageInviteStorageFailed)
+	}
+
+	if sending == nil {
+		userRole, err := role()
+
+		if err != nil {
+			return err
+		}
+
+		sending = getLegacyInviteSending(userRole)
+	}
+
+	if sending != nil && sending.IsExpired(lifetime, time.Now()) {
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageInviteExpired)
+	}
+
+	return nil
+}
+
+// revokeInviteSending deletes the sending of the revoked invite.
+// The invite is revoked already, so the failed deletion is logged only.
+func revokeInviteSending(ctx context.Context, lmt provider.LMT, store *invite.Store, roleId string) {
+	if err := store.Delete(ctx, roleId); err != nil {
+		lmt.L().Error(
+			"invite sending deletion failed",
+			logger.PairArgs("err", err.Error(), "role_id", roleId),
+		)
+	}
+}
+
+// findPendingInvite returns the not accepted invite by the role identifier
+func findPendingInvite(roles []*billing.UserRole, roleId string) *billing.UserRole {
+	for _, role := range roles {
+		if role.Id == roleId && role.Status == pkg.UserRoleStatusInvited {
+			return role
+		}
+	}
+
+	return nil
+}
+
+// readInvitesFile reads the CSV file with email and role columns from the multipart form.
+// The header row is optional.
+func readInvitesFile(ctx echo.Context) ([][]string, *echo.HTTPError) {
+	file, err := ctx.FormFile(common.RequestParameterFile)
+
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageInvitesFileInvalid)
+	}
+
+	src, err := file.Open()
+
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageInvitesFileInvalid)
+	}
+
+	defer func() {
+		if err := src.Close(); err != nil {
+			return
+		}
+	}()
+
+	reader := csv.NewReader(src)
+	reader.FieldsPerRecord = inviteBulkColumnsCount
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+
+	if err != nil || len(rows) <= 0 {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageInvitesFileInvalid)
+	}
+
+	if strings.EqualFold(strings.TrimSpace(rows[0][inviteBulkColumnEmail]), inviteBulkHeaderEmail) {
+		rows = rows[1:]
+	}
+
+	if len(rows) <= 0 {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageInvitesFileInvalid)
+	}
+
+	if len(rows) > inviteBulkRowsMax {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageInvitesFileTooManyRows)
+	}
+
+	return rows, nil
+}
+
+// sendBulkInvites sends the invite for every row of the file. The failed row doesn't stop the sending.
+func sendBulkInvites(set common.HandlerSet, rows [][]string, send inviteSender) *InviteBulkResult {
+	result := &InviteBulkResult{
+		Total: len(rows),
+		Rows:  make([]*InviteBulkRow, 0, len(rows)),
+	}
+
+	for i, columns := range rows {
+		row := &InviteBulkRow{
+			Row:   i + 1,
+			Email: strings.TrimSpace(columns[inviteBulkColumnEmail]),
+			Role:  strings.TrimSpace(columns[inviteBulkColumnRole]),
+		}
+		result.Rows = append(result.Rows, row)
+
+		if err := set.Validate.Var(row.Email, "required,email"); err != nil {
+			row.Status = inviteBulkRowStatusFailed
+			row.Message = common.ErrorEmailFieldIncorrect
+			result.Failed++
+			continue
+		}
+
+		if row.Role == "" {
+			row.Status = inviteBulkRowStatusFailed
+			row.Message = common.ErrorMessageInvalidRoleType
+			result.Failed++
+			continue
+		}
+
+		status, message, err := send(row.Email, row.Role)
+
+		if err != nil {
+			row.Status = inviteBulkRowStatusFailed
+			row.Message = common.ErrorMessageUnableToSendInvite
+			result.Failed++
+			continue
+		}
+
+		if status != pkg.ResponseStatusOk {
+			row.Status = inviteBulkRowStatusFailed
+			row.Message = message
+			result.Failed++
+			continue
+		}
+
+		row.Status = inviteBulkRowStatusOk
+		result.Invited++
+	}
+
+	return result
+}
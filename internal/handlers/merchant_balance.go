@@ -33,6 +33,8 @@ func NewBalanceRoute(set common.HandlerSet, cfg *common.Config) *BalanceRoute {
 func (h *BalanceRoute) Route(groups *common.Groups) {
 	groups.AuthUser.GET(balancePath, h.getBalance)
 	groups.SystemUser.GET(balanceMerchantPath, h.getBalance)
+	groups.AuthUser.GET(balanceHistoryPath, h.getBalanceHistory)
+	groups.SystemUser.GET(balanceMerchantHistoryPath, h.getBalanceHistory)
 }
 
 func (h *BalanceRoute) getBalance(ctx echo.Context) error {
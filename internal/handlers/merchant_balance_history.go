@@ -0,0 +1,417 @@
+package handlers
+
+import (
+	"context"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	balanceHistoryPath         = "/balance/history"
+	balanceMerchantHistoryPath = "/balance/:merchant_id/history"
+
+	balanceHistoryLimit = 100
+
+	balanceHistorySourceRoyaltyReport  = "royalty_report"
+	balanceHistorySourcePayoutDocument = "payout_document"
+	balanceHistorySourceRefund         = "refund"
+
+	balanceHistoryOrderStatusRefunded = "refunded"
+
+	// balanceHistoryRefundPeriod is the period after the payment the refunds of the order are looked for,
+	// so only the orders paid in the period before the ledger are listed instead of the whole merchant history
+	balanceHistoryRefundPeriod = 180 * 24 * time.Hour
+
+	// balanceHistoryPrecision drops the float noise of the running balance
+	balanceHistoryPrecision = 1e8
+)
+
+var (
+	// balanceHistoryRoyaltyReportStatuses are the statuses of the royalty reports credited to the balance
+	balanceHistoryRoyaltyReportStatuses = []string{"accepted", "waiting_payment", "paid"}
+	// balanceHistoryPayoutStatuses are the statuses of the payout documents debited from the balance
+	balanceHistoryPayoutStatuses = []string{"pending", "in_progress", "paid"}
+)
+
+type balanceHistoryRequest struct {
+	MerchantId string `json:"merchant_id" validate:"required,hexadecimal,len=24"`
+	DateFrom   int64  `query:"date_from" validate:"omitempty,gte=0"`
+	DateTo     int64  `query:"date_to" validate:"omitempty,gte=0"`
+	Currency   string `query:"currency" validate:"omitempty,len=3,alpha"`
+}
+
+// BalanceHistorySource is the document the ledger line is built from
+type BalanceHistorySource struct {
+	Type string `json:"type"`
+	Id   string `json:"id"`
+	Link string `json:"link"`
+}
+
+// BalanceHistoryLine is the single line of the ledger. The refunds are already deducted in the royalty report
+// of their period so they are shown for the reference and don't change the balance.
+type BalanceHistoryLine struct {
+	Date        time.Time             `json:"date"`
+	Description string                `json:"description"`
+	Status      string                `json:"status"`
+	Amount      float64               `json:"amount"`
+	Currency    string                `json:"currency"`
+	Balance     float64               `json:"balance"`
+	Informative bool                  `json:"informative,omitempty"`
+	Source      *BalanceHistorySource `json:"source"`
+}
+
+// BalanceHistory is the ledger of the merchant balance over the period
+type BalanceHistory struct {
+	MerchantId     string                `json:"merchant_id"`
+	DateFrom       time.Time             `json:"date_from"`
+	DateTo         time.Time             `json:"date_to"`
+	OpeningBalance float64               `json:"opening_balance"`
+	ClosingBalance float64               `json:"closing_balance"`
+	Credit         float64               `json:"credit"`
+	Debit          float64               `json:"debit"`
+	Currency       string                `json:"currency"`
+	Lines          []*BalanceHistoryLine `json:"lines"`
+}
+
+// getBalanceHistory returns the ledger of the balance changes built from the royalty reports and the payout documents
+// with the running balance, the refunds of the period are shown for the reference. The opening balance is
+// the balance of the documents before the period. The ledger has the single currency, the currency is required
+// when the merchant has the documents in several currencies.
+func (h *BalanceRoute) getBalanceHistory(ctx echo.Context) error {
+	req := &balanceHistoryRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	history := &BalanceHistory{
+		MerchantId: req.MerchantId,
+		DateFrom:   time.Unix(req.DateFrom, 0).UTC(),
+		DateTo:     time.Now().UTC(),
+		Lines:      make([]*BalanceHistoryLine, 0),
+	}
+
+	if req.DateTo > 0 {
+		history.DateTo = time.Unix(req.DateTo, 0).UTC()
+	}
+
+	if history.DateTo.Before(history.DateFrom) {
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorIncorrectPeriod)
+	}
+
+	reqCtx := ctx.Request().Context()
+	lines, err := h.getRoyaltyReportLines(reqCtx, req.MerchantId)
+
+	if err != nil {
+		return err
+	}
+
+	payouts, err := h.getPayoutDocumentLines(reqCtx, req.MerchantId)
+
+	if err != nil {
+		return err
+	}
+
+	lines, history.Currency, err = filterBalanceHistoryCurrency(append(lines, payouts...), req.Currency)
+
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].Date.Before(lines[j].Date)
+	})
+
+	balance := float64(0)
+
+	for _, line := range lines {
+		if line.Date.After(history.DateTo) {
+			break
+		}
+
+		balance = roundBalance(balance + line.Amount)
+		line.Balance = balance
+
+		if line.Date.Before(history.DateFrom) {
+			history.OpeningBalance = balance
+			continue
+		}
+
+		if line.Amount > 0 {
+			history.Credit = roundBalance(history.Credit + line.Amount)
+		} else {
+			history.Debit = roundBalance(history.Debit - line.Amount)
+		}
+
+		history.Lines = append(history.Lines, line)
+	}
+
+	history.ClosingBalance = balance
+
+	refunds, err := h.getRefundLines(reqCtx, req.MerchantId, history.DateFrom, history.DateTo)
+
+	if err != nil {
+		return err
+	}
+
+	history.Lines = mergeBalanceHistoryRefunds(history.Lines, refunds, history.OpeningBalance)
+
+	return ctx.JSON(http.StatusOK, history)
+}
+
+// getRoyaltyReportLines returns the credit lines of the accepted royalty reports
+func (h *BalanceRoute) getRoyaltyReportLines(ctx context.Context, merchantId string) ([]*BalanceHistoryLine, error) {
+	lines := make([]*BalanceHistoryLine, 0)
+	req := &grpc.ListRoyaltyReportsRequest{
+		MerchantId: merchantId,
+		Status:     balanceHistoryRoyaltyReportStatuses,
+		Limit:      balanceHistoryLimit,
+	}
+
+	for {
+		res, err := h.dispatch.Services.Billing.ListRoyaltyReports(ctx, req)
+
+		if err != nil {
+			return nil, h.dispatch.SrvCallHandler(req, err, pkg.ServiceName, "ListRoyaltyReports")
+		}
+
+		if res.Status != pkg.ResponseStatusOk {
+			return nil, echo.NewHTTPError(int(res.Status), res.Message)
+		}
+
+		for _, report := range res.Data.GetItems() {
+			lines = append(lines, getRoyaltyReportLine(report))
+		}
+
+		if int64(len(res.Data.GetItems())) < req.Limit {
+			break
+		}
+
+		req.Offset += req.Limit
+	}
+
+	return lines, nil
+}
+
+// getPayoutDocumentLines returns the debit lines of the payout documents which aren't canceled or failed
+func (h *BalanceRoute) getPayoutDocumentLines(ctx context.Context, merchantId string) ([]*BalanceHistoryLine, error) {
+	lines := make([]*BalanceHistoryLine, 0)
+	req := &grpc.GetPayoutDocumentsRequest{MerchantId: merchantId, Limit: balanceHistoryLimit}
+
+	for {
+		res, err := h.dispatch.Services.Billing.GetPayoutDocuments(ctx, req)
+
+		if err != nil {
+			return nil, h.dispatch.SrvCallHandler(req, err, pkg.ServiceName, "GetPayoutDocuments")
+		}
+
+		if res.Status != pkg.ResponseStatusOk {
+			return nil, echo.NewHTTPError(int(res.Status), res.Message)
+		}
+
+		for _, payout := range res.Data.GetItems() {
+			if containsString(balanceHistoryPayoutStatuses, payout.Status) {
+				lines = append(lines, getPayoutDocumentLine(payout))
+			}
+		}
+
+		if int64(len(res.Data.GetItems())) < req.Limit {
+			break
+		}
+
+		req.Offset += req.Limit
+	}
+
+	return lines, nil
+}
+
+// getRefundLines returns the informative lines of the refunds created in the period
+func (h *BalanceRoute) getRefundLines(ctx context.Context, merchantId string, from, to time.Time) ([]*BalanceHistoryLine, error) {
+	lines := make([]*BalanceHistoryLine, 0)
+	ordersReq := &grpc.ListOrdersRequest{
+		Merchant: []string{merchantId},
+		Status:   []string{balanceHistoryOrderStatusRefunded},
+		PmDateTo: to.Unix(),
+		Limit:    balanceHistoryLimit,
+	}
+
+	if paidFrom := from.Add(-balanceHistoryRefundPeriod); paidFrom.Unix() > 0 {
+		ordersReq.PmDateFrom = paidFrom.Unix()
+	}
+
+	for {
+		orders, err := h.dispatch.Services.Billing.FindAllOrdersPublic(ctx, ordersReq)
+
+		if err != nil {
+			return nil, h.dispatch.SrvCallHandler(ordersReq, err, pkg.ServiceName, "FindAllOrdersPublic")
+		}
+
+		if orders.Status != pkg.ResponseStatusOk {
+			return nil, echo.NewHTTPError(int(orders.Status), orders.Message)
+		}
+
+		for _, order := range orders.Item.GetItems() {
+			refundsReq := &grpc.ListRefundsRequest{OrderId: order.Uuid, Limit: balanceHistoryLimit}
+			refunds, err := h.dispatch.Services.Billing.ListRefunds(ctx, refundsReq)
+
+			if err != nil {
+				return nil, h.dispatch.SrvCallHandler(refundsReq, err, pkg.ServiceName, "ListRefunds")
+			}
+
+			for _, refund := range refunds.Items {
+				line := getRefundLine(order.Uuid, refund)
+
+				if !line.Date.Before(from) && !line.Date.After(to) {
+					lines = append(lines, line)
+				}
+			}
+		}
+
+		if int64(len(orders.Item.GetItems())) < ordersReq.Limit {
+			break
+		}
+
+		ordersReq.Offset += ordersReq.Limit
+	}
+
+	return lines, nil
+}
+
+// filterBalanceHistoryCurrency returns the lines of the currency and the currency of the ledger.
+// Without the currency the lines must have the single currency, the amounts of the different currencies can't be summed.
+func filterBalanceHistoryCurrency(lines []*BalanceHistoryLine, currency string) ([]*BalanceHistoryLine, string, error) {
+	if currency != "" {
+		currency = strings.ToUpper(currency)
+		filtered := make([]*BalanceHistoryLine, 0, len(lines))
+
+		for _, line := range lines {
+			if strings.EqualFold(line.Currency, currency) {
+				filtered = append(filtered, line)
+			}
+		}
+
+		return filtered, currency, nil
+	}
+
+	for _, line := range lines {
+		if currency == "" {
+			currency = line.Currency
+			continue
+		}
+
+		if !strings.EqualFold(line.Currency, currency) {
+			return nil, "", echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageBalanceHistoryCurrencyRequired)
+		}
+	}
+
+	return lines, currency, nil
+}
+
+func getRoyaltyReportLine(report *billing.RoyaltyReport) *BalanceHistoryLine {
+	date, ok := getTimestampTime(report.AcceptedAt)
+
+	if !ok {
+		date, _ = getTimestampTime(report.PeriodTo)
+	}
+
+	return &BalanceHistoryLine{
+		Date:        date,
+		Description: "royalty report",
+		Status:      report.Status,
+		Amount:      report.GetTotals().GetPayoutAmount(),
+		Currency:    report.Currency,
+		Source: &BalanceHistorySource{
+			Type: balanceHistorySourceRoyaltyReport,
+			Id:   report.Id,
+			Link: getBalanceHistoryLink(royaltyReportsIdPath, ":"+common.RequestParameterReportId, report.Id),
+		},
+	}
+}
+
+func getPayoutDocumentLine(payout *billing.PayoutDocument) *BalanceHistoryLine {
+	date, _ := getTimestampTime(payout.CreatedAt)
+
+	return &BalanceHistoryLine{
+		Date:        date,
+		Description: payout.Description,
+		Status:      payout.Status,
+		Amount:      -payout.TotalFees,
+		Currency:    payout.Currency,
+		Source: &BalanceHistorySource{
+			Type: balanceHistorySourcePayoutDocument,
+			Id:   payout.Id,
+			Link: getBalanceHistoryLink(payoutsIdPath, ":"+common.RequestPayoutDocumentId, payout.Id),
+		},
+	}
+}
+
+func getRefundLine(orderId string, refund *billing.Refund) *BalanceHistoryLine {
+	date, _ := getTimestampTime(refund.CreatedAt)
+
+	return &BalanceHistoryLine{
+		Date:        date,
+		Description: refund.Reason,
+		Amount:      -refund.Amount,
+		Currency:    refund.Currency,
+		Informative: true,
+		Source: &BalanceHistorySource{
+			Type: balanceHistorySourceRefund,
+			Id:   refund.Id,
+			Link: strings.NewReplacer(":"+common.RequestParameterOrderId, orderId, ":"+common.RequestParameterRefundId, refund.Id).
+				Replace(common.AuthUserGroupPath + orderRefundsIdsPath),
+		},
+	}
+}
+
+// mergeBalanceHistoryRefunds puts the refund lines between the balance lines by the date,
+// the refund line has the balance of the previous line
+func mergeBalanceHistoryRefunds(lines, refunds []*BalanceHistoryLine, balance float64) []*BalanceHistoryLine {
+	if len(refunds) <= 0 {
+		return lines
+	}
+
+	sort.SliceStable(refunds, func(i, j int) bool {
+		return refunds[i].Date.Before(refunds[j].Date)
+	})
+
+	merged := make([]*BalanceHistoryLine, 0, len(lines)+len(refunds))
+	i := 0
+
+	for _, refund := range refunds {
+		for ; i < len(lines) && !lines[i].Date.After(refund.Date); i++ {
+			merged = append(merged, lines[i])
+			balance = lines[i].Balance
+		}
+
+		refund.Balance = balance
+		merged = append(merged, refund)
+	}
+
+	return append(merged, lines[i:]...)
+}
+
+func getBalanceHistoryLink(path, param, id string) string {
+	return common.AuthUserGroupPath + strings.Replace(path, param, id, 1)
+}
+
+func roundBalance(value float64) float64 {
+	return math.Round(value*balanceHistoryPrecision) / balanceHistoryPrecision
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/test"
+	mock2 "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const (
+	balanceHistoryTestMerchantId = "5dc3f6c5ad8b8c0001b1e2b1"
+)
+
+type BalanceHistoryTestSuite struct {
+	suite.Suite
+	router  *BalanceRoute
+	caller  *test.EchoReqResCaller
+	billing *mocks.BillingService
+}
+
+func Test_BalanceHistory(t *testing.T) {
+	suite.Run(t, new(BalanceHistoryTestSuite))
+}
+
+func balanceHistoryTimestamp(month time.Month, day int) *timestamp.Timestamp {
+	ts, err := ptypes.TimestampProto(time.Date(2019, month, day, 0, 0, 0, 0, time.UTC))
+
+	if err != nil {
+		panic(err)
+	}
+
+	return ts
+}
+
+func (suite *BalanceHistoryTestSuite) SetupTest() {
+	user := &common.AuthUser{
+		Id:         "ffffffffffffffffffffffff",
+		Email:      "test@unit.test",
+		MerchantId: balanceHistoryTestMerchantId,
+	}
+
+	suite.billing = &mocks.BillingService{}
+	suite.billing.On("ListRoyaltyReports", mock2.Anything, mock2.Anything).
+		Return(&grpc.ListRoyaltyReportsResponse{
+			Status: pkg.ResponseStatusOk,
+			Data: &grpc.RoyaltyReportsPaginate{
+				Count: 2,
+				Items: []*billing.RoyaltyReport{
+					{
+						Id:         "report_2",
+						Status:     "accepted",
+						Currency:   "EUR",
+						AcceptedAt: balanceHistoryTimestamp(time.February, 10),
+						Totals:     &billing.RoyaltyReportTotals{PayoutAmount: 500},
+					},
+					{
+						Id:         "report_1",
+						Status:     "paid",
+						Currency:   "EUR",
+						AcceptedAt: balanceHistoryTimestamp(time.January, 10),
+						Totals:     &billing.RoyaltyReportTotals{PayoutAmount: 1000.1},
+					},
+				},
+			},
+		}, nil)
+	suite.billing.On("GetPayoutDocuments", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetPayoutDocumentsResponse{
+			Status: pkg.ResponseStatusOk,
+			Data: &grpc.PayoutDocumentsPaginate{
+				Count: 2,
+				Items: []*billing.PayoutDocument{
+					{Id: "payout_1", Status: "paid", Currency: "EUR", TotalFees: 800, CreatedAt: balanceHistoryTimestamp(time.January, 20)},
+					{Id: "payout_2", Status: "canceled", Currency: "EUR", TotalFees: 100, CreatedAt: balanceHistoryTimestamp(time.February, 1)},
+				},
+			},
+		}, nil)
+	suite.billing.On("FindAllOrdersPublic", mock2.Anything, mock2.Anything).
+		Return(&grpc.ListOrdersPublicResponse{
+			Status: pkg.ResponseStatusOk,
+			Item: &grpc.ListOrdersPublicResponseItem{
+				Count: 1,
+				Items: []*billing.OrderViewPublic{{Uuid: "order_1"}},
+			},
+		}, nil)
+	suite.billing.On("ListRefunds", mock2.Anything, mock2.Anything).
+		Return(&grpc.ListRefundsResponse{
+			Count: 2,
+			Items: []*billing.Refund{
+				{Id: "refund_1", Amount: 20, Currency: "USD", CreatedAt: balanceHistoryTimestamp(time.February, 5)},
+				{Id: "refund_2", Amount: 10, Currency: "USD", CreatedAt: balanceHistoryTimestamp(time.January, 5)},
+			},
+		}, nil)
+
+	var e error
+	settings := test.DefaultSettings()
+	srv := common.Services{
+		Billing: suite.billing,
+	}
+	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
+		mw.Pre(test.PreAuthUserMiddleware(user))
+		suite.router = NewBalanceRoute(set.HandlerSet, set.GlobalConfig)
+		return common.Handlers{
+			suite.router,
+		}
+	})
+	if e != nil {
+		panic(e)
+	}
+}
+
+func (suite *BalanceHistoryTestSuite) TearDownTest() {}
+
+func (suite *BalanceHistoryTestSuite) getHistory(path string, params map[string]string) *BalanceHistory {
+	builder := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterMerchantId, balanceHistoryTestMerchantId).
+		Path(path).
+		Init(test.ReqInitJSON())
+
+	for key, value := range params {
+		builder.SetQueryParam(key, value)
+	}
+
+	res, err := builder.Exec(suite.T())
+
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), http.StatusOK, res.Code)
+
+	history := &BalanceHistory{}
+	require.NoError(suite.T(), json.Unmarshal(res.Body.Bytes(), history))
+
+	return history
+}
+
+func (suite *BalanceHistoryTestSuite) TestBalanceHistory_Ok() {
+	shouldBe := require.New(suite.T())
+
+	history := suite.getHistory(common.AuthUserGroupPath+balanceHistoryPath, nil)
+	shouldBe.Equal(balanceHistoryTestMerchantId, history.MerchantId)
+	shouldBe.Equal("EUR", history.Currency)
+	shouldBe.EqualValues(0, history.OpeningBalance)
+	shouldBe.EqualValues(700.1, history.ClosingBalance)
+	shouldBe.EqualValues(1500.1, history.Credit)
+	shouldBe.EqualValues(800, history.Debit)
+	shouldBe.Len(history.Lines, 5)
+
+	shouldBe.Equal("refund_2", history.Lines[0].Source.Id)
+	shouldBe.True(history.Lines[0].Informative)
+	shouldBe.EqualValues(0, history.Lines[0].Balance)
+	shouldBe.Equal(common.AuthUserGroupPath+"/order/order_1/refunds/refund_2", history.Lines[0].Source.Link)
+
+	shouldBe.Equal("report_1", history.Lines[1].Source.Id)
+	shouldBe.Equal(balanceHistorySourceRoyaltyReport, history.Lines[1].Source.Type)
+	shouldBe.Equal(common.AuthUserGroupPath+"/royalty_reports/report_1", history.Lines[1].Source.Link)
+	shouldBe.EqualValues(1000.1, history.Lines[1].Balance)
+
+	shouldBe.Equal("payout_1", history.Lines[2].Source.Id)
+	shouldBe.Equal(common.AuthUserGroupPath+"/payout_documents/payout_1", history.Lines[2].Source.Link)
+	shouldBe.EqualValues(-800, history.Lines[2].Amount)
+	shouldBe.EqualValues(200.1, history.Lines[2].Balance)
+
+	shouldBe.Equal("refund_1", history.Lines[3].Source.Id)
+	shouldBe.EqualValues(200.1, history.Lines[3].Balance)
+
+	shouldBe.Equal("report_2", history.Lines[4].Source.Id)
+	shouldBe.EqualValues(700.1, history.Lines[4].Balance)
+}
+
+func (suite *BalanceHistoryTestSuite) TestBalanceHistory_System_Period_Ok() {
+	shouldBe := require.New(suite.T())
+
+	from := time.Date(2019, time.February, 1, 0, 0, 0, 0, time.UTC).Unix()
+	to := time.Date(2019, time.February, 9, 0, 0, 0, 0, time.UTC).Unix()
+	params := map[string]string{"date_from": strconv.FormatInt(from, 10), "date_to": strconv.FormatInt(to, 10)}
+
+	history := suite.getHistory(common.SystemUserGroupPath+balanceMerchantHistoryPath, params)
+	shouldBe.Equal(balanceHistoryTestMerchantId, history.MerchantId)
+	shouldBe.EqualValues(200.1, history.OpeningBalance)
+	shouldBe.EqualValues(200.1, history.ClosingBalance)
+	shouldBe.EqualValues(0, history.Credit)
+	shouldBe.EqualValues(0, history.Debit)
+	shouldBe.Len(history.Lines, 1)
+	shouldBe.Equal("refund_1", history.Lines[0].Source.Id)
+	shouldBe.EqualValues(200.1, history.Lines[0].Balance)
+
+	suite.billing.AssertCalled(suite.T(), "ListRoyaltyReports", mock2.Anything,
+		mock2.MatchedBy(func(req *grpc.ListRoyaltyReportsRequest) bool {
+			return req.MerchantId == balanceHistoryTestMerchantId
+		}))
+	suite.billing.AssertCalled(suite.T(), "FindAllOrdersPublic", mock2.Anything,
+		mock2.MatchedBy(func(req *grpc.ListOrdersRequest) bool {
+			return req.PmDateFrom == from-int64(balanceHistoryRefundPeriod.Seconds()) && req.PmDateTo == to
+		}))
+}
+
+func (suite *BalanceHistoryTestSuite) TestBalanceHistory_MixedCurrencies() {
+	billingService := &mocks.BillingService{}
+	billingService.On("ListRoyaltyReports", mock2.Anything, mock2.Anything).
+		Return(&grpc.ListRoyaltyReportsResponse{
+			Status: pkg.ResponseStatusOk,
+			Data: &grpc.RoyaltyReportsPaginate{
+				Count: 2,
+				Items: []*billing.RoyaltyReport{
+					{Id: "report_1", Currency: "EUR", AcceptedAt: balanceHistoryTimestamp(time.January, 10),
+						Totals: &billing.RoyaltyReportTotals{PayoutAmount: 1000}},
+					{Id: "report_2", Currency: "USD", AcceptedAt: balanceHistoryTimestamp(time.February, 10),
+						Totals: &billing.RoyaltyReportTotals{PayoutAmount: 500}},
+				},
+			},
+		}, nil)
+	billingService.On("GetPayoutDocuments", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetPayoutDocumentsResponse{Status: pkg.ResponseStatusOk, Data: &grpc.PayoutDocumentsPaginate{}}, nil)
+	billingService.On("FindAllOrdersPublic", mock2.Anything, mock2.Anything).
+		Return(&grpc.ListOrdersPublicResponse{Status: pkg.ResponseStatusOk, Item: &grpc.ListOrdersPublicResponseItem{}}, nil)
+	suite.router.dispatch.Services.Billing = billingService
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Path(common.AuthUserGroupPath + balanceHistoryPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), http.StatusBadRequest, httpErr.Code)
+	require.Equal(suite.T(), common.ErrorMessageBalanceHistoryCurrencyRequired, httpErr.Message)
+
+	history := suite.getHistory(common.AuthUserGroupPath+balanceHistoryPath, map[string]string{"currency": "usd"})
+	require.Equal(suite.T(), "USD", history.Currency)
+	require.EqualValues(suite.T(), 500, history.ClosingBalance)
+	require.Len(suite.T(), history.Lines, 1)
+	require.Equal(suite.T(), "report_2", history.Lines[0].Source.Id)
+}
+
+func (suite *BalanceHistoryTestSuite) TestBalanceHistory_PeriodInvalid() {
+	_, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Path(common.AuthUserGroupPath+balanceHistoryPath).
+		SetQueryParam("date_from", "1000").
+		SetQueryParam("date_to", "500").
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), http.StatusBadRequest, httpErr.Code)
+	require.Equal(suite.T(), common.ErrorIncorrectPeriod, httpErr.Message)
+}
+
+func (suite *BalanceHistoryTestSuite) TestBalanceHistory_BillingServer_Error() {
+	billingService := &mocks.BillingService{}
+	billingService.On("ListRoyaltyReports", mock2.Anything, mock2.Anything).Return(nil, errors.New("error"))
+	suite.router.dispatch.Services.Billing = billingService
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Path(common.AuthUserGroupPath + balanceHistoryPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), http.StatusInternalServerError, httpErr.Code)
+	require.Equal(suite.T(), common.ErrorInternal, httpErr.Message)
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/ProtocolONE/go-core/v2/pkg/logger"
+	"github.com/ProtocolONE/go-core/v2/pkg/provider"
+	"github.com/labstack/echo/v4"
+	awsWrapper "github.com/paysuper/paysuper-aws-manager"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/kyc"
+	"github.com/paysuper/paysuper-management-api/internal/storage"
+	"net/http"
+)
+
+const (
+	merchantsDocumentsPath              = "/merchants/documents"
+	merchantsDocumentsRulesPath         = "/merchants/documents/rules"
+	merchantsDocumentsTypePath          = "/merchants/documents/:document_type"
+	merchantsDocumentsTypeFilePath      = "/merchants/documents/:document_type/file"
+	merchantsIdDocumentsPath            = "/merchants/:merchant_id/documents"
+	merchantsIdDocumentsTypeFilePath    = "/merchants/:merchant_id/documents/:document_type/file"
+	merchantsIdDocumentsTypeReviewPath  = "/merchants/:merchant_id/documents/:document_type/review"
+	merchantsIdDocumentsTypeApprovePath = "/merchants/:merchant_id/documents/:document_type/approve"
+	merchantsIdDocumentsTypeRejectPath  = "/merchants/:merchant_id/documents/:document_type/reject"
+)
+
+const (
+	merchantDocumentNotificationTitle    = "KYC document %s"
+	merchantDocumentNotificationMessage  = "Your document \"%s\" has been %s."
+	merchantDocumentNotificationReason   = " Reason: %s"
+	merchantDocumentContentTypeSniffSize = 512
+)
+
+// merchantDocumentNotificationStatuses are the words of the document statuses in the merchant notifications
+var merchantDocumentNotificationStatuses = map[string]string{
+	kyc.StatusInReview: "taken into review",
+	kyc.StatusApproved: "approved",
+	kyc.StatusRejected: "rejected",
+}
+
+type merchantDocumentsRequest struct {
+	MerchantId string `json:"-" validate:"required,hexadecimal,len=24"`
+}
+
+type merchantDocumentRequest struct {
+	MerchantId   string `json:"-" validate:"required,hexadecimal,len=24"`
+	DocumentType string `json:"-" param:"document_type" validate:"required"`
+}
+
+type merchantDocumentDecisionRequest struct {
+	MerchantId   string `json:"-" validate:"required,hexadecimal,len=24"`
+	DocumentType string `json:"-" param:"document_type" validate:"required"`
+	Reason       string `json:"reason" validate:"omitempty,max=1000"`
+}
+
+type MerchantDocumentsRoute struct {
+	dispatch common.HandlerSet
+	store    *kyc.Store
+	cfg      common.Config
+	provider.LMT
+}
+
+func NewMerchantDocumentsRoute(
+	set common.HandlerSet,
+	awsManager awsWrapper.AwsManagerInterface,
+	deleter storage.Deleter,
+	cfg *common.Config,
+) *MerchantDocumentsRoute {
+	set.AwareSet.Logger = set.AwareSet.Logger.WithFields(logger.Fields{"router": "MerchantDocumentsRoute"})
+	return &MerchantDocumentsRoute{
+		dispatch: set,
+		LMT:      &set.AwareSet,
+		cfg:      *cfg,
+		store:    kyc.NewStore(awsManager, deleter),
+	}
+}
+
+func (h *MerchantDocumentsRoute) Route(groups *common.Groups) {
+	groups.AuthUser.GET(merchantsDocumentsPath, h.listDocuments)
+	groups.AuthUser.GET(merchantsDocumentsRulesPath, h.getRules)
+	groups.AuthUser.POST(merchantsDocumentsTypePath, h.uploadDocument)
+	groups.AuthUser.GET(merchantsDocumentsTypeFilePath, h.downloadDocument)
+
+	groups.SystemUser.GET(merchantsIdDocumentsPath, h.listDocuments)
+	groups.SystemUser.GET(merchantsIdDocumentsTypeFilePath, h.downloadDocument)
+	groups.SystemUser.POST(merchantsIdDocumentsTypeReviewPath, h.reviewDocument)
+	groups.SystemUser.POST(merchantsIdDocumentsTypeApprovePath, h.approveDocument)
+	groups.SystemUser.POST(merchantsIdDocumentsTypeRejectPath, h.rejectDocument)
+}
+
+func (h *MerchantDocumentsRoute) getRules(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, kyc.Rules)
+}
+
+func (h *MerchantDocumentsRoute) listDocuments(ctx echo.Context) error {
+	req := &merchantDocumentsRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	documents, err := h.store.List(ctx.Request().Context(), req.MerchantId)
+
+	if err != nil {
+		return h.storeErrorHandler(err, req.MerchantId, "")
+	}
+
+	return ctx.JSON(http.StatusOK, documents)
+}
+
+func (h *MerchantDocumentsRoute) uploadDocument(ctx echo.Context) error {
+	req := &merchantDocumentRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	file, err := ctx.FormFile(common.RequestParameterFile)
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorRequestParamsIncorrect)
+	}
+
+	src, err := file.Open()
+
+	if err != nil {
+		h.L().Error("open uploaded file failed", logger.PairArgs("err", err.Error()))
+		return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorUnknown)
+	}
+
+	defer func() {
+		if err := src.Close(); err != nil {
+			return
+		}
+	}()
+
+	buffer := make([]byte, merchantDocumentContentTypeSniffSize)
+	n, err := src.Read(buffer)
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorRequestParamsIncorrect)
+	}
+
+	if _, err = src.Seek(0, 0); err != nil {
+		h.L().Error("seek uploaded file failed", logger.PairArgs("err", err.Error()))
+		return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorUnknown)
+	}
+
+	document, err := h.store.Upload(
+		ctx.Request().Context(),
+		req.MerchantId,
+		req.DocumentType,
+		http.DetectContentType(buffer[:n]),
+		file.Size,
+		src,
+		common.ExtractUserContext(ctx).Id,
+	)
+
+	if err != nil {
+		return h.storeErrorHandler(err, req.MerchantId, req.DocumentType)
+	}
+
+	return ctx.JSON(http.StatusOK, document)
+}
+
+func (h *MerchantDocumentsRoute) downloadDocument(ctx echo.Context) error {
+	req := &merchantDocumentRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	filePath, _, err := h.store.Download(ctx.Request().Context(), req.MerchantId, req.DocumentType)
+
+	if err != nil {
+		return h.storeErrorHandler(err, req.MerchantId, req.DocumentType)
+	}
+
+	defer storage.Remove(filePath)
+
+	return ctx.File(filePath)
+}
+
+func (h *MerchantDocumentsRoute) reviewDocument(ctx echo.Context) error {
+	return h.decide(ctx, kyc.StatusInReview)
+}
+
+func (h *MerchantDocumentsRoute) approveDocument(ctx echo.Context) error {
+	return h.decide(ctx, kyc.StatusApproved)
+}
+
+func (h *MerchantDocumentsRoute) rejectDocument(ctx echo.Context) error {
+	return h.decide(ctx, kyc.StatusRejected)
+}
+
+// decide changes the document status by the system admin and notifies the merchant about the decision
+func (h *MerchantDocumentsRoute) decide(ctx echo.Context, status string) error {
+	req := &merchantDocumentDecisionRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	authUser := common.ExtractUserContext(ctx)
+	document, err := h.store.Decide(
+		ctx.Request().Context(),
+		req.MerchantId,
+		req.DocumentType,
+		status,
+		req.Reason,
+		authUser.Id,
+	)
+
+	if err != nil {
+		return h.storeErrorHandler(err, req.MerchantId, req.DocumentType)
+	}
+
+	h.notifyMerchant(ctx, req.MerchantId, authUser.Id, document)
+
+	return ctx.JSON(http.StatusOK, document)
+}
+
+// notifyMerchant sends the notification about the decision to the merchant.
+// The decision is saved already, so the notification failure is logged only.
+func (h *MerchantDocumentsRoute) notifyMerchant(ctx echo.Context, merchantId, userId string, document *kyc.Document) {
+	status := merchantDocumentNotificationStatuses[document.Status]
+	message := fmt.Sprintf(merchantDocumentNotificationMessage, document.Type, status)
+
+	if document.Reason != "" {
+		message += fmt.Sprintf(merchantDocumentNotificationReason, document.Reason)
+	}
+
+	req := &grpc.NotificationRequest{
+		MerchantId: merchantId,
+		UserId:     userId,
+		Title:      fmt.Sprintf(merchantDocumentNotificationTitle, status),
+		Message:    message,
+	}
+	res, err := h.dispatch.Services.Billing.CreateNotification(ctx.Request().Context(), req)
+
+	if err != nil {
+		common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "CreateNotification", req)
+		return
+	}
+
+	if res.Status != pkg.ResponseStatusOk {
+		h.L().Error(
+			"merchant document notification failed",
+			logger.PairArgs("merchant_id", merchantId, "document_type", document.Type, "message", res.Message),
+		)
+	}
+}
+
+func (h *MerchantDocumentsRoute) storeErrorHandler(err error, merchantId, documentType string) *echo.HTTPError {
+	switch err {
+	case kyc.ErrorDocumentTypeUnknown:
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageDocumentTypeUnknown)
+	case kyc.ErrorDocumentContentType:
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageDocumentContentType)
+	case kyc.ErrorDocumentMaxSize:
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageDocumentUploadMaxSize)
+	case kyc.ErrorDocumentNotFound:
+		return echo.NewHTTPError(http.StatusNotFound, common.ErrorMessageDocumentNotFound)
+	case kyc.ErrorDocumentLocked:
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageDocumentLocked)
+	case kyc.ErrorDocumentStatusTransition:
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageDocumentStatusTransition)
+	case kyc.ErrorDocumentRejectReasonNeeded:
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageDocumentRejectReasonRequired)
+	}
+
+	h.L().Error(
+		"merchant documents storage call failed",
+		logger.PairArgs("err", err.Error(), "merchant_id", merchantId, "document_type", documentType),
+	)
+
+	return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessageDocumentStorageFailed)
+}
@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/labstack/echo/v4"
+	awsWrapper "github.com/paysuper/paysuper-aws-manager"
+	awsWrapperMocks "github.com/paysuper/paysuper-aws-manager/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/kyc"
+	"github.com/paysuper/paysuper-management-api/internal/mock"
+	"github.com/paysuper/paysuper-management-api/internal/test"
+	mock2 "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"testing"
+)
+
+const (
+	merchantDocumentsTestMerchantId = "ffffffffffffffffffffffff"
+)
+
+type MerchantDocumentsTestSuite struct {
+	suite.Suite
+	router      *MerchantDocumentsRoute
+	caller      *test.EchoReqResCaller
+	files       map[string][]byte
+	bucket      *mock.BucketMock
+	downloadErr error
+	downloads   []string
+	somePDF     []byte
+}
+
+func Test_MerchantDocuments(t *testing.T) {
+	suite.Run(t, new(MerchantDocumentsTestSuite))
+}
+
+func (suite *MerchantDocumentsTestSuite) SetupTest() {
+	user := &common.AuthUser{
+		Id:         "ffffffffffffffffffffffff",
+		Email:      "test@unit.test",
+		MerchantId: merchantDocumentsTestMerchantId,
+	}
+	var e error
+	settings := test.DefaultSettings()
+	srv := common.Services{
+		Billing: &mocks.BillingService{},
+	}
+	suite.files = make(map[string][]byte)
+	suite.bucket = &mock.BucketMock{}
+	suite.downloadErr = nil
+	suite.downloads = nil
+	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
+		mw.Pre(test.PreAuthUserMiddleware(user))
+
+		suite.somePDF, e = ioutil.ReadFile(set.Initial.WorkDir + "/test/test_pdf.pdf")
+		if e != nil {
+			panic(e)
+		}
+
+		awsManagerMock := &awsWrapperMocks.AwsManagerInterface{}
+		awsManagerMock.On("Upload", mock2.Anything, mock2.Anything, mock2.Anything).
+			Run(func(args mock2.Arguments) {
+				in := args.Get(1).(*awsWrapper.UploadInput)
+				data, err := ioutil.ReadAll(in.Body)
+				if err != nil {
+					panic(err)
+				}
+				suite.files[in.FileName] = data
+			}).
+			Return(&s3manager.UploadOutput{}, nil)
+		awsManagerMock.On("Download", mock2.Anything, mock2.Anything, mock2.Anything, mock2.Anything).
+			Return(
+				func(ctx context.Context, filePath string, in *awsWrapper.DownloadInput, opts ...func(*s3manager.Downloader)) int64 {
+					suite.downloads = append(suite.downloads, filePath)
+					data, ok := suite.files[in.FileName]
+					if !ok || suite.downloadErr != nil {
+						return 0
+					}
+					if err := ioutil.WriteFile(filePath, data, 0644); err != nil {
+						panic(err)
+					}
+					return int64(len(data))
+				},
+				func(ctx context.Context, filePath string, in *awsWrapper.DownloadInput, opts ...func(*s3manager.Downloader)) error {
+					if suite.downloadErr != nil {
+						return suite.downloadErr
+					}
+					if _, ok := suite.files[in.FileName]; !ok {
+						return awserr.New(s3.ErrCodeNoSuchKey, "key not found", nil)
+					}
+					return nil
+				},
+			)
+
+		suite.router = NewMerchantDocumentsRoute(set.HandlerSet, awsManagerMock, suite.bucket, set.GlobalConfig)
+		return common.Handlers{
+			suite.router,
+		}
+	})
+	if e != nil {
+		panic(e)
+	}
+}
+
+func (suite *MerchantDocumentsTestSuite) TearDownTest() {}
+
+func (suite *MerchantDocumentsTestSuite) upload(documentType string, content []byte) (*kyc.Document, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile(common.RequestParameterFile, "document")
+	require.NoError(suite.T(), err)
+	_, err = part.Write(content)
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), writer.Close())
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Params(":"+common.RequestParameterDocumentType, documentType).
+		Path(common.AuthUserGroupPath + merchantsDocumentsTypePath).
+		Init(func(request *http.Request, middleware test.Middleware) {
+			request.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+		}).
+		Body(body).
+		Exec(suite.T())
+
+	if err != nil {
+		return nil, err
+	}
+
+	require.Equal(suite.T(), http.StatusOK, res.Code)
+
+	document := &kyc.Document{}
+	require.NoError(suite.T(), json.Unmarshal(res.Body.Bytes(), document))
+
+	return document, nil
+}
+
+func (suite *MerchantDocumentsTestSuite) decide(path, documentType, body string) (*kyc.Document, error) {
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Params(
+			":"+common.RequestParameterMerchantId, merchantDocumentsTestMerchantId,
+			":"+common.RequestParameterDocumentType, documentType,
+		).
+		Path(common.SystemUserGroupPath + path).
+		Init(test.ReqInitJSON()).
+		BodyString(body).
+		Exec(suite.T())
+
+	if err != nil {
+		return nil, err
+	}
+
+	require.Equal(suite.T(), http.StatusOK, res.Code)
+
+	document := &kyc.Document{}
+	require.NoError(suite.T(), json.Unmarshal(res.Body.Bytes(), document))
+
+	return document, nil
+}
+
+func (suite *MerchantDocumentsTestSuite) mockCreateNotification() *mocks.BillingService {
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("CreateNotification", mock2.Anything, mock2.Anything).
+		Return(&grpc.CreateNotificationResponse{Status: pkg.ResponseStatusOk}, nil)
+	return billingService
+}
+
+func (suite *MerchantDocumentsTestSuite) requireHttpError(err error, code int, message interface{}) {
+	shouldBe := require.New(suite.T())
+	shouldBe.Error(err)
+	hErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(code, hErr.Code)
+	shouldBe.Equal(message, hErr.Message)
+}
+
+func (suite *MerchantDocumentsTestSuite) TestMerchantDocuments_Upload_Ok() {
+	shouldBe := require.New(suite.T())
+
+	document, err := suite.upload(kyc.DocumentTypeIncorporationCertificate, suite.somePDF)
+	shouldBe.NoError(err)
+	shouldBe.Equal(kyc.StatusPending, document.Status)
+	shouldBe.Equal("application/pdf", document.ContentType)
+	shouldBe.Equal(int64(len(suite.somePDF)), document.Size)
+	shouldBe.Contains(suite.files, document.FileName)
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Path(common.AuthUserGroupPath + merchantsDocumentsPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+
+	var documents []*kyc.Document
+	shouldBe.NoError(json.Unmarshal(res.Body.Bytes(), &documents))
+	shouldBe.Len(documents, 1)
+	shouldBe.Equal(kyc.DocumentTypeIncorporationCertificate, documents[0].Type)
+}
+
+func (suite *MerchantDocumentsTestSuite) TestMerchantDocuments_List_Empty_Ok() {
+	shouldBe := require.New(suite.T())
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterMerchantId, merchantDocumentsTestMerchantId).
+		Path(common.SystemUserGroupPath + merchantsIdDocumentsPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+	shouldBe.JSONEq("[]", res.Body.String())
+}
+
+func (suite *MerchantDocumentsTestSuite) TestMerchantDocuments_Upload_UnknownType_Error() {
+	_, err := suite.upload("passport_of_cat", suite.somePDF)
+	suite.requireHttpError(err, http.StatusBadRequest, common.ErrorMessageDocumentTypeUnknown)
+}
+
+func (suite *MerchantDocumentsTestSuite) TestMerchantDocuments_Upload_ContentType_Error() {
+	_, err := suite.upload(kyc.DocumentTypeIncorporationCertificate, []byte("\xff\xd8\xff\xe0 not a pdf"))
+	suite.requireHttpError(err, http.StatusBadRequest, common.ErrorMessageDocumentContentType)
+}
+
+func (suite *MerchantDocumentsTestSuite) TestMerchantDocuments_Upload_MaxSize_Error() {
+	content := append([]byte("\xff\xd8\xff\xe0"), make([]byte, kyc.Rules[kyc.DocumentTypeDirectorId].MaxSize)...)
+	_, err := suite.upload(kyc.DocumentTypeDirectorId, content)
+	suite.requireHttpError(err, http.StatusBadRequest, common.ErrorMessageDocumentUploadMaxSize)
+}
+
+func (suite *MerchantDocumentsTestSuite) TestMerchantDocuments_Approve_Ok() {
+	shouldBe := require.New(suite.T())
+	billingService := suite.mockCreateNotification()
+
+	_, err := suite.upload(kyc.DocumentTypeDirectorId, []byte("\xff\xd8\xff\xe0 jpeg"))
+	shouldBe.NoError(err)
+
+	document, err := suite.decide(merchantsIdDocumentsTypeReviewPath, kyc.DocumentTypeDirectorId, `{}`)
+	shouldBe.NoError(err)
+	shouldBe.Equal(kyc.StatusInReview, document.Status)
+	billingService.AssertCalled(suite.T(), "CreateNotification", mock2.Anything, mock2.MatchedBy(func(req *grpc.NotificationRequest) bool {
+		return req.Title == "KYC document taken into review" &&
+			req.Message == `Your document "director_id" has been taken into review.`
+	}))
+
+	document, err = suite.decide(merchantsIdDocumentsTypeApprovePath, kyc.DocumentTypeDirectorId, `{}`)
+	shouldBe.NoError(err)
+	shouldBe.Equal(kyc.StatusApproved, document.Status)
+	shouldBe.Len(document.History, 3)
+	billingService.AssertCalled(suite.T(), "CreateNotification", mock2.Anything, mock2.MatchedBy(func(req *grpc.NotificationRequest) bool {
+		return req.MerchantId == merchantDocumentsTestMerchantId && req.Title == "KYC document approved"
+	}))
+
+	_, err = suite.upload(kyc.DocumentTypeDirectorId, []byte("\xff\xd8\xff\xe0 jpeg"))
+	suite.requireHttpError(err, http.StatusBadRequest, common.ErrorMessageDocumentLocked)
+
+	// the file of the refused upload is deleted, the approved file is kept
+	shouldBe.Len(suite.bucket.Deleted, 1)
+	shouldBe.NotEqual(document.FileName, suite.bucket.Deleted[0])
+}
+
+func (suite *MerchantDocumentsTestSuite) TestMerchantDocuments_Reject_Ok() {
+	shouldBe := require.New(suite.T())
+	billingService := suite.mockCreateNotification()
+
+	rejected, err := suite.upload(kyc.DocumentTypeProofOfAddress, suite.somePDF)
+	shouldBe.NoError(err)
+
+	document, err := suite.decide(merchantsIdDocumentsTypeRejectPath, kyc.DocumentTypeProofOfAddress, `{"reason": "document is expired"}`)
+	shouldBe.NoError(err)
+	shouldBe.Equal(kyc.StatusRejected, document.Status)
+	shouldBe.Equal("document is expired", document.Reason)
+	billingService.AssertCalled(suite.T(), "CreateNotification", mock2.Anything, mock2.MatchedBy(func(req *grpc.NotificationRequest) bool {
+		return req.Title == "KYC document rejected" && req.Message == `Your document "proof_of_address" has been rejected. Reason: document is expired`
+	}))
+
+	document, err = suite.upload(kyc.DocumentTypeProofOfAddress, suite.somePDF)
+	shouldBe.NoError(err)
+	shouldBe.Equal(kyc.StatusPending, document.Status)
+	shouldBe.Empty(document.Reason)
+
+	// the replaced file is deleted
+	shouldBe.NotEqual(rejected.FileName, document.FileName)
+	shouldBe.Equal([]string{rejected.FileName}, suite.bucket.Deleted)
+}
+
+func (suite *MerchantDocumentsTestSuite) TestMerchantDocuments_Reject_ReasonRequired_Error() {
+	_, err := suite.upload(kyc.DocumentTypeProofOfAddress, suite.somePDF)
+	require.NoError(suite.T(), err)
+
+	_, err = suite.decide(merchantsIdDocumentsTypeRejectPath, kyc.DocumentTypeProofOfAddress, `{}`)
+	suite.requireHttpError(err, http.StatusBadRequest, common.ErrorMessageDocumentRejectReasonRequired)
+}
+
+func (suite *MerchantDocumentsTestSuite) TestMerchantDocuments_Review_StatusTransition_Error() {
+	suite.mockCreateNotification()
+
+	_, err := suite.upload(kyc.DocumentTypeProofOfAddress, suite.somePDF)
+	require.NoError(suite.T(), err)
+
+	_, err = suite.decide(merchantsIdDocumentsTypeApprovePath, kyc.DocumentTypeProofOfAddress, `{}`)
+	require.NoError(suite.T(), err)
+
+	_, err = suite.decide(merchantsIdDocumentsTypeReviewPath, kyc.DocumentTypeProofOfAddress, `{}`)
+	suite.requireHttpError(err, http.StatusBadRequest, common.ErrorMessageDocumentStatusTransition)
+}
+
+func (suite *MerchantDocumentsTestSuite) TestMerchantDocuments_Approve_NotFound_Error() {
+	_, err := suite.decide(merchantsIdDocumentsTypeApprovePath, kyc.DocumentTypeDirectorId, `{}`)
+	suite.requireHttpError(err, http.StatusNotFound, common.ErrorMessageDocumentNotFound)
+}
+
+func (suite *MerchantDocumentsTestSuite) TestMerchantDocuments_Download_Ok() {
+	shouldBe := require.New(suite.T())
+
+	_, err := suite.upload(kyc.DocumentTypeIncorporationCertificate, suite.somePDF)
+	shouldBe.NoError(err)
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(
+			":"+common.RequestParameterMerchantId, merchantDocumentsTestMerchantId,
+			":"+common.RequestParameterDocumentType, kyc.DocumentTypeIncorporationCertificate,
+		).
+		Path(common.SystemUserGroupPath + merchantsIdDocumentsTypeFilePath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+	shouldBe.Equal(suite.somePDF, res.Body.Bytes())
+
+	// the temporary files of the manifest and the document are removed after the response
+	shouldBe.NotEmpty(suite.downloads)
+
+	for _, filePath := range suite.downloads {
+		_, err = os.Stat(filePath)
+		shouldBe.True(os.IsNotExist(err), filePath)
+	}
+}
+
+func (suite *MerchantDocumentsTestSuite) TestMerchantDocuments_StorageError() {
+	suite.downloadErr = errors.New("some error")
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Path(common.AuthUserGroupPath + merchantsDocumentsPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+	suite.requireHttpError(err, http.StatusInternalServerError, common.ErrorMessageDocumentStorageFailed)
+}
@@ -0,0 +1,601 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"github.com/ProtocolONE/go-core/v2/pkg/logger"
+	"github.com/ProtocolONE/go-core/v2/pkg/provider"
+	"github.com/labstack/echo/v4"
+	awsWrapper "github.com/paysuper/paysuper-aws-manager"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dataexport"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/storage"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	merchantsExportPath           = "/merchants/export"
+	merchantsExportIdPath         = "/merchants/export/:export_id"
+	merchantsExportIdDownloadPath = "/merchants/export/:export_id/download"
+	merchantsIdExportPath         = "/merchants/:merchant_id/export"
+	merchantsIdExportIdPath       = "/merchants/:merchant_id/export/:export_id"
+	merchantsIdExportDownloadPath = "/merchants/:merchant_id/export/:export_id/download"
+)
+
+const (
+	merchantExportLimit   = 100
+	merchantExportTimeout = 30 * time.Minute
+	// merchantExportCleanupInterval is how often the expired bundles are deleted from the storage
+	merchantExportCleanupInterval = time.Hour
+
+	merchantExportFileMerchant        = "merchant.json"
+	merchantExportFileCompany         = "company.json"
+	merchantExportFileContacts        = "contacts.json"
+	merchantExportFileBanking         = "banking.json"
+	merchantExportFileProjects        = "projects.json"
+	merchantExportFileProducts        = "products.json"
+	merchantExportFileKeyProducts     = "key_products.json"
+	merchantExportFileUserProfiles    = "user_profiles.json"
+	merchantExportFileNotifications   = "notifications.json"
+	merchantExportFileRoyaltyReports  = "royalty_reports.json"
+	merchantExportFilePayoutDocuments = "payout_documents.json"
+	merchantExportDirAgreements       = "agreements/"
+
+	merchantExportFileNameMask        = "merchant_data_%s.zip"
+	merchantExportCollectError        = "unable to collect merchant data"
+	merchantExportStatusError         = "%s returned status %d: %v"
+	merchantExportNotificationTitle   = "Merchant data export is ready"
+	merchantExportNotificationMessage = "The bundle of your data is ready to download: %s"
+)
+
+type merchantExportsRequest struct {
+	MerchantId string `json:"-" validate:"required,hexadecimal,len=24"`
+}
+
+type merchantExportRequest struct {
+	MerchantId string `json:"-" validate:"required,hexadecimal,len=24"`
+	ExportId   string `json:"-" param:"export_id" validate:"required,uuid"`
+}
+
+// MerchantExport is the merchant data export with the link to download the bundle when it's ready
+type MerchantExport struct {
+	*dataexport.Export
+	DownloadLink string `json:"download_link,omitempty"`
+}
+
+type MerchantExportRoute struct {
+	dispatch   common.HandlerSet
+	store      *dataexport.Store
+	agreements *storage.Store
+	jobs       sync.WaitGroup
+	cfg        common.Config
+	provider.LMT
+}
+
+func NewMerchantExportRoute(
+	set common.HandlerSet,
+	exports *dataexport.Store,
+	awsManagerAgreement awsWrapper.AwsManagerInterface,
+	cfg *common.Config,
+) *MerchantExportRoute {
+	set.AwareSet.Logger = set.AwareSet.Logger.WithFields(logger.Fields{"router": "MerchantExportRoute"})
+	return &MerchantExportRoute{
+		dispatch:   set,
+		LMT:        &set.AwareSet,
+		cfg:        *cfg,
+		store:      exports,
+		agreements: storage.New(awsManagerAgreement),
+	}
+}
+
+// Wait blocks until the started exports are collected
+func (h *MerchantExportRoute) Wait() {
+	h.jobs.Wait()
+}
+
+func (h *MerchantExportRoute) Route(groups *common.Groups) {
+	groups.AuthUser.POST(merchantsExportPath, h.createExport)
+	groups.AuthUser.GET(merchantsExportPath, h.listExports)
+	groups.AuthUser.GET(merchantsExportIdPath, h.getExport)
+	groups.AuthUser.GET(merchantsExportIdDownloadPath, h.downloadExport)
+
+	groups.SystemUser.POST(merchantsIdExportPath, h.createExport)
+	groups.SystemUser.GET(merchantsIdExportPath, h.listExports)
+	groups.SystemUser.GET(merchantsIdExportIdPath, h.getExport)
+	groups.SystemUser.GET(merchantsIdExportDownloadPath, h.downloadExport)
+}
+
+// createExport starts the collection of the merchant data bundle in the background
+func (h *MerchantExportRoute) createExport(ctx echo.Context) error {
+	req := &merchantExportsRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	authUser := common.ExtractUserContext(ctx)
+	export, dropped, err := h.store.Create(ctx.Request().Context(), req.MerchantId, authUser.Id)
+
+	if err != nil {
+		return h.storeErrorHandler(err, req.MerchantId, "")
+	}
+
+	// the bundles left behind are deleted by the cleanup when they expire
+	if err = h.store.DeleteBundles(ctx.Request().Context(), dropped); err != nil {
+		h.L().Error(
+			"merchant data export bundles delete failed",
+			logger.PairArgs("err", err.Error(), "merchant_id", req.MerchantId),
+		)
+	}
+
+	// the collector changes the status of its own copy, the response keeps the created one
+	job := *export
+	h.jobs.Add(1)
+	go h.collect(&job, authUser.Id)
+
+	return ctx.JSON(http.StatusAccepted, &MerchantExport{Export: export})
+}
+
+func (h *MerchantExportRoute) listExports(ctx echo.Context) error {
+	req := &merchantExportsRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	exports, err := h.store.List(ctx.Request().Context(), req.MerchantId)
+
+	if err != nil {
+		return h.storeErrorHandler(err, req.MerchantId, "")
+	}
+
+	items := make([]*MerchantExport, 0, len(exports))
+
+	for _, export := range exports {
+		items = append(items, h.getMerchantExport(ctx, export))
+	}
+
+	return ctx.JSON(http.StatusOK, items)
+}
+
+func (h *MerchantExportRoute) getExport(ctx echo.Context) error {
+	req := &merchantExportRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	export, err := h.store.Get(ctx.Request().Context(), req.MerchantId, req.ExportId)
+
+	if err != nil {
+		return h.storeErrorHandler(err, req.MerchantId, req.ExportId)
+	}
+
+	return ctx.JSON(http.StatusOK, h.getMerchantExport(ctx, export))
+}
+
+func (h *MerchantExportRoute) downloadExport(ctx echo.Context) error {
+	req := &merchantExportRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	export, err := h.store.Get(ctx.Request().Context(), req.MerchantId, req.ExportId)
+
+	if err != nil {
+		return h.storeErrorHandler(err, req.MerchantId, req.ExportId)
+	}
+
+	filePath, err := h.store.Download(ctx.Request().Context(), export)
+
+	if err != nil {
+		return h.storeErrorHandler(err, req.MerchantId, req.ExportId)
+	}
+
+	defer storage.Remove(filePath)
+
+	return ctx.Attachment(filePath, fmt.Sprintf(merchantExportFileNameMask, export.CreatedAt.Format("20060102150405")))
+}
+
+// getMerchantExport adds the download link to the ready export. The link points to the same users group as the request.
+func (h *MerchantExportRoute) getMerchantExport(ctx echo.Context, export *dataexport.Export) *MerchantExport {
+	item := &MerchantExport{Export: export}
+
+	if export.Status != dataexport.StatusReady {
+		return item
+	}
+
+	if strings.HasPrefix(ctx.Path(), common.SystemUserGroupPath) {
+		item.DownloadLink = getMerchantExportLink(common.SystemUserGroupPath+merchantsIdExportDownloadPath, export)
+	} else {
+		item.DownloadLink = getMerchantExportLink(common.AuthUserGroupPath+merchantsExportIdDownloadPath, export)
+	}
+
+	return item
+}
+
+// collect builds the bundle of the merchant data and saves it to the storage.
+// The request is completed already, so the failures are logged and saved to the export status.
+func (h *MerchantExportRoute) collect(export *dataexport.Export, userId string) {
+	defer h.jobs.Done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), merchantExportTimeout)
+	defer cancel()
+
+	export.Status = dataexport.StatusProcessing
+
+	if err := h.store.Update(ctx, export); err != nil {
+		h.L().Error("merchant data export status update failed", logger.PairArgs("err", err.Error(), "export_id", export.Id))
+		return
+	}
+
+	bundle := dataexport.NewBundle()
+	err := h.collectBundle(ctx, export.MerchantId, bundle)
+
+	if err == nil {
+		var content []byte
+		content, err = bundle.Close(export)
+
+		if err == nil {
+			err = h.store.SaveBundle(ctx, export, content, bundle.Files())
+		}
+	}
+
+	if err != nil {
+		h.L().Error(
+			"merchant data export failed",
+			logger.PairArgs("err", err.Error(), "merchant_id", export.MerchantId, "export_id", export.Id),
+		)
+
+		export.Status = dataexport.StatusFailed
+		export.Error = merchantExportCollectError
+
+		if err = h.store.Update(ctx, export); err != nil {
+			h.L().Error("merchant data export status update failed", logger.PairArgs("err", err.Error(), "export_id", export.Id))
+		}
+
+		return
+	}
+
+	h.notifyMerchant(ctx, export, userId)
+}
+
+func (h *MerchantExportRoute) collectBundle(ctx context.Context, merchantId string, bundle *dataexport.Bundle) error {
+	merchant, err := h.getMerchant(ctx, merchantId)
+
+	if err != nil {
+		return err
+	}
+
+	sections := []struct {
+		name string
+		data func(ctx context.Context, merchantId string) (interface{}, error)
+	}{
+		{name: merchantExportFileProjects, data: h.listProjects},
+		{name: merchantExportFileProducts, data: h.listProducts},
+		{name: merchantExportFileKeyProducts, data: h.listKeyProducts},
+		{name: merchantExportFileUserProfiles, data: h.listUserProfiles},
+		{name: merchantExportFileNotifications, data: h.listNotifications},
+		{name: merchantExportFileRoyaltyReports, data: h.listRoyaltyReports},
+		{name: merchantExportFilePayoutDocuments, data: h.listPayoutDocuments},
+	}
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{name: merchantExportFileMerchant, data: merchant},
+		{name: merchantExportFileCompany, data: merchant.Company},
+		{name: merchantExportFileContacts, data: merchant.Contacts},
+		{name: merchantExportFileBanking, data: merchant.Banking},
+	}
+
+	for _, file := range files {
+		if err = bundle.AddJson(file.name, file.data); err != nil {
+			return err
+		}
+	}
+
+	for _, section := range sections {
+		data, err := section.data(ctx, merchantId)
+
+		if err != nil {
+			return err
+		}
+
+		if err = bundle.AddJson(section.name, data); err != nil {
+			return err
+		}
+	}
+
+	return h.addAgreement(ctx, merchant, bundle)
+}
+
+// addAgreement adds the agreement document of the merchant if it's generated already
+func (h *MerchantExportRoute) addAgreement(ctx context.Context, merchant *billing.Merchant, bundle *dataexport.Bundle) error {
+	if merchant.S3AgreementName == "" {
+		return nil
+	}
+
+	content, err := h.agreements.Read(ctx, merchant.S3AgreementName)
+
+	if err != nil {
+		return err
+	}
+
+	return bundle.AddFile(merchantExportDirAgreements+filepath.Base(merchant.S3AgreementName), content)
+}
+
+func (h *MerchantExportRoute) getMerchant(ctx context.Context, merchantId string) (*billing.Merchant, error) {
+	req := &grpc.GetMerchantByRequest{MerchantId: merchantId}
+	res, err := h.dispatch.Services.Billing.GetMerchantBy(ctx, req)
+
+	if err != nil {
+		common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "GetMerchantBy", req)
+		return nil, err
+	}
+
+	if res.Status != pkg.ResponseStatusOk {
+		return nil, fmt.Errorf(merchantExportStatusError, "GetMerchantBy", res.Status, res.Message)
+	}
+
+	return res.Item, nil
+}
+
+func (h *MerchantExportRoute) listProjects(ctx context.Context, merchantId string) (interface{}, error) {
+	req := &grpc.ListProjectsRequest{Merchant: []string{merchantId}, Limit: merchantExportLimit}
+	projects := make([]*billing.Project, 0)
+
+	for {
+		res, err := h.dispatch.Services.Billing.ListProjects(ctx, req)
+
+		if err != nil {
+			common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "ListProjects", req)
+			return nil, err
+		}
+
+		projects = append(projects, res.Items...)
+
+		if int32(len(res.Items)) < req.Limit {
+			break
+		}
+
+		req.Offset += req.Limit
+	}
+
+	return projects, nil
+}
+
+func (h *MerchantExportRoute) listProducts(ctx context.Context, merchantId string) (interface{}, error) {
+	req := &grpc.ListProductsRequest{MerchantId: merchantId, Limit: merchantExportLimit}
+	products := make([]*grpc.Product, 0)
+
+	for {
+		res, err := h.dispatch.Services.Billing.ListProducts(ctx, req)
+
+		if err != nil {
+			common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "ListProducts", req)
+			return nil, err
+		}
+
+		products = append(products, res.Products...)
+		req.Offset += int64(len(res.Products))
+
+		if len(res.Products) <= 0 || req.Offset >= res.Total {
+			break
+		}
+	}
+
+	return products, nil
+}
+
+func (h *MerchantExportRoute) listKeyProducts(ctx context.Context, merchantId string) (interface{}, error) {
+	req := &grpc.ListKeyProductsRequest{MerchantId: merchantId, Limit: merchantExportLimit}
+	products := make([]*grpc.KeyProduct, 0)
+
+	for {
+		res, err := h.dispatch.Services.Billing.GetKeyProducts(ctx, req)
+
+		if err != nil {
+			common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "GetKeyProducts", req)
+			return nil, err
+		}
+
+		if res.Status != pkg.ResponseStatusOk {
+			return nil, fmt.Errorf(merchantExportStatusError, "GetKeyProducts", res.Status, res.Message)
+		}
+
+		products = append(products, res.Products...)
+
+		if int64(len(res.Products)) < req.Limit {
+			break
+		}
+
+		req.Offset += req.Limit
+	}
+
+	return products, nil
+}
+
+// listUserProfiles returns the profiles of the merchant users, the invited users may have no profile yet
+func (h *MerchantExportRoute) listUserProfiles(ctx context.Context, merchantId string) (interface{}, error) {
+	req := &grpc.GetMerchantUsersRequest{MerchantId: merchantId}
+	res, err := h.dispatch.Services.Billing.GetMerchantUsers(ctx, req)
+
+	if err != nil {
+		common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "GetMerchantUsers", req)
+		return nil, err
+	}
+
+	if res.Status != pkg.ResponseStatusOk {
+		return nil, fmt.Errorf(merchantExportStatusError, "GetMerchantUsers", res.Status, res.Message)
+	}
+
+	profiles := make([]*grpc.UserProfile, 0, len(res.Users))
+
+	for _, user := range res.Users {
+		if user.UserId == "" {
+			continue
+		}
+
+		profileReq := &grpc.GetUserProfileRequest{UserId: user.UserId}
+		profileRes, err := h.dispatch.Services.Billing.GetUserProfile(ctx, profileReq)
+
+		if err != nil {
+			common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "GetUserProfile", profileReq)
+			return nil, err
+		}
+
+		if profileRes.Status == pkg.ResponseStatusNotFound {
+			continue
+		}
+
+		if profileRes.Status != pkg.ResponseStatusOk {
+			return nil, fmt.Errorf(merchantExportStatusError, "GetUserProfile", profileRes.Status, profileRes.Message)
+		}
+
+		profiles = append(profiles, profileRes.Item)
+	}
+
+	return profiles, nil
+}
+
+func (h *MerchantExportRoute) listNotifications(ctx context.Context, merchantId string) (interface{}, error) {
+	req := &grpc.ListingNotificationRequest{MerchantId: merchantId, Limit: merchantExportLimit}
+	notifications := make([]*billing.Notification, 0)
+
+	for {
+		res, err := h.dispatch.Services.Billing.ListNotifications(ctx, req)
+
+		if err != nil {
+			common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "ListNotifications", req)
+			return nil, err
+		}
+
+		notifications = append(notifications, res.Items...)
+
+		if int64(len(res.Items)) < req.Limit {
+			break
+		}
+
+		req.Offset += req.Limit
+	}
+
+	return notifications, nil
+}
+
+func (h *MerchantExportRoute) listRoyaltyReports(ctx context.Context, merchantId string) (interface{}, error) {
+	req := &grpc.ListRoyaltyReportsRequest{MerchantId: merchantId, Limit: merchantExportLimit}
+	reports := make([]*billing.RoyaltyReport, 0)
+
+	for {
+		res, err := h.dispatch.Services.Billing.ListRoyaltyReports(ctx, req)
+
+		if err != nil {
+			common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "ListRoyaltyReports", req)
+			return nil, err
+		}
+
+		if res.Status != pkg.ResponseStatusOk {
+			return nil, fmt.Errorf(merchantExportStatusError, "ListRoyaltyReports", res.Status, res.Message)
+		}
+
+		reports = append(reports, res.Data.GetItems()...)
+
+		if int64(len(res.Data.GetItems())) < req.Limit {
+			break
+		}
+
+		req.Offset += req.Limit
+	}
+
+	return reports, nil
+}
+
+func (h *MerchantExportRoute) listPayoutDocuments(ctx context.Context, merchantId string) (interface{}, error) {
+	req := &grpc.GetPayoutDocumentsRequest{MerchantId: merchantId, Limit: merchantExportLimit}
+	payouts := make([]*billing.PayoutDocument, 0)
+
+	for {
+		res, err := h.dispatch.Services.Billing.GetPayoutDocuments(ctx, req)
+
+		if err != nil {
+			common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "GetPayoutDocuments", req)
+			return nil, err
+		}
+
+		if res.Status != pkg.ResponseStatusOk {
+			return nil, fmt.Errorf(merchantExportStatusError, "GetPayoutDocuments", res.Status, res.Message)
+		}
+
+		payouts = append(payouts, res.Data.GetItems()...)
+
+		if int64(len(res.Data.GetItems())) < req.Limit {
+			break
+		}
+
+		req.Offset += req.Limit
+	}
+
+	return payouts, nil
+}
+
+// notifyMerchant sends the notification with the download link to the merchant.
+// The bundle is saved already, so the notification failure is logged only.
+func (h *MerchantExportRoute) notifyMerchant(ctx context.Context, export *dataexport.Export, userId string) {
+	req := &grpc.NotificationRequest{
+		MerchantId: export.MerchantId,
+		UserId:     userId,
+		Title:      merchantExportNotificationTitle,
+		Message: fmt.Sprintf(
+			merchantExportNotificationMessage,
+			getMerchantExportLink(common.AuthUserGroupPath+merchantsExportIdDownloadPath, export),
+		),
+	}
+	res, err := h.dispatch.Services.Billing.CreateNotification(ctx, req)
+
+	if err != nil {
+		common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "CreateNotification", req)
+		return
+	}
+
+	if res.Status != pkg.ResponseStatusOk {
+		h.L().Error(
+			"merchant data export notification failed",
+			logger.PairArgs("merchant_id", export.MerchantId, "export_id", export.Id, "message", res.Message),
+		)
+	}
+}
+
+func (h *MerchantExportRoute) storeErrorHandler(err error, merchantId, exportId string) *echo.HTTPError {
+	switch err {
+	case dataexport.ErrorExportInProgress:
+		return echo.NewHTTPError(http.StatusConflict, common.ErrorMessageMerchantExportInProgress)
+	case dataexport.ErrorExportNotFound:
+		return echo.NewHTTPError(http.StatusNotFound, common.ErrorMessageMerchantExportNotFound)
+	case dataexport.ErrorExportNotReady:
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageMerchantExportNotReady)
+	case dataexport.ErrorExportExpired:
+		return echo.NewHTTPError(http.StatusGone, common.ErrorMessageMerchantExportExpired)
+	}
+
+	h.L().Error(
+		"merchant data export storage call failed",
+		logger.PairArgs("err", err.Error(), "merchant_id", merchantId, "export_id", exportId),
+	)
+
+	return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessageMerchantExportStorageFailed)
+}
+
+func getMerchantExportLink(path string, export *dataexport.Export) string {
+	path = strings.Replace(path, ":"+common.RequestParameterMerchantId, export.MerchantId, 1)
+
+	return strings.Replace(path, ":"+common.RequestParameterExportId, export.Id, 1)
+}
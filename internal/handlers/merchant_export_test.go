@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dataexport"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/mock"
+	"github.com/paysuper/paysuper-management-api/internal/test"
+	mock2 "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const (
+	merchantExportTestMerchantId    = "5dc3f6c5ad8b8c0001b1e2b1"
+	merchantExportTestAgreementName = "agreement_5dc3f6c5ad8b8c0001b1e2b1.pdf"
+)
+
+type MerchantExportTestSuite struct {
+	suite.Suite
+	router     *MerchantExportRoute
+	caller     *test.EchoReqResCaller
+	billing    *mocks.BillingService
+	files      map[string][]byte
+	bucket     *mock.BucketMock
+	agreements map[string][]byte
+}
+
+func Test_MerchantExport(t *testing.T) {
+	suite.Run(t, new(MerchantExportTestSuite))
+}
+
+func (suite *MerchantExportTestSuite) SetupTest() {
+	user := &common.AuthUser{
+		Id:         "ffffffffffffffffffffffff",
+		Email:      "test@unit.test",
+		MerchantId: merchantExportTestMerchantId,
+	}
+
+	suite.billing = &mocks.BillingService{}
+	suite.billing.On("GetMerchantBy", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetMerchantResponse{
+			Status: pkg.ResponseStatusOk,
+			Item: &billing.Merchant{
+				Id:              merchantExportTestMerchantId,
+				Company:         &billing.MerchantCompanyInfo{Name: "Merchant"},
+				S3AgreementName: merchantExportTestAgreementName,
+			},
+		}, nil)
+	suite.billing.On("ListProjects", mock2.Anything, mock2.Anything).
+		Return(&grpc.ListProjectsResponse{Count: 1, Items: []*billing.Project{{Id: "project_1"}}}, nil)
+	suite.billing.On("ListProducts", mock2.Anything, mock2.Anything).
+		Return(&grpc.ListProductsResponse{Total: 1, Products: []*grpc.Product{{Id: "product_1"}}}, nil)
+	suite.billing.On("GetKeyProducts", mock2.Anything, mock2.Anything).
+		Return(&grpc.ListKeyProductsResponse{Status: pkg.ResponseStatusOk, Products: []*grpc.KeyProduct{{Id: "key_product_1"}}}, nil)
+	suite.billing.On("GetMerchantUsers", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetMerchantUsersResponse{
+			Status: pkg.ResponseStatusOk,
+			Users:  []*billing.UserRole{{Id: "role_1", UserId: user.Id}, {Id: "role_2"}},
+		}, nil)
+	suite.billing.On("GetUserProfile", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetUserProfileResponse{Status: pkg.ResponseStatusOk, Item: &grpc.UserProfile{Id: "profile_1", UserId: user.Id}}, nil)
+	suite.billing.On("ListNotifications", mock2.Anything, mock2.Anything).
+		Return(&grpc.Notifications{Count: 1, Items: []*billing.Notification{{Id: "notification_1"}}}, nil)
+	suite.billing.On("ListRoyaltyReports", mock2.Anything, mock2.Anything).
+		Return(&grpc.ListRoyaltyReportsResponse{
+			Status: pkg.ResponseStatusOk,
+			Data:   &grpc.RoyaltyReportsPaginate{Count: 1, Items: []*billing.RoyaltyReport{{Id: "report_1"}}},
+		}, nil)
+	suite.billing.On("GetPayoutDocuments", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetPayoutDocumentsResponse{
+			Status: pkg.ResponseStatusOk,
+			Data:   &grpc.PayoutDocumentsPaginate{Count: 1, Items: []*billing.PayoutDocument{{Id: "payout_1"}}},
+		}, nil)
+	suite.billing.On("CreateNotification", mock2.Anything, mock2.Anything).
+		Return(&grpc.CreateNotificationResponse{Status: pkg.ResponseStatusOk}, nil)
+
+	suite.files = make(map[string][]byte)
+	suite.bucket = &mock.BucketMock{}
+	suite.agreements = map[string][]byte{merchantExportTestAgreementName: []byte("%PDF")}
+
+	var e error
+	settings := test.DefaultSettings()
+	srv := common.Services{
+		Billing: suite.billing,
+	}
+	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
+		mw.Pre(test.PreAuthUserMiddleware(user))
+		suite.router = NewMerchantExportRoute(
+			set.HandlerSet,
+			dataexport.NewStore(mock.NewAwsManagerFilesMock(suite.files), suite.bucket),
+			mock.NewAwsManagerFilesMock(suite.agreements),
+			set.GlobalConfig,
+		)
+		return common.Handlers{
+			suite.router,
+		}
+	})
+	if e != nil {
+		panic(e)
+	}
+}
+
+func (suite *MerchantExportTestSuite) TearDownTest() {}
+
+func (suite *MerchantExportTestSuite) createExport() *MerchantExport {
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.AuthUserGroupPath + merchantsExportPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), http.StatusAccepted, res.Code)
+
+	export := &MerchantExport{}
+	require.NoError(suite.T(), json.Unmarshal(res.Body.Bytes(), export))
+
+	return export
+}
+
+func (suite *MerchantExportTestSuite) getExport(id string) *MerchantExport {
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterExportId, id).
+		Path(common.AuthUserGroupPath + merchantsExportIdPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), http.StatusOK, res.Code)
+
+	export := &MerchantExport{}
+	require.NoError(suite.T(), json.Unmarshal(res.Body.Bytes(), export))
+
+	return export
+}
+
+func (suite *MerchantExportTestSuite) TestMerchantExport_Ok() {
+	shouldBe := require.New(suite.T())
+
+	created := suite.createExport()
+	shouldBe.Equal(dataexport.StatusPending, created.Status)
+	shouldBe.Equal(merchantExportTestMerchantId, created.MerchantId)
+	shouldBe.Empty(created.DownloadLink)
+
+	suite.router.Wait()
+
+	export := suite.getExport(created.Id)
+	shouldBe.Equal(dataexport.StatusReady, export.Status)
+	shouldBe.Equal(common.AuthUserGroupPath+"/merchants/export/"+created.Id+"/download", export.DownloadLink)
+	shouldBe.Contains(export.Files, merchantExportFileBanking)
+	shouldBe.Contains(export.Files, merchantExportFileUserProfiles)
+	shouldBe.Contains(export.Files, merchantExportDirAgreements+merchantExportTestAgreementName)
+
+	suite.billing.AssertNumberOfCalls(suite.T(), "GetUserProfile", 1)
+	suite.billing.AssertCalled(suite.T(), "CreateNotification", mock2.Anything,
+		mock2.MatchedBy(func(req *grpc.NotificationRequest) bool {
+			return req.MerchantId == merchantExportTestMerchantId && req.Title == merchantExportNotificationTitle
+		}))
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterExportId, created.Id).
+		Path(common.AuthUserGroupPath + merchantsExportIdDownloadPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+
+	reader, err := zip.NewReader(bytes.NewReader(res.Body.Bytes()), int64(res.Body.Len()))
+	shouldBe.NoError(err)
+	shouldBe.Len(reader.File, len(export.Files))
+}
+
+func (suite *MerchantExportTestSuite) TestMerchantExport_System_List_Ok() {
+	shouldBe := require.New(suite.T())
+
+	created := suite.createExport()
+	suite.router.Wait()
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterMerchantId, merchantExportTestMerchantId).
+		Path(common.SystemUserGroupPath + merchantsIdExportPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+
+	exports := make([]*MerchantExport, 0)
+	shouldBe.NoError(json.Unmarshal(res.Body.Bytes(), &exports))
+	shouldBe.Len(exports, 1)
+	shouldBe.Equal(created.Id, exports[0].Id)
+	shouldBe.Equal(
+		common.SystemUserGroupPath+"/merchants/"+merchantExportTestMerchantId+"/export/"+created.Id+"/download",
+		exports[0].DownloadLink,
+	)
+}
+
+func (suite *MerchantExportTestSuite) TestMerchantExport_InProgress() {
+	_, _, err := suite.router.store.Create(context.Background(), merchantExportTestMerchantId, "ffffffffffffffffffffffff")
+	require.NoError(suite.T(), err)
+
+	_, err = suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.AuthUserGroupPath + merchantsExportPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), http.StatusConflict, httpErr.Code)
+	require.Equal(suite.T(), common.ErrorMessageMerchantExportInProgress, httpErr.Message)
+}
+
+func (suite *MerchantExportTestSuite) TestMerchantExport_Expired() {
+	shouldBe := require.New(suite.T())
+
+	created := suite.createExport()
+	suite.router.Wait()
+
+	export := suite.getExport(created.Id)
+	expiresAt := time.Now().Add(-time.Minute)
+	export.ExpiresAt = &expiresAt
+	shouldBe.NoError(suite.router.store.Update(context.Background(), export.Export))
+
+	export = suite.getExport(created.Id)
+	shouldBe.Equal(dataexport.StatusExpired, export.Status)
+	shouldBe.Empty(export.DownloadLink)
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterExportId, created.Id).
+		Path(common.AuthUserGroupPath + merchantsExportIdDownloadPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.Error(err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusGone, httpErr.Code)
+	shouldBe.Equal(common.ErrorMessageMerchantExportExpired, httpErr.Message)
+}
+
+func (suite *MerchantExportTestSuite) TestMerchantExport_NotFound() {
+	_, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterExportId, "7a3b3a2e-8d34-4b6b-9c1f-3f6a5a0c2d11").
+		Path(common.AuthUserGroupPath + merchantsExportIdPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), http.StatusNotFound, httpErr.Code)
+	require.Equal(suite.T(), common.ErrorMessageMerchantExportNotFound, httpErr.Message)
+}
+
+func (suite *MerchantExportTestSuite) TestMerchantExport_BillingServer_Error() {
+	shouldBe := require.New(suite.T())
+
+	billingService := &mocks.BillingService{}
+	billingService.On("GetMerchantBy", mock2.Anything, mock2.Anything).Return(nil, errors.New("error"))
+	suite.router.dispatch.Services.Billing = billingService
+
+	created := suite.createExport()
+	suite.router.Wait()
+
+	export := suite.getExport(created.Id)
+	shouldBe.Equal(dataexport.StatusFailed, export.Status)
+	shouldBe.Equal(merchantExportCollectError, export.Error)
+	shouldBe.Empty(export.DownloadLink)
+	billingService.AssertNotCalled(suite.T(), "CreateNotification", mock2.Anything, mock2.Anything)
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterExportId, created.Id).
+		Path(common.AuthUserGroupPath + merchantsExportIdDownloadPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.Error(err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusBadRequest, httpErr.Code)
+}
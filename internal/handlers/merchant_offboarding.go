@@ -0,0 +1,568 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/ProtocolONE/go-core/v2/pkg/logger"
+	"github.com/ProtocolONE/go-core/v2/pkg/provider"
+	"github.com/labstack/echo/v4"
+	awsWrapper "github.com/paysuper/paysuper-aws-manager"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/offboarding"
+	"github.com/paysuper/paysuper-management-api/internal/storage"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	merchantsIdOffboardingPath = "/merchants/:merchant_id/offboarding"
+)
+
+const (
+	merchantOffboardingLimit = 100
+	// merchantOffboardingStepTimeout is less than the lease of the run, so the step is done before the lease expires
+	merchantOffboardingStepTimeout = 10 * time.Minute
+
+	merchantOffboardingArchiveFileMask = "archive/%s/%s"
+	merchantOffboardingStatusError     = "%s returned status %d: %v"
+
+	merchantOffboardingBlockerBalance        = "merchant balance isn't zero: %.2f %s"
+	merchantOffboardingBlockerRoyaltyReports = "merchant has %d royalty reports which aren't paid"
+	merchantOffboardingBlockerPayouts        = "merchant has %d payouts in progress"
+	merchantOffboardingBlockerRefunds        = "merchant has %d open refunds"
+)
+
+var (
+	// merchantOffboardingRoyaltyReportStatuses are the statuses of the royalty reports waiting for the payout
+	merchantOffboardingRoyaltyReportStatuses = []string{"pending", "accepted", "dispute", "waiting_payment"}
+	// merchantOffboardingPayoutStatuses are the statuses of the payout documents which aren't finished
+	merchantOffboardingPayoutStatuses = []string{"pending", "in_progress"}
+	// merchantOffboardingRefundableOrderStatuses are the statuses of the orders which may have the refunds,
+	// the partially refunded order stays processed
+	merchantOffboardingRefundableOrderStatuses = []string{"processed", "refunded", "chargeback"}
+	// merchantOffboardingRefundStatuses are the statuses of the refunds which aren't finished
+	merchantOffboardingRefundStatuses = []int32{pkg.RefundStatusCreated, pkg.RefundStatusInProgress}
+)
+
+type merchantOffboardingRequest struct {
+	MerchantId string `json:"-" validate:"required,hexadecimal,len=24"`
+	Reason     string `json:"reason" validate:"omitempty,max=1000"`
+}
+
+type MerchantOffboardingRoute struct {
+	dispatch   common.HandlerSet
+	store      *offboarding.Store
+	agreements *storage.Store
+	jobs       sync.WaitGroup
+	cfg        common.Config
+	provider.LMT
+}
+
+func NewMerchantOffboardingRoute(
+	set common.HandlerSet,
+	awsManager awsWrapper.AwsManagerInterface,
+	awsManagerAgreement awsWrapper.AwsManagerInterface,
+	cfg *common.Config,
+) *MerchantOffboardingRoute {
+	set.AwareSet.Logger = set.AwareSet.Logger.WithFields(logger.Fields{"router": "MerchantOffboardingRoute"})
+	return &MerchantOffboardingRoute{
+		dispatch:   set,
+		LMT:        &set.AwareSet,
+		cfg:        *cfg,
+		store:      offboarding.NewStore(awsManager),
+		agreements: storage.New(awsManagerAgreement),
+	}
+}
+
+// Wait blocks until the started offboarding runs are finished
+func (h *MerchantOffboardingRoute) Wait() {
+	h.jobs.Wait()
+}
+
+func (h *MerchantOffboardingRoute) Route(groups *common.Groups) {
+	groups.SystemUser.GET(merchantsIdOffboardingPath, h.getOffboarding)
+	groups.SystemUser.POST(merchantsIdOffboardingPath, h.runOffboarding)
+}
+
+func (h *MerchantOffboardingRoute) getOffboarding(ctx echo.Context) error {
+	req := &merchantOffboardingRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	w, err := h.store.Get(ctx.Request().Context(), req.MerchantId)
+
+	if err != nil {
+		return h.storeErrorHandler(err, req.MerchantId)
+	}
+
+	return ctx.JSON(http.StatusOK, w)
+}
+
+// runOffboarding starts the offboarding of the merchant or resumes the blocked, failed or interrupted one.
+// The steps run in the background, every step is saved as soon as it's finished and the completed steps
+// aren't repeated. The progress is read with getOffboarding.
+func (h *MerchantOffboardingRoute) runOffboarding(ctx echo.Context) error {
+	req := &merchantOffboardingRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	merchant, hErr := h.getMerchant(ctx, req.MerchantId)
+
+	if hErr != nil {
+		return hErr
+	}
+
+	authUser := common.ExtractUserContext(ctx)
+	w, err := h.store.Start(ctx.Request().Context(), req.MerchantId, authUser.Id, req.Reason)
+
+	if err != nil {
+		return h.storeErrorHandler(err, req.MerchantId)
+	}
+
+	if w.Status == offboarding.StatusCompleted {
+		return ctx.JSON(http.StatusOK, w)
+	}
+
+	// the run changes the workflow, so the response is written before the run is started
+	if err = ctx.JSON(http.StatusAccepted, w); err != nil {
+		return err
+	}
+
+	h.jobs.Add(1)
+	go h.run(w, merchant, authUser.Id)
+
+	return nil
+}
+
+// run executes the steps of the workflow until it's completed, blocked or failed.
+// The request is completed already, so the failures are logged and saved to the workflow.
+func (h *MerchantOffboardingRoute) run(w *offboarding.Workflow, merchant *billing.Merchant, userId string) {
+	defer h.jobs.Done()
+
+	for step := w.NextStep(); step != nil && w.Status == offboarding.StatusInProgress; step = w.NextStep() {
+		h.execStep(w, step, merchant, userId)
+
+		if err := h.store.Save(context.Background(), w); err != nil {
+			h.L().Error(
+				"merchant offboarding save failed",
+				logger.PairArgs("err", err.Error(), "merchant_id", merchant.Id, "step", step.Name),
+			)
+			return
+		}
+	}
+}
+
+func (h *MerchantOffboardingRoute) execStep(
+	w *offboarding.Workflow,
+	step *offboarding.Step,
+	merchant *billing.Merchant,
+	userId string,
+) {
+	ctx, cancel := context.WithTimeout(context.Background(), merchantOffboardingStepTimeout)
+	defer cancel()
+
+	items, blockers, err := h.runStep(ctx, step.Name, merchant, userId, w.Reason)
+
+	switch {
+	case err != nil:
+		h.L().Error(
+			"merchant offboarding step failed",
+			logger.PairArgs("err", err.Error(), "merchant_id", merchant.Id, "step", step.Name),
+		)
+		w.Fail(step, err.Error())
+	case len(blockers) > 0:
+		w.Block(step, blockers)
+	default:
+		w.Complete(step, items)
+	}
+}
+
+func (h *MerchantOffboardingRoute) runStep(
+	ctx context.Context,
+	name string,
+	merchant *billing.Merchant,
+	userId, reason string,
+) ([]string, []string, error) {
+	switch name {
+	case offboarding.StepCheckPreconditions:
+		blockers, err := h.checkPreconditions(ctx, merchant.Id)
+		return nil, blockers, err
+	case offboarding.StepUnpublishKeyProducts:
+		items, err := h.unpublishKeyProducts(ctx, merchant.Id)
+		return items, nil, err
+	case offboarding.StepDeactivateProjects:
+		items, err := h.deactivateProjects(ctx, merchant.Id)
+		return items, nil, err
+	case offboarding.StepRevokeUsers:
+		items, err := h.revokeUsers(ctx, merchant.Id)
+		return items, nil, err
+	case offboarding.StepArchiveAgreements:
+		items, err := h.archiveAgreements(ctx, merchant)
+		return items, nil, err
+	case offboarding.StepCloseAccount:
+		return nil, nil, h.closeAccount(ctx, merchant.Id, userId, reason)
+	}
+
+	return nil, nil, fmt.Errorf("unknown offboarding step %s", name)
+}
+
+// checkPreconditions returns the reasons why the merchant account can't be closed yet
+func (h *MerchantOffboardingRoute) checkPreconditions(ctx context.Context, merchantId string) ([]string, error) {
+	blockers := make([]string, 0)
+
+	balanceReq := &grpc.GetMerchantBalanceRequest{MerchantId: merchantId}
+	balance, err := h.dispatch.Services.Billing.GetMerchantBalance(ctx, balanceReq)
+
+	if err != nil {
+		common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "GetMerchantBalance", balanceReq)
+		return nil, err
+	}
+
+	if balance.Status != pkg.ResponseStatusOk {
+		return nil, fmt.Errorf(merchantOffboardingStatusError, "GetMerchantBalance", balance.Status, balance.Message)
+	}
+
+	if balance.Item != nil && roundBalance(balance.Item.Total) != 0 {
+		blockers = append(blockers, fmt.Sprintf(merchantOffboardingBlockerBalance, balance.Item.Total, balance.Item.Currency))
+	}
+
+	reportsReq := &grpc.ListRoyaltyReportsRequest{
+		MerchantId: merchantId,
+		Status:     merchantOffboardingRoyaltyReportStatuses,
+		Limit:      1,
+	}
+	reports, err := h.dispatch.Services.Billing.ListRoyaltyReports(ctx, reportsReq)
+
+	if err != nil {
+		common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "ListRoyaltyReports", reportsReq)
+		return nil, err
+	}
+
+	if reports.Status != pkg.ResponseStatusOk {
+		return nil, fmt.Errorf(merchantOffboardingStatusError, "ListRoyaltyReports", reports.Status, reports.Message)
+	}
+
+	if count := reports.Data.GetCount(); count > 0 {
+		blockers = append(blockers, fmt.Sprintf(merchantOffboardingBlockerRoyaltyReports, count))
+	}
+
+	payouts, err := h.countPayouts(ctx, merchantId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if payouts > 0 {
+		blockers = append(blockers, fmt.Sprintf(merchantOffboardingBlockerPayouts, payouts))
+	}
+
+	refunds, err := h.countRefunds(ctx, merchantId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if refunds > 0 {
+		blockers = append(blockers, fmt.Sprintf(merchantOffboardingBlockerRefunds, refunds))
+	}
+
+	return blockers, nil
+}
+
+// countPayouts returns the count of the payout documents which aren't paid, canceled or failed
+func (h *MerchantOffboardingRoute) countPayouts(ctx context.Context, merchantId string) (int, error) {
+	count := 0
+	req := &grpc.GetPayoutDocumentsRequest{MerchantId: merchantId, Limit: merchantOffboardingLimit}
+
+	for {
+		res, err := h.dispatch.Services.Billing.GetPayoutDocuments(ctx, req)
+
+		if err != nil {
+			common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "GetPayoutDocuments", req)
+			return 0, err
+		}
+
+		if res.Status != pkg.ResponseStatusOk {
+			return 0, fmt.Errorf(merchantOffboardingStatusError, "GetPayoutDocuments", res.Status, res.Message)
+		}
+
+		for _, payout := range res.Data.GetItems() {
+			if containsString(merchantOffboardingPayoutStatuses, payout.Status) {
+				count++
+			}
+		}
+
+		if int64(len(res.Data.GetItems())) < req.Limit {
+			break
+		}
+
+		req.Offset += req.Limit
+	}
+
+	return count, nil
+}
+
+// countRefunds returns the count of the refunds which aren't finished yet. The order gets the refunded status
+// only when its refund is done, so the orders of every status which may have the refunds are checked.
+func (h *MerchantOffboardingRoute) countRefunds(ctx context.Context, merchantId string) (int, error) {
+	count := 0
+	ordersReq := &grpc.ListOrdersRequest{
+		Merchant: []string{merchantId},
+		Status:   merchantOffboardingRefundableOrderStatuses,
+		Limit:    merchantOffboardingLimit,
+	}
+
+	for {
+		orders, err := h.dispatch.Services.Billing.FindAllOrdersPublic(ctx, ordersReq)
+
+		if err != nil {
+			common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "FindAllOrdersPublic", ordersReq)
+			return 0, err
+		}
+
+		if orders.Status != pkg.ResponseStatusOk {
+			return 0, fmt.Errorf(merchantOffboardingStatusError, "FindAllOrdersPublic", orders.Status, orders.Message)
+		}
+
+		for _, order := range orders.Item.GetItems() {
+			refundsReq := &grpc.ListRefundsRequest{OrderId: order.Uuid, Limit: merchantOffboardingLimit}
+			refunds, err := h.dispatch.Services.Billing.ListRefunds(ctx, refundsReq)
+
+			if err != nil {
+				common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "ListRefunds", refundsReq)
+				return 0, err
+			}
+
+			for _, refund := range refunds.Items {
+				for _, status := range merchantOffboardingRefundStatuses {
+					if refund.Status == status {
+						count++
+					}
+				}
+			}
+		}
+
+		if int64(len(orders.Item.GetItems())) < ordersReq.Limit {
+			break
+		}
+
+		ordersReq.Offset += ordersReq.Limit
+	}
+
+	return count, nil
+}
+
+// unpublishKeyProducts unpublishes the enabled key products, the list is read completely before the changes
+func (h *MerchantOffboardingRoute) unpublishKeyProducts(ctx context.Context, merchantId string) ([]string, error) {
+	listReq := &grpc.ListKeyProductsRequest{MerchantId: merchantId, Limit: merchantOffboardingLimit}
+	ids := make([]string, 0)
+
+	for {
+		res, err := h.dispatch.Services.Billing.GetKeyProducts(ctx, listReq)
+
+		if err != nil {
+			common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "GetKeyProducts", listReq)
+			return nil, err
+		}
+
+		if res.Status != pkg.ResponseStatusOk {
+			return nil, fmt.Errorf(merchantOffboardingStatusError, "GetKeyProducts", res.Status, res.Message)
+		}
+
+		for _, product := range res.Products {
+			if product.Enabled {
+				ids = append(ids, product.Id)
+			}
+		}
+
+		if int64(len(res.Products)) < listReq.Limit {
+			break
+		}
+
+		listReq.Offset += listReq.Limit
+	}
+
+	for _, id := range ids {
+		req := &grpc.UnPublishKeyProductRequest{KeyProductId: id, MerchantId: merchantId}
+		res, err := h.dispatch.Services.Billing.UnPublishKeyProduct(ctx, req)
+
+		if err != nil {
+			common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "UnPublishKeyProduct", req)
+			return nil, err
+		}
+
+		if res.Status != pkg.ResponseStatusOk {
+			return nil, fmt.Errorf(merchantOffboardingStatusError, "UnPublishKeyProduct", res.Status, res.Message)
+		}
+	}
+
+	return ids, nil
+}
+
+// deactivateProjects deletes the projects of the merchant which aren't deleted yet
+func (h *MerchantOffboardingRoute) deactivateProjects(ctx context.Context, merchantId string) ([]string, error) {
+	listReq := &grpc.ListProjectsRequest{Merchant: []string{merchantId}, Limit: merchantOffboardingLimit}
+	ids := make([]string, 0)
+
+	for {
+		res, err := h.dispatch.Services.Billing.ListProjects(ctx, listReq)
+
+		if err != nil {
+			common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "ListProjects", listReq)
+			return nil, err
+		}
+
+		for _, project := range res.Items {
+			if project.Status != pkg.ProjectStatusDeleted {
+				ids = append(ids, project.Id)
+			}
+		}
+
+		if int32(len(res.Items)) < listReq.Limit {
+			break
+		}
+
+		listReq.Offset += listReq.Limit
+	}
+
+	for _, id := range ids {
+		req := &grpc.GetProjectRequest{ProjectId: id, MerchantId: merchantId}
+		res, err := h.dispatch.Services.Billing.DeleteProject(ctx, req)
+
+		if err != nil {
+			common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "DeleteProject", req)
+			return nil, err
+		}
+
+		if res.Status != pkg.ResponseStatusOk {
+			return nil, fmt.Errorf(merchantOffboardingStatusError, "DeleteProject", res.Status, res.Message)
+		}
+	}
+
+	return ids, nil
+}
+
+// revokeUsers deletes the roles of the merchant users and the pending invites. The owner role is kept
+// because the closed account still belongs to its owner.
+func (h *MerchantOffboardingRoute) revokeUsers(ctx context.Context, merchantId string) ([]string, error) {
+	usersReq := &grpc.GetMerchantUsersRequest{MerchantId: merchantId}
+	users, err := h.dispatch.Services.Billing.GetMerchantUsers(ctx, usersReq)
+
+	if err != nil {
+		common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "GetMerchantUsers", usersReq)
+		return nil, err
+	}
+
+	if users.Status != pkg.ResponseStatusOk {
+		return nil, fmt.Errorf(merchantOffboardingStatusError, "GetMerchantUsers", users.Status, users.Message)
+	}
+
+	ids := make([]string, 0, len(users.Users))
+
+	for _, role := range users.Users {
+		if role.Role == pkg.RoleMerchantOwner {
+			continue
+		}
+
+		req := &grpc.MerchantRoleRequest{MerchantId: merchantId, RoleId: role.Id}
+		res, err := h.dispatch.Services.Billing.DeleteMerchantUser(ctx, req)
+
+		if err != nil {
+			common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "DeleteMerchantUser", req)
+			return nil, err
+		}
+
+		if res.Status != pkg.ResponseStatusOk {
+			return nil, fmt.Errorf(merchantOffboardingStatusError, "DeleteMerchantUser", res.Status, res.Message)
+		}
+
+		ids = append(ids, role.Id)
+	}
+
+	return ids, nil
+}
+
+// archiveAgreements copies the agreement document of the merchant to the archive of the agreements bucket
+func (h *MerchantOffboardingRoute) archiveAgreements(ctx context.Context, merchant *billing.Merchant) ([]string, error) {
+	files := make([]string, 0)
+
+	if merchant.S3AgreementName == "" {
+		return files, nil
+	}
+
+	content, err := h.agreements.Read(ctx, merchant.S3AgreementName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	fileName := fmt.Sprintf(merchantOffboardingArchiveFileMask, merchant.Id, filepath.Base(merchant.S3AgreementName))
+
+	if err = h.agreements.Upload(ctx, fileName, bytes.NewReader(content)); err != nil {
+		return nil, err
+	}
+
+	return append(files, fileName), nil
+}
+
+func (h *MerchantOffboardingRoute) closeAccount(ctx context.Context, merchantId, userId, reason string) error {
+	req := &grpc.MerchantChangeStatusRequest{
+		MerchantId: merchantId,
+		Status:     pkg.MerchantStatusDeleted,
+		Message:    reason,
+		UserId:     userId,
+	}
+	res, err := h.dispatch.Services.Billing.ChangeMerchantStatus(ctx, req)
+
+	if err != nil {
+		common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "ChangeMerchantStatus", req)
+		return err
+	}
+
+	if res.Status != pkg.ResponseStatusOk {
+		return fmt.Errorf(merchantOffboardingStatusError, "ChangeMerchantStatus", res.Status, res.Message)
+	}
+
+	return nil
+}
+
+func (h *MerchantOffboardingRoute) getMerchant(ctx echo.Context, merchantId string) (*billing.Merchant, error) {
+	req := &grpc.GetMerchantByRequest{MerchantId: merchantId}
+	res, err := h.dispatch.Services.Billing.GetMerchantBy(ctx.Request().Context(), req)
+
+	if err != nil {
+		return nil, h.dispatch.SrvCallHandler(req, err, pkg.ServiceName, "GetMerchantBy")
+	}
+
+	if res.Status != pkg.ResponseStatusOk {
+		return nil, echo.NewHTTPError(int(res.Status), res.Message)
+	}
+
+	return res.Item, nil
+}
+
+func (h *MerchantOffboardingRoute) storeErrorHandler(err error, merchantId string) *echo.HTTPError {
+	switch err {
+	case offboarding.ErrorWorkflowNotFound:
+		return echo.NewHTTPError(http.StatusNotFound, common.ErrorMessageMerchantOffboardingNotFound)
+	case offboarding.ErrorWorkflowRunning:
+		return echo.NewHTTPError(http.StatusConflict, common.ErrorMessageMerchantOffboardingInProgress)
+	}
+
+	h.L().Error(
+		"merchant offboarding storage call failed",
+		logger.PairArgs("err", err.Error(), "merchant_id", merchantId),
+	)
+
+	return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessageMerchantOffboardingStorageFailed)
+}
@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/mock"
+	"github.com/paysuper/paysuper-management-api/internal/offboarding"
+	"github.com/paysuper/paysuper-management-api/internal/test"
+	mock2 "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"testing"
+)
+
+const (
+	merchantOffboardingTestMerchantId    = "5dc3f6c5ad8b8c0001b1e2b1"
+	merchantOffboardingTestAgreementName = "agreement_5dc3f6c5ad8b8c0001b1e2b1.pdf"
+)
+
+type MerchantOffboardingTestSuite struct {
+	suite.Suite
+	router     *MerchantOffboardingRoute
+	caller     *test.EchoReqResCaller
+	billing    *mocks.BillingService
+	files      map[string][]byte
+	agreements map[string][]byte
+}
+
+func Test_MerchantOffboarding(t *testing.T) {
+	suite.Run(t, new(MerchantOffboardingTestSuite))
+}
+
+func (suite *MerchantOffboardingTestSuite) SetupTest() {
+	user := &common.AuthUser{
+		Id:    "ffffffffffffffffffffffff",
+		Email: "test@unit.test",
+	}
+
+	suite.files = make(map[string][]byte)
+	suite.agreements = map[string][]byte{merchantOffboardingTestAgreementName: []byte("%PDF")}
+	suite.billing = suite.newBillingService(0)
+
+	var e error
+	settings := test.DefaultSettings()
+	srv := common.Services{
+		Billing: suite.billing,
+	}
+	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
+		mw.Pre(test.PreAuthUserMiddleware(user))
+		suite.router = NewMerchantOffboardingRoute(
+			set.HandlerSet,
+			mock.NewAwsManagerFilesMock(suite.files),
+			mock.NewAwsManagerFilesMock(suite.agreements),
+			set.GlobalConfig,
+		)
+		return common.Handlers{
+			suite.router,
+		}
+	})
+	if e != nil {
+		panic(e)
+	}
+}
+
+func (suite *MerchantOffboardingTestSuite) TearDownTest() {}
+
+// newBillingService returns the billing mock of the merchant with the balance and without pending payments
+func (suite *MerchantOffboardingTestSuite) newBillingService(balance float64) *mocks.BillingService {
+	billingService := &mocks.BillingService{}
+	billingService.On("GetMerchantBy", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetMerchantResponse{
+			Status: pkg.ResponseStatusOk,
+			Item:   &billing.Merchant{Id: merchantOffboardingTestMerchantId, S3AgreementName: merchantOffboardingTestAgreementName},
+		}, nil)
+	billingService.On("GetMerchantBalance", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetMerchantBalanceResponse{
+			Status: pkg.ResponseStatusOk,
+			Item:   &billing.MerchantBalance{MerchantId: merchantOffboardingTestMerchantId, Currency: "USD", Total: balance},
+		}, nil)
+	billingService.On("ListRoyaltyReports", mock2.Anything, mock2.Anything).
+		Return(&grpc.ListRoyaltyReportsResponse{Status: pkg.ResponseStatusOk, Data: &grpc.RoyaltyReportsPaginate{}}, nil)
+	billingService.On("GetPayoutDocuments", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetPayoutDocumentsResponse{
+			Status: pkg.ResponseStatusOk,
+			Data: &grpc.PayoutDocumentsPaginate{
+				Count: 1,
+				Items: []*billing.PayoutDocument{{Id: "payout_1", Status: "paid"}},
+			},
+		}, nil)
+	billingService.On("FindAllOrdersPublic", mock2.Anything, mock2.Anything).
+		Return(&grpc.ListOrdersPublicResponse{Status: pkg.ResponseStatusOk, Item: &grpc.ListOrdersPublicResponseItem{}}, nil)
+	billingService.On("GetKeyProducts", mock2.Anything, mock2.Anything).
+		Return(&grpc.ListKeyProductsResponse{
+			Status:   pkg.ResponseStatusOk,
+			Products: []*grpc.KeyProduct{{Id: "key_product_1", Enabled: true}, {Id: "key_product_2"}},
+		}, nil)
+	billingService.On("UnPublishKeyProduct", mock2.Anything, mock2.Anything).
+		Return(&grpc.KeyProductResponse{Status: pkg.ResponseStatusOk}, nil)
+	billingService.On("ListProjects", mock2.Anything, mock2.Anything).
+		Return(&grpc.ListProjectsResponse{
+			Count: 2,
+			Items: []*billing.Project{{Id: "project_1"}, {Id: "project_2", Status: pkg.ProjectStatusDeleted}},
+		}, nil)
+	billingService.On("DeleteProject", mock2.Anything, mock2.Anything).
+		Return(&grpc.ChangeProjectResponse{Status: pkg.ResponseStatusOk}, nil)
+	billingService.On("GetMerchantUsers", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetMerchantUsersResponse{
+			Status: pkg.ResponseStatusOk,
+			Users:  []*billing.UserRole{{Id: "role_1", Role: pkg.RoleMerchantOwner}, {Id: "role_2", Role: "merchant_developer"}},
+		}, nil)
+	billingService.On("DeleteMerchantUser", mock2.Anything, mock2.Anything).
+		Return(&grpc.EmptyResponseWithStatus{Status: pkg.ResponseStatusOk}, nil)
+	billingService.On("ChangeMerchantStatus", mock2.Anything, mock2.Anything).
+		Return(&grpc.ChangeMerchantStatusResponse{Status: pkg.ResponseStatusOk}, nil)
+
+	return billingService
+}
+
+// runOffboarding starts the offboarding run and returns the workflow saved by the run when it's finished
+func (suite *MerchantOffboardingTestSuite) runOffboarding() *offboarding.Workflow {
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Params(":"+common.RequestParameterMerchantId, merchantOffboardingTestMerchantId).
+		Path(common.SystemUserGroupPath + merchantsIdOffboardingPath).
+		Init(test.ReqInitJSON()).
+		BodyString(`{"reason": "closed by the merchant request"}`).
+		Exec(suite.T())
+
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), http.StatusAccepted, res.Code)
+
+	w := &offboarding.Workflow{}
+	require.NoError(suite.T(), json.Unmarshal(res.Body.Bytes(), w))
+	require.Equal(suite.T(), offboarding.StatusInProgress, w.Status)
+	require.NotEmpty(suite.T(), w.RunId)
+
+	suite.router.Wait()
+
+	return suite.getOffboarding()
+}
+
+func (suite *MerchantOffboardingTestSuite) getOffboarding() *offboarding.Workflow {
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterMerchantId, merchantOffboardingTestMerchantId).
+		Path(common.SystemUserGroupPath + merchantsIdOffboardingPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), http.StatusOK, res.Code)
+
+	w := &offboarding.Workflow{}
+	require.NoError(suite.T(), json.Unmarshal(res.Body.Bytes(), w))
+
+	return w
+}
+
+func (suite *MerchantOffboardingTestSuite) TestMerchantOffboarding_Ok() {
+	shouldBe := require.New(suite.T())
+
+	w := suite.runOffboarding()
+	shouldBe.Equal(offboarding.StatusCompleted, w.Status)
+	shouldBe.Equal("closed by the merchant request", w.Reason)
+	shouldBe.Len(w.Steps, len(offboarding.Steps))
+	shouldBe.Equal([]string{"key_product_1"}, w.Steps[1].Items)
+	shouldBe.Equal([]string{"project_1"}, w.Steps[2].Items)
+	shouldBe.Equal([]string{"role_2"}, w.Steps[3].Items)
+	shouldBe.Len(w.Steps[4].Items, 1)
+
+	_, ok := suite.agreements[w.Steps[4].Items[0]]
+	shouldBe.True(ok)
+
+	shouldBe.Nil(w.LeaseUntil)
+
+	suite.billing.AssertNumberOfCalls(suite.T(), "UnPublishKeyProduct", 1)
+	suite.billing.AssertNumberOfCalls(suite.T(), "DeleteProject", 1)
+	suite.billing.AssertNumberOfCalls(suite.T(), "DeleteMerchantUser", 1)
+	suite.billing.AssertCalled(suite.T(), "ChangeMerchantStatus", mock2.Anything,
+		mock2.MatchedBy(func(req *grpc.MerchantChangeStatusRequest) bool {
+			return req.MerchantId == merchantOffboardingTestMerchantId && req.Status == pkg.MerchantStatusDeleted
+		}))
+	suite.billing.AssertCalled(suite.T(), "FindAllOrdersPublic", mock2.Anything,
+		mock2.MatchedBy(func(req *grpc.ListOrdersRequest) bool {
+			return len(req.Status) == len(merchantOffboardingRefundableOrderStatuses) && req.Status[0] == "processed"
+		}))
+
+	// the completed offboarding isn't started again
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Params(":"+common.RequestParameterMerchantId, merchantOffboardingTestMerchantId).
+		Path(common.SystemUserGroupPath + merchantsIdOffboardingPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+	suite.billing.AssertNumberOfCalls(suite.T(), "ChangeMerchantStatus", 1)
+}
+
+func (suite *MerchantOffboardingTestSuite) TestMerchantOffboarding_Blocked() {
+	shouldBe := require.New(suite.T())
+
+	billingService := suite.newBillingService(10.5)
+	suite.router.dispatch.Services.Billing = billingService
+
+	w := suite.runOffboarding()
+	shouldBe.Equal(offboarding.StatusBlocked, w.Status)
+	shouldBe.Len(w.Blockers, 1)
+	shouldBe.Equal(offboarding.StepStatusPending, w.Steps[0].Status)
+	billingService.AssertNotCalled(suite.T(), "GetKeyProducts", mock2.Anything, mock2.Anything)
+
+	suite.router.dispatch.Services.Billing = suite.billing
+
+	w = suite.runOffboarding()
+	shouldBe.Equal(offboarding.StatusCompleted, w.Status)
+	shouldBe.Empty(w.Blockers)
+	shouldBe.Equal(2, w.Steps[0].Attempts)
+}
+
+func (suite *MerchantOffboardingTestSuite) TestMerchantOffboarding_Resume() {
+	shouldBe := require.New(suite.T())
+
+	// the first project deletion fails, the rest of the calls are the same as in the successful offboarding
+	billingService := &mocks.BillingService{}
+	billingService.On("DeleteProject", mock2.Anything, mock2.Anything).Return(nil, errors.New("error")).Once()
+
+	for _, call := range suite.billing.ExpectedCalls {
+		billingService.On(call.Method, call.Arguments...).Return(call.ReturnArguments...)
+	}
+
+	suite.router.dispatch.Services.Billing = billingService
+
+	w := suite.runOffboarding()
+	shouldBe.Equal(offboarding.StatusFailed, w.Status)
+	shouldBe.Equal(offboarding.StepStatusFailed, w.Steps[2].Status)
+	shouldBe.NotEmpty(w.Steps[2].Error)
+	shouldBe.Equal(offboarding.StepStatusCompleted, w.Steps[1].Status)
+
+	w = suite.runOffboarding()
+	shouldBe.Equal(offboarding.StatusCompleted, w.Status)
+	shouldBe.Equal(2, w.Steps[0].Attempts)
+	shouldBe.Equal(2, w.Steps[2].Attempts)
+	shouldBe.Equal(1, w.Steps[1].Attempts)
+	billingService.AssertNumberOfCalls(suite.T(), "GetMerchantBalance", 2)
+	billingService.AssertNumberOfCalls(suite.T(), "UnPublishKeyProduct", 1)
+	billingService.AssertNumberOfCalls(suite.T(), "DeleteProject", 2)
+}
+
+func (suite *MerchantOffboardingTestSuite) TestMerchantOffboarding_InProgress() {
+	_, err := suite.router.store.Start(context.Background(), merchantOffboardingTestMerchantId, "ffffffffffffffffffffffff", "")
+	require.NoError(suite.T(), err)
+
+	_, err = suite.caller.Builder().
+		Method(http.MethodPost).
+		Params(":"+common.RequestParameterMerchantId, merchantOffboardingTestMerchantId).
+		Path(common.SystemUserGroupPath + merchantsIdOffboardingPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), http.StatusConflict, httpErr.Code)
+	require.Equal(suite.T(), common.ErrorMessageMerchantOffboardingInProgress, httpErr.Message)
+	suite.billing.AssertNotCalled(suite.T(), "GetMerchantBalance", mock2.Anything, mock2.Anything)
+}
+
+func (suite *MerchantOffboardingTestSuite) TestMerchantOffboarding_NotFound() {
+	_, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterMerchantId, merchantOffboardingTestMerchantId).
+		Path(common.SystemUserGroupPath + merchantsIdOffboardingPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), http.StatusNotFound, httpErr.Code)
+	require.Equal(suite.T(), common.ErrorMessageMerchantOffboardingNotFound, httpErr.Message)
+}
+
+func (suite *MerchantOffboardingTestSuite) TestMerchantOffboarding_BillingServer_Error() {
+	billingService := &mocks.BillingService{}
+	billingService.On("GetMerchantBy", mock2.Anything, mock2.Anything).Return(nil, errors.New("error"))
+	suite.router.dispatch.Services.Billing = billingService
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Params(":"+common.RequestParameterMerchantId, merchantOffboardingTestMerchantId).
+		Path(common.SystemUserGroupPath + merchantsIdOffboardingPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), http.StatusInternalServerError, httpErr.Code)
+	require.Empty(suite.T(), suite.files)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/paysuper/paysuper-billing-server/pkg"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
 	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/invite"
 	"net/http"
 )
 
@@ -18,18 +19,29 @@ const (
 	merchantUsersRole    = "/merchants/users/roles/:role_id"
 )
 
+const (
+	merchantInvites       = "/merchants/invites"
+	merchantInvitesBulk   = "/merchants/invites/bulk"
+	merchantInvitesRole   = "/merchants/invites/:role_id"
+	merchantIdInvites     = "/merchants/:merchant_id/invites"
+	merchantIdInvitesBulk = "/merchants/:merchant_id/invites/bulk"
+	merchantIdInvitesRole = "/merchants/:merchant_id/invites/:role_id"
+)
+
 type MerchantUsersRoute struct {
 	dispatch common.HandlerSet
 	cfg      common.Config
+	invites  *invite.Store
 	provider.LMT
 }
 
-func NewMerchantUsersRoute(set common.HandlerSet, cfg *common.Config) *MerchantUsersRoute {
+func NewMerchantUsersRoute(set common.HandlerSet, invites *invite.Store, cfg *common.Config) *MerchantUsersRoute {
 	set.AwareSet.Logger = set.AwareSet.Logger.WithFields(logger.Fields{"router": "MerchantUsersRoute"})
 	return &MerchantUsersRoute{
 		dispatch: set,
 		LMT:      &set.AwareSet,
 		cfg:      *cfg,
+		invites:  invites,
 	}
 }
 
@@ -41,6 +53,13 @@ func (h *MerchantUsersRoute) Route(groups *common.Groups) {
 	groups.AuthUser.GET(merchantListRoles, h.listRoles)
 	groups.AuthUser.DELETE(merchantUsersRole, h.deleteUser)
 	groups.AuthUser.GET(merchantUsersRole, h.getUser)
+
+	groups.AuthUser.GET(merchantInvites, h.listInvites)
+	groups.AuthUser.POST(merchantInvitesBulk, h.sendBulkInvites)
+	groups.AuthUser.DELETE(merchantInvitesRole, h.revokeInvite)
+	groups.SystemUser.GET(merchantIdInvites, h.listInvites)
+	groups.SystemUser.POST(merchantIdInvitesBulk, h.sendBulkInvites)
+	groups.SystemUser.DELETE(merchantIdInvitesRole, h.revokeInvite)
 }
 
 func (h *MerchantUsersRoute) changeRole(ctx echo.Context) error {
@@ -100,6 +119,8 @@ func (h *MerchantUsersRoute) sendInvite(ctx echo.Context) error {
 		return echo.NewHTTPError(int(res.Status), res.Message)
 	}
 
+	recordInviteSent(ctx.Request().Context(), h, h.invites, res.Role.GetId())
+
 	return ctx.JSON(http.StatusOK, res)
 }
 
@@ -120,6 +141,8 @@ func (h *MerchantUsersRoute) resendInvite(ctx echo.Context) error {
 		return echo.NewHTTPError(int(res.Status), res.Message)
 	}
 
+	recordInviteSent(ctx.Request().Context(), h, h.invites, req.RoleId)
+
 	return ctx.JSON(http.StatusOK, res)
 }
 
@@ -173,3 +196,99 @@ func (h *MerchantUsersRoute) getUser(ctx echo.Context) error {
 
 	return ctx.JSON(http.StatusOK, res)
 }
+
+func (h *MerchantUsersRoute) listInvites(ctx echo.Context) error {
+	req := &grpc.GetMerchantUsersRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	res, err := h.dispatch.Services.Billing.GetMerchantUsers(ctx.Request().Context(), req)
+
+	if err != nil {
+		return h.dispatch.SrvCallHandler(req, err, pkg.ServiceName, "GetMerchantUsers")
+	}
+
+	if res.Status != http.StatusOK {
+		return echo.NewHTTPError(int(res.Status), res.Message)
+	}
+
+	invites, err := getPendingInvites(ctx.Request().Context(), h.invites, res.Users, h.cfg.InviteLifetime)
+
+	if err != nil {
+		h.L().Error("invites storage call failed", logger.PairArgs("err", err.Error()))
+		return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessageInviteStorageFailed)
+	}
+
+	return ctx.JSON(http.StatusOK, invites)
+}
+
+func (h *MerchantUsersRoute) revokeInvite(ctx echo.Context) error {
+	req := &grpc.MerchantRoleRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	usersReq := &grpc.GetMerchantUsersRequest{MerchantId: req.MerchantId}
+	usersRes, err := h.dispatch.Services.Billing.GetMerchantUsers(ctx.Request().Context(), usersReq)
+
+	if err != nil {
+		return h.dispatch.SrvCallHandler(usersReq, err, pkg.ServiceName, "GetMerchantUsers")
+	}
+
+	if usersRes.Status != http.StatusOK {
+		return echo.NewHTTPError(int(usersRes.Status), usersRes.Message)
+	}
+
+	if findPendingInvite(usersRes.Users, req.RoleId) == nil {
+		return echo.NewHTTPError(http.StatusNotFound, common.ErrorMessageInviteNotFound)
+	}
+
+	res, err := h.dispatch.Services.Billing.DeleteMerchantUser(ctx.Request().Context(), req)
+
+	if err != nil {
+		return h.dispatch.SrvCallHandler(req, err, pkg.ServiceName, "DeleteMerchantUser")
+	}
+
+	if res.Status != pkg.ResponseStatusOk {
+		return echo.NewHTTPError(int(res.Status), res.Message)
+	}
+
+	revokeInviteSending(ctx.Request().Context(), h, h.invites, req.RoleId)
+
+	return ctx.NoContent(http.StatusOK)
+}
+
+func (h *MerchantUsersRoute) sendBulkInvites(ctx echo.Context) error {
+	req := &grpc.GetMerchantUsersRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	rows, hErr := readInvitesFile(ctx)
+
+	if hErr != nil {
+		return hErr
+	}
+
+	result := sendBulkInvites(h.dispatch, rows, func(email, role string) (int32, *grpc.ResponseErrorMessage, error) {
+		inviteReq := &grpc.InviteUserMerchantRequest{MerchantId: req.MerchantId, Email: email, Role: role}
+		res, err := h.dispatch.Services.Billing.InviteUserMerchant(ctx.Request().Context(), inviteReq)
+
+		if err != nil {
+			common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "InviteUserMerchant", inviteReq)
+			return 0, nil, err
+		}
+
+		if res.Status == pkg.ResponseStatusOk {
+			recordInviteSent(ctx.Request().Context(), h, h.invites, res.Role.GetId())
+		}
+
+		return res.Status, res.Message, nil
+	})
+
+	return ctx.JSON(http.StatusOK, result)
+}
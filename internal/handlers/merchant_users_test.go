@@ -1,25 +1,36 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"github.com/globalsign/mgo/bson"
+	"github.com/golang/protobuf/ptypes"
 	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg"
 	"github.com/paysuper/paysuper-billing-server/pkg/mocks"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
 	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/invite"
+	"github.com/paysuper/paysuper-management-api/internal/mock"
 	"github.com/paysuper/paysuper-management-api/internal/test"
 	mock2 "github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"mime/multipart"
 	"net/http"
 	"testing"
+	"time"
 )
 
 type MerchantUsersTestSuite struct {
 	suite.Suite
-	router *MerchantUsersRoute
-	caller *test.EchoReqResCaller
+	router  *MerchantUsersRoute
+	caller  *test.EchoReqResCaller
+	invites *invite.Store
+	bucket  *mock.BucketMock
 }
 
 func Test_MerchantUsers(t *testing.T) {
@@ -39,7 +50,9 @@ func (suite *MerchantUsersTestSuite) SetupTest() {
 	}
 	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
 		mw.Pre(test.PreAuthUserMiddleware(user))
-		suite.router = NewMerchantUsersRoute(set.HandlerSet, set.GlobalConfig)
+		suite.bucket = &mock.BucketMock{}
+		suite.invites = invite.NewStore(mock.NewAwsManagerFilesMock(map[string][]byte{}), suite.bucket)
+		suite.router = NewMerchantUsersRoute(set.HandlerSet, suite.invites, set.GlobalConfig)
 		return common.Handlers{
 			suite.router,
 		}
@@ -179,3 +192,263 @@ func (suite *MerchantUsersTestSuite) TestMerchantChangeRole_Ok() {
 	shouldBe.Equal(http.StatusOK, res.Code)
 	shouldBe.Empty(res.Body.String())
 }
+
+func newInvitesFileBody(t *testing.T, content string) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile(common.RequestParameterFile, "invites.csv")
+	require.NoError(t, err)
+	_, err = part.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	return body, writer.FormDataContentType()
+}
+
+// newInvitedUserRoles returns the accepted role and the invites sent now and a month ago.
+// The role of the month old invite was updated just now, it doesn't extend the invite lifetime.
+func newInvitedUserRoles(t *testing.T, store *invite.Store) []*billing.UserRole {
+	roles := []*billing.UserRole{
+		{Id: bson.NewObjectId().Hex(), Email: "accepted@unit.test", Status: pkg.UserRoleStatusAccepted},
+		{Id: bson.NewObjectId().Hex(), Email: "fresh@unit.test", Status: pkg.UserRoleStatusInvited},
+		{Id: bson.NewObjectId().Hex(), Email: "expired@unit.test", Status: pkg.UserRoleStatusInvited, UpdatedAt: ptypes.TimestampNow()},
+	}
+
+	_, err := store.Sent(context.Background(), roles[1].Id, time.Now())
+	require.NoError(t, err)
+	_, err = store.Sent(context.Background(), roles[2].Id, time.Now().Add(-30*24*time.Hour))
+	require.NoError(t, err)
+
+	return roles
+}
+
+func (suite *MerchantUsersTestSuite) TestMerchantUsers_ListInvites_Ok() {
+	shouldBe := require.New(suite.T())
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("GetMerchantUsers", mock2.Anything, mock2.Anything).Return(&grpc.GetMerchantUsersResponse{
+		Status: http.StatusOK,
+		Users:  newInvitedUserRoles(suite.T(), suite.invites),
+	}, nil)
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Path(common.AuthUserGroupPath + merchantInvites).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+
+	var invites []*PendingInvite
+	shouldBe.NoError(json.Unmarshal(res.Body.Bytes(), &invites))
+	shouldBe.Len(invites, 2)
+	shouldBe.Equal("expired@unit.test", invites[0].Email)
+	shouldBe.True(invites[0].Expired)
+	shouldBe.Equal("fresh@unit.test", invites[1].Email)
+	shouldBe.False(invites[1].Expired)
+	shouldBe.True(invites[1].ExpiresAt.After(time.Now()))
+}
+
+func (suite *MerchantUsersTestSuite) TestMerchantUsers_ListInvites_NotRecorded_Ok() {
+	shouldBe := require.New(suite.T())
+	createdAt, err := ptypes.TimestampProto(time.Now().Add(-40 * 24 * time.Hour))
+	shouldBe.NoError(err)
+
+	// the invites sent before the sendings were recorded expire in the lifetime after the role creation
+	roles := append(
+		newInvitedUserRoles(suite.T(), suite.invites),
+		&billing.UserRole{
+			Id:        bson.NewObjectId().Hex(),
+			Email:     "legacy@unit.test",
+			Status:    pkg.UserRoleStatusInvited,
+			CreatedAt: createdAt,
+			UpdatedAt: ptypes.TimestampNow(),
+		},
+		&billing.UserRole{
+			Id:     bson.NewObjectId().Hex(),
+			Email:  "unknown@unit.test",
+			Status: pkg.UserRoleStatusInvited,
+		},
+	)
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("GetMerchantUsers", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetMerchantUsersResponse{Status: http.StatusOK, Users: roles}, nil)
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Path(common.AuthUserGroupPath + merchantInvites).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+
+	var invites []*PendingInvite
+	shouldBe.NoError(json.Unmarshal(res.Body.Bytes(), &invites))
+	shouldBe.Len(invites, 4)
+	shouldBe.Equal("legacy@unit.test", invites[0].Email)
+	shouldBe.NotNil(invites[0].ExpiresAt)
+	shouldBe.True(invites[0].Expired)
+	shouldBe.Equal("unknown@unit.test", invites[3].Email)
+	shouldBe.Nil(invites[3].ExpiresAt)
+	shouldBe.False(invites[3].Expired)
+}
+
+func (suite *MerchantUsersTestSuite) TestMerchantUsers_ListInvites_System_Ok() {
+	shouldBe := require.New(suite.T())
+	merchantId := bson.NewObjectId().Hex()
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("GetMerchantUsers", mock2.Anything, mock2.MatchedBy(func(req *grpc.GetMerchantUsersRequest) bool {
+		return req.MerchantId == merchantId
+	})).Return(&grpc.GetMerchantUsersResponse{Status: http.StatusOK, Users: newInvitedUserRoles(suite.T(), suite.invites)}, nil)
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterMerchantId, merchantId).
+		Path(common.SystemUserGroupPath + merchantIdInvites).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+}
+
+func (suite *MerchantUsersTestSuite) TestMerchantUsers_ListInvites_InternalError() {
+	shouldBe := require.New(suite.T())
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("GetMerchantUsers", mock2.Anything, mock2.Anything).Return(nil, errors.New("some error"))
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Path(common.AuthUserGroupPath + merchantInvites).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.Error(err)
+	hErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusInternalServerError, hErr.Code)
+}
+
+func (suite *MerchantUsersTestSuite) TestMerchantUsers_RevokeInvite_Ok() {
+	shouldBe := require.New(suite.T())
+	roles := newInvitedUserRoles(suite.T(), suite.invites)
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("GetMerchantUsers", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetMerchantUsersResponse{Status: http.StatusOK, Users: roles}, nil)
+	billingService.On("DeleteMerchantUser", mock2.Anything, mock2.Anything).
+		Return(&grpc.EmptyResponseWithStatus{Status: pkg.ResponseStatusOk}, nil)
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodDelete).
+		Params(":"+common.RequestRoleId, roles[1].Id).
+		Path(common.AuthUserGroupPath + merchantInvitesRole).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+	billingService.AssertCalled(suite.T(), "DeleteMerchantUser", mock2.Anything, mock2.MatchedBy(func(req *grpc.MerchantRoleRequest) bool {
+		return req.RoleId == roles[1].Id
+	}))
+	shouldBe.Equal([]string{"invites/" + roles[1].Id + ".json"}, suite.bucket.Deleted)
+}
+
+func (suite *MerchantUsersTestSuite) TestMerchantUsers_RevokeInvite_Accepted_Error() {
+	shouldBe := require.New(suite.T())
+	roles := newInvitedUserRoles(suite.T(), suite.invites)
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("GetMerchantUsers", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetMerchantUsersResponse{Status: http.StatusOK, Users: roles}, nil)
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodDelete).
+		Params(":"+common.RequestRoleId, roles[0].Id).
+		Path(common.AuthUserGroupPath + merchantInvitesRole).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.Error(err)
+	hErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusNotFound, hErr.Code)
+	shouldBe.Equal(common.ErrorMessageInviteNotFound, hErr.Message)
+	billingService.AssertNotCalled(suite.T(), "DeleteMerchantUser", mock2.Anything, mock2.Anything)
+	shouldBe.Empty(suite.bucket.Deleted)
+}
+
+func (suite *MerchantUsersTestSuite) TestMerchantUsers_SendBulkInvites_Ok() {
+	shouldBe := require.New(suite.T())
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("InviteUserMerchant", mock2.Anything, mock2.MatchedBy(func(req *grpc.InviteUserMerchantRequest) bool {
+		return req.Email == "first@unit.test"
+	})).Return(&grpc.InviteUserMerchantResponse{
+		Status: pkg.ResponseStatusOk,
+		Role:   &billing.UserRole{Id: "5dc3f6c5ad8b8c0001b1e2e1"},
+	}, nil)
+	billingService.On("InviteUserMerchant", mock2.Anything, mock2.MatchedBy(func(req *grpc.InviteUserMerchantRequest) bool {
+		return req.Email == "second@unit.test"
+	})).Return(&grpc.InviteUserMerchantResponse{
+		Status:  pkg.ResponseStatusBadData,
+		Message: &grpc.ResponseErrorMessage{Message: "some error"},
+	}, nil)
+
+	body, contentType := newInvitesFileBody(suite.T(), "email,role\nfirst@unit.test,merchant_developer\nsecond@unit.test,merchant_accounting\nnot_email,merchant_developer\n")
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.AuthUserGroupPath + merchantInvitesBulk).
+		Init(func(request *http.Request, middleware test.Middleware) {
+			request.Header.Set(echo.HeaderContentType, contentType)
+		}).
+		Body(body).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+
+	result := &InviteBulkResult{}
+	shouldBe.NoError(json.Unmarshal(res.Body.Bytes(), result))
+	shouldBe.Equal(3, result.Total)
+	shouldBe.Equal(1, result.Invited)
+	shouldBe.Equal(2, result.Failed)
+	shouldBe.Equal(inviteBulkRowStatusOk, result.Rows[0].Status)
+	shouldBe.Equal(inviteBulkRowStatusFailed, result.Rows[1].Status)
+	shouldBe.Equal("some error", result.Rows[1].Message.Message)
+	shouldBe.Equal(inviteBulkRowStatusFailed, result.Rows[2].Status)
+	shouldBe.Equal(common.ErrorEmailFieldIncorrect.Code, result.Rows[2].Message.Code)
+	billingService.AssertNumberOfCalls(suite.T(), "InviteUserMerchant", 2)
+
+	sending, err := suite.invites.Get(context.Background(), "5dc3f6c5ad8b8c0001b1e2e1")
+	shouldBe.NoError(err)
+	shouldBe.NotNil(sending)
+	shouldBe.False(sending.IsExpired(suite.router.cfg.InviteLifetime, time.Now()))
+}
+
+func (suite *MerchantUsersTestSuite) TestMerchantUsers_SendBulkInvites_InvalidFile_Error() {
+	shouldBe := require.New(suite.T())
+
+	body, contentType := newInvitesFileBody(suite.T(), "first@unit.test;merchant_developer\n")
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.AuthUserGroupPath + merchantInvitesBulk).
+		Init(func(request *http.Request, middleware test.Middleware) {
+			request.Header.Set(echo.HeaderContentType, contentType)
+		}).
+		Body(body).
+		Exec(suite.T())
+
+	shouldBe.Error(err)
+	hErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusBadRequest, hErr.Code)
+	shouldBe.Equal(common.ErrorMessageInvitesFileInvalid, hErr.Message)
+}
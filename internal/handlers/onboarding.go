@@ -11,7 +11,10 @@ import (
 	"github.com/paysuper/paysuper-billing-server/pkg"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/banking"
+	"github.com/paysuper/paysuper-management-api/internal/branding"
 	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/pdf"
 	"mime/multipart"
 	"net/http"
 	"os"
@@ -35,6 +38,8 @@ const (
 	merchantsIdAgreementPath           = "/merchants/:merchant_id/agreement"
 	merchantsAgreementDocumentPath     = "/merchants/agreement/document"
 	merchantsIdAgreementDocumentPath   = "/merchants/:merchant_id/agreement/document"
+	merchantsAgreementHtmlPath         = "/merchants/agreement/html"
+	merchantsIdAgreementHtmlPath       = "/merchants/:merchant_id/agreement/html"
 	merchantsNotificationsIdPath       = "/merchants/notifications/:notification_id"
 	merchantsNotificationsMarkReadPath = "/merchants/notifications/:notification_id/mark-as-read"
 	merchantsTariffsPath               = "/merchants/tariffs"
@@ -42,6 +47,9 @@ const (
 	merchantsIdManualPayoutEnablePath  = "/merchants/manual_payout/enable"
 	merchantsIdManualPayoutDisablePath = "/merchants/manual_payout/disable"
 	merchantsIdSetOperatingCompanyPath = "/merchants/:merchant_id/set_operating_company"
+	merchantsBankingVerifyPath         = "/merchants/banking/verify"
+	merchantsIdBankingVerifyPath       = "/merchants/:merchant_id/banking/verify"
+	bankingDirectoryReloadPath         = "/banking/directory/reload"
 )
 
 const (
@@ -51,6 +59,7 @@ const (
 	merchantAgreementUrlMask = "%s://%s/admin/api/v1/merchants/agreement/document"
 	systemAgreementUrlMask   = "%s://%s/system/api/v1/merchants/%s/agreement/document"
 	agreementUploadMaxSize   = 3145728
+	bankDirectoryFileDefault = "/assets/banking/bic_directory.csv"
 )
 
 type OnboardingFileMetadata struct {
@@ -65,21 +74,60 @@ type OnboardingFileData struct {
 	Metadata *OnboardingFileMetadata `json:"metadata"`
 }
 
+// MerchantBankingResponse is the merchant with the warnings found by the verification of the saved banking data
+type MerchantBankingResponse struct {
+	*billing.Merchant
+	BankingWarnings []*banking.Issue `json:"banking_warnings,omitempty"`
+}
+
+type BankDirectoryInfo struct {
+	Banks     int       `json:"banks"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
 type OnboardingRoute struct {
-	dispatch   common.HandlerSet
-	awsManager awsWrapper.AwsManagerInterface
-	cfg        common.Config
+	dispatch     common.HandlerSet
+	awsManager   awsWrapper.AwsManagerInterface
+	bankVerifier *banking.Verifier
+	documents    *documentRenderer
+	cfg          common.Config
 	provider.LMT
 }
 
-func NewOnboardingRoute(set common.HandlerSet, initial config.Initial, awsManager awsWrapper.AwsManagerInterface, globalCfg *common.Config) *OnboardingRoute {
+func NewOnboardingRoute(
+	set common.HandlerSet,
+	bankDirectory *banking.Directory,
+	awsManager awsWrapper.AwsManagerInterface,
+	brandingStore *branding.Store,
+	globalCfg *common.Config,
+) *OnboardingRoute {
 	set.AwareSet.Logger = set.AwareSet.Logger.WithFields(logger.Fields{"router": "OnboardingRoute"})
 	return &OnboardingRoute{
-		dispatch:   set,
-		LMT:        &set.AwareSet,
-		cfg:        *globalCfg,
-		awsManager: awsManager,
+		dispatch:     set,
+		LMT:          &set.AwareSet,
+		cfg:          *globalCfg,
+		awsManager:   awsManager,
+		bankVerifier: banking.NewVerifier(bankDirectory),
+		documents:    newDocumentRenderer(set, brandingStore, *globalCfg),
+	}
+}
+
+// newBankDirectory loads the bank directory from the configured file or from the file shipped with the service.
+// The banking data can't be verified without the directory, so the load error must stop the startup.
+func newBankDirectory(initial config.Initial, cfg *common.Config) (*banking.Directory, error) {
+	bankDirectoryFile := cfg.BankDirectoryFile
+
+	if bankDirectoryFile == "" {
+		bankDirectoryFile = initial.WorkDir + bankDirectoryFileDefault
+	}
+
+	bankDirectory := banking.NewDirectory(bankDirectoryFile)
+
+	if err := bankDirectory.Reload(); err != nil {
+		return nil, err
 	}
+
+	return bankDirectory, nil
 }
 
 func (h *OnboardingRoute) Route(groups *common.Groups) {
@@ -92,6 +140,9 @@ func (h *OnboardingRoute) Route(groups *common.Groups) {
 	groups.SystemUser.PUT(merchantsIdCompanyPath, h.setMerchantCompany)
 	groups.SystemUser.PUT(merchantsIdContactsPath, h.setMerchantContacts)
 	groups.SystemUser.PUT(merchantsIdBankingPath, h.setMerchantBanking)
+	groups.AuthUser.POST(merchantsBankingVerifyPath, h.verifyMerchantBanking)
+	groups.SystemUser.POST(merchantsIdBankingVerifyPath, h.verifyMerchantBanking)
+	groups.SystemUser.POST(bankingDirectoryReloadPath, h.reloadBankDirectory)
 	groups.AuthUser.GET(merchantsStatusCompanyPath, h.getMerchantStatus)
 
 	groups.SystemUser.PUT(merchantsIdChangeStatusCompanyPath, h.changeMerchantStatus)
@@ -101,6 +152,8 @@ func (h *OnboardingRoute) Route(groups *common.Groups) {
 	groups.SystemUser.GET(merchantsIdAgreementPath, h.getSystemAgreementData)
 	groups.AuthUser.GET(merchantsAgreementDocumentPath, h.getAgreementDocument)
 	groups.SystemUser.GET(merchantsIdAgreementDocumentPath, h.getAgreementDocument)
+	groups.AuthUser.GET(merchantsAgreementHtmlPath, h.getAgreementHtml)
+	groups.SystemUser.GET(merchantsIdAgreementHtmlPath, h.getAgreementHtml)
 
 	groups.SystemUser.POST(merchantsIdNotificationsPath, h.createNotification)
 	groups.SystemUser.GET(merchantsIdNotificationsPath, h.listNotifications)
@@ -338,6 +391,11 @@ func (h *OnboardingRoute) getAgreementDocument(ctx echo.Context) error {
 		return echo.NewHTTPError(http.StatusNotFound, common.ErrorMessageAgreementNotGenerated)
 	}
 
+	// the agreement branded by the operating company is the same document which is sent for the signature
+	if data, ok := h.documents.agreementPdf(ctx, res.Item); ok {
+		return ctx.Blob(http.StatusOK, pdf.MimeType, data)
+	}
+
 	filePath := os.TempDir() + string(os.PathSeparator) + res.Item.S3AgreementName
 	_, err = h.awsManager.Download(ctx.Request().Context(), filePath, &awsWrapper.DownloadInput{FileName: res.Item.S3AgreementName})
 
@@ -350,6 +408,40 @@ func (h *OnboardingRoute) getAgreementDocument(ctx echo.Context) error {
 	return ctx.File(filePath)
 }
 
+// getAgreementHtml renders the agreement with the template set of the merchant's operating company
+func (h *OnboardingRoute) getAgreementHtml(ctx echo.Context) error {
+	req := &grpc.GetMerchantByRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorRequestParamsIncorrect)
+	}
+
+	res, err := h.dispatch.Services.Billing.GetMerchantBy(ctx.Request().Context(), req)
+
+	if err != nil {
+		return h.dispatch.SrvCallHandler(req, err, pkg.ServiceName, "GetMerchantBy")
+	}
+
+	if res.Status != pkg.ResponseStatusOk {
+		return echo.NewHTTPError(int(res.Status), res.Message)
+	}
+
+	merchant := res.Item
+
+	if merchant.Company == nil || merchant.Banking == nil || merchant.Contacts == nil || merchant.Contacts.Authorized == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageAgreementCanNotBeGenerate)
+	}
+
+	set, documentBranding := h.documents.branding(ctx, merchant.OperatingCompanyId)
+	data := &AgreementDocument{
+		AgreementNumber: merchant.AgreementNumber,
+		Merchant:        merchant,
+		Branding:        documentBranding,
+	}
+
+	return h.documents.render(ctx, set, branding.TemplateAgreement, data)
+}
+
 func (h *OnboardingRoute) getAgreementStructure(
 	ctx echo.Context,
 	merchantId, ext, ct, fPath string,
@@ -525,6 +617,20 @@ func (h *OnboardingRoute) setMerchantBanking(ctx echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, common.GetValidationError(err))
 	}
 
+	verification, err := h.verifyBanking(ctx, in)
+
+	if err != nil {
+		return err
+	}
+
+	if verification.HasErrors() {
+		return echo.NewHTTPError(http.StatusBadRequest, common.NewManagementApiResponseError(
+			common.ErrorMessageBankingVerificationFailed.Code,
+			common.ErrorMessageBankingVerificationFailed.Message,
+			verification.Errors[0].Message,
+		))
+	}
+
 	res, err := h.dispatch.Services.Billing.ChangeMerchant(ctx.Request().Context(), req)
 
 	if err != nil {
@@ -536,7 +642,70 @@ func (h *OnboardingRoute) setMerchantBanking(ctx echo.Context) error {
 		return echo.NewHTTPError(int(res.Status), res.Message)
 	}
 
-	return ctx.JSON(http.StatusOK, res.Item)
+	return ctx.JSON(http.StatusOK, &MerchantBankingResponse{Merchant: res.Item, BankingWarnings: verification.Warnings})
+}
+
+// verifyMerchantBanking checks the banking data against the bank directory and the merchant company data
+// and returns all found warnings and errors without saving the data
+func (h *OnboardingRoute) verifyMerchantBanking(ctx echo.Context) error {
+	in := &billing.MerchantBanking{}
+
+	if err := ctx.Bind(in); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorRequestParamsIncorrect)
+	}
+
+	verification, err := h.verifyBanking(ctx, in)
+
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, verification)
+}
+
+// verifyBanking checks the banking data with the company country of the merchant
+func (h *OnboardingRoute) verifyBanking(ctx echo.Context, in *billing.MerchantBanking) (*banking.Verification, error) {
+	req := &grpc.GetMerchantByRequest{MerchantId: ctx.Param(common.RequestParameterMerchantId)}
+
+	if req.MerchantId == "" {
+		req.UserId = common.ExtractUserContext(ctx).Id
+	}
+
+	res, err := h.dispatch.Services.Billing.GetMerchantBy(ctx.Request().Context(), req)
+
+	if err != nil {
+		return nil, h.dispatch.SrvCallHandler(req, err, pkg.ServiceName, "GetMerchantBy")
+	}
+
+	if res.Status != pkg.ResponseStatusOk {
+		return nil, echo.NewHTTPError(int(res.Status), res.Message)
+	}
+
+	account := &banking.Account{
+		Swift:         in.Swift,
+		AccountNumber: in.AccountNumber,
+		Currency:      in.Currency,
+	}
+
+	if res.Item.Company != nil {
+		account.CompanyCountry = res.Item.Company.Country
+	}
+
+	return h.bankVerifier.Verify(account), nil
+}
+
+// reloadBankDirectory rereads the directory file of the replica which received the request only.
+// The file is shipped with the service, so the replicas get the new directory with the new release
+// and the reload is used to pick up the file replaced in place on the single replica.
+func (h *OnboardingRoute) reloadBankDirectory(ctx echo.Context) error {
+	directory := h.bankVerifier.Directory()
+
+	if err := directory.Reload(); err != nil {
+		h.L().Error("Bank directory reload failed", logger.PairArgs("err", err.Error()))
+		return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessageBankDirectoryReloadFailed)
+	}
+
+	return ctx.JSON(http.StatusOK, &BankDirectoryInfo{Banks: directory.Len(), UpdatedAt: directory.UpdatedAt()})
 }
 
 func (h *OnboardingRoute) getMerchantStatus(ctx echo.Context) error {
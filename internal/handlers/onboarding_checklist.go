@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/ProtocolONE/go-core/v2/pkg/logger"
+	"github.com/ProtocolONE/go-core/v2/pkg/provider"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"gopkg.in/go-playground/validator.v9"
+	"net/http"
+)
+
+const (
+	merchantsOnboardingPath   = "/merchants/onboarding"
+	merchantsIdOnboardingPath = "/merchants/:merchant_id/onboarding"
+)
+
+const (
+	onboardingStepCompany           = "company"
+	onboardingStepContacts          = "contacts"
+	onboardingStepBanking           = "banking"
+	onboardingStepTariff            = "tariff"
+	onboardingStepAgreement         = "agreement"
+	onboardingStepMerchantSignature = "merchant_signature"
+	onboardingStepPspSignature      = "psp_signature"
+
+	onboardingProblemTagRequired = "required"
+)
+
+// onboardingStepDependencies describes the steps which must be completed before the step can be started.
+// The order of the slice is the order of the checklist.
+var onboardingStepDependencies = []struct {
+	name      string
+	dependsOn []string
+}{
+	{name: onboardingStepCompany},
+	{name: onboardingStepContacts},
+	{name: onboardingStepBanking},
+	{name: onboardingStepTariff, dependsOn: []string{onboardingStepCompany}},
+	{
+		name:      onboardingStepAgreement,
+		dependsOn: []string{onboardingStepCompany, onboardingStepContacts, onboardingStepBanking, onboardingStepTariff},
+	},
+	{name: onboardingStepMerchantSignature, dependsOn: []string{onboardingStepAgreement}},
+	{name: onboardingStepPspSignature, dependsOn: []string{onboardingStepMerchantSignature}},
+}
+
+type OnboardingChecklistProblem struct {
+	Field   string `json:"field"`
+	Tag     string `json:"tag"`
+	Message string `json:"message"`
+}
+
+type OnboardingChecklistStep struct {
+	Name      string                        `json:"name"`
+	Completed bool                          `json:"completed"`
+	Problems  []*OnboardingChecklistProblem `json:"problems,omitempty"`
+	BlockedBy []string                      `json:"blocked_by,omitempty"`
+}
+
+type OnboardingChecklist struct {
+	MerchantId     string                     `json:"merchant_id"`
+	Status         int32                      `json:"status"`
+	CompletedSteps int                        `json:"completed_steps"`
+	TotalSteps     int                        `json:"total_steps"`
+	NextStep       string                     `json:"next_step,omitempty"`
+	BlockedBy      []string                   `json:"blocked_by,omitempty"`
+	Steps          []*OnboardingChecklistStep `json:"steps"`
+}
+
+type OnboardingChecklistRoute struct {
+	dispatch common.HandlerSet
+	cfg      common.Config
+	provider.LMT
+}
+
+func NewOnboardingChecklistRoute(set common.HandlerSet, cfg *common.Config) *OnboardingChecklistRoute {
+	set.AwareSet.Logger = set.AwareSet.Logger.WithFields(logger.Fields{"router": "OnboardingChecklistRoute"})
+	return &OnboardingChecklistRoute{
+		dispatch: set,
+		LMT:      &set.AwareSet,
+		cfg:      *cfg,
+	}
+}
+
+func (h *OnboardingChecklistRoute) Route(groups *common.Groups) {
+	groups.AuthUser.GET(merchantsOnboardingPath, h.getOnboardingChecklist)
+	groups.SystemUser.GET(merchantsIdOnboardingPath, h.getOnboardingChecklist)
+}
+
+func (h *OnboardingChecklistRoute) getOnboardingChecklist(ctx echo.Context) error {
+	req := &grpc.GetMerchantByRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	if req.MerchantId == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorIncorrectMerchantId)
+	}
+
+	res, err := h.dispatch.Services.Billing.GetMerchantBy(ctx.Request().Context(), req)
+
+	if err != nil {
+		return h.dispatch.SrvCallHandler(req, err, pkg.ServiceName, "GetMerchantBy")
+	}
+
+	if res.Status != pkg.ResponseStatusOk {
+		return echo.NewHTTPError(int(res.Status), res.Message)
+	}
+
+	return ctx.JSON(http.StatusOK, h.newOnboardingChecklist(res.Item))
+}
+
+func (h *OnboardingChecklistRoute) newOnboardingChecklist(merchant *billing.Merchant) *OnboardingChecklist {
+	problems := map[string][]*OnboardingChecklistProblem{
+		onboardingStepCompany:  h.validateStep(merchant.Company != nil, merchant.Company),
+		onboardingStepContacts: h.validateStep(merchant.Contacts != nil, merchant.Contacts),
+		onboardingStepBanking:  h.validateStep(merchant.Banking != nil, merchant.Banking),
+	}
+
+	completed := map[string]bool{
+		onboardingStepCompany:           merchant.Company != nil && len(problems[onboardingStepCompany]) == 0,
+		onboardingStepContacts:          merchant.Contacts != nil && len(problems[onboardingStepContacts]) == 0,
+		onboardingStepBanking:           merchant.Banking != nil && len(problems[onboardingStepBanking]) == 0,
+		onboardingStepTariff:            merchant.Tariff != nil,
+		onboardingStepAgreement:         merchant.S3AgreementName != "",
+		onboardingStepMerchantSignature: merchant.HasMerchantSignature,
+		onboardingStepPspSignature:      merchant.HasPspSignature,
+	}
+
+	checklist := &OnboardingChecklist{
+		MerchantId: merchant.Id,
+		Status:     merchant.Status,
+		TotalSteps: len(onboardingStepDependencies),
+		Steps:      make([]*OnboardingChecklistStep, 0, len(onboardingStepDependencies)),
+	}
+
+	for _, dependency := range onboardingStepDependencies {
+		step := &OnboardingChecklistStep{
+			Name:      dependency.name,
+			Completed: completed[dependency.name],
+			Problems:  problems[dependency.name],
+		}
+
+		for _, name := range dependency.dependsOn {
+			if !completed[name] {
+				step.BlockedBy = append(step.BlockedBy, name)
+			}
+		}
+
+		if step.Completed {
+			checklist.CompletedSteps++
+		} else if checklist.NextStep == "" {
+			checklist.NextStep = step.Name
+			checklist.BlockedBy = step.BlockedBy
+		}
+
+		checklist.Steps = append(checklist.Steps, step)
+	}
+
+	return checklist
+}
+
+func (h *OnboardingChecklistRoute) validateStep(exists bool, data interface{}) []*OnboardingChecklistProblem {
+	if !exists {
+		return []*OnboardingChecklistProblem{
+			{
+				Tag:     onboardingProblemTagRequired,
+				Message: common.ErrorOnboardingStepNotFilled.Message,
+			},
+		}
+	}
+
+	err := h.dispatch.Validate.Struct(data)
+
+	if err == nil {
+		return nil
+	}
+
+	vErrs, ok := err.(validator.ValidationErrors)
+
+	if !ok {
+		h.L().Error("onboarding step validation failed", logger.PairArgs("err", err.Error()))
+		return nil
+	}
+
+	problems := make([]*OnboardingChecklistProblem, 0, len(vErrs))
+
+	for _, vErr := range vErrs {
+		problems = append(problems, &OnboardingChecklistProblem{
+			Field:   vErr.Field(),
+			Tag:     vErr.Tag(),
+			Message: fmt.Sprintf(common.ErrorMessageMask, vErr.Field(), vErr.Tag()),
+		})
+	}
+
+	return problems
+}
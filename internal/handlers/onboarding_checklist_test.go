@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/mock"
+	"github.com/paysuper/paysuper-management-api/internal/test"
+	mock2 "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"testing"
+)
+
+type OnboardingChecklistTestSuite struct {
+	suite.Suite
+	router *OnboardingChecklistRoute
+	caller *test.EchoReqResCaller
+}
+
+func Test_OnboardingChecklist(t *testing.T) {
+	suite.Run(t, new(OnboardingChecklistTestSuite))
+}
+
+func (suite *OnboardingChecklistTestSuite) SetupTest() {
+	user := &common.AuthUser{
+		Id:         "ffffffffffffffffffffffff",
+		Email:      "test@unit.test",
+		MerchantId: "ffffffffffffffffffffffff",
+	}
+	var e error
+	settings := test.DefaultSettings()
+	srv := common.Services{
+		Billing: &mocks.BillingService{},
+	}
+	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
+		mw.Pre(test.PreAuthUserMiddleware(user))
+		suite.router = NewOnboardingChecklistRoute(set.HandlerSet, set.GlobalConfig)
+		return common.Handlers{
+			suite.router,
+		}
+	})
+	if e != nil {
+		panic(e)
+	}
+}
+
+func (suite *OnboardingChecklistTestSuite) TearDownTest() {}
+
+func (suite *OnboardingChecklistTestSuite) TestOnboardingChecklist_EmptyMerchant_Ok() {
+	shouldBe := require.New(suite.T())
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("GetMerchantBy", mock2.Anything, mock2.Anything).Return(&grpc.GetMerchantResponse{
+		Status: pkg.ResponseStatusOk,
+		Item: &billing.Merchant{
+			Id:     "ffffffffffffffffffffffff",
+			Status: pkg.MerchantStatusDraft,
+		},
+	}, nil)
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Path(common.AuthUserGroupPath + merchantsOnboardingPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+
+	checklist := &OnboardingChecklist{}
+	shouldBe.NoError(json.Unmarshal(res.Body.Bytes(), checklist))
+	shouldBe.Equal("ffffffffffffffffffffffff", checklist.MerchantId)
+	shouldBe.Equal(0, checklist.CompletedSteps)
+	shouldBe.Equal(len(onboardingStepDependencies), checklist.TotalSteps)
+	shouldBe.Len(checklist.Steps, len(onboardingStepDependencies))
+	shouldBe.Equal(onboardingStepCompany, checklist.NextStep)
+	shouldBe.Empty(checklist.BlockedBy)
+	shouldBe.Len(checklist.Steps[0].Problems, 1)
+	shouldBe.Equal(onboardingProblemTagRequired, checklist.Steps[0].Problems[0].Tag)
+	shouldBe.Equal(
+		[]string{onboardingStepCompany, onboardingStepContacts, onboardingStepBanking, onboardingStepTariff},
+		checklist.Steps[4].BlockedBy,
+	)
+}
+
+func (suite *OnboardingChecklistTestSuite) TestOnboardingChecklist_InvalidCompany_Ok() {
+	shouldBe := require.New(suite.T())
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("GetMerchantBy", mock2.Anything, mock2.Anything).Return(&grpc.GetMerchantResponse{
+		Status: pkg.ResponseStatusOk,
+		Item: &billing.Merchant{
+			Id:       "ffffffffffffffffffffffff",
+			Company:  &billing.MerchantCompanyInfo{Name: "merchant1"},
+			Contacts: mock.OnboardingMerchantMock.Contacts,
+		},
+	}, nil)
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Path(common.AuthUserGroupPath + merchantsOnboardingPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+
+	checklist := &OnboardingChecklist{}
+	shouldBe.NoError(json.Unmarshal(res.Body.Bytes(), checklist))
+	shouldBe.False(checklist.Steps[0].Completed)
+	shouldBe.NotEmpty(checklist.Steps[0].Problems)
+	shouldBe.NotEqual(onboardingProblemTagRequired, checklist.Steps[0].Problems[0].Tag)
+	shouldBe.Equal(onboardingStepCompany, checklist.NextStep)
+}
+
+func (suite *OnboardingChecklistTestSuite) TestOnboardingChecklist_CompletedMerchant_Ok() {
+	shouldBe := require.New(suite.T())
+
+	merchant := &billing.Merchant{Id: "ffffffffffffffffffffffff", S3AgreementName: mock.SomeAgreementName}
+	checklist := suite.router.newOnboardingChecklist(merchant)
+	shouldBe.Equal(1, checklist.CompletedSteps)
+	shouldBe.True(checklist.Steps[4].Completed)
+	shouldBe.Equal(onboardingStepCompany, checklist.NextStep)
+
+	merchant.HasMerchantSignature = true
+	merchant.HasPspSignature = true
+	checklist = suite.router.newOnboardingChecklist(merchant)
+	shouldBe.Equal(3, checklist.CompletedSteps)
+	shouldBe.Empty(checklist.Steps[6].BlockedBy)
+}
+
+func (suite *OnboardingChecklistTestSuite) TestOnboardingChecklist_System_Ok() {
+	shouldBe := require.New(suite.T())
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("GetMerchantBy", mock2.Anything, mock2.Anything).Return(&grpc.GetMerchantResponse{
+		Status: pkg.ResponseStatusOk,
+		Item:   mock.OnboardingMerchantMock,
+	}, nil)
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterMerchantId, mock.OnboardingMerchantMock.Id).
+		Path(common.SystemUserGroupPath + merchantsIdOnboardingPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+
+	checklist := &OnboardingChecklist{}
+	shouldBe.NoError(json.Unmarshal(res.Body.Bytes(), checklist))
+	shouldBe.Equal(mock.OnboardingMerchantMock.Id, checklist.MerchantId)
+}
+
+func (suite *OnboardingChecklistTestSuite) TestOnboardingChecklist_BillingServerError() {
+	shouldBe := require.New(suite.T())
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("GetMerchantBy", mock2.Anything, mock2.Anything).Return(nil, errors.New("some error"))
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Path(common.AuthUserGroupPath + merchantsOnboardingPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.Error(err)
+	hErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusInternalServerError, hErr.Code)
+	shouldBe.Equal(common.ErrorInternal, hErr.Message)
+}
+
+func (suite *OnboardingChecklistTestSuite) TestOnboardingChecklist_MerchantNotFound() {
+	shouldBe := require.New(suite.T())
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("GetMerchantBy", mock2.Anything, mock2.Anything).Return(&grpc.GetMerchantResponse{
+		Status:  pkg.ResponseStatusNotFound,
+		Message: mock.SomeError,
+	}, nil)
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Path(common.AuthUserGroupPath + merchantsOnboardingPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.Error(err)
+	hErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusNotFound, hErr.Code)
+	shouldBe.Equal(mock.SomeError, hErr.Message)
+}
@@ -14,6 +14,8 @@ import (
 	billingMocks "github.com/paysuper/paysuper-billing-server/pkg/mocks"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/banking"
+	"github.com/paysuper/paysuper-management-api/internal/branding"
 	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
 	"github.com/paysuper/paysuper-management-api/internal/mock"
 	"github.com/paysuper/paysuper-management-api/internal/test"
@@ -102,7 +104,12 @@ func (suite *OnboardingTestSuite) SetupTest() {
 		awsManagerMock.On("Download", mock2.Anything, mock2.Anything, mock2.Anything, mock2.Anything).
 			Return(downloadMockResultFn, nil)
 
-		suite.router = NewOnboardingRoute(set.HandlerSet, set.Initial, awsManagerMock, set.GlobalConfig)
+		bankDirectory, err := newBankDirectory(set.Initial, set.GlobalConfig)
+		if err != nil {
+			panic(err)
+		}
+
+		suite.router = NewOnboardingRoute(set.HandlerSet, bankDirectory, awsManagerMock, branding.NewStore(awsManagerMock), set.GlobalConfig)
 		return common.Handlers{
 			suite.router,
 		}
@@ -1478,7 +1485,7 @@ func (suite *OnboardingTestSuite) TestOnboarding_SetMerchantBanking_WithoutMerch
 		Name:                 "Bank Name-Spb.",
 		Address:              "St.Petersburg, Nevskiy st. 1",
 		AccountNumber:        "SE1412345678901234567890",
-		Swift:                "ALFARUMM",
+		Swift:                "ESSESESS",
 		CorrespondentAccount: "408000000001",
 	}
 	b, err := json.Marshal(banking)
@@ -1507,7 +1514,7 @@ func (suite *OnboardingTestSuite) TestOnboarding_SetMerchantBanking_WithMerchant
 		Name:                 "Bank Name-Spb.",
 		Address:              "St.Petersburg, Nevskiy st. 1",
 		AccountNumber:        "SE1412345678901234567890",
-		Swift:                "ALFARUMM",
+		Swift:                "ESSESESS",
 		CorrespondentAccount: "408000000001",
 	}
 	b, err := json.Marshal(banking)
@@ -1701,11 +1708,13 @@ func (suite *OnboardingTestSuite) TestOnboarding_SetMerchantBanking_BillingServe
 		"name": "Bank Name-Spb.",
 		"address": "St.Petersburg, Nevskiy st. 1",
 		"account_number": "SE1412345678901234567890",
-		"swift": "ALFARUMM",
+		"swift": "ESSESESS",
 		"correspondent_account": "408000000001"
 	}`
 
 	billingService := &billMock.BillingService{}
+	billingService.On("GetMerchantBy", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetMerchantResponse{Status: pkg.ResponseStatusOk, Item: mock.OnboardingMerchantMock}, nil)
 	billingService.On("ChangeMerchant", mock2.Anything, mock2.Anything).Return(nil, mock.SomeError)
 	suite.router.dispatch.Services.Billing = billingService
 
@@ -1730,11 +1739,13 @@ func (suite *OnboardingTestSuite) TestOnboarding_SetMerchantBanking_BillingServe
 		"name": "Bank Name-Spb.",
 		"address": "St.Petersburg, Nevskiy st. 1",
 		"account_number": "SE1412345678901234567890",
-		"swift": "ALFARUMM",
+		"swift": "ESSESESS",
 		"correspondent_account": "408000000001"
 	}`
 
 	billingService := &billMock.BillingService{}
+	billingService.On("GetMerchantBy", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetMerchantResponse{Status: pkg.ResponseStatusOk, Item: mock.OnboardingMerchantMock}, nil)
 	billingService.On("ChangeMerchant", mock2.Anything, mock2.Anything).
 		Return(&grpc.ChangeMerchantResponse{Status: http.StatusBadRequest, Message: mock.SomeError}, nil)
 	suite.router.dispatch.Services.Billing = billingService
@@ -1754,6 +1765,197 @@ func (suite *OnboardingTestSuite) TestOnboarding_SetMerchantBanking_BillingServe
 	assert.Equal(suite.T(), mock.SomeError, httpErr.Message)
 }
 
+func (suite *OnboardingTestSuite) TestOnboarding_SetMerchantBanking_VerificationError() {
+	b := `{
+		"currency": "RUB",
+		"name": "Bank Name-Spb.",
+		"address": "St.Petersburg, Nevskiy st. 1",
+		"account_number": "SE1412345678901234567890",
+		"swift": "ALFARUMM",
+		"correspondent_account": "408000000001"
+	}`
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodPut).
+		Path(common.AuthUserGroupPath + merchantsBankingPath).
+		Init(test.ReqInitJSON()).
+		BodyString(b).
+		Exec(suite.T())
+
+	assert.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), http.StatusBadRequest, httpErr.Code)
+
+	msg, ok := httpErr.Message.(*grpc.ResponseErrorMessage)
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), common.ErrorMessageBankingVerificationFailed.Code, msg.Code)
+	assert.Regexp(suite.T(), "iban country SE does not match the bank country RU", msg.Details)
+}
+
+func (suite *OnboardingTestSuite) TestOnboarding_SetMerchantBanking_CompanyCountryWarnings() {
+	b := `{
+		"currency": "SEK",
+		"name": "Bank Name-Spb.",
+		"address": "St.Petersburg, Nevskiy st. 1",
+		"account_number": "SE1412345678901234567890",
+		"swift": "ESSESESS",
+		"correspondent_account": "408000000001"
+	}`
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodPut).
+		Params(":"+common.RequestParameterMerchantId, mock.OnboardingMerchantMock.Id).
+		Path(common.SystemUserGroupPath + merchantsIdBankingPath).
+		Init(test.ReqInitJSON()).
+		BodyString(b).
+		Exec(suite.T())
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, res.Code)
+
+	response := &MerchantBankingResponse{}
+	err = json.Unmarshal(res.Body.Bytes(), response)
+	assert.NoError(suite.T(), err)
+
+	var codes []string
+
+	for _, warning := range response.BankingWarnings {
+		codes = append(codes, warning.Code)
+	}
+
+	assert.Contains(suite.T(), codes, banking.IssueCodeBicCompanyCountryMismatch)
+	assert.Contains(suite.T(), codes, banking.IssueCodeIbanCompanyCountryMismatch)
+}
+
+func (suite *OnboardingTestSuite) TestOnboarding_VerifyMerchantBanking_IbanTerritory() {
+	b := `{
+		"currency": "GBP",
+		"account_number": "GB29NWBK60161331926819",
+		"swift": "ZZZZJESH"
+	}`
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.AuthUserGroupPath + merchantsBankingVerifyPath).
+		Init(test.ReqInitJSON()).
+		BodyString(b).
+		Exec(suite.T())
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, res.Code)
+
+	verification := new(banking.Verification)
+	err = json.Unmarshal(res.Body.Bytes(), verification)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "GB", verification.IbanCountry)
+	assert.Equal(suite.T(), "JE", verification.BicCountry)
+	assert.Empty(suite.T(), verification.Errors)
+}
+
+func (suite *OnboardingTestSuite) TestOnboarding_VerifyMerchantBanking_Ok() {
+	b := `{
+		"currency": "RUB",
+		"account_number": "40702810100000000001",
+		"swift": "ALFARUMMXXX"
+	}`
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.AuthUserGroupPath + merchantsBankingVerifyPath).
+		Init(test.ReqInitJSON()).
+		BodyString(b).
+		Exec(suite.T())
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, res.Code)
+
+	verification := new(banking.Verification)
+	err = json.Unmarshal(res.Body.Bytes(), verification)
+	assert.NoError(suite.T(), err)
+	assert.NotNil(suite.T(), verification.Bank)
+	assert.Equal(suite.T(), "Alfa-Bank", verification.Bank.Name)
+	assert.Equal(suite.T(), "RU", verification.BicCountry)
+	assert.Empty(suite.T(), verification.IbanCountry)
+	assert.Empty(suite.T(), verification.Warnings)
+	assert.Empty(suite.T(), verification.Errors)
+}
+
+func (suite *OnboardingTestSuite) TestOnboarding_VerifyMerchantBanking_Mismatches() {
+	b := `{
+		"currency": "RUB",
+		"account_number": "SE14 1234 5678 9012 3456 7890",
+		"swift": "ZZZZDEFF"
+	}`
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Params(":"+common.RequestParameterMerchantId, mock.OnboardingMerchantMock.Id).
+		Path(common.SystemUserGroupPath + merchantsIdBankingVerifyPath).
+		Init(test.ReqInitJSON()).
+		BodyString(b).
+		Exec(suite.T())
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, res.Code)
+
+	verification := new(banking.Verification)
+	err = json.Unmarshal(res.Body.Bytes(), verification)
+	assert.NoError(suite.T(), err)
+	assert.Nil(suite.T(), verification.Bank)
+	assert.Equal(suite.T(), "SE", verification.IbanCountry)
+	assert.True(suite.T(), verification.HasErrors())
+	assert.Equal(suite.T(), banking.IssueCodeIbanBicCountryMismatch, verification.Errors[0].Code)
+
+	var codes []string
+
+	for _, warning := range verification.Warnings {
+		codes = append(codes, warning.Code)
+	}
+
+	assert.Contains(suite.T(), codes, banking.IssueCodeBicNotFound)
+	assert.Contains(suite.T(), codes, banking.IssueCodeBicCompanyCountryMismatch)
+	assert.Contains(suite.T(), codes, banking.IssueCodeIbanCompanyCountryMismatch)
+	assert.Contains(suite.T(), codes, banking.IssueCodeCurrencyCountryMismatch)
+}
+
+func (suite *OnboardingTestSuite) TestOnboarding_VerifyMerchantBanking_BillingServerSystemError() {
+	billingService := &billMock.BillingService{}
+	billingService.On("GetMerchantBy", mock2.Anything, mock2.Anything).Return(nil, mock.SomeError)
+	suite.router.dispatch.Services.Billing = billingService
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.AuthUserGroupPath + merchantsBankingVerifyPath).
+		Init(test.ReqInitJSON()).
+		BodyString(`{"swift": "ALFARUMM"}`).
+		Exec(suite.T())
+
+	assert.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), http.StatusInternalServerError, httpErr.Code)
+}
+
+func (suite *OnboardingTestSuite) TestOnboarding_ReloadBankDirectory_Ok() {
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.SystemUserGroupPath + bankingDirectoryReloadPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, res.Code)
+
+	info := new(BankDirectoryInfo)
+	err = json.Unmarshal(res.Body.Bytes(), info)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), info.Banks > 0)
+	assert.False(suite.T(), info.UpdatedAt.IsZero())
+}
+
 func (suite *OnboardingTestSuite) TestOnboarding_GetMerchantStatus_Ok() {
 
 	res, err := suite.caller.Builder().
@@ -7,6 +7,7 @@ import (
 	"github.com/paysuper/paysuper-billing-server/pkg"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/branding"
 	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
 	"net/http"
 )
@@ -18,14 +19,16 @@ const (
 
 type OperatingCompanyRoute struct {
 	dispatch common.HandlerSet
+	branding *branding.Store
 	cfg      common.Config
 	provider.LMT
 }
 
-func NewOperatingCompanyRoute(set common.HandlerSet, cfg *common.Config) *OperatingCompanyRoute {
+func NewOperatingCompanyRoute(set common.HandlerSet, brandingStore *branding.Store, cfg *common.Config) *OperatingCompanyRoute {
 	set.AwareSet.Logger = set.AwareSet.Logger.WithFields(logger.Fields{"router": "OperatingCompanyRoute"})
 	return &OperatingCompanyRoute{
 		dispatch: set,
+		branding: brandingStore,
 		LMT:      &set.AwareSet,
 		cfg:      *cfg,
 	}
@@ -37,6 +40,12 @@ func (h *OperatingCompanyRoute) Route(groups *common.Groups) {
 	groups.SystemUser.POST(operatingCompanyPath, h.addOperatingCompany)
 	groups.SystemUser.POST(operatingCompanyIdPath, h.updateOperatingCompany)
 
+	groups.SystemUser.GET(operatingCompanyIdBrandingPath, h.getBranding)
+	groups.SystemUser.PUT(operatingCompanyIdBrandingPath, h.setBranding)
+	groups.SystemUser.POST(operatingCompanyIdBrandingLogoPath, h.uploadLogo)
+	groups.SystemUser.GET(operatingCompanyIdBrandingPreviewPath, h.getBrandingPreview)
+	groups.SystemUser.POST(operatingCompanyIdBrandingPreviewPath, h.postBrandingPreview)
+	groups.Common.GET(operatingCompanyIdLogoPath, h.getLogo)
 }
 
 func (h *OperatingCompanyRoute) getOperatingCompanyList(ctx echo.Context) error {
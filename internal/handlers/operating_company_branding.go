@@ -0,0 +1,598 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/ProtocolONE/go-core/v2/pkg/logger"
+	"github.com/ProtocolONE/go-core/v2/pkg/provider"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/branding"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/pdf"
+	"github.com/paysuper/paysuper-management-api/internal/receipt"
+	"github.com/paysuper/paysuper-management-api/internal/storage"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+)
+
+const (
+	operatingCompanyIdBrandingPath        = "/operating_company/:id/branding"
+	operatingCompanyIdBrandingLogoPath    = "/operating_company/:id/branding/logo"
+	operatingCompanyIdBrandingPreviewPath = "/operating_company/:id/branding/preview/:template"
+	operatingCompanyIdLogoPath            = "/operating_company/:id/logo"
+)
+
+const (
+	operatingCompanyLogoUrlMask = "%s/api/v1/operating_company/%s/logo"
+	documentTemplateExtension   = ".html"
+	documentFormatQueryParam    = "format"
+	documentFormatHtml          = "html"
+	documentFormatPdf           = "pdf"
+)
+
+type operatingCompanyBrandingRequest struct {
+	Id          string            `json:"-" validate:"required,hexadecimal,len=24"`
+	LegalFooter string            `json:"legal_footer"`
+	Templates   map[string]string `json:"templates"`
+}
+
+type operatingCompanyBrandingPreviewRequest struct {
+	Id          string            `json:"-" validate:"required,hexadecimal,len=24"`
+	Template    string            `json:"-" validate:"required,oneof=agreement receipt"`
+	LegalFooter *string           `json:"legal_footer"`
+	Templates   map[string]string `json:"templates"`
+}
+
+type operatingCompanyIdRequest struct {
+	Id string `validate:"required,hexadecimal,len=24"`
+}
+
+// DocumentBranding is the branding of the operating company available in the document templates as .Branding
+type DocumentBranding struct {
+	OperatingCompany *billing.OperatingCompany `json:"operating_company"`
+	LegalFooter      string                    `json:"legal_footer"`
+	// LogoUrl is trusted by the templates to keep the data uri of the inlined logo in the pdf documents
+	LogoUrl template.URL `json:"logo_url"`
+}
+
+// AgreementDocument is the data of the agreement template
+type AgreementDocument struct {
+	AgreementNumber string
+	Merchant        *billing.Merchant
+	Branding        *DocumentBranding
+}
+
+// ReceiptDocument is the data of the receipt template, the texts are translated to the order language
+type ReceiptDocument struct {
+	Receipt  *billing.OrderReceipt
+	Labels   *receipt.Labels
+	Branding *DocumentBranding
+}
+
+// documentRenderer renders the documents with the template set of the merchant's operating company
+type documentRenderer struct {
+	dispatch common.HandlerSet
+	store    *branding.Store
+	pdf      *pdf.Renderer
+	cfg      common.Config
+	provider.LMT
+}
+
+func newDocumentRenderer(set common.HandlerSet, store *branding.Store, cfg common.Config) *documentRenderer {
+	return &documentRenderer{
+		dispatch: set,
+		store:    store,
+		pdf:      pdf.NewRenderer(cfg.PdfRendererBinary, cfg.PdfRendererProxy),
+		cfg:      cfg,
+		LMT:      &set.AwareSet,
+	}
+}
+
+// branding returns the template set and the branding of the operating company.
+// The documents of the merchant without the operating company or with the failed lookup get the default branding.
+func (r *documentRenderer) branding(ctx echo.Context, operatingCompanyId string) (*branding.TemplateSet, *DocumentBranding) {
+	if operatingCompanyId == "" {
+		return nil, nil
+	}
+
+	req := &grpc.GetOperatingCompanyRequest{Id: operatingCompanyId}
+	res, err := r.dispatch.Services.Billing.GetOperatingCompany(ctx.Request().Context(), req)
+
+	if err != nil {
+		common.LogSrvCallFailedGRPC(r.L(), err, pkg.ServiceName, "GetOperatingCompany", req)
+		return nil, nil
+	}
+
+	if res.Status != pkg.ResponseStatusOk {
+		r.L().Error(
+			"operating company of document not found",
+			logger.PairArgs("operating_company_id", operatingCompanyId, "status", res.Status, "message", res.Message),
+		)
+		return nil, nil
+	}
+
+	set, err := r.store.Get(ctx.Request().Context(), operatingCompanyId)
+
+	if err != nil {
+		r.L().Error(
+			"operating company branding storage call failed",
+			logger.PairArgs("err", err.Error(), "operating_company_id", operatingCompanyId),
+		)
+		return nil, &DocumentBranding{OperatingCompany: res.Company}
+	}
+
+	return set, getDocumentBranding(r.cfg, res.Company, set)
+}
+
+// merchantOperatingCompanyId returns the operating company of the merchant, the failed lookup returns the empty id
+func (r *documentRenderer) merchantOperatingCompanyId(ctx echo.Context, merchantId string) string {
+	if merchantId == "" {
+		return ""
+	}
+
+	req := &grpc.GetMerchantByRequest{MerchantId: merchantId}
+	res, err := r.dispatch.Services.Billing.GetMerchantBy(ctx.Request().Context(), req)
+
+	if err != nil {
+		common.LogSrvCallFailedGRPC(r.L(), err, pkg.ServiceName, "GetMerchantBy", req)
+		return ""
+	}
+
+	if res.Status != pkg.ResponseStatusOk {
+		r.L().Error(
+			"merchant of document not found",
+			logger.PairArgs("merchant_id", merchantId, "status", res.Status, "message", res.Message),
+		)
+		return ""
+	}
+
+	return res.Item.OperatingCompanyId
+}
+
+// html executes the template of the set or the default template when the document isn't branded.
+// The branded template failed on the document data is replaced by the default one to deliver the document anyway.
+func (r *documentRenderer) html(ctx echo.Context, set *branding.TemplateSet, name string, data interface{}) ([]byte, error) {
+	if set.HasTemplate(name) {
+		out, err := set.Render(name, data, common.FuncMap)
+
+		if err == nil {
+			return out, nil
+		}
+
+		r.L().Error(
+			"operating company document template render failed",
+			logger.PairArgs("err", err.Error(), "operating_company_id", set.OperatingCompanyId, "template", name),
+		)
+	}
+
+	renderer := ctx.Echo().Renderer
+
+	if renderer == nil {
+		return nil, echo.ErrRendererNotRegistered
+	}
+
+	buf := &bytes.Buffer{}
+
+	if err := renderer.Render(buf, name+documentTemplateExtension, data, ctx); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// agreementPdf renders the agreement of the merchant with the branding of the operating company.
+// False is returned when the operating company doesn't brand the agreement or the agreement can't be rendered,
+// the agreement generated by the billing server is used then.
+func (r *documentRenderer) agreementPdf(ctx echo.Context, merchant *billing.Merchant) ([]byte, bool) {
+	if merchant.Company == nil || merchant.Banking == nil || merchant.Contacts == nil || merchant.Contacts.Authorized == nil {
+		return nil, false
+	}
+
+	set, documentBranding := r.branding(ctx, merchant.OperatingCompanyId)
+
+	if !set.IsBranded(branding.TemplateAgreement) {
+		return nil, false
+	}
+
+	document := &AgreementDocument{
+		AgreementNumber: merchant.AgreementNumber,
+		Merchant:        merchant,
+		Branding:        documentBranding,
+	}
+	data, err := r.pdfDocument(ctx, set, branding.TemplateAgreement, document, documentBranding)
+
+	if err == nil {
+		return data, true
+	}
+
+	r.L().Error(
+		"branded agreement render failed",
+		logger.PairArgs("err", err.Error(), "merchant_id", merchant.Id, "operating_company_id", merchant.OperatingCompanyId),
+	)
+
+	return nil, false
+}
+
+// pdfDocument renders the pdf of the document. The pdf renderer doesn't load anything from the network,
+// so the logo link of the branding is replaced by the logo inlined as the data uri for the render.
+func (r *documentRenderer) pdfDocument(
+	ctx echo.Context,
+	set *branding.TemplateSet,
+	name string,
+	data interface{},
+	documentBranding *DocumentBranding,
+) ([]byte, error) {
+	if documentBranding != nil && documentBranding.LogoUrl != "" {
+		logoUrl := documentBranding.LogoUrl
+		documentBranding.LogoUrl = template.URL(r.logoDataUri(ctx, set))
+		defer func() { documentBranding.LogoUrl = logoUrl }()
+	}
+
+	html, err := r.html(ctx, set, name, data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r.pdf.Render(ctx.Request().Context(), html)
+}
+
+// logoDataUri returns the logo of the operating company as the data uri,
+// the document is rendered without the logo when it can't be read
+func (r *documentRenderer) logoDataUri(ctx echo.Context, set *branding.TemplateSet) string {
+	uri, err := r.store.LogoDataUri(ctx.Request().Context(), set)
+
+	if err != nil {
+		r.L().Error(
+			"operating company logo read failed",
+			logger.PairArgs("err", err.Error(), "operating_company_id", set.OperatingCompanyId),
+		)
+		return ""
+	}
+
+	return uri
+}
+
+// render responds with the html document
+func (r *documentRenderer) render(ctx echo.Context, set *branding.TemplateSet, name string, data interface{}) error {
+	out, err := r.html(ctx, set, name, data)
+
+	if err != nil {
+		return err
+	}
+
+	return ctx.HTMLBlob(http.StatusOK, out)
+}
+
+func (h *OperatingCompanyRoute) getBranding(ctx echo.Context) error {
+	req := &operatingCompanyIdRequest{Id: ctx.Param(common.RequestParameterId)}
+
+	if err := h.dispatch.Validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, common.GetValidationError(err))
+	}
+
+	set, err := h.branding.Get(ctx.Request().Context(), req.Id)
+
+	if err != nil {
+		return h.brandingErrorHandler(err, req.Id)
+	}
+
+	return ctx.JSON(http.StatusOK, set)
+}
+
+func (h *OperatingCompanyRoute) setBranding(ctx echo.Context) error {
+	req := &operatingCompanyBrandingRequest{}
+
+	if err := ctx.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorRequestParamsIncorrect)
+	}
+
+	req.Id = ctx.Param(common.RequestParameterId)
+
+	if err := h.dispatch.Validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, common.GetValidationError(err))
+	}
+
+	if _, err := h.getCompany(ctx, req.Id); err != nil {
+		return err
+	}
+
+	set, err := h.branding.Get(ctx.Request().Context(), req.Id)
+
+	if err != nil {
+		return h.brandingErrorHandler(err, req.Id)
+	}
+
+	set.LegalFooter = req.LegalFooter
+	set.Templates = make(map[string]string)
+
+	for name, source := range req.Templates {
+		if source != "" {
+			set.Templates[name] = source
+		}
+	}
+
+	if err = set.Validate(common.FuncMap); err != nil {
+		return h.brandingErrorHandler(err, req.Id)
+	}
+
+	authUser := common.ExtractUserContext(ctx)
+
+	if err = h.branding.Save(ctx.Request().Context(), set, authUser.Id); err != nil {
+		return h.brandingErrorHandler(err, req.Id)
+	}
+
+	return ctx.JSON(http.StatusOK, set)
+}
+
+func (h *OperatingCompanyRoute) uploadLogo(ctx echo.Context) error {
+	req := &operatingCompanyIdRequest{Id: ctx.Param(common.RequestParameterId)}
+
+	if err := h.dispatch.Validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, common.GetValidationError(err))
+	}
+
+	file, err := ctx.FormFile(common.RequestParameterFile)
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageFileNotFound)
+	}
+
+	if file.Size > branding.LogoMaxSize {
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageBrandingLogoMaxSize)
+	}
+
+	src, err := file.Open()
+
+	if err != nil {
+		h.L().Error("operating company logo open failed", logger.PairArgs("err", err.Error()))
+		return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorUnknown)
+	}
+
+	defer func() {
+		if err := src.Close(); err != nil {
+			return
+		}
+	}()
+
+	data, err := ioutil.ReadAll(src)
+
+	if err != nil {
+		h.L().Error("operating company logo read failed", logger.PairArgs("err", err.Error()))
+		return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorUnknown)
+	}
+
+	if _, err = h.getCompany(ctx, req.Id); err != nil {
+		return err
+	}
+
+	set, err := h.branding.Get(ctx.Request().Context(), req.Id)
+
+	if err != nil {
+		return h.brandingErrorHandler(err, req.Id)
+	}
+
+	authUser := common.ExtractUserContext(ctx)
+	err = h.branding.UploadLogo(
+		ctx.Request().Context(),
+		set,
+		http.DetectContentType(data),
+		int64(len(data)),
+		bytes.NewReader(data),
+		authUser.Id,
+	)
+
+	if err != nil {
+		return h.brandingErrorHandler(err, req.Id)
+	}
+
+	return ctx.JSON(http.StatusOK, set)
+}
+
+func (h *OperatingCompanyRoute) getLogo(ctx echo.Context) error {
+	req := &operatingCompanyIdRequest{Id: ctx.Param(common.RequestParameterId)}
+
+	if err := h.dispatch.Validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, common.GetValidationError(err))
+	}
+
+	set, err := h.branding.Get(ctx.Request().Context(), req.Id)
+
+	if err != nil {
+		return h.brandingErrorHandler(err, req.Id)
+	}
+
+	filePath, err := h.branding.DownloadLogo(ctx.Request().Context(), set)
+
+	if err != nil {
+		return h.brandingErrorHandler(err, req.Id)
+	}
+
+	defer storage.Remove(filePath)
+
+	ctx.Response().Header().Set(echo.HeaderContentType, set.Logo.ContentType)
+
+	return ctx.File(filePath)
+}
+
+func (h *OperatingCompanyRoute) getBrandingPreview(ctx echo.Context) error {
+	return h.preview(ctx, false)
+}
+
+func (h *OperatingCompanyRoute) postBrandingPreview(ctx echo.Context) error {
+	return h.preview(ctx, true)
+}
+
+// preview renders the template with the sample data, the draft templates of the request body replace the saved ones
+func (h *OperatingCompanyRoute) preview(ctx echo.Context, draft bool) error {
+	req := &operatingCompanyBrandingPreviewRequest{}
+
+	if draft {
+		if err := ctx.Bind(req); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, common.ErrorRequestParamsIncorrect)
+		}
+	}
+
+	req.Id = ctx.Param(common.RequestParameterId)
+	req.Template = ctx.Param(common.RequestParameterTemplate)
+
+	if err := h.dispatch.Validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, common.GetValidationError(err))
+	}
+
+	company, err := h.getCompany(ctx, req.Id)
+
+	if err != nil {
+		return err
+	}
+
+	set, err := h.branding.Get(ctx.Request().Context(), req.Id)
+
+	if err != nil {
+		return h.brandingErrorHandler(err, req.Id)
+	}
+
+	if req.LegalFooter != nil {
+		set.LegalFooter = *req.LegalFooter
+	}
+
+	for name, source := range req.Templates {
+		set.Templates[name] = source
+	}
+
+	if err = set.Validate(common.FuncMap); err != nil {
+		return h.brandingErrorHandler(err, req.Id)
+	}
+
+	data := getSampleDocument(req.Template, getDocumentBranding(h.cfg, company, set))
+
+	if !set.HasTemplate(req.Template) {
+		return ctx.Render(http.StatusOK, req.Template+documentTemplateExtension, data)
+	}
+
+	out, err := set.Render(req.Template, data, common.FuncMap)
+
+	if err != nil {
+		return h.brandingErrorHandler(err, req.Id)
+	}
+
+	return ctx.HTMLBlob(http.StatusOK, out)
+}
+
+func (h *OperatingCompanyRoute) getCompany(ctx echo.Context, id string) (*billing.OperatingCompany, error) {
+	req := &grpc.GetOperatingCompanyRequest{Id: id}
+	res, err := h.dispatch.Services.Billing.GetOperatingCompany(ctx.Request().Context(), req)
+
+	if err != nil {
+		common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "GetOperatingCompany", req)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, common.ErrorUnknown)
+	}
+
+	if res.Status != pkg.ResponseStatusOk {
+		return nil, echo.NewHTTPError(int(res.Status), res.Message)
+	}
+
+	return res.Company, nil
+}
+
+func (h *OperatingCompanyRoute) brandingErrorHandler(err error, operatingCompanyId string) *echo.HTTPError {
+	switch err {
+	case branding.ErrorTemplateUnknown:
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageBrandingTemplateUnknown)
+	case branding.ErrorTemplateInvalid:
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageBrandingTemplateInvalid)
+	case branding.ErrorTemplateTooLong:
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageBrandingTemplateTooLong)
+	case branding.ErrorTemplateRenderError:
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageBrandingRenderFailed)
+	case branding.ErrorLegalFooterTooLong:
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageBrandingLegalFooterTooLong)
+	case branding.ErrorLogoContentType:
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageBrandingLogoContentType)
+	case branding.ErrorLogoMaxSize:
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageBrandingLogoMaxSize)
+	case branding.ErrorLogoNotFound:
+		return echo.NewHTTPError(http.StatusNotFound, common.ErrorMessageBrandingLogoNotFound)
+	}
+
+	h.L().Error(
+		"operating company branding storage call failed",
+		logger.PairArgs("err", err.Error(), "operating_company_id", operatingCompanyId),
+	)
+
+	return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessageBrandingStorageFailed)
+}
+
+// getDocumentBranding returns the branding of the document, the logo link is absolute as the email clients
+// load it outside of the request. The pdf documents get the logo inlined instead, see pdfDocument.
+func getDocumentBranding(
+	cfg common.Config,
+	company *billing.OperatingCompany,
+	set *branding.TemplateSet,
+) *DocumentBranding {
+	documentBranding := &DocumentBranding{OperatingCompany: company, LegalFooter: set.LegalFooter}
+
+	if set.Logo != nil {
+		documentBranding.LogoUrl = template.URL(fmt.Sprintf(operatingCompanyLogoUrlMask, cfg.PublicUrl, company.Id))
+	}
+
+	return documentBranding
+}
+
+// getSampleDocument returns the document filled with the sample data for the template preview
+func getSampleDocument(name string, documentBranding *DocumentBranding) interface{} {
+	if name == branding.TemplateReceipt {
+		return &ReceiptDocument{
+			Receipt: &billing.OrderReceipt{
+				TotalPrice:      "10.00 USD",
+				TransactionId:   "00000000-0000-0000-0000-000000000000",
+				TransactionDate: "January 1, 2020",
+				ProjectName:     "Sample Project",
+				MerchantName:    "Sample Merchant",
+				PlatformName:    "Steam",
+				PaymentPartner:  documentBranding.OperatingCompany.Name,
+				Items: []*billing.OrderReceiptItem{
+					{Name: "Sample Product", Price: "10.00 USD"},
+				},
+			},
+			Labels:   receipt.GetLabels(receipt.LanguageDefault),
+			Branding: documentBranding,
+		}
+	}
+
+	return &AgreementDocument{
+		AgreementNumber: "SAMPLE-0001",
+		Merchant: &billing.Merchant{
+			Company: &billing.MerchantCompanyInfo{
+				Name:               "Sample Merchant",
+				Country:            "US",
+				State:              "CA",
+				Zip:                "94105",
+				City:               "San Francisco",
+				Address:            "1 Sample Street",
+				RegistrationNumber: "0000000000",
+				TaxId:              "0000000000",
+			},
+			Banking: &billing.MerchantBanking{
+				Currency:      "USD",
+				Name:          "Sample Bank",
+				Address:       "1 Bank Street",
+				AccountNumber: "0000000000",
+				Swift:         "SMPLUS00",
+			},
+			Contacts: &billing.MerchantContact{
+				Authorized: &billing.MerchantContactAuthorized{
+					Name:     "John Doe",
+					Email:    "john.doe@example.com",
+					Phone:    "+10000000000",
+					Position: "CEO",
+				},
+			},
+		},
+		Branding: documentBranding,
+	}
+}
@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/branding"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/mock"
+	"github.com/paysuper/paysuper-management-api/internal/paymentform"
+	"github.com/paysuper/paysuper-management-api/internal/pdf"
+	"github.com/paysuper/paysuper-management-api/internal/receipt"
+	"github.com/paysuper/paysuper-management-api/internal/test"
+	"github.com/paysuper/paysuper-management-api/internal/theme"
+	"github.com/paysuper/paysuper-management-api/internal/timeline"
+	mock2 "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	operatingCompanyBrandingTestCompanyId  = "5dc3f6c5ad8b8c0001b1e2c1"
+	operatingCompanyBrandingTestMerchantId = "5dc3f6c5ad8b8c0001b1e2c2"
+)
+
+var operatingCompanyBrandingTestLogo = append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...)
+
+type OperatingCompanyBrandingTestSuite struct {
+	suite.Suite
+	router      *OperatingCompanyRoute
+	orderRouter *OrderRoute
+	caller      *test.EchoReqResCaller
+	billing     *mocks.BillingService
+	files       map[string][]byte
+	pdfDir      string
+}
+
+func Test_OperatingCompanyBranding(t *testing.T) {
+	suite.Run(t, new(OperatingCompanyBrandingTestSuite))
+}
+
+func (suite *OperatingCompanyBrandingTestSuite) SetupTest() {
+	user := &common.AuthUser{
+		Id: "ffffffffffffffffffffffff",
+	}
+
+	suite.files = make(map[string][]byte)
+	suite.billing = &mocks.BillingService{}
+	suite.billing.On("GetOperatingCompany", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetOperatingCompanyResponse{
+			Status:  pkg.ResponseStatusOk,
+			Company: &billing.OperatingCompany{Id: operatingCompanyBrandingTestCompanyId, Name: "Operating Company"},
+		}, nil)
+	suite.billing.On("OrderReceipt", mock2.Anything, mock2.Anything).
+		Return(&grpc.OrderReceiptResponse{
+			Status:  pkg.ResponseStatusOk,
+			Receipt: &billing.OrderReceipt{ProjectName: "Receipt Project"},
+		}, nil)
+	suite.billing.On("GetOrderPublic", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetOrderPublicResponse{
+			Status: pkg.ResponseStatusOk,
+			Item:   &billing.OrderViewPublic{MerchantId: operatingCompanyBrandingTestMerchantId},
+		}, nil)
+	suite.billing.On("GetMerchantBy", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetMerchantResponse{
+			Status: pkg.ResponseStatusOk,
+			Item: &billing.Merchant{
+				Id:                 operatingCompanyBrandingTestMerchantId,
+				OperatingCompanyId: operatingCompanyBrandingTestCompanyId,
+				S3AgreementName:    "agreement_" + operatingCompanyBrandingTestMerchantId + ".pdf",
+				AgreementNumber:    "AGR-0001",
+				Company:            &billing.MerchantCompanyInfo{Name: "Branded Merchant"},
+				Banking:            &billing.MerchantBanking{Currency: "USD"},
+				Contacts:           &billing.MerchantContact{Authorized: &billing.MerchantContactAuthorized{Name: "John Doe"}},
+			},
+		}, nil)
+
+	// the pdf renderer prints the html back, so the rendered document is checked without wkhtmltopdf
+	var err error
+	suite.pdfDir, err = ioutil.TempDir("", "pdf")
+	require.NoError(suite.T(), err)
+	pdfBinary := filepath.Join(suite.pdfDir, "wkhtmltopdf")
+	require.NoError(suite.T(), ioutil.WriteFile(pdfBinary, []byte("#!/bin/sh\necho \"%PDF-1.4\"; cat\n"), 0755))
+
+	var e error
+	settings := test.DefaultSettings()
+	srv := common.Services{
+		Billing: suite.billing,
+	}
+	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
+		mw.Pre(test.PreAuthUserMiddleware(user))
+		awsManager := mock.NewAwsManagerFilesMock(suite.files)
+		brandingStore := branding.NewStore(awsManager)
+		suite.router = NewOperatingCompanyRoute(set.HandlerSet, brandingStore, set.GlobalConfig)
+		suite.orderRouter = NewOrderRoute(set.HandlerSet, timeline.NewJournal(awsManager), brandingStore, receipt.NewCache(awsManager), paymentform.NewTokens(awsManager), theme.NewStore(awsManager, projectThemeCacheTtl), set.GlobalConfig)
+		cfg := *set.GlobalConfig
+		cfg.PdfRendererBinary = pdfBinary
+		bankDirectory, err := newBankDirectory(set.Initial, &cfg)
+		if err != nil {
+			panic(err)
+		}
+		return common.Handlers{
+			suite.router,
+			suite.orderRouter,
+			NewOnboardingRoute(set.HandlerSet, bankDirectory, mock.NewAwsManagerFilesMock(map[string][]byte{}), brandingStore, &cfg),
+		}
+	})
+	if e != nil {
+		panic(e)
+	}
+}
+
+func (suite *OperatingCompanyBrandingTestSuite) TearDownTest() {
+	_ = os.RemoveAll(suite.pdfDir)
+}
+
+func (suite *OperatingCompanyBrandingTestSuite) setBranding(body string) (*branding.TemplateSet, error) {
+	res, err := suite.caller.Builder().
+		Method(http.MethodPut).
+		Params(":"+common.RequestParameterId, operatingCompanyBrandingTestCompanyId).
+		Path(common.SystemUserGroupPath + operatingCompanyIdBrandingPath).
+		Init(test.ReqInitJSON()).
+		BodyString(body).
+		Exec(suite.T())
+
+	if err != nil {
+		return nil, err
+	}
+
+	require.Equal(suite.T(), http.StatusOK, res.Code)
+
+	set := &branding.TemplateSet{}
+	require.NoError(suite.T(), json.Unmarshal(res.Body.Bytes(), set))
+
+	return set, nil
+}
+
+func (suite *OperatingCompanyBrandingTestSuite) uploadLogo(content []byte) (*branding.TemplateSet, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile(common.RequestParameterFile, "logo")
+	require.NoError(suite.T(), err)
+	_, err = part.Write(content)
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), writer.Close())
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Params(":"+common.RequestParameterId, operatingCompanyBrandingTestCompanyId).
+		Path(common.SystemUserGroupPath + operatingCompanyIdBrandingLogoPath).
+		Init(func(request *http.Request, middleware test.Middleware) {
+			request.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+		}).
+		Body(body).
+		Exec(suite.T())
+
+	if err != nil {
+		return nil, err
+	}
+
+	require.Equal(suite.T(), http.StatusOK, res.Code)
+
+	set := &branding.TemplateSet{}
+	require.NoError(suite.T(), json.Unmarshal(res.Body.Bytes(), set))
+
+	return set, nil
+}
+
+func (suite *OperatingCompanyBrandingTestSuite) TestOperatingCompanyBranding_Get_Empty() {
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterId, operatingCompanyBrandingTestCompanyId).
+		Path(common.SystemUserGroupPath + operatingCompanyIdBrandingPath).
+		Exec(suite.T())
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), http.StatusOK, res.Code)
+
+	set := &branding.TemplateSet{}
+	require.NoError(suite.T(), json.Unmarshal(res.Body.Bytes(), set))
+	require.Equal(suite.T(), operatingCompanyBrandingTestCompanyId, set.OperatingCompanyId)
+	require.Empty(suite.T(), set.Templates)
+	require.Nil(suite.T(), set.Logo)
+}
+
+func (suite *OperatingCompanyBrandingTestSuite) TestOperatingCompanyBranding_Set_Ok() {
+	set, err := suite.setBranding(`{"legal_footer": "Legal footer", "templates": {"receipt": "<p>{{ .Receipt.ProjectName }}</p>"}}`)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), "Legal footer", set.LegalFooter)
+	require.True(suite.T(), set.HasTemplate(branding.TemplateReceipt))
+	require.False(suite.T(), set.HasTemplate(branding.TemplateAgreement))
+	require.Equal(suite.T(), "ffffffffffffffffffffffff", set.UpdatedBy)
+	require.NotEmpty(suite.T(), suite.files[fmt.Sprintf("operating_companies/%s/branding.json", operatingCompanyBrandingTestCompanyId)])
+}
+
+func (suite *OperatingCompanyBrandingTestSuite) TestOperatingCompanyBranding_Set_TemplateInvalid_Error() {
+	_, err := suite.setBranding(`{"templates": {"receipt": "<p>{{ .Receipt.ProjectName </p>"}}`)
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), http.StatusBadRequest, httpErr.Code)
+	require.Equal(suite.T(), common.ErrorMessageBrandingTemplateInvalid, httpErr.Message)
+	require.Empty(suite.T(), suite.files)
+}
+
+func (suite *OperatingCompanyBrandingTestSuite) TestOperatingCompanyBranding_Set_TemplateUnknown_Error() {
+	_, err := suite.setBranding(`{"templates": {"invoice": "<p>invoice</p>"}}`)
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), http.StatusBadRequest, httpErr.Code)
+	require.Equal(suite.T(), common.ErrorMessageBrandingTemplateUnknown, httpErr.Message)
+}
+
+func (suite *OperatingCompanyBrandingTestSuite) TestOperatingCompanyBranding_Set_CompanyNotFound_Error() {
+	suite.billing.ExpectedCalls = nil
+	suite.billing.On("GetOperatingCompany", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetOperatingCompanyResponse{Status: pkg.ResponseStatusNotFound, Message: mock.SomeError}, nil)
+
+	_, err := suite.setBranding(`{"legal_footer": "Legal footer"}`)
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), http.StatusNotFound, httpErr.Code)
+	require.Empty(suite.T(), suite.files)
+}
+
+func (suite *OperatingCompanyBrandingTestSuite) TestOperatingCompanyBranding_Logo_Ok() {
+	set, err := suite.uploadLogo(operatingCompanyBrandingTestLogo)
+	require.NoError(suite.T(), err)
+	require.NotNil(suite.T(), set.Logo)
+	require.Equal(suite.T(), "image/png", set.Logo.ContentType)
+	require.EqualValues(suite.T(), len(operatingCompanyBrandingTestLogo), set.Logo.Size)
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterId, operatingCompanyBrandingTestCompanyId).
+		Path(common.NoAuthGroupPath + operatingCompanyIdLogoPath).
+		Exec(suite.T())
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), http.StatusOK, res.Code)
+	require.Equal(suite.T(), "image/png", res.Header().Get(echo.HeaderContentType))
+	require.Equal(suite.T(), operatingCompanyBrandingTestLogo, res.Body.Bytes())
+}
+
+func (suite *OperatingCompanyBrandingTestSuite) TestOperatingCompanyBranding_Logo_ContentType_Error() {
+	_, err := suite.uploadLogo([]byte("plain text logo"))
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), http.StatusBadRequest, httpErr.Code)
+	require.Equal(suite.T(), common.ErrorMessageBrandingLogoContentType, httpErr.Message)
+	require.Empty(suite.T(), suite.files)
+}
+
+func (suite *OperatingCompanyBrandingTestSuite) TestOperatingCompanyBranding_Logo_NotFound_Error() {
+	_, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterId, operatingCompanyBrandingTestCompanyId).
+		Path(common.NoAuthGroupPath + operatingCompanyIdLogoPath).
+		Exec(suite.T())
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), http.StatusNotFound, httpErr.Code)
+	require.Equal(suite.T(), common.ErrorMessageBrandingLogoNotFound, httpErr.Message)
+}
+
+func (suite *OperatingCompanyBrandingTestSuite) TestOperatingCompanyBranding_Preview_Draft() {
+	_, err := suite.uploadLogo(operatingCompanyBrandingTestLogo)
+	require.NoError(suite.T(), err)
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Params(
+			":"+common.RequestParameterId, operatingCompanyBrandingTestCompanyId,
+			":"+common.RequestParameterTemplate, branding.TemplateAgreement,
+		).
+		Path(common.SystemUserGroupPath + operatingCompanyIdBrandingPreviewPath).
+		Init(test.ReqInitJSON()).
+		BodyString(`{"legal_footer": "Draft footer", "templates": {"agreement": "<p>{{ .Merchant.Company.Name }}|{{ .Branding.OperatingCompany.Name }}|{{ .Branding.LegalFooter }}|{{ .Branding.LogoUrl }}</p>"}}`).
+		Exec(suite.T())
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), http.StatusOK, res.Code)
+	require.Contains(suite.T(), res.Body.String(), "Sample Merchant|Operating Company|Draft footer|")
+	require.Contains(suite.T(), res.Body.String(), "http://localhost/api/v1/operating_company/"+operatingCompanyBrandingTestCompanyId+"/logo")
+
+	// the draft isn't saved
+	set, err := suite.router.branding.Get(context.Background(), operatingCompanyBrandingTestCompanyId)
+	require.NoError(suite.T(), err)
+	require.Empty(suite.T(), set.LegalFooter)
+	require.False(suite.T(), set.HasTemplate(branding.TemplateAgreement))
+}
+
+func (suite *OperatingCompanyBrandingTestSuite) TestOperatingCompanyBranding_AgreementDocument_Branded() {
+	_, err := suite.setBranding(`{"legal_footer": "Legal footer", "templates": {"agreement": "<p>{{ .Merchant.Company.Name }}|{{ .Branding.LegalFooter }}|{{ .Branding.LogoUrl }}</p>"}}`)
+	require.NoError(suite.T(), err)
+	_, err = suite.uploadLogo(operatingCompanyBrandingTestLogo)
+	require.NoError(suite.T(), err)
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterMerchantId, operatingCompanyBrandingTestMerchantId).
+		Path(common.SystemUserGroupPath + merchantsIdAgreementDocumentPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), http.StatusOK, res.Code)
+	require.Equal(suite.T(), pdf.MimeType, res.Header().Get(echo.HeaderContentType))
+	require.Contains(suite.T(), res.Body.String(), "%PDF-1.4")
+	require.Contains(
+		suite.T(),
+		res.Body.String(),
+		"Branded Merchant|Legal footer|data:image/png;base64,"+base64.StdEncoding.EncodeToString(operatingCompanyBrandingTestLogo),
+	)
+	require.NotContains(suite.T(), res.Body.String(), "http://localhost/api/v1/operating_company/")
+}
+
+func (suite *OperatingCompanyBrandingTestSuite) TestOperatingCompanyBranding_Preview_RenderFailed_Error() {
+	_, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Params(
+			":"+common.RequestParameterId, operatingCompanyBrandingTestCompanyId,
+			":"+common.RequestParameterTemplate, branding.TemplateReceipt,
+		).
+		Path(common.SystemUserGroupPath + operatingCompanyIdBrandingPreviewPath).
+		Init(test.ReqInitJSON()).
+		BodyString(`{"templates": {"receipt": "<p>{{ .Receipt.UnknownField }}</p>"}}`).
+		Exec(suite.T())
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), http.StatusBadRequest, httpErr.Code)
+	require.Equal(suite.T(), common.ErrorMessageBrandingRenderFailed, httpErr.Message)
+}
+
+func (suite *OperatingCompanyBrandingTestSuite) TestOperatingCompanyBranding_Preview_TemplateUnknown_Error() {
+	_, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(
+			":"+common.RequestParameterId, operatingCompanyBrandingTestCompanyId,
+			":"+common.RequestParameterTemplate, "invoice",
+		).
+		Path(common.SystemUserGroupPath + operatingCompanyIdBrandingPreviewPath).
+		Exec(suite.T())
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), http.StatusBadRequest, httpErr.Code)
+}
+
+func (suite *OperatingCompanyBrandingTestSuite) TestOperatingCompanyBranding_Receipt_Html() {
+	_, err := suite.setBranding(`{"legal_footer": "Legal footer", "templates": {"receipt": "<p>{{ .Receipt.ProjectName }}|{{ .Branding.LegalFooter }}</p>"}}`)
+	require.NoError(suite.T(), err)
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterReceiptId, uuid.New().String(), ":"+common.RequestParameterOrderId, uuid.New().String()).
+		Path(common.NoAuthGroupPath+orderReceiptPath).
+		SetQueryParam(documentFormatQueryParam, documentFormatHtml).
+		Exec(suite.T())
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), http.StatusOK, res.Code)
+	require.Equal(suite.T(), echo.MIMETextHTMLCharsetUTF8, res.Header().Get(echo.HeaderContentType))
+	require.Equal(suite.T(), "<p>Receipt Project|Legal footer</p>", res.Body.String())
+	suite.billing.AssertCalled(suite.T(), "GetMerchantBy", mock2.Anything, &grpc.GetMerchantByRequest{MerchantId: operatingCompanyBrandingTestMerchantId})
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/paysuper/paysuper-billing-server/pkg"
 	billMock "github.com/paysuper/paysuper-billing-server/pkg/mocks"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/branding"
 	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
 	"github.com/paysuper/paysuper-management-api/internal/mock"
 	"github.com/paysuper/paysuper-management-api/internal/test"
@@ -38,7 +39,7 @@ func (suite *OperatingCompanyTestSuite) SetupTest() {
 	}
 	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
 		mw.Pre(test.PreAuthUserMiddleware(user))
-		suite.router = NewOperatingCompanyRoute(set.HandlerSet, set.GlobalConfig)
+		suite.router = NewOperatingCompanyRoute(set.HandlerSet, branding.NewStore(mock.NewAwsManagerFilesMock(make(map[string][]byte))), set.GlobalConfig)
 		return common.Handlers{
 			suite.router,
 		}
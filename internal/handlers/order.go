@@ -2,17 +2,26 @@ package handlers
 
 import (
 	"context"
+	"fmt"
 	"github.com/ProtocolONE/go-core/v2/pkg/logger"
 	"github.com/ProtocolONE/go-core/v2/pkg/provider"
 	u "github.com/PuerkitoBio/purell"
-	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/paysuper/paysuper-billing-server/pkg"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/branding"
 	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
 	"github.com/paysuper/paysuper-management-api/internal/helpers"
+	"github.com/paysuper/paysuper-management-api/internal/mailer"
+	"github.com/paysuper/paysuper-management-api/internal/paymentform"
+	"github.com/paysuper/paysuper-management-api/internal/pdf"
+	"github.com/paysuper/paysuper-management-api/internal/receipt"
+	"github.com/paysuper/paysuper-management-api/internal/theme"
+	"github.com/paysuper/paysuper-management-api/internal/throttle"
+	"github.com/paysuper/paysuper-management-api/internal/timeline"
 	"net/http"
+	"net/url"
 	"time"
 )
 
@@ -33,6 +42,7 @@ const (
 	orderNotifyNewRegionPath = "/orders/:order_id/notify_new_region"
 	orderPlatformPath        = "/orders/:order_id/platform"
 	orderReceiptPath         = "/orders/receipt/:receipt_id/:order_id"
+	orderReceiptResendPath   = "/orders/receipt/:receipt_id/:order_id/resend"
 )
 
 const (
@@ -70,17 +80,62 @@ func (b *OrderListRefundsBinder) Bind(i interface{}, ctx echo.Context) error {
 }
 
 type OrderRoute struct {
-	dispatch common.HandlerSet
-	cfg      common.Config
+	dispatch        common.HandlerSet
+	journal         *timeline.Journal
+	documents       *documentRenderer
+	mailer          *mailer.Mailer
+	receiptThrottle *throttle.Throttle
+	receipts        *receipt.Cache
+	paymentForm     *paymentform.Builder
+	paymentTokens   *paymentform.Tokens
+	themes          *theme.Store
+	cfg             common.Config
 	provider.LMT
+
+	// the unsigned payment form requests are accepted until this time to migrate the issued form urls
+	paymentFormUnsignedUntil *time.Time
 }
 
-func NewOrderRoute(set common.HandlerSet, cfg *common.Config) *OrderRoute {
+func NewOrderRoute(
+	set common.HandlerSet,
+	journal *timeline.Journal,
+	brandingStore *branding.Store,
+	receipts *receipt.Cache,
+	paymentTokens *paymentform.Tokens,
+	themes *theme.Store,
+	cfg *common.Config,
+) *OrderRoute {
 	set.AwareSet.Logger = set.AwareSet.Logger.WithFields(logger.Fields{"router": "OrderRoute"})
+	unsignedUntil, err := parsePaymentFormUnsignedUntil(cfg.PaymentFormUrlUnsignedUntil)
+
+	if err != nil {
+		set.AwareSet.L().Error(
+			"payment form unsigned requests date is invalid, unsigned requests are rejected",
+			logger.PairArgs("err", err.Error(), "date", cfg.PaymentFormUrlUnsignedUntil),
+		)
+		unsignedUntil = &time.Time{}
+	}
+
 	return &OrderRoute{
-		dispatch: set,
-		LMT:      &set.AwareSet,
-		cfg:      *cfg,
+		dispatch:  set,
+		journal:   journal,
+		documents: newDocumentRenderer(set, brandingStore, *cfg),
+		mailer: mailer.NewMailer(mailer.Options{
+			Host:     cfg.SmtpHost,
+			Port:     cfg.SmtpPort,
+			Username: cfg.SmtpUser,
+			Password: cfg.SmtpPassword,
+			From:     cfg.SmtpFrom,
+		}),
+		receiptThrottle: throttle.New(cfg.ReceiptResendInterval, cfg.ReceiptResendDailyLimit, orderReceiptResendWindow),
+		receipts:        receipts,
+		paymentForm:     newPaymentFormUrlBuilder(cfg),
+		paymentTokens:   paymentTokens,
+		themes:          themes,
+		LMT:             &set.AwareSet,
+		cfg:             *cfg,
+
+		paymentFormUnsignedUntil: unsignedUntil,
 	}
 }
 
@@ -101,9 +156,11 @@ func (h *OrderRoute) Route(groups *common.Groups) {
 	groups.Common.POST(orderPlatformPath, h.changePlatform)
 
 	groups.Common.GET(orderReceiptPath, h.getReceipt)
+	groups.Common.POST(orderReceiptResendPath, h.resendReceipt)
 
 	groups.AuthUser.GET(orderPath, h.listOrdersPublic)
 	groups.AuthUser.GET(orderIdPath, h.getOrderPublic) // TODO: Need a test
+	groups.AuthUser.GET(orderTimelinePath, h.getTimeline)
 
 	groups.AuthUser.GET(orderRefundsPath, h.listRefunds)
 	groups.AuthUser.GET(orderRefundsIdsPath, h.getRefund)
@@ -141,11 +198,33 @@ func (h *OrderRoute) createFromFormData(ctx echo.Context) error {
 		return echo.NewHTTPError(int(orderResponse.Status), orderResponse.Message)
 	}
 
-	rUrl := "/order/" + orderResponse.Item.Id
+	// the form opened by the redirect is signed for the order, the unsigned form requests are rejected
+	query, err := h.paymentForm.Query(&paymentform.Params{OrderId: orderResponse.Item.Id}, time.Now())
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessagePaymentFormUrlParamsInvalid)
+	}
+
+	rUrl := "/order/" + orderResponse.Item.Id + "?" + query.Encode()
 
 	return ctx.Redirect(http.StatusFound, rUrl)
 }
 
+// bindPaymentToken binds the order created from the payment token to the token,
+// so the payment form url signed for the token opens the order
+func (h *OrderRoute) bindPaymentToken(ctx echo.Context, token, orderId string) error {
+	if token == "" {
+		return nil
+	}
+
+	if err := h.paymentTokens.Bind(ctx.Request().Context(), token, orderId); err != nil {
+		h.L().Error("payment form token bind failed", logger.PairArgs("err", err.Error(), "order_id", orderId))
+		return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessagePaymentFormTokenStorageFailed)
+	}
+
+	return nil
+}
+
 func (h *OrderRoute) recreateOrder(ctx echo.Context) error {
 	req := &grpc.OrderReCreateProcessRequest{}
 	if err := ctx.Bind(req); err != nil {
@@ -168,9 +247,15 @@ func (h *OrderRoute) recreateOrder(ctx echo.Context) error {
 	}
 
 	order := res.Item
+	formUrl, err := buildPaymentFormUrl(ctx, h.paymentForm, order.Uuid, "")
+
+	if err != nil {
+		return err
+	}
+
 	response := &CreateOrderJsonProjectResponse{
 		Id:             order.Uuid,
-		PaymentFormUrl: h.cfg.OrderInlineFormUrlMask + "?order_id=" + order.Uuid,
+		PaymentFormUrl: formUrl,
 	}
 
 	return ctx.JSON(http.StatusOK, response)
@@ -245,9 +330,19 @@ func (h *OrderRoute) createJson(ctx echo.Context) error {
 		order = orderResponse.Item
 	}
 
+	if err = h.bindPaymentToken(ctx, req.Token, order.Uuid); err != nil {
+		return err
+	}
+
+	formUrl, err := buildPaymentFormUrl(ctx, h.paymentForm, order.Uuid, "")
+
+	if err != nil {
+		return err
+	}
+
 	response := &CreateOrderJsonProjectResponse{
 		Id:             order.Uuid,
-		PaymentFormUrl: h.cfg.OrderInlineFormUrlMask + "?order_id=" + order.Uuid,
+		PaymentFormUrl: formUrl,
 	}
 
 	return ctx.JSON(http.StatusOK, response)
@@ -260,11 +355,24 @@ func (h *OrderRoute) getPaymentFormData(ctx echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorRequestParamsIncorrect)
 	}
 
+	formParams, err := h.verifyPaymentFormUrl(ctx, id)
+
+	if err != nil {
+		return err
+	}
+
+	locale := ctx.Request().Header.Get(common.HeaderAcceptLanguage)
+
+	// the locale of the signed form url takes precedence over the browser language
+	if formParams != nil && formParams.Locale != "" {
+		locale = formParams.Locale
+	}
+
 	req := &grpc.PaymentFormJsonDataRequest{
 		OrderId: id,
 		Scheme:  h.cfg.HttpScheme,
 		Host:    ctx.Request().Host,
-		Locale:  ctx.Request().Header.Get(common.HeaderAcceptLanguage),
+		Locale:  locale,
 		Ip:      ctx.RealIP(),
 		Referer: ctx.Request().Header.Get(common.HeaderReferer),
 		Cookie:  helpers.GetRequestCookie(ctx, common.CustomerTokenCookiesName),
@@ -284,7 +392,14 @@ func (h *OrderRoute) getPaymentFormData(ctx echo.Context) error {
 	h.dispatch.AwareSet.L().Info("Before set cookie", logger.WithPrettyFields(logger.Fields{"lifetime": h.cfg.CustomerTokenCookiesLifetime, "expire": expire}))
 	helpers.SetResponseCookie(ctx, common.CustomerTokenCookiesName, res.Cookie, h.cfg.CookieDomain, expire)
 
-	return ctx.JSON(http.StatusOK, res.Item)
+	return ctx.JSON(http.StatusOK, h.getPaymentFormTheme(ctx, res.Item))
+}
+
+// paylinkUtmParams are the query parameters of the paylink passed to the payment form
+var paylinkUtmParams = []string{
+	common.QueryParameterNameUtmSource,
+	common.QueryParameterNameUtmMedium,
+	common.QueryParameterNameUtmCampaign,
 }
 
 func (h *OrderRoute) getOrderForPaylink(ctx echo.Context) error {
@@ -325,9 +440,24 @@ func (h *OrderRoute) getOrderForPaylink(ctx echo.Context) error {
 		return echo.NewHTTPError(int(orderResponse.Status), orderResponse.Message)
 	}
 
-	qParams.Set("order_id", orderResponse.Item.Uuid)
+	inlineFormRedirectUrl, err := buildPaymentFormUrl(ctx, h.paymentForm, orderResponse.Item.Uuid, "")
+
+	if err != nil {
+		return err
+	}
+
+	// the utm parameters are passed to the form as is, they aren't signed
+	utm := url.Values{}
 
-	inlineFormRedirectUrl := h.cfg.OrderInlineFormUrlMask + "?" + qParams.Encode()
+	for _, key := range paylinkUtmParams {
+		if value := qParams.Get(key); value != "" {
+			utm.Set(key, value)
+		}
+	}
+
+	if len(utm) > 0 {
+		inlineFormRedirectUrl += "&" + utm.Encode()
+	}
 
 	inlineFormRedirectUrl, err = u.NormalizeURLString(inlineFormRedirectUrl, u.FlagsUsuallySafeGreedy|u.FlagRemoveDuplicateSlashes)
 	if err != nil {
@@ -413,10 +543,23 @@ func (h *OrderRoute) processCreatePayment(ctx echo.Context) error {
 		return h.dispatch.SrvCallHandler(req, err, pkg.ServiceName, "PaymentCreateProcess")
 	}
 
+	event := &timeline.Event{
+		Type:  timeline.EventPaymentAttempted,
+		Actor: timeline.ActorCustomer,
+		Details: map[string]string{
+			"payment_method_id": data[pkg.PaymentCreateFieldPaymentMethodId],
+			"status":            fmt.Sprintf("%d", res.Status),
+		},
+	}
+
 	if res.Status != pkg.ResponseStatusOk {
+		// the failed attempts are journaled too, but only for the orders known by the billing server
+		recordRejectedTimelineEvent(h.LMT, h.journal, h.dispatch.Services.Billing, data[pkg.PaymentCreateFieldOrderId], event)
 		return echo.NewHTTPError(int(res.Status), res.Message)
 	}
 
+	recordTimelineEvent(h.LMT, h.journal, data[pkg.PaymentCreateFieldOrderId], event)
+
 	body := map[string]interface{}{
 		"redirect_url":  res.RedirectUrl,
 		"need_redirect": res.NeedRedirect,
@@ -487,6 +630,12 @@ func (h *OrderRoute) replaceCode(ctx echo.Context) error {
 		return echo.NewHTTPError(int(res.Status), res.Message)
 	}
 
+	recordTimelineEvent(h.LMT, h.journal, req.OrderId, &timeline.Event{
+		Type:    timeline.EventCodeReplaced,
+		Actor:   common.ExtractUserContext(ctx).Id,
+		Details: map[string]string{"key_product_id": req.KeyProductId},
+	})
+
 	return ctx.JSON(http.StatusOK, res.Order)
 }
 
@@ -554,6 +703,12 @@ func (h *OrderRoute) changeLanguage(ctx echo.Context) error {
 		return echo.NewHTTPError(int(res.Status), res.Message)
 	}
 
+	recordTimelineEvent(h.LMT, h.journal, orderId, &timeline.Event{
+		Type:    timeline.EventLanguageChanged,
+		Actor:   timeline.ActorCustomer,
+		Details: map[string]string{"lang": req.Lang},
+	})
+
 	return ctx.JSON(http.StatusOK, res.Item)
 }
 
@@ -591,6 +746,12 @@ func (h *OrderRoute) changeCustomer(ctx echo.Context) error {
 		return echo.NewHTTPError(int(res.Status), res.Message)
 	}
 
+	recordTimelineEvent(h.LMT, h.journal, orderId, &timeline.Event{
+		Type:    timeline.EventCustomerChanged,
+		Actor:   timeline.ActorCustomer,
+		Details: map[string]string{"method_id": req.MethodId},
+	})
+
 	return ctx.JSON(http.StatusOK, res.Item)
 }
 
@@ -628,6 +789,12 @@ func (h *OrderRoute) processBillingAddress(ctx echo.Context) error {
 		return echo.NewHTTPError(int(res.Status), res.Message)
 	}
 
+	recordTimelineEvent(h.LMT, h.journal, orderId, &timeline.Event{
+		Type:    timeline.EventBillingAddressChanged,
+		Actor:   timeline.ActorCustomer,
+		Details: map[string]string{"country": req.Country, "zip": req.Zip},
+	})
+
 	expire := time.Now().AddDate(0, 0, 30)
 	h.dispatch.AwareSet.L().Info("Before set cookie", logger.WithPrettyFields(logger.Fields{"lifetime": h.cfg.CustomerTokenCookiesLifetime, "expire": expire}))
 	helpers.SetResponseCookie(ctx, common.CustomerTokenCookiesName, res.Cookie, h.cfg.CookieDomain, expire)
@@ -733,31 +900,49 @@ func (h *OrderRoute) changePlatform(ctx echo.Context) error {
 		return echo.NewHTTPError(int(res.Status), res.Message)
 	}
 
+	recordTimelineEvent(h.LMT, h.journal, orderId, &timeline.Event{
+		Type:    timeline.EventPlatformChanged,
+		Actor:   timeline.ActorCustomer,
+		Details: map[string]string{"platform": req.Platform},
+	})
+
 	return ctx.JSON(http.StatusOK, res.Item)
 }
 func (h *OrderRoute) getReceipt(ctx echo.Context) error {
-	orderId := ctx.Param(common.RequestParameterOrderId)
+	req, orderReceipt, err := h.getOrderReceipt(ctx)
 
-	if _, err := uuid.Parse(orderId); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorRequestParamsIncorrect)
+	if err != nil {
+		return err
 	}
 
-	receiptId := ctx.Param(common.RequestParameterReceiptId)
+	recordTimelineEvent(h.LMT, h.journal, req.OrderId, &timeline.Event{
+		Type:    timeline.EventReceiptViewed,
+		Actor:   timeline.ActorCustomer,
+		Details: map[string]string{orderReceiptDetailsId: req.ReceiptId},
+	})
 
-	if _, err := uuid.Parse(receiptId); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorRequestParamsIncorrect)
+	format := ctx.QueryParam(documentFormatQueryParam)
+
+	if format != documentFormatHtml && format != documentFormatPdf {
+		return ctx.JSON(http.StatusOK, orderReceipt)
 	}
 
-	req := &grpc.OrderReceiptRequest{OrderId: orderId, ReceiptId: receiptId}
-	res, err := h.dispatch.Services.Billing.OrderReceipt(ctx.Request().Context(), req)
+	document, set := h.getReceiptDocument(ctx, h.getReceiptOrder(ctx, req.OrderId), orderReceipt)
 
-	if err != nil {
-		return h.dispatch.SrvCallHandler(req, err, pkg.ServiceName, "OrderReceipt")
+	if format == documentFormatHtml {
+		return h.documents.render(ctx, set, branding.TemplateReceipt, document)
 	}
 
-	if res.Status != http.StatusOK {
-		return echo.NewHTTPError(int(res.Status), res.Message)
+	data, err := h.getReceiptPdf(ctx, req.ReceiptId, set, document)
+
+	if err != nil {
+		return err
 	}
 
-	return ctx.JSON(http.StatusOK, res.Receipt)
+	ctx.Response().Header().Set(
+		echo.HeaderContentDisposition,
+		"inline; filename="+fmt.Sprintf(orderReceiptFileMask, req.ReceiptId),
+	)
+
+	return ctx.Blob(http.StatusOK, pdf.MimeType, data)
 }
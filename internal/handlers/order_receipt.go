@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/ProtocolONE/go-core/v2/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/branding"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/mailer"
+	"github.com/paysuper/paysuper-management-api/internal/pdf"
+	"github.com/paysuper/paysuper-management-api/internal/receipt"
+	"github.com/paysuper/paysuper-management-api/internal/timeline"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	orderReceiptFileMask        = "receipt_%s.pdf"
+	orderReceiptSubjectMask     = "%s: %s"
+	orderReceiptResendWindow    = 24 * time.Hour
+	orderReceiptDetailsId       = "receipt_id"
+	orderReceiptDetailsLanguage = "language"
+)
+
+// resendReceipt sends the receipt email with the pdf attachment to the order's customer email.
+// The emails are throttled by the order to prevent the mailbox flooding. The throttle is kept in memory,
+// so every replica of the api applies the limits on its own.
+func (h *OrderRoute) resendReceipt(ctx echo.Context) error {
+	req, orderReceipt, err := h.getOrderReceipt(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	order := h.getReceiptOrder(ctx, req.OrderId)
+
+	if order == nil || order.User == nil || order.User.Email == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageReceiptEmailNotFound)
+	}
+
+	if ok, wait := h.receiptThrottle.Allow(req.OrderId, time.Now()); !ok {
+		ctx.Response().Header().Set(common.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		return echo.NewHTTPError(http.StatusTooManyRequests, common.ErrorMessageReceiptResendThrottled)
+	}
+
+	document, set := h.getReceiptDocument(ctx, order, orderReceipt)
+	html, data, err := h.renderReceiptPdf(ctx, set, document)
+
+	if err != nil {
+		return err
+	}
+
+	subject := document.Labels.EmailSubject
+
+	if orderReceipt.ProjectName != "" {
+		subject = fmt.Sprintf(orderReceiptSubjectMask, subject, orderReceipt.ProjectName)
+	}
+
+	msg := &mailer.Message{
+		To:      order.User.Email,
+		Subject: subject,
+		Html:    string(html),
+		Attachments: []*mailer.Attachment{
+			{
+				Name:        fmt.Sprintf(orderReceiptFileMask, req.ReceiptId),
+				ContentType: pdf.MimeType,
+				Data:        data,
+			},
+		},
+	}
+
+	if err = h.mailer.Send(ctx.Request().Context(), msg); err != nil {
+		h.L().Error(
+			"receipt email send failed",
+			logger.PairArgs("err", err.Error(), "order_id", req.OrderId, "receipt_id", req.ReceiptId),
+		)
+		return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessageReceiptEmailSendFailed)
+	}
+
+	recordTimelineEvent(h.LMT, h.journal, req.OrderId, &timeline.Event{
+		Type:  timeline.EventReceiptSent,
+		Actor: timeline.ActorCustomer,
+		Details: map[string]string{
+			orderReceiptDetailsId:       req.ReceiptId,
+			orderReceiptDetailsLanguage: document.Labels.Language,
+		},
+	})
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// getOrderReceipt returns the receipt of the order by the request parameters
+func (h *OrderRoute) getOrderReceipt(ctx echo.Context) (*grpc.OrderReceiptRequest, *billing.OrderReceipt, error) {
+	req := &grpc.OrderReceiptRequest{
+		OrderId:   ctx.Param(common.RequestParameterOrderId),
+		ReceiptId: ctx.Param(common.RequestParameterReceiptId),
+	}
+
+	if _, err := uuid.Parse(req.OrderId); err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest, common.ErrorRequestParamsIncorrect)
+	}
+
+	if _, err := uuid.Parse(req.ReceiptId); err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest, common.ErrorRequestParamsIncorrect)
+	}
+
+	res, err := h.dispatch.Services.Billing.OrderReceipt(ctx.Request().Context(), req)
+
+	if err != nil {
+		return nil, nil, h.dispatch.SrvCallHandler(req, err, pkg.ServiceName, "OrderReceipt")
+	}
+
+	if res.Status != http.StatusOK {
+		return nil, nil, echo.NewHTTPError(int(res.Status), res.Message)
+	}
+
+	return req, res.Receipt, nil
+}
+
+// getReceiptOrder returns the order of the receipt, the failed lookup returns nil
+func (h *OrderRoute) getReceiptOrder(ctx echo.Context, orderId string) *billing.OrderViewPublic {
+	req := &grpc.GetOrderRequest{OrderId: orderId}
+	res, err := h.dispatch.Services.Billing.GetOrderPublic(ctx.Request().Context(), req)
+
+	if err != nil {
+		common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "GetOrderPublic", req)
+		return nil
+	}
+
+	if res.Status != pkg.ResponseStatusOk {
+		h.L().Error(
+			"order of receipt not found",
+			logger.PairArgs("order_id", orderId, "status", res.Status, "message", res.Message),
+		)
+		return nil
+	}
+
+	return res.Item
+}
+
+// getReceiptDocument returns the receipt in the order language with the branding of the merchant's operating company
+func (h *OrderRoute) getReceiptDocument(
+	ctx echo.Context,
+	order *billing.OrderViewPublic,
+	orderReceipt *billing.OrderReceipt,
+) (*ReceiptDocument, *branding.TemplateSet) {
+	merchantId := ""
+	locale := ""
+
+	if order != nil {
+		merchantId = order.MerchantId
+
+		if order.User != nil {
+			locale = order.User.Locale
+		}
+	}
+
+	set, documentBranding := h.documents.branding(ctx, h.documents.merchantOperatingCompanyId(ctx, merchantId))
+	document := &ReceiptDocument{
+		Receipt:  orderReceipt,
+		Labels:   receipt.GetLabels(locale),
+		Branding: documentBranding,
+	}
+
+	return document, set
+}
+
+// getReceiptPdf returns the pdf of the public receipt link from the cache shared by the replicas,
+// the receipt is rendered when it isn't cached yet. The cache failures don't fail the request.
+func (h *OrderRoute) getReceiptPdf(
+	ctx echo.Context,
+	receiptId string,
+	set *branding.TemplateSet,
+	document *ReceiptDocument,
+) ([]byte, error) {
+	fileName := receipt.PdfFileName(receiptId, document.Labels.Language, set.Version())
+	data, found, err := h.receipts.Get(ctx.Request().Context(), fileName)
+
+	if err != nil {
+		h.L().Error("receipt pdf cache read failed", logger.PairArgs("err", err.Error(), "receipt_id", receiptId))
+	}
+
+	if found {
+		return data, nil
+	}
+
+	_, data, err = h.renderReceiptPdf(ctx, set, document)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = h.receipts.Put(ctx.Request().Context(), fileName, data); err != nil {
+		h.L().Error("receipt pdf cache write failed", logger.PairArgs("err", err.Error(), "receipt_id", receiptId))
+	}
+
+	return data, nil
+}
+
+// renderReceiptPdf returns the html and the pdf of the receipt.
+// The html links the logo for the email clients, the pdf has the logo inlined.
+func (h *OrderRoute) renderReceiptPdf(
+	ctx echo.Context,
+	set *branding.TemplateSet,
+	document *ReceiptDocument,
+) ([]byte, []byte, error) {
+	html, err := h.documents.html(ctx, set, branding.TemplateReceipt, document)
+
+	if err != nil {
+		h.L().Error("receipt html render failed", logger.PairArgs("err", err.Error()))
+		return nil, nil, echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessageReceiptRenderFailed)
+	}
+
+	data, err := h.documents.pdfDocument(ctx, set, branding.TemplateReceipt, document, document.Branding)
+
+	if err != nil {
+		h.L().Error("receipt pdf render failed", logger.PairArgs("err", err.Error()))
+		return nil, nil, echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessageReceiptRenderFailed)
+	}
+
+	return html, data, nil
+}
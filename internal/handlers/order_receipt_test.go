@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/branding"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/mailer"
+	"github.com/paysuper/paysuper-management-api/internal/mock"
+	"github.com/paysuper/paysuper-management-api/internal/paymentform"
+	"github.com/paysuper/paysuper-management-api/internal/pdf"
+	"github.com/paysuper/paysuper-management-api/internal/receipt"
+	"github.com/paysuper/paysuper-management-api/internal/test"
+	"github.com/paysuper/paysuper-management-api/internal/theme"
+	"github.com/paysuper/paysuper-management-api/internal/timeline"
+	mock2 "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	orderReceiptTestCompanyId  = "5dc3f6c5ad8b8c0001b1e2d1"
+	orderReceiptTestMerchantId = "5dc3f6c5ad8b8c0001b1e2d2"
+	orderReceiptTestEmail      = "customer@paysuper.test"
+	orderReceiptTestTemplate   = "<p>{{ .Labels.Title }}|{{ .Receipt.ProjectName }}</p>"
+)
+
+type OrderReceiptTestSuite struct {
+	suite.Suite
+	router    *OrderRoute
+	caller    *test.EchoReqResCaller
+	billing   *mocks.BillingService
+	smtp      *mailer.LocalServer
+	pdfDir    string
+	files     map[string][]byte
+	orderId   string
+	receiptId string
+}
+
+func Test_OrderReceipt(t *testing.T) {
+	suite.Run(t, new(OrderReceiptTestSuite))
+}
+
+func (suite *OrderReceiptTestSuite) SetupTest() {
+	user := &common.AuthUser{
+		Id: "ffffffffffffffffffffffff",
+	}
+
+	var e error
+	suite.smtp, e = mailer.NewLocalServer()
+	require.NoError(suite.T(), e)
+
+	// the pdf renderer stand-in prints the pdf header and the html it gets
+	suite.pdfDir, e = ioutil.TempDir("", "receipt")
+	require.NoError(suite.T(), e)
+	binary := filepath.Join(suite.pdfDir, "wkhtmltopdf")
+	require.NoError(suite.T(), ioutil.WriteFile(binary, []byte("#!/bin/sh\necho \"%PDF-1.4\"\ncat\n"), 0755))
+
+	suite.files = make(map[string][]byte)
+	suite.orderId = uuid.New().String()
+	suite.receiptId = uuid.New().String()
+
+	suite.billing = &mocks.BillingService{}
+	suite.billing.On("OrderReceipt", mock2.Anything, mock2.Anything).
+		Return(&grpc.OrderReceiptResponse{
+			Status:  pkg.ResponseStatusOk,
+			Receipt: &billing.OrderReceipt{ProjectName: "Project"},
+		}, nil)
+	suite.billing.On("GetOrderPublic", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetOrderPublicResponse{
+			Status: pkg.ResponseStatusOk,
+			Item: &billing.OrderViewPublic{
+				Uuid:       suite.orderId,
+				MerchantId: orderReceiptTestMerchantId,
+				User:       &billing.OrderUser{Email: orderReceiptTestEmail, Locale: "ru-RU"},
+			},
+		}, nil)
+	suite.billing.On("GetMerchantBy", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetMerchantResponse{
+			Status: pkg.ResponseStatusOk,
+			Item:   &billing.Merchant{Id: orderReceiptTestMerchantId, OperatingCompanyId: orderReceiptTestCompanyId},
+		}, nil)
+	suite.billing.On("GetOperatingCompany", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetOperatingCompanyResponse{
+			Status:  pkg.ResponseStatusOk,
+			Company: &billing.OperatingCompany{Id: orderReceiptTestCompanyId, Name: "Operating Company"},
+		}, nil)
+
+	awsManager := mock.NewAwsManagerFilesMock(suite.files)
+	brandingStore := branding.NewStore(awsManager)
+	set := branding.NewTemplateSet(orderReceiptTestCompanyId)
+	set.Templates[branding.TemplateReceipt] = orderReceiptTestTemplate
+	require.NoError(suite.T(), brandingStore.Save(context.Background(), set, user.Id))
+
+	smtpOptions := suite.smtp.Options("noreply@paysuper.test")
+	settings := test.DefaultSettings()
+	global := settings["dispatcher"].(map[string]interface{})["global"].(map[string]interface{})
+	global["smtpHost"] = smtpOptions.Host
+	global["smtpPort"] = smtpOptions.Port
+	global["pdfRendererBinary"] = binary
+
+	srv := common.Services{
+		Billing: suite.billing,
+	}
+	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
+		mw.Pre(test.PreAuthUserMiddleware(user))
+		suite.router = NewOrderRoute(set.HandlerSet, timeline.NewJournal(awsManager), brandingStore, receipt.NewCache(awsManager), paymentform.NewTokens(awsManager), theme.NewStore(awsManager, projectThemeCacheTtl), set.GlobalConfig)
+		return common.Handlers{
+			suite.router,
+		}
+	})
+	if e != nil {
+		panic(e)
+	}
+}
+
+func (suite *OrderReceiptTestSuite) TearDownTest() {
+	require.NoError(suite.T(), suite.smtp.Close())
+	require.NoError(suite.T(), os.RemoveAll(suite.pdfDir))
+}
+
+func (suite *OrderReceiptTestSuite) resend() (*http.Response, error) {
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Params(":"+common.RequestParameterReceiptId, suite.receiptId, ":"+common.RequestParameterOrderId, suite.orderId).
+		Path(common.NoAuthGroupPath + orderReceiptResendPath).
+		Exec(suite.T())
+
+	return res.Result(), err
+}
+
+func (suite *OrderReceiptTestSuite) TestOrderReceipt_Pdf_Ok() {
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterReceiptId, suite.receiptId, ":"+common.RequestParameterOrderId, suite.orderId).
+		Path(common.NoAuthGroupPath+orderReceiptPath).
+		SetQueryParam(documentFormatQueryParam, documentFormatPdf).
+		Exec(suite.T())
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), http.StatusOK, res.Code)
+	require.Equal(suite.T(), pdf.MimeType, res.Header().Get(echo.HeaderContentType))
+	require.Equal(suite.T(), "inline; filename=receipt_"+suite.receiptId+".pdf", res.Header().Get(echo.HeaderContentDisposition))
+	require.Equal(suite.T(), "%PDF-1.4\n<p>Квитанция|Project</p>", res.Body.String())
+}
+
+func (suite *OrderReceiptTestSuite) TestOrderReceipt_Pdf_Cached_Ok() {
+	for i := 0; i < 2; i++ {
+		res, err := suite.caller.Builder().
+			Method(http.MethodGet).
+			Params(":"+common.RequestParameterReceiptId, suite.receiptId, ":"+common.RequestParameterOrderId, suite.orderId).
+			Path(common.NoAuthGroupPath+orderReceiptPath).
+			SetQueryParam(documentFormatQueryParam, documentFormatPdf).
+			Exec(suite.T())
+		require.NoError(suite.T(), err)
+		require.Equal(suite.T(), http.StatusOK, res.Code)
+		require.Equal(suite.T(), "%PDF-1.4\n<p>Квитанция|Project</p>", res.Body.String())
+
+		// the second request is served from the cache without the renderer
+		require.NoError(suite.T(), os.RemoveAll(filepath.Join(suite.pdfDir, "wkhtmltopdf")))
+	}
+
+	cached := 0
+
+	for name := range suite.files {
+		if filepath.Dir(name) == "receipts/"+suite.receiptId {
+			cached++
+		}
+	}
+
+	require.Equal(suite.T(), 1, cached)
+}
+
+func (suite *OrderReceiptTestSuite) TestOrderReceipt_Pdf_RenderFailed_Error() {
+	require.NoError(suite.T(), os.Remove(filepath.Join(suite.pdfDir, "wkhtmltopdf")))
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterReceiptId, suite.receiptId, ":"+common.RequestParameterOrderId, suite.orderId).
+		Path(common.NoAuthGroupPath+orderReceiptPath).
+		SetQueryParam(documentFormatQueryParam, documentFormatPdf).
+		Exec(suite.T())
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), http.StatusInternalServerError, httpErr.Code)
+	require.Equal(suite.T(), common.ErrorMessageReceiptRenderFailed, httpErr.Message)
+}
+
+func (suite *OrderReceiptTestSuite) TestOrderReceipt_Resend_Ok() {
+	res, err := suite.resend()
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), http.StatusNoContent, res.StatusCode)
+
+	messages := suite.smtp.Messages()
+	require.Len(suite.T(), messages, 1)
+	require.Equal(suite.T(), []string{orderReceiptTestEmail}, messages[0].To)
+
+	msg, err := mail.ReadMessage(bytes.NewReader(messages[0].Data))
+	require.NoError(suite.T(), err)
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), "Ваша квитанция: Project", subject)
+	require.Contains(suite.T(), string(messages[0].Data), "receipt_"+suite.receiptId+".pdf")
+
+	suite.router.journal.Wait()
+	events, err := suite.router.journal.List(context.Background(), suite.orderId)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), events, 1)
+	require.Equal(suite.T(), timeline.EventReceiptSent, events[0].Type)
+	require.Equal(suite.T(), "ru", events[0].Details[orderReceiptDetailsLanguage])
+}
+
+func (suite *OrderReceiptTestSuite) TestOrderReceipt_Resend_Throttled_Error() {
+	res, err := suite.resend()
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), http.StatusNoContent, res.StatusCode)
+
+	res, err = suite.resend()
+	require.Error(suite.T(), err)
+	require.Equal(suite.T(), http.StatusTooManyRequests, res.StatusCode)
+	require.NotEmpty(suite.T(), res.Header.Get(common.HeaderRetryAfter))
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), common.ErrorMessageReceiptResendThrottled, httpErr.Message)
+	require.Len(suite.T(), suite.smtp.Messages(), 1)
+}
+
+func (suite *OrderReceiptTestSuite) TestOrderReceipt_Resend_EmailNotFound_Error() {
+	suite.billing.ExpectedCalls = nil
+	suite.billing.On("OrderReceipt", mock2.Anything, mock2.Anything).
+		Return(&grpc.OrderReceiptResponse{Status: pkg.ResponseStatusOk, Receipt: &billing.OrderReceipt{}}, nil)
+	suite.billing.On("GetOrderPublic", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetOrderPublicResponse{Status: pkg.ResponseStatusOk, Item: &billing.OrderViewPublic{}}, nil)
+
+	_, err := suite.resend()
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), http.StatusBadRequest, httpErr.Code)
+	require.Equal(suite.T(), common.ErrorMessageReceiptEmailNotFound, httpErr.Message)
+	require.Empty(suite.T(), suite.smtp.Messages())
+}
+
+func (suite *OrderReceiptTestSuite) TestOrderReceipt_Resend_SmtpFailed_Error() {
+	require.NoError(suite.T(), suite.smtp.Close())
+
+	_, err := suite.resend()
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), http.StatusInternalServerError, httpErr.Code)
+	require.Equal(suite.T(), common.ErrorMessageReceiptEmailSendFailed, httpErr.Message)
+	require.Empty(suite.T(), suite.files[fmt.Sprintf("orders/%s/timeline.json", suite.orderId)])
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,9 +12,14 @@ import (
 	billMock "github.com/paysuper/paysuper-billing-server/pkg/mocks"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/branding"
 	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
 	"github.com/paysuper/paysuper-management-api/internal/mock"
+	"github.com/paysuper/paysuper-management-api/internal/paymentform"
+	"github.com/paysuper/paysuper-management-api/internal/receipt"
 	"github.com/paysuper/paysuper-management-api/internal/test"
+	"github.com/paysuper/paysuper-management-api/internal/theme"
+	"github.com/paysuper/paysuper-management-api/internal/timeline"
 	"github.com/stretchr/testify/assert"
 	mock2 "github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -47,7 +53,8 @@ func (suite *OrderTestSuite) SetupTest() {
 	}
 	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
 		mw.Pre(test.PreAuthUserMiddleware(user))
-		suite.router = NewOrderRoute(set.HandlerSet, set.GlobalConfig)
+		awsManager := mock.NewAwsManagerFilesMock(make(map[string][]byte))
+		suite.router = NewOrderRoute(set.HandlerSet, timeline.NewJournal(awsManager), branding.NewStore(awsManager), receipt.NewCache(awsManager), paymentform.NewTokens(awsManager), theme.NewStore(awsManager, projectThemeCacheTtl), set.GlobalConfig)
 		return common.Handlers{
 			suite.router,
 		}
@@ -1008,10 +1015,13 @@ func (suite *OrderTestSuite) TestOrder_CreateJson_ProductionEnvironment_Ok() {
 }
 
 func (suite *OrderTestSuite) TestOrder_GetPaymentFormData_Ok() {
+	orderId := uuid.New().String()
+
 	res, err := suite.caller.Builder().
 		Method(http.MethodGet).
-		Params(":"+common.RequestParameterOrderId, uuid.New().String()).
+		Params(":"+common.RequestParameterOrderId, orderId).
 		Path(common.NoAuthGroupPath + orderIdPath).
+		SetQueryParams(suite.getSignedPaymentFormQuery(orderId, time.Now())).
 		Init(test.ReqInitJSON()).
 		Exec(suite.T())
 
@@ -1026,6 +1036,250 @@ func (suite *OrderTestSuite) TestOrder_GetPaymentFormData_Ok() {
 	assert.NotEmpty(suite.T(), res.Header().Get(echo.HeaderSetCookie))
 }
 
+func (suite *OrderTestSuite) TestOrder_CreateJson_PaymentFormParams_Ok() {
+	order := &billing.OrderCreateRequest{
+		ProjectId: bson.NewObjectId().Hex(),
+		Currency:  "RUB",
+		Amount:    100,
+	}
+
+	b, err := json.Marshal(order)
+	assert.NoError(suite.T(), err)
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.NoAuthGroupPath+orderPath).
+		SetQueryParam(paymentform.ParamLocale, "ru-RU").
+		SetQueryParam(paymentform.ParamMode, paymentform.ModeIframe).
+		Init(test.ReqInitJSON()).
+		BodyBytes(b).
+		Exec(suite.T())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, res.Code)
+
+	var response *CreateOrderJsonProjectResponse
+	err = json.Unmarshal(res.Body.Bytes(), &response)
+	assert.NoError(suite.T(), err)
+
+	formUrl, err := url.Parse(response.PaymentFormUrl)
+	assert.NoError(suite.T(), err)
+
+	params, err := suite.router.paymentForm.Verify(formUrl.Query(), time.Now())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), response.Id, params.OrderId)
+	assert.Equal(suite.T(), "ru-RU", params.Locale)
+	assert.Equal(suite.T(), paymentform.ModeIframe, params.Mode)
+}
+
+func (suite *OrderTestSuite) TestOrder_CreateJson_PaymentFormParams_Error() {
+	order := &billing.OrderCreateRequest{
+		ProjectId: bson.NewObjectId().Hex(),
+		Currency:  "RUB",
+		Amount:    100,
+	}
+
+	b, err := json.Marshal(order)
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.NoAuthGroupPath+orderPath).
+		SetQueryParam(paymentform.ParamMode, "popup").
+		Init(test.ReqInitJSON()).
+		BodyBytes(b).
+		Exec(suite.T())
+	assert.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), http.StatusBadRequest, httpErr.Code)
+	assert.Equal(suite.T(), common.ErrorMessagePaymentFormUrlParamsInvalid, httpErr.Message)
+}
+
+func (suite *OrderTestSuite) getSignedPaymentFormQuery(orderId string, now time.Time) url.Values {
+	formUrl, err := suite.router.paymentForm.Build(&paymentform.Params{OrderId: orderId, Locale: "de"}, now)
+	require.NoError(suite.T(), err)
+
+	u, err := url.Parse(formUrl)
+	require.NoError(suite.T(), err)
+
+	return u.Query()
+}
+
+func (suite *OrderTestSuite) TestOrder_GetPaymentFormData_SignedUrl_Ok() {
+	orderId := uuid.New().String()
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterOrderId, orderId).
+		Path(common.NoAuthGroupPath + orderIdPath).
+		SetQueryParams(suite.getSignedPaymentFormQuery(orderId, time.Now())).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, res.Code)
+}
+
+func (suite *OrderTestSuite) TestOrder_GetPaymentFormData_SignedUrl_Error() {
+	orderId := uuid.New().String()
+
+	altered := suite.getSignedPaymentFormQuery(orderId, time.Now())
+	altered.Set(paymentform.ParamLocale, "en")
+
+	cases := []struct {
+		orderId string
+		query   url.Values
+		code    int
+		message interface{}
+	}{
+		{orderId, altered, http.StatusForbidden, common.ErrorMessagePaymentFormUrlSignatureInvalid},
+		{uuid.New().String(), suite.getSignedPaymentFormQuery(orderId, time.Now()), http.StatusForbidden, common.ErrorMessagePaymentFormUrlSignatureInvalid},
+		{orderId, suite.getSignedPaymentFormQuery(orderId, time.Now().Add(-48*time.Hour)), http.StatusGone, common.ErrorMessagePaymentFormUrlExpired},
+	}
+
+	for _, c := range cases {
+		_, err := suite.caller.Builder().
+			Method(http.MethodGet).
+			Params(":"+common.RequestParameterOrderId, c.orderId).
+			Path(common.NoAuthGroupPath + orderIdPath).
+			SetQueryParams(c.query).
+			Init(test.ReqInitJSON()).
+			Exec(suite.T())
+		assert.Error(suite.T(), err)
+
+		httpErr, ok := err.(*echo.HTTPError)
+		assert.True(suite.T(), ok)
+		assert.Equal(suite.T(), c.code, httpErr.Code)
+		assert.Equal(suite.T(), c.message, httpErr.Message)
+	}
+}
+
+func (suite *OrderTestSuite) TestOrder_GetPaymentFormData_Unsigned_Error() {
+	unsignedUntil := time.Now().Add(-time.Hour)
+	suite.router.paymentFormUnsignedUntil = &unsignedUntil
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterOrderId, uuid.New().String()).
+		Path(common.NoAuthGroupPath + orderIdPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+	assert.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), http.StatusForbidden, httpErr.Code)
+	assert.Equal(suite.T(), common.ErrorMessagePaymentFormUrlSignatureRequired, httpErr.Message)
+}
+
+func (suite *OrderTestSuite) TestOrder_GetPaymentFormData_Unsigned_Migration_Ok() {
+	unsignedUntil := time.Now().Add(time.Hour)
+	suite.router.paymentFormUnsignedUntil = &unsignedUntil
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterOrderId, uuid.New().String()).
+		Path(common.NoAuthGroupPath + orderIdPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, res.Code)
+}
+
+func (suite *OrderTestSuite) TestOrder_GetPaymentFormData_Unsigned_MigrationEndNotSet_Ok() {
+	suite.router.paymentFormUnsignedUntil = nil
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterOrderId, uuid.New().String()).
+		Path(common.NoAuthGroupPath + orderIdPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, res.Code)
+}
+
+func (suite *OrderTestSuite) TestOrder_GetOrderForPaylink_SignedUrl_Ok() {
+	orderId := uuid.New().String()
+	billingService := &billMock.BillingService{}
+	billingService.On("IncrPaylinkVisits", mock2.Anything, mock2.Anything).Return(&grpc.EmptyResponse{}, nil)
+	billingService.On("OrderCreateByPaylink", mock2.Anything, mock2.Anything).Return(&grpc.OrderCreateProcessResponse{
+		Status: pkg.ResponseStatusOk,
+		Item:   &billing.Order{Uuid: orderId},
+	}, nil)
+	suite.router.dispatch.Services.Billing = billingService
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterId, bson.NewObjectId().Hex()).
+		Path(common.NoAuthGroupPath + paylinkIdPath).
+		SetQueryParams(url.Values{
+			common.QueryParameterNameUtmSource: []string{"newsletter"},
+			paymentform.ParamLocale:            []string{"ru"},
+			paymentform.ParamOrderId:           []string{uuid.New().String()},
+			"other":                            []string{"value"},
+		}).
+		Exec(suite.T())
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), http.StatusFound, res.Code)
+
+	location, err := url.Parse(res.Header().Get(echo.HeaderLocation))
+	require.NoError(suite.T(), err)
+
+	query := location.Query()
+	params, err := suite.router.paymentForm.Verify(query, time.Now())
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), orderId, params.OrderId)
+	assert.Equal(suite.T(), "ru", params.Locale)
+	assert.Equal(suite.T(), "newsletter", query.Get(common.QueryParameterNameUtmSource))
+	assert.Empty(suite.T(), query.Get("other"))
+}
+
+func (suite *OrderTestSuite) getTokenPaymentFormQuery(token string) url.Values {
+	formUrl, err := suite.router.paymentForm.Build(&paymentform.Params{Token: token}, time.Now())
+	require.NoError(suite.T(), err)
+
+	u, err := url.Parse(formUrl)
+	require.NoError(suite.T(), err)
+
+	return u.Query()
+}
+
+func (suite *OrderTestSuite) TestOrder_GetPaymentFormData_TokenUrl_Ok() {
+	token := uuid.New().String()
+	orderId := uuid.New().String()
+	require.NoError(suite.T(), suite.router.paymentTokens.Bind(context.Background(), token, orderId))
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterOrderId, orderId).
+		Path(common.NoAuthGroupPath + orderIdPath).
+		SetQueryParams(suite.getTokenPaymentFormQuery(token)).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, res.Code)
+}
+
+func (suite *OrderTestSuite) TestOrder_GetPaymentFormData_TokenUrl_OtherOrder_Error() {
+	token := uuid.New().String()
+	require.NoError(suite.T(), suite.router.paymentTokens.Bind(context.Background(), token, uuid.New().String()))
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterOrderId, uuid.New().String()).
+		Path(common.NoAuthGroupPath + orderIdPath).
+		SetQueryParams(suite.getTokenPaymentFormQuery(token)).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+	assert.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), http.StatusForbidden, httpErr.Code)
+	assert.Equal(suite.T(), common.ErrorMessagePaymentFormUrlSignatureInvalid, httpErr.Message)
+}
+
 func (suite *OrderTestSuite) TestOrder_GetOrderForm_TokenCookieExist_Ok() {
 	cookie := new(http.Cookie)
 	cookie.Name = common.CustomerTokenCookiesName
@@ -1038,6 +1292,7 @@ func (suite *OrderTestSuite) TestOrder_GetOrderForm_TokenCookieExist_Ok() {
 		AddCookie(cookie).
 		Params(":"+common.RequestParameterOrderId, mock.SomeMerchantId1).
 		Path(common.NoAuthGroupPath + orderIdPath).
+		SetQueryParams(suite.getSignedPaymentFormQuery(mock.SomeMerchantId1, time.Now())).
 		Init(test.ReqInitJSON()).
 		Exec(suite.T())
 
@@ -1074,11 +1329,13 @@ func (suite *OrderTestSuite) TestOrder_GetOrderForm_ParameterIdNotFound_Error()
 func (suite *OrderTestSuite) TestOrder_GetOrderForm_BillingServerSystemError() {
 
 	suite.router.dispatch.Services.Billing = mock.NewBillingServerSystemErrorMock()
+	orderId := bson.NewObjectId().Hex()
 
 	_, err := suite.caller.Builder().
 		Method(http.MethodGet).
-		Params(":"+common.RequestParameterOrderId, bson.NewObjectId().Hex()).
+		Params(":"+common.RequestParameterOrderId, orderId).
 		Path(common.NoAuthGroupPath + orderIdPath).
+		SetQueryParams(suite.getSignedPaymentFormQuery(orderId, time.Now())).
 		Init(test.ReqInitJSON()).
 		Exec(suite.T())
 
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"github.com/ProtocolONE/go-core/v2/pkg/logger"
+	"github.com/ProtocolONE/go-core/v2/pkg/provider"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/timeline"
+	"net/http"
+	"time"
+)
+
+const (
+	orderTimelinePath = "/order/:order_id/timeline"
+
+	orderTimelineRefundsLimit = 100
+)
+
+// OrderTimeline is the chronological list of the order lifecycle events
+type OrderTimeline struct {
+	OrderId string            `json:"order_id"`
+	Events  []*timeline.Event `json:"events"`
+}
+
+// getTimeline aggregates the order snapshot, the order refunds and the events journaled by the API
+// into the single chronological list of the order events
+func (h *OrderRoute) getTimeline(ctx echo.Context) error {
+	req := &grpc.GetOrderRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	res, err := h.dispatch.Services.Billing.GetOrderPublic(ctx.Request().Context(), req)
+
+	if err != nil {
+		return h.dispatch.SrvCallHandler(req, err, pkg.ServiceName, "GetOrderPublic")
+	}
+
+	if res.Status != pkg.ResponseStatusOk {
+		return echo.NewHTTPError(int(res.Status), res.Message)
+	}
+
+	order := res.Item
+	events := getOrderSnapshotEvents(order)
+
+	refundsReq := &grpc.ListRefundsRequest{OrderId: order.Uuid, Limit: orderTimelineRefundsLimit}
+
+	for {
+		refunds, err := h.dispatch.Services.Billing.ListRefunds(ctx.Request().Context(), refundsReq)
+
+		if err != nil {
+			return h.dispatch.SrvCallHandler(refundsReq, err, pkg.ServiceName, "ListRefunds")
+		}
+
+		for _, refund := range refunds.Items {
+			events = append(events, getRefundEvent(refund))
+		}
+
+		if int64(len(refunds.Items)) < refundsReq.Limit {
+			break
+		}
+
+		refundsReq.Offset += refundsReq.Limit
+	}
+
+	journal, err := h.journal.List(ctx.Request().Context(), order.Uuid, order.Id)
+
+	if err != nil {
+		h.L().Error("order timeline journal load failed", logger.PairArgs("order_id", order.Uuid, "err", err.Error()))
+		return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessageOrderTimelineStorageFailed)
+	}
+
+	events = append(events, journal...)
+	timeline.Sort(events)
+
+	return ctx.JSON(http.StatusOK, &OrderTimeline{OrderId: order.Uuid, Events: events})
+}
+
+// recordTimelineEvent journals the event of the request accepted by the billing server.
+// The event is written in the background, the failure of the journal doesn't fail the request, it's logged only.
+func recordTimelineEvent(lmt provider.LMT, journal *timeline.Journal, orderId string, event *timeline.Event) {
+	journalTimelineEvent(lmt, journal, orderId, event, nil)
+}
+
+// recordRejectedTimelineEvent journals the event of the request rejected by the billing server.
+// The order identifier of such request is supplied by the caller, so the event is journaled
+// only when the billing server knows the order.
+func recordRejectedTimelineEvent(
+	lmt provider.LMT,
+	journal *timeline.Journal,
+	billingService grpc.BillingService,
+	orderId string,
+	event *timeline.Event,
+) {
+	journalTimelineEvent(lmt, journal, orderId, event, func(ctx context.Context) bool {
+		res, err := billingService.GetOrderPublic(ctx, &grpc.GetOrderRequest{OrderId: orderId})
+		return err == nil && res.Status == pkg.ResponseStatusOk
+	})
+}
+
+func journalTimelineEvent(
+	lmt provider.LMT,
+	journal *timeline.Journal,
+	orderId string,
+	event *timeline.Event,
+	confirm func(ctx context.Context) bool,
+) {
+	if orderId == "" {
+		return
+	}
+
+	err := journal.Async(func(ctx context.Context) {
+		if confirm != nil && !confirm(ctx) {
+			return
+		}
+
+		if err := journal.Record(ctx, orderId, event); err != nil {
+			lmt.L().Error(
+				"order timeline event record failed",
+				logger.PairArgs("order_id", orderId, "event", event.Type, "err", err.Error()),
+			)
+		}
+	})
+
+	if err != nil {
+		lmt.L().Error(
+			"order timeline event dropped",
+			logger.PairArgs("order_id", orderId, "event", event.Type, "err", err.Error()),
+		)
+	}
+}
+
+func getOrderSnapshotEvents(order *billing.OrderViewPublic) []*timeline.Event {
+	events := make([]*timeline.Event, 0)
+
+	if createdAt, ok := getTimestampTime(order.CreatedAt); ok {
+		events = append(events, &timeline.Event{
+			Type:       timeline.EventOrderCreated,
+			OccurredAt: createdAt,
+			Source:     timeline.SourceOrder,
+			Details: map[string]string{
+				"amount":   fmt.Sprintf("%v", order.TotalPaymentAmount),
+				"currency": order.Currency,
+			},
+		})
+	}
+
+	if processedAt, ok := getTimestampTime(order.TransactionDate); ok {
+		events = append(events, &timeline.Event{
+			Type:       timeline.EventOrderProcessed,
+			OccurredAt: processedAt,
+			Source:     timeline.SourceOrder,
+			Actor:      timeline.ActorPaymentSystem,
+			Details:    map[string]string{"status": order.Status},
+		})
+	}
+
+	return events
+}
+
+func getRefundEvent(refund *billing.Refund) *timeline.Event {
+	event := &timeline.Event{
+		Type:   timeline.EventRefundCreated,
+		Source: timeline.SourceRefund,
+		Actor:  refund.CreatorId,
+		Details: map[string]string{
+			"refund_id": refund.Id,
+			"amount":    fmt.Sprintf("%v", refund.Amount),
+			"currency":  refund.Currency,
+			"reason":    refund.Reason,
+			"status":    fmt.Sprintf("%d", refund.Status),
+		},
+	}
+	event.OccurredAt, _ = getTimestampTime(refund.CreatedAt)
+
+	return event
+}
+
+func getTimestampTime(ts *timestamp.Timestamp) (time.Time, bool) {
+	if ts == nil {
+		return time.Time{}, false
+	}
+
+	t, err := ptypes.Timestamp(ts)
+
+	if err != nil || t.Unix() <= 0 {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	awsWrapperMocks "github.com/paysuper/paysuper-aws-manager/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/branding"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/mock"
+	"github.com/paysuper/paysuper-management-api/internal/paymentform"
+	"github.com/paysuper/paysuper-management-api/internal/receipt"
+	"github.com/paysuper/paysuper-management-api/internal/test"
+	"github.com/paysuper/paysuper-management-api/internal/theme"
+	"github.com/paysuper/paysuper-management-api/internal/timeline"
+	mock2 "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type OrderTimelineTestSuite struct {
+	suite.Suite
+	router  *OrderRoute
+	caller  *test.EchoReqResCaller
+	files   map[string][]byte
+	orderId string
+}
+
+func Test_OrderTimeline(t *testing.T) {
+	suite.Run(t, new(OrderTimelineTestSuite))
+}
+
+func (suite *OrderTimelineTestSuite) SetupTest() {
+	user := &common.AuthUser{
+		Id:         "ffffffffffffffffffffffff",
+		MerchantId: "ffffffffffffffffffffffff",
+	}
+
+	suite.files = make(map[string][]byte)
+	suite.orderId = uuid.New().String()
+
+	var e error
+	settings := test.DefaultSettings()
+	srv := common.Services{
+		Billing: suite.mockBilling(),
+	}
+	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
+		mw.Pre(test.PreAuthUserMiddleware(user))
+		awsManager := mock.NewAwsManagerFilesMock(suite.files)
+		suite.router = NewOrderRoute(set.HandlerSet, timeline.NewJournal(awsManager), branding.NewStore(awsManager), receipt.NewCache(awsManager), paymentform.NewTokens(awsManager), theme.NewStore(awsManager, projectThemeCacheTtl), set.GlobalConfig)
+		return common.Handlers{
+			suite.router,
+		}
+	})
+	if e != nil {
+		panic(e)
+	}
+}
+
+func (suite *OrderTimelineTestSuite) TearDownTest() {}
+
+func (suite *OrderTimelineTestSuite) mockBilling() *mocks.BillingService {
+	createdAt, _ := ptypes.TimestampProto(time.Date(2019, 10, 1, 10, 0, 0, 0, time.UTC))
+	transactionDate, _ := ptypes.TimestampProto(time.Date(2019, 10, 1, 10, 5, 0, 0, time.UTC))
+	refundedAt, _ := ptypes.TimestampProto(time.Date(2019, 10, 2, 12, 0, 0, 0, time.UTC))
+
+	billingService := &mocks.BillingService{}
+	billingService.On("GetOrderPublic", mock2.Anything, mock2.Anything).Return(&grpc.GetOrderPublicResponse{
+		Status: pkg.ResponseStatusOk,
+		Item: &billing.OrderViewPublic{
+			Id:              "5dbac5f0120a810001a8fe2a",
+			Uuid:            suite.orderId,
+			Currency:        "USD",
+			Status:          "processed",
+			CreatedAt:       createdAt,
+			TransactionDate: transactionDate,
+		},
+	}, nil)
+	billingService.On("ListRefunds", mock2.Anything, mock2.Anything).Return(&grpc.ListRefundsResponse{
+		Count: 1,
+		Items: []*billing.Refund{
+			{
+				Id:        "5dbac5f0120a810001a8fe2b",
+				Amount:    10,
+				Currency:  "USD",
+				CreatorId: "ffffffffffffffffffffffff",
+				Reason:    "test",
+				CreatedAt: refundedAt,
+			},
+		},
+	}, nil)
+	billingService.On("ChangeCodeInOrder", mock2.Anything, mock2.Anything).Return(&grpc.ChangeCodeInOrderResponse{
+		Status: pkg.ResponseStatusOk,
+		Order:  &billing.Order{},
+	}, nil)
+
+	return billingService
+}
+
+func (suite *OrderTimelineTestSuite) getTimeline() (*OrderTimeline, error) {
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":order_id", suite.orderId).
+		Path(common.AuthUserGroupPath + orderTimelinePath).
+		Exec(suite.T())
+
+	if err != nil {
+		return nil, err
+	}
+
+	require.Equal(suite.T(), http.StatusOK, res.Code)
+
+	result := &OrderTimeline{}
+	require.NoError(suite.T(), json.Unmarshal(res.Body.Bytes(), result))
+
+	return result, nil
+}
+
+func (suite *OrderTimelineTestSuite) TestOrderTimeline_Ok() {
+	shouldBe := require.New(suite.T())
+
+	err := suite.router.journal.Record(context.Background(), suite.orderId, &timeline.Event{
+		Type:       timeline.EventLanguageChanged,
+		OccurredAt: time.Date(2019, 10, 1, 10, 1, 0, 0, time.UTC),
+		Actor:      timeline.ActorCustomer,
+	})
+	shouldBe.NoError(err)
+
+	// the callbacks are journaled by the order id instead of the uuid
+	err = suite.router.journal.Record(context.Background(), "5dbac5f0120a810001a8fe2a", &timeline.Event{
+		Type:       timeline.EventPaymentCallback,
+		OccurredAt: time.Date(2019, 10, 1, 10, 4, 0, 0, time.UTC),
+		Actor:      timeline.ActorPaymentSystem,
+	})
+	shouldBe.NoError(err)
+
+	result, err := suite.getTimeline()
+	shouldBe.NoError(err)
+	shouldBe.Equal(suite.orderId, result.OrderId)
+	shouldBe.Len(result.Events, 5)
+
+	types := make([]string, 0, len(result.Events))
+
+	for _, event := range result.Events {
+		types = append(types, event.Type)
+	}
+
+	shouldBe.Equal([]string{
+		timeline.EventOrderCreated,
+		timeline.EventLanguageChanged,
+		timeline.EventPaymentCallback,
+		timeline.EventOrderProcessed,
+		timeline.EventRefundCreated,
+	}, types)
+	shouldBe.Equal(timeline.SourceOrder, result.Events[0].Source)
+	shouldBe.Equal(timeline.SourceJournal, result.Events[1].Source)
+	shouldBe.Equal(timeline.SourceRefund, result.Events[4].Source)
+	shouldBe.Equal("5dbac5f0120a810001a8fe2b", result.Events[4].Details["refund_id"])
+}
+
+func (suite *OrderTimelineTestSuite) TestOrderTimeline_ReplaceCode_Recorded() {
+	shouldBe := require.New(suite.T())
+
+	b, err := json.Marshal(&grpc.ChangeCodeInOrderRequest{KeyProductId: "5dbac5f0120a810001a8fe2c"})
+	shouldBe.NoError(err)
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodPut).
+		Params(":order_id", suite.orderId).
+		Path(common.SystemUserGroupPath + orderReplaceCodePath).
+		Init(test.ReqInitJSON()).
+		BodyBytes(b).
+		Exec(suite.T())
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+
+	suite.router.journal.Wait()
+	result, err := suite.getTimeline()
+	shouldBe.NoError(err)
+
+	event := result.Events[len(result.Events)-1]
+	shouldBe.Equal(timeline.EventCodeReplaced, event.Type)
+	shouldBe.Equal("5dbac5f0120a810001a8fe2c", event.Details["key_product_id"])
+}
+
+// createPayment sends the payment attempt rejected by the billing server
+func (suite *OrderTimelineTestSuite) createPayment(billingService *mocks.BillingService, orderId string) {
+	billingService.On("PaymentCreateProcess", mock2.Anything, mock2.Anything).Return(&grpc.PaymentCreateResponse{
+		Status:  pkg.ResponseStatusBadData,
+		Message: &grpc.ResponseErrorMessage{Message: "some error"},
+	}, nil)
+	suite.router.dispatch.Services.Billing = billingService
+
+	b, err := json.Marshal(map[string]string{
+		pkg.PaymentCreateFieldOrderId:         orderId,
+		pkg.PaymentCreateFieldPaymentMethodId: "5dbac5f0120a810001a8fe2d",
+	})
+	require.NoError(suite.T(), err)
+
+	_, err = suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.NoAuthGroupPath + paymentPath).
+		Init(test.ReqInitJSON()).
+		BodyBytes(b).
+		Exec(suite.T())
+	require.Error(suite.T(), err)
+
+	suite.router.journal.Wait()
+}
+
+func (suite *OrderTimelineTestSuite) TestOrderTimeline_RejectedPayment_Recorded() {
+	suite.createPayment(suite.mockBilling(), suite.orderId)
+
+	events, err := suite.router.journal.List(context.Background(), suite.orderId)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), events, 1)
+	require.Equal(suite.T(), timeline.EventPaymentAttempted, events[0].Type)
+	require.Equal(suite.T(), "5dbac5f0120a810001a8fe2d", events[0].Details["payment_method_id"])
+}
+
+func (suite *OrderTimelineTestSuite) TestOrderTimeline_RejectedPayment_UnknownOrder_NotRecorded() {
+	billingService := &mocks.BillingService{}
+	billingService.On("GetOrderPublic", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetOrderPublicResponse{Status: pkg.ResponseStatusNotFound}, nil)
+	suite.createPayment(billingService, "unknown")
+
+	require.Empty(suite.T(), suite.files)
+}
+
+func (suite *OrderTimelineTestSuite) TestOrderTimeline_BillingServer_Error() {
+	billingService := &mocks.BillingService{}
+	billingService.On("GetOrderPublic", mock2.Anything, mock2.Anything).Return(nil, errors.New("some error"))
+	suite.router.dispatch.Services.Billing = billingService
+
+	_, err := suite.getTimeline()
+
+	shouldBe := require.New(suite.T())
+	shouldBe.Error(err)
+	httpErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusInternalServerError, httpErr.Code)
+	shouldBe.Equal(common.ErrorInternal, httpErr.Message)
+}
+
+func (suite *OrderTimelineTestSuite) TestOrderTimeline_Storage_Error() {
+	awsManagerMock := &awsWrapperMocks.AwsManagerInterface{}
+	awsManagerMock.On("Download", mock2.Anything, mock2.Anything, mock2.Anything, mock2.Anything).
+		Return(int64(0), errors.New("some error"))
+	suite.router.journal = timeline.NewJournal(awsManagerMock)
+
+	_, err := suite.getTimeline()
+
+	shouldBe := require.New(suite.T())
+	shouldBe.Error(err)
+	httpErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusInternalServerError, httpErr.Code)
+	shouldBe.Equal(common.ErrorMessageOrderTimelineStorageFailed, httpErr.Message)
+}
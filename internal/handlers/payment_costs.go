@@ -8,19 +8,22 @@ import (
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
 	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/paymentcosts"
 	"net/http"
 )
 
 type PaymentCostRoute struct {
 	dispatch common.HandlerSet
+	versions *paymentcosts.Versions
 	cfg      common.Config
 	provider.LMT
 }
 
-func NewPaymentCostRoute(set common.HandlerSet, cfg *common.Config) *PaymentCostRoute {
+func NewPaymentCostRoute(set common.HandlerSet, versions *paymentcosts.Versions, cfg *common.Config) *PaymentCostRoute {
 	set.AwareSet.Logger = set.AwareSet.Logger.WithFields(logger.Fields{"router": "PaymentCostRoute"})
 	return &PaymentCostRoute{
 		dispatch: set,
+		versions: versions,
 		LMT:      &set.AwareSet,
 		cfg:      *cfg,
 	}
@@ -66,6 +69,13 @@ func (h *PaymentCostRoute) Route(groups *common.Groups) {
 	groups.SystemUser.PUT(paymentCostsChannelMerchantIdsPath, h.setPaymentChannelCostMerchant)
 	groups.SystemUser.PUT(paymentCostsMoneyBackSystemIdPath, h.setMoneyBackCostSystem)
 	groups.SystemUser.PUT(paymentCostsMoneyBackMerchantIdsPath, h.setMoneyBackCostMerchant)
+
+	groups.SystemUser.GET(paymentCostsExportPath, h.exportPaymentCosts)
+	groups.SystemUser.POST(paymentCostsImportPath, h.importPaymentCosts)
+	groups.SystemUser.GET(paymentCostsVersionsPath, h.listPaymentCostsVersions)
+	groups.SystemUser.GET(paymentCostsVersionIdPath, h.getPaymentCostsVersion)
+	groups.SystemUser.POST(paymentCostsVersionRollbackPath, h.rollbackPaymentCosts)
+	groups.SystemUser.GET(paymentCostsOverridesPath, h.getPaymentCostsOverrides)
 }
 
 func (h *PaymentCostRoute) getPaymentChannelCostSystem(ctx echo.Context) error {
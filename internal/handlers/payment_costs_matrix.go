@@ -0,0 +1,693 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"github.com/ProtocolONE/go-core/v2/pkg/logger"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/paymentcosts"
+	"github.com/paysuper/paysuper-management-api/internal/xlsx"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+const (
+	paymentCostsExportPath          = "/payment_costs/export"
+	paymentCostsImportPath          = "/payment_costs/import"
+	paymentCostsVersionsPath        = "/payment_costs/versions"
+	paymentCostsVersionIdPath       = "/payment_costs/versions/:version_id"
+	paymentCostsVersionRollbackPath = "/payment_costs/versions/:version_id/rollback"
+
+	paymentCostsFileName        = "payment_costs.%s"
+	paymentCostsVersionFileName = "payment_costs_v%d.%s"
+	paymentCostsFormatCsv       = "csv"
+	paymentCostsFormatXlsx      = "xlsx"
+
+	paymentCostsImportRowsMax        = 50000
+	paymentCostsMerchantsConcurrency = 8
+	paymentCostImportActionInvalid   = "invalid"
+)
+
+type paymentCostsExportRequest struct {
+	Format    string   `query:"format" validate:"omitempty,oneof=csv xlsx"`
+	Merchants []string `query:"merchant_id" validate:"omitempty,dive,hexadecimal,len=24"`
+}
+
+type paymentCostsImportRequest struct {
+	DryRun  bool `form:"dry_run"`
+	Replace bool `form:"replace"`
+}
+
+type paymentCostsVersionRequest struct {
+	Format string `query:"format" validate:"omitempty,oneof=csv xlsx"`
+}
+
+type paymentCostsRollbackRequest struct {
+	DryRun bool `json:"dry_run" form:"dry_run"`
+}
+
+// PaymentCostImportRow is the change of the single cost. The deleted costs have no row of the import file.
+type PaymentCostImportRow struct {
+	Row     int                        `json:"row,omitempty"`
+	Action  string                     `json:"action"`
+	Cost    *paymentcosts.Row          `json:"cost,omitempty"`
+	Old     *paymentcosts.Row          `json:"old,omitempty"`
+	Message *grpc.ResponseErrorMessage `json:"message,omitempty"`
+}
+
+// PaymentCostsImportResult is the diff of the cost matrix, the applied change has the created version
+type PaymentCostsImportResult struct {
+	DryRun    bool                       `json:"dry_run"`
+	Total     int                        `json:"total"`
+	Created   int                        `json:"created"`
+	Updated   int                        `json:"updated"`
+	Deleted   int                        `json:"deleted"`
+	Unchanged int                        `json:"unchanged"`
+	Failed    int                        `json:"failed"`
+	Rows      []*PaymentCostImportRow    `json:"rows"`
+	Version   *paymentcosts.Version      `json:"version,omitempty"`
+	Message   *grpc.ResponseErrorMessage `json:"message,omitempty"`
+}
+
+// PaymentCostsVersion is the version with the snapshot of the changed part of the cost matrix
+type PaymentCostsVersion struct {
+	*paymentcosts.Version
+	*paymentcosts.Snapshot
+}
+
+func (r *PaymentCostsImportResult) count() {
+	r.Created, r.Updated, r.Deleted, r.Unchanged, r.Failed = 0, 0, 0, 0, 0
+
+	for _, row := range r.Rows {
+		switch row.Action {
+		case paymentcosts.ActionCreate:
+			r.Created++
+		case paymentcosts.ActionUpdate:
+			r.Updated++
+		case paymentcosts.ActionDelete:
+			r.Deleted++
+		case paymentcosts.ActionUnchanged:
+			r.Unchanged++
+		default:
+			r.Failed++
+		}
+	}
+}
+
+// exportPaymentCosts returns the system costs and the costs of the requested merchants in the format accepted by the import
+func (h *PaymentCostRoute) exportPaymentCosts(ctx echo.Context) error {
+	req := &paymentCostsExportRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	rows, err := h.listPaymentCosts(ctx.Request().Context(), req.Merchants)
+
+	if err != nil {
+		return err
+	}
+
+	return h.writePaymentCosts(ctx, rows, req.Format, fmt.Sprintf(paymentCostsFileName, paymentCostsFormat(req.Format)))
+}
+
+// importPaymentCosts creates and updates the costs of the CSV or XLSX file. With replace the costs of the file merchants
+// missing in the file are deleted, the system costs are deleted the same way when the file has any system cost.
+// The replace isn't applied at all when any row of the file is invalid, so the invalid row never deletes its cost.
+// The applied import is saved as the version, with dry_run nothing is saved and only the diff is returned.
+func (h *PaymentCostRoute) importPaymentCosts(ctx echo.Context) error {
+	req := &paymentCostsImportRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	fileName, lines, err := readPaymentCostsImportFile(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	result := &PaymentCostsImportResult{
+		DryRun: req.DryRun,
+		Total:  len(lines),
+		Rows:   make([]*PaymentCostImportRow, 0, len(lines)),
+	}
+	imported := make([]*paymentcosts.Row, 0, len(lines))
+	resultRows := make(map[*paymentcosts.Row]*PaymentCostImportRow, len(lines))
+	keys := make(map[string]bool, len(lines))
+
+	for _, line := range lines {
+		row := &PaymentCostImportRow{Row: line.Number, Cost: line.Row}
+		result.Rows = append(result.Rows, row)
+
+		if line.Field != "" {
+			msg := common.ErrorMessagePaymentCostsImportValueInvalid
+			row.Action = paymentCostImportActionInvalid
+			row.Message = common.NewManagementApiResponseError(msg.Code, msg.Message, line.Field)
+			continue
+		}
+
+		if keys[line.Row.Key()] {
+			row.Action = paymentCostImportActionInvalid
+			row.Message = common.ErrorMessagePaymentCostsImportRowDuplicated
+			continue
+		}
+
+		keys[line.Row.Key()] = true
+		imported = append(imported, line.Row)
+		resultRows[line.Row] = row
+	}
+
+	scope := paymentcosts.ImportScope(imported)
+	current, err := h.listPaymentCosts(ctx.Request().Context(), scope.Merchants)
+
+	if err != nil {
+		return err
+	}
+
+	deleted := scope
+
+	if !req.Replace {
+		deleted = nil
+	}
+
+	changes := paymentcosts.Diff(current, imported, deleted)
+	changeRows := make([]*PaymentCostImportRow, 0, len(changes))
+
+	for _, change := range changes {
+		row, ok := resultRows[change.Row]
+
+		if !ok {
+			row = &PaymentCostImportRow{}
+			result.Rows = append(result.Rows, row)
+		}
+
+		row.Action = change.Action
+		row.Old = change.Old
+		changeRows = append(changeRows, row)
+	}
+
+	version := &paymentcosts.Version{
+		Source:    paymentcosts.SourceImport,
+		FileName:  fileName,
+		System:    scope.System,
+		Merchants: scope.Merchants,
+	}
+
+	return h.applyPaymentCosts(ctx, result, changes, changeRows, version, current, req.Replace)
+}
+
+// listPaymentCostsVersions returns the applied imports and rollbacks from the latest one
+func (h *PaymentCostRoute) listPaymentCostsVersions(ctx echo.Context) error {
+	versions, err := h.versions.List(ctx.Request().Context())
+
+	if err != nil {
+		h.L().Error("payment costs versions load failed", logger.PairArgs("err", err.Error()))
+		return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessagePaymentCostsVersionsStorageFailed)
+	}
+
+	return ctx.JSON(http.StatusOK, versions)
+}
+
+// getPaymentCostsVersion returns the version with the snapshot, with the format the snapshot is returned as the file
+func (h *PaymentCostRoute) getPaymentCostsVersion(ctx echo.Context) error {
+	req := &paymentCostsVersionRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	version, snapshot, err := h.getVersion(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if req.Format == "" {
+		return ctx.JSON(http.StatusOK, &PaymentCostsVersion{Version: version, Snapshot: snapshot})
+	}
+
+	fileName := fmt.Sprintf(paymentCostsVersionFileName, version.Number, req.Format)
+
+	return h.writePaymentCosts(ctx, snapshot.Rows, req.Format, fileName)
+}
+
+// rollbackPaymentCosts restores the part of the cost matrix changed by the version as it was before the version.
+// The rollback is saved as the new version, with dry_run nothing is saved and only the diff is returned.
+func (h *PaymentCostRoute) rollbackPaymentCosts(ctx echo.Context) error {
+	req := &paymentCostsRollbackRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	version, snapshot, err := h.getVersion(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	current, err := h.listPaymentCosts(ctx.Request().Context(), version.Merchants)
+
+	if err != nil {
+		return err
+	}
+
+	changes := paymentcosts.Diff(current, snapshot.Previous, version.Scope())
+	result := &PaymentCostsImportResult{
+		DryRun: req.DryRun,
+		Total:  len(snapshot.Previous),
+		Rows:   make([]*PaymentCostImportRow, 0, len(changes)),
+	}
+
+	for _, change := range changes {
+		result.Rows = append(result.Rows, &PaymentCostImportRow{Action: change.Action, Cost: change.Row, Old: change.Old})
+	}
+
+	rollback := &paymentcosts.Version{
+		Source:     paymentcosts.SourceRollback,
+		RollbackOf: version.Id,
+		System:     version.System,
+		Merchants:  version.Merchants,
+	}
+
+	return h.applyPaymentCosts(ctx, result, changes, result.Rows, rollback, current, true)
+}
+
+// applyPaymentCosts checks and saves the changes, the rows are the result rows of the changes.
+// With replace nothing is saved when any row is invalid. The version is saved when any change is applied.
+func (h *PaymentCostRoute) applyPaymentCosts(
+	ctx echo.Context,
+	result *PaymentCostsImportResult,
+	changes []*paymentcosts.Change,
+	rows []*PaymentCostImportRow,
+	version *paymentcosts.Version,
+	previous []*paymentcosts.Row,
+	replace bool,
+) error {
+	for i, change := range changes {
+		if change.Action != paymentcosts.ActionCreate && change.Action != paymentcosts.ActionUpdate {
+			continue
+		}
+
+		if err := h.dispatch.Validate.Struct(getPaymentCostItem(change.Row)); err != nil {
+			rows[i].Action = paymentCostImportActionInvalid
+			rows[i].Message = common.GetValidationError(err)
+		}
+	}
+
+	result.count()
+
+	if replace && result.Failed > 0 {
+		result.Message = common.ErrorMessagePaymentCostsReplaceRowsInvalid
+		return ctx.JSON(http.StatusBadRequest, result)
+	}
+
+	if result.DryRun {
+		return ctx.JSON(http.StatusOK, result)
+	}
+
+	applied := false
+
+	for i, change := range changes {
+		row := rows[i]
+
+		if row.Action == paymentCostImportActionInvalid || row.Action == paymentcosts.ActionUnchanged {
+			continue
+		}
+
+		if msg := h.savePaymentCost(ctx.Request().Context(), change); msg != nil {
+			row.Action = paymentCostImportActionInvalid
+			row.Message = msg
+			continue
+		}
+
+		applied = true
+	}
+
+	result.count()
+
+	if !applied {
+		return ctx.JSON(http.StatusOK, result)
+	}
+
+	snapshot := &paymentcosts.Snapshot{Rows: make([]*paymentcosts.Row, 0), Previous: make([]*paymentcosts.Row, 0)}
+	scope := version.Scope()
+
+	for _, row := range previous {
+		if scope.Contains(row) {
+			snapshot.Previous = append(snapshot.Previous, row)
+		}
+	}
+
+	current, err := h.listPaymentCosts(ctx.Request().Context(), version.Merchants)
+
+	if err != nil {
+		return err
+	}
+
+	for _, row := range current {
+		if scope.Contains(row) {
+			snapshot.Rows = append(snapshot.Rows, row)
+		}
+	}
+
+	version.Created, version.Updated, version.Deleted = result.Created, result.Updated, result.Deleted
+	version.CreatedBy = common.ExtractUserContext(ctx).Id
+
+	if err = h.versions.Add(ctx.Request().Context(), version, snapshot); err != nil {
+		h.L().Error("payment costs version save failed", logger.PairArgs("err", err.Error()))
+		return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessagePaymentCostsVersionsStorageFailed)
+	}
+
+	result.Version = version
+
+	return ctx.JSON(http.StatusOK, result)
+}
+
+// savePaymentCost creates, updates or deletes the cost and returns the error message when the cost isn't saved
+func (h *PaymentCostRoute) savePaymentCost(ctx context.Context, change *paymentcosts.Change) *grpc.ResponseErrorMessage {
+	var (
+		status  int32
+		message *grpc.ResponseErrorMessage
+		err     error
+		method  string
+		req     interface{}
+	)
+
+	if change.Action == paymentcosts.ActionDelete {
+		delReq := &billing.PaymentCostDeleteRequest{Id: change.Old.Id()}
+		res := &grpc.ResponseError{}
+		req = delReq
+
+		switch change.Old.Type {
+		case paymentcosts.TypeChannelSystem:
+			method = "DeletePaymentChannelCostSystem"
+			res, err = h.dispatch.Services.Billing.DeletePaymentChannelCostSystem(ctx, delReq)
+		case paymentcosts.TypeChannelMerchant:
+			method = "DeletePaymentChannelCostMerchant"
+			res, err = h.dispatch.Services.Billing.DeletePaymentChannelCostMerchant(ctx, delReq)
+		case paymentcosts.TypeMoneyBackSystem:
+			method = "DeleteMoneyBackCostSystem"
+			res, err = h.dispatch.Services.Billing.DeleteMoneyBackCostSystem(ctx, delReq)
+		case paymentcosts.TypeMoneyBackMerchant:
+			method = "DeleteMoneyBackCostMerchant"
+			res, err = h.dispatch.Services.Billing.DeleteMoneyBackCostMerchant(ctx, delReq)
+		}
+
+		if err == nil {
+			status, message = res.Status, res.Message
+		}
+	} else {
+		switch item := getPaymentCostItem(change.Row).(type) {
+		case *billing.PaymentChannelCostSystem:
+			method, req = "SetPaymentChannelCostSystem", item
+			res, e := h.dispatch.Services.Billing.SetPaymentChannelCostSystem(ctx, item)
+
+			if err = e; err == nil {
+				status, message = res.Status, res.Message
+			}
+		case *billing.PaymentChannelCostMerchant:
+			method, req = "SetPaymentChannelCostMerchant", item
+			res, e := h.dispatch.Services.Billing.SetPaymentChannelCostMerchant(ctx, item)
+
+			if err = e; err == nil {
+				status, message = res.Status, res.Message
+			}
+		case *billing.MoneyBackCostSystem:
+			method, req = "SetMoneyBackCostSystem", item
+			res, e := h.dispatch.Services.Billing.SetMoneyBackCostSystem(ctx, item)
+
+			if err = e; err == nil {
+				status, message = res.Status, res.Message
+			}
+		case *billing.MoneyBackCostMerchant:
+			method, req = "SetMoneyBackCostMerchant", item
+			res, e := h.dispatch.Services.Billing.SetMoneyBackCostMerchant(ctx, item)
+
+			if err = e; err == nil {
+				status, message = res.Status, res.Message
+			}
+		}
+	}
+
+	if err != nil {
+		common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, method, req)
+		return common.ErrorInternal
+	}
+
+	if status != pkg.ResponseStatusOk {
+		return message
+	}
+
+	return nil
+}
+
+// listPaymentCosts returns the system costs and the costs of the merchants
+func (h *PaymentCostRoute) listPaymentCosts(ctx context.Context, merchants []string) ([]*paymentcosts.Row, error) {
+	rows := make([]*paymentcosts.Row, 0)
+	req := &grpc.EmptyRequest{}
+
+	channelSystem, err := h.dispatch.Services.Billing.GetAllPaymentChannelCostSystem(ctx, req)
+
+	if err != nil {
+		return nil, h.dispatch.SrvCallHandler(req, err, pkg.ServiceName, "GetAllPaymentChannelCostSystem")
+	}
+
+	if channelSystem.Status != pkg.ResponseStatusOk {
+		return nil, echo.NewHTTPError(int(channelSystem.Status), channelSystem.Message)
+	}
+
+	for _, item := range channelSystem.Item.GetItems() {
+		rows = append(rows, paymentcosts.FromChannelSystem(item))
+	}
+
+	moneyBackSystem, err := h.dispatch.Services.Billing.GetAllMoneyBackCostSystem(ctx, req)
+
+	if err != nil {
+		return nil, h.dispatch.SrvCallHandler(req, err, pkg.ServiceName, "GetAllMoneyBackCostSystem")
+	}
+
+	if moneyBackSystem.Status != pkg.ResponseStatusOk {
+		return nil, echo.NewHTTPError(int(moneyBackSystem.Status), moneyBackSystem.Message)
+	}
+
+	for _, item := range moneyBackSystem.Item.GetItems() {
+		rows = append(rows, paymentcosts.FromMoneyBackSystem(item))
+	}
+
+	merchantRows := make([][]*paymentcosts.Row, len(merchants))
+
+	err = forEachMerchant(ctx, merchants, func(ctx context.Context, i int) error {
+		var e error
+		merchantRows[i], e = h.listMerchantPaymentCosts(ctx, merchants[i])
+		return e
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, items := range merchantRows {
+		rows = append(rows, items...)
+	}
+
+	paymentcosts.Sort(rows)
+
+	return rows, nil
+}
+
+// listMerchantPaymentCosts returns the channel and money-back costs of the merchant
+func (h *PaymentCostRoute) listMerchantPaymentCosts(ctx context.Context, merchantId string) ([]*paymentcosts.Row, error) {
+	rows := make([]*paymentcosts.Row, 0)
+	channelReq := &billing.PaymentChannelCostMerchantListRequest{MerchantId: merchantId}
+	channelMerchant, err := h.dispatch.Services.Billing.GetAllPaymentChannelCostMerchant(ctx, channelReq)
+
+	if err != nil {
+		return nil, h.dispatch.SrvCallHandler(channelReq, err, pkg.ServiceName, "GetAllPaymentChannelCostMerchant")
+	}
+
+	if channelMerchant.Status != pkg.ResponseStatusOk {
+		return nil, echo.NewHTTPError(int(channelMerchant.Status), channelMerchant.Message)
+	}
+
+	for _, item := range channelMerchant.Item.GetItems() {
+		rows = append(rows, paymentcosts.FromChannelMerchant(item))
+	}
+
+	moneyBackReq := &billing.MoneyBackCostMerchantListRequest{MerchantId: merchantId}
+	moneyBackMerchant, err := h.dispatch.Services.Billing.GetAllMoneyBackCostMerchant(ctx, moneyBackReq)
+
+	if err != nil {
+		return nil, h.dispatch.SrvCallHandler(moneyBackReq, err, pkg.ServiceName, "GetAllMoneyBackCostMerchant")
+	}
+
+	if moneyBackMerchant.Status != pkg.ResponseStatusOk {
+		return nil, echo.NewHTTPError(int(moneyBackMerchant.Status), moneyBackMerchant.Message)
+	}
+
+	for _, item := range moneyBackMerchant.Item.GetItems() {
+		rows = append(rows, paymentcosts.FromMoneyBackMerchant(item))
+	}
+
+	return rows, nil
+}
+
+// forEachMerchant calls the function for every merchant index, at most paymentCostsMerchantsConcurrency calls run
+// at once. The first error cancels the context of the other calls and is returned.
+func forEachMerchant(ctx context.Context, merchants []string, call func(ctx context.Context, i int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg    sync.WaitGroup
+		mx    sync.Mutex
+		first error
+	)
+
+	slots := make(chan struct{}, paymentCostsMerchantsConcurrency)
+
+	for i := range merchants {
+		slots <- struct{}{}
+		wg.Add(1)
+
+		go func(i int) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			if err := call(ctx, i); err != nil {
+				mx.Lock()
+				if first == nil {
+					first = err
+				}
+				mx.Unlock()
+				cancel()
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	return first
+}
+
+func (h *PaymentCostRoute) getVersion(ctx echo.Context) (*paymentcosts.Version, *paymentcosts.Snapshot, error) {
+	version, snapshot, err := h.versions.Get(ctx.Request().Context(), ctx.Param(common.RequestParameterVersionId))
+
+	if err == paymentcosts.ErrorVersionNotFound {
+		return nil, nil, echo.NewHTTPError(http.StatusNotFound, common.ErrorMessagePaymentCostsVersionNotFound)
+	}
+
+	if err != nil {
+		h.L().Error("payment costs version load failed", logger.PairArgs("err", err.Error()))
+		return nil, nil, echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessagePaymentCostsVersionsStorageFailed)
+	}
+
+	return version, snapshot, nil
+}
+
+func (h *PaymentCostRoute) writePaymentCosts(ctx echo.Context, rows []*paymentcosts.Row, format, fileName string) error {
+	var (
+		data []byte
+		err  error
+	)
+
+	mime := taxesMimeCsv
+
+	if paymentCostsFormat(format) == paymentCostsFormatXlsx {
+		mime = xlsx.MimeType
+		data, err = paymentcosts.WriteXlsx(rows)
+	} else {
+		data, err = paymentcosts.WriteCsv(rows)
+	}
+
+	if err != nil {
+		h.L().Error("payment costs export failed", logger.PairArgs("err", err.Error()))
+		return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorUnknown)
+	}
+
+	ctx.Response().Header().Set(echo.HeaderContentDisposition, "attachment; filename="+fileName)
+
+	return ctx.Blob(http.StatusOK, mime, data)
+}
+
+// readPaymentCostsImportFile reads the lines of the multipart CSV or XLSX file with the header row
+func readPaymentCostsImportFile(ctx echo.Context) (string, []*paymentcosts.Line, error) {
+	file, err := ctx.FormFile(common.RequestParameterFile)
+
+	if err != nil {
+		return "", nil, echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessagePaymentCostsImportFileInvalid)
+	}
+
+	src, err := file.Open()
+
+	if err != nil {
+		return "", nil, echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessagePaymentCostsImportFileInvalid)
+	}
+
+	defer func() {
+		if err := src.Close(); err != nil {
+			return
+		}
+	}()
+
+	data, err := ioutil.ReadAll(src)
+
+	if err != nil {
+		return "", nil, echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessagePaymentCostsImportFileInvalid)
+	}
+
+	table, err := paymentcosts.ReadTable(data)
+
+	if err == nil && len(table) > paymentCostsImportRowsMax+1 {
+		return "", nil, echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessagePaymentCostsImportTooManyRows)
+	}
+
+	var lines []*paymentcosts.Line
+
+	if err == nil {
+		lines, err = paymentcosts.Parse(table)
+	}
+
+	if err != nil || len(lines) <= 0 {
+		msg := common.ErrorMessagePaymentCostsImportFileInvalid
+
+		if err != nil {
+			msg = common.NewManagementApiResponseError(msg.Code, msg.Message, err.Error())
+		}
+
+		return "", nil, echo.NewHTTPError(http.StatusBadRequest, msg)
+	}
+
+	return file.Filename, lines, nil
+}
+
+// getPaymentCostItem returns the billing server cost of the row
+func getPaymentCostItem(row *paymentcosts.Row) interface{} {
+	switch row.Type {
+	case paymentcosts.TypeChannelSystem:
+		return row.ChannelSystem()
+	case paymentcosts.TypeChannelMerchant:
+		return row.ChannelMerchant()
+	case paymentcosts.TypeMoneyBackSystem:
+		return row.MoneyBackSystem()
+	}
+
+	return row.MoneyBackMerchant()
+}
+
+func paymentCostsFormat(format string) string {
+	if format == paymentCostsFormatXlsx {
+		return paymentCostsFormatXlsx
+	}
+
+	return paymentCostsFormatCsv
+}
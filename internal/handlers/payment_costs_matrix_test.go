@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/labstack/echo/v4"
+	"github.com/micro/go-micro/client"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/mock"
+	"github.com/paysuper/paysuper-management-api/internal/paymentcosts"
+	"github.com/paysuper/paysuper-management-api/internal/test"
+	"github.com/paysuper/paysuper-management-api/internal/xlsx"
+	mock2 "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const (
+	paymentCostsImportTestCsv = "type,merchant_id,method,region,country,payout_currency,percent,fix_amount,fix_amount_currency\n" +
+		"channel_system,,VISA,CIS,AZ,,2.2,0.01,USD\n" +
+		"channel_system,,MASTERCARD,CIS,AZ,,1.5,0,USD\n" +
+		"channel_system,,VISA,CIS,AM,,1.5,0,USD\n" +
+		"channel_system,,VISA,,RU,,1.5,0,USD\n" +
+		"channel_system,,VISA,CIS,AZ,,3,0,USD\n"
+)
+
+type PaymentCostsMatrixTestSuite struct {
+	suite.Suite
+	router  *PaymentCostRoute
+	caller  *test.EchoReqResCaller
+	billing *mocks.BillingService
+	costs   []*billing.PaymentChannelCostSystem
+	files   map[string][]byte
+}
+
+func Test_PaymentCostsMatrix(t *testing.T) {
+	suite.Run(t, new(PaymentCostsMatrixTestSuite))
+}
+
+func (suite *PaymentCostsMatrixTestSuite) SetupTest() {
+	user := &common.AuthUser{
+		Id:    "ffffffffffffffffffffffff",
+		Email: "test@unit.test",
+	}
+	suite.costs = []*billing.PaymentChannelCostSystem{
+		{Id: "5dc3f6c5ad8b8c0001b1e2b1", Name: "VISA", Region: "CIS", Country: "AZ", Percent: 1.5, FixAmountCurrency: "USD"},
+		{Id: "5dc3f6c5ad8b8c0001b1e2b2", Name: "MASTERCARD", Region: "CIS", Country: "AZ", Percent: 1.5, FixAmountCurrency: "USD"},
+	}
+	suite.files = make(map[string][]byte)
+
+	suite.billing = &mocks.BillingService{}
+	suite.billing.On("GetAllPaymentChannelCostSystem", mock2.Anything, mock2.Anything).
+		Return(
+			func(ctx context.Context, in *grpc.EmptyRequest, opts ...client.CallOption) *grpc.PaymentChannelCostSystemListResponse {
+				items := make([]*billing.PaymentChannelCostSystem, 0, len(suite.costs))
+				for _, item := range suite.costs {
+					copied := *item
+					items = append(items, &copied)
+				}
+				return &grpc.PaymentChannelCostSystemListResponse{
+					Status: pkg.ResponseStatusOk,
+					Item:   &billing.PaymentChannelCostSystemList{Items: items},
+				}
+			},
+			nil,
+		)
+	suite.billing.On("GetAllMoneyBackCostSystem", mock2.Anything, mock2.Anything).
+		Return(&grpc.MoneyBackCostSystemListResponse{Status: pkg.ResponseStatusOk}, nil)
+	suite.billing.On("SetPaymentChannelCostSystem", mock2.Anything, mock2.Anything).
+		Run(func(args mock2.Arguments) {
+			in := args.Get(1).(*billing.PaymentChannelCostSystem)
+			for i, item := range suite.costs {
+				if item.Id == in.Id {
+					suite.costs[i] = in
+					return
+				}
+			}
+			in.Id = "5dc3f6c5ad8b8c0001b1e2c" + strconv.Itoa(len(suite.costs))
+			suite.costs = append(suite.costs, in)
+		}).
+		Return(&grpc.PaymentChannelCostSystemResponse{Status: pkg.ResponseStatusOk}, nil)
+	suite.billing.On("DeletePaymentChannelCostSystem", mock2.Anything, mock2.Anything).
+		Run(func(args mock2.Arguments) {
+			in := args.Get(1).(*billing.PaymentCostDeleteRequest)
+			for i, item := range suite.costs {
+				if item.Id == in.Id {
+					suite.costs = append(suite.costs[:i], suite.costs[i+1:]...)
+					return
+				}
+			}
+		}).
+		Return(&grpc.ResponseError{Status: pkg.ResponseStatusOk}, nil)
+
+	var e error
+	settings := test.DefaultSettings()
+	srv := common.Services{
+		Billing: suite.billing,
+	}
+	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
+		mw.Pre(test.PreAuthUserMiddleware(user))
+		versions := paymentcosts.NewVersions(mock.NewAwsManagerFilesMock(suite.files))
+		suite.router = NewPaymentCostRoute(set.HandlerSet, versions, set.GlobalConfig)
+		return common.Handlers{
+			suite.router,
+		}
+	})
+	if e != nil {
+		panic(e)
+	}
+}
+
+func (suite *PaymentCostsMatrixTestSuite) TearDownTest() {}
+
+func (suite *PaymentCostsMatrixTestSuite) importPaymentCosts(content string, fields map[string]string) *PaymentCostsImportResult {
+	return suite.importPaymentCostsStatus(content, fields, http.StatusOK)
+}
+
+func (suite *PaymentCostsMatrixTestSuite) importPaymentCostsStatus(
+	content string,
+	fields map[string]string,
+	status int,
+) *PaymentCostsImportResult {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	for key, value := range fields {
+		require.NoError(suite.T(), writer.WriteField(key, value))
+	}
+
+	part, err := writer.CreateFormFile(common.RequestParameterFile, "payment_costs.csv")
+	require.NoError(suite.T(), err)
+	_, err = part.Write([]byte(content))
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), writer.Close())
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.SystemUserGroupPath + paymentCostsImportPath).
+		Init(func(request *http.Request, middleware test.Middleware) {
+			request.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+		}).
+		Body(body).
+		Exec(suite.T())
+
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), status, res.Code)
+
+	result := &PaymentCostsImportResult{}
+	require.NoError(suite.T(), json.Unmarshal(res.Body.Bytes(), result))
+
+	return result
+}
+
+func (suite *PaymentCostsMatrixTestSuite) TestPaymentCostsMatrix_Export_Csv_Ok() {
+	shouldBe := require.New(suite.T())
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Path(common.SystemUserGroupPath + paymentCostsExportPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+	shouldBe.Contains(res.Header().Get(echo.HeaderContentDisposition), "payment_costs.csv")
+
+	lines := strings.Split(strings.TrimSpace(res.Body.String()), "\n")
+	shouldBe.Len(lines, 3)
+	shouldBe.Equal(strings.Join(paymentcosts.Columns, ","), lines[0])
+	shouldBe.Equal("channel_system,,MASTERCARD,CIS,AZ,,,1.5,0,USD,,,,,,,", lines[1])
+	shouldBe.Equal("channel_system,,VISA,CIS,AZ,,,1.5,0,USD,,,,,,,", lines[2])
+}
+
+func (suite *PaymentCostsMatrixTestSuite) TestPaymentCostsMatrix_Export_Xlsx_Ok() {
+	shouldBe := require.New(suite.T())
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Path(common.SystemUserGroupPath+paymentCostsExportPath).
+		SetQueryParam("format", paymentCostsFormatXlsx).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+	shouldBe.Equal(xlsx.MimeType, res.Header().Get(echo.HeaderContentType))
+
+	table, err := paymentcosts.ReadTable(res.Body.Bytes())
+	shouldBe.NoError(err)
+	lines, err := paymentcosts.Parse(table)
+	shouldBe.NoError(err)
+	shouldBe.Len(lines, 2)
+	shouldBe.Equal("VISA", lines[1].Row.Method)
+	shouldBe.EqualValues(1.5, lines[1].Row.Percent)
+}
+
+func (suite *PaymentCostsMatrixTestSuite) TestPaymentCostsMatrix_Import_DryRun_Ok() {
+	shouldBe := require.New(suite.T())
+
+	result := suite.importPaymentCosts(paymentCostsImportTestCsv, map[string]string{"dry_run": "true"})
+	shouldBe.True(result.DryRun)
+	shouldBe.Equal(5, result.Total)
+	shouldBe.Equal(1, result.Created)
+	shouldBe.Equal(1, result.Updated)
+	shouldBe.Equal(1, result.Unchanged)
+	shouldBe.Equal(0, result.Deleted)
+	shouldBe.Equal(2, result.Failed)
+	shouldBe.Nil(result.Version)
+
+	shouldBe.Equal(paymentcosts.ActionUpdate, result.Rows[0].Action)
+	shouldBe.EqualValues(1.5, result.Rows[0].Old.Percent)
+	shouldBe.Equal(paymentcosts.ActionUnchanged, result.Rows[1].Action)
+	shouldBe.Equal(paymentcosts.ActionCreate, result.Rows[2].Action)
+	shouldBe.Equal(paymentCostImportActionInvalid, result.Rows[3].Action)
+	shouldBe.Equal(common.ErrorMessagePaymentCostsImportValueInvalid.Code, result.Rows[3].Message.Code)
+	shouldBe.Equal(paymentcosts.ColumnRegion, result.Rows[3].Message.Details)
+	shouldBe.Equal(paymentCostImportActionInvalid, result.Rows[4].Action)
+	shouldBe.Equal(common.ErrorMessagePaymentCostsImportRowDuplicated.Code, result.Rows[4].Message.Code)
+
+	suite.billing.AssertNotCalled(suite.T(), "SetPaymentChannelCostSystem", mock2.Anything, mock2.Anything)
+	shouldBe.Empty(suite.files)
+}
+
+func (suite *PaymentCostsMatrixTestSuite) TestPaymentCostsMatrix_Import_Replace_Delete() {
+	shouldBe := require.New(suite.T())
+
+	csv := "type,method,region,country,percent,fix_amount,fix_amount_currency\n" +
+		"channel_system,VISA,CIS,AZ,1.5,0,USD\n"
+
+	result := suite.importPaymentCosts(csv, map[string]string{"dry_run": "true"})
+	shouldBe.Equal(0, result.Deleted)
+
+	result = suite.importPaymentCosts(csv, map[string]string{"dry_run": "true", "replace": "true"})
+	shouldBe.Equal(1, result.Deleted)
+	shouldBe.Equal(paymentcosts.ActionDelete, result.Rows[1].Action)
+	shouldBe.Equal("MASTERCARD", result.Rows[1].Old.Method)
+}
+
+func (suite *PaymentCostsMatrixTestSuite) TestPaymentCostsMatrix_Import_Replace_InvalidRows_Aborted() {
+	shouldBe := require.New(suite.T())
+
+	// the typo in the rate of MASTERCARD must not delete the MASTERCARD cost
+	csv := "type,method,region,country,percent,fix_amount,fix_amount_currency\n" +
+		"channel_system,VISA,CIS,AZ,2,0,USD\n" +
+		"channel_system,MASTERCARD,CIS,AZ,1..5,0,USD\n"
+
+	result := suite.importPaymentCostsStatus(csv, map[string]string{"replace": "true"}, http.StatusBadRequest)
+	shouldBe.Equal(common.ErrorMessagePaymentCostsReplaceRowsInvalid.Code, result.Message.Code)
+	shouldBe.Equal(1, result.Failed)
+	shouldBe.Nil(result.Version)
+
+	suite.billing.AssertNotCalled(suite.T(), "SetPaymentChannelCostSystem", mock2.Anything, mock2.Anything)
+	suite.billing.AssertNotCalled(suite.T(), "DeletePaymentChannelCostSystem", mock2.Anything, mock2.Anything)
+	shouldBe.Len(suite.costs, 2)
+	shouldBe.Empty(suite.files)
+}
+
+func (suite *PaymentCostsMatrixTestSuite) TestPaymentCostsMatrix_Import_Apply_Rollback_Ok() {
+	shouldBe := require.New(suite.T())
+
+	result := suite.importPaymentCosts(paymentCostsImportTestCsv, nil)
+	shouldBe.False(result.DryRun)
+	shouldBe.Equal(1, result.Created)
+	shouldBe.Equal(1, result.Updated)
+	shouldBe.NotNil(result.Version)
+	shouldBe.Equal(1, result.Version.Number)
+	shouldBe.Equal(paymentcosts.SourceImport, result.Version.Source)
+	shouldBe.Equal("payment_costs.csv", result.Version.FileName)
+	shouldBe.True(result.Version.System)
+	shouldBe.Equal("ffffffffffffffffffffffff", result.Version.CreatedBy)
+	shouldBe.Len(suite.costs, 3)
+	shouldBe.EqualValues(2.2, suite.costs[0].Percent)
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterVersionId, result.Version.Id).
+		Path(common.SystemUserGroupPath + paymentCostsVersionIdPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+
+	version := &PaymentCostsVersion{}
+	shouldBe.NoError(json.Unmarshal(res.Body.Bytes(), version))
+	shouldBe.Len(version.Rows, 3)
+	shouldBe.Len(version.Previous, 2)
+
+	res, err = suite.caller.Builder().
+		Method(http.MethodPost).
+		Params(":"+common.RequestParameterVersionId, result.Version.Id).
+		Path(common.SystemUserGroupPath + paymentCostsVersionRollbackPath).
+		Init(test.ReqInitJSON()).
+		BodyString(`{}`).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+
+	rollback := &PaymentCostsImportResult{}
+	shouldBe.NoError(json.Unmarshal(res.Body.Bytes(), rollback))
+	shouldBe.Equal(1, rollback.Updated)
+	shouldBe.Equal(1, rollback.Deleted)
+	shouldBe.NotNil(rollback.Version)
+	shouldBe.Equal(2, rollback.Version.Number)
+	shouldBe.Equal(paymentcosts.SourceRollback, rollback.Version.Source)
+	shouldBe.Equal(result.Version.Id, rollback.Version.RollbackOf)
+	shouldBe.Len(suite.costs, 2)
+	shouldBe.EqualValues(1.5, suite.costs[0].Percent)
+
+	res, err = suite.caller.Builder().
+		Method(http.MethodGet).
+		Path(common.SystemUserGroupPath + paymentCostsVersionsPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+
+	var versions []*paymentcosts.Version
+	shouldBe.NoError(json.Unmarshal(res.Body.Bytes(), &versions))
+	shouldBe.Len(versions, 2)
+	shouldBe.Equal(rollback.Version.Id, versions[0].Id)
+}
+
+func (suite *PaymentCostsMatrixTestSuite) TestPaymentCostsMatrix_Import_FileInvalid() {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile(common.RequestParameterFile, "payment_costs.csv")
+	require.NoError(suite.T(), err)
+	_, err = part.Write([]byte("type,method,region,percent,fix_amount,color\n"))
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), writer.Close())
+
+	_, err = suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.SystemUserGroupPath + paymentCostsImportPath).
+		Init(func(request *http.Request, middleware test.Middleware) {
+			request.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+		}).
+		Body(body).
+		Exec(suite.T())
+
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), http.StatusBadRequest, httpErr.Code)
+	require.Equal(suite.T(), common.ErrorMessagePaymentCostsImportFileInvalid.Code, httpErr.Message.(*grpc.ResponseErrorMessage).Code)
+}
+
+func (suite *PaymentCostsMatrixTestSuite) TestPaymentCostsMatrix_Version_NotFound() {
+	_, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterVersionId, "unknown").
+		Path(common.SystemUserGroupPath + paymentCostsVersionIdPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), http.StatusNotFound, httpErr.Code)
+	require.Equal(suite.T(), common.ErrorMessagePaymentCostsVersionNotFound, httpErr.Message)
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"github.com/ProtocolONE/go-core/v2/pkg/logger"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/paymentcosts"
+	"net/http"
+	"strings"
+)
+
+const (
+	paymentCostsOverridesPath = "/payment_costs/overrides"
+
+	paymentCostsOverridesFileName      = "payment_costs_overrides.csv"
+	paymentCostsOverridesMerchantLimit = 100
+)
+
+type paymentCostsOverridesRequest struct {
+	Method    string   `query:"method"`
+	Region    string   `query:"region"`
+	Merchants []string `query:"merchant_id" validate:"omitempty,dive,hexadecimal,len=24"`
+	Format    string   `query:"format" validate:"omitempty,oneof=csv"`
+}
+
+// PaymentCostsOverrides is the report of the merchant costs overriding the system ones
+type PaymentCostsOverrides struct {
+	Count     int                      `json:"count"`
+	Merchants int                      `json:"merchants"`
+	Items     []*paymentcosts.Override `json:"items"`
+}
+
+// getPaymentCostsOverrides returns the channel and money-back costs of the merchants next to the matching
+// system costs with the deltas, with the csv format the report is returned as the file
+func (h *PaymentCostRoute) getPaymentCostsOverrides(ctx echo.Context) error {
+	req := &paymentCostsOverridesRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	names, err := h.listMerchantNames(ctx.Request().Context(), req.Merchants)
+
+	if err != nil {
+		return err
+	}
+
+	merchants := make([]string, 0, len(names))
+
+	for merchantId := range names {
+		merchants = append(merchants, merchantId)
+	}
+
+	rows, err := h.listPaymentCosts(ctx.Request().Context(), merchants)
+
+	if err != nil {
+		return err
+	}
+
+	report := &PaymentCostsOverrides{Items: make([]*paymentcosts.Override, 0)}
+	filtered := make(map[string]bool)
+
+	for _, override := range paymentcosts.Overrides(rows, names) {
+		if req.Method != "" && !strings.EqualFold(override.Cost.Method, req.Method) {
+			continue
+		}
+
+		if req.Region != "" && !strings.EqualFold(override.Cost.Region, req.Region) {
+			continue
+		}
+
+		report.Items = append(report.Items, override)
+		filtered[override.Cost.MerchantId] = true
+	}
+
+	report.Count = len(report.Items)
+	report.Merchants = len(filtered)
+
+	if req.Format != paymentCostsFormatCsv {
+		return ctx.JSON(http.StatusOK, report)
+	}
+
+	data, err := paymentcosts.WriteOverridesCsv(report.Items)
+
+	if err != nil {
+		h.L().Error("payment costs overrides export failed", logger.PairArgs("err", err.Error()))
+		return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorUnknown)
+	}
+
+	ctx.Response().Header().Set(echo.HeaderContentDisposition, "attachment; filename="+paymentCostsOverridesFileName)
+
+	return ctx.Blob(http.StatusOK, taxesMimeCsv, data)
+}
+
+// listMerchantNames returns the names of all merchants by their identifiers, with the filter only the requested
+// merchants are requested, the unknown ones are skipped
+func (h *PaymentCostRoute) listMerchantNames(ctx context.Context, filter []string) (map[string]string, error) {
+	if len(filter) > 0 {
+		return h.getMerchantNames(ctx, filter)
+	}
+
+	names := make(map[string]string)
+	req := &grpc.MerchantListingRequest{Limit: paymentCostsOverridesMerchantLimit}
+
+	for {
+		res, err := h.dispatch.Services.Billing.ListMerchants(ctx, req)
+
+		if err != nil {
+			return nil, h.dispatch.SrvCallHandler(req, err, pkg.ServiceName, "ListMerchants")
+		}
+
+		for _, merchant := range res.Items {
+			names[merchant.Id] = merchant.GetCompany().GetName()
+		}
+
+		if int64(len(res.Items)) < req.Limit {
+			break
+		}
+
+		req.Offset += req.Limit
+	}
+
+	return names, nil
+}
+
+func (h *PaymentCostRoute) getMerchantNames(ctx context.Context, filter []string) (map[string]string, error) {
+	merchants := make([]string, 0, len(filter))
+
+	for _, merchantId := range filter {
+		if !containsMerchantId(merchants, merchantId) {
+			merchants = append(merchants, strings.ToLower(merchantId))
+		}
+	}
+
+	found := make([]*billing.Merchant, len(merchants))
+
+	err := forEachMerchant(ctx, merchants, func(ctx context.Context, i int) error {
+		req := &grpc.GetMerchantByRequest{MerchantId: merchants[i]}
+		res, err := h.dispatch.Services.Billing.GetMerchantBy(ctx, req)
+
+		if err != nil {
+			return h.dispatch.SrvCallHandler(req, err, pkg.ServiceName, "GetMerchantBy")
+		}
+
+		switch res.Status {
+		case pkg.ResponseStatusOk:
+			found[i] = res.Item
+		case pkg.ResponseStatusNotFound:
+		default:
+			return echo.NewHTTPError(int(res.Status), res.Message)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string, len(found))
+
+	for _, merchant := range found {
+		if merchant != nil {
+			names[merchant.Id] = merchant.GetCompany().GetName()
+		}
+	}
+
+	return names, nil
+}
+
+func containsMerchantId(merchants []string, merchantId string) bool {
+	for _, id := range merchants {
+		if strings.EqualFold(id, merchantId) {
+			return true
+		}
+	}
+
+	return false
+}
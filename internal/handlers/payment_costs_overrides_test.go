@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/mock"
+	"github.com/paysuper/paysuper-management-api/internal/paymentcosts"
+	"github.com/paysuper/paysuper-management-api/internal/test"
+	mock2 "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const (
+	paymentCostsOverridesTestMerchant1 = "5dc3f6c5ad8b8c0001b1e2b1"
+	paymentCostsOverridesTestMerchant2 = "5dc3f6c5ad8b8c0001b1e2b2"
+)
+
+type PaymentCostsOverridesTestSuite struct {
+	suite.Suite
+	router  *PaymentCostRoute
+	caller  *test.EchoReqResCaller
+	billing *mocks.BillingService
+}
+
+func Test_PaymentCostsOverrides(t *testing.T) {
+	suite.Run(t, new(PaymentCostsOverridesTestSuite))
+}
+
+func (suite *PaymentCostsOverridesTestSuite) SetupTest() {
+	user := &common.AuthUser{
+		Id:    "ffffffffffffffffffffffff",
+		Email: "test@unit.test",
+	}
+
+	suite.billing = &mocks.BillingService{}
+	suite.billing.On("ListMerchants", mock2.Anything, mock2.Anything).
+		Return(&grpc.MerchantListingResponse{
+			Count: 2,
+			Items: []*billing.Merchant{
+				{Id: paymentCostsOverridesTestMerchant1, Company: &billing.MerchantCompanyInfo{Name: "First"}},
+				{Id: paymentCostsOverridesTestMerchant2, Company: &billing.MerchantCompanyInfo{Name: "Second"}},
+			},
+		}, nil)
+	suite.billing.On("GetMerchantBy", mock2.Anything, &grpc.GetMerchantByRequest{MerchantId: paymentCostsOverridesTestMerchant2}).
+		Return(&grpc.GetMerchantResponse{
+			Status: pkg.ResponseStatusOk,
+			Item:   &billing.Merchant{Id: paymentCostsOverridesTestMerchant2, Company: &billing.MerchantCompanyInfo{Name: "Second"}},
+		}, nil)
+	suite.billing.On("GetMerchantBy", mock2.Anything, mock2.Anything).
+		Return(&grpc.GetMerchantResponse{Status: pkg.ResponseStatusNotFound, Message: mock.SomeError}, nil)
+	suite.billing.On("GetAllPaymentChannelCostSystem", mock2.Anything, mock2.Anything).
+		Return(&grpc.PaymentChannelCostSystemListResponse{
+			Status: pkg.ResponseStatusOk,
+			Item: &billing.PaymentChannelCostSystemList{
+				Items: []*billing.PaymentChannelCostSystem{
+					{Name: "VISA", Region: "CIS", Country: "AZ", Percent: 1.5, FixAmount: 0.01, FixAmountCurrency: "USD"},
+					{Name: "MASTERCARD", Region: "CIS", Country: "AZ", Percent: 2, FixAmountCurrency: "USD"},
+				},
+			},
+		}, nil)
+	suite.billing.On("GetAllMoneyBackCostSystem", mock2.Anything, mock2.Anything).
+		Return(&grpc.MoneyBackCostSystemListResponse{
+			Status: pkg.ResponseStatusOk,
+			Item: &billing.MoneyBackCostSystemList{
+				Items: []*billing.MoneyBackCostSystem{
+					{Name: "VISA", Region: "CIS", Country: "AZ", PayoutCurrency: "USD", UndoReason: "refund", PaymentStage: 1, Percent: 3},
+				},
+			},
+		}, nil)
+	suite.billing.On("GetAllPaymentChannelCostMerchant", mock2.Anything, mock2.MatchedBy(func(req *billing.PaymentChannelCostMerchantListRequest) bool {
+		return req.MerchantId == paymentCostsOverridesTestMerchant1
+	})).
+		Return(&grpc.PaymentChannelCostMerchantListResponse{
+			Status: pkg.ResponseStatusOk,
+			Item: &billing.PaymentChannelCostMerchantList{
+				Items: []*billing.PaymentChannelCostMerchant{
+					{MerchantId: paymentCostsOverridesTestMerchant1, Name: "VISA", Region: "CIS", Country: "AZ", PayoutCurrency: "USD",
+						MethodPercent: 2.5, MethodFixAmount: 0.2, MethodFixAmountCurrency: "USD"},
+				},
+			},
+		}, nil)
+	suite.billing.On("GetAllPaymentChannelCostMerchant", mock2.Anything, mock2.Anything).
+		Return(&grpc.PaymentChannelCostMerchantListResponse{
+			Status: pkg.ResponseStatusOk,
+			Item: &billing.PaymentChannelCostMerchantList{
+				Items: []*billing.PaymentChannelCostMerchant{
+					{MerchantId: paymentCostsOverridesTestMerchant2, Name: "MASTERCARD", Region: "CIS", Country: "AZ", PayoutCurrency: "USD",
+						MethodPercent: 1, MethodFixAmountCurrency: "USD"},
+				},
+			},
+		}, nil)
+	suite.billing.On("GetAllMoneyBackCostMerchant", mock2.Anything, mock2.MatchedBy(func(req *billing.MoneyBackCostMerchantListRequest) bool {
+		return req.MerchantId == paymentCostsOverridesTestMerchant1
+	})).
+		Return(&grpc.MoneyBackCostMerchantListResponse{
+			Status: pkg.ResponseStatusOk,
+			Item: &billing.MoneyBackCostMerchantList{
+				Items: []*billing.MoneyBackCostMerchant{
+					{MerchantId: paymentCostsOverridesTestMerchant1, Name: "VISA", Region: "CIS", Country: "AZ", PayoutCurrency: "USD",
+						UndoReason: "refund", PaymentStage: 1, Percent: 2},
+				},
+			},
+		}, nil)
+	suite.billing.On("GetAllMoneyBackCostMerchant", mock2.Anything, mock2.Anything).
+		Return(&grpc.MoneyBackCostMerchantListResponse{Status: pkg.ResponseStatusOk}, nil)
+
+	var e error
+	settings := test.DefaultSettings()
+	srv := common.Services{
+		Billing: suite.billing,
+	}
+	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
+		mw.Pre(test.PreAuthUserMiddleware(user))
+		versions := paymentcosts.NewVersions(mock.NewAwsManagerFilesMock(make(map[string][]byte)))
+		suite.router = NewPaymentCostRoute(set.HandlerSet, versions, set.GlobalConfig)
+		return common.Handlers{
+			suite.router,
+		}
+	})
+	if e != nil {
+		panic(e)
+	}
+}
+
+func (suite *PaymentCostsOverridesTestSuite) TearDownTest() {}
+
+func (suite *PaymentCostsOverridesTestSuite) getOverrides(params url.Values) *PaymentCostsOverrides {
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Path(common.SystemUserGroupPath + paymentCostsOverridesPath).
+		SetQueryParams(params).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), http.StatusOK, res.Code)
+
+	report := &PaymentCostsOverrides{}
+	require.NoError(suite.T(), json.Unmarshal(res.Body.Bytes(), report))
+
+	return report
+}
+
+func (suite *PaymentCostsOverridesTestSuite) TestPaymentCostsOverrides_Ok() {
+	shouldBe := require.New(suite.T())
+
+	report := suite.getOverrides(url.Values{})
+	shouldBe.Equal(3, report.Count)
+	shouldBe.Equal(2, report.Merchants)
+
+	visa := report.Items[0]
+	shouldBe.Equal(paymentcosts.TypeChannelMerchant, visa.Cost.Type)
+	shouldBe.Equal("First", visa.MerchantName)
+	shouldBe.NotNil(visa.System)
+	shouldBe.EqualValues(1.5, visa.System.Percent)
+	shouldBe.EqualValues(1, *visa.PercentDelta)
+	shouldBe.EqualValues(0.19, *visa.FixAmountDelta)
+
+	shouldBe.Equal("Second", report.Items[1].MerchantName)
+	shouldBe.EqualValues(-1, *report.Items[1].PercentDelta)
+
+	shouldBe.Equal(paymentcosts.TypeMoneyBackMerchant, report.Items[2].Cost.Type)
+	shouldBe.EqualValues(-1, *report.Items[2].PercentDelta)
+}
+
+func (suite *PaymentCostsOverridesTestSuite) TestPaymentCostsOverrides_Filter() {
+	shouldBe := require.New(suite.T())
+
+	report := suite.getOverrides(url.Values{"method": []string{"visa"}})
+	shouldBe.Equal(2, report.Count)
+	shouldBe.Equal(1, report.Merchants)
+
+	report = suite.getOverrides(url.Values{"region": []string{"EU"}})
+	shouldBe.Equal(0, report.Count)
+	shouldBe.NotNil(report.Items)
+
+	report = suite.getOverrides(url.Values{"merchant_id": []string{paymentCostsOverridesTestMerchant2, "5dc3f6c5ad8b8c0001b1e2ff"}})
+	shouldBe.Equal(1, report.Count)
+	shouldBe.Equal(1, report.Merchants)
+	shouldBe.Equal(paymentCostsOverridesTestMerchant2, report.Items[0].Cost.MerchantId)
+	shouldBe.Equal("Second", report.Items[0].MerchantName)
+	suite.billing.AssertNumberOfCalls(suite.T(), "ListMerchants", 2)
+	suite.billing.AssertNotCalled(suite.T(), "GetAllMoneyBackCostMerchant", mock2.Anything,
+		mock2.MatchedBy(func(req *billing.MoneyBackCostMerchantListRequest) bool {
+			return req.MerchantId == paymentCostsOverridesTestMerchant1
+		}))
+}
+
+func (suite *PaymentCostsOverridesTestSuite) TestPaymentCostsOverrides_Csv_Ok() {
+	shouldBe := require.New(suite.T())
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Path(common.SystemUserGroupPath+paymentCostsOverridesPath).
+		SetQueryParam("format", paymentCostsFormatCsv).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+	shouldBe.Contains(res.Header().Get(echo.HeaderContentDisposition), paymentCostsOverridesFileName)
+
+	lines := strings.Split(strings.TrimSpace(res.Body.String()), "\n")
+	shouldBe.Len(lines, 4)
+	shouldBe.Equal(strings.Join(paymentcosts.OverrideColumns, ","), lines[0])
+	shouldBe.True(strings.HasPrefix(lines[1], "channel_merchant,"+paymentCostsOverridesTestMerchant1+",First,VISA,"))
+}
+
+func (suite *PaymentCostsOverridesTestSuite) TestPaymentCostsOverrides_ValidationError() {
+	_, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Path(common.SystemUserGroupPath+paymentCostsOverridesPath).
+		SetQueryParam("merchant_id", "unknown").
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), http.StatusBadRequest, httpErr.Code)
+}
+
+func (suite *PaymentCostsOverridesTestSuite) TestPaymentCostsOverrides_BillingServer_Error() {
+	billingService := &mocks.BillingService{}
+	billingService.On("ListMerchants", mock2.Anything, mock2.Anything).Return(nil, errors.New("error"))
+	suite.router.dispatch.Services.Billing = billingService
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Path(common.SystemUserGroupPath + paymentCostsOverridesPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), http.StatusInternalServerError, httpErr.Code)
+}
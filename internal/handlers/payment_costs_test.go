@@ -4,6 +4,7 @@ import (
 	"github.com/globalsign/mgo/bson"
 	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
 	"github.com/paysuper/paysuper-management-api/internal/mock"
+	"github.com/paysuper/paysuper-management-api/internal/paymentcosts"
 	"github.com/paysuper/paysuper-management-api/internal/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -34,7 +35,7 @@ func (suite *PaymentCostTestSuite) SetupTest() {
 	}
 	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
 		mw.Pre(test.PreAuthUserMiddleware(user))
-		suite.router = NewPaymentCostRoute(set.HandlerSet, set.GlobalConfig)
+		suite.router = NewPaymentCostRoute(set.HandlerSet, paymentcosts.NewVersions(mock.NewAwsManagerFilesMock(make(map[string][]byte))), set.GlobalConfig)
 		return common.Handlers{
 			suite.router,
 		}
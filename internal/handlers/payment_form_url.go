@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"github.com/ProtocolONE/go-core/v2/pkg/logger"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/paymentform"
+	"net/http"
+	"time"
+)
+
+// newPaymentFormUrlBuilder returns the builder of the signed payment form urls
+func newPaymentFormUrlBuilder(cfg *common.Config) *paymentform.Builder {
+	return paymentform.NewBuilder(cfg.OrderInlineFormUrlMask, cfg.PaymentFormUrlSecret, cfg.PaymentFormUrlLifetime)
+}
+
+// buildPaymentFormUrl returns the payment form url of the order or token with the optional form parameters
+// (locale, theme and mode) passed in the query of the request
+func buildPaymentFormUrl(ctx echo.Context, builder *paymentform.Builder, orderId, token string) (string, error) {
+	params := &paymentform.Params{
+		OrderId: orderId,
+		Token:   token,
+		Locale:  ctx.QueryParam(paymentform.ParamLocale),
+		Theme:   ctx.QueryParam(paymentform.ParamTheme),
+		Mode:    ctx.QueryParam(paymentform.ParamMode),
+	}
+
+	formUrl, err := builder.Build(params, time.Now())
+
+	if err != nil {
+		return "", echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessagePaymentFormUrlParamsInvalid)
+	}
+
+	return formUrl, nil
+}
+
+// paymentFormUnsignedUntilLayout is the date layout of the unsigned payment form requests migration
+const paymentFormUnsignedUntilLayout = "2006-01-02"
+
+// parsePaymentFormUnsignedUntil returns the end of the migration period of the unsigned payment form requests.
+// The empty date returns nil, so the unsigned requests are accepted until the end of the migration is set.
+func parsePaymentFormUnsignedUntil(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	until, err := time.Parse(paymentFormUnsignedUntilLayout, value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &until, nil
+}
+
+// verifyPaymentFormUrl checks the signed payment form parameters passed in the query of the request.
+// The unsigned requests are accepted only until the end of the migration period, if it's set, and return nil parameters,
+// the altered or expired urls are rejected. The url signed for the order opens only that order,
+// the url signed for the token opens only the orders created from the token.
+func (h *OrderRoute) verifyPaymentFormUrl(ctx echo.Context, orderId string) (*paymentform.Params, error) {
+	query := ctx.QueryParams()
+	now := time.Now()
+
+	if !paymentform.IsSigned(query) {
+		if h.paymentFormUnsignedUntil == nil || now.Before(*h.paymentFormUnsignedUntil) {
+			return nil, nil
+		}
+
+		return nil, echo.NewHTTPError(http.StatusForbidden, common.ErrorMessagePaymentFormUrlSignatureRequired)
+	}
+
+	params, err := h.paymentForm.Verify(query, now)
+
+	if err == paymentform.ErrorExpired {
+		return nil, echo.NewHTTPError(http.StatusGone, common.ErrorMessagePaymentFormUrlExpired)
+	}
+
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusForbidden, common.ErrorMessagePaymentFormUrlSignatureInvalid)
+	}
+
+	if params.OrderId != "" {
+		if params.OrderId != orderId {
+			return nil, echo.NewHTTPError(http.StatusForbidden, common.ErrorMessagePaymentFormUrlSignatureInvalid)
+		}
+
+		return params, nil
+	}
+
+	bound, err := h.paymentTokens.IsBound(ctx.Request().Context(), params.Token, orderId)
+
+	if err != nil {
+		h.L().Error("payment form token read failed", logger.PairArgs("err", err.Error(), "order_id", orderId))
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessagePaymentFormTokenStorageFailed)
+	}
+
+	if !bound {
+		return nil, echo.NewHTTPError(http.StatusForbidden, common.ErrorMessagePaymentFormUrlSignatureInvalid)
+	}
+
+	return params, nil
+}
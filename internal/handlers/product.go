@@ -15,6 +15,8 @@ const (
 	productsMerchantPath = "/products/merchant/:merchant_id"
 	productsIdPath       = "/products/:product_id"
 	productsPricesPath   = "/products/:product_id/prices"
+	productsImportPath   = "/products/import"
+	productsExportPath   = "/products/export"
 )
 
 type ProductRoute struct {
@@ -36,6 +38,8 @@ func (h *ProductRoute) Route(groups *common.Groups) {
 	groups.AuthUser.GET(productsPath, h.getProductsList)
 	groups.SystemUser.GET(productsMerchantPath, h.getProductsList)
 	groups.AuthUser.POST(productsPath, h.createProduct)
+	groups.AuthUser.POST(productsImportPath, h.importProducts)
+	groups.AuthUser.GET(productsExportPath, h.exportProducts)
 	groups.AuthUser.GET(productsIdPath, h.getProduct)
 	groups.AuthUser.PUT(productsIdPath, h.updateProduct)
 	groups.AuthUser.DELETE(productsIdPath, h.deleteProduct)
@@ -0,0 +1,658 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/ProtocolONE/go-core/v2/pkg/logger"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"io"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	productCatalogFormatCsv  = "csv"
+	productCatalogFormatJson = "json"
+	productCatalogFileName   = "products_%s.%s"
+	productCatalogMimeCsv    = "text/csv"
+
+	productImportRowsMax         = 1000
+	productImportActionCreate    = "create"
+	productImportActionUpdate    = "update"
+	productImportActionUnchanged = "unchanged"
+	productImportActionInvalid   = "invalid"
+
+	productCsvColumnSku             = "sku"
+	productCsvColumnType            = "type"
+	productCsvColumnEnabled         = "enabled"
+	productCsvColumnDefaultCurrency = "default_currency"
+	productCsvColumnUrl             = "url"
+	productCsvColumnImages          = "images"
+	productCsvPrefixName            = "name:"
+	productCsvPrefixDescription     = "description:"
+	productCsvPrefixLongDescription = "long_description:"
+	productCsvPrefixPrice           = "price:"
+	productCsvPriceVirtual          = "virtual"
+	productCsvKeySeparator          = ":"
+	productCsvListSeparator         = "|"
+	productCsvRowMask               = "row %d, column %s: %s"
+)
+
+var productCsvFixedColumns = []string{
+	productCsvColumnSku,
+	productCsvColumnType,
+	productCsvColumnEnabled,
+	productCsvColumnDefaultCurrency,
+	productCsvColumnUrl,
+	productCsvColumnImages,
+}
+
+type productImportRequest struct {
+	MerchantId string `json:"-" validate:"required,hexadecimal,len=24"`
+	ProjectId  string `form:"project_id" validate:"required,hexadecimal,len=24"`
+	Format     string `form:"format" validate:"omitempty,oneof=csv json"`
+	DryRun     bool   `form:"dry_run"`
+}
+
+type productExportRequest struct {
+	MerchantId string `json:"-" validate:"required,hexadecimal,len=24"`
+	ProjectId  string `query:"project_id" validate:"required,hexadecimal,len=24"`
+	Format     string `query:"format" validate:"omitempty,oneof=csv json"`
+}
+
+// ProductImportChange is the field of the product which will be changed by the import
+type ProductImportChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// ProductImportRow is the result of the import for the single product of the import file
+type ProductImportRow struct {
+	Row       int                        `json:"row"`
+	Sku       string                     `json:"sku"`
+	ProductId string                     `json:"product_id,omitempty"`
+	Action    string                     `json:"action"`
+	Changes   []*ProductImportChange     `json:"changes,omitempty"`
+	Message   *grpc.ResponseErrorMessage `json:"message,omitempty"`
+}
+
+// ProductImportResult
+type ProductImportResult struct {
+	DryRun    bool                `json:"dry_run"`
+	Total     int                 `json:"total"`
+	Created   int                 `json:"created"`
+	Updated   int                 `json:"updated"`
+	Unchanged int                 `json:"unchanged"`
+	Failed    int                 `json:"failed"`
+	Rows      []*ProductImportRow `json:"rows"`
+}
+
+func (r *ProductImportResult) add(row *ProductImportRow) {
+	r.Rows = append(r.Rows, row)
+
+	switch row.Action {
+	case productImportActionCreate:
+		r.Created++
+	case productImportActionUpdate:
+		r.Updated++
+	case productImportActionUnchanged:
+		r.Unchanged++
+	default:
+		r.Failed++
+	}
+}
+
+// importProducts creates or updates the project products by SKU from the CSV or JSON file.
+// The invalid rows are skipped, with dry_run nothing is saved and only the changes are returned.
+func (h *ProductRoute) importProducts(ctx echo.Context) error {
+	req := &productImportRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	products, err := readProductsImportFile(ctx, req.Format)
+
+	if err != nil {
+		return err
+	}
+
+	existing, err := listProjectProducts(ctx, h.dispatch, int64(h.cfg.LimitMax), req.MerchantId, req.ProjectId)
+
+	if err != nil {
+		return err
+	}
+
+	current := make(map[string]*grpc.Product, len(existing))
+
+	for _, product := range existing {
+		current[product.Sku] = product
+	}
+
+	result := &ProductImportResult{
+		DryRun: req.DryRun,
+		Total:  len(products),
+		Rows:   make([]*ProductImportRow, 0, len(products)),
+	}
+	imported := make(map[string]bool, len(products))
+
+	for i, product := range products {
+		row := &ProductImportRow{Row: i + 1, Sku: product.Sku}
+
+		if imported[product.Sku] {
+			row.Action = productImportActionInvalid
+			row.Message = common.ErrorMessageProductsImportSkuDuplicated
+			result.add(row)
+			continue
+		}
+
+		imported[product.Sku] = true
+		product.MerchantId = req.MerchantId
+		product.ProjectId = req.ProjectId
+		old, ok := current[product.Sku]
+
+		if ok {
+			mergeImportedProduct(product, old)
+		} else {
+			// the identifiers of the other project export must not point the new product at a foreign one
+			product.Id = ""
+			product.Object = ""
+			old = &grpc.Product{}
+		}
+
+		if message := h.validateImportedProduct(product); message != nil {
+			row.Action = productImportActionInvalid
+			row.Message = message
+			result.add(row)
+			continue
+		}
+
+		row.ProductId = product.Id
+		row.Changes = diffProducts(old, product)
+
+		switch {
+		case product.Id == "":
+			row.Action = productImportActionCreate
+		case len(row.Changes) > 0:
+			row.Action = productImportActionUpdate
+		default:
+			row.Action = productImportActionUnchanged
+		}
+
+		if req.DryRun || row.Action == productImportActionUnchanged {
+			result.add(row)
+			continue
+		}
+
+		res, err := h.dispatch.Services.Billing.CreateOrUpdateProduct(ctx.Request().Context(), product)
+
+		if err != nil {
+			common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "CreateOrUpdateProduct", product)
+			row.Action = productImportActionInvalid
+			row.Message = common.ErrorInternal
+			result.add(row)
+			continue
+		}
+
+		row.ProductId = res.Id
+		result.add(row)
+	}
+
+	return ctx.JSON(http.StatusOK, result)
+}
+
+// exportProducts returns all products of the project in the format accepted by the import
+func (h *ProductRoute) exportProducts(ctx echo.Context) error {
+	req := &productExportRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	products, err := listProjectProducts(ctx, h.dispatch, int64(h.cfg.LimitMax), req.MerchantId, req.ProjectId)
+
+	if err != nil {
+		return err
+	}
+
+	if req.Format == "" {
+		req.Format = productCatalogFormatJson
+	}
+
+	ctx.Response().Header().Set(
+		echo.HeaderContentDisposition,
+		fmt.Sprintf("attachment; filename="+productCatalogFileName, req.ProjectId, req.Format),
+	)
+
+	if req.Format == productCatalogFormatJson {
+		return ctx.JSON(http.StatusOK, products)
+	}
+
+	data, err := writeProductsCsv(products)
+
+	if err != nil {
+		h.L().Error("products csv export failed", logger.PairArgs("err", err.Error()))
+		return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorUnknown)
+	}
+
+	return ctx.Blob(http.StatusOK, productCatalogMimeCsv, data)
+}
+
+// listProjectProducts returns all products of the project page by page
+func listProjectProducts(ctx echo.Context, set common.HandlerSet, limit int64, merchantId, projectId string) ([]*grpc.Product, error) {
+	req := &grpc.ListProductsRequest{
+		MerchantId: merchantId,
+		ProjectId:  projectId,
+		Limit:      limit,
+	}
+	products := make([]*grpc.Product, 0)
+
+	for {
+		res, err := set.Services.Billing.ListProducts(ctx.Request().Context(), req)
+
+		if err != nil {
+			return nil, set.SrvCallHandler(req, err, pkg.ServiceName, "ListProducts")
+		}
+
+		products = append(products, res.Products...)
+		req.Offset += int64(len(res.Products))
+
+		if len(res.Products) <= 0 || req.Offset >= res.Total {
+			break
+		}
+	}
+
+	return products, nil
+}
+
+// validateImportedProduct checks the product with the same rules as the single product creation
+func (h *ProductRoute) validateImportedProduct(product *grpc.Product) *grpc.ResponseErrorMessage {
+	err := h.dispatch.Validate.Var(product.Prices, "required,min=1,currency_price")
+
+	if err == nil {
+		err = h.dispatch.Validate.Struct(product)
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	// GetValidationError returns the shared error message, so the copy is kept for the row
+	message := common.GetValidationError(err)
+
+	return &grpc.ResponseErrorMessage{Code: message.Code, Message: message.Message, Details: message.Details}
+}
+
+// mergeImportedProduct keeps the fields of the existing product which aren't a part of the import file.
+// The omitted languages and prices are kept as well, enabled is always taken from the file
+// because the JSON export omits the disabled flag and it can't be told apart from an omitted column.
+func mergeImportedProduct(product, old *grpc.Product) {
+	product.Id = old.Id
+	product.Object = old.Object
+
+	if product.Type == "" {
+		product.Type = old.Type
+	}
+
+	if product.DefaultCurrency == "" {
+		product.DefaultCurrency = old.DefaultCurrency
+	}
+
+	if product.Url == "" {
+		product.Url = old.Url
+	}
+
+	if len(product.Images) <= 0 {
+		product.Images = old.Images
+	}
+
+	if product.Pricing == "" {
+		product.Pricing = old.Pricing
+	}
+
+	if product.BillingType == "" {
+		product.BillingType = old.BillingType
+	}
+
+	if product.Metadata == nil {
+		product.Metadata = old.Metadata
+	}
+
+	product.Name = mergeImportedLocalization(product.Name, old.Name)
+	product.Description = mergeImportedLocalization(product.Description, old.Description)
+	product.LongDescription = mergeImportedLocalization(product.LongDescription, old.LongDescription)
+
+	prices := productPricesMap(product.Prices)
+
+	for _, price := range old.Prices {
+		if _, ok := prices[productPriceKey(price)]; !ok {
+			product.Prices = append(product.Prices, price)
+		}
+	}
+}
+
+// mergeImportedLocalization adds the languages of the existing product which aren't a part of the import file
+func mergeImportedLocalization(values, old map[string]string) map[string]string {
+	if len(old) <= 0 {
+		return values
+	}
+
+	result := make(map[string]string, len(old)+len(values))
+
+	for lang, value := range old {
+		result[lang] = value
+	}
+
+	for lang, value := range values {
+		result[lang] = value
+	}
+
+	return result
+}
+
+// diffProducts returns the catalog fields which differ between the existing and the imported product
+func diffProducts(old, product *grpc.Product) []*ProductImportChange {
+	changes := make([]*ProductImportChange, 0)
+	fields := []struct {
+		name     string
+		old, new interface{}
+	}{
+		{"type", old.Type, product.Type},
+		{"enabled", old.Enabled, product.Enabled},
+		{"default_currency", old.DefaultCurrency, product.DefaultCurrency},
+		{"url", old.Url, product.Url},
+		{"images", old.Images, product.Images},
+		{"name", old.Name, product.Name},
+		{"description", old.Description, product.Description},
+		{"long_description", old.LongDescription, product.LongDescription},
+		{"prices", productPricesMap(old.Prices), productPricesMap(product.Prices)},
+	}
+
+	for _, field := range fields {
+		if isFieldChanged(field.old, field.new) {
+			changes = append(changes, &ProductImportChange{Field: field.name, Old: field.old, New: field.new})
+		}
+	}
+
+	return changes
+}
+
+// isFieldChanged compares the values treating the empty and the nil maps and slices as equal
+func isFieldChanged(old, new interface{}) bool {
+	ov, nv := reflect.ValueOf(old), reflect.ValueOf(new)
+
+	if (ov.Kind() == reflect.Map || ov.Kind() == reflect.Slice) && ov.Len() <= 0 && nv.Len() <= 0 {
+		return false
+	}
+
+	return !reflect.DeepEqual(old, new)
+}
+
+// productPricesMap returns the prices keyed by the CSV price column suffix
+func productPricesMap(prices []*billing.ProductPrice) map[string]float64 {
+	result := make(map[string]float64, len(prices))
+
+	for _, price := range prices {
+		result[productPriceKey(price)] = price.Amount
+	}
+
+	return result
+}
+
+func productPriceKey(price *billing.ProductPrice) string {
+	if price.IsVirtualCurrency {
+		return productCsvPriceVirtual
+	}
+
+	return price.Currency + productCsvKeySeparator + price.Region
+}
+
+// readProductsImportFile reads the products from the multipart file.
+// Without the format in the request the format is taken from the file extension.
+func readProductsImportFile(ctx echo.Context, format string) ([]*grpc.Product, error) {
+	file, err := ctx.FormFile(common.RequestParameterFile)
+
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageProductsImportFileInvalid)
+	}
+
+	if format == "" {
+		format = strings.ToLower(strings.TrimPrefix(filepath.Ext(file.Filename), "."))
+	}
+
+	src, err := file.Open()
+
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageProductsImportFileInvalid)
+	}
+
+	defer func() {
+		if err := src.Close(); err != nil {
+			return
+		}
+	}()
+
+	var products []*grpc.Product
+
+	switch format {
+	case productCatalogFormatJson:
+		err = json.NewDecoder(src).Decode(&products)
+	case productCatalogFormatCsv:
+		products, err = readProductsCsv(src)
+	default:
+		return nil, echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageProductsImportFileInvalid)
+	}
+
+	if err != nil || len(products) <= 0 {
+		msg := common.ErrorMessageProductsImportFileInvalid
+
+		if err != nil {
+			msg = common.NewManagementApiResponseError(msg.Code, msg.Message, err.Error())
+		}
+
+		return nil, echo.NewHTTPError(http.StatusBadRequest, msg)
+	}
+
+	if len(products) > productImportRowsMax {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageProductsImportTooManyRows)
+	}
+
+	for _, product := range products {
+		product.Sku = strings.TrimSpace(product.Sku)
+	}
+
+	return products, nil
+}
+
+// readProductsCsv reads the products from CSV with the header row.
+// The localized columns are named as name:<lang>, the price columns as price:<currency>:<region> and price:virtual.
+func readProductsCsv(src io.Reader) ([]*grpc.Product, error) {
+	reader := csv.NewReader(src)
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) <= 1 {
+		return nil, nil
+	}
+
+	header := rows[0]
+	products := make([]*grpc.Product, 0, len(rows)-1)
+
+	for i, columns := range rows[1:] {
+		product := &grpc.Product{}
+
+		for j, column := range header {
+			value := strings.TrimSpace(columns[j])
+
+			if value == "" {
+				continue
+			}
+
+			if err = setProductCsvValue(product, strings.TrimSpace(column), value); err != nil {
+				return nil, fmt.Errorf(productCsvRowMask, i+1, column, err.Error())
+			}
+		}
+
+		products = append(products, product)
+	}
+
+	return products, nil
+}
+
+func setProductCsvValue(product *grpc.Product, column, value string) error {
+	switch {
+	case column == productCsvColumnSku:
+		product.Sku = value
+	case column == productCsvColumnType:
+		product.Type = value
+	case column == productCsvColumnEnabled:
+		enabled, err := strconv.ParseBool(value)
+
+		if err != nil {
+			return err
+		}
+
+		product.Enabled = enabled
+	case column == productCsvColumnDefaultCurrency:
+		product.DefaultCurrency = value
+	case column == productCsvColumnUrl:
+		product.Url = value
+	case column == productCsvColumnImages:
+		product.Images = strings.Split(value, productCsvListSeparator)
+	case strings.HasPrefix(column, productCsvPrefixName):
+		product.Name = setProductCsvLocalization(product.Name, column, productCsvPrefixName, value)
+	case strings.HasPrefix(column, productCsvPrefixDescription):
+		product.Description = setProductCsvLocalization(product.Description, column, productCsvPrefixDescription, value)
+	case strings.HasPrefix(column, productCsvPrefixLongDescription):
+		product.LongDescription = setProductCsvLocalization(product.LongDescription, column, productCsvPrefixLongDescription, value)
+	case strings.HasPrefix(column, productCsvPrefixPrice):
+		amount, err := strconv.ParseFloat(value, 64)
+
+		if err != nil {
+			return err
+		}
+
+		price := &billing.ProductPrice{Amount: amount}
+		key := strings.TrimPrefix(column, productCsvPrefixPrice)
+
+		if key == productCsvPriceVirtual {
+			price.IsVirtualCurrency = true
+		} else {
+			parts := strings.SplitN(key, productCsvKeySeparator, 2)
+			price.Currency = parts[0]
+
+			if len(parts) > 1 {
+				price.Region = parts[1]
+			}
+		}
+
+		product.Prices = append(product.Prices, price)
+	default:
+		return fmt.Errorf("unknown column")
+	}
+
+	return nil
+}
+
+func setProductCsvLocalization(values map[string]string, column, prefix, value string) map[string]string {
+	if values == nil {
+		values = make(map[string]string)
+	}
+
+	values[strings.TrimPrefix(column, prefix)] = value
+
+	return values
+}
+
+// writeProductsCsv writes the products with the columns of all languages and prices used by the products
+func writeProductsCsv(products []*grpc.Product) ([]byte, error) {
+	columns := make(map[string]bool)
+
+	for _, product := range products {
+		for lang := range product.Name {
+			columns[productCsvPrefixName+lang] = true
+		}
+
+		for lang := range product.Description {
+			columns[productCsvPrefixDescription+lang] = true
+		}
+
+		for lang := range product.LongDescription {
+			columns[productCsvPrefixLongDescription+lang] = true
+		}
+
+		for _, price := range product.Prices {
+			columns[productCsvPrefixPrice+productPriceKey(price)] = true
+		}
+	}
+
+	dynamic := make([]string, 0, len(columns))
+
+	for column := range columns {
+		dynamic = append(dynamic, column)
+	}
+
+	sort.Strings(dynamic)
+	header := append(append([]string{}, productCsvFixedColumns...), dynamic...)
+
+	buffer := &bytes.Buffer{}
+	writer := csv.NewWriter(buffer)
+
+	if err := writer.Write(header); err != nil {
+		return nil, err
+	}
+
+	for _, product := range products {
+		prices := productPricesMap(product.Prices)
+		row := []string{
+			product.Sku,
+			product.Type,
+			strconv.FormatBool(product.Enabled),
+			product.DefaultCurrency,
+			product.Url,
+			strings.Join(product.Images, productCsvListSeparator),
+		}
+
+		for _, column := range dynamic {
+			value := ""
+
+			switch {
+			case strings.HasPrefix(column, productCsvPrefixName):
+				value = product.Name[strings.TrimPrefix(column, productCsvPrefixName)]
+			case strings.HasPrefix(column, productCsvPrefixDescription):
+				value = product.Description[strings.TrimPrefix(column, productCsvPrefixDescription)]
+			case strings.HasPrefix(column, productCsvPrefixLongDescription):
+				value = product.LongDescription[strings.TrimPrefix(column, productCsvPrefixLongDescription)]
+			case strings.HasPrefix(column, productCsvPrefixPrice):
+				if amount, ok := prices[strings.TrimPrefix(column, productCsvPrefixPrice)]; ok {
+					value = strconv.FormatFloat(amount, 'f', -1, 64)
+				}
+			}
+
+			row = append(row, value)
+		}
+
+		if err := writer.Write(row); err != nil {
+			return nil, err
+		}
+	}
+
+	writer.Flush()
+
+	return buffer.Bytes(), writer.Error()
+}
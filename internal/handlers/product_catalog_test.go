@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/globalsign/mgo/bson"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/test"
+	mock2 "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const (
+	productCatalogTestCsv = "sku,default_currency,enabled,name:en,description:en,price:USD:USD\n" +
+		"new_sku,USD,true,New,New product,10\n" +
+		"old_sku,USD,true,Old,Old product,20\n" +
+		"bad_sku,USD,true,Bad,Bad product,\n"
+)
+
+type ProductCatalogTestSuite struct {
+	suite.Suite
+	router    *ProductRoute
+	caller    *test.EchoReqResCaller
+	projectId string
+	existing  *grpc.Product
+}
+
+func Test_ProductCatalog(t *testing.T) {
+	suite.Run(t, new(ProductCatalogTestSuite))
+}
+
+func (suite *ProductCatalogTestSuite) SetupTest() {
+	user := &common.AuthUser{
+		Id:         "ffffffffffffffffffffffff",
+		MerchantId: "ffffffffffffffffffffffff",
+		Role:       "owner",
+	}
+
+	suite.projectId = bson.NewObjectId().Hex()
+	suite.existing = &grpc.Product{
+		Id:              bson.NewObjectId().Hex(),
+		Object:          "product",
+		Type:            "simple_product",
+		Sku:             "old_sku",
+		Name:            map[string]string{"en": "Old"},
+		Description:     map[string]string{"en": "Old product"},
+		DefaultCurrency: "USD",
+		Enabled:         true,
+		MerchantId:      user.MerchantId,
+		ProjectId:       suite.projectId,
+		Prices:          []*billing.ProductPrice{{Currency: "USD", Region: "USD", Amount: 15}},
+	}
+
+	var e error
+	settings := test.DefaultSettings()
+	srv := common.Services{
+		Billing: &mocks.BillingService{},
+	}
+
+	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
+		mw.Pre(test.PreAuthUserMiddleware(user))
+		suite.router = NewProductRoute(set.HandlerSet, set.GlobalConfig)
+		return common.Handlers{
+			suite.router,
+		}
+	})
+
+	if e != nil {
+		panic(e)
+	}
+}
+
+func (suite *ProductCatalogTestSuite) TearDownTest() {}
+
+func (suite *ProductCatalogTestSuite) newImportBody(fileName, content string, fields map[string]string) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	for key, value := range fields {
+		require.NoError(suite.T(), writer.WriteField(key, value))
+	}
+
+	part, err := writer.CreateFormFile(common.RequestParameterFile, fileName)
+	require.NoError(suite.T(), err)
+	_, err = part.Write([]byte(content))
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), writer.Close())
+
+	return body, writer.FormDataContentType()
+}
+
+func (suite *ProductCatalogTestSuite) mockListProducts() *mocks.BillingService {
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("ListProducts", mock2.Anything, mock2.MatchedBy(func(req *grpc.ListProductsRequest) bool {
+		return req.ProjectId == suite.projectId
+	})).Return(&grpc.ListProductsResponse{Total: 1, Products: []*grpc.Product{suite.existing}}, nil)
+
+	return billingService
+}
+
+func (suite *ProductCatalogTestSuite) importProducts(fileName, content string, fields map[string]string) (*ProductImportResult, error) {
+	body, contentType := suite.newImportBody(fileName, content, fields)
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.AuthUserGroupPath + productsImportPath).
+		Init(func(request *http.Request, middleware test.Middleware) {
+			request.Header.Set(echo.HeaderContentType, contentType)
+		}).
+		Body(body).
+		Exec(suite.T())
+
+	if err != nil {
+		return nil, err
+	}
+
+	require.Equal(suite.T(), http.StatusOK, res.Code)
+
+	result := &ProductImportResult{}
+	require.NoError(suite.T(), json.Unmarshal(res.Body.Bytes(), result))
+
+	return result, nil
+}
+
+func (suite *ProductCatalogTestSuite) TestProductCatalog_Import_DryRun_Ok() {
+	shouldBe := require.New(suite.T())
+	billingService := suite.mockListProducts()
+
+	result, err := suite.importProducts(
+		"products.csv",
+		productCatalogTestCsv,
+		map[string]string{"project_id": suite.projectId, "dry_run": "true"},
+	)
+
+	shouldBe.NoError(err)
+	shouldBe.True(result.DryRun)
+	shouldBe.Equal(3, result.Total)
+	shouldBe.Equal(1, result.Created)
+	shouldBe.Equal(1, result.Updated)
+	shouldBe.Equal(1, result.Failed)
+
+	shouldBe.Equal(productImportActionCreate, result.Rows[0].Action)
+	shouldBe.Equal(productImportActionUpdate, result.Rows[1].Action)
+	shouldBe.Equal(suite.existing.Id, result.Rows[1].ProductId)
+	shouldBe.Len(result.Rows[1].Changes, 1)
+	shouldBe.Equal("prices", result.Rows[1].Changes[0].Field)
+	shouldBe.Equal(productImportActionInvalid, result.Rows[2].Action)
+	shouldBe.NotNil(result.Rows[2].Message)
+
+	billingService.AssertNotCalled(suite.T(), "CreateOrUpdateProduct", mock2.Anything, mock2.Anything)
+}
+
+func (suite *ProductCatalogTestSuite) TestProductCatalog_Import_Ok() {
+	shouldBe := require.New(suite.T())
+	billingService := suite.mockListProducts()
+	billingService.On("CreateOrUpdateProduct", mock2.Anything, mock2.MatchedBy(func(req *grpc.Product) bool {
+		return req.Sku == "new_sku" && req.Id == ""
+	})).Return(&grpc.Product{Id: bson.NewObjectId().Hex()}, nil)
+	billingService.On("CreateOrUpdateProduct", mock2.Anything, mock2.MatchedBy(func(req *grpc.Product) bool {
+		return req.Sku == "old_sku" && req.Id == suite.existing.Id && req.Type == suite.existing.Type &&
+			req.Prices[0].Amount == 20
+	})).Return(suite.existing, nil)
+
+	result, err := suite.importProducts("products.csv", productCatalogTestCsv, map[string]string{"project_id": suite.projectId})
+
+	shouldBe.NoError(err)
+	shouldBe.False(result.DryRun)
+	shouldBe.Equal(1, result.Created)
+	shouldBe.Equal(1, result.Updated)
+	shouldBe.Equal(1, result.Failed)
+	shouldBe.NotEmpty(result.Rows[0].ProductId)
+	billingService.AssertNumberOfCalls(suite.T(), "CreateOrUpdateProduct", 2)
+}
+
+func (suite *ProductCatalogTestSuite) TestProductCatalog_Import_Json_Unchanged_Ok() {
+	shouldBe := require.New(suite.T())
+	billingService := suite.mockListProducts()
+
+	products := []*grpc.Product{
+		{
+			Sku:             suite.existing.Sku,
+			Name:            suite.existing.Name,
+			Description:     suite.existing.Description,
+			DefaultCurrency: suite.existing.DefaultCurrency,
+			Enabled:         suite.existing.Enabled,
+			Prices:          suite.existing.Prices,
+		},
+		{
+			Sku:             suite.existing.Sku,
+			Name:            suite.existing.Name,
+			Description:     suite.existing.Description,
+			DefaultCurrency: suite.existing.DefaultCurrency,
+			Prices:          suite.existing.Prices,
+		},
+	}
+	content, err := json.Marshal(products)
+	shouldBe.NoError(err)
+
+	result, err := suite.importProducts("products.json", string(content), map[string]string{"project_id": suite.projectId})
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(1, result.Unchanged)
+	shouldBe.Equal(1, result.Failed)
+	shouldBe.Equal(common.ErrorMessageProductsImportSkuDuplicated.Code, result.Rows[1].Message.Code)
+	billingService.AssertNotCalled(suite.T(), "CreateOrUpdateProduct", mock2.Anything, mock2.Anything)
+}
+
+func (suite *ProductCatalogTestSuite) TestProductCatalog_Import_Json_ForeignId_Ok() {
+	shouldBe := require.New(suite.T())
+	billingService := suite.mockListProducts()
+	billingService.On("CreateOrUpdateProduct", mock2.Anything, mock2.MatchedBy(func(req *grpc.Product) bool {
+		return req.Sku == "new_sku" && req.Id == "" && req.Object == "" && req.ProjectId == suite.projectId
+	})).Return(&grpc.Product{Id: bson.NewObjectId().Hex()}, nil)
+
+	products := []*grpc.Product{
+		{
+			Id:              bson.NewObjectId().Hex(),
+			Object:          "product",
+			Sku:             "new_sku",
+			Name:            map[string]string{"en": "New"},
+			Description:     map[string]string{"en": "New product"},
+			DefaultCurrency: "USD",
+			Enabled:         true,
+			Prices:          []*billing.ProductPrice{{Currency: "USD", Region: "USD", Amount: 10}},
+		},
+	}
+	content, err := json.Marshal(products)
+	shouldBe.NoError(err)
+
+	result, err := suite.importProducts("products.json", string(content), map[string]string{"project_id": suite.projectId})
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(1, result.Created)
+	shouldBe.Equal(productImportActionCreate, result.Rows[0].Action)
+	shouldBe.NotEqual(products[0].Id, result.Rows[0].ProductId)
+	billingService.AssertNumberOfCalls(suite.T(), "CreateOrUpdateProduct", 1)
+}
+
+func (suite *ProductCatalogTestSuite) TestProductCatalog_Import_OmittedColumns_Ok() {
+	shouldBe := require.New(suite.T())
+	suite.existing.Url = "https://example.com/old"
+	suite.existing.Images = []string{"https://example.com/old.png"}
+	suite.existing.Name["ru"] = "Старый"
+	suite.mockListProducts()
+
+	result, err := suite.importProducts(
+		"products.csv",
+		"sku,enabled,url,name:en\nold_sku,true,https://example.com/new,Old\n",
+		map[string]string{"project_id": suite.projectId, "dry_run": "true"},
+	)
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(1, result.Updated)
+	shouldBe.Len(result.Rows[0].Changes, 1)
+	shouldBe.Equal("url", result.Rows[0].Changes[0].Field)
+}
+
+func (suite *ProductCatalogTestSuite) TestProductCatalog_Import_PriceWithoutRegion_Error() {
+	shouldBe := require.New(suite.T())
+	suite.mockListProducts()
+
+	result, err := suite.importProducts(
+		"products.csv",
+		"sku,default_currency,name:en,description:en,price:USD\nnew_sku,USD,New,New product,10\n",
+		map[string]string{"project_id": suite.projectId, "dry_run": "1"},
+	)
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(1, result.Failed)
+	shouldBe.Equal(productImportActionInvalid, result.Rows[0].Action)
+}
+
+func (suite *ProductCatalogTestSuite) TestProductCatalog_Import_UnknownFormat_Error() {
+	shouldBe := require.New(suite.T())
+
+	_, err := suite.importProducts("products.txt", productCatalogTestCsv, map[string]string{"project_id": suite.projectId})
+
+	shouldBe.Error(err)
+	hErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusBadRequest, hErr.Code)
+	shouldBe.Equal(common.ErrorMessageProductsImportFileInvalid, hErr.Message)
+}
+
+func (suite *ProductCatalogTestSuite) TestProductCatalog_Import_InvalidCsvValue_Error() {
+	shouldBe := require.New(suite.T())
+
+	_, err := suite.importProducts(
+		"products.csv",
+		"sku,price:USD:USD\nnew_sku,ten\n",
+		map[string]string{"project_id": suite.projectId},
+	)
+
+	shouldBe.Error(err)
+	hErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusBadRequest, hErr.Code)
+	shouldBe.Equal(common.ErrorMessageProductsImportFileInvalid.Code, hErr.Message.(*grpc.ResponseErrorMessage).Code)
+}
+
+func (suite *ProductCatalogTestSuite) TestProductCatalog_Import_ProjectRequired_Error() {
+	shouldBe := require.New(suite.T())
+
+	_, err := suite.importProducts("products.csv", productCatalogTestCsv, nil)
+
+	shouldBe.Error(err)
+	hErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusBadRequest, hErr.Code)
+}
+
+func (suite *ProductCatalogTestSuite) TestProductCatalog_Export_Csv_Ok() {
+	shouldBe := require.New(suite.T())
+	suite.mockListProducts()
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		SetQueryParam("project_id", suite.projectId).
+		SetQueryParam("format", productCatalogFormatCsv).
+		Path(common.AuthUserGroupPath + productsExportPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+	shouldBe.Contains(res.Header().Get(echo.HeaderContentDisposition), "attachment")
+
+	lines := strings.Split(strings.TrimSpace(res.Body.String()), "\n")
+	shouldBe.Len(lines, 2)
+	shouldBe.Equal("sku,type,enabled,default_currency,url,images,description:en,name:en,price:USD:USD", lines[0])
+	shouldBe.Equal("old_sku,simple_product,true,USD,,,Old product,Old,15", lines[1])
+
+	products, err := readProductsCsv(strings.NewReader(res.Body.String()))
+	shouldBe.NoError(err)
+	shouldBe.Len(products, 1)
+	shouldBe.Empty(diffProducts(suite.existing, products[0]))
+}
+
+func (suite *ProductCatalogTestSuite) TestProductCatalog_Export_BillingServer_Error() {
+	shouldBe := require.New(suite.T())
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("ListProducts", mock2.Anything, mock2.Anything).Return(nil, errors.New("some error"))
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		SetQueryParam("project_id", suite.projectId).
+		Path(common.AuthUserGroupPath + productsExportPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.Error(err)
+	hErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusInternalServerError, hErr.Code)
+}
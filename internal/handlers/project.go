@@ -7,7 +7,9 @@ import (
 	"github.com/paysuper/paysuper-billing-server/pkg"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/callback"
 	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/theme"
 	"net/http"
 )
 
@@ -17,18 +19,28 @@ const (
 	projectsSkuPath = "/projects/:project_id/sku"
 )
 
+const (
+	projectsClonePath         = "/projects/:project_id/clone"
+	projectsDiffPath          = "/projects/:project_id/diff/:target_project_id"
+	projectsCallbacksTestPath = "/projects/:project_id/callbacks/test"
+)
+
 type ProjectRoute struct {
-	dispatch common.HandlerSet
-	cfg      common.Config
+	dispatch       common.HandlerSet
+	cfg            common.Config
+	callbackTester *callback.Tester
+	themes         *theme.Store
 	provider.LMT
 }
 
-func NewProjectRoute(set common.HandlerSet, cfg *common.Config) *ProjectRoute {
+func NewProjectRoute(set common.HandlerSet, themes *theme.Store, cfg *common.Config) *ProjectRoute {
 	set.AwareSet.Logger = set.AwareSet.Logger.WithFields(logger.Fields{"router": "ProjectRoute"})
 	return &ProjectRoute{
-		dispatch: set,
-		LMT:      &set.AwareSet,
-		cfg:      *cfg,
+		dispatch:       set,
+		LMT:            &set.AwareSet,
+		cfg:            *cfg,
+		callbackTester: callback.NewTester(cfg.CallbackTesterTimeout, cfg.CallbackTesterLocalServer),
+		themes:         themes,
 	}
 }
 
@@ -39,6 +51,13 @@ func (h *ProjectRoute) Route(groups *common.Groups) {
 	groups.AuthUser.PATCH(projectsIdPath, h.updateProject)
 	groups.AuthUser.DELETE(projectsIdPath, h.deleteProject)
 	groups.AuthUser.POST(projectsSkuPath, h.checkSku)
+	groups.AuthUser.POST(projectsClonePath, h.cloneProject)
+	groups.AuthUser.GET(projectsDiffPath, h.diffProjects)
+	groups.AuthUser.POST(projectsCallbacksTestPath, h.testCallback)
+	groups.AuthUser.GET(projectsThemePath, h.getTheme)
+	groups.AuthUser.PUT(projectsThemePath, h.setTheme)
+
+	groups.Common.GET(projectsThemeCssPath, h.getThemeCss)
 }
 
 func (h *ProjectRoute) createProject(ctx echo.Context) error {
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"github.com/ProtocolONE/go-core/v2/pkg/logger"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-management-api/internal/callback"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"net/http"
+)
+
+type projectCallbackTestRequest struct {
+	MerchantId       string `json:"-" validate:"required,hexadecimal,len=24"`
+	ProjectId        string `json:"-" param:"project_id" validate:"required,hexadecimal,len=24"`
+	Type             string `json:"type" validate:"required,oneof=check_account process_payment refund_payment chargeback_payment cancel_payment fraud_payment"`
+	InvalidSignature bool   `json:"invalid_signature"`
+}
+
+// testCallback sends the signed sample notification of the requested type to the project callback url
+// and reports the merchant server answer
+func (h *ProjectRoute) testCallback(ctx echo.Context) error {
+	req := &projectCallbackTestRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	project, err := h.getMerchantProject(ctx, req.MerchantId, req.ProjectId)
+
+	if err != nil {
+		return err
+	}
+
+	url := getProjectCallbackUrl(project, req.Type)
+
+	if url == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageCallbackUrlNotConfigured)
+	}
+
+	res, err := h.callbackTester.Test(
+		ctx.Request().Context(),
+		req.Type,
+		url,
+		project.Id,
+		project.SecretKey,
+		req.InvalidSignature,
+	)
+
+	switch err {
+	case nil:
+		return ctx.JSON(http.StatusOK, res)
+	case callback.ErrorUrlInvalid:
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageCallbackUrlInvalid)
+	case callback.ErrorUrlForbidden:
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageCallbackUrlForbidden)
+	case callback.ErrorSecretKeyEmpty:
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageCallbackSecretKeyEmpty)
+	}
+
+	h.L().Error(
+		"project callback test failed",
+		logger.PairArgs("err", err.Error(), "project_id", project.Id, "type", req.Type),
+	)
+
+	return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorUnknown)
+}
+
+func getProjectCallbackUrl(project *billing.Project, typ string) string {
+	switch typ {
+	case callback.TypeCheckAccount:
+		return project.UrlCheckAccount
+	case callback.TypeProcessPayment:
+		return project.UrlProcessPayment
+	case callback.TypeRefundPayment:
+		return project.UrlRefundPayment
+	case callback.TypeChargebackPayment:
+		return project.UrlChargebackPayment
+	case callback.TypeCancelPayment:
+		return project.UrlCancelPayment
+	case callback.TypeFraudPayment:
+		return project.UrlFraudPayment
+	}
+
+	return ""
+}
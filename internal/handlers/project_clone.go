@@ -0,0 +1,539 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"net/http"
+	"sort"
+)
+
+const (
+	projectCloneNameSuffix      = " (copy)"
+	projectCloneOverridableName = "name"
+)
+
+type projectCloneRequest struct {
+	MerchantId      string          `json:"-" validate:"required,hexadecimal,len=24"`
+	ProjectId       string          `json:"-" param:"project_id" validate:"required,hexadecimal,len=24"`
+	Overrides       json.RawMessage `json:"overrides"`
+	SkipProducts    bool            `json:"skip_products"`
+	SkipKeyProducts bool            `json:"skip_key_products"`
+}
+
+type projectDiffRequest struct {
+	MerchantId      string `json:"-" validate:"required,hexadecimal,len=24"`
+	ProjectId       string `json:"-" param:"project_id" validate:"required,hexadecimal,len=24"`
+	TargetProjectId string `json:"-" param:"target_project_id" validate:"required,hexadecimal,len=24,nefield=ProjectId"`
+}
+
+// ProjectCloneItem is the result of the copying of the single product to the cloned project
+type ProjectCloneItem struct {
+	SourceId string                     `json:"source_id"`
+	Sku      string                     `json:"sku"`
+	Id       string                     `json:"id,omitempty"`
+	Message  *grpc.ResponseErrorMessage `json:"message,omitempty"`
+}
+
+// ProjectCloneResult
+type ProjectCloneResult struct {
+	Project     *billing.Project    `json:"project"`
+	Products    []*ProjectCloneItem `json:"products"`
+	KeyProducts []*ProjectCloneItem `json:"key_products"`
+}
+
+// ProjectDiffChange is the field which has different values in the compared projects
+type ProjectDiffChange struct {
+	Field  string      `json:"field"`
+	Source interface{} `json:"source"`
+	Target interface{} `json:"target"`
+}
+
+// ProjectDiffItem is the product which exists in both projects with different fields
+type ProjectDiffItem struct {
+	Sku     string               `json:"sku"`
+	Changes []*ProjectDiffChange `json:"changes"`
+}
+
+// ProjectDiffItems is the difference of the products of the compared projects matched by SKU
+type ProjectDiffItems struct {
+	OnlyInSource []string           `json:"only_in_source"`
+	OnlyInTarget []string           `json:"only_in_target"`
+	Changed      []*ProjectDiffItem `json:"changed"`
+}
+
+// ProjectDiff
+type ProjectDiff struct {
+	SourceId    string               `json:"source_id"`
+	TargetId    string               `json:"target_id"`
+	Settings    []*ProjectDiffChange `json:"settings"`
+	Products    *ProjectDiffItems    `json:"products"`
+	KeyProducts *ProjectDiffItems    `json:"key_products"`
+}
+
+// projectSettingsFields are the project settings which are copied by the clone and compared by the diff
+var projectSettingsFields = []struct {
+	name  string
+	value func(*billing.Project) interface{}
+}{
+	{"callback_currency", func(p *billing.Project) interface{} { return p.CallbackCurrency }},
+	{"callback_protocol", func(p *billing.Project) interface{} { return p.CallbackProtocol }},
+	{"create_order_allowed_urls", func(p *billing.Project) interface{} { return p.CreateOrderAllowedUrls }},
+	{"allow_dynamic_notify_urls", func(p *billing.Project) interface{} { return p.AllowDynamicNotifyUrls }},
+	{"allow_dynamic_redirect_urls", func(p *billing.Project) interface{} { return p.AllowDynamicRedirectUrls }},
+	{"limits_currency", func(p *billing.Project) interface{} { return p.LimitsCurrency }},
+	{"min_payment_amount", func(p *billing.Project) interface{} { return p.MinPaymentAmount }},
+	{"max_payment_amount", func(p *billing.Project) interface{} { return p.MaxPaymentAmount }},
+	{"notify_emails", func(p *billing.Project) interface{} { return p.NotifyEmails }},
+	{"is_products_checkout", func(p *billing.Project) interface{} { return p.IsProductsCheckout }},
+	{"signature_required", func(p *billing.Project) interface{} { return p.SignatureRequired }},
+	{"send_notify_email", func(p *billing.Project) interface{} { return p.SendNotifyEmail }},
+	{"url_check_account", func(p *billing.Project) interface{} { return p.UrlCheckAccount }},
+	{"url_process_payment", func(p *billing.Project) interface{} { return p.UrlProcessPayment }},
+	{"url_redirect_fail", func(p *billing.Project) interface{} { return p.UrlRedirectFail }},
+	{"url_redirect_success", func(p *billing.Project) interface{} { return p.UrlRedirectSuccess }},
+	{"url_chargeback_payment", func(p *billing.Project) interface{} { return p.UrlChargebackPayment }},
+	{"url_cancel_payment", func(p *billing.Project) interface{} { return p.UrlCancelPayment }},
+	{"url_fraud_payment", func(p *billing.Project) interface{} { return p.UrlFraudPayment }},
+	{"url_refund_payment", func(p *billing.Project) interface{} { return p.UrlRefundPayment }},
+	{"localizations", func(p *billing.Project) interface{} { return p.Localizations }},
+	{"currencies", func(p *billing.Project) interface{} { return p.Currencies }},
+	{"virtual_currency", func(p *billing.Project) interface{} { return p.VirtualCurrency }},
+}
+
+// cloneProject creates the new project with the settings of the project and copies its products and key products.
+// The secret key isn't copied, so the cloned project gets its own one. The products are listed before the project
+// is created, so the failed listing doesn't leave the empty copy behind.
+func (h *ProjectRoute) cloneProject(ctx echo.Context) error {
+	req := &projectCloneRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	source, err := h.getMerchantProject(ctx, req.MerchantId, req.ProjectId)
+
+	if err != nil {
+		return err
+	}
+
+	project := copyProjectSettings(source)
+
+	if err := applyProjectCloneOverrides(project, req.Overrides); err != nil {
+		return err
+	}
+
+	project.Id = ""
+	project.MerchantId = req.MerchantId
+
+	if len(project.CallbackProtocol) == 0 {
+		project.CallbackProtocol = pkg.ProjectCallbackProtocolEmpty
+	}
+
+	if err := h.dispatch.Validate.Struct(project); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, common.GetValidationError(err))
+	}
+
+	limit := int64(h.cfg.LimitMax)
+	products := make([]*grpc.Product, 0)
+	keyProducts := make([]*grpc.KeyProduct, 0)
+
+	if !req.SkipProducts {
+		if products, err = listProjectProducts(ctx, h.dispatch, limit, req.MerchantId, req.ProjectId); err != nil {
+			return err
+		}
+	}
+
+	if !req.SkipKeyProducts {
+		if keyProducts, err = listProjectKeyProducts(ctx, h.dispatch, limit, req.MerchantId, req.ProjectId); err != nil {
+			return err
+		}
+	}
+
+	res, err := h.dispatch.Services.Billing.ChangeProject(ctx.Request().Context(), project)
+
+	if err != nil {
+		return h.dispatch.SrvCallHandler(project, err, pkg.ServiceName, "ChangeProject")
+	}
+
+	if res.Status != pkg.ResponseStatusOk {
+		return echo.NewHTTPError(int(res.Status), res.Message)
+	}
+
+	result := &ProjectCloneResult{
+		Project:     res.Item,
+		Products:    h.cloneProducts(ctx, products, req.MerchantId, res.Item.Id),
+		KeyProducts: h.cloneKeyProducts(ctx, keyProducts, req.MerchantId, res.Item.Id),
+	}
+
+	return ctx.JSON(http.StatusOK, result)
+}
+
+// diffProjects compares the settings, products and key products of two projects of the merchant
+func (h *ProjectRoute) diffProjects(ctx echo.Context) error {
+	req := &projectDiffRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	source, err := h.getMerchantProject(ctx, req.MerchantId, req.ProjectId)
+
+	if err != nil {
+		return err
+	}
+
+	target, err := h.getMerchantProject(ctx, req.MerchantId, req.TargetProjectId)
+
+	if err != nil {
+		return err
+	}
+
+	limit := int64(h.cfg.LimitMax)
+	diff := &ProjectDiff{
+		SourceId: source.Id,
+		TargetId: target.Id,
+		Settings: make([]*ProjectDiffChange, 0),
+	}
+
+	for _, field := range projectSettingsFields {
+		sv, tv := field.value(source), field.value(target)
+
+		if isFieldChanged(sv, tv) {
+			diff.Settings = append(diff.Settings, &ProjectDiffChange{Field: field.name, Source: sv, Target: tv})
+		}
+	}
+
+	sourceProducts, err := listProjectProducts(ctx, h.dispatch, limit, req.MerchantId, source.Id)
+
+	if err != nil {
+		return err
+	}
+
+	targetProducts, err := listProjectProducts(ctx, h.dispatch, limit, req.MerchantId, target.Id)
+
+	if err != nil {
+		return err
+	}
+
+	diff.Products = diffProjectItems(productsBySku(sourceProducts), productsBySku(targetProducts))
+
+	sourceKeyProducts, err := listProjectKeyProducts(ctx, h.dispatch, limit, req.MerchantId, source.Id)
+
+	if err != nil {
+		return err
+	}
+
+	targetKeyProducts, err := listProjectKeyProducts(ctx, h.dispatch, limit, req.MerchantId, target.Id)
+
+	if err != nil {
+		return err
+	}
+
+	diff.KeyProducts = diffProjectItems(keyProductsBySku(sourceKeyProducts), keyProductsBySku(targetKeyProducts))
+
+	return ctx.JSON(http.StatusOK, diff)
+}
+
+// applyProjectCloneOverrides sets the overridden settings of the cloned project.
+// Only the name and the copied settings can be overridden, the other fields of the project are rejected.
+func applyProjectCloneOverrides(project *billing.Project, overrides json.RawMessage) error {
+	if len(overrides) == 0 {
+		return nil
+	}
+
+	fields := make(map[string]json.RawMessage)
+
+	if err := json.Unmarshal(overrides, &fields); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageProjectCloneOverridesInvalid)
+	}
+
+	for name := range fields {
+		if !isProjectCloneOverridable(name) {
+			return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageProjectCloneOverridesInvalid)
+		}
+	}
+
+	if err := json.Unmarshal(overrides, project); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageProjectCloneOverridesInvalid)
+	}
+
+	return nil
+}
+
+func isProjectCloneOverridable(name string) bool {
+	if name == projectCloneOverridableName {
+		return true
+	}
+
+	for _, field := range projectSettingsFields {
+		if field.name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h *ProjectRoute) getMerchantProject(ctx echo.Context, merchantId, projectId string) (*billing.Project, error) {
+	req := &grpc.GetProjectRequest{MerchantId: merchantId, ProjectId: projectId}
+	res, err := h.dispatch.Services.Billing.GetProject(ctx.Request().Context(), req)
+
+	if err != nil {
+		return nil, h.dispatch.SrvCallHandler(req, err, pkg.ServiceName, "GetProject")
+	}
+
+	if res.Status != pkg.ResponseStatusOk {
+		return nil, echo.NewHTTPError(int(res.Status), res.Message)
+	}
+
+	return res.Item, nil
+}
+
+// cloneProducts copies the products to the cloned project. The failed product doesn't stop the copying.
+func (h *ProjectRoute) cloneProducts(ctx echo.Context, products []*grpc.Product, merchantId, projectId string) []*ProjectCloneItem {
+	items := make([]*ProjectCloneItem, 0, len(products))
+
+	for _, product := range products {
+		item := &ProjectCloneItem{SourceId: product.Id, Sku: product.Sku}
+		items = append(items, item)
+
+		req := &grpc.Product{
+			Object:          product.Object,
+			Type:            product.Type,
+			Sku:             product.Sku,
+			Name:            product.Name,
+			DefaultCurrency: product.DefaultCurrency,
+			Enabled:         product.Enabled,
+			Prices:          product.Prices,
+			Description:     product.Description,
+			LongDescription: product.LongDescription,
+			Images:          product.Images,
+			Url:             product.Url,
+			Metadata:        product.Metadata,
+			Pricing:         product.Pricing,
+			BillingType:     product.BillingType,
+			MerchantId:      merchantId,
+			ProjectId:       projectId,
+		}
+		res, err := h.dispatch.Services.Billing.CreateOrUpdateProduct(ctx.Request().Context(), req)
+
+		if err != nil {
+			common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "CreateOrUpdateProduct", req)
+			item.Message = common.ErrorInternal
+			continue
+		}
+
+		item.Id = res.Id
+	}
+
+	return items
+}
+
+// cloneKeyProducts copies the key products to the cloned project. The keys of the platforms aren't copied.
+func (h *ProjectRoute) cloneKeyProducts(ctx echo.Context, products []*grpc.KeyProduct, merchantId, projectId string) []*ProjectCloneItem {
+	items := make([]*ProjectCloneItem, 0, len(products))
+
+	for _, product := range products {
+		item := &ProjectCloneItem{SourceId: product.Id, Sku: product.Sku}
+		items = append(items, item)
+
+		req := &grpc.CreateOrUpdateKeyProductRequest{
+			Object:          product.Object,
+			Sku:             product.Sku,
+			Name:            product.Name,
+			DefaultCurrency: product.DefaultCurrency,
+			Description:     product.Description,
+			LongDescription: product.LongDescription,
+			Cover:           product.Cover,
+			Url:             product.Url,
+			Metadata:        product.Metadata,
+			Platforms:       product.Platforms,
+			MerchantId:      merchantId,
+			ProjectId:       projectId,
+		}
+		res, err := h.dispatch.Services.Billing.CreateOrUpdateKeyProduct(ctx.Request().Context(), req)
+
+		if err != nil {
+			common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "CreateOrUpdateKeyProduct", req)
+			item.Message = common.ErrorInternal
+			continue
+		}
+
+		if res.Status != pkg.ResponseStatusOk {
+			item.Message = res.Message
+			continue
+		}
+
+		item.Id = res.Product.Id
+	}
+
+	return items
+}
+
+// listProjectKeyProducts returns all key products of the project page by page
+func listProjectKeyProducts(ctx echo.Context, set common.HandlerSet, limit int64, merchantId, projectId string) ([]*grpc.KeyProduct, error) {
+	req := &grpc.ListKeyProductsRequest{
+		MerchantId: merchantId,
+		ProjectId:  projectId,
+		Limit:      limit,
+	}
+	products := make([]*grpc.KeyProduct, 0)
+
+	for {
+		res, err := set.Services.Billing.GetKeyProducts(ctx.Request().Context(), req)
+
+		if err != nil {
+			return nil, set.SrvCallHandler(req, err, pkg.ServiceName, "GetKeyProducts")
+		}
+
+		if res.Status != pkg.ResponseStatusOk {
+			return nil, echo.NewHTTPError(int(res.Status), res.Message)
+		}
+
+		products = append(products, res.Products...)
+
+		if int64(len(res.Products)) < req.Limit {
+			break
+		}
+
+		req.Offset += int64(len(res.Products))
+	}
+
+	return products, nil
+}
+
+// copyProjectSettings returns the new project with the settings of the project.
+// The name of the copy gets the suffix in every localization.
+func copyProjectSettings(source *billing.Project) *billing.Project {
+	project := &billing.Project{
+		Name:                     make(map[string]string, len(source.Name)),
+		CallbackCurrency:         source.CallbackCurrency,
+		CallbackProtocol:         source.CallbackProtocol,
+		CreateOrderAllowedUrls:   source.CreateOrderAllowedUrls,
+		AllowDynamicNotifyUrls:   source.AllowDynamicNotifyUrls,
+		AllowDynamicRedirectUrls: source.AllowDynamicRedirectUrls,
+		LimitsCurrency:           source.LimitsCurrency,
+		MinPaymentAmount:         source.MinPaymentAmount,
+		MaxPaymentAmount:         source.MaxPaymentAmount,
+		NotifyEmails:             source.NotifyEmails,
+		IsProductsCheckout:       source.IsProductsCheckout,
+		SignatureRequired:        source.SignatureRequired,
+		SendNotifyEmail:          source.SendNotifyEmail,
+		UrlCheckAccount:          source.UrlCheckAccount,
+		UrlProcessPayment:        source.UrlProcessPayment,
+		UrlRedirectFail:          source.UrlRedirectFail,
+		UrlRedirectSuccess:       source.UrlRedirectSuccess,
+		UrlChargebackPayment:     source.UrlChargebackPayment,
+		UrlCancelPayment:         source.UrlCancelPayment,
+		UrlFraudPayment:          source.UrlFraudPayment,
+		UrlRefundPayment:         source.UrlRefundPayment,
+		ShortDescription:         source.ShortDescription,
+		Cover:                    source.Cover,
+		FullDescription:          source.FullDescription,
+		Localizations:            source.Localizations,
+		Currencies:               source.Currencies,
+		VirtualCurrency:          source.VirtualCurrency,
+	}
+
+	for lang, name := range source.Name {
+		project.Name[lang] = name + projectCloneNameSuffix
+	}
+
+	return project
+}
+
+func productsBySku(products []*grpc.Product) map[string][]*ProjectDiffChange {
+	result := make(map[string][]*ProjectDiffChange, len(products))
+
+	for _, product := range products {
+		result[product.Sku] = []*ProjectDiffChange{
+			{Field: "enabled", Source: product.Enabled},
+			{Field: "default_currency", Source: product.DefaultCurrency},
+			{Field: "url", Source: product.Url},
+			{Field: "images", Source: product.Images},
+			{Field: "name", Source: product.Name},
+			{Field: "description", Source: product.Description},
+			{Field: "long_description", Source: product.LongDescription},
+			{Field: "prices", Source: productPricesMap(product.Prices)},
+		}
+	}
+
+	return result
+}
+
+func keyProductsBySku(products []*grpc.KeyProduct) map[string][]*ProjectDiffChange {
+	result := make(map[string][]*ProjectDiffChange, len(products))
+
+	for _, product := range products {
+		platforms := make(map[string]map[string]float64, len(product.Platforms))
+
+		for _, platform := range product.Platforms {
+			platforms[platform.Id] = productPricesMap(platform.Prices)
+		}
+
+		result[product.Sku] = []*ProjectDiffChange{
+			{Field: "enabled", Source: product.Enabled},
+			{Field: "default_currency", Source: product.DefaultCurrency},
+			{Field: "url", Source: product.Url},
+			{Field: "name", Source: product.Name},
+			{Field: "description", Source: product.Description},
+			{Field: "long_description", Source: product.LongDescription},
+			{Field: "platforms", Source: platforms},
+		}
+	}
+
+	return result
+}
+
+// diffProjectItems compares the compared fields of the products matched by SKU.
+// Every item holds the values of the same fields in the same order.
+func diffProjectItems(source, target map[string][]*ProjectDiffChange) *ProjectDiffItems {
+	items := &ProjectDiffItems{
+		OnlyInSource: make([]string, 0),
+		OnlyInTarget: make([]string, 0),
+		Changed:      make([]*ProjectDiffItem, 0),
+	}
+
+	for sku, sourceFields := range source {
+		targetFields, ok := target[sku]
+
+		if !ok {
+			items.OnlyInSource = append(items.OnlyInSource, sku)
+			continue
+		}
+
+		item := &ProjectDiffItem{Sku: sku, Changes: make([]*ProjectDiffChange, 0)}
+
+		for i, field := range sourceFields {
+			if isFieldChanged(field.Source, targetFields[i].Source) {
+				item.Changes = append(item.Changes, &ProjectDiffChange{
+					Field:  field.Field,
+					Source: field.Source,
+					Target: targetFields[i].Source,
+				})
+			}
+		}
+
+		if len(item.Changes) > 0 {
+			items.Changed = append(items.Changed, item)
+		}
+	}
+
+	for sku := range target {
+		if _, ok := source[sku]; !ok {
+			items.OnlyInTarget = append(items.OnlyInTarget, sku)
+		}
+	}
+
+	sort.Strings(items.OnlyInSource)
+	sort.Strings(items.OnlyInTarget)
+	sort.Slice(items.Changed, func(i, j int) bool {
+		return items.Changed[i].Sku < items.Changed[j].Sku
+	})
+
+	return items
+}
@@ -9,9 +9,12 @@ import (
 	billMock "github.com/paysuper/paysuper-billing-server/pkg/mocks"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/callback"
 	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
 	"github.com/paysuper/paysuper-management-api/internal/mock"
 	"github.com/paysuper/paysuper-management-api/internal/test"
+	"github.com/paysuper/paysuper-management-api/internal/theme"
+	"github.com/paysuper/paysuper-management-api/pkg/signature"
 	"github.com/stretchr/testify/assert"
 	mock2 "github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -43,7 +46,7 @@ func (suite *ProjectTestSuite) SetupTest() {
 	}
 	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
 		mw.Pre(test.PreAuthUserMiddleware(user))
-		suite.router = NewProjectRoute(set.HandlerSet, set.GlobalConfig)
+		suite.router = NewProjectRoute(set.HandlerSet, theme.NewStore(mock.NewAwsManagerFilesMock(make(map[string][]byte)), projectThemeCacheTtl), set.GlobalConfig)
 		return common.Handlers{
 			suite.router,
 		}
@@ -633,4 +636,363 @@ func (suite *ProjectTestSuite) TestProject_CreateProjectWithoutCallbackProtocol_
 
 	assert.NoError(suite.T(), err)
 	assert.NotEmpty(suite.T(), res.Body.String())
-}
\ No newline at end of file
+}
+func (suite *ProjectTestSuite) newCloneSourceProject() *billing.Project {
+	return &billing.Project{
+		Id:                 bson.NewObjectId().Hex(),
+		MerchantId:         "ffffffffffffffffffffffff",
+		Name:               map[string]string{"en": "A"},
+		CallbackCurrency:   "RUB",
+		CallbackProtocol:   pkg.ProjectCallbackProtocolEmpty,
+		LimitsCurrency:     "RUB",
+		MaxPaymentAmount:   15000,
+		SecretKey:          "secret",
+		UrlProcessPayment:  "http://test.unit.test/process",
+		UrlRedirectSuccess: "http://test.unit.test/success",
+	}
+}
+
+func (suite *ProjectTestSuite) TestProject_CloneProject_Ok() {
+	shouldBe := require.New(suite.T())
+	source := suite.newCloneSourceProject()
+	cloneId := bson.NewObjectId().Hex()
+
+	billingService := &billMock.BillingService{}
+	billingService.On("GetProject", mock2.Anything, mock2.Anything).
+		Return(&grpc.ChangeProjectResponse{Status: pkg.ResponseStatusOk, Item: source}, nil)
+	billingService.On("ChangeProject", mock2.Anything, mock2.MatchedBy(func(req *billing.Project) bool {
+		return req.Id == "" && req.SecretKey == "" && req.Name["en"] == "A"+projectCloneNameSuffix &&
+			req.UrlProcessPayment == "https://prod.unit.test/process" && req.UrlRedirectSuccess == source.UrlRedirectSuccess
+	})).Return(&grpc.ChangeProjectResponse{Status: pkg.ResponseStatusOk, Item: &billing.Project{Id: cloneId}}, nil)
+	billingService.On("ListProducts", mock2.Anything, mock2.Anything).Return(&grpc.ListProductsResponse{
+		Total:    1,
+		Products: []*grpc.Product{{Id: bson.NewObjectId().Hex(), Sku: "sku_1"}},
+	}, nil)
+	billingService.On("CreateOrUpdateProduct", mock2.Anything, mock2.MatchedBy(func(req *grpc.Product) bool {
+		return req.Id == "" && req.ProjectId == cloneId
+	})).Return(&grpc.Product{Id: bson.NewObjectId().Hex()}, nil)
+	billingService.On("GetKeyProducts", mock2.Anything, mock2.Anything).Return(&grpc.ListKeyProductsResponse{
+		Status:   pkg.ResponseStatusOk,
+		Products: []*grpc.KeyProduct{{Id: bson.NewObjectId().Hex(), Sku: "key_sku_1"}},
+	}, nil)
+	billingService.On("CreateOrUpdateKeyProduct", mock2.Anything, mock2.Anything).Return(&grpc.KeyProductResponse{
+		Status:  http.StatusBadRequest,
+		Message: &grpc.ResponseErrorMessage{Message: "some error"},
+	}, nil)
+	suite.router.dispatch.Services.Billing = billingService
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Params(":"+common.RequestParameterProjectId, source.Id).
+		Path(common.AuthUserGroupPath + projectsClonePath).
+		Init(test.ReqInitJSON()).
+		BodyString(`{"overrides": {"url_process_payment": "https://prod.unit.test/process"}}`).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+
+	result := &ProjectCloneResult{}
+	shouldBe.NoError(json.Unmarshal(res.Body.Bytes(), result))
+	shouldBe.Equal(cloneId, result.Project.Id)
+	shouldBe.Len(result.Products, 1)
+	shouldBe.NotEmpty(result.Products[0].Id)
+	shouldBe.Len(result.KeyProducts, 1)
+	shouldBe.Empty(result.KeyProducts[0].Id)
+	shouldBe.Equal("some error", result.KeyProducts[0].Message.Message)
+}
+
+func (suite *ProjectTestSuite) TestProject_CloneProject_SkipProducts_Ok() {
+	shouldBe := require.New(suite.T())
+	source := suite.newCloneSourceProject()
+
+	billingService := &billMock.BillingService{}
+	billingService.On("GetProject", mock2.Anything, mock2.Anything).
+		Return(&grpc.ChangeProjectResponse{Status: pkg.ResponseStatusOk, Item: source}, nil)
+	billingService.On("ChangeProject", mock2.Anything, mock2.Anything).
+		Return(&grpc.ChangeProjectResponse{Status: pkg.ResponseStatusOk, Item: &billing.Project{Id: bson.NewObjectId().Hex()}}, nil)
+	suite.router.dispatch.Services.Billing = billingService
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Params(":"+common.RequestParameterProjectId, source.Id).
+		Path(common.AuthUserGroupPath + projectsClonePath).
+		Init(test.ReqInitJSON()).
+		BodyString(`{"skip_products": true, "skip_key_products": true}`).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+	billingService.AssertNotCalled(suite.T(), "ListProducts", mock2.Anything, mock2.Anything)
+	billingService.AssertNotCalled(suite.T(), "GetKeyProducts", mock2.Anything, mock2.Anything)
+}
+
+func (suite *ProjectTestSuite) TestProject_CloneProject_ListProducts_Error() {
+	shouldBe := require.New(suite.T())
+	source := suite.newCloneSourceProject()
+
+	billingService := &billMock.BillingService{}
+	billingService.On("GetProject", mock2.Anything, mock2.Anything).
+		Return(&grpc.ChangeProjectResponse{Status: pkg.ResponseStatusOk, Item: source}, nil)
+	billingService.On("ListProducts", mock2.Anything, mock2.Anything).Return(nil, errors.New("some error"))
+	suite.router.dispatch.Services.Billing = billingService
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Params(":"+common.RequestParameterProjectId, source.Id).
+		Path(common.AuthUserGroupPath + projectsClonePath).
+		Init(test.ReqInitJSON()).
+		BodyString(`{}`).
+		Exec(suite.T())
+
+	shouldBe.Error(err)
+	billingService.AssertNotCalled(suite.T(), "ChangeProject", mock2.Anything, mock2.Anything)
+}
+
+func (suite *ProjectTestSuite) TestProject_CloneProject_InvalidOverrides_Error() {
+	shouldBe := require.New(suite.T())
+	source := suite.newCloneSourceProject()
+
+	billingService := &billMock.BillingService{}
+	billingService.On("GetProject", mock2.Anything, mock2.Anything).
+		Return(&grpc.ChangeProjectResponse{Status: pkg.ResponseStatusOk, Item: source}, nil)
+	suite.router.dispatch.Services.Billing = billingService
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Params(":"+common.RequestParameterProjectId, source.Id).
+		Path(common.AuthUserGroupPath + projectsClonePath).
+		Init(test.ReqInitJSON()).
+		BodyString(`{"overrides": {"max_payment_amount": "a lot"}}`).
+		Exec(suite.T())
+
+	shouldBe.Error(err)
+	httpErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusBadRequest, httpErr.Code)
+	shouldBe.Equal(common.ErrorMessageProjectCloneOverridesInvalid, httpErr.Message)
+	billingService.AssertNotCalled(suite.T(), "ChangeProject", mock2.Anything, mock2.Anything)
+}
+
+func (suite *ProjectTestSuite) TestProject_CloneProject_ForbiddenOverrides_Error() {
+	shouldBe := require.New(suite.T())
+	source := suite.newCloneSourceProject()
+
+	billingService := &billMock.BillingService{}
+	billingService.On("GetProject", mock2.Anything, mock2.Anything).
+		Return(&grpc.ChangeProjectResponse{Status: pkg.ResponseStatusOk, Item: source}, nil)
+	suite.router.dispatch.Services.Billing = billingService
+
+	for _, overrides := range []string{`{"secret_key": "key"}`, `{"status": 4}`, `{"merchant_id": "5be2c3022b9bb6000765d132"}`} {
+		_, err := suite.caller.Builder().
+			Method(http.MethodPost).
+			Params(":"+common.RequestParameterProjectId, source.Id).
+			Path(common.AuthUserGroupPath + projectsClonePath).
+			Init(test.ReqInitJSON()).
+			BodyString(`{"overrides": ` + overrides + `}`).
+			Exec(suite.T())
+
+		shouldBe.Error(err)
+		httpErr, ok := err.(*echo.HTTPError)
+		shouldBe.True(ok)
+		shouldBe.Equal(http.StatusBadRequest, httpErr.Code)
+		shouldBe.Equal(common.ErrorMessageProjectCloneOverridesInvalid, httpErr.Message)
+	}
+
+	billingService.AssertNotCalled(suite.T(), "ChangeProject", mock2.Anything, mock2.Anything)
+}
+
+func (suite *ProjectTestSuite) TestProject_CloneProject_NotFound_Error() {
+	shouldBe := require.New(suite.T())
+
+	billingService := &billMock.BillingService{}
+	billingService.On("GetProject", mock2.Anything, mock2.Anything).Return(&grpc.ChangeProjectResponse{
+		Status:  http.StatusNotFound,
+		Message: &grpc.ResponseErrorMessage{Message: "some error"},
+	}, nil)
+	suite.router.dispatch.Services.Billing = billingService
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Params(":"+common.RequestParameterProjectId, bson.NewObjectId().Hex()).
+		Path(common.AuthUserGroupPath + projectsClonePath).
+		Init(test.ReqInitJSON()).
+		BodyString(`{}`).
+		Exec(suite.T())
+
+	shouldBe.Error(err)
+	httpErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusNotFound, httpErr.Code)
+}
+
+func (suite *ProjectTestSuite) TestProject_DiffProjects_Ok() {
+	shouldBe := require.New(suite.T())
+	source := suite.newCloneSourceProject()
+	target := suite.newCloneSourceProject()
+	target.UrlProcessPayment = "https://prod.unit.test/process"
+	target.MaxPaymentAmount = 30000
+
+	price := func(amount float64) []*billing.ProductPrice {
+		return []*billing.ProductPrice{{Currency: "USD", Region: "USD", Amount: amount}}
+	}
+
+	billingService := &billMock.BillingService{}
+	billingService.On("GetProject", mock2.Anything, mock2.MatchedBy(func(req *grpc.GetProjectRequest) bool {
+		return req.ProjectId == source.Id
+	})).Return(&grpc.ChangeProjectResponse{Status: pkg.ResponseStatusOk, Item: source}, nil)
+	billingService.On("GetProject", mock2.Anything, mock2.MatchedBy(func(req *grpc.GetProjectRequest) bool {
+		return req.ProjectId == target.Id
+	})).Return(&grpc.ChangeProjectResponse{Status: pkg.ResponseStatusOk, Item: target}, nil)
+	billingService.On("ListProducts", mock2.Anything, mock2.MatchedBy(func(req *grpc.ListProductsRequest) bool {
+		return req.ProjectId == source.Id
+	})).Return(&grpc.ListProductsResponse{Total: 2, Products: []*grpc.Product{
+		{Sku: "both", Prices: price(10)},
+		{Sku: "source_only", Prices: price(10)},
+	}}, nil)
+	billingService.On("ListProducts", mock2.Anything, mock2.MatchedBy(func(req *grpc.ListProductsRequest) bool {
+		return req.ProjectId == target.Id
+	})).Return(&grpc.ListProductsResponse{Total: 2, Products: []*grpc.Product{
+		{Sku: "both", Prices: price(20)},
+		{Sku: "target_only", Prices: price(10)},
+	}}, nil)
+	billingService.On("GetKeyProducts", mock2.Anything, mock2.Anything).Return(&grpc.ListKeyProductsResponse{
+		Status:   pkg.ResponseStatusOk,
+		Products: []*grpc.KeyProduct{{Sku: "key_sku", DefaultCurrency: "USD"}},
+	}, nil)
+	suite.router.dispatch.Services.Billing = billingService
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterProjectId, source.Id, ":"+common.RequestParameterTargetProjectId, target.Id).
+		Path(common.AuthUserGroupPath + projectsDiffPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+
+	diff := &ProjectDiff{}
+	shouldBe.NoError(json.Unmarshal(res.Body.Bytes(), diff))
+	shouldBe.Len(diff.Settings, 2)
+	shouldBe.Equal("max_payment_amount", diff.Settings[0].Field)
+	shouldBe.Equal("url_process_payment", diff.Settings[1].Field)
+	shouldBe.Equal([]string{"source_only"}, diff.Products.OnlyInSource)
+	shouldBe.Equal([]string{"target_only"}, diff.Products.OnlyInTarget)
+	shouldBe.Len(diff.Products.Changed, 1)
+	shouldBe.Equal("both", diff.Products.Changed[0].Sku)
+	shouldBe.Equal("prices", diff.Products.Changed[0].Changes[0].Field)
+	shouldBe.Empty(diff.KeyProducts.OnlyInSource)
+	shouldBe.Empty(diff.KeyProducts.OnlyInTarget)
+	shouldBe.Empty(diff.KeyProducts.Changed)
+}
+
+func (suite *ProjectTestSuite) TestProject_DiffProjects_SameProject_Error() {
+	shouldBe := require.New(suite.T())
+	projectId := bson.NewObjectId().Hex()
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterProjectId, projectId, ":"+common.RequestParameterTargetProjectId, projectId).
+		Path(common.AuthUserGroupPath + projectsDiffPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.Error(err)
+	httpErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusBadRequest, httpErr.Code)
+}
+
+func (suite *ProjectTestSuite) testCallback(project *billing.Project, body string) (*callback.Result, error) {
+	billingService := &billMock.BillingService{}
+	billingService.On("GetProject", mock2.Anything, mock2.Anything).
+		Return(&grpc.ChangeProjectResponse{Status: pkg.ResponseStatusOk, Item: project}, nil)
+	suite.router.dispatch.Services.Billing = billingService
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Params(":"+common.RequestParameterProjectId, project.Id).
+		Path(common.AuthUserGroupPath + projectsCallbacksTestPath).
+		Init(test.ReqInitJSON()).
+		BodyString(body).
+		Exec(suite.T())
+
+	if err != nil {
+		return nil, err
+	}
+
+	require.Equal(suite.T(), http.StatusOK, res.Code)
+
+	result := &callback.Result{}
+	require.NoError(suite.T(), json.Unmarshal(res.Body.Bytes(), result))
+
+	return result, nil
+}
+
+func (suite *ProjectTestSuite) TestProject_TestCallback_Ok() {
+	shouldBe := require.New(suite.T())
+	project := suite.newCloneSourceProject()
+
+	result, err := suite.testCallback(project, `{"type": "process_payment"}`)
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(callback.TypeProcessPayment, result.Type)
+	shouldBe.Equal(project.UrlProcessPayment, result.Url)
+	shouldBe.Equal(http.StatusOK, result.Status)
+	shouldBe.Empty(result.Error)
+	shouldBe.NotEmpty(result.Request)
+	shouldBe.True(result.Signature.Valid)
+	shouldBe.True(result.Signature.Accepted)
+	shouldBe.Equal(signature.Sign([]byte(result.Request), project.SecretKey), result.Signature.Value)
+}
+
+func (suite *ProjectTestSuite) TestProject_TestCallback_InvalidSignature_Ok() {
+	shouldBe := require.New(suite.T())
+	project := suite.newCloneSourceProject()
+
+	result, err := suite.testCallback(project, `{"type": "process_payment", "invalid_signature": true}`)
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusForbidden, result.Status)
+	shouldBe.False(result.Signature.Valid)
+	shouldBe.False(result.Signature.Accepted)
+}
+
+func (suite *ProjectTestSuite) TestProject_TestCallback_UrlNotConfigured_Error() {
+	shouldBe := require.New(suite.T())
+	project := suite.newCloneSourceProject()
+
+	_, err := suite.testCallback(project, `{"type": "refund_payment"}`)
+
+	shouldBe.Error(err)
+	httpErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusBadRequest, httpErr.Code)
+	shouldBe.Equal(common.ErrorMessageCallbackUrlNotConfigured, httpErr.Message)
+}
+
+func (suite *ProjectTestSuite) TestProject_TestCallback_SecretKeyEmpty_Error() {
+	shouldBe := require.New(suite.T())
+	project := suite.newCloneSourceProject()
+	project.SecretKey = ""
+
+	_, err := suite.testCallback(project, `{"type": "process_payment"}`)
+
+	shouldBe.Error(err)
+	httpErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusBadRequest, httpErr.Code)
+	shouldBe.Equal(common.ErrorMessageCallbackSecretKeyEmpty, httpErr.Message)
+}
+
+func (suite *ProjectTestSuite) TestProject_TestCallback_UnknownType_Error() {
+	shouldBe := require.New(suite.T())
+
+	_, err := suite.testCallback(suite.newCloneSourceProject(), `{"type": "unknown"}`)
+
+	shouldBe.Error(err)
+	httpErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusBadRequest, httpErr.Code)
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/ProtocolONE/go-core/v2/pkg/logger"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/theme"
+	"net/http"
+	"time"
+)
+
+const (
+	projectsThemePath    = "/projects/:project_id/theme"
+	projectsThemeCssPath = "/projects/:project_id/theme.css"
+)
+
+const (
+	projectThemeCacheTtl    = 5 * time.Minute
+	projectThemeCssUrlMask  = "%s/api/v1/projects/%s/theme.css"
+	projectThemeContentType = "text/css; charset=utf-8"
+)
+
+type projectThemeRequest struct {
+	MerchantId string `json:"-" validate:"required,hexadecimal,len=24"`
+	ProjectId  string `json:"-" param:"project_id" validate:"required,hexadecimal,len=24"`
+}
+
+type projectThemeChangeRequest struct {
+	MerchantId string           `json:"-" validate:"required,hexadecimal,len=24"`
+	ProjectId  string           `json:"-" param:"project_id" validate:"required,hexadecimal,len=24"`
+	Colors     *theme.Colors    `json:"colors"`
+	LogoUrl    string           `json:"logo_url"`
+	Fonts      *theme.FontSet   `json:"fonts"`
+	Layout     *theme.Layout    `json:"layout"`
+	CustomCss  []*theme.CssRule `json:"custom_css"`
+}
+
+type projectThemeCssRequest struct {
+	ProjectId string `json:"-" param:"project_id" validate:"required,hexadecimal,len=24"`
+}
+
+// PaymentFormJsonData is the payment form data extended by the theme of the order project
+type PaymentFormJsonData struct {
+	*grpc.PaymentFormJsonData
+	Theme       *theme.Theme `json:"theme,omitempty"`
+	ThemeCssUrl string       `json:"theme_css_url,omitempty"`
+}
+
+func (h *ProjectRoute) getTheme(ctx echo.Context) error {
+	req := &projectThemeRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	if _, err := h.getMerchantProject(ctx, req.MerchantId, req.ProjectId); err != nil {
+		return err
+	}
+
+	projectTheme, err := h.themes.Get(ctx.Request().Context(), req.ProjectId)
+
+	if err != nil {
+		return h.themeErrorHandler(err, req.ProjectId)
+	}
+
+	if projectTheme == nil {
+		projectTheme = theme.NewTheme(req.ProjectId)
+	}
+
+	return ctx.JSON(http.StatusOK, projectTheme)
+}
+
+// setTheme replaces the payment form theme of the project, the empty theme resets the form to the default style
+func (h *ProjectRoute) setTheme(ctx echo.Context) error {
+	req := &projectThemeChangeRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	if _, err := h.getMerchantProject(ctx, req.MerchantId, req.ProjectId); err != nil {
+		return err
+	}
+
+	projectTheme := &theme.Theme{
+		ProjectId: req.ProjectId,
+		Colors:    req.Colors,
+		LogoUrl:   req.LogoUrl,
+		Fonts:     req.Fonts,
+		Layout:    req.Layout,
+		CustomCss: req.CustomCss,
+	}
+
+	if err := projectTheme.Validate(); err != nil {
+		return h.themeErrorHandler(err, req.ProjectId)
+	}
+
+	authUser := common.ExtractUserContext(ctx)
+
+	if err := h.themes.Save(ctx.Request().Context(), projectTheme, authUser.Id); err != nil {
+		return h.themeErrorHandler(err, req.ProjectId)
+	}
+
+	// the other replicas drop the cached stylesheets and themes on the next sync of the invalidations,
+	// the theme is saved already, so the failed broadcast leaves them stale until the cache ttl at worst
+	if err := h.dispatch.Services.Cache.Invalidate(ctx.Request().Context(), common.ResponseCacheTagProjectThemes); err != nil {
+		h.L().Error(
+			"response cache invalidation broadcast failed",
+			logger.PairArgs("err", err.Error(), "tag", common.ResponseCacheTagProjectThemes),
+		)
+	}
+
+	return ctx.JSON(http.StatusOK, projectTheme)
+}
+
+// getThemeCss returns the stylesheet of the project theme for the payment form.
+// The project without the theme gets the empty stylesheet, so the form can always link it.
+func (h *ProjectRoute) getThemeCss(ctx echo.Context) error {
+	req := &projectThemeCssRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	projectTheme, err := h.themes.Get(ctx.Request().Context(), req.ProjectId)
+
+	if err != nil {
+		return h.themeErrorHandler(err, req.ProjectId)
+	}
+
+	if projectTheme == nil {
+		projectTheme = theme.NewTheme(req.ProjectId)
+	}
+
+	return ctx.Blob(http.StatusOK, projectThemeContentType, projectTheme.Css())
+}
+
+func (h *ProjectRoute) themeErrorHandler(err error, projectId string) *echo.HTTPError {
+	if httpErr := getThemeValidationError(err); httpErr != nil {
+		return httpErr
+	}
+
+	h.L().Error(
+		"project theme storage call failed",
+		logger.PairArgs("err", err.Error(), "project_id", projectId),
+	)
+
+	return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessageProjectThemeStorageFailed)
+}
+
+func getThemeValidationError(err error) *echo.HTTPError {
+	switch err {
+	case theme.ErrorProjectIdEmpty:
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorRequestParamsIncorrect)
+	case theme.ErrorColorInvalid:
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageProjectThemeColorInvalid)
+	case theme.ErrorLogoUrlInvalid:
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageProjectThemeLogoUrlInvalid)
+	case theme.ErrorFontUnknown:
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageProjectThemeFontUnknown)
+	case theme.ErrorLayoutInvalid:
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageProjectThemeLayoutInvalid)
+	case theme.ErrorCssRulesTooMany:
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageProjectThemeCssRulesTooMany)
+	case theme.ErrorCssSelectorDenied:
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageProjectThemeCssSelectorDenied)
+	case theme.ErrorCssPropertyDenied:
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageProjectThemeCssPropertyDenied)
+	case theme.ErrorCssValueInvalid:
+		return echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageProjectThemeCssValueInvalid)
+	}
+
+	return nil
+}
+
+// getPaymentFormTheme returns the theme of the order project for the payment form.
+// The theme is optional for the form, so the failed lookup is logged and the form uses the default style.
+func (h *OrderRoute) getPaymentFormTheme(ctx echo.Context, data *grpc.PaymentFormJsonData) *PaymentFormJsonData {
+	formData := &PaymentFormJsonData{PaymentFormJsonData: data}
+
+	if data == nil || data.Project == nil || data.Project.Id == "" {
+		return formData
+	}
+
+	projectTheme, err := h.themes.Get(ctx.Request().Context(), data.Project.Id)
+
+	if err != nil {
+		h.L().Error(
+			"project theme storage call failed",
+			logger.PairArgs("err", err.Error(), "project_id", data.Project.Id),
+		)
+		return formData
+	}
+
+	if projectTheme != nil {
+		formData.Theme = projectTheme
+		formData.ThemeCssUrl = fmt.Sprintf(projectThemeCssUrlMask, h.cfg.PublicUrl, data.Project.Id)
+	}
+
+	return formData
+}
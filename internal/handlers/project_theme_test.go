@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	billMock "github.com/paysuper/paysuper-billing-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/branding"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/mock"
+	"github.com/paysuper/paysuper-management-api/internal/paymentform"
+	"github.com/paysuper/paysuper-management-api/internal/receipt"
+	"github.com/paysuper/paysuper-management-api/internal/test"
+	"github.com/paysuper/paysuper-management-api/internal/theme"
+	"github.com/paysuper/paysuper-management-api/internal/timeline"
+	"github.com/stretchr/testify/assert"
+	mock2 "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const (
+	projectThemeTestProjectId = "5dc3f6c5ad8b8c0001b1e2e1"
+	projectThemeTestBody      = `{
+		"colors": {"primary": "#00AAFF"},
+		"fonts": {"body": "roboto"},
+		"layout": {"alignment": "center", "border_radius": 4},
+		"custom_css": [{"selector": ".paysuper-form__button", "properties": {"font-weight": "600"}}]
+	}`
+)
+
+type ProjectThemeTestSuite struct {
+	suite.Suite
+	router      *ProjectRoute
+	orderRouter *OrderRoute
+	caller      *test.EchoReqResCaller
+	billing     *billMock.BillingService
+	files       map[string][]byte
+}
+
+func Test_ProjectTheme(t *testing.T) {
+	suite.Run(t, new(ProjectThemeTestSuite))
+}
+
+func (suite *ProjectThemeTestSuite) SetupTest() {
+	user := &common.AuthUser{
+		Id:         "ffffffffffffffffffffffff",
+		MerchantId: "ffffffffffffffffffffffff",
+	}
+
+	suite.billing = &billMock.BillingService{}
+	suite.billing.On("GetProject", mock2.Anything, mock2.Anything).
+		Return(&grpc.ChangeProjectResponse{
+			Status: pkg.ResponseStatusOk,
+			Item:   &billing.Project{Id: projectThemeTestProjectId, MerchantId: user.MerchantId},
+		}, nil)
+	suite.billing.On("PaymentFormJsonDataProcess", mock2.Anything, mock2.Anything).
+		Return(&grpc.PaymentFormJsonDataResponse{
+			Status: pkg.ResponseStatusOk,
+			Item: &grpc.PaymentFormJsonData{
+				Project: &grpc.PaymentFormJsonDataProject{Id: projectThemeTestProjectId},
+			},
+		}, nil)
+
+	var e error
+	settings := test.DefaultSettings()
+	srv := common.Services{
+		Billing: suite.billing,
+	}
+	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
+		mw.Pre(test.PreAuthUserMiddleware(user))
+		suite.files = make(map[string][]byte)
+		awsManager := mock.NewAwsManagerFilesMock(suite.files)
+		themeStore := theme.NewStore(awsManager, projectThemeCacheTtl)
+		suite.router = NewProjectRoute(set.HandlerSet, themeStore, set.GlobalConfig)
+		suite.orderRouter = NewOrderRoute(set.HandlerSet, timeline.NewJournal(awsManager), branding.NewStore(awsManager), receipt.NewCache(awsManager), paymentform.NewTokens(awsManager), themeStore, set.GlobalConfig)
+		return common.Handlers{
+			suite.router,
+			suite.orderRouter,
+		}
+	})
+	if e != nil {
+		panic(e)
+	}
+}
+
+func (suite *ProjectThemeTestSuite) TearDownTest() {}
+
+func (suite *ProjectThemeTestSuite) setTheme(body string) (*http.Response, error) {
+	res, err := suite.caller.Builder().
+		Method(http.MethodPut).
+		Params(":"+common.RequestParameterProjectId, projectThemeTestProjectId).
+		Path(common.AuthUserGroupPath + projectsThemePath).
+		Init(test.ReqInitJSON()).
+		BodyString(body).
+		Exec(suite.T())
+
+	return res.Result(), err
+}
+
+func (suite *ProjectThemeTestSuite) TestProjectTheme_Get_Empty() {
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterProjectId, projectThemeTestProjectId).
+		Path(common.AuthUserGroupPath + projectsThemePath).
+		Exec(suite.T())
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, res.Code)
+
+	result := &theme.Theme{}
+	require.NoError(suite.T(), json.Unmarshal(res.Body.Bytes(), result))
+	assert.Equal(suite.T(), projectThemeTestProjectId, result.ProjectId)
+	assert.Nil(suite.T(), result.Colors)
+	assert.Empty(suite.T(), result.CustomCss)
+}
+
+func (suite *ProjectThemeTestSuite) TestProjectTheme_Set_Ok() {
+	res, err := suite.setTheme(projectThemeTestBody)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, res.StatusCode)
+
+	res2, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterProjectId, projectThemeTestProjectId).
+		Path(common.AuthUserGroupPath + projectsThemePath).
+		Exec(suite.T())
+	require.NoError(suite.T(), err)
+
+	result := &theme.Theme{}
+	require.NoError(suite.T(), json.Unmarshal(res2.Body.Bytes(), result))
+	assert.Equal(suite.T(), "#00AAFF", result.Colors.Primary)
+	assert.Equal(suite.T(), "roboto", result.Fonts.Body)
+	assert.Equal(suite.T(), theme.AlignmentCenter, result.Layout.Alignment)
+	assert.Len(suite.T(), result.CustomCss, 1)
+	assert.Equal(suite.T(), "ffffffffffffffffffffffff", result.UpdatedBy)
+}
+
+func (suite *ProjectThemeTestSuite) TestProjectTheme_Set_ValidationError() {
+	cases := []struct {
+		body    string
+		message *grpc.ResponseErrorMessage
+	}{
+		{`{"colors": {"primary": "blue"}}`, common.ErrorMessageProjectThemeColorInvalid},
+		{`{"logo_url": "javascript:alert(1)"}`, common.ErrorMessageProjectThemeLogoUrlInvalid},
+		{`{"fonts": {"heading": "comic_sans"}}`, common.ErrorMessageProjectThemeFontUnknown},
+		{`{"layout": {"border_radius": 100}}`, common.ErrorMessageProjectThemeLayoutInvalid},
+		{`{"custom_css": [{"selector": "body", "properties": {"color": "#000000"}}]}`, common.ErrorMessageProjectThemeCssSelectorDenied},
+		{`{"custom_css": [{"selector": ".paysuper-form", "properties": {"position": "fixed"}}]}`, common.ErrorMessageProjectThemeCssPropertyDenied},
+		{`{"custom_css": [{"selector": ".paysuper-form", "properties": {"color": "red;}"}}]}`, common.ErrorMessageProjectThemeCssValueInvalid},
+	}
+
+	for _, c := range cases {
+		_, err := suite.setTheme(c.body)
+		require.Error(suite.T(), err)
+
+		httpErr, ok := err.(*echo.HTTPError)
+		require.True(suite.T(), ok)
+		assert.Equal(suite.T(), http.StatusBadRequest, httpErr.Code)
+		assert.Equal(suite.T(), c.message, httpErr.Message)
+	}
+}
+
+func (suite *ProjectThemeTestSuite) TestProjectTheme_Set_ProjectNotFound() {
+	suite.billing.ExpectedCalls = nil
+	suite.billing.On("GetProject", mock2.Anything, mock2.Anything).
+		Return(&grpc.ChangeProjectResponse{Status: pkg.ResponseStatusNotFound, Message: mock.SomeError}, nil)
+
+	_, err := suite.setTheme(projectThemeTestBody)
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	assert.Equal(suite.T(), http.StatusNotFound, httpErr.Code)
+}
+
+func (suite *ProjectThemeTestSuite) TestProjectTheme_Css_Ok() {
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterProjectId, projectThemeTestProjectId).
+		Path(common.NoAuthGroupPath + projectsThemeCssPath).
+		Exec(suite.T())
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, res.Code)
+	assert.Equal(suite.T(), projectThemeContentType, res.Header().Get(echo.HeaderContentType))
+	assert.NotContains(suite.T(), res.Body.String(), ":root")
+
+	_, err = suite.setTheme(projectThemeTestBody)
+	require.NoError(suite.T(), err)
+
+	res, err = suite.caller.Builder().
+		Method(http.MethodGet).
+		Params(":"+common.RequestParameterProjectId, projectThemeTestProjectId).
+		Path(common.NoAuthGroupPath + projectsThemeCssPath).
+		Exec(suite.T())
+	require.NoError(suite.T(), err)
+	assert.Contains(suite.T(), res.Body.String(), "--paysuper-color-primary: #00AAFF;")
+	assert.Contains(suite.T(), res.Body.String(), ".paysuper-form__button {\n  font-weight: 600;\n}")
+}
+
+func (suite *ProjectThemeTestSuite) TestProjectTheme_PaymentFormData_Ok() {
+	orderId := uuid.New().String()
+	query, err := suite.orderRouter.paymentForm.Query(&paymentform.Params{OrderId: orderId}, time.Now())
+	require.NoError(suite.T(), err)
+
+	getFormData := func() *PaymentFormJsonData {
+		res, err := suite.caller.Builder().
+			Method(http.MethodGet).
+			Params(":"+common.RequestParameterOrderId, orderId).
+			Path(common.NoAuthGroupPath + orderIdPath).
+			SetQueryParams(query).
+			Init(test.ReqInitJSON()).
+			Exec(suite.T())
+		require.NoError(suite.T(), err)
+		require.Equal(suite.T(), http.StatusOK, res.Code)
+
+		data := &PaymentFormJsonData{}
+		require.NoError(suite.T(), json.Unmarshal(res.Body.Bytes(), data))
+
+		return data
+	}
+
+	data := getFormData()
+	assert.Nil(suite.T(), data.Theme)
+	assert.Empty(suite.T(), data.ThemeCssUrl)
+
+	_, err = suite.setTheme(projectThemeTestBody)
+	require.NoError(suite.T(), err)
+
+	data = getFormData()
+	require.NotNil(suite.T(), data.Theme)
+	assert.Equal(suite.T(), "#00AAFF", data.Theme.Colors.Primary)
+	assert.Equal(suite.T(), "http://localhost/api/v1/projects/"+projectThemeTestProjectId+"/theme.css", data.ThemeCssUrl)
+	assert.Equal(suite.T(), projectThemeTestProjectId, data.Project.Id)
+}
+
+func (suite *ProjectThemeTestSuite) TestProjectTheme_OtherReplica_Invalidated() {
+	ctx := context.Background()
+
+	// the other replica caches the project without the theme
+	other := theme.NewStore(mock.NewAwsManagerFilesMock(suite.files), projectThemeCacheTtl)
+	otherCache := common.NewResponseCache(nil)
+	otherCache.Broadcast(mock.NewAwsManagerFilesMock(suite.files))
+	otherCache.OnInvalidate(common.ResponseCacheTagProjectThemes, other.Invalidate)
+
+	cached, err := other.Get(ctx, projectThemeTestProjectId)
+	require.NoError(suite.T(), err)
+	require.Nil(suite.T(), cached)
+
+	_, err = suite.setTheme(projectThemeTestBody)
+	require.NoError(suite.T(), err)
+
+	cache := common.NewResponseCache(nil)
+	cache.Broadcast(mock.NewAwsManagerFilesMock(suite.files))
+	require.NoError(suite.T(), cache.Invalidate(ctx, common.ResponseCacheTagProjectThemes))
+
+	cached, err = other.Get(ctx, projectThemeTestProjectId)
+	require.NoError(suite.T(), err)
+	assert.Nil(suite.T(), cached)
+
+	require.NoError(suite.T(), otherCache.Sync(ctx))
+
+	cached, err = other.Get(ctx, projectThemeTestProjectId)
+	require.NoError(suite.T(), err)
+	require.NotNil(suite.T(), cached)
+	assert.Equal(suite.T(), "#00AAFF", cached.Colors.Primary)
+}
@@ -1,10 +1,23 @@
 package handlers
 
 import (
+	"context"
 	"github.com/ProtocolONE/go-core/v2/pkg/config"
 	"github.com/ProtocolONE/go-core/v2/pkg/provider"
 	awsWrapper "github.com/paysuper/paysuper-aws-manager"
+	"github.com/paysuper/paysuper-management-api/internal/branding"
+	"github.com/paysuper/paysuper-management-api/internal/dataexport"
 	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/esign"
+	"github.com/paysuper/paysuper-management-api/internal/invite"
+	"github.com/paysuper/paysuper-management-api/internal/paymentcosts"
+	"github.com/paysuper/paysuper-management-api/internal/paymentform"
+	"github.com/paysuper/paysuper-management-api/internal/receipt"
+	"github.com/paysuper/paysuper-management-api/internal/savedcard"
+	"github.com/paysuper/paysuper-management-api/internal/storage"
+	"github.com/paysuper/paysuper-management-api/internal/taxrates"
+	"github.com/paysuper/paysuper-management-api/internal/theme"
+	"github.com/paysuper/paysuper-management-api/internal/timeline"
 	"gopkg.in/go-playground/validator.v9"
 )
 
@@ -40,23 +53,90 @@ func ProviderHandlers(initial config.Initial, srv common.Services, validator *va
 		return nil, func() {}, err
 	}
 
-	return []common.Handler{
-		NewCardPayWebHook(hSet, &copyCfg),
+	// the aws manager can't delete the files, the reporter bucket is cleaned up with the direct client
+	reporterBucket, err := storage.NewBucket(
+		cfg.AwsAccessKeyIdReporter,
+		cfg.AwsSecretAccessKeyReporter,
+		cfg.AwsRegionReporter,
+		cfg.AwsBucketReporter,
+	)
+	if err != nil {
+		return nil, func() {}, err
+	}
+
+	agreementBucket, err := storage.NewBucket(
+		cfg.AwsAccessKeyIdAgreement,
+		cfg.AwsSecretAccessKeyAgreement,
+		cfg.AwsRegionAgreement,
+		cfg.AwsBucketAgreement,
+	)
+	if err != nil {
+		return nil, func() {}, err
+	}
+
+	bankDirectory, err := newBankDirectory(initial, cfg)
+	if err != nil {
+		return nil, func() {}, err
+	}
+
+	// the merchant agreement is signed electronically only when the e-signature provider is configured
+	var signer esign.Provider
+	if cfg.ESignProvider != "" {
+		signer, err = esign.New(cfg.ESignProvider, esign.Options{
+			CallbackSecret: cfg.ESignCallbackSecret,
+			Sandbox:        cfg.SandboxMode,
+			Storage:        awsManagerAgreement,
+		})
+		if err != nil {
+			return nil, func() {}, err
+		}
+	}
+
+	// the background jobs run until the handlers are released
+	backgroundCtx, backgroundCancel := context.WithCancel(context.Background())
+
+	// scheduled tax rate changes are applied in the background by one replica at a time
+	taxSchedule := taxrates.NewSchedule(awsManagerReporter, srv.Tax)
+	go taxSchedule.Run(backgroundCtx, cfg.TaxScheduleInterval, &hSet.AwareSet)
+
+	// the response cache invalidations of the other replicas are picked up in the background
+	if srv.Cache != nil {
+		srv.Cache.Broadcast(awsManagerReporter)
+		go srv.Cache.RunSync(backgroundCtx, responseCacheSyncInterval, &hSet.AwareSet)
+	}
+
+	orderJournal := timeline.NewJournal(awsManagerReporter)
+	brandingStore := branding.NewStore(awsManagerReporter)
+	receiptCache := receipt.NewCache(awsManagerReporter)
+	paymentTokens := paymentform.NewTokens(awsManagerReporter)
+	themeStore := theme.NewStore(awsManagerReporter, projectThemeCacheTtl)
+	// the themes cached by the replicas are dropped with the theme stylesheets invalidated by the theme change
+	srv.Cache.OnInvalidate(common.ResponseCacheTagProjectThemes, themeStore.Invalidate)
+	inviteStore := invite.NewStore(awsManagerReporter, reporterBucket)
+
+	// expired merchant data export bundles are deleted in the background by one replica at a time
+	exportStore := dataexport.NewStore(awsManagerReporter, reporterBucket)
+	go exportStore.RunCleanup(backgroundCtx, merchantExportCleanupInterval, &hSet.AwareSet)
+	exportRoute := NewMerchantExportRoute(hSet, exportStore, awsManagerAgreement, &copyCfg)
+	offboardingRoute := NewMerchantOffboardingRoute(hSet, awsManagerReporter, awsManagerAgreement, &copyCfg)
+
+	handlers := common.Handlers{
+		NewCardPayWebHook(hSet, orderJournal, &copyCfg),
 		NewCountryApiV1(hSet, &copyCfg),
 		NewDashboardRoute(hSet, &copyCfg),
 		NewKeyRoute(hSet, &copyCfg),
 		NewKeyProductRoute(hSet, &copyCfg),
-		NewOnboardingRoute(hSet, initial, awsManagerAgreement, &copyCfg),
-		NewOrderRoute(hSet, &copyCfg),
+		NewOnboardingRoute(hSet, bankDirectory, awsManagerAgreement, brandingStore, &copyCfg),
+		NewOrderRoute(hSet, orderJournal, brandingStore, receiptCache, paymentTokens, themeStore, &copyCfg),
 		NewPayLinkRoute(hSet, &copyCfg),
-		NewPaymentCostRoute(hSet, &copyCfg),
+		NewPaymentCostRoute(hSet, paymentcosts.NewVersions(awsManagerReporter), &copyCfg),
 		NewPaymentMethodApiV1(hSet, &copyCfg),
 		NewPriceGroupRoute(hSet, &copyCfg),
 		NewProductRoute(hSet, &copyCfg),
-		NewProjectRoute(hSet, &copyCfg),
+		NewProjectRoute(hSet, themeStore, &copyCfg),
 		NewReportFileRoute(hSet, awsManagerReporter, &copyCfg),
 		NewRoyaltyReportsRoute(hSet, &copyCfg),
-		NewTaxesRoute(hSet, &copyCfg),
+		NewTaxesRoute(hSet, taxSchedule, &copyCfg),
 		NewTokenRoute(hSet, &copyCfg),
 		NewUserProfileRoute(hSet, &copyCfg),
 		NewVatReportsRoute(hSet, &copyCfg),
@@ -64,11 +144,29 @@ func ProviderHandlers(initial config.Initial, srv common.Services, validator *va
 		NewBalanceRoute(hSet, &copyCfg),
 		NewPayoutDocumentsRoute(hSet, &copyCfg),
 		NewPricingRoute(hSet, &copyCfg),
-		NewRecurringRoute(hSet, &copyCfg),
-		NewOperatingCompanyRoute(hSet, &copyCfg),
+		NewRecurringRoute(hSet, savedcard.NewStore(awsManagerReporter), &copyCfg),
+		NewOperatingCompanyRoute(hSet, brandingStore, &copyCfg),
 		NewPaymentMinLimitSystemRoute(hSet, &copyCfg),
-		NewAdminUsersRoute(hSet, &copyCfg),
-		NewMerchantUsersRoute(hSet, &copyCfg),
-		NewUserRoute(hSet, &copyCfg),
-	}, func() {}, nil
+		NewAdminUsersRoute(hSet, inviteStore, &copyCfg),
+		NewMerchantUsersRoute(hSet, inviteStore, &copyCfg),
+		NewUserRoute(hSet, inviteStore, &copyCfg),
+		NewOnboardingChecklistRoute(hSet, &copyCfg),
+		NewMerchantDocumentsRoute(hSet, awsManagerAgreement, agreementBucket, &copyCfg),
+		exportRoute,
+		offboardingRoute,
+		NewSignatureRoute(hSet, &copyCfg),
+		NewCacheRoute(hSet, &copyCfg),
+		NewCheckoutLocaleRoute(hSet, &copyCfg),
+	}
+
+	if signer != nil {
+		handlers = append(handlers, NewAgreementSignatureRoute(hSet, awsManagerAgreement, brandingStore, signer, &copyCfg))
+	}
+
+	return handlers, func() {
+		backgroundCancel()
+		orderJournal.Wait()
+		exportRoute.Wait()
+		offboardingRoute.Wait()
+	}, nil
 }
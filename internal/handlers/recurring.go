@@ -8,32 +8,68 @@ import (
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
 	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
 	"github.com/paysuper/paysuper-management-api/internal/helpers"
+	"github.com/paysuper/paysuper-management-api/internal/savedcard"
+	"github.com/paysuper/paysuper-management-api/internal/throttle"
+	"github.com/paysuper/paysuper-recurring-repository/pkg/constant"
+	"github.com/paysuper/paysuper-recurring-repository/pkg/proto/repository"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 const (
-	removeSavedCardPath = "/saved_card"
+	removeSavedCardPath     = "/saved_card"
+	savedCardsPath          = "/saved_cards"
+	savedCardsIdPath        = "/saved_cards/:id"
+	savedCardsIdDefaultPath = "/saved_cards/:id/default"
 )
 
+const (
+	savedCardDeleteWindow = 24 * time.Hour
+)
+
+type savedCardRequest struct {
+	Id     string `json:"-" param:"id" validate:"required,hexadecimal,len=24"`
+	Cookie string `json:"-" validate:"required"`
+}
+
+type savedCardsResponse struct {
+	Items []*savedcard.Card `json:"items"`
+}
+
 type RecurringRoute struct {
-	dispatch common.HandlerSet
-	cfg      common.Config
+	dispatch    common.HandlerSet
+	cfg         common.Config
+	preferences *savedcard.Store
+	deletions   *throttle.Throttle
 	provider.LMT
 }
 
-func NewRecurringRoute(set common.HandlerSet, cfg *common.Config) *RecurringRoute {
+func NewRecurringRoute(
+	set common.HandlerSet,
+	preferences *savedcard.Store,
+	cfg *common.Config,
+) *RecurringRoute {
 	set.AwareSet.Logger = set.AwareSet.Logger.WithFields(logger.Fields{"router": "RecurringRoute"})
 	return &RecurringRoute{
-		dispatch: set,
-		LMT:      &set.AwareSet,
-		cfg:      *cfg,
+		dispatch:    set,
+		LMT:         &set.AwareSet,
+		cfg:         *cfg,
+		preferences: preferences,
+		deletions:   throttle.New(cfg.SavedCardDeleteInterval, cfg.SavedCardDeleteDailyLimit, savedCardDeleteWindow),
 	}
 }
 
 func (h *RecurringRoute) Route(groups *common.Groups) {
 	groups.Common.DELETE(removeSavedCardPath, h.removeSavedCard)
+	groups.Common.GET(savedCardsPath, h.listSavedCards)
+	groups.Common.PUT(savedCardsIdDefaultPath, h.setDefaultSavedCard)
+	groups.Common.DELETE(savedCardsIdPath, h.deleteSavedCard)
 }
 
+// removeSavedCard is the legacy deletion by the card identifier in the body.
+// The deletions are throttled by the cookie and audited when the customer of the cookie is known.
 func (h *RecurringRoute) removeSavedCard(ctx echo.Context) error {
 	req := &grpc.DeleteSavedCardRequest{}
 	err := ctx.Bind(req)
@@ -50,6 +86,206 @@ func (h *RecurringRoute) removeSavedCard(ctx echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, common.GetValidationError(err))
 	}
 
+	if err = h.allowDeletion(ctx, req.Cookie); err != nil {
+		return err
+	}
+
+	if err = h.deleteBillingSavedCard(ctx, req); err != nil {
+		return err
+	}
+
+	if customerId, err := h.deserializeCookie(ctx, req.Cookie); err == nil && customerId != "" {
+		h.recordSavedCardDeleted(ctx, customerId, req.Id)
+	}
+
+	return ctx.NoContent(http.StatusOK)
+}
+
+// listSavedCards returns the saved cards of the customer of the token cookie
+func (h *RecurringRoute) listSavedCards(ctx echo.Context) error {
+	customerId, err := h.getCustomerId(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	cards, err := h.getSavedCards(ctx, customerId)
+
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, &savedCardsResponse{Items: cards})
+}
+
+// setDefaultSavedCard makes the card the preselected one on the payment form of the customer
+func (h *RecurringRoute) setDefaultSavedCard(ctx echo.Context) error {
+	req, customerId, err := h.bindSavedCardRequest(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	card, err := h.getSavedCard(ctx, customerId, req.Id)
+
+	if err != nil {
+		return err
+	}
+
+	event := h.newSavedCardEvent(ctx, savedcard.EventDefaultChanged, req.Id)
+
+	if err = h.preferences.SetDefault(ctx.Request().Context(), customerId, event); err != nil {
+		h.L().Error(
+			"saved cards preferences storage call failed",
+			logger.PairArgs("err", err.Error(), "customer_id", customerId),
+		)
+		return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessageSavedCardStorageFailed)
+	}
+
+	card.IsDefault = true
+
+	return ctx.JSON(http.StatusOK, card)
+}
+
+// deleteSavedCard removes the card of the customer, the deletions are throttled by the customer
+func (h *RecurringRoute) deleteSavedCard(ctx echo.Context) error {
+	req, customerId, err := h.bindSavedCardRequest(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = h.getSavedCard(ctx, customerId, req.Id); err != nil {
+		return err
+	}
+
+	if err = h.allowDeletion(ctx, customerId); err != nil {
+		return err
+	}
+
+	if err = h.deleteBillingSavedCard(ctx, &grpc.DeleteSavedCardRequest{Id: req.Id, Cookie: req.Cookie}); err != nil {
+		return err
+	}
+
+	h.recordSavedCardDeleted(ctx, customerId, req.Id)
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (h *RecurringRoute) bindSavedCardRequest(ctx echo.Context) (*savedCardRequest, string, error) {
+	req := &savedCardRequest{
+		Id:     ctx.Param(common.RequestParameterId),
+		Cookie: helpers.GetRequestCookie(ctx, common.CustomerTokenCookiesName),
+	}
+
+	if err := h.dispatch.Validate.Struct(req); err != nil {
+		return nil, "", echo.NewHTTPError(http.StatusBadRequest, common.GetValidationError(err))
+	}
+
+	customerId, err := h.getCustomerId(ctx)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return req, customerId, nil
+}
+
+// getCustomerId returns the customer of the token cookie, the virtual customer gets the empty identifier
+func (h *RecurringRoute) getCustomerId(ctx echo.Context) (string, error) {
+	return h.deserializeCookie(ctx, helpers.GetRequestCookie(ctx, common.CustomerTokenCookiesName))
+}
+
+// deserializeCookie resolves the customer of the token cookie by the billing server issuing the cookie
+func (h *RecurringRoute) deserializeCookie(ctx echo.Context, cookie string) (string, error) {
+	if cookie == "" {
+		return "", echo.NewHTTPError(http.StatusUnauthorized, common.ErrorMessageSavedCardCustomerInvalid)
+	}
+
+	req := &grpc.DeserializeCookieRequest{Cookie: cookie}
+	res, err := h.dispatch.Services.Billing.DeserializeCookie(ctx.Request().Context(), req)
+
+	if err != nil {
+		return "", h.dispatch.SrvCallHandler(req, err, pkg.ServiceName, "DeserializeCookie")
+	}
+
+	if res.Status != pkg.ResponseStatusOk || res.Item == nil {
+		return "", echo.NewHTTPError(http.StatusUnauthorized, common.ErrorMessageSavedCardCustomerInvalid)
+	}
+
+	return res.Item.CustomerId, nil
+}
+
+// getSavedCards returns the saved cards of the customer with the default card marked
+func (h *RecurringRoute) getSavedCards(ctx echo.Context, customerId string) ([]*savedcard.Card, error) {
+	cards := make([]*savedcard.Card, 0)
+
+	if customerId == "" {
+		return cards, nil
+	}
+
+	req := &repository.SavedCardRequest{Token: customerId}
+	res, err := h.dispatch.Services.Repository.FindSavedCards(ctx.Request().Context(), req)
+
+	if err != nil {
+		return nil, h.dispatch.SrvCallHandler(req, err, constant.PayOneRepositoryServiceName, "FindSavedCards")
+	}
+
+	preferences, err := h.preferences.Get(ctx.Request().Context(), customerId)
+
+	if err != nil {
+		h.L().Error(
+			"saved cards preferences storage call failed",
+			logger.PairArgs("err", err.Error(), "customer_id", customerId),
+		)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessageSavedCardStorageFailed)
+	}
+
+	for _, v := range res.SavedCards {
+		card := &savedcard.Card{
+			Id:        v.Id,
+			MaskedPan: v.MaskedPan,
+			Brand:     savedcard.Brand(v.MaskedPan),
+			IsDefault: v.Id == preferences.DefaultCardId,
+		}
+
+		if v.Expire != nil {
+			card.Expire = &savedcard.Expire{Month: v.Expire.Month, Year: v.Expire.Year}
+		}
+
+		cards = append(cards, card)
+	}
+
+	return cards, nil
+}
+
+// getSavedCard returns the card of the customer, the cards of the other customers aren't found
+func (h *RecurringRoute) getSavedCard(ctx echo.Context, customerId, cardId string) (*savedcard.Card, error) {
+	cards, err := h.getSavedCards(ctx, customerId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, card := range cards {
+		if card.Id == cardId {
+			return card, nil
+		}
+	}
+
+	return nil, echo.NewHTTPError(http.StatusNotFound, common.ErrorMessageSavedCardNotFound)
+}
+
+func (h *RecurringRoute) allowDeletion(ctx echo.Context, key string) error {
+	if ok, wait := h.deletions.Allow(key, time.Now()); !ok {
+		ctx.Response().Header().Set(common.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		return echo.NewHTTPError(http.StatusTooManyRequests, common.ErrorMessageSavedCardDeleteThrottled)
+	}
+
+	return nil
+}
+
+func (h *RecurringRoute) deleteBillingSavedCard(ctx echo.Context, req *grpc.DeleteSavedCardRequest) error {
 	res, err := h.dispatch.Services.Billing.DeleteSavedCard(ctx.Request().Context(), req)
 
 	if err != nil {
@@ -61,5 +297,27 @@ func (h *RecurringRoute) removeSavedCard(ctx echo.Context) error {
 		return echo.NewHTTPError(int(res.Status), res.Message)
 	}
 
-	return ctx.NoContent(http.StatusOK)
+	return nil
+}
+
+// recordSavedCardDeleted records the audit event of the deleted card, the card is already deleted
+// so the failed record is only logged
+func (h *RecurringRoute) recordSavedCardDeleted(ctx echo.Context, customerId, cardId string) {
+	event := h.newSavedCardEvent(ctx, savedcard.EventDeleted, cardId)
+
+	if err := h.preferences.RecordDeleted(ctx.Request().Context(), customerId, event); err != nil {
+		h.L().Error(
+			"saved card audit event record failed",
+			logger.PairArgs("err", err.Error(), "customer_id", customerId, "card_id", cardId),
+		)
+	}
+}
+
+func (h *RecurringRoute) newSavedCardEvent(ctx echo.Context, eventType, cardId string) *savedcard.Event {
+	return &savedcard.Event{
+		Type:      eventType,
+		CardId:    cardId,
+		Ip:        ctx.RealIP(),
+		UserAgent: ctx.Request().UserAgent(),
+	}
 }
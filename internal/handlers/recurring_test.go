@@ -1,26 +1,61 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"github.com/globalsign/mgo/bson"
 	"github.com/labstack/echo/v4"
+	"github.com/micro/go-micro/client"
+	awsWrapperMocks "github.com/paysuper/paysuper-aws-manager/pkg/mocks"
 	"github.com/paysuper/paysuper-billing-server/pkg"
 	billMock "github.com/paysuper/paysuper-billing-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
 	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/mock"
+	"github.com/paysuper/paysuper-management-api/internal/savedcard"
 	"github.com/paysuper/paysuper-management-api/internal/test"
+	"github.com/paysuper/paysuper-recurring-repository/pkg/proto/entity"
+	"github.com/paysuper/paysuper-recurring-repository/pkg/proto/repository"
 	"github.com/stretchr/testify/assert"
 	mock2 "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"net/http"
 	"testing"
 	"time"
 )
 
+const (
+	recurringTestCustomerId      = "5dc3f6c5ad8b8c0001b1e2e1"
+	recurringTestOtherCustomerId = "ffffffffffffffffffffffff"
+	recurringTestCardId          = "5dc3f6c5ad8b8c0001b1e2e2"
+	recurringTestCookiePrefix    = "customer_"
+)
+
 type RecurringTestSuite struct {
 	suite.Suite
-	router *RecurringRoute
-	caller *test.EchoReqResCaller
+	router      *RecurringRoute
+	caller      *test.EchoReqResCaller
+	preferences *savedcard.Store
+}
+
+type savedCardRepositoryMock struct {
+	repository.RepositoryService
+	cards []*entity.SavedCard
+}
+
+func (m *savedCardRepositoryMock) FindSavedCards(
+	ctx context.Context,
+	in *repository.SavedCardRequest,
+	opts ...client.CallOption,
+) (*repository.SavedCardList, error) {
+	if in.Token != recurringTestCustomerId {
+		return &repository.SavedCardList{}, nil
+	}
+
+	return &repository.SavedCardList{SavedCards: m.cards}, nil
 }
 
 func Test_Recurring(t *testing.T) {
@@ -34,11 +69,31 @@ func (suite *RecurringTestSuite) SetupTest() {
 	bs := &billMock.BillingService{}
 	bs.On("DeleteSavedCard", mock2.Anything, mock2.Anything, mock2.Anything).
 		Return(&grpc.EmptyResponseWithStatus{Status: pkg.ResponseStatusOk}, nil)
+
+	// the billing server resolves the cookies of the known customers only
+	for _, customerId := range []string{recurringTestCustomerId, recurringTestOtherCustomerId} {
+		bs.On("DeserializeCookie", mock2.Anything, &grpc.DeserializeCookieRequest{Cookie: recurringTestCookiePrefix + customerId}, mock2.Anything).
+			Return(&grpc.DeserializeCookieResponse{
+				Status: pkg.ResponseStatusOk,
+				Item:   &billing.BrowserCookieCustomer{CustomerId: customerId},
+			}, nil)
+	}
+
+	bs.On("DeserializeCookie", mock2.Anything, mock2.Anything, mock2.Anything).
+		Return(&grpc.DeserializeCookieResponse{Status: pkg.ResponseStatusBadData}, nil)
+	rep := &savedCardRepositoryMock{
+		cards: []*entity.SavedCard{
+			{Id: recurringTestCardId, MaskedPan: "400000******0002", Expire: &entity.CardExpire{Month: "12", Year: "2030"}},
+			{Id: "5dc3f6c5ad8b8c0001b1e2e3", MaskedPan: "555555******4444", Expire: &entity.CardExpire{Month: "01", Year: "2031"}},
+		},
+	}
 	srv := common.Services{
-		Billing: bs,
+		Billing:    bs,
+		Repository: rep,
 	}
 	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
-		suite.router = NewRecurringRoute(set.HandlerSet, set.GlobalConfig)
+		suite.preferences = savedcard.NewStore(mock.NewAwsManagerFilesMock(make(map[string][]byte)))
+		suite.router = NewRecurringRoute(set.HandlerSet, suite.preferences, set.GlobalConfig)
 		return common.Handlers{
 			suite.router,
 		}
@@ -171,3 +226,192 @@ func (suite *RecurringTestSuite) TestRecurring_RemoveSavedCard_BillingServerResu
 	assert.Equal(suite.T(), http.StatusNotFound, httpErr.Code)
 	assert.Equal(suite.T(), errMsg, httpErr.Message)
 }
+
+func (suite *RecurringTestSuite) customerCookie(customerId string) *http.Cookie {
+	return &http.Cookie{
+		Name:     common.CustomerTokenCookiesName,
+		Value:    recurringTestCookiePrefix + customerId,
+		Expires:  time.Now().Add(suite.router.cfg.CustomerTokenCookiesLifetime),
+		HttpOnly: true,
+	}
+}
+
+func (suite *RecurringTestSuite) listSavedCards(cookie *http.Cookie) []*savedcard.Card {
+	rsp, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Path(common.NoAuthGroupPath + savedCardsPath).
+		AddCookie(cookie).
+		Exec(suite.T())
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), http.StatusOK, rsp.Code)
+
+	res := &savedCardsResponse{}
+	require.NoError(suite.T(), json.Unmarshal(rsp.Body.Bytes(), res))
+
+	return res.Items
+}
+
+func (suite *RecurringTestSuite) deleteSavedCard(cookie *http.Cookie, cardId string) (*http.Response, error) {
+	rsp, err := suite.caller.Builder().
+		Method(http.MethodDelete).
+		Params(":"+common.RequestParameterId, cardId).
+		Path(common.NoAuthGroupPath + savedCardsIdPath).
+		AddCookie(cookie).
+		Exec(suite.T())
+
+	return rsp.Result(), err
+}
+
+func (suite *RecurringTestSuite) TestRecurring_ListSavedCards_Ok() {
+	cards := suite.listSavedCards(suite.customerCookie(recurringTestCustomerId))
+	require.Len(suite.T(), cards, 2)
+	assert.Equal(suite.T(), recurringTestCardId, cards[0].Id)
+	assert.Equal(suite.T(), "400000******0002", cards[0].MaskedPan)
+	assert.Equal(suite.T(), savedcard.BrandVisa, cards[0].Brand)
+	assert.Equal(suite.T(), &savedcard.Expire{Month: "12", Year: "2030"}, cards[0].Expire)
+	assert.False(suite.T(), cards[0].IsDefault)
+	assert.Equal(suite.T(), savedcard.BrandMasterCard, cards[1].Brand)
+
+	assert.Empty(suite.T(), suite.listSavedCards(suite.customerCookie(recurringTestOtherCustomerId)))
+}
+
+func (suite *RecurringTestSuite) TestRecurring_ListSavedCards_CustomerInvalid() {
+	cookie := &http.Cookie{Name: common.CustomerTokenCookiesName, Value: bson.NewObjectId().Hex()}
+
+	for _, cookie := range []*http.Cookie{cookie, {Name: "other", Value: "value"}} {
+		_, err := suite.caller.Builder().
+			Method(http.MethodGet).
+			Path(common.NoAuthGroupPath + savedCardsPath).
+			AddCookie(cookie).
+			Exec(suite.T())
+		require.Error(suite.T(), err)
+
+		httpErr, ok := err.(*echo.HTTPError)
+		require.True(suite.T(), ok)
+		assert.Equal(suite.T(), http.StatusUnauthorized, httpErr.Code)
+		assert.Equal(suite.T(), common.ErrorMessageSavedCardCustomerInvalid, httpErr.Message)
+	}
+}
+
+func (suite *RecurringTestSuite) setDefaultSavedCard(cookie *http.Cookie, cardId string) (*http.Response, error) {
+	rsp, err := suite.caller.Builder().
+		Method(http.MethodPut).
+		Params(":"+common.RequestParameterId, cardId).
+		Path(common.NoAuthGroupPath + savedCardsIdDefaultPath).
+		AddCookie(cookie).
+		Exec(suite.T())
+
+	return rsp.Result(), err
+}
+
+func (suite *RecurringTestSuite) TestRecurring_SetDefaultSavedCard_Ok() {
+	cookie := suite.customerCookie(recurringTestCustomerId)
+	rsp, err := suite.setDefaultSavedCard(cookie, recurringTestCardId)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, rsp.StatusCode)
+
+	cards := suite.listSavedCards(cookie)
+	assert.True(suite.T(), cards[0].IsDefault)
+	assert.False(suite.T(), cards[1].IsDefault)
+
+	preferences, err := suite.preferences.Get(context.Background(), recurringTestCustomerId)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), recurringTestCardId, preferences.DefaultCardId)
+	require.Len(suite.T(), preferences.Events, 1)
+	assert.Equal(suite.T(), savedcard.EventDefaultChanged, preferences.Events[0].Type)
+}
+
+func (suite *RecurringTestSuite) TestRecurring_SetDefaultSavedCard_NotFound() {
+	_, err := suite.setDefaultSavedCard(suite.customerCookie(recurringTestOtherCustomerId), recurringTestCardId)
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	assert.Equal(suite.T(), http.StatusNotFound, httpErr.Code)
+	assert.Equal(suite.T(), common.ErrorMessageSavedCardNotFound, httpErr.Message)
+}
+
+func (suite *RecurringTestSuite) TestRecurring_SetDefaultSavedCard_StorageError() {
+	awsManagerMock := &awsWrapperMocks.AwsManagerInterface{}
+	awsManagerMock.On("Download", mock2.Anything, mock2.Anything, mock2.Anything, mock2.Anything).
+		Return(int64(0), errors.New("some error"))
+	suite.router.preferences = savedcard.NewStore(awsManagerMock)
+
+	_, err := suite.setDefaultSavedCard(suite.customerCookie(recurringTestCustomerId), recurringTestCardId)
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	assert.Equal(suite.T(), http.StatusInternalServerError, httpErr.Code)
+	assert.Equal(suite.T(), common.ErrorMessageSavedCardStorageFailed, httpErr.Message)
+}
+
+func (suite *RecurringTestSuite) TestRecurring_DeleteSavedCard_Ok() {
+	rsp, err := suite.deleteSavedCard(suite.customerCookie(recurringTestCustomerId), recurringTestCardId)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusNoContent, rsp.StatusCode)
+
+	preferences, err := suite.preferences.Get(context.Background(), recurringTestCustomerId)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), preferences.Events, 1)
+	assert.Equal(suite.T(), savedcard.EventDeleted, preferences.Events[0].Type)
+	assert.Equal(suite.T(), recurringTestCardId, preferences.Events[0].CardId)
+}
+
+func (suite *RecurringTestSuite) TestRecurring_DeleteSavedCard_DefaultCleared() {
+	cookie := suite.customerCookie(recurringTestCustomerId)
+	_, err := suite.setDefaultSavedCard(cookie, recurringTestCardId)
+	require.NoError(suite.T(), err)
+
+	_, err = suite.deleteSavedCard(cookie, recurringTestCardId)
+	require.NoError(suite.T(), err)
+
+	preferences, err := suite.preferences.Get(context.Background(), recurringTestCustomerId)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), preferences.DefaultCardId)
+	require.Len(suite.T(), preferences.Events, 2)
+	assert.Equal(suite.T(), savedcard.EventDeleted, preferences.Events[1].Type)
+}
+
+func (suite *RecurringTestSuite) TestRecurring_DeleteSavedCard_NotFound() {
+	_, err := suite.deleteSavedCard(suite.customerCookie(recurringTestOtherCustomerId), recurringTestCardId)
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	assert.Equal(suite.T(), http.StatusNotFound, httpErr.Code)
+	assert.Equal(suite.T(), common.ErrorMessageSavedCardNotFound, httpErr.Message)
+}
+
+func (suite *RecurringTestSuite) TestRecurring_DeleteSavedCard_Throttled() {
+	cookie := suite.customerCookie(recurringTestCustomerId)
+	_, err := suite.deleteSavedCard(cookie, recurringTestCardId)
+	require.NoError(suite.T(), err)
+
+	rsp, err := suite.deleteSavedCard(cookie, recurringTestCardId)
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	assert.Equal(suite.T(), http.StatusTooManyRequests, httpErr.Code)
+	assert.Equal(suite.T(), common.ErrorMessageSavedCardDeleteThrottled, httpErr.Message)
+	assert.NotEmpty(suite.T(), rsp.Header.Get(common.HeaderRetryAfter))
+}
+
+func (suite *RecurringTestSuite) TestRecurring_ListSavedCards_BillingServerSystemError() {
+	bs := &billMock.BillingService{}
+	bs.On("DeserializeCookie", mock2.Anything, mock2.Anything, mock2.Anything).
+		Return(nil, errors.New("some error"))
+	suite.router.dispatch.Services.Billing = bs
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Path(common.NoAuthGroupPath + savedCardsPath).
+		AddCookie(suite.customerCookie(recurringTestCustomerId)).
+		Exec(suite.T())
+	require.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	assert.Equal(suite.T(), http.StatusInternalServerError, httpErr.Code)
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"github.com/ProtocolONE/go-core/v2/pkg/logger"
+	"github.com/ProtocolONE/go-core/v2/pkg/provider"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/pkg/signature"
+	"net/http"
+)
+
+const (
+	signatureDebugPath = "/signature/debug"
+)
+
+type SignatureRoute struct {
+	dispatch common.HandlerSet
+	cfg      common.Config
+	provider.LMT
+}
+
+type signatureDebugRequest struct {
+	Body      string `json:"body" validate:"required"`
+	SecretKey string `json:"secret_key" validate:"required"`
+	Signature string `json:"signature"`
+}
+
+func NewSignatureRoute(set common.HandlerSet, cfg *common.Config) *SignatureRoute {
+	set.AwareSet.Logger = set.AwareSet.Logger.WithFields(logger.Fields{"router": "SignatureRoute"})
+	return &SignatureRoute{
+		dispatch: set,
+		LMT:      &set.AwareSet,
+		cfg:      *cfg,
+	}
+}
+
+func (h *SignatureRoute) Route(groups *common.Groups) {
+	// debug endpoint is available in the sandbox only because it computes signatures for any secret key
+	if !h.cfg.SandboxMode {
+		return
+	}
+
+	groups.Common.POST(signatureDebugPath, h.debug)
+}
+
+// debug explains how the expected signature of the body is calculated
+// and hints the common mistakes giving the provided signature
+func (h *SignatureRoute) debug(ctx echo.Context) error {
+	req := &signatureDebugRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, signature.Explain([]byte(req.Body), req.SecretKey, req.Signature))
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/mock"
+	"github.com/paysuper/paysuper-management-api/internal/test"
+	"github.com/paysuper/paysuper-management-api/pkg/signature"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"testing"
+)
+
+type SignatureTestSuite struct {
+	suite.Suite
+	router *SignatureRoute
+	caller *test.EchoReqResCaller
+}
+
+func Test_Signature(t *testing.T) {
+	suite.Run(t, new(SignatureTestSuite))
+}
+
+func (suite *SignatureTestSuite) SetupTest() {
+	var e error
+	settings := test.DefaultSettings()
+	srv := common.Services{
+		Billing: mock.NewBillingServerOkMock(),
+	}
+	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
+		suite.router = NewSignatureRoute(set.HandlerSet, set.GlobalConfig)
+		return common.Handlers{
+			suite.router,
+		}
+	})
+	if e != nil {
+		panic(e)
+	}
+}
+
+func (suite *SignatureTestSuite) TearDownTest() {}
+
+func (suite *SignatureTestSuite) TestSignature_Debug_Ok() {
+	body := `{"amount":10,"currency":"USD"}`
+	req := &signatureDebugRequest{
+		Body:      body,
+		SecretKey: "project_secret_key",
+		Signature: signature.Sign([]byte(body), "project_secret_key"),
+	}
+	b, err := json.Marshal(req)
+	assert.NoError(suite.T(), err)
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.NoAuthGroupPath + signatureDebugPath).
+		Init(test.ReqInitJSON()).
+		BodyBytes(b).
+		Exec(suite.T())
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, res.Code)
+
+	explanation := &signature.Explanation{}
+	err = json.Unmarshal(res.Body.Bytes(), explanation)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), explanation.Match)
+	assert.Equal(suite.T(), req.Signature, explanation.Expected)
+	assert.Equal(suite.T(), signature.Header, explanation.Header)
+	assert.Equal(suite.T(), "pr***ey", explanation.SecretKey)
+	assert.Empty(suite.T(), explanation.Hints)
+}
+
+func (suite *SignatureTestSuite) TestSignature_Debug_Mismatch() {
+	body := "{\"amount\": 10}\n"
+	req := &signatureDebugRequest{
+		Body:      body,
+		SecretKey: "project_secret_key",
+		Signature: signature.Sign([]byte(`{"amount": 10}`), "project_secret_key"),
+	}
+	b, err := json.Marshal(req)
+	assert.NoError(suite.T(), err)
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.NoAuthGroupPath + signatureDebugPath).
+		Init(test.ReqInitJSON()).
+		BodyBytes(b).
+		Exec(suite.T())
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, res.Code)
+
+	explanation := &signature.Explanation{}
+	err = json.Unmarshal(res.Body.Bytes(), explanation)
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), explanation.Match)
+	assert.Equal(suite.T(), signature.Sign([]byte(body), "project_secret_key"), explanation.Expected)
+	assert.Equal(suite.T(), []string{signature.HintBodyWhitespace}, explanation.Hints)
+}
+
+func (suite *SignatureTestSuite) TestSignature_Debug_ValidationError() {
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.NoAuthGroupPath + signatureDebugPath).
+		Init(test.ReqInitJSON()).
+		BodyString(`{"body": "{}"}`).
+		Exec(suite.T())
+
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusBadRequest, res.Code)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	assert.True(suite.T(), ok)
+
+	msg, ok := httpErr.Message.(*grpc.ResponseErrorMessage)
+	assert.True(suite.T(), ok)
+	assert.Regexp(suite.T(), "SecretKey", msg.Details)
+}
+
+func (suite *SignatureTestSuite) TestSignature_Debug_SandboxModeDisabled() {
+	settings := test.DefaultSettings()
+	settings["sandboxMode"] = false
+	srv := common.Services{
+		Billing: mock.NewBillingServerOkMock(),
+	}
+	caller, err := test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
+		return common.Handlers{
+			NewSignatureRoute(set.HandlerSet, set.GlobalConfig),
+		}
+	})
+	assert.NoError(suite.T(), err)
+
+	res, err := caller.Builder().
+		Method(http.MethodPost).
+		Path(common.NoAuthGroupPath + signatureDebugPath).
+		Init(test.ReqInitJSON()).
+		BodyString(`{"body": "{}", "secret_key": "key"}`).
+		Exec(suite.T())
+
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusNotFound, res.Code)
+}
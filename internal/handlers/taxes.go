@@ -5,28 +5,40 @@ import (
 	"github.com/ProtocolONE/go-core/v2/pkg/provider"
 	"github.com/labstack/echo/v4"
 	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/taxrates"
 	"github.com/paysuper/paysuper-tax-service/proto"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 const (
-	taxesPath   = "/taxes"
-	taxesIDPath = "/taxes/:id"
+	taxesPath            = "/taxes"
+	taxesIDPath          = "/taxes/:id"
+	taxesImportPath      = "/taxes/import"
+	taxesExportPath      = "/taxes/export"
+	taxesScheduledPath   = "/taxes/scheduled"
+	taxesScheduledIdPath = "/taxes/scheduled/:change_id"
+
+	taxCountryRatesCacheTtl = 5 * time.Minute
 )
 
 type TaxesRoute struct {
 	dispatch common.HandlerSet
 	cfg      common.Config
 	provider.LMT
+	schedule     *taxrates.Schedule
+	countryRates *taxrates.CountryCache
 }
 
-func NewTaxesRoute(set common.HandlerSet, cfg *common.Config) *TaxesRoute {
+func NewTaxesRoute(set common.HandlerSet, schedule *taxrates.Schedule, cfg *common.Config) *TaxesRoute {
 	set.AwareSet.Logger = set.AwareSet.Logger.WithFields(logger.Fields{"router": "TaxesRoute"})
 	return &TaxesRoute{
-		dispatch: set,
-		LMT:      &set.AwareSet,
-		cfg:      *cfg,
+		dispatch:     set,
+		LMT:          &set.AwareSet,
+		cfg:          *cfg,
+		schedule:     schedule,
+		countryRates: taxrates.NewCountryCache(set.Services.Tax, taxCountryRatesCacheTtl, cfg.LimitMax),
 	}
 }
 
@@ -34,6 +46,12 @@ func (h *TaxesRoute) Route(groups *common.Groups) {
 	groups.SystemUser.GET(taxesPath, h.getTaxes)
 	groups.SystemUser.POST(taxesPath, h.setTax)
 	groups.SystemUser.DELETE(taxesIDPath, h.deleteTax)
+	groups.SystemUser.POST(taxesImportPath, h.importTaxes)
+	groups.SystemUser.GET(taxesExportPath, h.exportTaxes)
+	groups.SystemUser.GET(taxesScheduledPath, h.listScheduledTaxes)
+	groups.SystemUser.DELETE(taxesScheduledIdPath, h.cancelScheduledTax)
+
+	groups.Common.GET(taxesCalculatePath, h.calculateTax)
 }
 
 func (h *TaxesRoute) getTaxes(ctx echo.Context) error {
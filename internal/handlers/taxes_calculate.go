@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"github.com/ProtocolONE/go-core/v2/pkg/logger"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/taxrates"
+	"github.com/paysuper/paysuper-tax-service/proto"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	taxesCalculatePath = "/taxes/calculate"
+
+	taxLevelCountry = "country"
+	taxLevelState   = "state"
+	taxLevelCity    = "city"
+	taxLevelZip     = "zip"
+
+	taxAmountDecimalsDefault = 2
+)
+
+var (
+	// taxAmountDecimals are the ISO 4217 minor units of the currencies which don't have two decimals
+	taxAmountDecimals = map[string]int{
+		"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0, "PYG": 0,
+		"RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+		"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	}
+	taxLevelsSpecificity = map[string]int{
+		taxLevelCountry: 0,
+		taxLevelState:   1,
+		taxLevelCity:    2,
+		taxLevelZip:     3,
+	}
+)
+
+type taxCalculateRequest struct {
+	Amount    float64 `query:"amount" validate:"required,gt=0"`
+	Currency  string  `query:"currency" validate:"required,len=3"`
+	Country   string  `query:"country" validate:"required,len=2,alpha"`
+	State     string  `query:"state" validate:"omitempty,max=3"`
+	Zip       string  `query:"zip" validate:"omitempty,max=10"`
+	Inclusive bool    `query:"inclusive"`
+}
+
+// TaxJurisdiction is the address the tax is calculated for after the zip code resolution
+type TaxJurisdiction struct {
+	Country     string `json:"country"`
+	State       string `json:"state,omitempty"`
+	City        string `json:"city,omitempty"`
+	Zip         string `json:"zip,omitempty"`
+	ZipResolved bool   `json:"zip_resolved"`
+}
+
+// TaxBreakdownItem is the tax rate of the single jurisdiction level matching the address
+type TaxBreakdownItem struct {
+	Level   string  `json:"level"`
+	Country string  `json:"country"`
+	State   string  `json:"state,omitempty"`
+	City    string  `json:"city,omitempty"`
+	Zip     string  `json:"zip,omitempty"`
+	Rate    float64 `json:"rate"`
+	Applied bool    `json:"applied"`
+}
+
+// TaxCalculation is the preview of the tax for the order amount.
+// The rate of the most specific jurisdiction matching the address is applied.
+type TaxCalculation struct {
+	Amount       float64             `json:"amount"`
+	Currency     string              `json:"currency"`
+	Inclusive    bool                `json:"inclusive"`
+	Rate         float64             `json:"rate"`
+	NetAmount    float64             `json:"net_amount"`
+	TaxAmount    float64             `json:"tax_amount"`
+	TotalAmount  float64             `json:"total_amount"`
+	Jurisdiction *TaxJurisdiction    `json:"jurisdiction"`
+	Breakdown    []*TaxBreakdownItem `json:"breakdown"`
+}
+
+// calculateTax returns the tax which would apply to the order with the amount for the address without creating the order.
+// With inclusive the amount is treated as the price already including the tax.
+func (h *TaxesRoute) calculateTax(ctx echo.Context) error {
+	req := &taxCalculateRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	jurisdiction, err := h.resolveTaxJurisdiction(ctx, req)
+
+	if err != nil {
+		return err
+	}
+
+	rates, err := h.listJurisdictionRates(ctx, jurisdiction)
+
+	if err != nil {
+		h.L().Error(common.InternalErrorTemplate, logger.WithFields(logger.Fields{"err": err.Error()}))
+		return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorInternal)
+	}
+
+	res := &TaxCalculation{
+		Amount:       req.Amount,
+		Currency:     strings.ToUpper(req.Currency),
+		Inclusive:    req.Inclusive,
+		Jurisdiction: jurisdiction,
+		Breakdown:    getTaxBreakdown(jurisdiction, rates),
+	}
+
+	if len(res.Breakdown) > 0 {
+		applied := res.Breakdown[len(res.Breakdown)-1]
+		applied.Applied = true
+		res.Rate = applied.Rate
+	}
+
+	if req.Inclusive {
+		res.TotalAmount = req.Amount
+		res.NetAmount = roundTaxAmount(req.Amount/(1+res.Rate), res.Currency)
+		res.TaxAmount = roundTaxAmount(res.TotalAmount-res.NetAmount, res.Currency)
+	} else {
+		res.NetAmount = req.Amount
+		res.TaxAmount = roundTaxAmount(req.Amount*res.Rate, res.Currency)
+		res.TotalAmount = roundTaxAmount(res.NetAmount+res.TaxAmount, res.Currency)
+	}
+
+	return ctx.JSON(http.StatusOK, res)
+}
+
+// resolveTaxJurisdiction completes the state and the city of the address by the zip code
+func (h *TaxesRoute) resolveTaxJurisdiction(ctx echo.Context, req *taxCalculateRequest) (*TaxJurisdiction, error) {
+	jurisdiction := &TaxJurisdiction{
+		Country: strings.ToUpper(req.Country),
+		State:   strings.ToUpper(req.State),
+		Zip:     req.Zip,
+	}
+
+	if jurisdiction.Zip == "" {
+		return jurisdiction, nil
+	}
+
+	in := &grpc.FindByZipCodeRequest{Country: jurisdiction.Country, Zip: jurisdiction.Zip, Limit: int64(h.cfg.LimitDefault)}
+	res, err := h.dispatch.Services.Billing.FindByZipCode(ctx.Request().Context(), in)
+
+	if err != nil {
+		return nil, h.dispatch.SrvCallHandler(in, err, pkg.ServiceName, "FindByZipCode")
+	}
+
+	for _, item := range res.Items {
+		if item.Zip != jurisdiction.Zip {
+			continue
+		}
+
+		if jurisdiction.State == "" {
+			jurisdiction.State = item.GetState().GetCode()
+		}
+
+		jurisdiction.City = item.City
+		jurisdiction.ZipResolved = true
+		break
+	}
+
+	return jurisdiction, nil
+}
+
+// listJurisdictionRates returns the rates of the most specific filter known for the address merged with the rates
+// of the whole country, so the breakdown has every level of the address. The broader filter is used
+// when the specific one finds nothing, the rates of the whole country are cached.
+func (h *TaxesRoute) listJurisdictionRates(ctx echo.Context, jurisdiction *TaxJurisdiction) ([]*tax_service.TaxRate, error) {
+	filters := make([]*tax_service.GetRatesRequest, 0)
+
+	if jurisdiction.Zip != "" {
+		filters = append(filters, &tax_service.GetRatesRequest{Country: jurisdiction.Country, Zip: jurisdiction.Zip})
+	}
+
+	if jurisdiction.State != "" {
+		filters = append(filters, &tax_service.GetRatesRequest{Country: jurisdiction.Country, State: jurisdiction.State})
+	}
+
+	rates := make([]*tax_service.TaxRate, 0)
+
+	for _, filter := range filters {
+		filtered, err := taxrates.List(ctx.Request().Context(), h.dispatch.Services.Tax, filter, h.cfg.LimitMax)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(getTaxBreakdown(jurisdiction, filtered)) > 0 {
+			rates = filtered
+			break
+		}
+	}
+
+	countryRates, err := h.countryRates.List(ctx.Request().Context(), jurisdiction.Country)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the rates listed by the filter are newer than the cached ones of the same jurisdiction
+	listed := make(map[string]bool, len(rates))
+
+	for _, rate := range rates {
+		listed[taxrates.RateJurisdiction(rate)] = true
+	}
+
+	for _, rate := range countryRates {
+		if !listed[taxrates.RateJurisdiction(rate)] {
+			rates = append(rates, rate)
+		}
+	}
+
+	return rates, nil
+}
+
+// getTaxBreakdown returns the rates matching the address ordered from the broadest to the most specific level
+func getTaxBreakdown(jurisdiction *TaxJurisdiction, rates []*tax_service.TaxRate) []*TaxBreakdownItem {
+	breakdown := make([]*TaxBreakdownItem, 0)
+
+	for _, rate := range rates {
+		if rate.Country != jurisdiction.Country ||
+			(rate.State != "" && !strings.EqualFold(rate.State, jurisdiction.State)) ||
+			(rate.City != "" && !strings.EqualFold(rate.City, jurisdiction.City)) ||
+			(rate.Zip != "" && rate.Zip != jurisdiction.Zip) {
+			continue
+		}
+
+		item := &TaxBreakdownItem{
+			Level:   taxLevelCountry,
+			Country: rate.Country,
+			State:   rate.State,
+			City:    rate.City,
+			Zip:     rate.Zip,
+			Rate:    rate.Rate,
+		}
+
+		switch {
+		case rate.Zip != "":
+			item.Level = taxLevelZip
+		case rate.City != "":
+			item.Level = taxLevelCity
+		case rate.State != "":
+			item.Level = taxLevelState
+		}
+
+		breakdown = append(breakdown, item)
+	}
+
+	sort.SliceStable(breakdown, func(i, j int) bool {
+		return taxLevelsSpecificity[breakdown[i].Level] < taxLevelsSpecificity[breakdown[j].Level]
+	})
+
+	return breakdown
+}
+
+// roundTaxAmount rounds the amount to the minor units of the currency
+func roundTaxAmount(amount float64, currency string) float64 {
+	decimals, ok := taxAmountDecimals[currency]
+
+	if !ok {
+		decimals = taxAmountDecimalsDefault
+	}
+
+	precision := math.Pow10(decimals)
+
+	return math.Round(amount*precision) / precision
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/mock"
+	"github.com/paysuper/paysuper-management-api/internal/taxrates"
+	"github.com/paysuper/paysuper-management-api/internal/test"
+	"github.com/paysuper/paysuper-tax-service/proto"
+	mock2 "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+type TaxesCalculateTestSuite struct {
+	suite.Suite
+	router *TaxesRoute
+	caller *test.EchoReqResCaller
+	tax    *taxRatesStoreMock
+}
+
+func Test_TaxesCalculate(t *testing.T) {
+	suite.Run(t, new(TaxesCalculateTestSuite))
+}
+
+func (suite *TaxesCalculateTestSuite) SetupTest() {
+	suite.tax = &taxRatesStoreMock{
+		rates: []*tax_service.TaxRate{
+			{Id: 1, Country: "US", State: "NY", Rate: 0.04},
+			{Id: 2, Country: "US", State: "NY", City: "New York", Zip: "10001", Rate: 0.08875},
+			{Id: 3, Country: "US", State: "CA", Zip: "90001", Rate: 0.095},
+			{Id: 4, Country: "DE", Rate: 0.19},
+		},
+	}
+	billingService := &mocks.BillingService{}
+	billingService.On("FindByZipCode", mock2.Anything, mock2.MatchedBy(func(req *grpc.FindByZipCodeRequest) bool {
+		return req.Zip == "10001"
+	})).Return(&grpc.FindByZipCodeResponse{
+		Count: 1,
+		Items: []*billing.ZipCode{
+			{
+				Zip:     "10001",
+				Country: "US",
+				City:    "New York",
+				State:   &billing.ZipCodeState{Code: "NY", Name: "New York"},
+			},
+		},
+	}, nil)
+	billingService.On("FindByZipCode", mock2.Anything, mock2.MatchedBy(func(req *grpc.FindByZipCodeRequest) bool {
+		return req.Zip == "00000"
+	})).Return(nil, errors.New("some error"))
+	billingService.On("FindByZipCode", mock2.Anything, mock2.Anything).
+		Return(&grpc.FindByZipCodeResponse{}, nil)
+
+	var e error
+	settings := test.DefaultSettings()
+	srv := common.Services{
+		Billing: billingService,
+		Tax:     suite.tax,
+	}
+	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
+		schedule := taxrates.NewSchedule(mock.NewAwsManagerFilesMock(make(map[string][]byte)), suite.tax)
+		suite.router = NewTaxesRoute(set.HandlerSet, schedule, set.GlobalConfig)
+		return common.Handlers{
+			suite.router,
+		}
+	})
+	if e != nil {
+		panic(e)
+	}
+}
+
+func (suite *TaxesCalculateTestSuite) TearDownTest() {}
+
+func (suite *TaxesCalculateTestSuite) calculate(params map[string]string) (*TaxCalculation, error) {
+	q := make(url.Values)
+
+	for key, value := range params {
+		q.Set(key, value)
+	}
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Path(common.NoAuthGroupPath + taxesCalculatePath).
+		SetQueryParams(q).
+		Exec(suite.T())
+
+	if err != nil {
+		return nil, err
+	}
+
+	require.Equal(suite.T(), http.StatusOK, res.Code)
+
+	result := &TaxCalculation{}
+	require.NoError(suite.T(), json.Unmarshal(res.Body.Bytes(), result))
+
+	return result, nil
+}
+
+func (suite *TaxesCalculateTestSuite) TestTaxesCalculate_ZipResolved_Ok() {
+	shouldBe := require.New(suite.T())
+
+	result, err := suite.calculate(map[string]string{"amount": "40", "currency": "usd", "country": "us", "zip": "10001"})
+	shouldBe.NoError(err)
+	shouldBe.Equal("USD", result.Currency)
+	shouldBe.True(result.Jurisdiction.ZipResolved)
+	shouldBe.Equal("NY", result.Jurisdiction.State)
+	shouldBe.Equal("New York", result.Jurisdiction.City)
+
+	// the state rate comes from the cached country rates
+	shouldBe.Len(result.Breakdown, 2)
+	shouldBe.Equal(taxLevelState, result.Breakdown[0].Level)
+	shouldBe.False(result.Breakdown[0].Applied)
+	shouldBe.Equal(taxLevelZip, result.Breakdown[1].Level)
+	shouldBe.True(result.Breakdown[1].Applied)
+	shouldBe.EqualValues(0.08875, result.Rate)
+	shouldBe.EqualValues(40, result.NetAmount)
+	shouldBe.EqualValues(3.55, result.TaxAmount)
+	shouldBe.EqualValues(43.55, result.TotalAmount)
+}
+
+func (suite *TaxesCalculateTestSuite) TestTaxesCalculate_StateFallback_Ok() {
+	shouldBe := require.New(suite.T())
+
+	result, err := suite.calculate(map[string]string{"amount": "50", "currency": "USD", "country": "US", "state": "NY"})
+	shouldBe.NoError(err)
+	shouldBe.False(result.Jurisdiction.ZipResolved)
+	shouldBe.Len(result.Breakdown, 1)
+	shouldBe.Equal(taxLevelState, result.Breakdown[0].Level)
+	shouldBe.EqualValues(0.04, result.Rate)
+	shouldBe.EqualValues(2, result.TaxAmount)
+	shouldBe.EqualValues(52, result.TotalAmount)
+}
+
+func (suite *TaxesCalculateTestSuite) TestTaxesCalculate_Inclusive_Ok() {
+	shouldBe := require.New(suite.T())
+
+	result, err := suite.calculate(map[string]string{"amount": "119", "currency": "EUR", "country": "DE", "inclusive": "true"})
+	shouldBe.NoError(err)
+	shouldBe.True(result.Inclusive)
+	shouldBe.Equal(taxLevelCountry, result.Breakdown[0].Level)
+	shouldBe.EqualValues(0.19, result.Rate)
+	shouldBe.EqualValues(100, result.NetAmount)
+	shouldBe.EqualValues(19, result.TaxAmount)
+	shouldBe.EqualValues(119, result.TotalAmount)
+}
+
+func (suite *TaxesCalculateTestSuite) TestTaxesCalculate_ZeroDecimalsCurrency_Ok() {
+	shouldBe := require.New(suite.T())
+
+	result, err := suite.calculate(map[string]string{"amount": "999", "currency": "jpy", "country": "DE"})
+	shouldBe.NoError(err)
+	shouldBe.EqualValues(190, result.TaxAmount)
+	shouldBe.EqualValues(1189, result.TotalAmount)
+
+	result, err = suite.calculate(map[string]string{"amount": "9.999", "currency": "KWD", "country": "DE"})
+	shouldBe.NoError(err)
+	shouldBe.EqualValues(1.9, result.TaxAmount)
+	shouldBe.EqualValues(11.899, result.TotalAmount)
+}
+
+func (suite *TaxesCalculateTestSuite) TestTaxesCalculate_CountryRatesCached_Ok() {
+	shouldBe := require.New(suite.T())
+
+	for _, zip := range []string{"10115", "80331"} {
+		result, err := suite.calculate(map[string]string{"amount": "100", "currency": "EUR", "country": "DE", "zip": zip})
+		shouldBe.NoError(err)
+		shouldBe.EqualValues(0.19, result.Rate)
+	}
+
+	countryRequests := 0
+
+	for _, req := range suite.tax.requests {
+		if req.Zip == "" && req.State == "" {
+			countryRequests++
+		}
+	}
+
+	// the unknown zip codes fall back to the country rates listed once
+	shouldBe.Equal(1, countryRequests)
+}
+
+func (suite *TaxesCalculateTestSuite) TestTaxesCalculate_NoRate_Ok() {
+	shouldBe := require.New(suite.T())
+
+	result, err := suite.calculate(map[string]string{"amount": "10", "currency": "GBP", "country": "GB", "zip": "SW1A"})
+	shouldBe.NoError(err)
+	shouldBe.False(result.Jurisdiction.ZipResolved)
+	shouldBe.Empty(result.Breakdown)
+	shouldBe.EqualValues(0, result.Rate)
+	shouldBe.EqualValues(0, result.TaxAmount)
+	shouldBe.EqualValues(10, result.TotalAmount)
+}
+
+func (suite *TaxesCalculateTestSuite) TestTaxesCalculate_ValidationError() {
+	_, err := suite.calculate(map[string]string{"amount": "-1", "currency": "USD", "country": "US"})
+
+	shouldBe := require.New(suite.T())
+	shouldBe.Error(err)
+	httpErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusBadRequest, httpErr.Code)
+}
+
+func (suite *TaxesCalculateTestSuite) TestTaxesCalculate_BillingServer_Error() {
+	_, err := suite.calculate(map[string]string{"amount": "10", "currency": "USD", "country": "US", "zip": "00000"})
+
+	shouldBe := require.New(suite.T())
+	shouldBe.Error(err)
+	httpErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(http.StatusInternalServerError, httpErr.Code)
+	shouldBe.Equal(common.ErrorInternal, httpErr.Message)
+}
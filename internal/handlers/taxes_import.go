@@ -0,0 +1,600 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"github.com/ProtocolONE/go-core/v2/pkg/logger"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/taxrates"
+	"github.com/paysuper/paysuper-tax-service/proto"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	taxesFileName        = "taxes.csv"
+	taxesCountryFileName = "taxes_%s.csv"
+	taxesMimeCsv         = "text/csv"
+	taxesDateLayout      = "2006-01-02"
+
+	taxImportRowsMax         = 50000
+	taxImportConcurrency     = 20
+	taxImportActionCreate    = "create"
+	taxImportActionUpdate    = "update"
+	taxImportActionUnchanged = "unchanged"
+	taxImportActionSchedule  = "schedule"
+	taxImportActionInvalid   = "invalid"
+
+	taxCsvColumnCountry       = "country"
+	taxCsvColumnState         = "state"
+	taxCsvColumnCity          = "city"
+	taxCsvColumnZip           = "zip"
+	taxCsvColumnRate          = "rate"
+	taxCsvColumnEffectiveFrom = "effective_from"
+)
+
+var (
+	taxCsvColumns = []string{
+		taxCsvColumnCountry,
+		taxCsvColumnState,
+		taxCsvColumnCity,
+		taxCsvColumnZip,
+		taxCsvColumnRate,
+		taxCsvColumnEffectiveFrom,
+	}
+	taxCountryRegexp = regexp.MustCompile("^[A-Z]{2}$")
+)
+
+type taxesImportRequest struct {
+	DryRun bool `form:"dry_run"`
+}
+
+type taxesExportRequest struct {
+	Country string `query:"country" validate:"omitempty,len=2"`
+}
+
+// taxImportLine is the raw row of the tax rates import file
+type taxImportLine struct {
+	Country       string
+	State         string
+	City          string
+	Zip           string
+	Rate          string
+	EffectiveFrom string
+}
+
+// TaxRateImportRow is the result of the import for the single row of the import file
+type TaxRateImportRow struct {
+	Row           int                        `json:"row"`
+	Country       string                     `json:"country"`
+	State         string                     `json:"state"`
+	City          string                     `json:"city"`
+	Zip           string                     `json:"zip"`
+	Rate          float64                    `json:"rate"`
+	OldRate       *float64                   `json:"old_rate,omitempty"`
+	EffectiveFrom *time.Time                 `json:"effective_from,omitempty"`
+	Action        string                     `json:"action"`
+	Message       *grpc.ResponseErrorMessage `json:"message,omitempty"`
+
+	current *tax_service.TaxRate
+}
+
+// TaxRateImportResult
+type TaxRateImportResult struct {
+	DryRun    bool                `json:"dry_run"`
+	Total     int                 `json:"total"`
+	Created   int                 `json:"created"`
+	Updated   int                 `json:"updated"`
+	Unchanged int                 `json:"unchanged"`
+	Scheduled int                 `json:"scheduled"`
+	Failed    int                 `json:"failed"`
+	Rows      []*TaxRateImportRow `json:"rows"`
+}
+
+func (r *TaxRateImportResult) count() {
+	r.Created, r.Updated, r.Unchanged, r.Scheduled, r.Failed = 0, 0, 0, 0, 0
+
+	for _, row := range r.Rows {
+		switch row.Action {
+		case taxImportActionCreate:
+			r.Created++
+		case taxImportActionUpdate:
+			r.Updated++
+		case taxImportActionUnchanged:
+			r.Unchanged++
+		case taxImportActionSchedule:
+			r.Scheduled++
+		default:
+			r.Failed++
+		}
+	}
+}
+
+// importTaxes creates or updates the tax rates of the jurisdictions from the CSV file.
+// The rows with the future effective date are scheduled and applied automatically when the date comes,
+// the rows without the date or with the past date are applied at once.
+// The invalid rows are skipped, with dry_run nothing is saved and only the changes are returned.
+func (h *TaxesRoute) importTaxes(ctx echo.Context) error {
+	req := &taxesImportRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	lines, err := readTaxesImportFile(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	result := &TaxRateImportResult{
+		DryRun: req.DryRun,
+		Total:  len(lines),
+		Rows:   make([]*TaxRateImportRow, 0, len(lines)),
+	}
+
+	for i, line := range lines {
+		result.Rows = append(result.Rows, parseTaxImportLine(i+1, line, now))
+	}
+
+	current, err := h.listImportedCountriesRates(ctx, result.Rows)
+
+	if err != nil {
+		return err
+	}
+
+	changes := make([]*taxrates.Change, 0)
+	imported := make(map[string]bool, len(result.Rows))
+	authUser := common.ExtractUserContext(ctx)
+
+	for _, row := range result.Rows {
+		if row.Action == taxImportActionInvalid {
+			continue
+		}
+
+		key := taxrates.Jurisdiction(row.Country, row.State, row.City, row.Zip)
+
+		// the rates effective at once are keyed by the jurisdiction only, so the jurisdiction can't get two of them
+		if row.EffectiveFrom != nil {
+			key += "@" + row.EffectiveFrom.Format(time.RFC3339)
+		}
+
+		if imported[key] {
+			row.Action = taxImportActionInvalid
+			row.Message = common.ErrorMessageTaxesImportRowDuplicated
+			continue
+		}
+
+		imported[key] = true
+		row.current = current[taxrates.Jurisdiction(row.Country, row.State, row.City, row.Zip)]
+
+		if row.current != nil {
+			oldRate := row.current.Rate
+			row.OldRate = &oldRate
+		}
+
+		switch {
+		case row.EffectiveFrom != nil:
+			row.Action = taxImportActionSchedule
+			changes = append(changes, &taxrates.Change{
+				Country:       row.Country,
+				State:         row.State,
+				City:          row.City,
+				Zip:           row.Zip,
+				Rate:          row.Rate,
+				EffectiveFrom: *row.EffectiveFrom,
+				CreatedBy:     authUser.Id,
+			})
+		case row.current == nil:
+			row.Action = taxImportActionCreate
+		case row.current.Rate != row.Rate:
+			row.Action = taxImportActionUpdate
+		default:
+			row.Action = taxImportActionUnchanged
+		}
+	}
+
+	if req.DryRun {
+		result.count()
+		return ctx.JSON(http.StatusOK, result)
+	}
+
+	if len(changes) > 0 {
+		if err = h.schedule.Add(ctx.Request().Context(), changes); err != nil {
+			h.L().Error("tax rate schedule save failed", logger.PairArgs("err", err.Error()))
+			return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessageTaxRateScheduleStorageFailed)
+		}
+	}
+
+	h.saveImportedRates(ctx.Request().Context(), result.Rows)
+	result.count()
+
+	return ctx.JSON(http.StatusOK, result)
+}
+
+// saveImportedRates saves the created and updated rates to the tax service.
+// The tax service has no batch method, so at most taxImportConcurrency rates are saved at once.
+// The failed rate doesn't stop the import and is reported in its row.
+func (h *TaxesRoute) saveImportedRates(ctx context.Context, rows []*TaxRateImportRow) {
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, taxImportConcurrency)
+
+	for _, row := range rows {
+		if row.Action != taxImportActionCreate && row.Action != taxImportActionUpdate {
+			continue
+		}
+
+		slots <- struct{}{}
+		wg.Add(1)
+
+		go func(row *TaxRateImportRow) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			rate := &tax_service.TaxRate{Country: row.Country, State: row.State, City: row.City, Zip: row.Zip}
+
+			if row.current != nil {
+				rate = row.current
+			}
+
+			rate.Rate = row.Rate
+
+			if _, err := h.dispatch.Services.Tax.CreateOrUpdate(ctx, rate); err != nil {
+				h.L().Error(common.InternalErrorTemplate, logger.WithFields(logger.Fields{"err": err.Error()}))
+				row.Action = taxImportActionInvalid
+				row.Message = common.ErrorInternal
+			}
+		}(row)
+	}
+
+	wg.Wait()
+}
+
+// exportTaxes returns the current tax rates and the scheduled changes in the format accepted by the import
+func (h *TaxesRoute) exportTaxes(ctx echo.Context) error {
+	req := &taxesExportRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	req.Country = strings.ToUpper(req.Country)
+	rates, err := taxrates.List(
+		ctx.Request().Context(),
+		h.dispatch.Services.Tax,
+		&tax_service.GetRatesRequest{Country: req.Country},
+		h.cfg.LimitMax,
+	)
+
+	if err != nil {
+		h.L().Error(common.InternalErrorTemplate, logger.WithFields(logger.Fields{"err": err.Error()}))
+		return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorInternal)
+	}
+
+	changes, err := h.listScheduledChanges(ctx, req.Country)
+
+	if err != nil {
+		return err
+	}
+
+	data, err := writeTaxesCsv(rates, changes)
+
+	if err != nil {
+		h.L().Error("tax rates csv export failed", logger.PairArgs("err", err.Error()))
+		return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorUnknown)
+	}
+
+	fileName := taxesFileName
+
+	if req.Country != "" {
+		fileName = fmt.Sprintf(taxesCountryFileName, req.Country)
+	}
+
+	ctx.Response().Header().Set(echo.HeaderContentDisposition, "attachment; filename="+fileName)
+
+	return ctx.Blob(http.StatusOK, taxesMimeCsv, data)
+}
+
+// listScheduledTaxes returns the pending tax rate changes ordered by the effective date
+func (h *TaxesRoute) listScheduledTaxes(ctx echo.Context) error {
+	req := &taxesExportRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	changes, err := h.listScheduledChanges(ctx, strings.ToUpper(req.Country))
+
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, changes)
+}
+
+// cancelScheduledTax removes the pending tax rate change before it takes effect
+func (h *TaxesRoute) cancelScheduledTax(ctx echo.Context) error {
+	change, err := h.schedule.Cancel(ctx.Request().Context(), ctx.Param(common.RequestParameterChangeId))
+
+	if err == taxrates.ErrorChangeNotFound {
+		return echo.NewHTTPError(http.StatusNotFound, common.ErrorMessageTaxRateChangeNotFound)
+	}
+
+	if err != nil {
+		h.L().Error("tax rate schedule cancel failed", logger.PairArgs("err", err.Error()))
+		return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessageTaxRateScheduleStorageFailed)
+	}
+
+	return ctx.JSON(http.StatusOK, change)
+}
+
+func (h *TaxesRoute) listScheduledChanges(ctx echo.Context, country string) ([]*taxrates.Change, error) {
+	changes, err := h.schedule.List(ctx.Request().Context())
+
+	if err != nil {
+		h.L().Error("tax rate schedule load failed", logger.PairArgs("err", err.Error()))
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessageTaxRateScheduleStorageFailed)
+	}
+
+	if country == "" {
+		return changes, nil
+	}
+
+	filtered := make([]*taxrates.Change, 0, len(changes))
+
+	for _, change := range changes {
+		if change.Country == country {
+			filtered = append(filtered, change)
+		}
+	}
+
+	return filtered, nil
+}
+
+// listImportedCountriesRates returns the current rates of all countries of the import file keyed by the jurisdiction
+func (h *TaxesRoute) listImportedCountriesRates(ctx echo.Context, rows []*TaxRateImportRow) (map[string]*tax_service.TaxRate, error) {
+	current := make(map[string]*tax_service.TaxRate)
+	countries := make(map[string]bool)
+
+	for _, row := range rows {
+		if row.Action == taxImportActionInvalid || countries[row.Country] {
+			continue
+		}
+
+		countries[row.Country] = true
+		rates, err := taxrates.List(
+			ctx.Request().Context(),
+			h.dispatch.Services.Tax,
+			&tax_service.GetRatesRequest{Country: row.Country},
+			h.cfg.LimitMax,
+		)
+
+		if err != nil {
+			h.L().Error(common.InternalErrorTemplate, logger.WithFields(logger.Fields{"err": err.Error()}))
+			return nil, echo.NewHTTPError(http.StatusInternalServerError, common.ErrorInternal)
+		}
+
+		for _, rate := range rates {
+			current[taxrates.RateJurisdiction(rate)] = rate
+		}
+	}
+
+	return current, nil
+}
+
+// parseTaxImportLine checks the row of the import file. The past effective dates are dropped
+// because such rates are already in effect.
+func parseTaxImportLine(number int, line *taxImportLine, now time.Time) *TaxRateImportRow {
+	row := &TaxRateImportRow{
+		Row:     number,
+		Country: strings.ToUpper(line.Country),
+		State:   line.State,
+		City:    line.City,
+		Zip:     line.Zip,
+	}
+
+	if !taxCountryRegexp.MatchString(row.Country) {
+		row.Action = taxImportActionInvalid
+		row.Message = common.ErrorMessageTaxRateCountryInvalid
+		return row
+	}
+
+	rate, err := strconv.ParseFloat(line.Rate, 64)
+
+	if err != nil || rate < 0 || rate > 1 {
+		row.Action = taxImportActionInvalid
+		row.Message = common.ErrorMessageTaxRateValueInvalid
+		return row
+	}
+
+	row.Rate = rate
+
+	if line.EffectiveFrom == "" {
+		return row
+	}
+
+	effectiveFrom, err := parseTaxEffectiveFrom(line.EffectiveFrom)
+
+	if err != nil {
+		row.Action = taxImportActionInvalid
+		row.Message = common.ErrorMessageTaxRateEffectiveFromInvalid
+		return row
+	}
+
+	if effectiveFrom.After(now) {
+		row.EffectiveFrom = &effectiveFrom
+	}
+
+	return row
+}
+
+// parseTaxEffectiveFrom accepts the date which takes effect from the midnight UTC or the exact RFC 3339 time
+func parseTaxEffectiveFrom(value string) (time.Time, error) {
+	if t, err := time.Parse(taxesDateLayout, value); err == nil {
+		return t.UTC(), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return t.UTC(), nil
+}
+
+func formatTaxEffectiveFrom(t time.Time) string {
+	if t.Equal(t.Truncate(24 * time.Hour)) {
+		return t.Format(taxesDateLayout)
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+// readTaxesImportFile reads the rows of the multipart CSV file with the header row.
+// The country and rate columns are required, the others may be omitted.
+func readTaxesImportFile(ctx echo.Context) ([]*taxImportLine, error) {
+	file, err := ctx.FormFile(common.RequestParameterFile)
+
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageTaxesImportFileInvalid)
+	}
+
+	src, err := file.Open()
+
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageTaxesImportFileInvalid)
+	}
+
+	defer func() {
+		if err := src.Close(); err != nil {
+			return
+		}
+	}()
+
+	lines, err := readTaxesCsv(src)
+
+	if err != nil || len(lines) <= 0 {
+		msg := common.ErrorMessageTaxesImportFileInvalid
+
+		if err != nil {
+			msg = common.NewManagementApiResponseError(msg.Code, msg.Message, err.Error())
+		}
+
+		return nil, echo.NewHTTPError(http.StatusBadRequest, msg)
+	}
+
+	if len(lines) > taxImportRowsMax {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, common.ErrorMessageTaxesImportTooManyRows)
+	}
+
+	return lines, nil
+}
+
+func readTaxesCsv(src io.Reader) ([]*taxImportLine, error) {
+	reader := csv.NewReader(src)
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) <= 1 {
+		return nil, nil
+	}
+
+	header := make(map[string]int, len(rows[0]))
+
+	for i, column := range rows[0] {
+		column = strings.ToLower(strings.TrimSpace(column))
+		known := false
+
+		for _, c := range taxCsvColumns {
+			known = known || c == column
+		}
+
+		if !known {
+			return nil, fmt.Errorf("unknown column %s", column)
+		}
+
+		header[column] = i
+	}
+
+	for _, column := range []string{taxCsvColumnCountry, taxCsvColumnRate} {
+		if _, ok := header[column]; !ok {
+			return nil, fmt.Errorf("column %s is required", column)
+		}
+	}
+
+	value := func(columns []string, column string) string {
+		if i, ok := header[column]; ok {
+			return strings.TrimSpace(columns[i])
+		}
+
+		return ""
+	}
+	lines := make([]*taxImportLine, 0, len(rows)-1)
+
+	for _, columns := range rows[1:] {
+		lines = append(lines, &taxImportLine{
+			Country:       value(columns, taxCsvColumnCountry),
+			State:         value(columns, taxCsvColumnState),
+			City:          value(columns, taxCsvColumnCity),
+			Zip:           value(columns, taxCsvColumnZip),
+			Rate:          value(columns, taxCsvColumnRate),
+			EffectiveFrom: value(columns, taxCsvColumnEffectiveFrom),
+		})
+	}
+
+	return lines, nil
+}
+
+// writeTaxesCsv writes the current rates without the effective date followed by the scheduled changes
+func writeTaxesCsv(rates []*tax_service.TaxRate, changes []*taxrates.Change) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	writer := csv.NewWriter(buffer)
+
+	if err := writer.Write(taxCsvColumns); err != nil {
+		return nil, err
+	}
+
+	for _, rate := range rates {
+		row := []string{rate.Country, rate.State, rate.City, rate.Zip, strconv.FormatFloat(rate.Rate, 'f', -1, 64), ""}
+
+		if err := writer.Write(row); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, change := range changes {
+		row := []string{
+			change.Country,
+			change.State,
+			change.City,
+			change.Zip,
+			strconv.FormatFloat(change.Rate, 'f', -1, 64),
+			formatTaxEffectiveFrom(change.EffectiveFrom),
+		}
+
+		if err := writer.Write(row); err != nil {
+			return nil, err
+		}
+	}
+
+	writer.Flush()
+
+	return buffer.Bytes(), writer.Error()
+}
@@ -0,0 +1,457 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/labstack/echo/v4"
+	"github.com/micro/go-micro/client"
+	awsWrapper "github.com/paysuper/paysuper-aws-manager"
+	awsWrapperMocks "github.com/paysuper/paysuper-aws-manager/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/mock"
+	"github.com/paysuper/paysuper-management-api/internal/taxrates"
+	"github.com/paysuper/paysuper-management-api/internal/test"
+	"github.com/paysuper/paysuper-tax-service/proto"
+	mock2 "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	taxesImportTestCsv = "country,state,city,zip,rate,effective_from\n" +
+		"US,NY,,,0.045,\n" +
+		"US,CA,,,0.0725,\n" +
+		"us,TX,,,0.0625,2019-01-01\n" +
+		"DE,,,,0.16,2099-07-01\n" +
+		"US,NY,,,0.05,2099-01-01T10:00:00Z\n" +
+		"USA,,,,0.1,\n" +
+		"FR,,,,1.5,\n" +
+		"FR,,,,0.2,someday\n" +
+		"US,NY,,,0.04,\n"
+)
+
+// taxRatesStoreMock keeps the tax rates in memory and filters them like the tax service
+type taxRatesStoreMock struct {
+	mx       sync.Mutex
+	rates    []*tax_service.TaxRate
+	requests []*tax_service.GetRatesRequest
+	// onSave is called before the rate is saved
+	onSave func(rate *tax_service.TaxRate)
+}
+
+type TaxesImportTestSuite struct {
+	suite.Suite
+	router      *TaxesRoute
+	caller      *test.EchoReqResCaller
+	tax         *taxRatesStoreMock
+	files       map[string][]byte
+	downloadErr error
+}
+
+func Test_TaxesImport(t *testing.T) {
+	suite.Run(t, new(TaxesImportTestSuite))
+}
+
+func (suite *TaxesImportTestSuite) SetupTest() {
+	user := &common.AuthUser{
+		Id:    "ffffffffffffffffffffffff",
+		Email: "test@unit.test",
+	}
+	suite.tax = &taxRatesStoreMock{
+		rates: []*tax_service.TaxRate{
+			{Id: 1, Country: "US", State: "NY", Rate: 0.04},
+			{Id: 2, Country: "US", State: "CA", Rate: 0.0725},
+			{Id: 3, Country: "DE", Rate: 0.19},
+		},
+	}
+	suite.files = make(map[string][]byte)
+	suite.downloadErr = nil
+
+	var e error
+	settings := test.DefaultSettings()
+	srv := common.Services{
+		Billing: mock.NewBillingServerOkMock(),
+		Tax:     suite.tax,
+	}
+	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
+		mw.Pre(test.PreAuthUserMiddleware(user))
+
+		awsManagerMock := &awsWrapperMocks.AwsManagerInterface{}
+		awsManagerMock.On("Upload", mock2.Anything, mock2.Anything, mock2.Anything).
+			Run(func(args mock2.Arguments) {
+				in := args.Get(1).(*awsWrapper.UploadInput)
+				data, err := ioutil.ReadAll(in.Body)
+				if err != nil {
+					panic(err)
+				}
+				suite.files[in.FileName] = data
+			}).
+			Return(&s3manager.UploadOutput{}, nil)
+		awsManagerMock.On("Download", mock2.Anything, mock2.Anything, mock2.Anything, mock2.Anything).
+			Return(
+				func(ctx context.Context, filePath string, in *awsWrapper.DownloadInput, opts ...func(*s3manager.Downloader)) int64 {
+					data, ok := suite.files[in.FileName]
+					if !ok || suite.downloadErr != nil {
+						return 0
+					}
+					if err := ioutil.WriteFile(filePath, data, 0644); err != nil {
+						panic(err)
+					}
+					return int64(len(data))
+				},
+				func(ctx context.Context, filePath string, in *awsWrapper.DownloadInput, opts ...func(*s3manager.Downloader)) error {
+					if suite.downloadErr != nil {
+						return suite.downloadErr
+					}
+					if _, ok := suite.files[in.FileName]; !ok {
+						return awserr.New(s3.ErrCodeNoSuchKey, "key not found", nil)
+					}
+					return nil
+				},
+			)
+
+		suite.router = NewTaxesRoute(set.HandlerSet, taxrates.NewSchedule(awsManagerMock, suite.tax), set.GlobalConfig)
+		return common.Handlers{
+			suite.router,
+		}
+	})
+	if e != nil {
+		panic(e)
+	}
+}
+
+func (suite *TaxesImportTestSuite) TearDownTest() {}
+
+func (suite *TaxesImportTestSuite) importTaxes(content string, fields map[string]string) (*TaxRateImportResult, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	for key, value := range fields {
+		require.NoError(suite.T(), writer.WriteField(key, value))
+	}
+
+	part, err := writer.CreateFormFile(common.RequestParameterFile, "taxes.csv")
+	require.NoError(suite.T(), err)
+	_, err = part.Write([]byte(content))
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), writer.Close())
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.SystemUserGroupPath + taxesImportPath).
+		Init(func(request *http.Request, middleware test.Middleware) {
+			request.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+		}).
+		Body(body).
+		Exec(suite.T())
+
+	if err != nil {
+		return nil, err
+	}
+
+	require.Equal(suite.T(), http.StatusOK, res.Code)
+
+	result := &TaxRateImportResult{}
+	require.NoError(suite.T(), json.Unmarshal(res.Body.Bytes(), result))
+
+	return result, nil
+}
+
+func (suite *TaxesImportTestSuite) listScheduled() []*taxrates.Change {
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Path(common.SystemUserGroupPath + taxesScheduledPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), http.StatusOK, res.Code)
+
+	var changes []*taxrates.Change
+	require.NoError(suite.T(), json.Unmarshal(res.Body.Bytes(), &changes))
+
+	return changes
+}
+
+func (suite *TaxesImportTestSuite) TestTaxesImport_DryRun_Ok() {
+	shouldBe := require.New(suite.T())
+
+	result, err := suite.importTaxes(taxesImportTestCsv, map[string]string{"dry_run": "true"})
+	shouldBe.NoError(err)
+	shouldBe.True(result.DryRun)
+	shouldBe.Equal(9, result.Total)
+	shouldBe.Equal(1, result.Created)
+	shouldBe.Equal(1, result.Updated)
+	shouldBe.Equal(1, result.Unchanged)
+	shouldBe.Equal(2, result.Scheduled)
+	shouldBe.Equal(4, result.Failed)
+
+	shouldBe.Equal(taxImportActionUpdate, result.Rows[0].Action)
+	shouldBe.EqualValues(0.04, *result.Rows[0].OldRate)
+	shouldBe.Equal(taxImportActionUnchanged, result.Rows[1].Action)
+	shouldBe.Equal(taxImportActionCreate, result.Rows[2].Action)
+	shouldBe.Equal("US", result.Rows[2].Country)
+	shouldBe.Nil(result.Rows[2].EffectiveFrom)
+	shouldBe.Equal(taxImportActionSchedule, result.Rows[3].Action)
+	shouldBe.Equal(taxImportActionSchedule, result.Rows[4].Action)
+	shouldBe.Equal(common.ErrorMessageTaxRateCountryInvalid.Code, result.Rows[5].Message.Code)
+	shouldBe.Equal(common.ErrorMessageTaxRateValueInvalid.Code, result.Rows[6].Message.Code)
+	shouldBe.Equal(common.ErrorMessageTaxRateEffectiveFromInvalid.Code, result.Rows[7].Message.Code)
+	shouldBe.Equal(common.ErrorMessageTaxesImportRowDuplicated.Code, result.Rows[8].Message.Code)
+
+	shouldBe.Len(suite.tax.rates, 3)
+	shouldBe.EqualValues(0.04, suite.tax.rates[0].Rate)
+	shouldBe.Empty(suite.files)
+}
+
+func (suite *TaxesImportTestSuite) TestTaxesImport_Ok() {
+	shouldBe := require.New(suite.T())
+
+	result, err := suite.importTaxes(taxesImportTestCsv, nil)
+	shouldBe.NoError(err)
+	shouldBe.False(result.DryRun)
+	shouldBe.Equal(2, result.Scheduled)
+
+	shouldBe.Len(suite.tax.rates, 4)
+	shouldBe.EqualValues(0.045, suite.tax.rates[0].Rate)
+	shouldBe.Equal("TX", suite.tax.rates[3].State)
+	shouldBe.EqualValues(0.0625, suite.tax.rates[3].Rate)
+
+	changes := suite.listScheduled()
+	shouldBe.Len(changes, 2)
+	shouldBe.Equal("NY", changes[0].State)
+	shouldBe.Equal("DE", changes[1].Country)
+	shouldBe.Equal("ffffffffffffffffffffffff", changes[0].CreatedBy)
+	shouldBe.NotEmpty(changes[0].Id)
+}
+
+func (suite *TaxesImportTestSuite) TestTaxesImport_ScheduleApplied_Ok() {
+	shouldBe := require.New(suite.T())
+
+	_, err := suite.importTaxes(taxesImportTestCsv, nil)
+	shouldBe.NoError(err)
+
+	applied, err := suite.router.schedule.ApplyDue(context.Background(), time.Date(2099, 3, 1, 0, 0, 0, 0, time.UTC))
+	shouldBe.NoError(err)
+	shouldBe.Len(applied, 1)
+	shouldBe.EqualValues(0.05, suite.tax.rates[0].Rate)
+
+	changes := suite.listScheduled()
+	shouldBe.Len(changes, 1)
+	shouldBe.Equal("DE", changes[0].Country)
+
+	// importing the same change again replaces the pending one instead of adding the second
+	_, err = suite.importTaxes("country,rate,effective_from\nDE,0.17,2099-07-01\n", nil)
+	shouldBe.NoError(err)
+
+	changes = suite.listScheduled()
+	shouldBe.Len(changes, 1)
+	shouldBe.EqualValues(0.17, changes[0].Rate)
+}
+
+func (suite *TaxesImportTestSuite) TestTaxesImport_ScheduleReplacedWhileApplied_Ok() {
+	shouldBe := require.New(suite.T())
+
+	_, err := suite.importTaxes(taxesImportTestCsv, nil)
+	shouldBe.NoError(err)
+
+	// the schedule isn't locked while the rate is saved, so the change is replaced by the import meanwhile
+	suite.tax.onSave = func(rate *tax_service.TaxRate) {
+		suite.tax.onSave = nil
+		_, err := suite.importTaxes("country,state,rate,effective_from\nUS,NY,0.06,2099-01-01T10:00:00Z\n", nil)
+		shouldBe.NoError(err)
+	}
+
+	applied, err := suite.router.schedule.ApplyDue(context.Background(), time.Date(2099, 3, 1, 0, 0, 0, 0, time.UTC))
+	shouldBe.NoError(err)
+	shouldBe.Empty(applied)
+	shouldBe.EqualValues(0.05, suite.tax.rates[0].Rate)
+
+	changes := suite.listScheduled()
+	shouldBe.Len(changes, 2)
+	shouldBe.Equal("NY", changes[0].State)
+	shouldBe.EqualValues(0.06, changes[0].Rate)
+
+	applied, err = suite.router.schedule.ApplyDue(context.Background(), time.Date(2099, 3, 1, 0, 0, 0, 0, time.UTC))
+	shouldBe.NoError(err)
+	shouldBe.Len(applied, 1)
+	shouldBe.EqualValues(0.06, suite.tax.rates[0].Rate)
+}
+
+func (suite *TaxesImportTestSuite) TestTaxesImport_ScheduleNotDue_Ok() {
+	shouldBe := require.New(suite.T())
+
+	_, err := suite.importTaxes(taxesImportTestCsv, nil)
+	shouldBe.NoError(err)
+
+	delete(suite.files, "locks/taxes/schedule.json")
+	applied, err := suite.router.schedule.ApplyDue(context.Background(), time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	shouldBe.NoError(err)
+	shouldBe.Empty(applied)
+	shouldBe.Len(suite.listScheduled(), 2)
+
+	// the schedule isn't locked and rewritten when nothing is due
+	shouldBe.NotContains(suite.files, "locks/taxes/schedule.json")
+}
+
+func (suite *TaxesImportTestSuite) TestTaxesImport_UnknownColumn_Error() {
+	_, err := suite.importTaxes("country,rate,vat\nDE,0.19,1\n", nil)
+	suite.requireHttpError(err, http.StatusBadRequest, common.ErrorMessageTaxesImportFileInvalid)
+
+	_, err = suite.importTaxes("country,state\nDE,\n", nil)
+	suite.requireHttpError(err, http.StatusBadRequest, common.ErrorMessageTaxesImportFileInvalid)
+}
+
+func (suite *TaxesImportTestSuite) TestTaxesExport_Ok() {
+	shouldBe := require.New(suite.T())
+
+	_, err := suite.importTaxes(taxesImportTestCsv, nil)
+	shouldBe.NoError(err)
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		SetQueryParam("country", "us").
+		Path(common.SystemUserGroupPath + taxesExportPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+	shouldBe.Contains(res.Header().Get(echo.HeaderContentDisposition), "taxes_US.csv")
+
+	lines := strings.Split(strings.TrimSpace(res.Body.String()), "\n")
+	shouldBe.Equal([]string{
+		"country,state,city,zip,rate,effective_from",
+		"US,NY,,,0.045,",
+		"US,CA,,,0.0725,",
+		"US,TX,,,0.0625,",
+		"US,NY,,,0.05,2099-01-01T10:00:00Z",
+	}, lines)
+}
+
+func (suite *TaxesImportTestSuite) TestTaxesScheduled_Cancel_Ok() {
+	shouldBe := require.New(suite.T())
+
+	_, err := suite.importTaxes(taxesImportTestCsv, nil)
+	shouldBe.NoError(err)
+
+	changes := suite.listScheduled()
+	shouldBe.Len(changes, 2)
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodDelete).
+		Params(":"+common.RequestParameterChangeId, changes[0].Id).
+		Path(common.SystemUserGroupPath + taxesScheduledIdPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	shouldBe.NoError(err)
+	shouldBe.Equal(http.StatusOK, res.Code)
+	shouldBe.Len(suite.listScheduled(), 1)
+
+	_, err = suite.caller.Builder().
+		Method(http.MethodDelete).
+		Params(":"+common.RequestParameterChangeId, changes[0].Id).
+		Path(common.SystemUserGroupPath + taxesScheduledIdPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	suite.requireHttpError(err, http.StatusNotFound, common.ErrorMessageTaxRateChangeNotFound)
+}
+
+func (suite *TaxesImportTestSuite) TestTaxesScheduled_StorageFailed_Error() {
+	suite.downloadErr = errors.New("some error")
+
+	_, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		Path(common.SystemUserGroupPath + taxesScheduledPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	suite.requireHttpError(err, http.StatusInternalServerError, common.ErrorMessageTaxRateScheduleStorageFailed)
+}
+
+func (suite *TaxesImportTestSuite) requireHttpError(err error, code int, message *grpc.ResponseErrorMessage) {
+	shouldBe := require.New(suite.T())
+	shouldBe.Error(err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	shouldBe.True(ok)
+	shouldBe.Equal(code, httpErr.Code)
+
+	msg, ok := httpErr.Message.(*grpc.ResponseErrorMessage)
+	shouldBe.True(ok)
+	shouldBe.Equal(message.Code, msg.Code)
+}
+
+func (m *taxRatesStoreMock) GetRate(ctx context.Context, in *tax_service.GeoIdentity, opts ...client.CallOption) (*tax_service.TaxRate, error) {
+	panic("this method is not implemented in mock")
+}
+
+func (m *taxRatesStoreMock) GetRates(ctx context.Context, in *tax_service.GetRatesRequest, opts ...client.CallOption) (*tax_service.GetRatesResponse, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	matched := make([]*tax_service.TaxRate, 0)
+	m.requests = append(m.requests, in)
+
+	for _, rate := range m.rates {
+		if (in.Country != "" && in.Country != rate.Country) ||
+			(in.State != "" && in.State != rate.State) ||
+			(in.City != "" && in.City != rate.City) ||
+			(in.Zip != "" && in.Zip != rate.Zip) {
+			continue
+		}
+
+		copied := *rate
+		matched = append(matched, &copied)
+	}
+
+	res := &tax_service.GetRatesResponse{}
+
+	for i := int(in.Offset); i < len(matched) && i < int(in.Offset+in.Limit); i++ {
+		res.Rates = append(res.Rates, matched[i])
+	}
+
+	return res, nil
+}
+
+func (m *taxRatesStoreMock) CreateOrUpdate(ctx context.Context, in *tax_service.TaxRate, opts ...client.CallOption) (*tax_service.TaxRate, error) {
+	if m.onSave != nil {
+		m.onSave(in)
+	}
+
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	copied := *in
+
+	for i, rate := range m.rates {
+		if rate.Id == in.Id {
+			m.rates[i] = &copied
+			return in, nil
+		}
+	}
+
+	copied.Id = uint32(len(m.rates) + 1)
+	m.rates = append(m.rates, &copied)
+
+	return &copied, nil
+}
+
+func (m *taxRatesStoreMock) DeleteRateById(ctx context.Context, in *tax_service.DeleteRateRequest, opts ...client.CallOption) (*tax_service.DeleteRateResponse, error) {
+	panic("this method is not implemented in mock")
+}
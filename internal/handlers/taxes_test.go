@@ -8,6 +8,7 @@ import (
 	"github.com/micro/go-micro/client"
 	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
 	"github.com/paysuper/paysuper-management-api/internal/mock"
+	"github.com/paysuper/paysuper-management-api/internal/taxrates"
 	"github.com/paysuper/paysuper-management-api/internal/test"
 	"github.com/paysuper/paysuper-tax-service/proto"
 	"github.com/stretchr/testify/assert"
@@ -17,6 +18,7 @@ import (
 	"net/url"
 	"strconv"
 	"testing"
+	"time"
 )
 
 type TaxServiceMock struct {
@@ -27,6 +29,7 @@ type TaxesTestSuite struct {
 	suite.Suite
 	router *TaxesRoute
 	caller *test.EchoReqResCaller
+	files  map[string][]byte
 }
 
 func Test_Taxes(t *testing.T) {
@@ -34,6 +37,8 @@ func Test_Taxes(t *testing.T) {
 }
 
 func (suite *TaxesTestSuite) SetupTest() {
+	suite.files = make(map[string][]byte)
+
 	var e error
 	settings := test.DefaultSettings()
 	srv := common.Services{
@@ -47,7 +52,8 @@ func (suite *TaxesTestSuite) SetupTest() {
 	}
 	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
 		mw.Pre(test.PreAuthUserMiddleware(user))
-		suite.router = NewTaxesRoute(set.HandlerSet, set.GlobalConfig)
+		schedule := taxrates.NewSchedule(mock.NewAwsManagerFilesMock(suite.files), srv.Tax)
+		suite.router = NewTaxesRoute(set.HandlerSet, schedule, set.GlobalConfig)
 		return common.Handlers{
 			suite.router,
 		}
@@ -212,6 +218,38 @@ func testDeleteTaxWithError(t *testing.T, suite *TaxesTestSuite, id string, code
 	}
 }
 
+func (suite *TaxesTestSuite) Test_ScheduledTaxes() {
+	effectiveFrom := time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC)
+	err := suite.router.schedule.Add(context.Background(), []*taxrates.Change{
+		{Country: "DE", Rate: 0.16, EffectiveFrom: effectiveFrom},
+		{Country: "US", State: "NY", Rate: 0.05, EffectiveFrom: effectiveFrom.AddDate(0, -1, 0)},
+	})
+	assert.NoError(suite.T(), err)
+	assert.Contains(suite.T(), suite.files, "taxes/schedule.json")
+
+	res, err := suite.caller.Builder().
+		Method(http.MethodGet).
+		SetQueryParam("country", "de").
+		Path(common.SystemUserGroupPath + taxesScheduledPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	if assert.NoError(suite.T(), err) {
+		var changes []*taxrates.Change
+		assert.NoError(suite.T(), json.Unmarshal(res.Body.Bytes(), &changes))
+		assert.Len(suite.T(), changes, 1)
+		assert.EqualValues(suite.T(), 0.16, changes[0].Rate)
+		assert.True(suite.T(), effectiveFrom.Equal(changes[0].EffectiveFrom))
+	}
+
+	// the schedule saved by the other replica is read from the shared storage
+	schedule := taxrates.NewSchedule(mock.NewAwsManagerFilesMock(suite.files), suite.router.dispatch.Services.Tax)
+	changes, err := schedule.List(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), changes, 2)
+	assert.Equal(suite.T(), "US", changes[0].Country)
+}
+
 func createNewTaxServiceMock() tax_service.TaxService {
 	return &TaxServiceMock{}
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/paysuper/paysuper-billing-server/pkg"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
 	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/paymentform"
 	"net/http"
 )
 
@@ -15,17 +16,19 @@ const (
 )
 
 type TokenRoute struct {
-	dispatch common.HandlerSet
-	cfg      common.Config
+	dispatch    common.HandlerSet
+	paymentForm *paymentform.Builder
+	cfg         common.Config
 	provider.LMT
 }
 
 func NewTokenRoute(set common.HandlerSet, cfg *common.Config) *TokenRoute {
 	set.AwareSet.Logger = set.AwareSet.Logger.WithFields(logger.Fields{"router": "TokenRoute"})
 	return &TokenRoute{
-		dispatch: set,
-		LMT:      &set.AwareSet,
-		cfg:      *cfg,
+		dispatch:    set,
+		paymentForm: newPaymentFormUrlBuilder(cfg),
+		LMT:         &set.AwareSet,
+		cfg:         *cfg,
 	}
 }
 
@@ -64,9 +67,15 @@ func (h *TokenRoute) createToken(ctx echo.Context) error {
 		return echo.NewHTTPError(int(res.Status), res.Message)
 	}
 
+	formUrl, err := buildPaymentFormUrl(ctx, h.paymentForm, "", res.Token)
+
+	if err != nil {
+		return err
+	}
+
 	response := map[string]string{
 		"token":            res.Token,
-		"payment_form_url": h.cfg.OrderInlineFormUrlMask + "?token=" + res.Token,
+		"payment_form_url": formUrl,
 	}
 
 	return ctx.JSON(http.StatusOK, response)
@@ -12,7 +12,9 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"net/http"
+	"net/url"
 	"testing"
+	"time"
 )
 
 type TokenTestSuite struct {
@@ -98,6 +100,17 @@ func (suite *TokenTestSuite) TestToken_CreateToken_Ok() {
 
 	assert.Equal(suite.T(), http.StatusOK, res.Code)
 	assert.NotEmpty(suite.T(), res.Body.String())
+
+	response := make(map[string]string)
+	err = json.Unmarshal(res.Body.Bytes(), &response)
+	assert.NoError(suite.T(), err)
+
+	formUrl, err := url.Parse(response["payment_form_url"])
+	assert.NoError(suite.T(), err)
+
+	params, err := suite.router.paymentForm.Verify(formUrl.Query(), time.Now())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), response["token"], params.Token)
 }
 
 func (suite *TokenTestSuite) TestToken_CreateToken_BindError() {
@@ -5,14 +5,17 @@ import (
 	"github.com/ProtocolONE/go-core/v2/pkg/provider"
 	"github.com/labstack/echo/v4"
 	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
 	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/invite"
 	"net/http"
 )
 
 type UserRoute struct {
 	dispatch common.HandlerSet
 	cfg      common.Config
+	invites  *invite.Store
 	provider.LMT
 }
 
@@ -22,12 +25,13 @@ const (
 	getMerchants  = "/user/merchants"
 )
 
-func NewUserRoute(set common.HandlerSet, cfg *common.Config) *UserRoute {
+func NewUserRoute(set common.HandlerSet, invites *invite.Store, cfg *common.Config) *UserRoute {
 	set.AwareSet.Logger = set.AwareSet.Logger.WithFields(logger.Fields{"router": "UsersRoute"})
 	return &UserRoute{
 		dispatch: set,
 		LMT:      &set.AwareSet,
 		cfg:      *cfg,
+		invites:  invites,
 	}
 }
 
@@ -53,14 +57,9 @@ func (h *UserRoute) checkInvite(ctx echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, common.GetValidationError(err))
 	}
 
-	res, err := h.dispatch.Services.Billing.CheckInviteToken(ctx.Request().Context(), req)
+	res, err := h.getInvite(ctx, req)
 	if err != nil {
-		common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "CheckInviteToken", req)
-		return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessageUnableToCheckInviteToken)
-	}
-
-	if res.Status != pkg.ResponseStatusOk {
-		return echo.NewHTTPError(int(res.Status), res.Message)
+		return err
 	}
 
 	return ctx.JSON(http.StatusOK, res)
@@ -82,6 +81,11 @@ func (h *UserRoute) approveInvite(ctx echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, common.GetValidationError(err))
 	}
 
+	_, err = h.getInvite(ctx, &grpc.CheckInviteTokenRequest{Token: req.Token, Email: req.Email})
+	if err != nil {
+		return err
+	}
+
 	res, err := h.dispatch.Services.Billing.AcceptInvite(ctx.Request().Context(), req)
 	if err != nil {
 		common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "AcceptInvite", req)
@@ -95,6 +99,56 @@ func (h *UserRoute) approveInvite(ctx echo.Context) error {
 	return ctx.JSON(http.StatusOK, res)
 }
 
+// getInvite checks the invite token and rejects the invite sent longer than the invite lifetime ago
+func (h *UserRoute) getInvite(ctx echo.Context, req *grpc.CheckInviteTokenRequest) (*grpc.CheckInviteTokenResponse, error) {
+	res, err := h.dispatch.Services.Billing.CheckInviteToken(ctx.Request().Context(), req)
+	if err != nil {
+		common.LogSrvCallFailedGRPC(h.L(), err, pkg.ServiceName, "CheckInviteToken", req)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessageUnableToCheckInviteToken)
+	}
+
+	if res.Status != pkg.ResponseStatusOk {
+		return nil, echo.NewHTTPError(int(res.Status), res.Message)
+	}
+
+	role := func() (*billing.UserRole, error) {
+		return h.getInviteRole(ctx, res)
+	}
+
+	err = checkInviteExpiry(ctx.Request().Context(), h, h.invites, role, res.RoleId, h.cfg.InviteLifetime)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// getInviteRole returns the role of the checked invite token by the role type of the token
+func (h *UserRoute) getInviteRole(ctx echo.Context, token *grpc.CheckInviteTokenResponse) (*billing.UserRole, error) {
+	var (
+		res *grpc.UserRoleResponse
+		err error
+	)
+
+	if token.RoleType == pkg.RoleTypeSystem {
+		req := &grpc.AdminRoleRequest{RoleId: token.RoleId}
+		if res, err = h.dispatch.Services.Billing.GetAdminUserRole(ctx.Request().Context(), req); err != nil {
+			return nil, h.dispatch.SrvCallHandler(req, err, pkg.ServiceName, "GetAdminUserRole")
+		}
+	} else {
+		req := &grpc.MerchantRoleRequest{RoleId: token.RoleId}
+		if res, err = h.dispatch.Services.Billing.GetMerchantUserRole(ctx.Request().Context(), req); err != nil {
+			return nil, h.dispatch.SrvCallHandler(req, err, pkg.ServiceName, "GetMerchantUserRole")
+		}
+	}
+
+	if res.Status != pkg.ResponseStatusOk {
+		return nil, echo.NewHTTPError(int(res.Status), res.Message)
+	}
+
+	return res.UserRole, nil
+}
+
 func (h *UserRoute) getMerchants(ctx echo.Context) error {
 	authUser := common.ExtractUserContext(ctx)
 
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/labstack/echo/v4"
+	"github.com/paysuper/paysuper-billing-server/pkg"
+	"github.com/paysuper/paysuper-billing-server/pkg/mocks"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
+	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/invite"
+	"github.com/paysuper/paysuper-management-api/internal/mock"
+	"github.com/paysuper/paysuper-management-api/internal/test"
+	mock2 "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const (
+	userTestRoleId = "5dc3f6c5ad8b8c0001b1e2e1"
+)
+
+type UserTestSuite struct {
+	suite.Suite
+	router  *UserRoute
+	caller  *test.EchoReqResCaller
+	invites *invite.Store
+	bucket  *mock.BucketMock
+}
+
+func Test_User(t *testing.T) {
+	suite.Run(t, new(UserTestSuite))
+}
+
+func (suite *UserTestSuite) SetupTest() {
+	user := &common.AuthUser{
+		Id:    "ffffffffffffffffffffffff",
+		Email: "test@unit.test",
+	}
+	var e error
+	settings := test.DefaultSettings()
+	srv := common.Services{
+		Billing: &mocks.BillingService{},
+	}
+	suite.caller, e = test.SetUp(settings, srv, func(set *test.TestSet, mw test.Middleware) common.Handlers {
+		mw.Pre(test.PreAuthUserMiddleware(user))
+		suite.bucket = &mock.BucketMock{}
+		suite.invites = invite.NewStore(mock.NewAwsManagerFilesMock(map[string][]byte{}), suite.bucket)
+		suite.router = NewUserRoute(set.HandlerSet, suite.invites, set.GlobalConfig)
+		return common.Handlers{
+			suite.router,
+		}
+	})
+	if e != nil {
+		panic(e)
+	}
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("CheckInviteToken", mock2.Anything, mock2.Anything).
+		Return(&grpc.CheckInviteTokenResponse{Status: pkg.ResponseStatusOk, RoleId: userTestRoleId}, nil)
+	billingService.On("AcceptInvite", mock2.Anything, mock2.Anything).
+		Return(&grpc.AcceptInviteResponse{Status: pkg.ResponseStatusOk}, nil)
+}
+
+func (suite *UserTestSuite) TearDownTest() {}
+
+func (suite *UserTestSuite) exec(path string) error {
+	_, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.AuthProjectGroupPath + path).
+		Init(test.ReqInitJSON()).
+		BodyString(`{"token": "some_token"}`).
+		Exec(suite.T())
+
+	return err
+}
+
+func (suite *UserTestSuite) TestUser_CheckInvite_Ok() {
+	_, err := suite.invites.Sent(context.Background(), userTestRoleId, time.Now().Add(-time.Hour))
+	require.NoError(suite.T(), err)
+
+	require.NoError(suite.T(), suite.exec(inviteCheck))
+}
+
+func (suite *UserTestSuite) TestUser_CheckInvite_Expired_Error() {
+	_, err := suite.invites.Sent(context.Background(), userTestRoleId, time.Now().Add(-30*24*time.Hour))
+	require.NoError(suite.T(), err)
+
+	err = suite.exec(inviteCheck)
+	require.Error(suite.T(), err)
+	hErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), http.StatusBadRequest, hErr.Code)
+	require.Equal(suite.T(), common.ErrorMessageInviteExpired, hErr.Message)
+}
+
+// mockInviteRole returns the invited merchant role created the time ago
+func (suite *UserTestSuite) mockInviteRole(ago time.Duration) *mocks.BillingService {
+	createdAt, err := ptypes.TimestampProto(time.Now().Add(-ago))
+	require.NoError(suite.T(), err)
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.On("GetMerchantUserRole", mock2.Anything, mock2.MatchedBy(func(req *grpc.MerchantRoleRequest) bool {
+		return req.RoleId == userTestRoleId
+	})).Return(&grpc.UserRoleResponse{
+		Status:   pkg.ResponseStatusOk,
+		UserRole: &billing.UserRole{Id: userTestRoleId, Status: pkg.UserRoleStatusInvited, CreatedAt: createdAt},
+	}, nil)
+
+	return billingService
+}
+
+func (suite *UserTestSuite) TestUser_CheckInvite_NotRecorded_Ok() {
+	billingService := suite.mockInviteRole(time.Hour)
+
+	require.NoError(suite.T(), suite.exec(inviteCheck))
+	billingService.AssertCalled(suite.T(), "GetMerchantUserRole", mock2.Anything, mock2.Anything)
+}
+
+func (suite *UserTestSuite) TestUser_CheckInvite_NotRecorded_Expired_Error() {
+	// the invite sent before the sendings were recorded expires in the lifetime after the role creation
+	suite.mockInviteRole(30 * 24 * time.Hour)
+
+	err := suite.exec(inviteCheck)
+	require.Error(suite.T(), err)
+	hErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), http.StatusBadRequest, hErr.Code)
+	require.Equal(suite.T(), common.ErrorMessageInviteExpired, hErr.Message)
+
+	// the check doesn't record the sending, the next check expires the invite again
+	sending, err := suite.invites.Get(context.Background(), userTestRoleId)
+	require.NoError(suite.T(), err)
+	require.Nil(suite.T(), sending)
+}
+
+func (suite *UserTestSuite) TestUser_ApproveInvite_Ok() {
+	_, err := suite.invites.Sent(context.Background(), userTestRoleId, time.Now().Add(-time.Hour))
+	require.NoError(suite.T(), err)
+
+	require.NoError(suite.T(), suite.exec(inviteApprove))
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.AssertCalled(suite.T(), "CheckInviteToken", mock2.Anything, mock2.MatchedBy(func(req *grpc.CheckInviteTokenRequest) bool {
+		return req.Token == "some_token" && req.Email == "test@unit.test"
+	}))
+	billingService.AssertNumberOfCalls(suite.T(), "AcceptInvite", 1)
+}
+
+func (suite *UserTestSuite) TestUser_ApproveInvite_Expired_Error() {
+	_, err := suite.invites.Sent(context.Background(), userTestRoleId, time.Now().Add(-30*24*time.Hour))
+	require.NoError(suite.T(), err)
+
+	err = suite.exec(inviteApprove)
+	require.Error(suite.T(), err)
+	hErr, ok := err.(*echo.HTTPError)
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), common.ErrorMessageInviteExpired, hErr.Message)
+
+	billingService := suite.router.dispatch.Services.Billing.(*mocks.BillingService)
+	billingService.AssertNotCalled(suite.T(), "AcceptInvite", mock2.Anything, mock2.Anything)
+}
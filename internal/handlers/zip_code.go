@@ -6,30 +6,48 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
 	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/postcode"
 	"net/http"
+	"time"
 )
 
 const (
-	zipCodePath = "/zip"
+	zipCodePath              = "/zip"
+	zipCodeValidatePath      = "/zip/validate"
+	zipCodeFormatsReloadPath = "/zip/formats/reload"
 )
 
 type ZipCodeRoute struct {
-	dispatch common.HandlerSet
-	cfg      common.Config
+	dispatch  common.HandlerSet
+	cfg       common.Config
+	postcodes *postcode.Formats
 	provider.LMT
 }
 
+type zipCodeValidateRequest struct {
+	Country string `query:"country" validate:"required,len=2"`
+	Zip     string `query:"zip" validate:"required,max=30"`
+}
+
+type ZipFormatsInfo struct {
+	Formats   int       `json:"formats"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
 func NewZipCodeRoute(set common.HandlerSet, cfg *common.Config) *ZipCodeRoute {
 	set.AwareSet.Logger = set.AwareSet.Logger.WithFields(logger.Fields{"router": "ZipCodeRoute"})
 	return &ZipCodeRoute{
-		dispatch: set,
-		LMT:      &set.AwareSet,
-		cfg:      *cfg,
+		dispatch:  set,
+		LMT:       &set.AwareSet,
+		cfg:       *cfg,
+		postcodes: postcode.Default(),
 	}
 }
 
 func (h *ZipCodeRoute) Route(groups *common.Groups) {
 	groups.Common.GET(zipCodePath, h.checkZip)
+	groups.Common.GET(zipCodeValidatePath, h.validateZip)
+	groups.SystemUser.POST(zipCodeFormatsReloadPath, h.reloadZipFormats)
 }
 
 func (h *ZipCodeRoute) checkZip(ctx echo.Context) error {
@@ -56,3 +74,24 @@ func (h *ZipCodeRoute) checkZip(ctx echo.Context) error {
 
 	return ctx.JSON(http.StatusOK, res)
 }
+
+// validateZip checks the postal code against the format of the country.
+// The response contains the normalized postal code and the examples of the country format.
+func (h *ZipCodeRoute) validateZip(ctx echo.Context) error {
+	req := &zipCodeValidateRequest{}
+
+	if err := h.dispatch.BindAndValidate(req, ctx); err != nil {
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, h.postcodes.Validate(req.Country, req.Zip))
+}
+
+func (h *ZipCodeRoute) reloadZipFormats(ctx echo.Context) error {
+	if err := h.postcodes.Reload(); err != nil {
+		h.L().Error("Postal code formats reload failed", logger.PairArgs("err", err.Error()))
+		return echo.NewHTTPError(http.StatusInternalServerError, common.ErrorMessageZipFormatsReloadFailed)
+	}
+
+	return ctx.JSON(http.StatusOK, &ZipFormatsInfo{Formats: h.postcodes.Len(), UpdatedAt: h.postcodes.UpdatedAt()})
+}
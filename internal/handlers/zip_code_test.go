@@ -6,6 +6,7 @@ import (
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
 	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
 	"github.com/paysuper/paysuper-management-api/internal/mock"
+	"github.com/paysuper/paysuper-management-api/internal/postcode"
 	"github.com/paysuper/paysuper-management-api/internal/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -115,3 +116,79 @@ func (suite *ZipCodeTestSuite) TestCheckZip_BillingServerError() {
 	assert.Equal(suite.T(), http.StatusInternalServerError, httpErr.Code)
 	assert.Equal(suite.T(), common.ErrorUnknown, httpErr.Message)
 }
+
+func (suite *ZipCodeTestSuite) TestValidateZip_Ok() {
+	q := make(url.Values)
+	q.Set("country", "ca")
+	q.Set("zip", " k1a-0b1 ")
+
+	res, err := suite.caller.Builder().
+		Path(common.NoAuthGroupPath + zipCodeValidatePath).
+		SetQueryParams(q).
+		Exec(suite.T())
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, res.Code)
+
+	data := &postcode.Result{}
+	err = json.Unmarshal(res.Body.Bytes(), data)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "CA", data.Country)
+	assert.True(suite.T(), data.Valid)
+	assert.True(suite.T(), data.Known)
+	assert.Equal(suite.T(), "K1A 0B1", data.Normalized)
+	assert.NotEmpty(suite.T(), data.Examples)
+}
+
+func (suite *ZipCodeTestSuite) TestValidateZip_Invalid() {
+	q := make(url.Values)
+	q.Set("country", "RU")
+	q.Set("zip", "1900")
+
+	res, err := suite.caller.Builder().
+		Path(common.NoAuthGroupPath + zipCodeValidatePath).
+		SetQueryParams(q).
+		Exec(suite.T())
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, res.Code)
+
+	data := &postcode.Result{}
+	err = json.Unmarshal(res.Body.Bytes(), data)
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), data.Valid)
+	assert.Empty(suite.T(), data.Normalized)
+	assert.Contains(suite.T(), data.Examples, "190000")
+}
+
+func (suite *ZipCodeTestSuite) TestValidateZip_ValidateError() {
+	q := make(url.Values)
+	q.Set("zip", "190000")
+
+	_, err := suite.caller.Builder().
+		Path(common.NoAuthGroupPath + zipCodeValidatePath).
+		SetQueryParams(q).
+		Exec(suite.T())
+	assert.Error(suite.T(), err)
+
+	httpErr, ok := err.(*echo.HTTPError)
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), http.StatusBadRequest, httpErr.Code)
+}
+
+func (suite *ZipCodeTestSuite) TestReloadZipFormats_Ok() {
+	res, err := suite.caller.Builder().
+		Method(http.MethodPost).
+		Path(common.SystemUserGroupPath + zipCodeFormatsReloadPath).
+		Init(test.ReqInitJSON()).
+		Exec(suite.T())
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, res.Code)
+
+	info := new(ZipFormatsInfo)
+	err = json.Unmarshal(res.Body.Bytes(), info)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), info.Formats > 0)
+	assert.False(suite.T(), info.UpdatedAt.IsZero())
+}
@@ -0,0 +1,126 @@
+package invite
+
+import (
+	"context"
+	"fmt"
+	awsWrapper "github.com/paysuper/paysuper-aws-manager"
+	"github.com/paysuper/paysuper-management-api/internal/storage"
+	"sync"
+	"time"
+)
+
+const (
+	sendingFileMask = "invites/%s.json"
+	// sendingReaders is the number of the sendings read at once by GetMany
+	sendingReaders = 10
+)
+
+// Sending is the last sending of the invite, the invite expires in the lifetime after it
+type Sending struct {
+	RoleId string    `json:"role_id"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+// ExpiresAt returns the expiration time of the invite
+func (s *Sending) ExpiresAt(lifetime time.Duration) time.Time {
+	return s.SentAt.Add(lifetime)
+}
+
+// IsExpired
+func (s *Sending) IsExpired(lifetime time.Duration, now time.Time) bool {
+	return now.After(s.ExpiresAt(lifetime))
+}
+
+// Store keeps the sending times of the invites in the reporter bucket by the invited role identifier.
+// The billing server doesn't keep the time of the invite sending, the role update time changes on any change.
+type Store struct {
+	files   *storage.Store
+	deleter storage.Deleter
+}
+
+// NewStore
+func NewStore(awsManager awsWrapper.AwsManagerInterface, deleter storage.Deleter) *Store {
+	return &Store{files: storage.New(awsManager), deleter: deleter}
+}
+
+// Sent records the sending of the invite of the role
+func (s *Store) Sent(ctx context.Context, roleId string, sentAt time.Time) (*Sending, error) {
+	sending := &Sending{RoleId: roleId, SentAt: sentAt.UTC()}
+
+	if err := s.files.Save(ctx, fmt.Sprintf(sendingFileMask, roleId), sending); err != nil {
+		return nil, err
+	}
+
+	return sending, nil
+}
+
+// Get returns the last sending of the invite of the role, nil is returned for the invite sent before
+// the sendings were recorded
+func (s *Store) Get(ctx context.Context, roleId string) (*Sending, error) {
+	sending := &Sending{}
+	found, err := s.files.Load(ctx, fmt.Sprintf(sendingFileMask, roleId), sending)
+
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return sending, nil
+}
+
+// GetMany returns the last sendings of the invites of the roles by the role identifiers.
+// The sendings are read in parallel, the roles of the invites sent before the sendings were recorded are missed.
+func (s *Store) GetMany(ctx context.Context, roleIds []string) (map[string]*Sending, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg    sync.WaitGroup
+		mx    sync.Mutex
+		first error
+	)
+
+	sendings := make(map[string]*Sending, len(roleIds))
+	slots := make(chan struct{}, sendingReaders)
+
+	for _, roleId := range roleIds {
+		slots <- struct{}{}
+		wg.Add(1)
+
+		go func(roleId string) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			sending, err := s.Get(ctx, roleId)
+
+			mx.Lock()
+			defer mx.Unlock()
+
+			if err != nil {
+				if first == nil {
+					first = err
+				}
+				cancel()
+				return
+			}
+
+			if sending != nil {
+				sendings[roleId] = sending
+			}
+		}(roleId)
+	}
+
+	wg.Wait()
+
+	if first != nil {
+		return nil, first
+	}
+
+	return sendings, nil
+}
+
+// Delete removes the sending of the invite of the revoked role
+func (s *Store) Delete(ctx context.Context, roleId string) error {
+	return s.deleter.Delete(ctx, fmt.Sprintf(sendingFileMask, roleId))
+}
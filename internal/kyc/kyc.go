@@ -0,0 +1,144 @@
+package kyc
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	DocumentTypeIncorporationCertificate = "incorporation_certificate"
+	DocumentTypeDirectorId               = "director_id"
+	DocumentTypeProofOfAddress           = "proof_of_address"
+
+	StatusPending  = "pending"
+	StatusInReview = "in_review"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+
+	contentTypePdf  = "application/pdf"
+	contentTypeJpeg = "image/jpeg"
+	contentTypePng  = "image/png"
+)
+
+var (
+	ErrorDocumentTypeUnknown        = errors.New("document type is unknown")
+	ErrorDocumentContentType        = errors.New("document content type is not allowed for the document type")
+	ErrorDocumentMaxSize            = errors.New("document max upload size exceeded")
+	ErrorDocumentNotFound           = errors.New("document not found")
+	ErrorDocumentLocked             = errors.New("document is in review or approved and can't be replaced")
+	ErrorDocumentStatusTransition   = errors.New("document status can't be changed to the requested status")
+	ErrorDocumentRejectReasonNeeded = errors.New("reason is required to reject the document")
+)
+
+// Rule describes the files accepted for the document type
+type Rule struct {
+	// ContentTypes maps the allowed content type to the stored file extension
+	ContentTypes map[string]string `json:"content_types"`
+	MaxSize      int64             `json:"max_size"`
+}
+
+// Rules contains the upload rules for every supported document type
+var Rules = map[string]*Rule{
+	DocumentTypeIncorporationCertificate: {
+		ContentTypes: map[string]string{contentTypePdf: "pdf"},
+		MaxSize:      10485760,
+	},
+	DocumentTypeDirectorId: {
+		ContentTypes: map[string]string{contentTypeJpeg: "jpg", contentTypePng: "png", contentTypePdf: "pdf"},
+		MaxSize:      5242880,
+	},
+	DocumentTypeProofOfAddress: {
+		ContentTypes: map[string]string{contentTypePdf: "pdf", contentTypeJpeg: "jpg", contentTypePng: "png"},
+		MaxSize:      5242880,
+	},
+}
+
+// allowedTransitions maps the target status to the statuses the document can be moved from by the reviewer
+var allowedTransitions = map[string][]string{
+	StatusInReview: {StatusPending},
+	StatusApproved: {StatusPending, StatusInReview},
+	StatusRejected: {StatusPending, StatusInReview},
+}
+
+// GetRule returns the upload rule for the document type
+func GetRule(documentType string) (*Rule, error) {
+	rule, ok := Rules[documentType]
+
+	if !ok {
+		return nil, ErrorDocumentTypeUnknown
+	}
+
+	return rule, nil
+}
+
+// Check validates the file against the rule and returns the extension to store the file with
+func (r *Rule) Check(contentType string, size int64) (string, error) {
+	if size > r.MaxSize {
+		return "", ErrorDocumentMaxSize
+	}
+
+	ext, ok := r.ContentTypes[contentType]
+
+	if !ok {
+		return "", ErrorDocumentContentType
+	}
+
+	return ext, nil
+}
+
+// Decision is the record of the document status change
+type Decision struct {
+	Status    string    `json:"status"`
+	Reason    string    `json:"reason,omitempty"`
+	UserId    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Document
+type Document struct {
+	Type        string      `json:"type"`
+	FileName    string      `json:"file_name"`
+	ContentType string      `json:"content_type"`
+	Size        int64       `json:"size"`
+	Status      string      `json:"status"`
+	Reason      string      `json:"reason,omitempty"`
+	UploadedBy  string      `json:"uploaded_by"`
+	UploadedAt  time.Time   `json:"uploaded_at"`
+	History     []*Decision `json:"history"`
+}
+
+// CanBeReplaced returns true if the merchant can upload a new file for the document
+func (d *Document) CanBeReplaced() bool {
+	return d.Status == StatusPending || d.Status == StatusRejected
+}
+
+// Decide changes the document status by the reviewer and records the decision in the document history
+func (d *Document) Decide(status, reason, userId string) error {
+	if status == StatusRejected && reason == "" {
+		return ErrorDocumentRejectReasonNeeded
+	}
+
+	allowed := false
+
+	for _, from := range allowedTransitions[status] {
+		if d.Status == from {
+			allowed = true
+			break
+		}
+	}
+
+	if !allowed {
+		return ErrorDocumentStatusTransition
+	}
+
+	d.Status = status
+	d.Reason = reason
+	d.History = append(d.History, &Decision{
+		Status:    status,
+		Reason:    reason,
+		UserId:    userId,
+		CreatedAt: time.Now(),
+	})
+
+	return nil
+}
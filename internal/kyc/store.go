@@ -0,0 +1,206 @@
+package kyc
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	awsWrapper "github.com/paysuper/paysuper-aws-manager"
+	"github.com/paysuper/paysuper-management-api/internal/storage"
+	"io"
+	"time"
+)
+
+const (
+	manifestFileMask = "kyc/%s/documents.json"
+	documentFileMask = "kyc/%s/%s/%s.%s"
+)
+
+// Store keeps the merchant documents and their manifest in the agreement bucket.
+// The manifest is the JSON list of the merchant documents with their statuses.
+// Every uploaded file gets the own key, the file replaced in the manifest is deleted.
+type Store struct {
+	files   *storage.Store
+	deleter storage.Deleter
+}
+
+// NewStore
+func NewStore(awsManager awsWrapper.AwsManagerInterface, deleter storage.Deleter) *Store {
+	return &Store{files: storage.New(awsManager), deleter: deleter}
+}
+
+// List returns all uploaded documents of the merchant
+func (s *Store) List(ctx context.Context, merchantId string) ([]*Document, error) {
+	documents := make([]*Document, 0)
+
+	if _, err := s.files.Load(ctx, fmt.Sprintf(manifestFileMask, merchantId), &documents); err != nil {
+		return nil, err
+	}
+
+	return documents, nil
+}
+
+// Get returns the merchant document by the document type
+func (s *Store) Get(ctx context.Context, merchantId, documentType string) (*Document, error) {
+	documents, err := s.List(ctx, merchantId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	document := findDocument(documents, documentType)
+
+	if document == nil {
+		return nil, ErrorDocumentNotFound
+	}
+
+	return document, nil
+}
+
+// Upload stores the file of the document and resets the document status to pending
+func (s *Store) Upload(
+	ctx context.Context,
+	merchantId, documentType, contentType string,
+	size int64,
+	body io.Reader,
+	userId string,
+) (*Document, error) {
+	rule, err := GetRule(documentType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ext, err := rule.Check(contentType, size)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the file is uploaded before the manifest is locked, so the slow upload doesn't hold the manifest
+	fileName := fmt.Sprintf(documentFileMask, merchantId, documentType, uuid.New().String(), ext)
+
+	if err = s.files.Upload(ctx, fileName, body); err != nil {
+		return nil, err
+	}
+
+	superseded := ""
+	document := &Document{}
+	err = s.updateManifest(ctx, merchantId, func(documents []*Document) ([]*Document, error) {
+		document = findDocument(documents, documentType)
+
+		if document == nil {
+			document = &Document{Type: documentType}
+			documents = append(documents, document)
+		} else if !document.CanBeReplaced() {
+			return nil, ErrorDocumentLocked
+		}
+
+		superseded = document.FileName
+		document.FileName = fileName
+		document.ContentType = contentType
+		document.Size = size
+		document.Status = StatusPending
+		document.Reason = ""
+		document.UploadedBy = userId
+		document.UploadedAt = time.Now()
+		document.History = append(document.History, &Decision{
+			Status:    StatusPending,
+			UserId:    userId,
+			CreatedAt: document.UploadedAt,
+		})
+
+		return documents, nil
+	})
+
+	if err != nil {
+		s.remove(ctx, fileName)
+		return nil, err
+	}
+
+	if superseded != "" && superseded != fileName {
+		s.remove(ctx, superseded)
+	}
+
+	return document, nil
+}
+
+// Download saves the document file to the new temporary file and returns the path to the file.
+// The caller removes the temporary file when it's served.
+func (s *Store) Download(ctx context.Context, merchantId, documentType string) (string, *Document, error) {
+	document, err := s.Get(ctx, merchantId, documentType)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	filePath, err := s.files.Download(ctx, document.FileName)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	return filePath, document, nil
+}
+
+// Decide changes the status of the merchant document by the reviewer
+func (s *Store) Decide(ctx context.Context, merchantId, documentType, status, reason, userId string) (*Document, error) {
+	document := &Document{}
+	err := s.updateManifest(ctx, merchantId, func(documents []*Document) ([]*Document, error) {
+		document = findDocument(documents, documentType)
+
+		if document == nil {
+			return nil, ErrorDocumentNotFound
+		}
+
+		if err := document.Decide(status, reason, userId); err != nil {
+			return nil, err
+		}
+
+		return documents, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
+
+// updateManifest changes the manifest of the merchant under the lock of the manifest file
+func (s *Store) updateManifest(
+	ctx context.Context,
+	merchantId string,
+	change func(documents []*Document) ([]*Document, error),
+) error {
+	documents := make([]*Document, 0)
+
+	return s.files.Update(ctx, fmt.Sprintf(manifestFileMask, merchantId), &documents, func(found bool) error {
+		changed, err := change(documents)
+
+		if err != nil {
+			return err
+		}
+
+		documents = changed
+
+		return nil
+	})
+}
+
+// remove deletes the file which isn't referenced by the manifest.
+// The left file isn't served to anyone, so the failed deletion isn't the error of the upload.
+func (s *Store) remove(ctx context.Context, fileName string) {
+	if err := s.deleter.Delete(ctx, fileName); err != nil {
+		return
+	}
+}
+
+func findDocument(documents []*Document, documentType string) *Document {
+	for _, document := range documents {
+		if document.Type == documentType {
+			return document
+		}
+	}
+
+	return nil
+}
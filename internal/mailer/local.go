@@ -0,0 +1,143 @@
+package mailer
+
+import (
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// LocalMessage is the email received by the local smtp server
+type LocalMessage struct {
+	From string
+	To   []string
+	Data []byte
+}
+
+// LocalServer is the smtp stand-in which keeps the received emails in memory.
+// It is used in tests and local environments instead of the real smtp server.
+type LocalServer struct {
+	listener net.Listener
+	mx       sync.RWMutex
+	messages []*LocalMessage
+}
+
+// NewLocalServer starts the server on the random local port
+func NewLocalServer() (*LocalServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		return nil, err
+	}
+
+	s := &LocalServer{listener: listener}
+	go s.serve()
+
+	return s, nil
+}
+
+// Options returns the options of the mailer sending the emails to the server
+func (s *LocalServer) Options(from string) Options {
+	addr := s.listener.Addr().(*net.TCPAddr)
+
+	return Options{Host: addr.IP.String(), Port: addr.Port, From: from}
+}
+
+// Messages returns the received emails
+func (s *LocalServer) Messages() []*LocalMessage {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	messages := make([]*LocalMessage, len(s.messages))
+	copy(messages, s.messages)
+
+	return messages
+}
+
+// Close stops the server
+func (s *LocalServer) Close() error {
+	return s.listener.Close()
+}
+
+func (s *LocalServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+
+		if err != nil {
+			return
+		}
+
+		go s.handle(textproto.NewConn(conn))
+	}
+}
+
+func (s *LocalServer) handle(conn *textproto.Conn) {
+	defer func() {
+		if err := conn.Close(); err != nil {
+			return
+		}
+	}()
+
+	msg := &LocalMessage{}
+
+	if err := conn.PrintfLine("220 localhost ESMTP"); err != nil {
+		return
+	}
+
+	for {
+		line, err := conn.ReadLine()
+
+		if err != nil {
+			return
+		}
+
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		reply := "250 OK"
+
+		switch command {
+		case "EHLO", "HELO":
+			reply = "250 localhost"
+		case "MAIL":
+			msg = &LocalMessage{From: getLocalAddress(line)}
+		case "RCPT":
+			msg.To = append(msg.To, getLocalAddress(line))
+		case "DATA":
+			if err = conn.PrintfLine("354 End data with <CR><LF>.<CR><LF>"); err != nil {
+				return
+			}
+
+			if msg.Data, err = conn.ReadDotBytes(); err != nil {
+				return
+			}
+
+			s.mx.Lock()
+			s.messages = append(s.messages, msg)
+			s.mx.Unlock()
+
+			msg = &LocalMessage{}
+		case "RSET":
+			msg = &LocalMessage{}
+		case "NOOP":
+		case "QUIT":
+			_ = conn.PrintfLine("221 Bye")
+			return
+		default:
+			reply = "502 Command not implemented"
+		}
+
+		if err = conn.PrintfLine(reply); err != nil {
+			return
+		}
+	}
+}
+
+func getLocalAddress(line string) string {
+	start := strings.Index(line, "<")
+	end := strings.LastIndex(line, ">")
+
+	if start < 0 || end < start {
+		return ""
+	}
+
+	return line[start+1 : end]
+}
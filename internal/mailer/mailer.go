@@ -0,0 +1,218 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+)
+
+const (
+	base64LineLength = 76
+	timeoutDefault   = 30 * time.Second
+)
+
+var (
+	ErrorRecipientEmpty = errors.New("email recipient is empty")
+	ErrorSenderEmpty    = errors.New("email sender is empty")
+)
+
+// Options of the smtp server used to send the emails
+type Options struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	Timeout  time.Duration
+}
+
+// Attachment is the file attached to the email
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Message is the html email
+type Message struct {
+	To          string
+	Subject     string
+	Html        string
+	Attachments []*Attachment
+}
+
+// Mailer sends the emails by the smtp server.
+// STARTTLS is used when the server supports it, the authentication is used when the username is set.
+type Mailer struct {
+	opts Options
+}
+
+// NewMailer
+func NewMailer(opts Options) *Mailer {
+	if opts.Timeout <= 0 {
+		opts.Timeout = timeoutDefault
+	}
+
+	return &Mailer{opts: opts}
+}
+
+// Send delivers the message to the smtp server
+func (m *Mailer) Send(ctx context.Context, msg *Message) error {
+	if msg.To == "" {
+		return ErrorRecipientEmpty
+	}
+
+	if m.opts.From == "" {
+		return ErrorSenderEmpty
+	}
+
+	data, err := m.build(msg)
+
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.opts.Timeout)
+	defer cancel()
+
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(m.opts.Host, strconv.Itoa(m.opts.Port)))
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := conn.Close(); err != nil {
+			return
+		}
+	}()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err = conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+
+	client, err := smtp.NewClient(conn, m.opts.Host)
+
+	if err != nil {
+		return err
+	}
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err = client.StartTLS(&tls.Config{ServerName: m.opts.Host}); err != nil {
+			return err
+		}
+	}
+
+	if m.opts.Username != "" {
+		if err = client.Auth(smtp.PlainAuth("", m.opts.Username, m.opts.Password, m.opts.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err = client.Mail(m.opts.From); err != nil {
+		return err
+	}
+
+	if err = client.Rcpt(msg.To); err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = w.Write(data); err != nil {
+		return err
+	}
+
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// build returns the mime message with the html body and the attachments
+func (m *Mailer) build(msg *Message) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	writer := multipart.NewWriter(buf)
+
+	header := fmt.Sprintf(
+		"From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=%s\r\n\r\n",
+		m.opts.From,
+		msg.To,
+		mime.QEncoding.Encode("utf-8", msg.Subject),
+		time.Now().Format(time.RFC1123Z),
+		writer.Boundary(),
+	)
+	out := bytes.NewBufferString(header)
+
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/html; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	qp := quotedprintable.NewWriter(part)
+
+	if _, err = qp.Write([]byte(msg.Html)); err != nil {
+		return nil, err
+	}
+
+	if err = qp.Close(); err != nil {
+		return nil, err
+	}
+
+	for _, attachment := range msg.Attachments {
+		part, err = writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {attachment.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name})},
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		encoded := base64.StdEncoding.EncodeToString(attachment.Data)
+
+		for i := 0; i < len(encoded); i += base64LineLength {
+			end := i + base64LineLength
+
+			if end > len(encoded) {
+				end = len(encoded)
+			}
+
+			if _, err = part.Write([]byte(encoded[i:end] + "\r\n")); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+
+	out.Write(buf.Bytes())
+
+	return out.Bytes(), nil
+}
@@ -0,0 +1,83 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"testing"
+)
+
+func TestMailer_Send(t *testing.T) {
+	server, err := NewLocalServer()
+	require.NoError(t, err)
+	defer server.Close()
+
+	m := NewMailer(server.Options("noreply@paysuper.test"))
+	err = m.Send(context.Background(), &Message{
+		To:      "customer@paysuper.test",
+		Subject: "Квитанция",
+		Html:    "<p>receipt</p>",
+		Attachments: []*Attachment{
+			{Name: "receipt.pdf", ContentType: "application/pdf", Data: []byte("%PDF-1.4")},
+		},
+	})
+	require.NoError(t, err)
+
+	messages := server.Messages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, "noreply@paysuper.test", messages[0].From)
+	assert.Equal(t, []string{"customer@paysuper.test"}, messages[0].To)
+
+	msg, err := mail.ReadMessage(bytes.NewReader(messages[0].Data))
+	require.NoError(t, err)
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "Квитанция", subject)
+
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+
+	part, err := reader.NextPart()
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(part)
+	require.NoError(t, err)
+	assert.Equal(t, "<p>receipt</p>", string(body))
+
+	part, err = reader.NextPart()
+	require.NoError(t, err)
+	assert.Equal(t, "receipt.pdf", part.FileName())
+	body, err = ioutil.ReadAll(part)
+	require.NoError(t, err)
+	data, err := base64.StdEncoding.DecodeString(string(bytes.Replace(body, []byte("\r\n"), nil, -1)))
+	require.NoError(t, err)
+	assert.Equal(t, "%PDF-1.4", string(data))
+}
+
+func TestMailer_Send_Error(t *testing.T) {
+	server, err := NewLocalServer()
+	require.NoError(t, err)
+
+	opts := server.Options("noreply@paysuper.test")
+	m := NewMailer(opts)
+
+	err = m.Send(context.Background(), &Message{Subject: "receipt"})
+	assert.Equal(t, ErrorRecipientEmpty, err)
+
+	err = NewMailer(server.Options("")).Send(context.Background(), &Message{To: "customer@paysuper.test"})
+	assert.Equal(t, ErrorSenderEmpty, err)
+
+	require.NoError(t, server.Close())
+
+	err = m.Send(context.Background(), &Message{To: "customer@paysuper.test"})
+	assert.Error(t, err)
+	assert.Empty(t, server.Messages())
+}
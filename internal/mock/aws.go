@@ -0,0 +1,85 @@
+package mock
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	awsWrapper "github.com/paysuper/paysuper-aws-manager"
+	awsWrapperMocks "github.com/paysuper/paysuper-aws-manager/pkg/mocks"
+	"github.com/stretchr/testify/mock"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+// NewAwsManagerFilesMock returns the aws manager keeping the uploaded files in the map and downloading them back.
+// The map is changed under the lock, so the files may be uploaded by the background jobs.
+func NewAwsManagerFilesMock(files map[string][]byte) *awsWrapperMocks.AwsManagerInterface {
+	mx := &sync.Mutex{}
+	awsManager := &awsWrapperMocks.AwsManagerInterface{}
+	awsManager.On("Upload", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			in := args.Get(1).(*awsWrapper.UploadInput)
+			data, err := ioutil.ReadAll(in.Body)
+			if err != nil {
+				panic(err)
+			}
+			mx.Lock()
+			files[in.FileName] = data
+			mx.Unlock()
+		}).
+		Return(&s3manager.UploadOutput{}, nil)
+	awsManager.On("Download", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(
+			func(ctx context.Context, filePath string, in *awsWrapper.DownloadInput, opts ...func(*s3manager.Downloader)) int64 {
+				mx.Lock()
+				data, ok := files[in.FileName]
+				mx.Unlock()
+				if !ok {
+					return 0
+				}
+				if err := ioutil.WriteFile(filePath, data, 0644); err != nil {
+					panic(err)
+				}
+				return int64(len(data))
+			},
+			func(ctx context.Context, filePath string, in *awsWrapper.DownloadInput, opts ...func(*s3manager.Downloader)) error {
+				mx.Lock()
+				_, ok := files[in.FileName]
+				mx.Unlock()
+				if !ok {
+					return awserr.New(s3.ErrCodeNoSuchKey, "key not found", nil)
+				}
+				return nil
+			},
+		)
+
+	return awsManager
+}
+
+// BucketMock records the deleted files of the bucket
+type BucketMock struct {
+	mx      sync.Mutex
+	Deleted []string
+	// DeletedOlder are the prefix*suffix masks of the files deleted by the age and Before is the age of the last one
+	DeletedOlder []string
+	Before       time.Time
+}
+
+func (m *BucketMock) Delete(ctx context.Context, fileName string) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.Deleted = append(m.Deleted, fileName)
+
+	return nil
+}
+
+func (m *BucketMock) DeleteOlder(ctx context.Context, prefix, suffix string, before time.Time) (int, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.DeletedOlder = append(m.DeletedOlder, prefix+"*"+suffix)
+	m.Before = before
+
+	return 0, nil
+}
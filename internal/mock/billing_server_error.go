@@ -1095,6 +1095,10 @@ func (s *BillingServerErrorMock) DeleteSavedCard(ctx context.Context, in *grpc.D
 	panic("implement me")
 }
 
+func (s *BillingServerErrorMock) DeserializeCookie(ctx context.Context, in *grpc.DeserializeCookieRequest, opts ...client.CallOption) (*grpc.DeserializeCookieResponse, error) {
+	panic("implement me")
+}
+
 func (s *BillingServerErrorMock) SetMerchantOperatingCompany(ctx context.Context, in *grpc.SetMerchantOperatingCompanyRequest, opts ...client.CallOption) (*grpc.SetMerchantOperatingCompanyResponse, error) {
 	panic("implement me")
 }
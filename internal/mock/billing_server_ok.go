@@ -468,6 +468,7 @@ func (s *BillingServerOkMock) CreateToken(
 ) (*grpc.TokenResponse, error) {
 	return &grpc.TokenResponse{
 		Status: pkg.ResponseStatusOk,
+		Token:  bson.NewObjectId().Hex(),
 	}, nil
 }
 
@@ -518,7 +519,7 @@ func (s *BillingServerOkMock) IsOrderCanBePaying(
 ) (*grpc.IsOrderCanBePayingResponse, error) {
 	return &grpc.IsOrderCanBePayingResponse{
 		Status: pkg.ResponseStatusOk,
-		Item:   &billing.Order{},
+		Item:   &billing.Order{Uuid: uuid.New().String()},
 	}, nil
 }
 
@@ -1324,6 +1325,10 @@ func (s *BillingServerOkMock) DeleteSavedCard(ctx context.Context, in *grpc.Dele
 	panic("implement me")
 }
 
+func (s *BillingServerOkMock) DeserializeCookie(ctx context.Context, in *grpc.DeserializeCookieRequest, opts ...client.CallOption) (*grpc.DeserializeCookieResponse, error) {
+	panic("implement me")
+}
+
 func (s *BillingServerOkMock) SetMerchantOperatingCompany(ctx context.Context, in *grpc.SetMerchantOperatingCompanyRequest, opts ...client.CallOption) (*grpc.SetMerchantOperatingCompanyResponse, error) {
 	panic("implement me")
 }
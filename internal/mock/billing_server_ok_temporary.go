@@ -1034,6 +1034,10 @@ func (s *BillingServerOkTemporaryMock) DeleteSavedCard(ctx context.Context, in *
 	panic("implement me")
 }
 
+func (s *BillingServerOkTemporaryMock) DeserializeCookie(ctx context.Context, in *grpc.DeserializeCookieRequest, opts ...client.CallOption) (*grpc.DeserializeCookieResponse, error) {
+	panic("implement me")
+}
+
 func (s *BillingServerOkTemporaryMock) SetMerchantOperatingCompany(ctx context.Context, in *grpc.SetMerchantOperatingCompanyRequest, opts ...client.CallOption) (*grpc.SetMerchantOperatingCompanyResponse, error) {
 	panic("implement me")
 }
@@ -977,6 +977,10 @@ func (s *BillingServerSystemErrorMock) DeleteSavedCard(ctx context.Context, in *
 	panic("implement me")
 }
 
+func (s *BillingServerSystemErrorMock) DeserializeCookie(ctx context.Context, in *grpc.DeserializeCookieRequest, opts ...client.CallOption) (*grpc.DeserializeCookieResponse, error) {
+	panic("implement me")
+}
+
 func (s *BillingServerSystemErrorMock) SetMerchantOperatingCompany(ctx context.Context, in *grpc.SetMerchantOperatingCompanyRequest, opts ...client.CallOption) (*grpc.SetMerchantOperatingCompanyResponse, error) {
 	panic("implement me")
 }
@@ -0,0 +1,177 @@
+package offboarding
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	StepCheckPreconditions   = "check_preconditions"
+	StepUnpublishKeyProducts = "unpublish_key_products"
+	StepDeactivateProjects   = "deactivate_projects"
+	StepRevokeUsers          = "revoke_users"
+	StepArchiveAgreements    = "archive_agreements"
+	StepCloseAccount         = "close_account"
+
+	StepStatusPending   = "pending"
+	StepStatusCompleted = "completed"
+	StepStatusFailed    = "failed"
+
+	StatusInProgress = "in_progress"
+	StatusBlocked    = "blocked"
+	StatusFailed     = "failed"
+	StatusCompleted  = "completed"
+)
+
+var (
+	ErrorWorkflowNotFound  = errors.New("merchant offboarding not found")
+	ErrorWorkflowRunning   = errors.New("merchant offboarding is in progress already")
+	ErrorWorkflowTakenOver = errors.New("merchant offboarding is taken over by another run")
+
+	// LeaseTtl is the time the run holds the workflow without saving it, after that the workflow
+	// is treated as interrupted and may be resumed by another run
+	LeaseTtl = 15 * time.Minute
+
+	// Steps are the offboarding steps in the order of the execution
+	Steps = []string{
+		StepCheckPreconditions,
+		StepUnpublishKeyProducts,
+		StepDeactivateProjects,
+		StepRevokeUsers,
+		StepArchiveAgreements,
+		StepCloseAccount,
+	}
+)
+
+// Step is the state of the single offboarding step. Items are the identifiers of the processed objects.
+type Step struct {
+	Name      string     `json:"name"`
+	Status    string     `json:"status"`
+	Items     []string   `json:"items,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	Attempts  int        `json:"attempts"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// Workflow is the offboarding of the merchant account. The completed steps are never repeated,
+// so the failed or blocked workflow is resumed from the first not completed step.
+// The single run at a time holds the workflow, the run is identified by RunId until its lease expires.
+type Workflow struct {
+	MerchantId  string     `json:"merchant_id"`
+	Status      string     `json:"status"`
+	RunId       string     `json:"run_id,omitempty"`
+	LeaseUntil  *time.Time `json:"lease_until,omitempty"`
+	Reason      string     `json:"reason"`
+	Blockers    []string   `json:"blockers,omitempty"`
+	Steps       []*Step    `json:"steps"`
+	CreatedBy   string     `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// NewWorkflow
+func NewWorkflow(merchantId, userId, reason string) *Workflow {
+	now := time.Now().UTC()
+	w := &Workflow{
+		MerchantId: merchantId,
+		Status:     StatusInProgress,
+		Reason:     reason,
+		Steps:      make([]*Step, 0, len(Steps)),
+		CreatedBy:  userId,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	for _, name := range Steps {
+		w.Steps = append(w.Steps, &Step{Name: name, Status: StepStatusPending})
+	}
+
+	return w
+}
+
+// NextStep returns the first not completed step or nil when all steps are completed
+func (w *Workflow) NextStep() *Step {
+	for _, step := range w.Steps {
+		if step.Status != StepStatusCompleted {
+			return step
+		}
+	}
+
+	return nil
+}
+
+// IsRunning checks whether the workflow is held by the run whose lease isn't expired
+func (w *Workflow) IsRunning(now time.Time) bool {
+	return w.Status == StatusInProgress && w.LeaseUntil != nil && now.Before(*w.LeaseUntil)
+}
+
+// Resume starts the next run of the workflow. The preconditions are checked again before the rest of the steps,
+// the merchant may get the new payments or refunds since the last run.
+func (w *Workflow) Resume(now time.Time) {
+	if w.Status == StatusCompleted {
+		return
+	}
+
+	for _, step := range w.Steps {
+		if step.Name == StepCheckPreconditions {
+			step.Status = StepStatusPending
+		}
+	}
+
+	w.Status = StatusInProgress
+	w.Blockers = nil
+	w.RunId = uuid.New().String()
+	w.UpdatedAt = now.UTC()
+	w.Extend(now)
+}
+
+// Extend prolongs the lease of the run, the lease is released when the run is finished
+func (w *Workflow) Extend(now time.Time) {
+	if w.Status != StatusInProgress {
+		w.LeaseUntil = nil
+		return
+	}
+
+	leaseUntil := now.Add(LeaseTtl).UTC()
+	w.LeaseUntil = &leaseUntil
+}
+
+// Complete marks the step as completed, the workflow is completed with its last step
+func (w *Workflow) Complete(step *Step, items []string) {
+	now := w.touch(step)
+	step.Status = StepStatusCompleted
+	step.Items = items
+	step.Error = ""
+
+	if w.NextStep() == nil {
+		w.Status = StatusCompleted
+		w.CompletedAt = &now
+	}
+}
+
+// Fail marks the step and the workflow as failed
+func (w *Workflow) Fail(step *Step, message string) {
+	w.touch(step)
+	step.Status = StepStatusFailed
+	step.Error = message
+	w.Status = StatusFailed
+}
+
+// Block stops the workflow on the step until the blockers are resolved
+func (w *Workflow) Block(step *Step, blockers []string) {
+	w.touch(step)
+	step.Status = StepStatusPending
+	w.Status = StatusBlocked
+	w.Blockers = blockers
+}
+
+func (w *Workflow) touch(step *Step) time.Time {
+	now := time.Now().UTC()
+	step.Attempts++
+	step.UpdatedAt = &now
+	w.UpdatedAt = now
+
+	return now
+}
@@ -0,0 +1,78 @@
+package offboarding
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestWorkflow_Steps(t *testing.T) {
+	w := NewWorkflow("merchant_id", "user_id", "closed by request")
+	require.Len(t, w.Steps, len(Steps))
+	assert.Equal(t, StatusInProgress, w.Status)
+
+	step := w.NextStep()
+	require.NotNil(t, step)
+	assert.Equal(t, StepCheckPreconditions, step.Name)
+
+	w.Block(step, []string{"balance"})
+	assert.Equal(t, StatusBlocked, w.Status)
+	assert.Equal(t, []string{"balance"}, w.Blockers)
+	assert.Equal(t, StepStatusPending, step.Status)
+	assert.Equal(t, 1, step.Attempts)
+
+	w.Resume(time.Now())
+	assert.Equal(t, StatusInProgress, w.Status)
+	assert.Empty(t, w.Blockers)
+	assert.Equal(t, step, w.NextStep())
+
+	w.Complete(step, nil)
+	step = w.NextStep()
+	assert.Equal(t, StepUnpublishKeyProducts, step.Name)
+
+	w.Fail(step, "error")
+	assert.Equal(t, StatusFailed, w.Status)
+	assert.Equal(t, StepStatusFailed, step.Status)
+	assert.Equal(t, "error", step.Error)
+
+	// the preconditions are checked again before the failed step is repeated
+	w.Resume(time.Now())
+	assert.Equal(t, StepCheckPreconditions, w.NextStep().Name)
+	w.Complete(w.NextStep(), nil)
+	assert.Equal(t, step, w.NextStep())
+
+	for step = w.NextStep(); step != nil; step = w.NextStep() {
+		w.Complete(step, []string{"id"})
+	}
+
+	assert.Equal(t, StatusCompleted, w.Status)
+	assert.NotNil(t, w.CompletedAt)
+	assert.Empty(t, w.Steps[1].Error)
+	assert.Equal(t, 2, w.Steps[1].Attempts)
+
+	w.Resume(time.Now())
+	assert.Equal(t, StatusCompleted, w.Status)
+}
+
+func TestWorkflow_Lease(t *testing.T) {
+	now := time.Now()
+	w := NewWorkflow("merchant_id", "user_id", "closed by request")
+	assert.False(t, w.IsRunning(now))
+
+	w.Resume(now)
+	runId := w.RunId
+	require.NotEmpty(t, runId)
+	require.NotNil(t, w.LeaseUntil)
+	assert.True(t, w.IsRunning(now))
+	assert.False(t, w.IsRunning(now.Add(LeaseTtl+time.Second)))
+
+	// the interrupted run is resumed with the new run
+	w.Resume(now.Add(LeaseTtl + time.Second))
+	assert.NotEqual(t, runId, w.RunId)
+
+	w.Block(w.NextStep(), []string{"balance"})
+	w.Extend(now)
+	assert.Nil(t, w.LeaseUntil)
+	assert.False(t, w.IsRunning(now))
+}
@@ -0,0 +1,85 @@
+package offboarding
+
+import (
+	"context"
+	"fmt"
+	awsWrapper "github.com/paysuper/paysuper-aws-manager"
+	"github.com/paysuper/paysuper-management-api/internal/storage"
+	"time"
+)
+
+const (
+	workflowFileMask = "merchant_offboarding/%s/workflow.json"
+)
+
+// Store keeps the offboarding workflow of every merchant as the JSON file in the reporter bucket
+type Store struct {
+	files *storage.Store
+}
+
+// NewStore
+func NewStore(awsManager awsWrapper.AwsManagerInterface) *Store {
+	return &Store{files: storage.New(awsManager)}
+}
+
+// Get returns the offboarding workflow of the merchant
+func (s *Store) Get(ctx context.Context, merchantId string) (*Workflow, error) {
+	w := &Workflow{}
+	found, err := s.files.Load(ctx, fmt.Sprintf(workflowFileMask, merchantId), w)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, ErrorWorkflowNotFound
+	}
+
+	return w, nil
+}
+
+// Start creates the offboarding workflow of the merchant or resumes the blocked, failed or interrupted one
+// and returns it with the identifier of the new run. The completed workflow is returned as is without the run.
+// The workflow is changed under the lock shared by all replicas, so only one run of the merchant offboarding
+// is started at a time.
+func (s *Store) Start(ctx context.Context, merchantId, userId, reason string) (*Workflow, error) {
+	w := &Workflow{}
+	err := s.files.Update(ctx, fmt.Sprintf(workflowFileMask, merchantId), w, func(found bool) error {
+		now := time.Now()
+
+		if !found {
+			*w = *NewWorkflow(merchantId, userId, reason)
+		}
+
+		if w.IsRunning(now) {
+			return ErrorWorkflowRunning
+		}
+
+		w.Resume(now)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Save replaces the offboarding workflow of the run and prolongs the lease of the run.
+// The run whose lease was expired and taken over by another run can't save the workflow anymore.
+func (s *Store) Save(ctx context.Context, w *Workflow) error {
+	saved := &Workflow{}
+
+	return s.files.Update(ctx, fmt.Sprintf(workflowFileMask, w.MerchantId), saved, func(found bool) error {
+		if !found || saved.RunId != w.RunId {
+			return ErrorWorkflowTakenOver
+		}
+
+		w.Extend(time.Now())
+		*saved = *w
+
+		return nil
+	})
+}
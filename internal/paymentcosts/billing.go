@@ -0,0 +1,181 @@
+package paymentcosts
+
+import (
+	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
+)
+
+// FromChannelSystem
+func FromChannelSystem(item *billing.PaymentChannelCostSystem) *Row {
+	return &Row{
+		Type:              TypeChannelSystem,
+		Method:            item.Name,
+		Region:            item.Region,
+		Country:           item.Country,
+		Percent:           item.Percent,
+		FixAmount:         item.FixAmount,
+		FixAmountCurrency: item.FixAmountCurrency,
+		item:              item,
+	}
+}
+
+// FromChannelMerchant
+func FromChannelMerchant(item *billing.PaymentChannelCostMerchant) *Row {
+	return &Row{
+		Type:               TypeChannelMerchant,
+		MerchantId:         item.MerchantId,
+		Method:             item.Name,
+		Region:             item.Region,
+		Country:            item.Country,
+		PayoutCurrency:     item.PayoutCurrency,
+		MinAmount:          item.MinAmount,
+		Percent:            item.MethodPercent,
+		FixAmount:          item.MethodFixAmount,
+		FixAmountCurrency:  item.MethodFixAmountCurrency,
+		PsPercent:          item.PsPercent,
+		PsFixedFee:         item.PsFixedFee,
+		PsFixedFeeCurrency: item.PsFixedFeeCurrency,
+		item:               item,
+	}
+}
+
+// FromMoneyBackSystem
+func FromMoneyBackSystem(item *billing.MoneyBackCostSystem) *Row {
+	return &Row{
+		Type:           TypeMoneyBackSystem,
+		Method:         item.Name,
+		Region:         item.Region,
+		Country:        item.Country,
+		PayoutCurrency: item.PayoutCurrency,
+		UndoReason:     item.UndoReason,
+		DaysFrom:       item.DaysFrom,
+		PaymentStage:   item.PaymentStage,
+		Percent:        item.Percent,
+		FixAmount:      item.FixAmount,
+		item:           item,
+	}
+}
+
+// FromMoneyBackMerchant
+func FromMoneyBackMerchant(item *billing.MoneyBackCostMerchant) *Row {
+	return &Row{
+		Type:             TypeMoneyBackMerchant,
+		MerchantId:       item.MerchantId,
+		Method:           item.Name,
+		Region:           item.Region,
+		Country:          item.Country,
+		PayoutCurrency:   item.PayoutCurrency,
+		UndoReason:       item.UndoReason,
+		DaysFrom:         item.DaysFrom,
+		PaymentStage:     item.PaymentStage,
+		Percent:          item.Percent,
+		FixAmount:        item.FixAmount,
+		IsPaidByMerchant: item.IsPaidByMerchant,
+		item:             item,
+	}
+}
+
+// Id returns the billing server identifier of the cost, the new cost has no identifier
+func (r *Row) Id() string {
+	switch item := r.item.(type) {
+	case *billing.PaymentChannelCostSystem:
+		return item.Id
+	case *billing.PaymentChannelCostMerchant:
+		return item.Id
+	case *billing.MoneyBackCostSystem:
+		return item.Id
+	case *billing.MoneyBackCostMerchant:
+		return item.Id
+	}
+
+	return ""
+}
+
+// ChannelSystem returns the billing server cost with the values of the row.
+// The fields of the matched cost not present in the matrix are kept.
+func (r *Row) ChannelSystem() *billing.PaymentChannelCostSystem {
+	item, ok := r.item.(*billing.PaymentChannelCostSystem)
+
+	if !ok {
+		item = &billing.PaymentChannelCostSystem{}
+	}
+
+	item.Name = r.Method
+	item.Region = r.Region
+	item.Country = r.Country
+	item.Percent = r.Percent
+	item.FixAmount = r.FixAmount
+	item.FixAmountCurrency = r.FixAmountCurrency
+
+	return item
+}
+
+// ChannelMerchant returns the billing server cost with the values of the row.
+// The fields of the matched cost not present in the matrix are kept.
+func (r *Row) ChannelMerchant() *billing.PaymentChannelCostMerchant {
+	item, ok := r.item.(*billing.PaymentChannelCostMerchant)
+
+	if !ok {
+		item = &billing.PaymentChannelCostMerchant{}
+	}
+
+	item.MerchantId = r.MerchantId
+	item.Name = r.Method
+	item.Region = r.Region
+	item.Country = r.Country
+	item.PayoutCurrency = r.PayoutCurrency
+	item.MinAmount = r.MinAmount
+	item.MethodPercent = r.Percent
+	item.MethodFixAmount = r.FixAmount
+	item.MethodFixAmountCurrency = r.FixAmountCurrency
+	item.PsPercent = r.PsPercent
+	item.PsFixedFee = r.PsFixedFee
+	item.PsFixedFeeCurrency = r.PsFixedFeeCurrency
+
+	return item
+}
+
+// MoneyBackSystem returns the billing server cost with the values of the row.
+// The fields of the matched cost not present in the matrix are kept.
+func (r *Row) MoneyBackSystem() *billing.MoneyBackCostSystem {
+	item, ok := r.item.(*billing.MoneyBackCostSystem)
+
+	if !ok {
+		item = &billing.MoneyBackCostSystem{}
+	}
+
+	item.Name = r.Method
+	item.Region = r.Region
+	item.Country = r.Country
+	item.PayoutCurrency = r.PayoutCurrency
+	item.UndoReason = r.UndoReason
+	item.DaysFrom = r.DaysFrom
+	item.PaymentStage = r.PaymentStage
+	item.Percent = r.Percent
+	item.FixAmount = r.FixAmount
+
+	return item
+}
+
+// MoneyBackMerchant returns the billing server cost with the values of the row.
+// The fields of the matched cost not present in the matrix are kept.
+func (r *Row) MoneyBackMerchant() *billing.MoneyBackCostMerchant {
+	item, ok := r.item.(*billing.MoneyBackCostMerchant)
+
+	if !ok {
+		item = &billing.MoneyBackCostMerchant{}
+	}
+
+	item.MerchantId = r.MerchantId
+	item.Name = r.Method
+	item.Region = r.Region
+	item.Country = r.Country
+	item.PayoutCurrency = r.PayoutCurrency
+	item.UndoReason = r.UndoReason
+	item.DaysFrom = r.DaysFrom
+	item.PaymentStage = r.PaymentStage
+	item.Percent = r.Percent
+	item.FixAmount = r.FixAmount
+	item.IsPaidByMerchant = r.IsPaidByMerchant
+
+	return item
+}
@@ -0,0 +1,96 @@
+package paymentcosts
+
+const (
+	ActionCreate    = "create"
+	ActionUpdate    = "update"
+	ActionDelete    = "delete"
+	ActionUnchanged = "unchanged"
+)
+
+// Change is the difference of the single cost between the current matrix and the imported one
+type Change struct {
+	Action string `json:"action"`
+	Row    *Row   `json:"cost"`
+	Old    *Row   `json:"old,omitempty"`
+}
+
+// Scope is the part of the matrix replaced by the import: the system costs when the import has them
+// and the costs of the merchants
+type Scope struct {
+	System    bool
+	Merchants []string
+}
+
+// Contains checks whether the cost belongs to the scope
+func (s *Scope) Contains(row *Row) bool {
+	if row.IsMerchant() {
+		return containsString(s.Merchants, row.MerchantId)
+	}
+
+	return s.System
+}
+
+// ImportScope returns the scope of the imported costs: the system costs when the import has any of them
+// and the merchants of the import
+func ImportScope(rows []*Row) *Scope {
+	scope := &Scope{Merchants: make([]string, 0)}
+
+	for _, row := range rows {
+		if !row.IsMerchant() {
+			scope.System = true
+			continue
+		}
+
+		if !containsString(scope.Merchants, row.MerchantId) {
+			scope.Merchants = append(scope.Merchants, row.MerchantId)
+		}
+	}
+
+	return scope
+}
+
+// Diff compares the imported costs with the current ones. The changes of the imported costs go in the import order
+// and keep the imported rows, the imported row gets the billing server cost it updates.
+// With the scope the current costs of the scope missing in the import are deleted.
+func Diff(current, imported []*Row, scope *Scope) []*Change {
+	existing := make(map[string]*Row, len(current))
+
+	for _, row := range current {
+		existing[row.Key()] = row
+	}
+
+	changes := make([]*Change, 0, len(imported))
+	matched := make(map[string]bool, len(imported))
+
+	for _, row := range imported {
+		key := row.Key()
+		old, ok := existing[key]
+		matched[key] = true
+
+		if !ok {
+			changes = append(changes, &Change{Action: ActionCreate, Row: row})
+			continue
+		}
+
+		row.item = old.item
+		change := &Change{Action: ActionUnchanged, Row: row, Old: old}
+
+		if !row.Equal(old) {
+			change.Action = ActionUpdate
+		}
+
+		changes = append(changes, change)
+	}
+
+	if scope == nil {
+		return changes
+	}
+
+	for _, row := range current {
+		if !matched[row.Key()] && scope.Contains(row) {
+			changes = append(changes, &Change{Action: ActionDelete, Old: row})
+		}
+	}
+
+	return changes
+}
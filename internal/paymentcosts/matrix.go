@@ -0,0 +1,458 @@
+package paymentcosts
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"github.com/paysuper/paysuper-management-api/internal/xlsx"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	TypeChannelSystem     = "channel_system"
+	TypeChannelMerchant   = "channel_merchant"
+	TypeMoneyBackSystem   = "money_back_system"
+	TypeMoneyBackMerchant = "money_back_merchant"
+
+	ColumnType               = "type"
+	ColumnMerchantId         = "merchant_id"
+	ColumnMethod             = "method"
+	ColumnRegion             = "region"
+	ColumnCountry            = "country"
+	ColumnPayoutCurrency     = "payout_currency"
+	ColumnMinAmount          = "min_amount"
+	ColumnPercent            = "percent"
+	ColumnFixAmount          = "fix_amount"
+	ColumnFixAmountCurrency  = "fix_amount_currency"
+	ColumnPsPercent          = "ps_percent"
+	ColumnPsFixedFee         = "ps_fixed_fee"
+	ColumnPsFixedFeeCurrency = "ps_fixed_fee_currency"
+	ColumnUndoReason         = "undo_reason"
+	ColumnDaysFrom           = "days_from"
+	ColumnPaymentStage       = "payment_stage"
+	ColumnIsPaidByMerchant   = "is_paid_by_merchant"
+
+	xlsxSheetName = "payment_costs"
+)
+
+var (
+	ErrorFileInvalid   = errors.New("payment costs file must be a csv or xlsx file")
+	ErrorColumnMissing = errors.New("payment costs file misses the required column")
+	ErrorColumnUnknown = errors.New("payment costs file contains the unknown column")
+
+	// Columns are the columns of the cost matrix file in the export order
+	Columns = []string{
+		ColumnType,
+		ColumnMerchantId,
+		ColumnMethod,
+		ColumnRegion,
+		ColumnCountry,
+		ColumnPayoutCurrency,
+		ColumnMinAmount,
+		ColumnPercent,
+		ColumnFixAmount,
+		ColumnFixAmountCurrency,
+		ColumnPsPercent,
+		ColumnPsFixedFee,
+		ColumnPsFixedFeeCurrency,
+		ColumnUndoReason,
+		ColumnDaysFrom,
+		ColumnPaymentStage,
+		ColumnIsPaidByMerchant,
+	}
+	requiredColumns = []string{ColumnType, ColumnMethod, ColumnRegion, ColumnPercent, ColumnFixAmount}
+
+	Types = []string{TypeChannelSystem, TypeChannelMerchant, TypeMoneyBackSystem, TypeMoneyBackMerchant}
+
+	merchantIdRegexp = regexp.MustCompile("^[0-9a-fA-F]{24}$")
+	countryRegexp    = regexp.MustCompile("^[A-Z]{2}$")
+	currencyRegexp   = regexp.MustCompile("^[A-Z]{3}$")
+)
+
+// Row is the single cost of the matrix. The set of the used fields depends on the type of the cost:
+// the channel costs have the fix amount currency and the merchant ones the payment system fees,
+// the money-back costs have the undo reason, the period start in days and the payment stage.
+type Row struct {
+	Type               string  `json:"type"`
+	MerchantId         string  `json:"merchant_id,omitempty"`
+	Method             string  `json:"method"`
+	Region             string  `json:"region"`
+	Country            string  `json:"country"`
+	PayoutCurrency     string  `json:"payout_currency,omitempty"`
+	MinAmount          float64 `json:"min_amount,omitempty"`
+	Percent            float64 `json:"percent"`
+	FixAmount          float64 `json:"fix_amount"`
+	FixAmountCurrency  string  `json:"fix_amount_currency,omitempty"`
+	PsPercent          float64 `json:"ps_percent,omitempty"`
+	PsFixedFee         float64 `json:"ps_fixed_fee,omitempty"`
+	PsFixedFeeCurrency string  `json:"ps_fixed_fee_currency,omitempty"`
+	UndoReason         string  `json:"undo_reason,omitempty"`
+	DaysFrom           int32   `json:"days_from,omitempty"`
+	PaymentStage       int32   `json:"payment_stage,omitempty"`
+	IsPaidByMerchant   bool    `json:"is_paid_by_merchant,omitempty"`
+
+	// item is the billing server cost the row is read from or matched to
+	item interface{}
+}
+
+// Line is the parsed line of the cost matrix file. The line with the invalid value has the name of the column.
+type Line struct {
+	Number int
+	Row    *Row
+	Field  string
+}
+
+// IsMerchant checks whether the cost is the merchant specific one
+func (r *Row) IsMerchant() bool {
+	return r.Type == TypeChannelMerchant || r.Type == TypeMoneyBackMerchant
+}
+
+// IsMoneyBack checks whether the cost is the money-back one
+func (r *Row) IsMoneyBack() bool {
+	return r.Type == TypeMoneyBackSystem || r.Type == TypeMoneyBackMerchant
+}
+
+// Key returns the identity of the cost in the matrix, the matrix can't contain two costs with the same key
+func (r *Row) Key() string {
+	parts := []string{r.Type, strings.ToLower(r.MerchantId), r.Method, r.Region, r.Country}
+
+	switch r.Type {
+	case TypeChannelMerchant:
+		parts = append(parts, r.PayoutCurrency, formatFloat(r.MinAmount))
+	case TypeMoneyBackSystem, TypeMoneyBackMerchant:
+		parts = append(parts, r.PayoutCurrency, r.UndoReason, formatInt(r.DaysFrom), formatInt(r.PaymentStage))
+	}
+
+	return strings.Join(parts, "|")
+}
+
+// Equal compares the values of the costs with the same key
+func (r *Row) Equal(o *Row) bool {
+	return r.Percent == o.Percent &&
+		r.FixAmount == o.FixAmount &&
+		r.FixAmountCurrency == o.FixAmountCurrency &&
+		r.PsPercent == o.PsPercent &&
+		r.PsFixedFee == o.PsFixedFee &&
+		r.PsFixedFeeCurrency == o.PsFixedFeeCurrency &&
+		r.IsPaidByMerchant == o.IsPaidByMerchant
+}
+
+// Sort orders the costs by the type, the merchant, the method and the geography
+func Sort(rows []*Row) {
+	order := make(map[string]int, len(Types))
+
+	for i, t := range Types {
+		order[t] = i
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Type != rows[j].Type {
+			return order[rows[i].Type] < order[rows[j].Type]
+		}
+
+		return rows[i].Key() < rows[j].Key()
+	})
+}
+
+// ReadTable returns the cells of the CSV or XLSX file, the XLSX file is recognized by the content
+func ReadTable(data []byte) ([][]string, error) {
+	if bytes.HasPrefix(data, []byte("PK")) {
+		table, err := xlsx.Read(bytes.NewReader(data), int64(len(data)))
+
+		if err != nil {
+			return nil, ErrorFileInvalid
+		}
+
+		return table, nil
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	table, err := reader.ReadAll()
+
+	if err != nil {
+		return nil, ErrorFileInvalid
+	}
+
+	return table, nil
+}
+
+// Parse returns the costs of the table with the header row. The empty lines are skipped.
+func Parse(table [][]string) ([]*Line, error) {
+	if len(table) <= 0 {
+		return nil, ErrorFileInvalid
+	}
+
+	header := make(map[string]int, len(table[0]))
+
+	for i, column := range table[0] {
+		column = strings.ToLower(strings.TrimSpace(column))
+
+		if column == "" {
+			continue
+		}
+
+		if !containsString(Columns, column) {
+			return nil, ErrorColumnUnknown
+		}
+
+		header[column] = i
+	}
+
+	for _, column := range requiredColumns {
+		if _, ok := header[column]; !ok {
+			return nil, ErrorColumnMissing
+		}
+	}
+
+	lines := make([]*Line, 0, len(table)-1)
+
+	for i, cells := range table[1:] {
+		values := make(map[string]string, len(header))
+		empty := true
+
+		for column, idx := range header {
+			if idx < len(cells) {
+				values[column] = strings.TrimSpace(cells[idx])
+				empty = empty && values[column] == ""
+			}
+		}
+
+		if empty {
+			continue
+		}
+
+		row, field := parseRow(values)
+		lines = append(lines, &Line{Number: i + 1, Row: row, Field: field})
+	}
+
+	return lines, nil
+}
+
+// WriteCsv returns the costs in the format accepted by the import
+func WriteCsv(rows []*Row) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	writer := csv.NewWriter(buf)
+
+	if err := writer.WriteAll(Table(rows)); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// WriteXlsx returns the costs in the format accepted by the import
+func WriteXlsx(rows []*Row) ([]byte, error) {
+	return xlsx.Write(xlsxSheetName, Table(rows))
+}
+
+// Table returns the cells of the costs with the header row
+func Table(rows []*Row) [][]string {
+	table := make([][]string, 0, len(rows)+1)
+	table = append(table, Columns)
+
+	for _, row := range rows {
+		cells := []string{
+			row.Type,
+			row.MerchantId,
+			row.Method,
+			row.Region,
+			row.Country,
+			row.PayoutCurrency,
+			"",
+			formatFloat(row.Percent),
+			formatFloat(row.FixAmount),
+			row.FixAmountCurrency,
+			"",
+			"",
+			row.PsFixedFeeCurrency,
+			row.UndoReason,
+			"",
+			"",
+			"",
+		}
+
+		if row.Type == TypeChannelMerchant {
+			cells[6] = formatFloat(row.MinAmount)
+			cells[10] = formatFloat(row.PsPercent)
+			cells[11] = formatFloat(row.PsFixedFee)
+		}
+
+		if row.IsMoneyBack() {
+			cells[14] = formatInt(row.DaysFrom)
+			cells[15] = formatInt(row.PaymentStage)
+		}
+
+		if row.Type == TypeMoneyBackMerchant {
+			cells[16] = strconv.FormatBool(row.IsPaidByMerchant)
+		}
+
+		table = append(table, cells)
+	}
+
+	return table
+}
+
+// parseRow checks the values of the line and returns the first invalid column
+func parseRow(values map[string]string) (*Row, string) {
+	row := &Row{
+		Type:               strings.ToLower(values[ColumnType]),
+		MerchantId:         strings.ToLower(values[ColumnMerchantId]),
+		Method:             values[ColumnMethod],
+		Region:             values[ColumnRegion],
+		Country:            strings.ToUpper(values[ColumnCountry]),
+		PayoutCurrency:     strings.ToUpper(values[ColumnPayoutCurrency]),
+		FixAmountCurrency:  strings.ToUpper(values[ColumnFixAmountCurrency]),
+		PsFixedFeeCurrency: strings.ToUpper(values[ColumnPsFixedFeeCurrency]),
+		UndoReason:         values[ColumnUndoReason],
+	}
+
+	if !containsString(Types, row.Type) {
+		return row, ColumnType
+	}
+
+	if row.IsMerchant() != merchantIdRegexp.MatchString(row.MerchantId) {
+		return row, ColumnMerchantId
+	}
+
+	if row.Method == "" {
+		return row, ColumnMethod
+	}
+
+	if row.Region == "" {
+		return row, ColumnRegion
+	}
+
+	if row.Country != "" && !countryRegexp.MatchString(row.Country) {
+		return row, ColumnCountry
+	}
+
+	currencies := map[string]string{
+		ColumnPayoutCurrency:     row.PayoutCurrency,
+		ColumnFixAmountCurrency:  row.FixAmountCurrency,
+		ColumnPsFixedFeeCurrency: row.PsFixedFeeCurrency,
+	}
+
+	for _, column := range []string{ColumnPayoutCurrency, ColumnFixAmountCurrency, ColumnPsFixedFeeCurrency} {
+		if currencies[column] != "" && !currencyRegexp.MatchString(currencies[column]) {
+			return row, column
+		}
+	}
+
+	var ok bool
+
+	if row.Percent, ok = parseAmount(values[ColumnPercent], true); !ok {
+		return row, ColumnPercent
+	}
+
+	if row.FixAmount, ok = parseAmount(values[ColumnFixAmount], true); !ok {
+		return row, ColumnFixAmount
+	}
+
+	switch row.Type {
+	case TypeChannelSystem:
+		return row, unexpectedColumn(values, ColumnPayoutCurrency, ColumnMinAmount, ColumnPsPercent, ColumnPsFixedFee,
+			ColumnPsFixedFeeCurrency, ColumnUndoReason, ColumnDaysFrom, ColumnPaymentStage, ColumnIsPaidByMerchant)
+	case TypeChannelMerchant:
+		if row.PayoutCurrency == "" {
+			return row, ColumnPayoutCurrency
+		}
+
+		if row.MinAmount, ok = parseAmount(values[ColumnMinAmount], false); !ok {
+			return row, ColumnMinAmount
+		}
+
+		if row.PsPercent, ok = parseAmount(values[ColumnPsPercent], false); !ok {
+			return row, ColumnPsPercent
+		}
+
+		if row.PsFixedFee, ok = parseAmount(values[ColumnPsFixedFee], false); !ok {
+			return row, ColumnPsFixedFee
+		}
+
+		return row, unexpectedColumn(values, ColumnUndoReason, ColumnDaysFrom, ColumnPaymentStage, ColumnIsPaidByMerchant)
+	}
+
+	if row.PayoutCurrency == "" {
+		return row, ColumnPayoutCurrency
+	}
+
+	if row.UndoReason == "" {
+		return row, ColumnUndoReason
+	}
+
+	if row.DaysFrom, ok = parseCount(values[ColumnDaysFrom]); !ok {
+		return row, ColumnDaysFrom
+	}
+
+	if row.PaymentStage, ok = parseCount(values[ColumnPaymentStage]); !ok {
+		return row, ColumnPaymentStage
+	}
+
+	if row.Type == TypeMoneyBackMerchant && values[ColumnIsPaidByMerchant] != "" {
+		paid, err := strconv.ParseBool(values[ColumnIsPaidByMerchant])
+
+		if err != nil {
+			return row, ColumnIsPaidByMerchant
+		}
+
+		row.IsPaidByMerchant = paid
+	}
+
+	if row.Type == TypeMoneyBackSystem {
+		return row, unexpectedColumn(values, ColumnFixAmountCurrency, ColumnMinAmount, ColumnPsPercent, ColumnPsFixedFee,
+			ColumnPsFixedFeeCurrency, ColumnIsPaidByMerchant)
+	}
+
+	return row, unexpectedColumn(values, ColumnFixAmountCurrency, ColumnMinAmount, ColumnPsPercent, ColumnPsFixedFee,
+		ColumnPsFixedFeeCurrency)
+}
+
+// unexpectedColumn returns the first filled column which isn't used by the type of the cost
+func unexpectedColumn(values map[string]string, columns ...string) string {
+	for _, column := range columns {
+		if values[column] != "" {
+			return column
+		}
+	}
+
+	return ""
+}
+
+func parseAmount(value string, required bool) (float64, bool) {
+	if value == "" {
+		return 0, !required
+	}
+
+	amount, err := strconv.ParseFloat(value, 64)
+
+	return amount, err == nil && amount >= 0
+}
+
+func parseCount(value string) (int32, bool) {
+	count, err := strconv.ParseInt(value, 10, 32)
+
+	return int32(count), err == nil && count >= 0
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func formatInt(value int32) string {
+	return strconv.FormatInt(int64(value), 10)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,185 @@
+package paymentcosts
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+const (
+	matrixTestMerchantId = "5dc3f6c5ad8b8c0001b1e2b1"
+)
+
+func parseTestTable(t *testing.T, csv string) []*Line {
+	table, err := ReadTable([]byte(csv))
+	require.NoError(t, err)
+
+	lines, err := Parse(table)
+	require.NoError(t, err)
+
+	return lines
+}
+
+func TestParse_Types_Ok(t *testing.T) {
+	lines := parseTestTable(t, "Type,Merchant_Id,Method,Region,Country,Payout_Currency,Min_Amount,Percent,Fix_Amount,"+
+		"Fix_Amount_Currency,Ps_Percent,Ps_Fixed_Fee,Ps_Fixed_Fee_Currency,Undo_Reason,Days_From,Payment_Stage,Is_Paid_By_Merchant\n"+
+		"channel_system,,VISA,CIS,az,,,1.5,0.01,usd,,,,,,,\n"+
+		",,,,,,,,,,,,,,,,\n"+
+		"channel_merchant,"+matrixTestMerchantId+",VISA,CIS,AZ,USD,1,2.5,0.2,USD,0.5,0.05,EUR,,,,\n"+
+		"money_back_system,,VISA,CIS,AZ,USD,,3,0,,,,,refund,30,1,\n"+
+		"money_back_merchant,"+matrixTestMerchantId+",VISA,CIS,AZ,USD,,3,0,,,,,chargeback,0,2,true\n")
+
+	require.Len(t, lines, 4)
+
+	for _, line := range lines {
+		assert.Empty(t, line.Field, line.Number)
+	}
+
+	assert.Equal(t, 1, lines[0].Number)
+	assert.Equal(t, "AZ", lines[0].Row.Country)
+	assert.Equal(t, "USD", lines[0].Row.FixAmountCurrency)
+	assert.Equal(t, 3, lines[1].Number)
+	assert.EqualValues(t, 0.05, lines[1].Row.PsFixedFee)
+	assert.EqualValues(t, 1, lines[1].Row.MinAmount)
+	assert.EqualValues(t, 30, lines[2].Row.DaysFrom)
+	assert.True(t, lines[3].Row.IsPaidByMerchant)
+	assert.True(t, lines[3].Row.IsMerchant())
+	assert.True(t, lines[3].Row.IsMoneyBack())
+}
+
+func TestParse_Values_Invalid(t *testing.T) {
+	lines := parseTestTable(t, "type,merchant_id,method,region,country,payout_currency,percent,fix_amount,undo_reason,days_from\n"+
+		"card,,VISA,CIS,AZ,,1,0,,\n"+
+		"channel_system,"+matrixTestMerchantId+",VISA,CIS,AZ,,1,0,,\n"+
+		"channel_merchant,,VISA,CIS,AZ,USD,1,0,,\n"+
+		"channel_system,,,CIS,AZ,,1,0,,\n"+
+		"channel_system,,VISA,CIS,AZE,,1,0,,\n"+
+		"channel_system,,VISA,CIS,AZ,,-1,0,,\n"+
+		"channel_system,,VISA,CIS,AZ,,1,,,\n"+
+		"channel_system,,VISA,CIS,AZ,USD,1,0,,\n"+
+		"channel_merchant,"+matrixTestMerchantId+",VISA,CIS,AZ,,1,0,,\n"+
+		"money_back_system,,VISA,CIS,AZ,USD,1,0,,10\n"+
+		"money_back_system,,VISA,CIS,AZ,USD,1,0,refund,many\n")
+
+	fields := make([]string, 0, len(lines))
+
+	for _, line := range lines {
+		fields = append(fields, line.Field)
+	}
+
+	assert.Equal(t, []string{
+		ColumnType,
+		ColumnMerchantId,
+		ColumnMerchantId,
+		ColumnMethod,
+		ColumnCountry,
+		ColumnPercent,
+		ColumnFixAmount,
+		ColumnPayoutCurrency,
+		ColumnPayoutCurrency,
+		ColumnUndoReason,
+		ColumnDaysFrom,
+	}, fields)
+}
+
+func TestParse_Header_Invalid(t *testing.T) {
+	_, err := Parse([][]string{{"type", "method", "region", "percent"}})
+	assert.Equal(t, ErrorColumnMissing, err)
+
+	_, err = Parse([][]string{{"type", "method", "region", "percent", "fix_amount", "color"}})
+	assert.Equal(t, ErrorColumnUnknown, err)
+
+	_, err = Parse(nil)
+	assert.Equal(t, ErrorFileInvalid, err)
+
+	_, err = ReadTable([]byte("PK not a zip"))
+	assert.Equal(t, ErrorFileInvalid, err)
+}
+
+func TestTable_Parse_RoundTrip(t *testing.T) {
+	rows := []*Row{
+		{Type: TypeMoneyBackMerchant, MerchantId: matrixTestMerchantId, Method: "VISA", Region: "CIS", Country: "AZ",
+			PayoutCurrency: "USD", UndoReason: "chargeback", DaysFrom: 30, PaymentStage: 2, Percent: 3, IsPaidByMerchant: true},
+		{Type: TypeChannelSystem, Method: "VISA", Region: "CIS", Country: "AZ", Percent: 1.5, FixAmount: 0.01, FixAmountCurrency: "USD"},
+		{Type: TypeChannelMerchant, MerchantId: matrixTestMerchantId, Method: "VISA", Region: "CIS", PayoutCurrency: "USD",
+			MinAmount: 0.75, Percent: 2.5, PsPercent: 0.5, PsFixedFee: 0.05, PsFixedFeeCurrency: "EUR"},
+		{Type: TypeMoneyBackSystem, Method: "VISA", Region: "CIS", Country: "AZ", PayoutCurrency: "USD",
+			UndoReason: "refund", PaymentStage: 1, Percent: 3},
+	}
+	Sort(rows)
+
+	assert.Equal(t, TypeChannelSystem, rows[0].Type)
+	assert.Equal(t, TypeMoneyBackMerchant, rows[3].Type)
+
+	for _, write := range []func([]*Row) ([]byte, error){WriteCsv, WriteXlsx} {
+		data, err := write(rows)
+		require.NoError(t, err)
+
+		table, err := ReadTable(data)
+		require.NoError(t, err)
+
+		lines, err := Parse(table)
+		require.NoError(t, err)
+		require.Len(t, lines, len(rows))
+
+		for i, line := range lines {
+			assert.Empty(t, line.Field)
+			assert.Equal(t, rows[i], line.Row)
+		}
+	}
+}
+
+func TestDiff_Scope(t *testing.T) {
+	current := []*Row{
+		{Type: TypeChannelSystem, Method: "VISA", Region: "CIS", Country: "AZ", Percent: 1.5, item: "system"},
+		{Type: TypeChannelSystem, Method: "MASTERCARD", Region: "CIS", Country: "AZ", Percent: 1.5},
+		{Type: TypeChannelMerchant, MerchantId: matrixTestMerchantId, Method: "VISA", Region: "CIS", PayoutCurrency: "USD"},
+		{Type: TypeChannelMerchant, MerchantId: "5dc3f6c5ad8b8c0001b1e2b2", Method: "VISA", Region: "CIS", PayoutCurrency: "USD"},
+	}
+	imported := []*Row{
+		{Type: TypeChannelSystem, Method: "VISA", Region: "CIS", Country: "AZ", Percent: 2},
+		{Type: TypeChannelSystem, Method: "VISA", Region: "CIS", Country: "AM", Percent: 2},
+		{Type: TypeChannelMerchant, MerchantId: matrixTestMerchantId, Method: "VISA", Region: "CIS", PayoutCurrency: "USD"},
+	}
+
+	scope := ImportScope(imported)
+	assert.True(t, scope.System)
+	assert.Equal(t, []string{matrixTestMerchantId}, scope.Merchants)
+
+	changes := Diff(current, imported, nil)
+	require.Len(t, changes, 3)
+	assert.Equal(t, ActionUpdate, changes[0].Action)
+	assert.Equal(t, current[0], changes[0].Old)
+	assert.Equal(t, "system", changes[0].Row.item)
+	assert.Equal(t, ActionCreate, changes[1].Action)
+	assert.Nil(t, changes[1].Old)
+	assert.Equal(t, ActionUnchanged, changes[2].Action)
+
+	changes = Diff(current, imported, scope)
+	require.Len(t, changes, 4)
+	assert.Equal(t, ActionDelete, changes[3].Action)
+	assert.Nil(t, changes[3].Row)
+	assert.Equal(t, current[1], changes[3].Old)
+}
+
+func TestDiff_Scope_MerchantOnly(t *testing.T) {
+	current := []*Row{
+		{Type: TypeChannelSystem, Method: "VISA", Region: "CIS", Country: "AZ", Percent: 1.5},
+		{Type: TypeMoneyBackSystem, Method: "VISA", Region: "CIS", Country: "AZ", PaymentStage: 1, Percent: 1},
+		{Type: TypeChannelMerchant, MerchantId: matrixTestMerchantId, Method: "VISA", Region: "CIS", PayoutCurrency: "USD"},
+		{Type: TypeChannelMerchant, MerchantId: matrixTestMerchantId, Method: "MASTERCARD", Region: "CIS", PayoutCurrency: "USD"},
+	}
+	imported := []*Row{
+		{Type: TypeChannelMerchant, MerchantId: matrixTestMerchantId, Method: "VISA", Region: "CIS", PayoutCurrency: "USD"},
+	}
+
+	scope := ImportScope(imported)
+	assert.False(t, scope.System)
+
+	// the system costs are kept when the import has the merchant costs only
+	changes := Diff(current, imported, scope)
+	require.Len(t, changes, 2)
+	assert.Equal(t, ActionUnchanged, changes[0].Action)
+	assert.Equal(t, ActionDelete, changes[1].Action)
+	assert.Equal(t, current[3], changes[1].Old)
+}
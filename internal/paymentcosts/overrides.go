@@ -0,0 +1,180 @@
+package paymentcosts
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math"
+)
+
+const (
+	// deltaPrecision drops the float noise of the subtraction
+	deltaPrecision = 1e8
+)
+
+var (
+	// OverrideColumns are the columns of the overrides report file
+	OverrideColumns = []string{
+		ColumnType,
+		ColumnMerchantId,
+		"merchant_name",
+		ColumnMethod,
+		ColumnRegion,
+		ColumnCountry,
+		ColumnPayoutCurrency,
+		ColumnMinAmount,
+		ColumnUndoReason,
+		ColumnDaysFrom,
+		ColumnPaymentStage,
+		ColumnPercent,
+		"system_percent",
+		"percent_delta",
+		ColumnFixAmount,
+		ColumnFixAmountCurrency,
+		"system_fix_amount",
+		"system_fix_amount_currency",
+		"fix_amount_delta",
+	}
+)
+
+// Override is the merchant cost next to the system cost it overrides. The cost without the matching
+// system cost has no deltas, the fix amount delta is missed when the fix amounts are in the different currencies.
+type Override struct {
+	MerchantName   string   `json:"merchant_name,omitempty"`
+	Cost           *Row     `json:"cost"`
+	System         *Row     `json:"system,omitempty"`
+	PercentDelta   *float64 `json:"percent_delta,omitempty"`
+	FixAmountDelta *float64 `json:"fix_amount_delta,omitempty"`
+}
+
+// Overrides matches the merchant costs with the system ones. The system cost of the country is preferred,
+// the system cost of the whole region is used when the country has no own cost.
+func Overrides(rows []*Row, merchantNames map[string]string) []*Override {
+	system := make(map[string]*Row)
+
+	for _, row := range rows {
+		if !row.IsMerchant() {
+			system[row.Key()] = row
+		}
+	}
+
+	overrides := make([]*Override, 0)
+
+	for _, row := range rows {
+		if !row.IsMerchant() {
+			continue
+		}
+
+		override := &Override{MerchantName: merchantNames[row.MerchantId], Cost: row}
+		match := row.systemRow()
+
+		if override.System = system[match.Key()]; override.System == nil && match.Country != "" {
+			match.Country = ""
+			override.System = system[match.Key()]
+		}
+
+		if override.System != nil {
+			override.PercentDelta = delta(row.Percent, override.System.Percent)
+
+			if row.FixAmountCurrency == override.System.FixAmountCurrency {
+				override.FixAmountDelta = delta(row.FixAmount, override.System.FixAmount)
+			}
+		}
+
+		overrides = append(overrides, override)
+	}
+
+	return overrides
+}
+
+// WriteOverridesCsv returns the overrides report as the CSV file
+func WriteOverridesCsv(overrides []*Override) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	writer := csv.NewWriter(buf)
+
+	if err := writer.Write(OverrideColumns); err != nil {
+		return nil, err
+	}
+
+	for _, override := range overrides {
+		row := override.Cost
+		cells := []string{
+			row.Type,
+			row.MerchantId,
+			override.MerchantName,
+			row.Method,
+			row.Region,
+			row.Country,
+			row.PayoutCurrency,
+			"",
+			row.UndoReason,
+			"",
+			"",
+			formatFloat(row.Percent),
+			"",
+			formatOptionalFloat(override.PercentDelta),
+			formatFloat(row.FixAmount),
+			row.FixAmountCurrency,
+			"",
+			"",
+			formatOptionalFloat(override.FixAmountDelta),
+		}
+
+		if row.Type == TypeChannelMerchant {
+			cells[7] = formatFloat(row.MinAmount)
+		}
+
+		if row.IsMoneyBack() {
+			cells[9] = formatInt(row.DaysFrom)
+			cells[10] = formatInt(row.PaymentStage)
+		}
+
+		if override.System != nil {
+			cells[12] = formatFloat(override.System.Percent)
+			cells[16] = formatFloat(override.System.FixAmount)
+			cells[17] = override.System.FixAmountCurrency
+		}
+
+		if err := writer.Write(cells); err != nil {
+			return nil, err
+		}
+	}
+
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// systemRow returns the system cost identity matching the merchant cost
+func (r *Row) systemRow() *Row {
+	if r.Type == TypeChannelMerchant {
+		return &Row{Type: TypeChannelSystem, Method: r.Method, Region: r.Region, Country: r.Country}
+	}
+
+	return &Row{
+		Type:           TypeMoneyBackSystem,
+		Method:         r.Method,
+		Region:         r.Region,
+		Country:        r.Country,
+		PayoutCurrency: r.PayoutCurrency,
+		UndoReason:     r.UndoReason,
+		DaysFrom:       r.DaysFrom,
+		PaymentStage:   r.PaymentStage,
+	}
+}
+
+func delta(value, base float64) *float64 {
+	d := math.Round((value-base)*deltaPrecision) / deltaPrecision
+	return &d
+}
+
+func formatOptionalFloat(value *float64) string {
+	if value == nil {
+		return ""
+	}
+
+	return formatFloat(*value)
+}
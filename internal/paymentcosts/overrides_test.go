@@ -0,0 +1,56 @@
+package paymentcosts
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestOverrides_Match(t *testing.T) {
+	rows := []*Row{
+		{Type: TypeChannelSystem, Method: "VISA", Region: "CIS", Country: "AZ", Percent: 1.5, FixAmount: 0.01, FixAmountCurrency: "USD"},
+		{Type: TypeChannelSystem, Method: "VISA", Region: "CIS", Percent: 2, FixAmountCurrency: "USD"},
+		{Type: TypeMoneyBackSystem, Method: "VISA", Region: "CIS", Country: "AZ", PayoutCurrency: "USD", UndoReason: "refund",
+			PaymentStage: 1, Percent: 3},
+		{Type: TypeChannelMerchant, MerchantId: matrixTestMerchantId, Method: "VISA", Region: "CIS", Country: "AZ",
+			PayoutCurrency: "USD", Percent: 2.5, FixAmount: 0.2, FixAmountCurrency: "USD"},
+		{Type: TypeChannelMerchant, MerchantId: matrixTestMerchantId, Method: "VISA", Region: "CIS", Country: "AM",
+			PayoutCurrency: "USD", Percent: 1, FixAmount: 0.2, FixAmountCurrency: "EUR"},
+		{Type: TypeChannelMerchant, MerchantId: matrixTestMerchantId, Method: "MASTERCARD", Region: "CIS", Country: "AZ",
+			PayoutCurrency: "USD", Percent: 1},
+		{Type: TypeMoneyBackMerchant, MerchantId: matrixTestMerchantId, Method: "VISA", Region: "CIS", Country: "AZ",
+			PayoutCurrency: "USD", UndoReason: "refund", PaymentStage: 1, Percent: 2, FixAmount: 0.5},
+	}
+
+	overrides := Overrides(rows, map[string]string{matrixTestMerchantId: "Merchant"})
+	require.Len(t, overrides, 4)
+
+	assert.Equal(t, "Merchant", overrides[0].MerchantName)
+	assert.Equal(t, rows[0], overrides[0].System)
+	assert.EqualValues(t, 1, *overrides[0].PercentDelta)
+	assert.EqualValues(t, 0.19, *overrides[0].FixAmountDelta)
+
+	assert.Equal(t, rows[1], overrides[1].System)
+	assert.EqualValues(t, -1, *overrides[1].PercentDelta)
+	assert.Nil(t, overrides[1].FixAmountDelta)
+
+	assert.Nil(t, overrides[2].System)
+	assert.Nil(t, overrides[2].PercentDelta)
+
+	assert.Equal(t, rows[2], overrides[3].System)
+	assert.EqualValues(t, -1, *overrides[3].PercentDelta)
+	assert.EqualValues(t, 0.5, *overrides[3].FixAmountDelta)
+
+	data, err := WriteOverridesCsv(overrides)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 5)
+	assert.Equal(t, strings.Join(OverrideColumns, ","), lines[0])
+	assert.Equal(t, "channel_merchant,"+matrixTestMerchantId+",Merchant,VISA,CIS,AZ,USD,0,,,,2.5,1.5,1,0.2,USD,0.01,USD,0.19",
+		lines[1])
+	assert.Equal(t, "channel_merchant,"+matrixTestMerchantId+",Merchant,MASTERCARD,CIS,AZ,USD,0,,,,1,,,0,,,,", lines[3])
+	assert.Equal(t, "money_back_merchant,"+matrixTestMerchantId+",Merchant,VISA,CIS,AZ,USD,,refund,0,1,2,3,-1,0.5,,0,,0.5",
+		lines[4])
+}
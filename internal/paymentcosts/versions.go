@@ -0,0 +1,120 @@
+package paymentcosts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	awsWrapper "github.com/paysuper/paysuper-aws-manager"
+	"github.com/paysuper/paysuper-management-api/internal/storage"
+	"time"
+)
+
+const (
+	SourceImport   = "import"
+	SourceRollback = "rollback"
+
+	versionsFileName    = "payment_costs/versions.json"
+	versionFileNameMask = "payment_costs/versions/%s.json"
+)
+
+var (
+	ErrorVersionNotFound = errors.New("payment costs version not found")
+)
+
+// Version is the applied change of the cost matrix. The version keeps the snapshot of the changed part
+// of the matrix after the change and before it, so the change can be rolled back.
+type Version struct {
+	Id         string    `json:"id"`
+	Number     int       `json:"number"`
+	Source     string    `json:"source"`
+	FileName   string    `json:"file_name,omitempty"`
+	RollbackOf string    `json:"rollback_of,omitempty"`
+	System     bool      `json:"system"`
+	Merchants  []string  `json:"merchants"`
+	Created    int       `json:"created"`
+	Updated    int       `json:"updated"`
+	Deleted    int       `json:"deleted"`
+	CreatedBy  string    `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Snapshot is the part of the cost matrix changed by the version
+type Snapshot struct {
+	Rows     []*Row `json:"rows"`
+	Previous []*Row `json:"previous"`
+}
+
+// Scope returns the part of the cost matrix changed by the version
+func (v *Version) Scope() *Scope {
+	return &Scope{System: v.System, Merchants: v.Merchants}
+}
+
+// Versions keeps the list of the versions and their snapshots in the reporter bucket
+type Versions struct {
+	files *storage.Store
+}
+
+// NewVersions
+func NewVersions(awsManager awsWrapper.AwsManagerInterface) *Versions {
+	return &Versions{files: storage.New(awsManager)}
+}
+
+// List returns the versions from the latest one
+func (v *Versions) List(ctx context.Context) ([]*Version, error) {
+	versions := make([]*Version, 0)
+
+	if _, err := v.files.Load(ctx, versionsFileName, &versions); err != nil {
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+// Get returns the version with its snapshot
+func (v *Versions) Get(ctx context.Context, id string) (*Version, *Snapshot, error) {
+	versions, err := v.List(ctx)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, version := range versions {
+		if version.Id != id {
+			continue
+		}
+
+		snapshot := &Snapshot{}
+
+		if _, err = v.files.Load(ctx, fmt.Sprintf(versionFileNameMask, id), snapshot); err != nil {
+			return nil, nil, err
+		}
+
+		return version, snapshot, nil
+	}
+
+	return nil, nil, ErrorVersionNotFound
+}
+
+// Add saves the snapshot and adds the version with the next number
+func (v *Versions) Add(ctx context.Context, version *Version, snapshot *Snapshot) error {
+	versions := make([]*Version, 0)
+
+	return v.files.Update(ctx, versionsFileName, &versions, func(found bool) error {
+		version.Id = uuid.New().String()
+		version.Number = 1
+		version.CreatedAt = time.Now().UTC()
+
+		if len(versions) > 0 {
+			version.Number = versions[0].Number + 1
+		}
+
+		if err := v.files.Save(ctx, fmt.Sprintf(versionFileNameMask, version.Id), snapshot); err != nil {
+			return err
+		}
+
+		versions = append([]*Version{version}, versions...)
+
+		return nil
+	})
+}
@@ -0,0 +1,75 @@
+package paymentform
+
+import (
+	"context"
+	"fmt"
+	awsWrapper "github.com/paysuper/paysuper-aws-manager"
+	"github.com/paysuper/paysuper-management-api/internal/storage"
+)
+
+const (
+	tokenFileMask  = "payment_form/tokens/%s.json"
+	tokenOrdersMax = 20
+)
+
+// TokenOrders is the list of the orders created by the payment form from the token
+type TokenOrders struct {
+	Orders []string `json:"orders"`
+}
+
+// Has returns true when the order is created from the token
+func (t *TokenOrders) Has(orderId string) bool {
+	for _, id := range t.Orders {
+		if id == orderId {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Tokens binds the payment tokens to the orders created from them in the reporter bucket,
+// so the form url signed for the token opens only the orders of the token.
+// Every visit of the form creates the new order from the token, only the latest orders are kept.
+type Tokens struct {
+	files *storage.Store
+}
+
+// NewTokens
+func NewTokens(awsManager awsWrapper.AwsManagerInterface) *Tokens {
+	return &Tokens{files: storage.New(awsManager)}
+}
+
+// Bind adds the order to the orders of the token
+func (t *Tokens) Bind(ctx context.Context, token, orderId string) error {
+	orders := &TokenOrders{}
+
+	return t.files.Update(ctx, tokenFileName(token), orders, func(found bool) error {
+		if orders.Has(orderId) {
+			return nil
+		}
+
+		orders.Orders = append(orders.Orders, orderId)
+
+		if len(orders.Orders) > tokenOrdersMax {
+			orders.Orders = orders.Orders[len(orders.Orders)-tokenOrdersMax:]
+		}
+
+		return nil
+	})
+}
+
+// IsBound returns true when the order is created from the token
+func (t *Tokens) IsBound(ctx context.Context, token, orderId string) (bool, error) {
+	orders := &TokenOrders{}
+
+	if _, err := t.files.Load(ctx, tokenFileName(token), orders); err != nil {
+		return false, err
+	}
+
+	return orders.Has(orderId), nil
+}
+
+func tokenFileName(token string) string {
+	return fmt.Sprintf(tokenFileMask, token)
+}
@@ -0,0 +1,47 @@
+package paymentform
+
+import (
+	"context"
+	"github.com/paysuper/paysuper-management-api/internal/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"testing"
+)
+
+func TestTokens_Bind(t *testing.T) {
+	ctx := context.Background()
+	tokens := NewTokens(mock.NewAwsManagerFilesMock(make(map[string][]byte)))
+
+	bound, err := tokens.IsBound(ctx, "token", "order")
+	require.NoError(t, err)
+	assert.False(t, bound)
+
+	require.NoError(t, tokens.Bind(ctx, "token", "order"))
+	require.NoError(t, tokens.Bind(ctx, "token", "order"))
+
+	bound, err = tokens.IsBound(ctx, "token", "order")
+	require.NoError(t, err)
+	assert.True(t, bound)
+
+	bound, err = tokens.IsBound(ctx, "other", "order")
+	require.NoError(t, err)
+	assert.False(t, bound)
+}
+
+func TestTokens_Bind_KeepsLatestOrders(t *testing.T) {
+	ctx := context.Background()
+	tokens := NewTokens(mock.NewAwsManagerFilesMock(make(map[string][]byte)))
+
+	for i := 0; i <= tokenOrdersMax; i++ {
+		require.NoError(t, tokens.Bind(ctx, "token", strconv.Itoa(i)))
+	}
+
+	bound, err := tokens.IsBound(ctx, "token", "0")
+	require.NoError(t, err)
+	assert.False(t, bound)
+
+	bound, err = tokens.IsBound(ctx, "token", strconv.Itoa(tokenOrdersMax))
+	require.NoError(t, err)
+	assert.True(t, bound)
+}
@@ -0,0 +1,194 @@
+package paymentform
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ParamOrderId   = "order_id"
+	ParamToken     = "token"
+	ParamLocale    = "locale"
+	ParamTheme     = "theme"
+	ParamMode      = "mode"
+	ParamExpires   = "expires"
+	ParamSignature = "signature"
+
+	ModeIframe   = "iframe"
+	ModeRedirect = "redirect"
+)
+
+var (
+	ErrorSubjectEmpty     = errors.New("payment form url must contain order identifier or token")
+	ErrorLocaleInvalid    = errors.New("payment form locale is invalid")
+	ErrorThemeInvalid     = errors.New("payment form theme is invalid")
+	ErrorModeUnknown      = errors.New("payment form mode is unknown")
+	ErrorSignatureInvalid = errors.New("payment form url signature is invalid")
+	ErrorExpired          = errors.New("payment form url is expired")
+
+	localeRegexp = regexp.MustCompile(`^[a-z]{2}(-[A-Z]{2})?$`)
+	themeRegexp  = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+)
+
+// Params of the payment form passed in the form url
+type Params struct {
+	OrderId   string
+	Token     string
+	Locale    string
+	Theme     string
+	Mode      string
+	ExpiresAt time.Time
+}
+
+// Validate checks the optional form parameters, the empty values are allowed
+func (p *Params) Validate() error {
+	if p.Locale != "" && !localeRegexp.MatchString(p.Locale) {
+		return ErrorLocaleInvalid
+	}
+
+	if p.Theme != "" && !themeRegexp.MatchString(p.Theme) {
+		return ErrorThemeInvalid
+	}
+
+	if p.Mode != "" && p.Mode != ModeIframe && p.Mode != ModeRedirect {
+		return ErrorModeUnknown
+	}
+
+	return nil
+}
+
+// Builder makes the payment form urls and verifies them.
+// The url parameters are signed by HMAC-SHA256 together with the expiration time,
+// so the parameters can't be changed and the url can't be used after the expiration.
+type Builder struct {
+	mask     string
+	secret   []byte
+	lifetime time.Duration
+}
+
+// NewBuilder
+func NewBuilder(mask, secret string, lifetime time.Duration) *Builder {
+	return &Builder{
+		mask:     mask,
+		secret:   []byte(secret),
+		lifetime: lifetime,
+	}
+}
+
+// Build returns the signed payment form url expiring after the builder lifetime
+func (b *Builder) Build(params *Params, now time.Time) (string, error) {
+	query, err := b.Query(params, now)
+
+	if err != nil {
+		return "", err
+	}
+
+	separator := "?"
+
+	if strings.Contains(b.mask, "?") {
+		separator = "&"
+	}
+
+	return b.mask + separator + query.Encode(), nil
+}
+
+// Query returns the signed query of the payment form url expiring after the builder lifetime
+func (b *Builder) Query(params *Params, now time.Time) (url.Values, error) {
+	if params.OrderId == "" && params.Token == "" {
+		return nil, ErrorSubjectEmpty
+	}
+
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	setParam(query, ParamOrderId, params.OrderId)
+	setParam(query, ParamToken, params.Token)
+	setParam(query, ParamLocale, params.Locale)
+	setParam(query, ParamTheme, params.Theme)
+	setParam(query, ParamMode, params.Mode)
+	query.Set(ParamExpires, strconv.FormatInt(now.Add(b.lifetime).Unix(), 10))
+	query.Set(ParamSignature, b.sign(query))
+
+	return query, nil
+}
+
+// Verify checks the signature and the expiration of the payment form url query and returns its parameters
+func (b *Builder) Verify(query url.Values, now time.Time) (*Params, error) {
+	signature, err := hex.DecodeString(query.Get(ParamSignature))
+
+	if err != nil || len(signature) <= 0 {
+		return nil, ErrorSignatureInvalid
+	}
+
+	signed := url.Values{}
+
+	for _, key := range []string{ParamOrderId, ParamToken, ParamLocale, ParamTheme, ParamMode, ParamExpires} {
+		if values, ok := query[key]; ok {
+			signed[key] = values
+		}
+	}
+
+	expected, _ := hex.DecodeString(b.sign(signed))
+
+	if !hmac.Equal(signature, expected) {
+		return nil, ErrorSignatureInvalid
+	}
+
+	expires, err := strconv.ParseInt(signed.Get(ParamExpires), 10, 64)
+
+	if err != nil {
+		return nil, ErrorSignatureInvalid
+	}
+
+	params := &Params{
+		OrderId:   signed.Get(ParamOrderId),
+		Token:     signed.Get(ParamToken),
+		Locale:    signed.Get(ParamLocale),
+		Theme:     signed.Get(ParamTheme),
+		Mode:      signed.Get(ParamMode),
+		ExpiresAt: time.Unix(expires, 0),
+	}
+
+	if !now.Before(params.ExpiresAt) {
+		return nil, ErrorExpired
+	}
+
+	return params, nil
+}
+
+// IsSigned returns true when the query contains the payment form url signature
+func IsSigned(query url.Values) bool {
+	_, ok := query[ParamSignature]
+	return ok
+}
+
+// sign returns the hex HMAC of the encoded query, the signature parameter isn't signed
+func (b *Builder) sign(query url.Values) string {
+	signed := url.Values{}
+
+	for key, values := range query {
+		if key != ParamSignature {
+			signed[key] = values
+		}
+	}
+
+	mac := hmac.New(sha256.New, b.secret)
+	mac.Write([]byte(signed.Encode()))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func setParam(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
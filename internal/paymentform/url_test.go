@@ -0,0 +1,114 @@
+package paymentform
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func getQuery(t *testing.T, rawUrl string) url.Values {
+	u, err := url.Parse(rawUrl)
+	require.NoError(t, err)
+
+	return u.Query()
+}
+
+func TestBuilder_Build_Verify_Ok(t *testing.T) {
+	now := time.Unix(1573000000, 0)
+	b := NewBuilder("https://checkout.pay.super.com/pay", "secret", time.Hour)
+
+	rawUrl, err := b.Build(&Params{OrderId: "order", Locale: "ru-RU", Theme: "dark", Mode: ModeIframe}, now)
+	require.NoError(t, err)
+	assert.Contains(t, rawUrl, "https://checkout.pay.super.com/pay?")
+
+	query := getQuery(t, rawUrl)
+	assert.True(t, IsSigned(query))
+
+	params, err := b.Verify(query, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, "order", params.OrderId)
+	assert.Equal(t, "ru-RU", params.Locale)
+	assert.Equal(t, "dark", params.Theme)
+	assert.Equal(t, ModeIframe, params.Mode)
+	assert.Equal(t, now.Add(time.Hour).Unix(), params.ExpiresAt.Unix())
+
+	// the parameters not made by the builder are ignored
+	query.Set("utm_source", "email")
+	_, err = b.Verify(query, now)
+	assert.NoError(t, err)
+}
+
+func TestBuilder_Build_MaskWithQuery(t *testing.T) {
+	b := NewBuilder("https://checkout.pay.super.com/pay?version=2", "secret", time.Hour)
+
+	rawUrl, err := b.Build(&Params{Token: "token"}, time.Now())
+	require.NoError(t, err)
+
+	query := getQuery(t, rawUrl)
+	assert.Equal(t, "2", query.Get("version"))
+	assert.Equal(t, "token", query.Get(ParamToken))
+}
+
+func TestBuilder_Query(t *testing.T) {
+	now := time.Now()
+	b := NewBuilder("https://checkout.pay.super.com/pay", "secret", time.Hour)
+
+	query, err := b.Query(&Params{OrderId: "order"}, now)
+	require.NoError(t, err)
+
+	params, err := b.Verify(query, now)
+	require.NoError(t, err)
+	assert.Equal(t, "order", params.OrderId)
+
+	_, err = b.Query(&Params{}, now)
+	assert.Equal(t, ErrorSubjectEmpty, err)
+}
+
+func TestBuilder_Build_Error(t *testing.T) {
+	b := NewBuilder("https://checkout.pay.super.com/pay", "secret", time.Hour)
+
+	_, err := b.Build(&Params{}, time.Now())
+	assert.Equal(t, ErrorSubjectEmpty, err)
+
+	_, err = b.Build(&Params{OrderId: "order", Locale: "russian"}, time.Now())
+	assert.Equal(t, ErrorLocaleInvalid, err)
+
+	_, err = b.Build(&Params{OrderId: "order", Theme: "<script>"}, time.Now())
+	assert.Equal(t, ErrorThemeInvalid, err)
+
+	_, err = b.Build(&Params{OrderId: "order", Mode: "popup"}, time.Now())
+	assert.Equal(t, ErrorModeUnknown, err)
+}
+
+func TestBuilder_Verify_Error(t *testing.T) {
+	now := time.Now()
+	b := NewBuilder("https://checkout.pay.super.com/pay", "secret", time.Hour)
+
+	rawUrl, err := b.Build(&Params{OrderId: "order", Locale: "en"}, now)
+	require.NoError(t, err)
+
+	query := getQuery(t, rawUrl)
+	query.Set(ParamLocale, "de")
+	_, err = b.Verify(query, now)
+	assert.Equal(t, ErrorSignatureInvalid, err)
+
+	query = getQuery(t, rawUrl)
+	query.Set(ParamExpires, "9999999999")
+	_, err = b.Verify(query, now)
+	assert.Equal(t, ErrorSignatureInvalid, err)
+
+	query = getQuery(t, rawUrl)
+	query.Del(ParamSignature)
+	assert.False(t, IsSigned(query))
+	_, err = b.Verify(query, now)
+	assert.Equal(t, ErrorSignatureInvalid, err)
+
+	query = getQuery(t, rawUrl)
+	_, err = NewBuilder("https://checkout.pay.super.com/pay", "other", time.Hour).Verify(query, now)
+	assert.Equal(t, ErrorSignatureInvalid, err)
+
+	_, err = b.Verify(query, now.Add(time.Hour))
+	assert.Equal(t, ErrorExpired, err)
+}
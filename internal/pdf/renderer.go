@@ -0,0 +1,97 @@
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+const (
+	// MimeType is the content type of the rendered documents
+	MimeType = "application/pdf"
+
+	// BinaryDefault is the name of the wkhtmltopdf binary looked up in the PATH
+	BinaryDefault = "wkhtmltopdf"
+	// RendersMax is the max count of the wkhtmltopdf processes run at a time by the api instance
+	RendersMax = 4
+	// ProxyNone is the proxy of wkhtmltopdf without the proxy configured. Nothing listens on the port,
+	// so every network request of the document is refused.
+	ProxyNone = "http://127.0.0.1:1"
+)
+
+var (
+	// args are the options of wkhtmltopdf. The documents are rendered from the templates edited by the users,
+	// so the document can't run the scripts or read the local files of the server. The network requests
+	// go through the proxy only and the failed images don't fail the document.
+	args = []string{
+		"--quiet",
+		"--encoding", "utf-8",
+		"--disable-javascript",
+		"--disable-local-file-access",
+		"--disable-plugins",
+		"--load-media-error-handling", "ignore",
+	}
+
+	// renders are shared by all renderers, so the processes are limited for the whole api instance
+	renders = make(chan struct{}, RendersMax)
+)
+
+var (
+	ErrorDocumentEmpty = errors.New("pdf document is empty")
+)
+
+// Renderer converts the html documents to pdf by the wkhtmltopdf binary.
+// The html is passed to the standard input and the pdf is read from the standard output.
+// The renders over the limit wait for the running ones until the context is done.
+// The document can't load anything from the network without the proxy allowing it,
+// so the images of the documents are inlined as the data uris.
+type Renderer struct {
+	binary string
+	proxy  string
+}
+
+// NewRenderer returns the renderer sending the network requests of the documents to the proxy.
+// Without the proxy all network requests are refused.
+func NewRenderer(binary, proxy string) *Renderer {
+	if binary == "" {
+		binary = BinaryDefault
+	}
+
+	if proxy == "" {
+		proxy = ProxyNone
+	}
+
+	return &Renderer{binary: binary, proxy: proxy}
+}
+
+// Render returns the pdf document of the html page
+func (r *Renderer) Render(ctx context.Context, html []byte) ([]byte, error) {
+	select {
+	case renders <- struct{}{}:
+		defer func() { <-renders }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	cmdArgs := append(append([]string{}, args...), "--proxy", r.proxy, "-", "-")
+	cmd := exec.CommandContext(ctx, r.binary, cmdArgs...)
+	cmd.Stdin = bytes.NewReader(html)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s failed: %v %s", r.binary, err, strings.TrimSpace(stderr.String()))
+	}
+
+	if stdout.Len() <= 0 {
+		return nil, ErrorDocumentEmpty
+	}
+
+	return stdout.Bytes(), nil
+}
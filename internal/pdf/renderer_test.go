@@ -0,0 +1,75 @@
+package pdf
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newFakeBinary(t *testing.T, script string) string {
+	dir, err := ioutil.TempDir("", "pdf")
+	require.NoError(t, err)
+
+	binary := filepath.Join(dir, "wkhtmltopdf")
+	require.NoError(t, ioutil.WriteFile(binary, []byte("#!/bin/sh\n"+script+"\n"), 0755))
+
+	return binary
+}
+
+func TestRenderer_Render(t *testing.T) {
+	binary := newFakeBinary(t, `echo "%PDF-1.4 $*"; cat`)
+	defer os.RemoveAll(filepath.Dir(binary))
+
+	out, err := NewRenderer(binary, "").Render(context.Background(), []byte("<p>receipt</p>"))
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"%PDF-1.4 --quiet --encoding utf-8 --disable-javascript --disable-local-file-access --disable-plugins "+
+			"--load-media-error-handling ignore --proxy "+ProxyNone+" - -\n<p>receipt</p>",
+		string(out),
+	)
+
+	out, err = NewRenderer(binary, "http://proxy:3128").Render(context.Background(), []byte("<p>receipt</p>"))
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "--proxy http://proxy:3128 - -")
+}
+
+func TestRenderer_Render_Busy(t *testing.T) {
+	for i := 0; i < RendersMax; i++ {
+		renders <- struct{}{}
+	}
+
+	defer func() {
+		for i := 0; i < RendersMax; i++ {
+			<-renders
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := NewRenderer(BinaryDefault, "").Render(ctx, []byte("<p>receipt</p>"))
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestRenderer_Render_Error(t *testing.T) {
+	binary := newFakeBinary(t, `echo "page load failed" >&2; exit 1`)
+	defer os.RemoveAll(filepath.Dir(binary))
+
+	_, err := NewRenderer(binary, "").Render(context.Background(), []byte("<p>receipt</p>"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "page load failed")
+
+	binary = newFakeBinary(t, `exit 0`)
+	defer os.RemoveAll(filepath.Dir(binary))
+
+	_, err = NewRenderer(binary, "").Render(context.Background(), []byte("<p>receipt</p>"))
+	assert.Equal(t, ErrorDocumentEmpty, err)
+
+	_, err = NewRenderer(filepath.Join(os.TempDir(), "unknown_binary"), "").Render(context.Background(), nil)
+	assert.Error(t, err)
+}
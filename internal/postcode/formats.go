@@ -0,0 +1,284 @@
+package postcode
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	DefaultFile = "/assets/postcode/formats.csv"
+
+	examplesSeparator = ";"
+)
+
+const (
+	formatsColumnCountry = iota
+	formatsColumnPattern
+	formatsColumnExamples
+	formatsColumnsCount
+)
+
+var (
+	ErrorFormatsFileEmpty   = errors.New("postal code formats file is empty")
+	ErrorFormatsFileInvalid = errors.New("postal code formats file has invalid format")
+
+	// generalRegexp is used for the countries without the postal code format
+	generalRegexp = regexp.MustCompile("^\\d{0,30}$")
+
+	dashesReplacer = strings.NewReplacer(
+		"‐", "-",
+		"‑", "-",
+		"‒", "-",
+		"–", "-",
+		"—", "-",
+		"―", "-",
+		"−", "-",
+	)
+
+	defaultFormats = NewFormats("")
+)
+
+// Format is the postal code format of the country
+type Format struct {
+	Country  string   `json:"country"`
+	Pattern  string   `json:"pattern"`
+	Examples []string `json:"examples"`
+	regexp   *regexp.Regexp
+}
+
+// Result is the postal code validation result.
+// Normalized is the postal code matching the country format after the normalization of spacing, case and dashes.
+type Result struct {
+	Country    string   `json:"country"`
+	Zip        string   `json:"zip"`
+	Normalized string   `json:"normalized,omitempty"`
+	Valid      bool     `json:"valid"`
+	Known      bool     `json:"known"`
+	Examples   []string `json:"examples,omitempty"`
+}
+
+// Formats is the table of the postal code formats loaded from the CSV file with columns: country, pattern, examples.
+// The examples are separated by the semicolon. The first row of the file is the header.
+type Formats struct {
+	path      string
+	mx        sync.RWMutex
+	formats   map[string]*Format
+	updatedAt time.Time
+}
+
+// NewFormats
+func NewFormats(path string) *Formats {
+	return &Formats{
+		path:    path,
+		formats: make(map[string]*Format),
+	}
+}
+
+// Default returns the formats table shared by the validators and the routes
+func Default() *Formats {
+	return defaultFormats
+}
+
+// SetPath changes the path of the formats file used by the next reload
+func (f *Formats) SetPath(path string) {
+	f.mx.Lock()
+	f.path = path
+	f.mx.Unlock()
+}
+
+// Reload replaces the formats with the content of the formats file
+func (f *Formats) Reload() error {
+	f.mx.RLock()
+	path := f.path
+	f.mx.RUnlock()
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := file.Close(); err != nil {
+			return
+		}
+	}()
+
+	return f.Load(file)
+}
+
+// Load replaces the formats with the CSV data read from the reader
+func (f *Formats) Load(r io.Reader) error {
+	rows, err := csv.NewReader(r).ReadAll()
+
+	if err != nil {
+		return ErrorFormatsFileInvalid
+	}
+
+	if len(rows) <= 1 {
+		return ErrorFormatsFileEmpty
+	}
+
+	formats := make(map[string]*Format, len(rows)-1)
+
+	for _, row := range rows[1:] {
+		if len(row) < formatsColumnsCount {
+			return ErrorFormatsFileInvalid
+		}
+
+		country := strings.ToUpper(strings.TrimSpace(row[formatsColumnCountry]))
+		pattern := strings.TrimSpace(row[formatsColumnPattern])
+
+		if len(country) != 2 || pattern == "" {
+			return ErrorFormatsFileInvalid
+		}
+
+		reg, err := regexp.Compile(pattern)
+
+		if err != nil {
+			return ErrorFormatsFileInvalid
+		}
+
+		format := &Format{
+			Country:  country,
+			Pattern:  pattern,
+			Examples: make([]string, 0),
+			regexp:   reg,
+		}
+
+		for _, example := range strings.Split(row[formatsColumnExamples], examplesSeparator) {
+			if example = strings.TrimSpace(example); example != "" {
+				format.Examples = append(format.Examples, example)
+			}
+		}
+
+		formats[country] = format
+	}
+
+	f.mx.Lock()
+	f.formats = formats
+	f.updatedAt = time.Now()
+	f.mx.Unlock()
+
+	return nil
+}
+
+// Get returns the postal code format of the country
+func (f *Formats) Get(country string) (*Format, bool) {
+	f.mx.RLock()
+	defer f.mx.RUnlock()
+
+	format, ok := f.formats[strings.ToUpper(country)]
+	return format, ok
+}
+
+// Match checks the postal code as is against the format of the country.
+// The postal codes of the countries without the format must contain digits only.
+func (f *Formats) Match(country, zip string) bool {
+	if format, ok := f.Get(country); ok {
+		return format.regexp.MatchString(zip)
+	}
+
+	return generalRegexp.MatchString(zip)
+}
+
+// Validate checks the postal code against the format of the country after the normalization.
+// The normalized postal code takes the layout of the matching country example when possible.
+func (f *Formats) Validate(country, zip string) *Result {
+	res := &Result{
+		Country: strings.ToUpper(country),
+		Zip:     zip,
+	}
+	normalized := Normalize(zip)
+	format, ok := f.Get(country)
+
+	if !ok {
+		if normalized != "" && generalRegexp.MatchString(normalized) {
+			res.Valid = true
+			res.Normalized = normalized
+		}
+
+		return res
+	}
+
+	res.Known = true
+	res.Examples = format.Examples
+
+	for _, candidate := range format.candidates(normalized) {
+		if format.regexp.MatchString(candidate) {
+			res.Valid = true
+			res.Normalized = candidate
+			break
+		}
+	}
+
+	return res
+}
+
+// Len returns the number of countries with the postal code format
+func (f *Formats) Len() int {
+	f.mx.RLock()
+	defer f.mx.RUnlock()
+	return len(f.formats)
+}
+
+// UpdatedAt returns the time of the last successful formats load
+func (f *Formats) UpdatedAt() time.Time {
+	f.mx.RLock()
+	defer f.mx.RUnlock()
+	return f.updatedAt
+}
+
+// candidates returns the spellings of the normalized postal code to check: laid out as the examples,
+// as entered and without the separators
+func (f *Format) candidates(normalized string) []string {
+	compact := strings.NewReplacer(" ", "", "-", "").Replace(normalized)
+	candidates := make([]string, 0, len(f.Examples)+2)
+
+	for _, example := range f.Examples {
+		if candidate, ok := layout(compact, strings.ToUpper(example)); ok {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	return append(candidates, normalized, compact)
+}
+
+// Normalize trims the postal code, converts it to the upper case, replaces the unicode dashes
+// and collapses the spacing including the spacing around the dashes
+func Normalize(zip string) string {
+	zip = strings.ToUpper(dashesReplacer.Replace(zip))
+	zip = strings.Join(strings.Fields(zip), " ")
+
+	return strings.NewReplacer(" - ", "-", " -", "-", "- ", "-").Replace(zip)
+}
+
+// layout places the letters and the digits of the compact postal code to the positions of the example ones
+func layout(compact, example string) (string, bool) {
+	runes := []rune(compact)
+	result := make([]rune, 0, len(example))
+	i := 0
+
+	for _, r := range example {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			result = append(result, r)
+			continue
+		}
+
+		if i >= len(runes) {
+			return "", false
+		}
+
+		result = append(result, runes[i])
+		i++
+	}
+
+	return string(result), i == len(runes)
+}
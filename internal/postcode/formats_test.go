@@ -0,0 +1,178 @@
+package postcode
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+const (
+	formatsTestFile = "./../.." + DefaultFile
+)
+
+func loadTestFormats(t *testing.T) *Formats {
+	formats := NewFormats(formatsTestFile)
+	require.NoError(t, formats.Reload())
+	require.True(t, formats.Len() > 0)
+
+	return formats
+}
+
+func TestFormats_Examples_Match(t *testing.T) {
+	formats := loadTestFormats(t)
+
+	for _, format := range formats.formats {
+		assert.NotEmpty(t, format.Examples, format.Country)
+
+		for _, example := range format.Examples {
+			assert.True(t, formats.Match(format.Country, example), "%s: %s", format.Country, example)
+
+			res := formats.Validate(format.Country, strings.ToLower(example))
+			assert.True(t, res.Valid, "%s: %s", format.Country, example)
+			assert.True(t, res.Known, format.Country)
+		}
+
+		assert.False(t, formats.Match(format.Country, ""), format.Country)
+		assert.False(t, formats.Match(format.Country, "!"+format.Examples[0]), format.Country)
+		assert.False(t, formats.Validate(format.Country, "!!!").Valid, format.Country)
+	}
+}
+
+func TestFormats_Validate(t *testing.T) {
+	formats := loadTestFormats(t)
+
+	tests := []struct {
+		country    string
+		zip        string
+		valid      bool
+		normalized string
+	}{
+		{country: "US", zip: "10001", valid: true, normalized: "10001"},
+		{country: "US", zip: " 10001 ", valid: true, normalized: "10001"},
+		{country: "US", zip: "100011234", valid: true, normalized: "10001-1234"},
+		{country: "US", zip: "10001 – 1234", valid: true, normalized: "10001-1234"},
+		{country: "US", zip: "1000", valid: false},
+		{country: "US", zip: "ABCDE", valid: false},
+		{country: "CA", zip: "k1a0b1", valid: true, normalized: "K1A 0B1"},
+		{country: "CA", zip: "K1A-0B1", valid: true, normalized: "K1A 0B1"},
+		{country: "CA", zip: "K1A 0B", valid: false},
+		{country: "NL", zip: "1012ab", valid: true, normalized: "1012 AB"},
+		{country: "BR", zip: "01310100", valid: true, normalized: "01310-100"},
+		{country: "BR", zip: "01310 100", valid: true, normalized: "01310-100"},
+		{country: "PL", zip: "00950", valid: true, normalized: "00-950"},
+		{country: "LV", zip: "lv 1050", valid: true, normalized: "LV-1050"},
+		{country: "SE", zip: "11122", valid: true, normalized: "111 22"},
+		{country: "RU", zip: "190000", valid: true, normalized: "190000"},
+		{country: "RU", zip: "19000", valid: false},
+		{country: "DE", zip: "10115", valid: true, normalized: "10115"},
+		{country: "ru", zip: "190 000", valid: true, normalized: "190000"},
+		{country: "AQ", zip: "12345", valid: true, normalized: "12345"},
+		{country: "AQ", zip: "ABC", valid: false},
+	}
+
+	for _, tt := range tests {
+		res := formats.Validate(tt.country, tt.zip)
+
+		assert.Equal(t, strings.ToUpper(tt.country), res.Country, "%s: %s", tt.country, tt.zip)
+		assert.Equal(t, tt.zip, res.Zip, "%s: %s", tt.country, tt.zip)
+		assert.Equal(t, tt.valid, res.Valid, "%s: %s", tt.country, tt.zip)
+		assert.Equal(t, tt.normalized, res.Normalized, "%s: %s", tt.country, tt.zip)
+		assert.Equal(t, tt.country != "AQ", res.Known, "%s: %s", tt.country, tt.zip)
+	}
+}
+
+// TestFormats_Corrected checks the formats which patterns couldn't match the real postal codes before
+func TestFormats_Corrected(t *testing.T) {
+	formats := loadTestFormats(t)
+
+	tests := []struct {
+		country    string
+		zip        string
+		valid      bool
+		normalized string
+	}{
+		{country: "GB", zip: "sw1a1aa", valid: true, normalized: "SW1A 1AA"},
+		{country: "GB", zip: "M1  1AE", valid: true, normalized: "M1 1AE"},
+		{country: "GB", zip: "SW1A 1A", valid: false},
+		{country: "GB", zip: "SW1A 1A]]", valid: false},
+		{country: "IM", zip: "im11aa", valid: true, normalized: "IM1 1AA"},
+		{country: "IM", zip: "I1 1[A-Z]]", valid: false},
+		{country: "AR", zip: "1425", valid: true, normalized: "1425"},
+		{country: "AR", zip: "c1425dkb", valid: true, normalized: "C1425DKB"},
+		{country: "AR", zip: "1425 and more", valid: false},
+		{country: "AS", zip: "96799", valid: true, normalized: "96799"},
+		{country: "AS", zip: "96799-1234", valid: true, normalized: "96799-1234"},
+		{country: "AS", zip: "96799-123456", valid: false},
+		{country: "AI", zip: "ai-2640", valid: true, normalized: "AI-2640"},
+		{country: "BB", zip: "bb11000", valid: true, normalized: "BB11000"},
+		{country: "BB", zip: "AZ11000", valid: false},
+		{country: "CL", zip: "8320000", valid: true, normalized: "832-0000"},
+		{country: "CL", zip: "8320000 (832-0000)", valid: false},
+		{country: "CZ", zip: "11000", valid: true, normalized: "110 00"},
+		{country: "CZ", zip: "11000 (110 00)", valid: false},
+		{country: "SK", zip: "81101", valid: true, normalized: "811 01"},
+		{country: "JP", zip: "1000001", valid: true, normalized: "100-0001"},
+		{country: "JP", zip: "1000001 (100-0001)", valid: false},
+		{country: "KR", zip: "03187", valid: true, normalized: "03187"},
+		{country: "KR", zip: "135080 (135-080)", valid: false},
+	}
+
+	for _, tt := range tests {
+		res := formats.Validate(tt.country, tt.zip)
+
+		assert.Equal(t, tt.valid, res.Valid, "%s: %s", tt.country, tt.zip)
+		assert.Equal(t, tt.normalized, res.Normalized, "%s: %s", tt.country, tt.zip)
+	}
+}
+
+func TestFormats_Match_Unknown(t *testing.T) {
+	formats := loadTestFormats(t)
+
+	assert.True(t, formats.Match("AQ", ""))
+	assert.True(t, formats.Match("AQ", "12345"))
+	assert.False(t, formats.Match("AQ", "AB-12"))
+}
+
+func TestFormats_Load_Error(t *testing.T) {
+	tests := []struct {
+		data string
+		err  error
+	}{
+		{data: "", err: ErrorFormatsFileEmpty},
+		{data: "country,pattern,examples\n", err: ErrorFormatsFileEmpty},
+		{data: "country,pattern,examples\nRU,^\\d{6}$\n", err: ErrorFormatsFileInvalid},
+		{data: "country,pattern,examples\nRUS,^\\d{6}$,190000\n", err: ErrorFormatsFileInvalid},
+		{data: "country,pattern,examples\nRU,,190000\n", err: ErrorFormatsFileInvalid},
+		{data: "country,pattern,examples\nRU,^[\\d$,190000\n", err: ErrorFormatsFileInvalid},
+	}
+
+	for _, tt := range tests {
+		formats := NewFormats("")
+		assert.Equal(t, tt.err, formats.Load(strings.NewReader(tt.data)), tt.data)
+		assert.Equal(t, 0, formats.Len())
+	}
+}
+
+func TestFormats_Reload_KeepsFormatsOnError(t *testing.T) {
+	formats := loadTestFormats(t)
+	count := formats.Len()
+
+	formats.SetPath(formatsTestFile + ".unknown")
+	assert.Error(t, formats.Reload())
+	assert.Equal(t, count, formats.Len())
+}
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		" sw1a  1aa ":  "SW1A 1AA",
+		"10001 - 1234": "10001-1234",
+		"10001—1234":   "10001-1234",
+		"lv -1050":     "LV-1050",
+		"\t190000\n":   "190000",
+	}
+
+	for zip, normalized := range tests {
+		assert.Equal(t, normalized, Normalize(zip), zip)
+	}
+}
@@ -0,0 +1,51 @@
+package receipt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	awsWrapper "github.com/paysuper/paysuper-aws-manager"
+	"github.com/paysuper/paysuper-management-api/internal/storage"
+)
+
+const (
+	pdfFileMask = "receipts/%s/%s_%s.pdf"
+)
+
+// Cache keeps the rendered pdf receipts in the reporter bucket shared by all replicas of the api.
+// The public receipt link renders the receipt once per its language and branding version,
+// so the repeated requests of the link don't start the pdf renderer again.
+type Cache struct {
+	files *storage.Store
+}
+
+// NewCache
+func NewCache(awsManager awsWrapper.AwsManagerInterface) *Cache {
+	return &Cache{files: storage.New(awsManager)}
+}
+
+// PdfFileName returns the name of the pdf receipt. The changed branding changes the name,
+// so the receipt is rendered again with the new branding.
+func PdfFileName(receiptId, language, brandingVersion string) string {
+	return fmt.Sprintf(pdfFileMask, receiptId, language, brandingVersion)
+}
+
+// Get returns the cached pdf receipt and false when the receipt isn't rendered yet
+func (c *Cache) Get(ctx context.Context, fileName string) ([]byte, bool, error) {
+	data, err := c.files.Read(ctx, fileName)
+
+	if err == storage.ErrorFileNotFound {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return data, true, nil
+}
+
+// Put saves the rendered pdf receipt
+func (c *Cache) Put(ctx context.Context, fileName string, data []byte) error {
+	return c.files.Upload(ctx, fileName, bytes.NewReader(data))
+}
@@ -0,0 +1,91 @@
+package receipt
+
+import (
+	"strings"
+)
+
+const (
+	// LanguageDefault is the language of the receipt when the order language isn't supported
+	LanguageDefault = "en"
+)
+
+// Labels are the translated texts of the receipt document and email
+type Labels struct {
+	Language        string
+	Title           string
+	TransactionId   string
+	TransactionDate string
+	Project         string
+	Merchant        string
+	Total           string
+	EmailSubject    string
+}
+
+var labels = map[string]*Labels{
+	"en": {
+		Language:        "en",
+		Title:           "Receipt",
+		TransactionId:   "Transaction ID",
+		TransactionDate: "Transaction date",
+		Project:         "Project",
+		Merchant:        "Merchant",
+		Total:           "Total",
+		EmailSubject:    "Your receipt",
+	},
+	"ru": {
+		Language:        "ru",
+		Title:           "Квитанция",
+		TransactionId:   "Номер транзакции",
+		TransactionDate: "Дата транзакции",
+		Project:         "Проект",
+		Merchant:        "Продавец",
+		Total:           "Итого",
+		EmailSubject:    "Ваша квитанция",
+	},
+	"de": {
+		Language:        "de",
+		Title:           "Quittung",
+		TransactionId:   "Transaktions-ID",
+		TransactionDate: "Transaktionsdatum",
+		Project:         "Projekt",
+		Merchant:        "Händler",
+		Total:           "Gesamt",
+		EmailSubject:    "Ihre Quittung",
+	},
+	"fr": {
+		Language:        "fr",
+		Title:           "Reçu",
+		TransactionId:   "ID de transaction",
+		TransactionDate: "Date de transaction",
+		Project:         "Projet",
+		Merchant:        "Marchand",
+		Total:           "Total",
+		EmailSubject:    "Votre reçu",
+	},
+	"es": {
+		Language:        "es",
+		Title:           "Recibo",
+		TransactionId:   "ID de transacción",
+		TransactionDate: "Fecha de transacción",
+		Project:         "Proyecto",
+		Merchant:        "Comerciante",
+		Total:           "Total",
+		EmailSubject:    "Su recibo",
+	},
+}
+
+// GetLabels returns the receipt texts in the language of the locale, like "ru" or "ru-RU".
+// The unsupported languages get the texts in the default language.
+func GetLabels(locale string) *Labels {
+	language := strings.ToLower(strings.TrimSpace(locale))
+
+	if i := strings.IndexAny(language, "-_"); i >= 0 {
+		language = language[:i]
+	}
+
+	if item, ok := labels[language]; ok {
+		return item
+	}
+
+	return labels[LanguageDefault]
+}
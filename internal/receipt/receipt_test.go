@@ -0,0 +1,14 @@
+package receipt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGetLabels(t *testing.T) {
+	assert.Equal(t, "ru", GetLabels("ru").Language)
+	assert.Equal(t, "ru", GetLabels("ru-RU").Language)
+	assert.Equal(t, "de", GetLabels("DE_de").Language)
+	assert.Equal(t, LanguageDefault, GetLabels("").Language)
+	assert.Equal(t, LanguageDefault, GetLabels("xx").Language)
+}
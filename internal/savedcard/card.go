@@ -0,0 +1,66 @@
+package savedcard
+
+import (
+	"strconv"
+)
+
+const (
+	BrandVisa       = "VISA"
+	BrandMasterCard = "MASTERCARD"
+	BrandAmex       = "AMEX"
+	BrandJcb        = "JCB"
+	BrandUnionPay   = "UNIONPAY"
+	BrandMir        = "MIR"
+	BrandUnknown    = "UNKNOWN"
+)
+
+type brandPrefix struct {
+	from, to int
+	brand    string
+}
+
+// brandPrefixes are the card number ranges of the brands by the leading digits, the longer ranges go first
+var brandPrefixes = []brandPrefix{
+	{2221, 2720, BrandMasterCard},
+	{3528, 3589, BrandJcb},
+	{2200, 2204, BrandMir},
+	{51, 55, BrandMasterCard},
+	{34, 34, BrandAmex},
+	{37, 37, BrandAmex},
+	{62, 62, BrandUnionPay},
+	{4, 4, BrandVisa},
+}
+
+// Expire is the expiry date of the card
+type Expire struct {
+	Month string `json:"month"`
+	Year  string `json:"year"`
+}
+
+// Card is the saved payment instrument as it's shown to the customer
+type Card struct {
+	Id        string  `json:"id"`
+	MaskedPan string  `json:"masked_pan"`
+	Brand     string  `json:"brand"`
+	Expire    *Expire `json:"expire,omitempty"`
+	IsDefault bool    `json:"is_default"`
+}
+
+// Brand returns the card brand by the leading digits of the masked card number
+func Brand(maskedPan string) string {
+	for _, p := range brandPrefixes {
+		digits := len(strconv.Itoa(p.from))
+
+		if len(maskedPan) < digits {
+			continue
+		}
+
+		n, err := strconv.Atoi(maskedPan[:digits])
+
+		if err == nil && n >= p.from && n <= p.to {
+			return p.brand
+		}
+	}
+
+	return BrandUnknown
+}
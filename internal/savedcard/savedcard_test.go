@@ -0,0 +1,24 @@
+package savedcard
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBrand(t *testing.T) {
+	cases := map[string]string{
+		"400000******0002": BrandVisa,
+		"555555******4444": BrandMasterCard,
+		"222100******0000": BrandMasterCard,
+		"378282******0005": BrandAmex,
+		"353011******0000": BrandJcb,
+		"620000******0000": BrandUnionPay,
+		"220220******0000": BrandMir,
+		"900000******0000": BrandUnknown,
+		"":                 BrandUnknown,
+	}
+
+	for pan, brand := range cases {
+		assert.Equal(t, brand, Brand(pan), pan)
+	}
+}
@@ -0,0 +1,102 @@
+package savedcard
+
+import (
+	"context"
+	"fmt"
+	awsWrapper "github.com/paysuper/paysuper-aws-manager"
+	"github.com/paysuper/paysuper-management-api/internal/storage"
+	"time"
+)
+
+const (
+	EventDefaultChanged = "saved_card.default_changed"
+	EventDeleted        = "saved_card.deleted"
+
+	preferencesFileMask = "customers/%s/saved_cards.json"
+	auditMaxEvents      = 200
+)
+
+// Event is the audit record of the customer action with the saved card
+type Event struct {
+	Type       string    `json:"type"`
+	CardId     string    `json:"card_id"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Ip         string    `json:"ip,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+}
+
+// Preferences are the saved cards settings of the customer with the audit events of the customer actions
+type Preferences struct {
+	CustomerId    string     `json:"customer_id"`
+	DefaultCardId string     `json:"default_card_id,omitempty"`
+	UpdatedAt     *time.Time `json:"updated_at,omitempty"`
+	Events        []*Event   `json:"events"`
+}
+
+// Store keeps the saved cards preferences of the customers in the reporter bucket.
+// The saved cards themselves are owned by the recurring repository, only the choices of the customer are kept here.
+type Store struct {
+	files *storage.Store
+}
+
+// NewStore
+func NewStore(awsManager awsWrapper.AwsManagerInterface) *Store {
+	return &Store{files: storage.New(awsManager)}
+}
+
+// Get returns the preferences of the customer, the customer without the preferences gets the empty ones
+func (s *Store) Get(ctx context.Context, customerId string) (*Preferences, error) {
+	return s.load(ctx, customerId)
+}
+
+// SetDefault marks the card as the default one of the customer and records the audit event
+func (s *Store) SetDefault(ctx context.Context, customerId string, event *Event) error {
+	return s.update(ctx, customerId, event, func(preferences *Preferences) {
+		preferences.DefaultCardId = event.CardId
+	})
+}
+
+// RecordDeleted records the audit event of the card deletion, the deleted card stops being the default one
+func (s *Store) RecordDeleted(ctx context.Context, customerId string, event *Event) error {
+	return s.update(ctx, customerId, event, func(preferences *Preferences) {
+		if preferences.DefaultCardId == event.CardId {
+			preferences.DefaultCardId = ""
+		}
+	})
+}
+
+func (s *Store) update(ctx context.Context, customerId string, event *Event, change func(preferences *Preferences)) error {
+	preferences := newPreferences(customerId)
+
+	return s.files.Update(ctx, fmt.Sprintf(preferencesFileMask, customerId), preferences, func(found bool) error {
+		now := time.Now().UTC()
+
+		if event.OccurredAt.IsZero() {
+			event.OccurredAt = now
+		}
+
+		change(preferences)
+		preferences.UpdatedAt = &now
+		preferences.Events = append(preferences.Events, event)
+
+		if len(preferences.Events) > auditMaxEvents {
+			preferences.Events = preferences.Events[len(preferences.Events)-auditMaxEvents:]
+		}
+
+		return nil
+	})
+}
+
+func (s *Store) load(ctx context.Context, customerId string) (*Preferences, error) {
+	preferences := newPreferences(customerId)
+
+	if _, err := s.files.Load(ctx, fmt.Sprintf(preferencesFileMask, customerId), preferences); err != nil {
+		return nil, err
+	}
+
+	return preferences, nil
+}
+
+func newPreferences(customerId string) *Preferences {
+	return &Preferences{CustomerId: customerId, Events: make([]*Event, 0)}
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"strings"
+	"time"
+)
+
+// Deleter removes the files of the bucket, the aws manager can upload and download the files only
+type Deleter interface {
+	// Delete removes the file, the missing file isn't the error
+	Delete(ctx context.Context, fileName string) error
+	// DeleteOlder removes the files of the prefix with the suffix changed before the time and returns their count
+	DeleteOlder(ctx context.Context, prefix, suffix string, before time.Time) (int, error)
+}
+
+// Bucket is the direct S3 client of the bucket for the operations the aws manager doesn't have
+type Bucket struct {
+	client s3iface.S3API
+	bucket string
+}
+
+// NewBucket
+func NewBucket(accessKeyId, secretAccessKey, region, bucket string) (*Bucket, error) {
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String(region),
+		Credentials: credentials.NewStaticCredentials(accessKeyId, secretAccessKey, ""),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Bucket{client: s3.New(sess), bucket: bucket}, nil
+}
+
+// Delete removes the file
+func (b *Bucket) Delete(ctx context.Context, fileName string) error {
+	_, err := b.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(fileName),
+	})
+
+	return err
+}
+
+// DeleteOlder removes the files of the prefix with the suffix changed before the time
+func (b *Bucket) DeleteOlder(ctx context.Context, prefix, suffix string, before time.Time) (int, error) {
+	keys := make([]string, 0)
+	in := &s3.ListObjectsV2Input{Bucket: aws.String(b.bucket), Prefix: aws.String(prefix)}
+
+	err := b.client.ListObjectsV2PagesWithContext(ctx, in, func(page *s3.ListObjectsV2Output, last bool) bool {
+		for _, object := range page.Contents {
+			key := aws.StringValue(object.Key)
+
+			if strings.HasSuffix(key, suffix) && aws.TimeValue(object.LastModified).Before(before) {
+				keys = append(keys, key)
+			}
+		}
+
+		return true
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	for i, key := range keys {
+		if err = b.Delete(ctx, key); err != nil {
+			return i, err
+		}
+	}
+
+	return len(keys), nil
+}
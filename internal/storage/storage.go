@@ -0,0 +1,361 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/google/uuid"
+	awsWrapper "github.com/paysuper/paysuper-aws-manager"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	lockFileMask    = "locks/%s"
+	tempFilePattern = "storage_*"
+)
+
+var (
+	ErrorFileNotFound = errors.New("storage file not found")
+	ErrorLockTimeout  = errors.New("storage lock wait timed out")
+)
+
+var (
+	// LockTtl is the time after which the lock of the crashed holder is taken over
+	LockTtl = 30 * time.Second
+	// LockSettleDelay is the time the holder waits after writing the lease to see the concurrent writers
+	LockSettleDelay = 200 * time.Millisecond
+	// LockRetryDelay is the pause between the attempts to take the busy lock
+	LockRetryDelay = 100 * time.Millisecond
+	// LockWaitTimeout is the maximal time to wait for the busy lock
+	LockWaitTimeout = 10 * time.Second
+)
+
+// lease is the content of the lock file
+type lease struct {
+	Owner     string    `json:"owner"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type localLock struct {
+	mx   sync.Mutex
+	refs int
+}
+
+// Store reads and writes the files of the bucket of the aws manager.
+// Every read goes through the own temporary file, so the concurrent reads of the same file don't collide.
+// The read-modify-write of the file runs under the lock shared by all replicas of the API,
+// the lock is the lease file in the same bucket. The lock is best-effort, see Lock.
+type Store struct {
+	awsManager awsWrapper.AwsManagerInterface
+	mx         sync.Mutex
+	locks      map[string]*localLock
+}
+
+// New
+func New(awsManager awsWrapper.AwsManagerInterface) *Store {
+	return &Store{
+		awsManager: awsManager,
+		locks:      make(map[string]*localLock),
+	}
+}
+
+// Load reads the JSON file into v and returns false when the file doesn't exist
+func (s *Store) Load(ctx context.Context, fileName string, v interface{}) (bool, error) {
+	data, err := s.Read(ctx, fileName)
+
+	if err == ErrorFileNotFound {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	if err = json.Unmarshal(data, v); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Save replaces the file by the JSON of v
+func (s *Store) Save(ctx context.Context, fileName string, v interface{}) error {
+	data, err := json.Marshal(v)
+
+	if err != nil {
+		return err
+	}
+
+	return s.Upload(ctx, fileName, bytes.NewReader(data))
+}
+
+// Update loads the JSON file into v, calls the change and saves v back when the change succeeds.
+// The file is locked for the whole cycle, the found flag of the change is false for the new file.
+// The lock makes the lost update of the concurrent writers unlikely, but doesn't exclude it,
+// so the files changed with Update hold the data which may be restored by the next change, e.g. the caches and lists.
+func (s *Store) Update(ctx context.Context, fileName string, v interface{}, change func(found bool) error) error {
+	unlock, err := s.Lock(ctx, fileName)
+
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	found, err := s.Load(ctx, fileName, v)
+
+	if err != nil {
+		return err
+	}
+
+	if err = change(found); err != nil {
+		return err
+	}
+
+	return s.Save(ctx, fileName, v)
+}
+
+// Read returns the content of the file
+func (s *Store) Read(ctx context.Context, fileName string) ([]byte, error) {
+	filePath, err := s.Download(ctx, fileName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer Remove(filePath)
+
+	return ioutil.ReadFile(filePath)
+}
+
+// Download saves the file to the new temporary file and returns the path to it.
+// The caller removes the temporary file by Remove when it's served.
+func (s *Store) Download(ctx context.Context, fileName string) (string, error) {
+	file, err := ioutil.TempFile("", tempFilePattern)
+
+	if err != nil {
+		return "", err
+	}
+
+	filePath := file.Name()
+
+	if err = file.Close(); err != nil {
+		Remove(filePath)
+		return "", err
+	}
+
+	_, err = s.awsManager.Download(ctx, filePath, &awsWrapper.DownloadInput{FileName: fileName})
+
+	if err != nil {
+		Remove(filePath)
+
+		if aErr, ok := err.(awserr.Error); ok && aErr.Code() == s3.ErrCodeNoSuchKey {
+			return "", ErrorFileNotFound
+		}
+
+		return "", err
+	}
+
+	return filePath, nil
+}
+
+// Upload replaces the file by the body
+func (s *Store) Upload(ctx context.Context, fileName string, body io.Reader) error {
+	_, err := s.awsManager.Upload(ctx, &awsWrapper.UploadInput{Body: body, FileName: fileName})
+	return err
+}
+
+// Lock takes the lock of the name shared by all replicas and returns the function releasing it.
+// The bucket has no conditional writes, so the lease is written and read back after the settle delay:
+// of the concurrent writers only the one whose lease survived the delay takes the lock.
+// The holders within the replica are queued on the local lock before they touch the lease.
+// The lease is renewed while the lock is held, so the long holder doesn't lose it after the ttl.
+//
+// The lock is best-effort: the writes delayed longer than the settle delay or the holder stalled longer
+// than the ttl let two holders run at once. The holders must keep their work safe to repeat
+// and must not rely on the lock alone to keep the data consistent.
+func (s *Store) Lock(ctx context.Context, name string) (func(), error) {
+	unlockLocal := s.lockLocal(name)
+	fileName := fmt.Sprintf(lockFileMask, name)
+	owner := uuid.New().String()
+	deadline := time.Now().Add(LockWaitTimeout)
+
+	for {
+		acquired, err := s.acquire(ctx, fileName, owner)
+
+		if err != nil {
+			unlockLocal()
+			return nil, err
+		}
+
+		if acquired {
+			return s.hold(fileName, owner, unlockLocal), nil
+		}
+
+		if time.Now().After(deadline) {
+			unlockLocal()
+			return nil, ErrorLockTimeout
+		}
+
+		select {
+		case <-ctx.Done():
+			unlockLocal()
+			return nil, ctx.Err()
+		case <-time.After(LockRetryDelay):
+		}
+	}
+}
+
+// TryLock takes the lock of the name like Lock, but returns false without waiting when the lock is busy.
+// It's used by the background jobs run by a single replica at a time.
+func (s *Store) TryLock(ctx context.Context, name string) (func(), bool, error) {
+	unlockLocal := s.lockLocal(name)
+	fileName := fmt.Sprintf(lockFileMask, name)
+	owner := uuid.New().String()
+	acquired, err := s.acquire(ctx, fileName, owner)
+
+	if err != nil || !acquired {
+		unlockLocal()
+		return nil, false, err
+	}
+
+	return s.hold(fileName, owner, unlockLocal), true, nil
+}
+
+// Remove removes the temporary file of the download
+func Remove(filePath string) {
+	if err := os.Remove(filePath); err != nil {
+		return
+	}
+}
+
+func (s *Store) acquire(ctx context.Context, fileName, owner string) (bool, error) {
+	current := &lease{}
+	found, err := s.Load(ctx, fileName, current)
+
+	if err != nil {
+		return false, err
+	}
+
+	if found && current.Owner != owner && current.ExpiresAt.After(time.Now()) {
+		return false, nil
+	}
+
+	if err = s.Save(ctx, fileName, &lease{Owner: owner, ExpiresAt: time.Now().Add(LockTtl)}); err != nil {
+		return false, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-time.After(LockSettleDelay):
+	}
+
+	if _, err = s.Load(ctx, fileName, current); err != nil {
+		return false, err
+	}
+
+	return current.Owner == owner, nil
+}
+
+// hold renews the lease every third of the ttl until the lock is released and returns the function releasing it.
+// The renewal stops when the lease is taken over by the other holder, the lost lease isn't taken back.
+func (s *Store) hold(fileName, owner string, unlockLocal func()) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(LockTtl / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if !s.renew(ctx, fileName, owner) {
+					return
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+		s.release(fileName, owner)
+		unlockLocal()
+	}
+}
+
+// renew extends the lease of the holder and returns false when the lease is owned by the other holder.
+// The failed read or write is retried with the next renewal while the lease is alive.
+func (s *Store) renew(ctx context.Context, fileName, owner string) bool {
+	current := &lease{}
+	found, err := s.Load(ctx, fileName, current)
+
+	if err != nil {
+		return true
+	}
+
+	if !found || current.Owner != owner {
+		return false
+	}
+
+	_ = s.Save(ctx, fileName, &lease{Owner: owner, ExpiresAt: time.Now().Add(LockTtl)})
+
+	return true
+}
+
+// release expires the lease if it's still owned by the holder.
+// The lease is released with the own context as the context of the holder may be done already.
+func (s *Store) release(fileName, owner string) {
+	ctx := context.Background()
+	current := &lease{}
+	found, err := s.Load(ctx, fileName, current)
+
+	if err != nil || !found || current.Owner != owner {
+		return
+	}
+
+	if err = s.Save(ctx, fileName, &lease{Owner: owner}); err != nil {
+		return
+	}
+}
+
+func (s *Store) lockLocal(name string) func() {
+	s.mx.Lock()
+	lock, ok := s.locks[name]
+
+	if !ok {
+		lock = &localLock{}
+		s.locks[name] = lock
+	}
+
+	lock.refs++
+	s.mx.Unlock()
+
+	lock.mx.Lock()
+
+	return func() {
+		lock.mx.Unlock()
+
+		s.mx.Lock()
+		defer s.mx.Unlock()
+
+		// the lock is dropped by the last waiter to keep the map bounded by the files in use
+		if lock.refs--; lock.refs <= 0 {
+			delete(s.locks, name)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	awsWrapper "github.com/paysuper/paysuper-aws-manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+type awsManagerFiles struct {
+	mx        sync.Mutex
+	files     map[string][]byte
+	downloads []string
+}
+
+func (m *awsManagerFiles) Upload(
+	ctx context.Context,
+	in *awsWrapper.UploadInput,
+	opts ...func(*s3manager.Uploader),
+) (*s3manager.UploadOutput, error) {
+	data, err := ioutil.ReadAll(in.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.files[in.FileName] = data
+
+	return &s3manager.UploadOutput{}, nil
+}
+
+func (m *awsManagerFiles) Download(
+	ctx context.Context,
+	filePath string,
+	in *awsWrapper.DownloadInput,
+	opts ...func(*s3manager.Downloader),
+) (int64, error) {
+	m.mx.Lock()
+	data, ok := m.files[in.FileName]
+	m.downloads = append(m.downloads, filePath)
+	m.mx.Unlock()
+
+	if !ok {
+		return 0, awserr.New(s3.ErrCodeNoSuchKey, "key not found", nil)
+	}
+
+	return int64(len(data)), ioutil.WriteFile(filePath, data, 0644)
+}
+
+func TestStore_LoadSave(t *testing.T) {
+	awsManager := &awsManagerFiles{files: make(map[string][]byte)}
+	store := New(awsManager)
+
+	items := make([]string, 0)
+	found, err := store.Load(context.Background(), "dir/items.json", &items)
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	assert.NoError(t, store.Save(context.Background(), "dir/items.json", []string{"a", "b"}))
+
+	found, err = store.Load(context.Background(), "dir/items.json", &items)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []string{"a", "b"}, items)
+
+	_, err = store.Read(context.Background(), "dir/missing.json")
+	assert.Equal(t, ErrorFileNotFound, err)
+
+	// every download goes to the own temporary file which is removed after the read
+	assert.Len(t, awsManager.downloads, 3)
+	assert.NotEqual(t, awsManager.downloads[0], awsManager.downloads[1])
+
+	for _, filePath := range awsManager.downloads {
+		_, err = os.Stat(filePath)
+		assert.True(t, os.IsNotExist(err), filePath)
+	}
+}
+
+func TestStore_Download(t *testing.T) {
+	store := New(&awsManagerFiles{files: map[string][]byte{"dir/file.pdf": []byte("pdf")}})
+
+	filePath, err := store.Download(context.Background(), "dir/file.pdf")
+	require.NoError(t, err)
+	defer Remove(filePath)
+
+	data, err := ioutil.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "pdf", string(data))
+
+	_, err = store.Download(context.Background(), "dir/missing.pdf")
+	assert.Equal(t, ErrorFileNotFound, err)
+}
+
+func TestStore_Update(t *testing.T) {
+	LockSettleDelay = time.Millisecond
+	awsManager := &awsManagerFiles{files: make(map[string][]byte)}
+	store := New(awsManager)
+
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			counter := 0
+			err := store.Update(context.Background(), "dir/counter.json", &counter, func(found bool) error {
+				counter++
+				return nil
+			})
+			assert.NoError(t, err)
+		}()
+	}
+
+	wg.Wait()
+
+	counter := 0
+	_, err := store.Load(context.Background(), "dir/counter.json", &counter)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, counter)
+	assert.Empty(t, store.locks)
+
+	current := &lease{}
+	_, err = store.Load(context.Background(), "locks/dir/counter.json", current)
+	assert.NoError(t, err)
+	assert.False(t, current.ExpiresAt.After(time.Now()))
+}
+
+func TestStore_Lock_Busy(t *testing.T) {
+	LockSettleDelay = time.Millisecond
+	LockWaitTimeout = 50 * time.Millisecond
+	awsManager := &awsManagerFiles{files: make(map[string][]byte)}
+
+	// the lease of the other replica is alive, so the lock isn't taken until it expires
+	other := New(awsManager)
+	assert.NoError(t, other.Save(
+		context.Background(),
+		"locks/dir/file.json",
+		&lease{Owner: "replica", ExpiresAt: time.Now().Add(time.Minute)},
+	))
+
+	_, err := New(awsManager).Lock(context.Background(), "dir/file.json")
+	assert.Equal(t, ErrorLockTimeout, err)
+
+	assert.NoError(t, other.Save(
+		context.Background(),
+		"locks/dir/file.json",
+		&lease{Owner: "replica", ExpiresAt: time.Now().Add(-time.Second)},
+	))
+
+	unlock, err := New(awsManager).Lock(context.Background(), "dir/file.json")
+	assert.NoError(t, err)
+	unlock()
+}
+
+func TestStore_TryLock(t *testing.T) {
+	LockSettleDelay = time.Millisecond
+	awsManager := &awsManagerFiles{files: make(map[string][]byte)}
+	store := New(awsManager)
+
+	unlock, ok, err := store.TryLock(context.Background(), "job")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// the other replica doesn't wait for the busy lock
+	_, ok, err = New(awsManager).TryLock(context.Background(), "job")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	unlock()
+
+	unlock, ok, err = New(awsManager).TryLock(context.Background(), "job")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	unlock()
+}
+
+func TestStore_Lock_Renewed(t *testing.T) {
+	LockSettleDelay = time.Millisecond
+	ttl := LockTtl
+	LockTtl = 30 * time.Millisecond
+	defer func() { LockTtl = ttl }()
+	awsManager := &awsManagerFiles{files: make(map[string][]byte)}
+
+	unlock, ok, err := New(awsManager).TryLock(context.Background(), "job")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// the lease of the long holder is renewed, so it isn't taken over after the ttl
+	time.Sleep(4 * LockTtl)
+
+	_, ok, err = New(awsManager).TryLock(context.Background(), "job")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	unlock()
+
+	unlock, ok, err = New(awsManager).TryLock(context.Background(), "job")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	unlock()
+}
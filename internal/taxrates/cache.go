@@ -0,0 +1,67 @@
+package taxrates
+
+import (
+	"context"
+	"github.com/paysuper/paysuper-tax-service/proto"
+	"sync"
+	"time"
+)
+
+type countryRates struct {
+	mx        sync.Mutex
+	rates     []*tax_service.TaxRate
+	expiresAt time.Time
+}
+
+// CountryCache keeps all tax rates of the country in memory for the ttl.
+// The country may have the rates of thousands of zip codes, so they are listed from the tax service
+// once per ttl instead of on every public request. The cache is kept by every replica of the API,
+// so the changed rates are seen by the requests after the ttl.
+type CountryCache struct {
+	tax       tax_service.TaxService
+	ttl       time.Duration
+	limit     int32
+	mx        sync.Mutex
+	countries map[string]*countryRates
+}
+
+// NewCountryCache
+func NewCountryCache(tax tax_service.TaxService, ttl time.Duration, limit int32) *CountryCache {
+	return &CountryCache{
+		tax:       tax,
+		ttl:       ttl,
+		limit:     limit,
+		countries: make(map[string]*countryRates),
+	}
+}
+
+// List returns the rates of the country, the concurrent requests of the expired country wait for the single listing
+func (c *CountryCache) List(ctx context.Context, country string) ([]*tax_service.TaxRate, error) {
+	c.mx.Lock()
+	entry, ok := c.countries[country]
+
+	if !ok {
+		entry = &countryRates{}
+		c.countries[country] = entry
+	}
+
+	c.mx.Unlock()
+
+	entry.mx.Lock()
+	defer entry.mx.Unlock()
+
+	if time.Now().Before(entry.expiresAt) {
+		return entry.rates, nil
+	}
+
+	rates, err := List(ctx, c.tax, &tax_service.GetRatesRequest{Country: country}, c.limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	entry.rates = rates
+	entry.expiresAt = time.Now().Add(c.ttl)
+
+	return rates, nil
+}
@@ -0,0 +1,45 @@
+package taxrates
+
+import (
+	"context"
+	"github.com/paysuper/paysuper-tax-service/proto"
+	"strings"
+)
+
+const (
+	jurisdictionSeparator = "|"
+)
+
+// Jurisdiction returns the key of the tax rate jurisdiction: country, state, city and zip
+func Jurisdiction(country, state, city, zip string) string {
+	return strings.Join([]string{country, state, city, zip}, jurisdictionSeparator)
+}
+
+// RateJurisdiction returns the jurisdiction key of the tax rate
+func RateJurisdiction(rate *tax_service.TaxRate) string {
+	return Jurisdiction(rate.Country, rate.State, rate.City, rate.Zip)
+}
+
+// List returns all tax rates matching the request page by page
+func List(ctx context.Context, tax tax_service.TaxService, req *tax_service.GetRatesRequest, limit int32) ([]*tax_service.TaxRate, error) {
+	req.Limit = limit
+	req.Offset = 0
+	rates := make([]*tax_service.TaxRate, 0)
+
+	for {
+		res, err := tax.GetRates(ctx, req)
+
+		if err != nil {
+			return nil, err
+		}
+
+		rates = append(rates, res.Rates...)
+		req.Offset += int32(len(res.Rates))
+
+		if int32(len(res.Rates)) < req.Limit {
+			break
+		}
+	}
+
+	return rates, nil
+}
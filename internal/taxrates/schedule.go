@@ -0,0 +1,350 @@
+package taxrates
+
+import (
+	"context"
+	"errors"
+	"github.com/ProtocolONE/go-core/v2/pkg/logger"
+	"github.com/ProtocolONE/go-core/v2/pkg/provider"
+	"github.com/google/uuid"
+	awsWrapper "github.com/paysuper/paysuper-aws-manager"
+	"github.com/paysuper/paysuper-management-api/internal/storage"
+	"github.com/paysuper/paysuper-tax-service/proto"
+	"sort"
+	"time"
+)
+
+const (
+	scheduleFileName       = "taxes/schedule.json"
+	scheduleRunnerLockName = "taxes/schedule_runner"
+	ratesPageLimit         = 1000
+)
+
+var (
+	ErrorChangeNotFound = errors.New("scheduled tax rate change not found")
+)
+
+// Change is the tax rate of the jurisdiction which takes effect from the date
+type Change struct {
+	Id            string    `json:"id"`
+	Country       string    `json:"country"`
+	State         string    `json:"state"`
+	City          string    `json:"city"`
+	Zip           string    `json:"zip"`
+	Rate          float64   `json:"rate"`
+	EffectiveFrom time.Time `json:"effective_from"`
+	CreatedBy     string    `json:"created_by"`
+	CreatedAt     time.Time `json:"created_at"`
+	// Error is the reason of the last failed attempt to apply the change
+	Error string `json:"error,omitempty"`
+}
+
+// Jurisdiction returns the jurisdiction key of the change
+func (c *Change) Jurisdiction() string {
+	return Jurisdiction(c.Country, c.State, c.City, c.Zip)
+}
+
+// Schedule keeps the tax rate changes with the future effective dates in the reporter bucket
+// and applies them through the tax service when they become effective.
+// The schedule file is changed under the lock shared by all replicas of the API.
+type Schedule struct {
+	files *storage.Store
+	tax   tax_service.TaxService
+}
+
+// NewSchedule
+func NewSchedule(awsManager awsWrapper.AwsManagerInterface, tax tax_service.TaxService) *Schedule {
+	return &Schedule{files: storage.New(awsManager), tax: tax}
+}
+
+// List returns the pending changes ordered by the effective date
+func (s *Schedule) List(ctx context.Context) ([]*Change, error) {
+	return s.load(ctx)
+}
+
+// Add schedules the changes. The pending change of the same jurisdiction and effective date is replaced.
+func (s *Schedule) Add(ctx context.Context, changes []*Change) error {
+	return s.update(ctx, func(pending []*Change) ([]*Change, error) {
+		return addChanges(pending, changes), nil
+	})
+}
+
+// Cancel removes the pending change
+func (s *Schedule) Cancel(ctx context.Context, id string) (*Change, error) {
+	var canceled *Change
+
+	err := s.update(ctx, func(pending []*Change) ([]*Change, error) {
+		for i, change := range pending {
+			if change.Id == id {
+				canceled = change
+				return append(pending[:i], pending[i+1:]...), nil
+			}
+		}
+
+		return nil, ErrorChangeNotFound
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return canceled, nil
+}
+
+// ApplyDue saves the changes effective at the moment to the tax service and removes them from the schedule.
+// The due changes are taken under the schedule lock, applied without it and removed under the lock again,
+// so the schedule isn't locked for the tax service calls. The change replaced while it was applied
+// is kept to be applied on the next run. The changes failed to apply are kept with the error to be retried.
+func (s *Schedule) ApplyDue(ctx context.Context, now time.Time) ([]*Change, error) {
+	applied := make([]*Change, 0)
+	pending, err := s.load(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the schedule isn't locked and rewritten until some change is due
+	if len(pending) <= 0 || pending[0].EffectiveFrom.After(now) {
+		return applied, nil
+	}
+
+	due, err := s.due(ctx, now)
+
+	if err != nil {
+		return nil, err
+	}
+
+	failed := s.applyChanges(ctx, due)
+	snapshot := make(map[string]*Change, len(due))
+
+	for _, change := range due {
+		snapshot[change.Id] = change
+	}
+
+	err = s.update(ctx, func(pending []*Change) ([]*Change, error) {
+		kept := make([]*Change, 0, len(pending))
+
+		for _, change := range pending {
+			done, ok := snapshot[change.Id]
+
+			if !ok || !isSameChange(done, change) {
+				kept = append(kept, change)
+				continue
+			}
+
+			if reason, ok := failed[change.Id]; ok {
+				change.Error = reason
+				kept = append(kept, change)
+				continue
+			}
+
+			applied = append(applied, change)
+		}
+
+		return kept, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+// Run applies the due changes with the interval until the context is done.
+// Every replica of the API runs the schedule, the run is skipped by the replica when the other one is applying it.
+func (s *Schedule) Run(ctx context.Context, interval time.Duration, lmt provider.LMT) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.runOnce(ctx, now, lmt)
+		}
+	}
+}
+
+func (s *Schedule) runOnce(ctx context.Context, now time.Time, lmt provider.LMT) {
+	unlock, ok, err := s.files.TryLock(ctx, scheduleRunnerLockName)
+
+	if err != nil {
+		lmt.L().Error("tax rate schedule runner lock failed", logger.PairArgs("err", err.Error()))
+		return
+	}
+
+	if !ok {
+		return
+	}
+
+	defer unlock()
+
+	applied, err := s.ApplyDue(ctx, now)
+
+	if err != nil {
+		lmt.L().Error("tax rate schedule apply failed", logger.PairArgs("err", err.Error()))
+		return
+	}
+
+	for _, change := range applied {
+		lmt.L().Info(
+			"scheduled tax rate applied",
+			logger.PairArgs("id", change.Id, "jurisdiction", change.Jurisdiction(), "rate", change.Rate),
+		)
+	}
+}
+
+// addChanges returns the pending changes with the changes added.
+// The pending change of the same jurisdiction and effective date is replaced.
+func addChanges(pending, changes []*Change) []*Change {
+	for _, change := range changes {
+		if change.Id == "" {
+			change.Id = uuid.New().String()
+		}
+
+		if change.CreatedAt.IsZero() {
+			change.CreatedAt = time.Now().UTC()
+		}
+
+		replaced := false
+
+		for i, p := range pending {
+			if p.Jurisdiction() == change.Jurisdiction() && p.EffectiveFrom.Equal(change.EffectiveFrom) {
+				change.Id = p.Id
+				pending[i] = change
+				replaced = true
+				break
+			}
+		}
+
+		if !replaced {
+			pending = append(pending, change)
+		}
+	}
+
+	return pending
+}
+
+// due returns the changes effective at the moment read under the schedule lock
+func (s *Schedule) due(ctx context.Context, now time.Time) ([]*Change, error) {
+	unlock, err := s.files.Lock(ctx, scheduleFileName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer unlock()
+
+	pending, err := s.load(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	due := make([]*Change, 0, len(pending))
+
+	for _, change := range pending {
+		if change.EffectiveFrom.After(now) {
+			break
+		}
+
+		due = append(due, change)
+	}
+
+	return due, nil
+}
+
+// applyChanges saves the changes to the tax service and returns the errors of the failed changes by the change id.
+// The rates of every country are listed once, the changes are ordered by the effective date,
+// so the latest due change of the jurisdiction wins.
+func (s *Schedule) applyChanges(ctx context.Context, changes []*Change) map[string]string {
+	failed := make(map[string]string)
+	countries := make(map[string]map[string]*tax_service.TaxRate)
+	countriesErrors := make(map[string]error)
+
+	for _, change := range changes {
+		if _, ok := countries[change.Country]; !ok && countriesErrors[change.Country] == nil {
+			rates, err := List(ctx, s.tax, &tax_service.GetRatesRequest{Country: change.Country}, ratesPageLimit)
+
+			if err != nil {
+				countriesErrors[change.Country] = err
+			} else {
+				countries[change.Country] = make(map[string]*tax_service.TaxRate, len(rates))
+
+				for _, rate := range rates {
+					countries[change.Country][RateJurisdiction(rate)] = rate
+				}
+			}
+		}
+
+		if err := countriesErrors[change.Country]; err != nil {
+			failed[change.Id] = err.Error()
+			continue
+		}
+
+		rate, ok := countries[change.Country][change.Jurisdiction()]
+
+		if !ok {
+			rate = &tax_service.TaxRate{
+				Country: change.Country,
+				State:   change.State,
+				City:    change.City,
+				Zip:     change.Zip,
+			}
+		}
+
+		rate.Rate = change.Rate
+		res, err := s.tax.CreateOrUpdate(ctx, rate)
+
+		if err != nil {
+			failed[change.Id] = err.Error()
+			continue
+		}
+
+		// the created rate is updated by the later change of the same jurisdiction instead of the second one created
+		if res != nil {
+			countries[change.Country][change.Jurisdiction()] = res
+		}
+	}
+
+	return failed
+}
+
+// isSameChange checks the pending change wasn't replaced since it was taken to be applied
+func isSameChange(done, change *Change) bool {
+	return done.Jurisdiction() == change.Jurisdiction() &&
+		done.EffectiveFrom.Equal(change.EffectiveFrom) &&
+		done.Rate == change.Rate
+}
+
+// update changes the pending changes under the schedule lock, the changes are saved ordered by the effective date
+func (s *Schedule) update(ctx context.Context, change func(pending []*Change) ([]*Change, error)) error {
+	pending := make([]*Change, 0)
+
+	return s.files.Update(ctx, scheduleFileName, &pending, func(found bool) error {
+		changed, err := change(pending)
+
+		if err != nil {
+			return err
+		}
+
+		sort.SliceStable(changed, func(i, j int) bool {
+			return changed[i].EffectiveFrom.Before(changed[j].EffectiveFrom)
+		})
+
+		pending = changed
+
+		return nil
+	})
+}
+
+func (s *Schedule) load(ctx context.Context) ([]*Change, error) {
+	changes := make([]*Change, 0)
+
+	if _, err := s.files.Load(ctx, scheduleFileName, &changes); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
@@ -264,8 +264,28 @@ func DefaultSettings() map[string]interface{} {
 				"awsRegionReporter":            "eu-west-1",
 				"awsBucketReporterr":           "eu-west-1",
 				"customerTokenCookiesLifetime": "2592000s",
+				"inviteLifetime":               "168h",
+				"callbackTesterTimeout":        "10s",
+				"callbackTesterLocalServer":    true,
+				"sandboxMode":                  true,
+				"eSignProvider":                "local",
+				"eSignCallbackSecret":          "secret",
+				"taxScheduleInterval":          "1m",
+				"responseCacheEnabled":         true,
+				"smtpHost":                     "localhost",
+				"smtpPort":                     25,
+				"smtpFrom":                     "noreply@paysuper.test",
+				"pdfRendererBinary":            "wkhtmltopdf",
+				"receiptResendInterval":        "1m",
+				"receiptResendDailyLimit":      5,
+				"savedCardDeleteInterval":      "10s",
+				"savedCardDeleteDailyLimit":    10,
 				"CookieDomain":                 "localhost",
 				"orderInlineFormUrlMask":       "http://localhost",
+				"publicUrl":                    "http://localhost",
+				"paymentFormUrlSecret":         "secret",
+				"paymentFormUrlLifetime":       "24h",
+				"paymentFormUrlUnsignedUntil":  "2019-11-01",
 				"auth1": map[string]interface{}{
 					"clientId":     "unknown",
 					"clientSecret": "unknown",
@@ -69,7 +69,7 @@ func BuildTestSet(ctx context.Context, settings config.Settings, srv common.Serv
 		cleanup()
 		return nil, nil, err
 	}
-	validatorSet, cleanup7, err := validators.Provider(srv, awareSet)
+	validatorSet, cleanup7, err := validators.Provider(srv, initial, awareSet)
 	if err != nil {
 		cleanup6()
 		cleanup5()
@@ -0,0 +1,110 @@
+package theme
+
+import (
+	"context"
+	"fmt"
+	awsWrapper "github.com/paysuper/paysuper-aws-manager"
+	"github.com/paysuper/paysuper-management-api/internal/storage"
+	"sync"
+	"time"
+)
+
+const (
+	themeFileMask = "projects/%s/theme.json"
+)
+
+type cachedTheme struct {
+	theme     *Theme
+	expiresAt time.Time
+}
+
+// Store keeps the themes of the projects in the reporter bucket.
+// The themes are read on every payment form opening, so they are cached in memory for the ttl.
+// The replica saving the theme updates its own cache, the other replicas drop theirs on Invalidate,
+// so the ttl only bounds the staleness when the invalidation isn't delivered.
+type Store struct {
+	files *storage.Store
+	ttl   time.Duration
+	mx    sync.RWMutex
+	cache map[string]*cachedTheme
+}
+
+// NewStore
+func NewStore(awsManager awsWrapper.AwsManagerInterface, ttl time.Duration) *Store {
+	return &Store{
+		files: storage.New(awsManager),
+		ttl:   ttl,
+		cache: make(map[string]*cachedTheme),
+	}
+}
+
+// Get returns the theme of the project, the project without the theme gets nil
+func (s *Store) Get(ctx context.Context, projectId string) (*Theme, error) {
+	now := time.Now()
+
+	s.mx.RLock()
+	cached, ok := s.cache[projectId]
+	s.mx.RUnlock()
+
+	if ok && cached.expiresAt.After(now) {
+		return cached.theme, nil
+	}
+
+	theme, err := s.download(ctx, projectId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.put(projectId, theme, now)
+
+	return theme, nil
+}
+
+// Save replaces the theme of the project
+func (s *Store) Save(ctx context.Context, theme *Theme, userId string) error {
+	updatedAt := time.Now().UTC()
+	theme.UpdatedBy = userId
+	theme.UpdatedAt = &updatedAt
+
+	if err := s.files.Save(ctx, fmt.Sprintf(themeFileMask, theme.ProjectId), theme); err != nil {
+		return err
+	}
+
+	s.put(theme.ProjectId, theme, time.Now())
+
+	return nil
+}
+
+// Invalidate drops the cached themes, they are read from the bucket again on the next Get
+func (s *Store) Invalidate() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.cache = make(map[string]*cachedTheme)
+}
+
+func (s *Store) download(ctx context.Context, projectId string) (*Theme, error) {
+	theme := NewTheme(projectId)
+	found, err := s.files.Load(ctx, fmt.Sprintf(themeFileMask, projectId), theme)
+
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return theme, nil
+}
+
+func (s *Store) put(projectId string, theme *Theme, now time.Time) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	// the expired themes are dropped here to keep the cache bounded by the active projects
+	for id, cached := range s.cache {
+		if !cached.expiresAt.After(now) {
+			delete(s.cache, id)
+		}
+	}
+
+	s.cache[projectId] = &cachedTheme{theme: theme, expiresAt: now.Add(s.ttl)}
+}
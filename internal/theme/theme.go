@@ -0,0 +1,278 @@
+package theme
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	AlignmentLeft   = "left"
+	AlignmentCenter = "center"
+	AlignmentRight  = "right"
+
+	// BorderRadiusMax is the max radius of the form controls in pixels
+	BorderRadiusMax = 32
+	// LogoUrlMaxLength is the max length of the logo url
+	LogoUrlMaxLength = 512
+	// CssRulesMax is the max count of the custom css rules
+	CssRulesMax = 50
+	// CssValueMaxLength is the max length of the custom css property value
+	CssValueMaxLength = 64
+)
+
+var (
+	ErrorProjectIdEmpty    = errors.New("theme project identifier is empty")
+	ErrorColorInvalid      = errors.New("theme color is invalid")
+	ErrorLogoUrlInvalid    = errors.New("theme logo url is invalid")
+	ErrorFontUnknown       = errors.New("theme font is unknown")
+	ErrorLayoutInvalid     = errors.New("theme layout is invalid")
+	ErrorCssRulesTooMany   = errors.New("theme has too many custom css rules")
+	ErrorCssSelectorDenied = errors.New("custom css selector isn't allowed")
+	ErrorCssPropertyDenied = errors.New("custom css property isn't allowed")
+	ErrorCssValueInvalid   = errors.New("custom css value is invalid")
+
+	colorRegexp             = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+	cssValueRegexp          = regexp.MustCompile(`^[a-zA-Z0-9#%.,() -]+$`)
+	cssValueForbiddenRegexp = regexp.MustCompile(`(?i)(url|expression|attr|var)\s*\(`)
+	logoUrlForbiddenSymbols = "\"'()\\<> \t\r\n"
+	alignments              = []string{AlignmentLeft, AlignmentCenter, AlignmentRight}
+)
+
+var (
+	// Fonts are the font families available for the payment form by the font name
+	Fonts = map[string]string{
+		"system":     `-apple-system, BlinkMacSystemFont, "Segoe UI", Arial, sans-serif`,
+		"roboto":     `Roboto, Arial, sans-serif`,
+		"open_sans":  `"Open Sans", Arial, sans-serif`,
+		"lato":       `Lato, Arial, sans-serif`,
+		"montserrat": `Montserrat, Arial, sans-serif`,
+		"pt_sans":    `"PT Sans", Arial, sans-serif`,
+		"georgia":    `Georgia, "Times New Roman", serif`,
+	}
+	// CssSelectors are the elements of the payment form which can be styled by the custom css
+	CssSelectors = []string{
+		".paysuper-form",
+		".paysuper-form__header",
+		".paysuper-form__logo",
+		".paysuper-form__input",
+		".paysuper-form__button",
+		".paysuper-form__link",
+		".paysuper-form__footer",
+	}
+	// CssProperties are the properties which can be set by the custom css
+	CssProperties = []string{
+		"background-color",
+		"border-color",
+		"border-radius",
+		"border-width",
+		"box-shadow",
+		"color",
+		"font-size",
+		"font-weight",
+		"letter-spacing",
+		"line-height",
+		"margin",
+		"opacity",
+		"padding",
+		"text-transform",
+	}
+)
+
+// Colors of the payment form in the #RRGGBB format, the empty colors are taken from the default form style
+type Colors struct {
+	Primary    string `json:"primary,omitempty"`
+	Secondary  string `json:"secondary,omitempty"`
+	Background string `json:"background,omitempty"`
+	Text       string `json:"text,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// FontSet is the fonts of the payment form by the name from Fonts
+type FontSet struct {
+	Body    string `json:"body,omitempty"`
+	Heading string `json:"heading,omitempty"`
+}
+
+// Layout options of the payment form
+type Layout struct {
+	Alignment    string `json:"alignment,omitempty"`
+	BorderRadius int    `json:"border_radius"`
+	Compact      bool   `json:"compact"`
+	HideLogo     bool   `json:"hide_logo"`
+}
+
+// CssRule is the custom style of the whitelisted payment form element
+type CssRule struct {
+	Selector   string            `json:"selector"`
+	Properties map[string]string `json:"properties"`
+}
+
+// Theme is the look of the hosted payment form of the project
+type Theme struct {
+	ProjectId string     `json:"project_id"`
+	Colors    *Colors    `json:"colors,omitempty"`
+	LogoUrl   string     `json:"logo_url,omitempty"`
+	Fonts     *FontSet   `json:"fonts,omitempty"`
+	Layout    *Layout    `json:"layout,omitempty"`
+	CustomCss []*CssRule `json:"custom_css,omitempty"`
+	UpdatedBy string     `json:"updated_by,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// NewTheme returns the empty theme of the project, the form with the empty theme uses the default style
+func NewTheme(projectId string) *Theme {
+	return &Theme{ProjectId: projectId}
+}
+
+// Validate checks the theme values, they are put to the css as is so the check must be strict
+func (t *Theme) Validate() error {
+	if t.ProjectId == "" {
+		return ErrorProjectIdEmpty
+	}
+
+	if t.Colors != nil {
+		for _, color := range []string{t.Colors.Primary, t.Colors.Secondary, t.Colors.Background, t.Colors.Text, t.Colors.Error} {
+			if color != "" && !colorRegexp.MatchString(color) {
+				return ErrorColorInvalid
+			}
+		}
+	}
+
+	if t.LogoUrl != "" && !isLogoUrl(t.LogoUrl) {
+		return ErrorLogoUrlInvalid
+	}
+
+	if t.Fonts != nil {
+		for _, font := range []string{t.Fonts.Body, t.Fonts.Heading} {
+			if _, ok := Fonts[font]; font != "" && !ok {
+				return ErrorFontUnknown
+			}
+		}
+	}
+
+	if t.Layout != nil {
+		if t.Layout.Alignment != "" && !contains(alignments, t.Layout.Alignment) {
+			return ErrorLayoutInvalid
+		}
+
+		if t.Layout.BorderRadius < 0 || t.Layout.BorderRadius > BorderRadiusMax {
+			return ErrorLayoutInvalid
+		}
+	}
+
+	if len(t.CustomCss) > CssRulesMax {
+		return ErrorCssRulesTooMany
+	}
+
+	for _, rule := range t.CustomCss {
+		if rule == nil || !contains(CssSelectors, rule.Selector) {
+			return ErrorCssSelectorDenied
+		}
+
+		for property, value := range rule.Properties {
+			if !contains(CssProperties, property) {
+				return ErrorCssPropertyDenied
+			}
+
+			if len(value) > CssValueMaxLength || !cssValueRegexp.MatchString(value) || cssValueForbiddenRegexp.MatchString(value) {
+				return ErrorCssValueInvalid
+			}
+		}
+	}
+
+	return nil
+}
+
+// Css returns the stylesheet of the theme. The theme values are exposed as the css variables of the form
+// and the custom rules are appended in the stable order.
+func (t *Theme) Css() []byte {
+	buf := &bytes.Buffer{}
+	vars := make([][2]string, 0)
+
+	if t.Colors != nil {
+		vars = appendVar(vars, "--paysuper-color-primary", t.Colors.Primary)
+		vars = appendVar(vars, "--paysuper-color-secondary", t.Colors.Secondary)
+		vars = appendVar(vars, "--paysuper-color-background", t.Colors.Background)
+		vars = appendVar(vars, "--paysuper-color-text", t.Colors.Text)
+		vars = appendVar(vars, "--paysuper-color-error", t.Colors.Error)
+	}
+
+	if t.LogoUrl != "" {
+		vars = appendVar(vars, "--paysuper-logo-url", fmt.Sprintf(`url("%s")`, t.LogoUrl))
+	}
+
+	if t.Fonts != nil {
+		vars = appendVar(vars, "--paysuper-font-body", Fonts[t.Fonts.Body])
+		vars = appendVar(vars, "--paysuper-font-heading", Fonts[t.Fonts.Heading])
+	}
+
+	if t.Layout != nil {
+		vars = appendVar(vars, "--paysuper-form-alignment", t.Layout.Alignment)
+		vars = appendVar(vars, "--paysuper-border-radius", fmt.Sprintf("%dpx", t.Layout.BorderRadius))
+	}
+
+	fmt.Fprintf(buf, "/* paysuper payment form theme of project %s */\n", t.ProjectId)
+
+	if len(vars) > 0 {
+		buf.WriteString(":root {\n")
+
+		for _, v := range vars {
+			fmt.Fprintf(buf, "  %s: %s;\n", v[0], v[1])
+		}
+
+		buf.WriteString("}\n")
+	}
+
+	for _, rule := range t.CustomCss {
+		properties := make([]string, 0, len(rule.Properties))
+
+		for property := range rule.Properties {
+			properties = append(properties, property)
+		}
+
+		sort.Strings(properties)
+		fmt.Fprintf(buf, "%s {\n", rule.Selector)
+
+		for _, property := range properties {
+			fmt.Fprintf(buf, "  %s: %s;\n", property, rule.Properties[property])
+		}
+
+		buf.WriteString("}\n")
+	}
+
+	return buf.Bytes()
+}
+
+func isLogoUrl(logoUrl string) bool {
+	if len(logoUrl) > LogoUrlMaxLength || strings.ContainsAny(logoUrl, logoUrlForbiddenSymbols) {
+		return false
+	}
+
+	u, err := url.Parse(logoUrl)
+
+	return err == nil && u.Scheme == "https" && u.Host != ""
+}
+
+func appendVar(vars [][2]string, name, value string) [][2]string {
+	if value == "" {
+		return vars
+	}
+
+	return append(vars, [2]string{name, value})
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,83 @@
+package theme
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestTheme_Validate(t *testing.T) {
+	theme := NewTheme("project_id")
+	assert.NoError(t, theme.Validate())
+
+	theme.Colors = &Colors{Primary: "#00AAFF", Text: "#000000"}
+	theme.LogoUrl = "https://cdn.pay.super.com/logo.png"
+	theme.Fonts = &FontSet{Body: "roboto", Heading: "montserrat"}
+	theme.Layout = &Layout{Alignment: AlignmentCenter, BorderRadius: 8}
+	theme.CustomCss = []*CssRule{
+		{Selector: ".paysuper-form__button", Properties: map[string]string{"box-shadow": "0 1px 2px rgba(0, 0, 0, 0.2)"}},
+	}
+	assert.NoError(t, theme.Validate())
+
+	cases := []struct {
+		change func(theme *Theme)
+		err    error
+	}{
+		{func(theme *Theme) { theme.ProjectId = "" }, ErrorProjectIdEmpty},
+		{func(theme *Theme) { theme.Colors.Background = "red" }, ErrorColorInvalid},
+		{func(theme *Theme) { theme.Colors.Error = "#fff" }, ErrorColorInvalid},
+		{func(theme *Theme) { theme.LogoUrl = "http://cdn.pay.super.com/logo.png" }, ErrorLogoUrlInvalid},
+		{func(theme *Theme) { theme.LogoUrl = `https://cdn.pay.super.com/logo.png");}body{color:red` }, ErrorLogoUrlInvalid},
+		{func(theme *Theme) { theme.Fonts.Body = "comic_sans" }, ErrorFontUnknown},
+		{func(theme *Theme) { theme.Layout.Alignment = "top" }, ErrorLayoutInvalid},
+		{func(theme *Theme) { theme.Layout.BorderRadius = BorderRadiusMax + 1 }, ErrorLayoutInvalid},
+		{func(theme *Theme) { theme.CustomCss = make([]*CssRule, CssRulesMax+1) }, ErrorCssRulesTooMany},
+		{func(theme *Theme) { theme.CustomCss[0].Selector = "body" }, ErrorCssSelectorDenied},
+		{func(theme *Theme) { theme.CustomCss[0].Properties["position"] = "fixed" }, ErrorCssPropertyDenied},
+		{func(theme *Theme) { theme.CustomCss[0].Properties["color"] = "red; } body { color: red" }, ErrorCssValueInvalid},
+		{func(theme *Theme) { theme.CustomCss[0].Properties["color"] = "url(https://evil)" }, ErrorCssValueInvalid},
+		{func(theme *Theme) {
+			theme.CustomCss[0].Properties["padding"] = strings.Repeat("1", CssValueMaxLength+1)
+		}, ErrorCssValueInvalid},
+	}
+
+	for _, c := range cases {
+		invalid := &Theme{
+			ProjectId: theme.ProjectId,
+			Colors:    &Colors{},
+			LogoUrl:   theme.LogoUrl,
+			Fonts:     &FontSet{},
+			Layout:    &Layout{},
+			CustomCss: []*CssRule{{Selector: ".paysuper-form", Properties: map[string]string{}}},
+		}
+		c.change(invalid)
+		assert.Equal(t, c.err, invalid.Validate())
+	}
+}
+
+func TestTheme_Css(t *testing.T) {
+	theme := NewTheme("project_id")
+	assert.Equal(t, "/* paysuper payment form theme of project project_id */\n", string(theme.Css()))
+
+	theme.Colors = &Colors{Primary: "#00AAFF"}
+	theme.LogoUrl = "https://cdn.pay.super.com/logo.png"
+	theme.Fonts = &FontSet{Body: "lato"}
+	theme.Layout = &Layout{BorderRadius: 4}
+	theme.CustomCss = []*CssRule{
+		{Selector: ".paysuper-form__button", Properties: map[string]string{"padding": "8px", "color": "#ffffff"}},
+	}
+
+	expected := `/* paysuper payment form theme of project project_id */
+:root {
+  --paysuper-color-primary: #00AAFF;
+  --paysuper-logo-url: url("https://cdn.pay.super.com/logo.png");
+  --paysuper-font-body: Lato, Arial, sans-serif;
+  --paysuper-border-radius: 4px;
+}
+.paysuper-form__button {
+  color: #ffffff;
+  padding: 8px;
+}
+`
+	assert.Equal(t, expected, string(theme.Css()))
+}
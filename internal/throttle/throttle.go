@@ -0,0 +1,77 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// Throttle limits the actions by the key: one action per interval and up to the limit of actions per window.
+// The actions are kept in memory of the process and aren't shared between the replicas,
+// so with N replicas behind the balancer the key may get up to N times the limit.
+type Throttle struct {
+	interval time.Duration
+	limit    int
+	window   time.Duration
+	mx       sync.Mutex
+	actions  map[string][]time.Time
+	sweptAt  time.Time
+}
+
+// New
+func New(interval time.Duration, limit int, window time.Duration) *Throttle {
+	return &Throttle{
+		interval: interval,
+		limit:    limit,
+		window:   window,
+		actions:  make(map[string][]time.Time),
+	}
+}
+
+// Allow registers the action of the key when it's allowed, otherwise it returns the time to wait for the next action
+func (t *Throttle) Allow(key string, now time.Time) (bool, time.Duration) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	if now.Sub(t.sweptAt) > t.window {
+		t.sweep(now)
+	}
+
+	actions := t.recent(key, now)
+
+	if len(actions) > 0 {
+		if wait := actions[len(actions)-1].Add(t.interval).Sub(now); wait > 0 {
+			return false, wait
+		}
+	}
+
+	if t.limit > 0 && len(actions) >= t.limit {
+		return false, actions[0].Add(t.window).Sub(now)
+	}
+
+	t.actions[key] = append(actions, now)
+
+	return true, 0
+}
+
+// recent returns the actions of the key made within the window
+func (t *Throttle) recent(key string, now time.Time) []time.Time {
+	actions := t.actions[key]
+	i := 0
+
+	for i < len(actions) && now.Sub(actions[i]) >= t.window {
+		i++
+	}
+
+	return actions[i:]
+}
+
+// sweep removes the keys without the actions within the window
+func (t *Throttle) sweep(now time.Time) {
+	for key := range t.actions {
+		if len(t.recent(key, now)) <= 0 {
+			delete(t.actions, key)
+		}
+	}
+
+	t.sweptAt = now
+}
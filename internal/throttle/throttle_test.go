@@ -0,0 +1,38 @@
+package throttle
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestThrottle_Allow(t *testing.T) {
+	throttle := New(time.Minute, 3, time.Hour)
+	now := time.Date(2019, 12, 1, 10, 0, 0, 0, time.UTC)
+
+	ok, _ := throttle.Allow("order", now)
+	assert.True(t, ok)
+
+	ok, wait := throttle.Allow("order", now.Add(20*time.Second))
+	assert.False(t, ok)
+	assert.Equal(t, 40*time.Second, wait)
+
+	ok, _ = throttle.Allow("another_order", now.Add(20*time.Second))
+	assert.True(t, ok)
+
+	ok, _ = throttle.Allow("order", now.Add(time.Minute))
+	assert.True(t, ok)
+	ok, _ = throttle.Allow("order", now.Add(2*time.Minute))
+	assert.True(t, ok)
+
+	ok, wait = throttle.Allow("order", now.Add(10*time.Minute))
+	assert.False(t, ok)
+	assert.Equal(t, 50*time.Minute, wait)
+
+	ok, _ = throttle.Allow("order", now.Add(time.Hour))
+	assert.True(t, ok)
+
+	throttle.Allow("order", now.Add(3*time.Hour))
+	assert.Len(t, throttle.actions, 1)
+	assert.Len(t, throttle.actions["order"], 1)
+}
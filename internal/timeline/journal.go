@@ -0,0 +1,161 @@
+package timeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	awsWrapper "github.com/paysuper/paysuper-aws-manager"
+	"github.com/paysuper/paysuper-management-api/internal/storage"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	EventOrderCreated          = "order.created"
+	EventOrderProcessed        = "order.processed"
+	EventLanguageChanged       = "order.language_changed"
+	EventCustomerChanged       = "order.customer_changed"
+	EventBillingAddressChanged = "order.billing_address_changed"
+	EventPlatformChanged       = "order.platform_changed"
+	EventPaymentAttempted      = "payment.attempted"
+	EventPaymentCallback       = "payment.callback"
+	EventRefundCreated         = "refund.created"
+	EventRefundCallback        = "refund.callback"
+	EventCodeReplaced          = "key.replaced"
+	EventReceiptViewed         = "receipt.viewed"
+	EventReceiptSent           = "receipt.sent"
+
+	SourceOrder   = "order"
+	SourceRefund  = "refund"
+	SourceJournal = "journal"
+
+	ActorCustomer      = "customer"
+	ActorPaymentSystem = "payment_system"
+
+	journalFileMask     = "orders/%s/timeline.json"
+	journalMaxEvents    = 500
+	journalWriters      = 32
+	journalWriteTimeout = 30 * time.Second
+)
+
+var ErrorJournalBusy = errors.New("order timeline journal is busy")
+
+// Event is the single step of the order lifecycle
+type Event struct {
+	Type       string            `json:"type"`
+	OccurredAt time.Time         `json:"occurred_at"`
+	Source     string            `json:"source"`
+	Actor      string            `json:"actor,omitempty"`
+	Details    map[string]string `json:"details,omitempty"`
+}
+
+// Journal keeps the order events passing through the API in the reporter bucket.
+// The events are stored as the JSON list per order, only the latest events of the order are kept.
+// The list of the order is updated under the lock of the order, so the events of the different orders
+// are written concurrently and the events of the same order are not lost between the replicas.
+type Journal struct {
+	files   *storage.Store
+	writers chan struct{}
+	pending sync.WaitGroup
+}
+
+// NewJournal
+func NewJournal(awsManager awsWrapper.AwsManagerInterface) *Journal {
+	return &Journal{files: storage.New(awsManager), writers: make(chan struct{}, journalWriters)}
+}
+
+// Async runs the journal write outside of the request, so the storage never slows down the payment requests.
+// The number of the concurrent writes is bounded, ErrorJournalBusy is returned and the write is dropped
+// when all the writers are busy.
+func (j *Journal) Async(write func(ctx context.Context)) error {
+	select {
+	case j.writers <- struct{}{}:
+	default:
+		return ErrorJournalBusy
+	}
+
+	j.pending.Add(1)
+
+	go func() {
+		defer func() {
+			<-j.writers
+			j.pending.Done()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), journalWriteTimeout)
+		defer cancel()
+
+		write(ctx)
+	}()
+
+	return nil
+}
+
+// Wait blocks until the started asynchronous writes are finished
+func (j *Journal) Wait() {
+	j.pending.Wait()
+}
+
+// Record appends the event to the journal of the order
+func (j *Journal) Record(ctx context.Context, orderId string, event *Event) error {
+	events := make([]*Event, 0)
+
+	return j.files.Update(ctx, fmt.Sprintf(journalFileMask, orderId), &events, func(found bool) error {
+		if event.OccurredAt.IsZero() {
+			event.OccurredAt = time.Now().UTC()
+		}
+
+		event.Source = SourceJournal
+		events = append(events, event)
+
+		if len(events) > journalMaxEvents {
+			events = events[len(events)-journalMaxEvents:]
+		}
+
+		return nil
+	})
+}
+
+// List returns the journal events of the order identifiers ordered by the time.
+// The order may be known by several identifiers, e.g. by the uuid for the payment form and by the id for the callbacks.
+func (j *Journal) List(ctx context.Context, orderIds ...string) ([]*Event, error) {
+	events := make([]*Event, 0)
+	seen := make(map[string]bool, len(orderIds))
+
+	for _, orderId := range orderIds {
+		if orderId == "" || seen[orderId] {
+			continue
+		}
+
+		seen[orderId] = true
+		orderEvents, err := j.load(ctx, orderId)
+
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, orderEvents...)
+	}
+
+	Sort(events)
+
+	return events, nil
+}
+
+// Sort orders the events by the time keeping the order of the simultaneous events
+func Sort(events []*Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].OccurredAt.Before(events[j].OccurredAt)
+	})
+}
+
+func (j *Journal) load(ctx context.Context, orderId string) ([]*Event, error) {
+	events := make([]*Event, 0)
+
+	if _, err := j.files.Load(ctx, fmt.Sprintf(journalFileMask, orderId), &events); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
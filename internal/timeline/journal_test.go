@@ -0,0 +1,36 @@
+package timeline
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestJournal_Async_Busy(t *testing.T) {
+	journal := NewJournal(nil)
+	release := make(chan struct{})
+	written := 0
+
+	for i := 0; i < journalWriters; i++ {
+		err := journal.Async(func(ctx context.Context) {
+			<-release
+		})
+		assert.NoError(t, err)
+	}
+
+	err := journal.Async(func(ctx context.Context) {
+		written++
+	})
+	assert.Equal(t, ErrorJournalBusy, err)
+
+	close(release)
+	journal.Wait()
+
+	err = journal.Async(func(ctx context.Context) {
+		written++
+	})
+	assert.NoError(t, err)
+
+	journal.Wait()
+	assert.Equal(t, 1, written)
+}
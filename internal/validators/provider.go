@@ -1,14 +1,21 @@
 package validators
 
 import (
+	"github.com/ProtocolONE/go-core/v2/pkg/config"
 	"github.com/ProtocolONE/go-core/v2/pkg/provider"
 	"github.com/google/wire"
 	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/postcode"
 )
 
 // Provider
-func Provider(services common.Services, set provider.AwareSet) (*ValidatorSet, func(), error) {
-	g := New(services, set)
+func Provider(services common.Services, initial config.Initial, set provider.AwareSet) (*ValidatorSet, func(), error) {
+	postcodes := postcode.Default()
+	postcodes.SetPath(initial.WorkDir + postcode.DefaultFile)
+	if err := postcodes.Reload(); err != nil {
+		return nil, func() {}, err
+	}
+	g := New(services, postcodes, set)
 	return g, func() {}, nil
 }
 
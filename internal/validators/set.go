@@ -10,13 +10,15 @@ import (
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/billing"
 	"github.com/paysuper/paysuper-billing-server/pkg/proto/grpc"
 	"github.com/paysuper/paysuper-management-api/internal/dispatcher/common"
+	"github.com/paysuper/paysuper-management-api/internal/postcode"
 	"github.com/ttacon/libphonenumber"
 	"gopkg.in/go-playground/validator.v9"
 	"regexp"
 )
 
 type ValidatorSet struct {
-	services common.Services
+	services  common.Services
+	postcodes *postcode.Formats
 	provider.LMT
 }
 
@@ -51,7 +53,6 @@ var (
 	zipUsaRegexp      = regexp.MustCompile("^[0-9]{5}(?:-[0-9]{4})?$")
 	nameRegexp        = regexp.MustCompile("^[\\p{L}\\p{M} \\-\\']+$")
 	companyNameRegexp = regexp.MustCompile("^[\\p{L}\\p{M} \\-\\.0-9\"]+$")
-	zipGeneralRegexp  = regexp.MustCompile("^\\d{0,30}$")
 	swiftRegexp       = regexp.MustCompile("^[A-Z]{6}[A-Z0-9]{2}([A-Z0-9]{3})?$")
 	cityRegexp        = regexp.MustCompile("^[\\p{L}\\p{M} \\-\\.]+$")
 	localeRegexp      = regexp.MustCompile("^[a-z]{2}-[A-Z]{2,10}$")
@@ -164,15 +165,7 @@ func (v *ValidatorSet) CompanyNameValidator(fl validator.FieldLevel) bool {
 func (v *ValidatorSet) MerchantCompanyValidator(sl validator.StructLevel) {
 	company := sl.Current().Interface().(billing.MerchantCompanyInfo)
 
-	reg := zipGeneralRegexp
-
-	if v, ok := common.ZipRegexp[company.Country]; ok {
-		reg = v
-	}
-
-	match := reg.MatchString(company.Zip)
-
-	if !match {
+	if !v.postcodes.Match(company.Country, company.Zip) {
 		sl.ReportError(company.Zip, "Zip", "zip", "zip", "")
 	}
 }
@@ -211,7 +204,7 @@ func (v *ValidatorSet) UserLocaleValidator(fl validator.FieldLevel) bool {
 }
 
 // New
-func New(services common.Services, set provider.AwareSet) *ValidatorSet {
+func New(services common.Services, postcodes *postcode.Formats, set provider.AwareSet) *ValidatorSet {
 	set.Logger = set.Logger.WithFields(logger.Fields{"service": Prefix})
-	return &ValidatorSet{services: services, LMT: &set}
+	return &ValidatorSet{services: services, postcodes: postcodes, LMT: &set}
 }
@@ -0,0 +1,361 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	MimeType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+	workbookPath     = "xl/workbook.xml"
+	workbookRelsPath = "xl/_rels/workbook.xml.rels"
+	sharedStringPath = "xl/sharedStrings.xml"
+	defaultSheetPath = "xl/worksheets/sheet1.xml"
+
+	cellTypeSharedString = "s"
+	cellTypeInlineString = "inlineStr"
+	cellTypeBoolean      = "b"
+)
+
+var (
+	ErrorFileInvalid  = errors.New("xlsx file is invalid")
+	ErrorSheetMissing = errors.New("xlsx file has no worksheets")
+)
+
+type xmlWorkbook struct {
+	Sheets []struct {
+		RelationId string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xmlRelationships struct {
+	Relationships []struct {
+		Id     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xmlSharedStrings struct {
+	Items []xmlText `xml:"si"`
+}
+
+type xmlText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t *xmlText) String() string {
+	if len(t.Runs) <= 0 {
+		return t.Text
+	}
+
+	text := ""
+
+	for _, run := range t.Runs {
+		text += run.Text
+	}
+
+	return text
+}
+
+type xmlWorksheet struct {
+	Rows []struct {
+		Cells []struct {
+			Ref    string  `xml:"r,attr"`
+			Type   string  `xml:"t,attr"`
+			Value  string  `xml:"v"`
+			Inline xmlText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// Read returns the cell values of the first worksheet of the file. The empty cells are returned as the empty strings,
+// the numbers and booleans are returned as they're stored in the file.
+func Read(r io.ReaderAt, size int64) ([][]string, error) {
+	archive, err := zip.NewReader(r, size)
+
+	if err != nil {
+		return nil, ErrorFileInvalid
+	}
+
+	files := make(map[string]*zip.File, len(archive.File))
+
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	sheetPath, err := firstSheetPath(files)
+
+	if err != nil {
+		return nil, err
+	}
+
+	strs := &xmlSharedStrings{}
+
+	if file, ok := files[sharedStringPath]; ok {
+		if err = readXml(file, strs); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet := &xmlWorksheet{}
+
+	if err = readXml(files[sheetPath], sheet); err != nil {
+		return nil, err
+	}
+
+	rows := make([][]string, 0, len(sheet.Rows))
+
+	for _, xmlRow := range sheet.Rows {
+		row := make([]string, 0, len(xmlRow.Cells))
+
+		for i, cell := range xmlRow.Cells {
+			column := i
+
+			if cell.Ref != "" {
+				if column, err = columnIndex(cell.Ref); err != nil {
+					return nil, err
+				}
+			}
+
+			for len(row) < column {
+				row = append(row, "")
+			}
+
+			value := cell.Value
+
+			switch cell.Type {
+			case cellTypeSharedString:
+				idx, err := strconv.Atoi(cell.Value)
+
+				if err != nil || idx < 0 || idx >= len(strs.Items) {
+					return nil, ErrorFileInvalid
+				}
+
+				value = strs.Items[idx].String()
+			case cellTypeInlineString:
+				value = cell.Inline.String()
+			case cellTypeBoolean:
+				value = strconv.FormatBool(cell.Value == "1")
+			}
+
+			if column < len(row) {
+				row[column] = value
+			} else {
+				row = append(row, value)
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// Write returns the file with the single worksheet containing the rows as the inline strings
+func Write(sheetName string, rows [][]string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	archive := zip.NewWriter(buf)
+	files := []struct {
+		name    string
+		content string
+	}{
+		{
+			name: "[Content_Types].xml",
+			content: `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+				`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+				`<Default Extension="xml" ContentType="application/xml"/>` +
+				`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+				`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+				`</Types>`,
+		},
+		{
+			name: "_rels/.rels",
+			content: `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+				`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+				`</Relationships>`,
+		},
+		{
+			name: workbookPath,
+			content: `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+				`<sheets><sheet name="` + escape(sheetName) + `" sheetId="1" r:id="rId1"/></sheets>` +
+				`</workbook>`,
+		},
+		{
+			name: workbookRelsPath,
+			content: `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+				`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+				`</Relationships>`,
+		},
+		{
+			name:    defaultSheetPath,
+			content: worksheet(rows),
+		},
+	}
+
+	for _, file := range files {
+		w, err := archive.Create(file.name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err = io.WriteString(w, xml.Header+file.content); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func worksheet(rows [][]string) string {
+	sb := &strings.Builder{}
+	sb.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	for i, row := range rows {
+		sb.WriteString(`<row r="` + strconv.Itoa(i+1) + `">`)
+
+		for j, value := range row {
+			sb.WriteString(`<c r="` + columnName(j) + strconv.Itoa(i+1) + `" t="inlineStr"><is><t xml:space="preserve">`)
+			sb.WriteString(escape(value))
+			sb.WriteString(`</t></is></c>`)
+		}
+
+		sb.WriteString(`</row>`)
+	}
+
+	sb.WriteString(`</sheetData></worksheet>`)
+
+	return sb.String()
+}
+
+// firstSheetPath resolves the path of the first worksheet through the workbook relationships
+func firstSheetPath(files map[string]*zip.File) (string, error) {
+	workbook := &xmlWorkbook{}
+	rels := &xmlRelationships{}
+
+	wbFile, wbOk := files[workbookPath]
+	relsFile, relsOk := files[workbookRelsPath]
+
+	if !wbOk || !relsOk {
+		if _, ok := files[defaultSheetPath]; ok {
+			return defaultSheetPath, nil
+		}
+
+		return "", ErrorSheetMissing
+	}
+
+	if err := readXml(wbFile, workbook); err != nil {
+		return "", err
+	}
+
+	if err := readXml(relsFile, rels); err != nil {
+		return "", err
+	}
+
+	if len(workbook.Sheets) <= 0 {
+		return "", ErrorSheetMissing
+	}
+
+	for _, rel := range rels.Relationships {
+		if rel.Id != workbook.Sheets[0].RelationId {
+			continue
+		}
+
+		target := rel.Target
+
+		if strings.HasPrefix(target, "/") {
+			target = strings.TrimPrefix(target, "/")
+		} else {
+			target = path.Join(path.Dir(workbookPath), target)
+		}
+
+		if _, ok := files[target]; ok {
+			return target, nil
+		}
+	}
+
+	return "", ErrorSheetMissing
+}
+
+func readXml(file *zip.File, v interface{}) error {
+	rc, err := file.Open()
+
+	if err != nil {
+		return ErrorFileInvalid
+	}
+
+	defer func() {
+		if err := rc.Close(); err != nil {
+			return
+		}
+	}()
+
+	data, err := ioutil.ReadAll(rc)
+
+	if err != nil {
+		return ErrorFileInvalid
+	}
+
+	if err = xml.Unmarshal(data, v); err != nil {
+		return ErrorFileInvalid
+	}
+
+	return nil
+}
+
+// columnIndex returns the zero based column index of the cell reference like "AB12"
+func columnIndex(ref string) (int, error) {
+	column := 0
+	letters := 0
+
+	for _, r := range strings.ToUpper(ref) {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+
+		column = column*26 + int(r-'A'+1)
+		letters++
+	}
+
+	if letters <= 0 {
+		return 0, ErrorFileInvalid
+	}
+
+	return column - 1, nil
+}
+
+// columnName returns the column letters of the zero based column index
+func columnName(index int) string {
+	name := ""
+
+	for index++; index > 0; index = (index - 1) / 26 {
+		name = string(rune('A'+(index-1)%26)) + name
+	}
+
+	return name
+}
+
+func escape(value string) string {
+	buf := &bytes.Buffer{}
+
+	if err := xml.EscapeText(buf, []byte(value)); err != nil {
+		return ""
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,71 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestWrite_Read(t *testing.T) {
+	rows := [][]string{
+		{"type", "method", "percent"},
+		{"channel_system", "VISA & <MC>", "0.015"},
+		{"", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "last"},
+	}
+
+	data, err := Write("costs", rows)
+	require.NoError(t, err)
+
+	result, err := Read(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	assert.Equal(t, rows, result)
+}
+
+func TestRead_SharedStrings(t *testing.T) {
+	files := map[string]string{
+		workbookPath: `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+			`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Sheet" sheetId="1" r:id="rId3"/></sheets></workbook>`,
+		workbookRelsPath: `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId3" Target="worksheets/data.xml"/></Relationships>`,
+		sharedStringPath: `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<si><t>method</t></si><si><r><t>VI</t></r><r><t>SA</t></r></si></sst>`,
+		"xl/worksheets/data.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+			`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="C1" t="b"><v>1</v></c></row>` +
+			`<row r="2"><c r="A2" t="s"><v>1</v></c><c r="B2"><v>1.4999999999999999E-2</v></c></row>` +
+			`</sheetData></worksheet>`,
+	}
+
+	buf := &bytes.Buffer{}
+	archive := zip.NewWriter(buf)
+
+	for name, content := range files {
+		w, err := archive.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, archive.Close())
+
+	result, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"method", "", "true"}, {"VISA", "1.4999999999999999E-2"}}, result)
+}
+
+func TestRead_Error(t *testing.T) {
+	_, err := Read(bytes.NewReader([]byte("type,method")), 11)
+	assert.Equal(t, ErrorFileInvalid, err)
+}
+
+func TestColumnName(t *testing.T) {
+	for index, name := range map[int]string{0: "A", 25: "Z", 26: "AA", 701: "ZZ", 702: "AAA"} {
+		assert.Equal(t, name, columnName(index))
+
+		idx, err := columnIndex(name + "1")
+		require.NoError(t, err)
+		assert.Equal(t, index, idx)
+	}
+}
@@ -0,0 +1,198 @@
+// Package signature implements the request body signing scheme of the PaySuper API.
+//
+// The signature is the lowercase hex encoded SHA-512 hash of the raw request body
+// concatenated with the project secret key. It is sent in the X-API-SIGNATURE header.
+// The body must be signed exactly as it is sent: any reformatting of JSON after signing
+// breaks the signature.
+package signature
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	Header    = "X-API-SIGNATURE"
+	Algorithm = "sha512"
+	Formula   = "hex(sha512(body + secret_key))"
+
+	HintSignatureEmpty     = "signature is empty, it must be passed in the X-API-SIGNATURE header"
+	HintUppercaseHex       = "signature must be encoded with the lowercase hex"
+	HintSecretKeyPrepended = "secret key must be appended to the body, not prepended"
+	HintSha256Used         = "signature must be calculated with sha512, not sha256"
+	HintBodyWhitespace     = "signature was calculated for the body without the leading or trailing whitespaces, sign the body exactly as it is sent"
+	HintBodyReformatted    = "signature was calculated for the differently formatted JSON, sign the body exactly as it is sent"
+
+	secretKeyVisibleChars = 2
+	secretKeyMask         = "***"
+)
+
+// Explanation describes how the expected signature of the body is calculated
+// and why the provided signature doesn't match it
+type Explanation struct {
+	Header     string   `json:"header"`
+	Algorithm  string   `json:"algorithm"`
+	Formula    string   `json:"formula"`
+	BodyLength int      `json:"body_length"`
+	SecretKey  string   `json:"secret_key"`
+	Expected   string   `json:"expected"`
+	Provided   string   `json:"provided"`
+	Match      bool     `json:"match"`
+	Hints      []string `json:"hints"`
+}
+
+// Sign returns the signature of the body with the secret key
+func Sign(body []byte, secretKey string) string {
+	h := sha512.New()
+	h.Write(body)
+	h.Write([]byte(secretKey))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Verify checks the signature of the body with the secret key
+func Verify(body []byte, secretKey, signature string) bool {
+	expected := Sign(body, secretKey)
+
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(signature)) == 1
+}
+
+// SignRequest sets the signature header of the request. The request body is left readable.
+func SignRequest(req *http.Request, secretKey string) error {
+	body, err := readBody(req)
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set(Header, Sign(body, secretKey))
+
+	return nil
+}
+
+// VerifyRequest checks the signature header of the request. The request body is left readable.
+func VerifyRequest(req *http.Request, secretKey string) (bool, error) {
+	body, err := readBody(req)
+
+	if err != nil {
+		return false, err
+	}
+
+	return Verify(body, secretKey, req.Header.Get(Header)), nil
+}
+
+// Explain returns the calculation of the expected signature and the hints about the common mistakes
+// which give the provided signature
+func Explain(body []byte, secretKey, signature string) *Explanation {
+	explanation := &Explanation{
+		Header:     Header,
+		Algorithm:  Algorithm,
+		Formula:    Formula,
+		BodyLength: len(body),
+		SecretKey:  maskSecretKey(secretKey),
+		Expected:   Sign(body, secretKey),
+		Provided:   signature,
+		Hints:      make([]string, 0),
+	}
+	explanation.Match = Verify(body, secretKey, signature)
+
+	if explanation.Match {
+		return explanation
+	}
+
+	if signature == "" {
+		explanation.Hints = append(explanation.Hints, HintSignatureEmpty)
+		return explanation
+	}
+
+	if hint := findMistake(body, secretKey, strings.ToLower(signature)); hint != "" {
+		explanation.Hints = append(explanation.Hints, hint)
+	}
+
+	if signature != strings.ToLower(signature) {
+		explanation.Hints = append(explanation.Hints, HintUppercaseHex)
+	}
+
+	return explanation
+}
+
+func findMistake(body []byte, secretKey, signature string) string {
+	if signature == Sign(body, secretKey) {
+		return ""
+	}
+
+	prepended := sha512.Sum512(append([]byte(secretKey), body...))
+
+	if signature == hex.EncodeToString(prepended[:]) {
+		return HintSecretKeyPrepended
+	}
+
+	sha := sha256.Sum256(append(append([]byte{}, body...), []byte(secretKey)...))
+
+	if signature == hex.EncodeToString(sha[:]) {
+		return HintSha256Used
+	}
+
+	if trimmed := bytes.TrimSpace(body); len(trimmed) != len(body) && signature == Sign(trimmed, secretKey) {
+		return HintBodyWhitespace
+	}
+
+	var value interface{}
+
+	if err := json.Unmarshal(body, &value); err != nil {
+		return ""
+	}
+
+	compacted := &bytes.Buffer{}
+
+	if err := json.Compact(compacted, body); err == nil && signature == Sign(compacted.Bytes(), secretKey) {
+		return HintBodyReformatted
+	}
+
+	indented := &bytes.Buffer{}
+
+	if err := json.Indent(indented, body, "", "  "); err == nil && signature == Sign(indented.Bytes(), secretKey) {
+		return HintBodyReformatted
+	}
+
+	if marshaled, err := json.Marshal(value); err == nil && signature == Sign(marshaled, secretKey) {
+		return HintBodyReformatted
+	}
+
+	return ""
+}
+
+func maskSecretKey(secretKey string) string {
+	if len(secretKey) <= secretKeyVisibleChars*2 {
+		return secretKeyMask
+	}
+
+	return secretKey[:secretKeyVisibleChars] + secretKeyMask + secretKey[len(secretKey)-secretKeyVisibleChars:]
+}
+
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return []byte{}, nil
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = req.Body.Close(); err != nil {
+		return nil, err
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
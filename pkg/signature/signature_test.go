@@ -0,0 +1,99 @@
+package signature
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type vector struct {
+	Name      string `json:"name"`
+	Body      string `json:"body"`
+	SecretKey string `json:"secret_key"`
+	Signature string `json:"signature"`
+}
+
+func loadVectors(t *testing.T) []*vector {
+	data, err := ioutil.ReadFile("testdata/vectors.json")
+	require.NoError(t, err)
+
+	var vectors []*vector
+	require.NoError(t, json.Unmarshal(data, &vectors))
+	require.NotEmpty(t, vectors)
+
+	return vectors
+}
+
+func TestSignature_Vectors(t *testing.T) {
+	for _, v := range loadVectors(t) {
+		t.Run(v.Name, func(t *testing.T) {
+			assert.Equal(t, v.Signature, Sign([]byte(v.Body), v.SecretKey))
+			assert.True(t, Verify([]byte(v.Body), v.SecretKey, v.Signature))
+			assert.False(t, Verify([]byte(v.Body), v.SecretKey+"x", v.Signature))
+			assert.False(t, Verify([]byte(v.Body+" "), v.SecretKey, v.Signature))
+		})
+	}
+}
+
+func TestSignature_Request(t *testing.T) {
+	body := `{"amount":1}`
+	req, err := http.NewRequest(http.MethodPost, "http://localhost/api/v1/tokens", strings.NewReader(body))
+	require.NoError(t, err)
+
+	require.NoError(t, SignRequest(req, "secret"))
+	assert.Equal(t, Sign([]byte(body), "secret"), req.Header.Get(Header))
+
+	ok, err := VerifyRequest(req, "secret")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	data, err := ioutil.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(data))
+
+	ok, err = VerifyRequest(req, "other")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestSignature_Explain(t *testing.T) {
+	body := []byte("{\"amount\": 1}\n")
+	key := "secret_key"
+
+	prepended := sha512.Sum512([]byte(key + string(body)))
+	sha := sha256.Sum256([]byte(string(body) + key))
+
+	cases := []struct {
+		name      string
+		signature string
+		match     bool
+		hints     []string
+	}{
+		{"valid", Sign(body, key), true, []string{}},
+		{"empty", "", false, []string{HintSignatureEmpty}},
+		{"uppercase", strings.ToUpper(Sign(body, key)), false, []string{HintUppercaseHex}},
+		{"prepended", hex.EncodeToString(prepended[:]), false, []string{HintSecretKeyPrepended}},
+		{"sha256", hex.EncodeToString(sha[:]), false, []string{HintSha256Used}},
+		{"trimmed", Sign([]byte(`{"amount": 1}`), key), false, []string{HintBodyWhitespace}},
+		{"compacted", Sign([]byte(`{"amount":1}`), key), false, []string{HintBodyReformatted}},
+		{"unknown", Sign(body, "other"), false, []string{}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			explanation := Explain(body, key, c.signature)
+			assert.Equal(t, c.match, explanation.Match)
+			assert.Equal(t, c.hints, explanation.Hints)
+			assert.Equal(t, Sign(body, key), explanation.Expected)
+			assert.Equal(t, len(body), explanation.BodyLength)
+			assert.Equal(t, "se***ey", explanation.SecretKey)
+		})
+	}
+}